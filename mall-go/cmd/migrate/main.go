@@ -94,10 +94,50 @@ func migrate(db *gorm.DB) error {
 		&model.ProductImage{},
 		&model.Cart{},
 		&model.CartItem{},
+		&model.CartMergeLog{},
 		&model.Order{},
 		&model.OrderItem{},
 		&model.Payment{},
 		&model.File{},
+		&model.Currency{},
+		&model.ExchangeRate{},
+		&model.CommissionRate{},
+		&model.SettlementStatement{},
+		&model.SettlementLine{},
+		&model.SettlementPayout{},
+		&model.MerchantSettlementAccount{},
+		&model.PaymentUserLimit{},
+		&model.PaymentCallback{},
+		&model.PaymentCallbackAttempt{},
+		&model.PaymentDispute{},
+		&model.PaymentDisputeEvidence{},
+		&model.PaymentDailyRollup{},
+		&model.SubscriptionPlan{},
+		&model.Subscription{},
+		&model.SubscriptionCycle{},
+		&model.WithholdingAgreement{},
+		&model.GiftCardBatch{},
+		&model.GiftCard{},
+		&model.GiftCardTransaction{},
+		&model.Promotion{},
+		&model.MemberLevel{},
+		&model.UserMembership{},
+		&model.MemberGrowthLog{},
+		&model.MemberLevelChange{},
+		&model.FavoriteFolder{},
+		&model.FavoriteItem{},
+		&model.FavoriteAlert{},
+		&model.CartReminder{},
+		&model.CartShare{},
+		&model.CartShareItem{},
+		&model.ProductSimilarity{},
+		&model.ProductBundle{},
+		&model.PriceSchedule{},
+		&model.PriceHistory{},
+		&model.CustomerGroup{},
+		&model.CustomerGroupMember{},
+		&model.PriceList{},
+		&model.PriceListItem{},
 	}
 
 	// 执行自动迁移
@@ -8,7 +8,10 @@ import (
 	"path/filepath"
 	"text/tabwriter"
 
+	"mall-go/internal/config"
+	"mall-go/pkg/database"
 	"mall-go/pkg/payment"
+	"mall-go/pkg/payment/secret"
 )
 
 // ConfigCLI 配置命令行工具
@@ -21,7 +24,7 @@ func main() {
 	// 定义命令行参数
 	var (
 		configPath = flag.String("config", "./config/payment.json", "配置文件路径")
-		command    = flag.String("cmd", "", "执行命令: generate|validate|migrate|compare|backup|restore")
+		command    = flag.String("cmd", "", "执行命令: generate|validate|migrate|compare|backup|restore|gen-key|rotate-key")
 		env        = flag.String("env", "dev", "环境类型: dev|test|prod")
 		force      = flag.Bool("force", false, "强制覆盖现有文件")
 		fromVer    = flag.String("from", "", "迁移源版本")
@@ -29,6 +32,7 @@ func main() {
 		compare    = flag.String("compare-with", "", "比较的配置文件路径")
 		backup     = flag.String("backup", "", "备份文件名")
 		output     = flag.String("output", "", "输出格式: json|table")
+		withDB     = flag.Bool("db", false, "轮换密钥时同时重新加密数据库中的支付方式配置")
 	)
 	flag.Parse()
 
@@ -52,6 +56,10 @@ func main() {
 		cli.listBackups(*output)
 	case "restore":
 		cli.restoreConfig(*backup)
+	case "gen-key":
+		cli.generateKey()
+	case "rotate-key":
+		cli.rotateKey(*withDB)
 	case "help":
 		cli.showHelp()
 	default:
@@ -231,6 +239,51 @@ func (cli *ConfigCLI) restoreConfig(backupFile string) {
 	fmt.Printf("📝 建议重新验证配置文件\n")
 }
 
+// generateKey 生成新的主密钥
+func (cli *ConfigCLI) generateKey() {
+	key, err := secret.GenerateKey()
+	if err != nil {
+		fmt.Printf("❌ 生成主密钥失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(key)
+	fmt.Fprintf(os.Stderr, "📝 请将主密钥保存到 %s 或 %s 指向的文件中，切勿与配置文件放在一起\n",
+		secret.EnvMasterKey, secret.EnvMasterKeyFile)
+}
+
+// rotateKey 使用当前主密钥重新加密配置文件（及数据库）中的支付密钥
+func (cli *ConfigCLI) rotateKey(withDB bool) {
+	keyring, err := secret.LoadKeyring()
+	if err != nil {
+		fmt.Printf("❌ 加载主密钥失败: %v\n", err)
+		fmt.Printf("   新主密钥放在 %s（或 %s 的第一行），旧主密钥放在 %s\n",
+			secret.EnvMasterKey, secret.EnvMasterKeyFile, secret.EnvPreviousMasterKey)
+		os.Exit(1)
+	}
+
+	fmt.Printf("🔐 正在使用主密钥 %s 重新加密支付密钥...\n", keyring.PrimaryKeyID())
+
+	rotated, err := cli.tool.RotateSecrets(keyring)
+	if err != nil {
+		fmt.Printf("❌ 配置文件密钥轮换失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ 配置文件已重新加密 %d 个字段: %s\n", rotated, cli.configPath)
+
+	if withDB {
+		config.Load()
+		rotated, err := payment.RotateDBSecrets(database.Init(), keyring)
+		if err != nil {
+			fmt.Printf("❌ 数据库支付配置密钥轮换失败 (已完成 %d 条): %v\n", rotated, err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ 数据库已重新加密 %d 条支付方式配置\n", rotated)
+	}
+
+	fmt.Printf("📝 确认服务均已使用新主密钥后，再移除 %s 中的旧主密钥\n", secret.EnvPreviousMasterKey)
+}
+
 // outputJSON 输出JSON格式
 func (cli *ConfigCLI) outputJSON(data interface{}) {
 	jsonData, err := json.MarshalIndent(data, "", "  ")
@@ -269,6 +322,8 @@ func (cli *ConfigCLI) showHelp() {
 	fmt.Println("  compare   比较两个配置文件")
 	fmt.Println("  backup    列出备份文件")
 	fmt.Println("  restore   从备份恢复配置")
+	fmt.Println("  gen-key   生成新的支付密钥主密钥")
+	fmt.Println("  rotate-key  使用当前主密钥重新加密支付密钥（明文密钥同时被加密）")
 	fmt.Println("  help      显示帮助信息")
 	fmt.Println()
 	fmt.Println("参数:")
@@ -281,6 +336,7 @@ func (cli *ConfigCLI) showHelp() {
 	fmt.Println("  -compare-with  比较的配置文件路径")
 	fmt.Println("  -backup   备份文件名")
 	fmt.Println("  -output   输出格式: json|table (默认: table)")
+	fmt.Println("  -db       rotate-key 时同时重新加密数据库中的支付方式配置")
 	fmt.Println()
 	fmt.Println("示例:")
 	fmt.Println("  # 生成开发环境配置")
@@ -297,6 +353,9 @@ func (cli *ConfigCLI) showHelp() {
 	fmt.Println()
 	fmt.Println("  # 恢复配置")
 	fmt.Println("  ./payment-config -cmd=restore -backup=payment_20240101_120000.json")
+	fmt.Println()
+	fmt.Println("  # 轮换主密钥")
+	fmt.Println("  PAYMENT_MASTER_KEY=<新密钥> PAYMENT_MASTER_KEY_PREVIOUS=<旧密钥> ./payment-config -cmd=rotate-key -db")
 }
 
 // showUsage 显示使用方法
@@ -305,7 +364,7 @@ func (cli *ConfigCLI) showUsage() {
 	fmt.Println("使用方法: ./payment-config -cmd=<command> [options]")
 	fmt.Println()
 	fmt.Println("可用命令:")
-	fmt.Println("  generate, validate, migrate, compare, backup, restore, help")
+	fmt.Println("  generate, validate, migrate, compare, backup, restore, gen-key, rotate-key, help")
 	fmt.Println()
 	fmt.Println("使用 -cmd=help 查看详细帮助信息")
 	fmt.Println()
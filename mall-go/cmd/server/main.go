@@ -1,13 +1,26 @@
 package main
 
 import (
+	"context"
 	"log"
 	"mall-go/internal/config"
 	"mall-go/internal/handler"
+	"mall-go/pkg/bundle"
 	"mall-go/pkg/cache"
+	"mall-go/pkg/cart"
+	"mall-go/pkg/currency"
 	"mall-go/pkg/database"
 	"mall-go/pkg/logger"
+	"mall-go/pkg/member"
 	"mall-go/pkg/payment"
+	"mall-go/pkg/payment/limit"
+	"mall-go/pkg/payment/risk"
+	"mall-go/pkg/payment/stats"
+	"mall-go/pkg/product"
+	"mall-go/pkg/subscription"
+	"mall-go/pkg/upload"
+	"mall-go/pkg/verification"
+	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
@@ -53,15 +66,100 @@ func main() {
 		logger.Info("Redis连接成功，缓存功能已启用")
 	}
 
-	// 初始化支付服务
+	// 启动时从汇率文件导入汇率
+	if ratesFile := os.Getenv("FX_RATES_FILE"); ratesFile != "" {
+		count, err := currency.NewService(db).LoadRatesFromFile(ratesFile)
+		if err != nil {
+			logger.Warn("导入汇率文件失败", zap.String("file", ratesFile), zap.Error(err))
+		} else {
+			logger.Info("导入汇率文件成功", zap.String("file", ratesFile), zap.Int("count", count))
+		}
+	}
+
+	// 初始化支付服务，配置了PAYMENT_CONFIG_FILE时从文件加载（密钥在内存中解密）并监听文件变更
 	paymentConfig := &payment.PaymentConfig{
 		// 使用默认配置或从环境变量读取
 	}
+	paymentConfigFile := os.Getenv("PAYMENT_CONFIG_FILE")
+	if paymentConfigFile != "" {
+		paymentConfig, err = payment.LoadConfigFromFile(paymentConfigFile)
+		if err != nil {
+			log.Fatalf("加载支付配置文件失败: %v", err)
+		}
+	}
 	paymentService, err := payment.NewService(db, paymentConfig)
 	if err != nil {
 		logger.Warn("支付服务初始化失败，将在没有支付功能的情况下运行", zap.Error(err))
 		paymentService = nil
 	}
+	if paymentService != nil && paymentConfigFile != "" {
+		if err := paymentService.ConfigManager().WatchFile(paymentConfigFile); err != nil {
+			logger.Warn("监听支付配置文件失败，配置变更需重启生效", zap.Error(err))
+		}
+	}
+
+	// 初始化支付同步管理器，Redis可用时使用Streams加速事件通知
+	if paymentService != nil {
+		var notifier payment.SyncNotifier
+		if rdb != nil {
+			streamNotifier, err := payment.NewRedisStreamNotifier(context.Background(), rdb)
+			if err != nil {
+				logger.Warn("初始化支付同步事件通知失败，将使用数据库轮询", zap.Error(err))
+			} else {
+				notifier = streamNotifier
+			}
+		}
+		paymentService.SetSyncManager(payment.NewSyncManagerWithOptions(db, payment.DefaultSyncOptions(), notifier))
+
+		// 支付风控，规则存储在数据库中，可通过管理接口调整
+		riskEngine := risk.NewEngine(db, verification.NewVerificationService(db), risk.DefaultOptions())
+		if err := riskEngine.SeedDefaultRules(); err != nil {
+			logger.Warn("写入默认风控规则失败", zap.Error(err))
+		}
+		paymentService.SetRiskEngine(riskEngine)
+
+		// 用户日/月累计限额，计数器存储在Redis中，Redis不可用时降级为进程内计数
+		paymentService.SetLimiter(limit.NewLimiter(db, limit.NewStore(rdb), paymentService.LimitDefaults))
+
+		// 主动轮询待支付订单，补偿丢失的渠道回调并关闭过期交易
+		payment.NewPaymentPoller(paymentService, payment.DefaultPollerOptions())
+	}
+
+	// 支付统计按日汇总任务，统计接口读取汇总表
+	stats.NewRollupJob(stats.NewService(db, stats.DefaultOptions()))
+
+	// 商品订阅调度任务，到期订阅自动下单扣款并处理催缴
+	subscription.NewScheduler(handler.NewSubscriptionService(db, rdb))
+
+	// 会员等级定期评定任务，扣除过期成长值后调整等级
+	memberService := member.NewService(db, member.DefaultOptions())
+	if err := memberService.SeedDefaultLevels(); err != nil {
+		logger.Warn("写入默认会员等级失败", zap.Error(err))
+	}
+	member.NewScheduler(memberService)
+
+	// 弃购追踪任务，标记闲置购物车并按轮次发送提醒，统计提醒后的下单转化
+	cart.NewAbandonmentJob(cart.NewAbandonmentService(db, cart.DefaultAbandonmentOptions()))
+
+	// 商品相似度重建任务，按共同购买和共同浏览计算购物车推荐使用的相似商品
+	similarityService := cart.NewSimilarityService(db, cart.DefaultSimilarityOptions())
+	if rdb != nil {
+		similarityService.SetBehaviorSource(cache.NewUserPreferenceCacheService(cache.NewRedisCacheManager(cache.WrapRedisClient(rdb)), cache.GetKeyManager()))
+	}
+	cart.NewSimilarityJob(similarityService)
+
+	// 搭配挖掘任务，按订单频繁项集生成商品详情页的"经常一起买"搭配
+	bundle.NewJob(bundle.NewService(db, cart.NewCartService(db), bundle.DefaultOptions()))
+
+	// 定时调价任务，到点生效调价、结束限时价，并失效价格缓存、标记购物车旧价格
+	product.NewPriceJob(handler.NewPriceService(db, rdb))
+
+	// 初始化文件上传管理器，支付争议证据等业务文件通过其保存
+	if err := upload.InitGlobalConfigManagerWithEnv(); err != nil {
+		logger.Warn("初始化上传配置失败，将无法上传业务文件", zap.Error(err))
+	} else if err := upload.InitGlobalFileManager(db, upload.GetGlobalConfigManager()); err != nil {
+		logger.Warn("初始化文件上传管理器失败，将无法上传业务文件", zap.Error(err))
+	}
 
 	// 设置Gin模式
 	gin.SetMode(gin.ReleaseMode)
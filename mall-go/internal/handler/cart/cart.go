@@ -1,15 +1,24 @@
 package cart
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"mall-go/internal/model"
+	"mall-go/pkg/bundle"
+	"mall-go/pkg/cache"
 	"mall-go/pkg/cart"
+	"mall-go/pkg/currency"
+	"mall-go/pkg/logger"
 	"mall-go/pkg/response"
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -21,8 +30,17 @@ type CartHandler struct {
 	syncService           *cart.SyncService
 	calculationService    *cart.CalculationService
 	recommendationService *cart.RecommendationService
+	currencyService       *currency.Service
+	mergeService          *cart.MergeService
+	abandonmentService    *cart.AbandonmentService
+	shareService          *cart.ShareService
+	bundleService         *bundle.Service
+	sessionSigner         *cart.SessionSigner
 }
 
+// guestSessionKey 游客会话ID在请求上下文中的键
+const guestSessionKey = "guest_session_id"
+
 // NewCartHandler 创建购物车处理器
 func NewCartHandler(db *gorm.DB, rdb *redis.Client) *CartHandler {
 	cartService := cart.NewCartService(db)
@@ -33,9 +51,14 @@ func NewCartHandler(db *gorm.DB, rdb *redis.Client) *CartHandler {
 	var cacheService *cart.CacheService
 	var syncService *cart.SyncService
 
+	// 游客购物车登录后合并，合并完成后清理两套缓存中的游客购物车
+	mergeService := cart.NewMergeService(db, cartService, cart.DefaultMergeOptions())
+
 	if rdb != nil {
 		cacheService = cart.NewCacheService(rdb, cartService)
 		syncService = cart.NewSyncService(db, cartService, cacheService)
+		mergeService.AddGuestCartCache(cacheService)
+		mergeService.AddGuestCartCache(cache.NewCartCacheService(cache.NewRedisCacheManager(cache.WrapRedisClient(rdb)), cache.GetKeyManager()))
 	}
 
 	return &CartHandler{
@@ -43,8 +66,14 @@ func NewCartHandler(db *gorm.DB, rdb *redis.Client) *CartHandler {
 		cartService:           cartService,
 		cacheService:          cacheService,
 		syncService:           syncService,
+		currencyService:       currency.NewService(db),
 		calculationService:    calculationService,
 		recommendationService: recommendationService,
+		mergeService:          mergeService,
+		abandonmentService:    cart.NewAbandonmentService(db, cart.DefaultAbandonmentOptions()),
+		shareService:          cart.NewShareService(db, cartService, cart.DefaultShareOptions()),
+		bundleService:         bundle.NewService(db, cartService, bundle.DefaultOptions()),
+		sessionSigner:         cart.NewSessionSignerFromEnv(),
 	}
 }
 
@@ -113,9 +142,58 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 		}
 	}
 
+	// 按展示币种换算金额，结算仍以结算币种为准
+	if code := c.Query("currency"); code != "" && currency.Normalize(code) != model.BaseCurrency && cartResponse.Summary != nil {
+		presentment, err := h.buildPresentment(cartResponse.Summary, code)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		cartResponse.Summary.Presentment = presentment
+	}
+
 	response.Success(c, "获取购物车成功", cartResponse)
 }
 
+// buildPresentment 按当前生效汇率换算购物车金额
+func (h *CartHandler) buildPresentment(summary *model.CartSummary, code string) (*model.CartPresentment, error) {
+	now := time.Now()
+	convert := func(amount decimal.Decimal) (*currency.Conversion, error) {
+		return h.currencyService.Convert(amount, code, now)
+	}
+
+	total, err := convert(summary.TotalAmount)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := convert(summary.SelectedAmount)
+	if err != nil {
+		return nil, err
+	}
+	discount, err := convert(summary.DiscountAmount)
+	if err != nil {
+		return nil, err
+	}
+	shipping, err := convert(summary.ShippingFee)
+	if err != nil {
+		return nil, err
+	}
+	final, err := convert(summary.FinalAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.CartPresentment{
+		Currency:       total.Currency,
+		ExchangeRate:   total.Rate,
+		TotalAmount:    total.Amount,
+		SelectedAmount: selected.Amount,
+		DiscountAmount: discount.Amount,
+		ShippingFee:    shipping.Amount,
+		FinalAmount:    final.Amount,
+	}, nil
+}
+
 // UpdateCartItem 更新购物车商品
 func (h *CartHandler) UpdateCartItem(c *gin.Context) {
 	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -343,10 +421,7 @@ func (h *CartHandler) GetPromotionSuggestions(c *gin.Context) {
 		return
 	}
 
-	// 计算选中商品金额
-	selectedAmount := cartResponse.Summary.SelectedAmount
-
-	suggestions := h.calculationService.GetPromotionSuggestions(selectedAmount)
+	suggestions := h.calculationService.GetPromotionSuggestions(cartResponse.Cart)
 
 	response.Success(c, "获取促销建议成功", suggestions)
 }
@@ -370,6 +445,7 @@ func (h *CartHandler) GetCartRecommendations(c *gin.Context) {
 }
 
 // GetCartStats 获取购物车统计信息
+// 包括购物车数、弃购数、热门商品、按小时分布及弃购提醒各轮次的发送和转化，启用Redis时附带同步和缓存统计
 func (h *CartHandler) GetCartStats(c *gin.Context) {
 	// 管理员权限检查
 	if !h.isAdmin(c) {
@@ -377,29 +453,51 @@ func (h *CartHandler) GetCartStats(c *gin.Context) {
 		return
 	}
 
-	// 获取同步统计
-	syncStats, err := h.syncService.GetSyncStats()
-	if err != nil {
-		response.Error(c, http.StatusInternalServerError, "获取同步统计失败: "+err.Error())
+	var req model.CartStatsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
 		return
 	}
 
-	// 获取缓存统计
-	cacheStats, err := h.cacheService.GetCacheStats()
+	cartStats, err := h.abandonmentService.GetStats(&req)
 	if err != nil {
-		response.Error(c, http.StatusInternalServerError, "获取缓存统计失败: "+err.Error())
+		if errors.Is(err, model.ErrInvalidCartStatsDate) {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "获取购物车统计失败: "+err.Error())
 		return
 	}
 
 	stats := gin.H{
-		"sync_stats":  syncStats,
-		"cache_stats": cacheStats,
+		"cart_stats": cartStats,
+	}
+
+	// 获取同步统计
+	if h.syncService != nil {
+		syncStats, err := h.syncService.GetSyncStats()
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "获取同步统计失败: "+err.Error())
+			return
+		}
+		stats["sync_stats"] = syncStats
+	}
+
+	// 获取缓存统计
+	if h.cacheService != nil {
+		cacheStats, err := h.cacheService.GetCacheStats()
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "获取缓存统计失败: "+err.Error())
+			return
+		}
+		stats["cache_stats"] = cacheStats
 	}
 
 	response.Success(c, "获取购物车统计信息成功", stats)
 }
 
 // MergeGuestCart 合并游客购物车
+// 将当前游客会话Cookie对应的购物车并入登录用户的购物车，登录时也会自动合并
 func (h *CartHandler) MergeGuestCart(c *gin.Context) {
 	userID, _ := h.getUserInfo(c)
 	if userID == 0 {
@@ -407,42 +505,234 @@ func (h *CartHandler) MergeGuestCart(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		GuestSessionID string `json:"guest_session_id" binding:"required"`
+	sessionID, ok := h.guestSessionFromCookie(c)
+	if !ok {
+		response.Success(c, "没有需要合并的游客购物车", nil)
+		return
+	}
+
+	mergeLog, err := h.merge(c, userID, sessionID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "合并购物车失败")
+		return
 	}
+
+	response.Success(c, "合并购物车成功", mergeLog)
+}
+
+// ShareCart 分享购物车
+// 将指定或已选中的购物车商品快照为分享清单，返回分享令牌
+func (h *CartHandler) ShareCart(c *gin.Context) {
+	userID, _ := h.getUserInfo(c)
+	if userID == 0 {
+		response.Error(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	var req model.ShareCartRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
 		return
 	}
 
-	// 这里应该实现购物车合并逻辑
-	// 简化处理，直接返回成功
-	response.Success(c, "合并购物车成功", nil)
+	share, err := h.shareService.Share(userID, &req)
+	if err != nil {
+		h.respondShareError(c, "分享购物车失败", err)
+		return
+	}
+
+	response.Success(c, "分享购物车成功", share)
 }
 
-// getUserInfo 获取用户信息
-func (h *CartHandler) getUserInfo(c *gin.Context) (uint, string) {
-	var userID uint
-	var sessionID string
+// ListShares 查询我的购物车分享，包含导入和结算次数
+func (h *CartHandler) ListShares(c *gin.Context) {
+	userID, _ := h.getUserInfo(c)
+	if userID == 0 {
+		response.Error(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	shares, err := h.shareService.List(userID)
+	if err != nil {
+		h.respondShareError(c, "查询购物车分享失败", err)
+		return
+	}
+
+	response.Success(c, "查询购物车分享成功", shares)
+}
+
+// RevokeShare 结束购物车分享
+func (h *CartHandler) RevokeShare(c *gin.Context) {
+	userID, _ := h.getUserInfo(c)
+	if userID == 0 {
+		response.Error(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
 
-	// 从JWT中获取用户ID
-	if uid, exists := c.Get("user_id"); exists {
-		userID = uid.(uint)
+	shareID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "分享ID格式错误")
+		return
 	}
 
-	// 从请求头或Cookie中获取会话ID
-	sessionID = c.GetHeader("X-Session-ID")
-	if sessionID == "" {
-		sessionID = c.GetHeader("X-Guest-ID")
+	if err := h.shareService.Revoke(userID, uint(shareID)); err != nil {
+		h.respondShareError(c, "结束购物车分享失败", err)
+		return
 	}
-	if sessionID == "" {
-		// 从Cookie获取
-		if cookie, err := c.Cookie("session_id"); err == nil {
-			sessionID = cookie
+
+	response.Success(c, "结束购物车分享成功", nil)
+}
+
+// GetSharedCart 查看分享的购物车
+// 游客也可查看，商品附带当前价格和库存，价格变化、下架和库存不足逐项标记
+func (h *CartHandler) GetSharedCart(c *gin.Context) {
+	view, err := h.shareService.Get(c.Param("token"))
+	if err != nil {
+		h.respondShareError(c, "查看分享购物车失败", err)
+		return
+	}
+
+	response.Success(c, "获取分享购物车成功", view)
+}
+
+// ImportSharedCart 导入分享的购物车
+// 按分享数量加入当前用户或游客的购物车，逐项返回导入结果
+func (h *CartHandler) ImportSharedCart(c *gin.Context) {
+	var req model.SharedCartItemsRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	userID, sessionID := h.getUserInfo(c)
+	result, err := h.shareService.Import(userID, sessionID, c.Param("token"), &req)
+	if err != nil {
+		h.respondShareError(c, "导入分享购物车失败", err)
+		return
+	}
+
+	response.Success(c, "导入分享购物车完成", result)
+}
+
+// AddBundle 将商品及其搭配商品各一件加入购物车
+// 任一商品无货时不加入任何商品
+func (h *CartHandler) AddBundle(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "无效的商品ID")
+		return
+	}
+
+	var req model.BundleToCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	userID, sessionID := h.getUserInfo(c)
+	items, err := h.bundleService.AddToCart(userID, sessionID, uint(productID), &req)
+	if err != nil {
+		if errors.Is(err, model.ErrBundleNotFound) {
+			response.Error(c, http.StatusNotFound, err.Error())
+			return
+		}
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(c, "搭配商品已加入购物车", items)
+}
+
+// respondShareError 按购物车分享错误类型返回响应
+func (h *CartHandler) respondShareError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrCartShareNotFound):
+		response.Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, model.ErrCartShareExpired):
+		response.Error(c, http.StatusGone, err.Error())
+	case errors.Is(err, model.ErrCartShareEmpty):
+		response.Error(c, http.StatusBadRequest, err.Error())
+	default:
+		logger.Error(message, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, message)
+	}
+}
+
+// MergeOnLogin 登录成功后合并游客购物车，合并失败不影响登录
+func (h *CartHandler) MergeOnLogin(c *gin.Context, userID uint) {
+	sessionID, ok := h.guestSessionFromCookie(c)
+	if !ok {
+		return
+	}
+	h.merge(c, userID, sessionID)
+}
+
+// GuestSession 游客会话中间件
+// 未登录请求使用签名Cookie标识游客购物车，Cookie缺失或签名无效时发放新的会话
+func (h *CartHandler) GuestSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetUint("user_id") > 0 {
+			c.Next()
+			return
 		}
+
+		sessionID, ok := h.guestSessionFromCookie(c)
+		if !ok {
+			var value string
+			var err error
+			sessionID, value, err = h.sessionSigner.NewSession()
+			if err != nil {
+				logger.Error("创建游客会话失败", zap.Error(err))
+				response.Error(c, http.StatusInternalServerError, "创建游客会话失败")
+				c.Abort()
+				return
+			}
+			h.setGuestCookie(c, value, int(cart.GuestCartExpire/time.Second))
+		}
+
+		c.Set(guestSessionKey, sessionID)
+		c.Next()
+	}
+}
+
+// merge 执行合并并清除游客会话Cookie
+func (h *CartHandler) merge(c *gin.Context, userID uint, sessionID string) (*model.CartMergeLog, error) {
+	mergeLog, err := h.mergeService.Merge(userID, sessionID)
+	if err != nil {
+		logger.Error("合并游客购物车失败", zap.Uint("user_id", userID), zap.String("session_id", sessionID), zap.Error(err))
+		return nil, err
+	}
+
+	h.setGuestCookie(c, "", -1)
+	if mergeLog != nil && h.cacheService != nil {
+		h.cacheService.RefreshCartCache(userID, "")
 	}
+	return mergeLog, nil
+}
 
-	return userID, sessionID
+// guestSessionFromCookie 读取并校验游客会话Cookie
+func (h *CartHandler) guestSessionFromCookie(c *gin.Context) (string, bool) {
+	value, err := c.Cookie(cart.GuestSessionCookie)
+	if err != nil || value == "" {
+		return "", false
+	}
+	return h.sessionSigner.Verify(value)
+}
+
+// setGuestCookie 写入游客会话Cookie，maxAge 小于0时删除
+func (h *CartHandler) setGuestCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(cart.GuestSessionCookie, value, maxAge, "/", "", c.Request.TLS != nil, true)
+}
+
+// getUserInfo 获取用户信息
+// 登录用户使用用户ID，游客使用 GuestSession 中间件校验过的会话ID
+func (h *CartHandler) getUserInfo(c *gin.Context) (uint, string) {
+	userID := c.GetUint("user_id")
+	if userID > 0 {
+		return userID, ""
+	}
+	return 0, c.GetString(guestSessionKey)
 }
 
 // isAdmin 检查是否为管理员
@@ -0,0 +1,16 @@
+package cart
+
+import (
+	"mall-go/internal/handler/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAdminRoutes 注册购物车管理路由
+func RegisterAdminRoutes(router *gin.RouterGroup, handler *CartHandler) {
+	adminGroup := router.Group("/admin/carts")
+	adminGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		adminGroup.GET("/stats", handler.GetCartStats) // 购物车及弃购提醒统计
+	}
+}
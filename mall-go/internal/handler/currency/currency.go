@@ -0,0 +1,181 @@
+package currency
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/currency"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Handler 币种与汇率处理器
+type Handler struct {
+	currencyService *currency.Service
+}
+
+// NewHandler 创建币种与汇率处理器
+func NewHandler(db *gorm.DB) *Handler {
+	return &Handler{
+		currencyService: currency.NewService(db),
+	}
+}
+
+// ListCurrencies 获取可用币种
+// @Summary 获取可用币种
+// @Description 获取已启用的展示币种列表
+// @Tags 币种管理
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.Currency} "查询成功"
+// @Router /api/v1/currencies [get]
+func (h *Handler) ListCurrencies(c *gin.Context) {
+	currencies, err := h.currencyService.ListCurrencies(true)
+	if err != nil {
+		logger.Error("查询币种失败", zap.Error(err))
+		response.ServerError(c, "查询币种失败")
+		return
+	}
+
+	response.Success(c, "查询成功", currencies)
+}
+
+// ListAllCurrencies 获取全部币种（管理员）
+// @Summary 获取全部币种
+// @Tags 币种管理
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.Currency} "查询成功"
+// @Router /api/v1/admin/currencies [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListAllCurrencies(c *gin.Context) {
+	currencies, err := h.currencyService.ListCurrencies(false)
+	if err != nil {
+		logger.Error("查询币种失败", zap.Error(err))
+		response.ServerError(c, "查询币种失败")
+		return
+	}
+
+	response.Success(c, "查询成功", currencies)
+}
+
+// SaveCurrency 新增或更新币种（管理员）
+// @Summary 维护币种
+// @Description 按币种代码新增或更新币种，小数位数不传时取ISO 4217默认值
+// @Tags 币种管理
+// @Accept json
+// @Produce json
+// @Param request body model.CurrencyRequest true "币种信息"
+// @Success 200 {object} response.Response{data=model.Currency} "保存成功"
+// @Router /api/v1/admin/currencies [post]
+// @Security ApiKeyAuth
+func (h *Handler) SaveCurrency(c *gin.Context) {
+	var req model.CurrencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+
+	saved, err := h.currencyService.SaveCurrency(&req)
+	if err != nil {
+		logger.Error("保存币种失败", zap.String("code", req.Code), zap.Error(err))
+		response.ServerError(c, "保存币种失败")
+		return
+	}
+
+	response.Success(c, "保存成功", saved)
+}
+
+// SetRate 录入汇率（管理员）
+// @Summary 录入汇率
+// @Description 追加一个新的汇率版本，已创建订单仍使用下单时锁定的汇率
+// @Tags 币种管理
+// @Accept json
+// @Produce json
+// @Param request body model.ExchangeRateRequest true "汇率信息"
+// @Success 200 {object} response.Response{data=model.ExchangeRate} "录入成功"
+// @Router /api/v1/admin/exchange-rates [post]
+// @Security ApiKeyAuth
+func (h *Handler) SetRate(c *gin.Context) {
+	var req model.ExchangeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+
+	effectiveAt := time.Now()
+	if req.EffectiveAt != nil {
+		effectiveAt = *req.EffectiveAt
+	}
+
+	rate, err := h.currencyService.SetRate(req.QuoteCurrency, req.Rate, effectiveAt, model.ExchangeRateSourceAdmin, c.GetUint("user_id"))
+	if err != nil {
+		if errors.Is(err, model.ErrCurrencyNotSupported) || errors.Is(err, model.ErrInvalidExchangeRate) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		logger.Error("录入汇率失败", zap.String("quote_currency", req.QuoteCurrency), zap.Error(err))
+		response.ServerError(c, "录入汇率失败")
+		return
+	}
+
+	response.Success(c, "录入成功", rate)
+}
+
+// ImportRates 批量导入汇率（管理员）
+// @Summary 批量导入汇率
+// @Description 按汇率文件格式批量导入同一生效时间的汇率，任一币种失败则整批回滚
+// @Tags 币种管理
+// @Accept json
+// @Produce json
+// @Param request body currency.RateFile true "汇率文件内容"
+// @Success 200 {object} response.Response "导入成功"
+// @Router /api/v1/admin/exchange-rates/import [post]
+// @Security ApiKeyAuth
+func (h *Handler) ImportRates(c *gin.Context) {
+	var file currency.RateFile
+	if err := c.ShouldBindJSON(&file); err != nil {
+		response.BadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+
+	count, err := h.currencyService.ImportRates(&file, model.ExchangeRateSourceAdmin, c.GetUint("user_id"))
+	if err != nil {
+		logger.Error("导入汇率失败", zap.Error(err))
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(c, "导入成功", gin.H{"count": count})
+}
+
+// ListRates 查询汇率历史（管理员）
+// @Summary 查询汇率历史
+// @Tags 币种管理
+// @Produce json
+// @Param quote_currency query string false "报价币种"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/admin/exchange-rates [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListRates(c *gin.Context) {
+	var query model.ExchangeRateQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.BadRequest(c, "请求参数错误")
+		return
+	}
+
+	rates, total, err := h.currencyService.ListRates(&query)
+	if err != nil {
+		logger.Error("查询汇率失败", zap.Error(err))
+		response.ServerError(c, "查询汇率失败")
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", rates, total, query.Page, query.PageSize)
+}
@@ -0,0 +1,25 @@
+package currency
+
+import (
+	"mall-go/internal/handler/middleware"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RegisterRoutes 注册币种与汇率路由
+func RegisterRoutes(router *gin.RouterGroup, db *gorm.DB) {
+	handler := NewHandler(db)
+
+	router.GET("/currencies", handler.ListCurrencies) // 获取可用币种
+
+	adminGroup := router.Group("/admin")
+	adminGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		adminGroup.GET("/currencies", handler.ListAllCurrencies)       // 获取全部币种
+		adminGroup.POST("/currencies", handler.SaveCurrency)           // 新增或更新币种
+		adminGroup.GET("/exchange-rates", handler.ListRates)           // 汇率历史
+		adminGroup.POST("/exchange-rates", handler.SetRate)            // 录入汇率
+		adminGroup.POST("/exchange-rates/import", handler.ImportRates) // 批量导入汇率
+	}
+}
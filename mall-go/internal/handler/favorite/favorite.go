@@ -0,0 +1,327 @@
+package favorite
+
+import (
+	"errors"
+	"net/http"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/favorite"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Handler 收藏处理器
+type Handler struct {
+	service *favorite.Service
+}
+
+// NewHandler 创建收藏处理器
+func NewHandler(service *favorite.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// List 查询我的收藏
+// @Summary 查询我的收藏
+// @Tags 收藏
+// @Produce json
+// @Param folder_id query int false "分组ID，0为默认分组"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/favorites [get]
+// @Security ApiKeyAuth
+func (h *Handler) List(c *gin.Context) {
+	var query model.FavoriteQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	items, total, err := h.service.List(c.GetUint("user_id"), &query)
+	if err != nil {
+		h.respondError(c, "查询收藏失败", err)
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", items, total, query.Page, query.PageSize)
+}
+
+// Add 收藏商品
+// @Summary 收藏商品
+// @Tags 收藏
+// @Accept json
+// @Produce json
+// @Param request body model.AddFavoriteRequest true "收藏商品"
+// @Success 200 {object} response.Response{data=model.FavoriteItem} "收藏成功"
+// @Router /api/v1/favorites [post]
+// @Security ApiKeyAuth
+func (h *Handler) Add(c *gin.Context) {
+	var req model.AddFavoriteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	item, err := h.service.Add(c.GetUint("user_id"), &req)
+	if err != nil {
+		h.respondError(c, "收藏商品失败", err)
+		return
+	}
+
+	response.Success(c, "收藏成功", item)
+}
+
+// Remove 取消收藏
+// @Summary 取消收藏
+// @Tags 收藏
+// @Produce json
+// @Param id path int true "收藏ID"
+// @Success 200 {object} response.Response "取消成功"
+// @Router /api/v1/favorites/{id} [delete]
+// @Security ApiKeyAuth
+func (h *Handler) Remove(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "收藏ID格式错误")
+	if !ok {
+		return
+	}
+
+	if err := h.service.Remove(c.GetUint("user_id"), id); err != nil {
+		h.respondError(c, "取消收藏失败", err)
+		return
+	}
+
+	response.Success(c, "取消成功", nil)
+}
+
+// Move 移动收藏到分组
+// @Summary 移动收藏到分组
+// @Tags 收藏
+// @Accept json
+// @Produce json
+// @Param request body model.MoveFavoritesRequest true "收藏及目标分组"
+// @Success 200 {object} response.Response "移动成功"
+// @Router /api/v1/favorites/move [put]
+// @Security ApiKeyAuth
+func (h *Handler) Move(c *gin.Context) {
+	var req model.MoveFavoritesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	if err := h.service.Move(c.GetUint("user_id"), &req); err != nil {
+		h.respondError(c, "移动收藏失败", err)
+		return
+	}
+
+	response.Success(c, "移动成功", nil)
+}
+
+// AddToCart 收藏加入购物车
+// @Summary 收藏加入购物车
+// @Description 逐件加入购物车，默认加购成功后移出收藏
+// @Tags 收藏
+// @Accept json
+// @Produce json
+// @Param request body model.FavoritesToCartRequest true "收藏ID列表"
+// @Success 200 {object} response.Response{data=model.FavoritesToCartResult} "加购完成"
+// @Router /api/v1/favorites/to-cart [post]
+// @Security ApiKeyAuth
+func (h *Handler) AddToCart(c *gin.Context) {
+	var req model.FavoritesToCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	result, err := h.service.AddToCart(c.GetUint("user_id"), &req)
+	if err != nil {
+		h.respondError(c, "加入购物车失败", err)
+		return
+	}
+
+	response.Success(c, "加购完成", result)
+}
+
+// ListFolders 查询收藏分组
+// @Summary 查询收藏分组
+// @Tags 收藏
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.FavoriteFolder} "查询成功"
+// @Router /api/v1/favorites/folders [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListFolders(c *gin.Context) {
+	folders, err := h.service.ListFolders(c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "查询收藏分组失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", folders)
+}
+
+// CreateFolder 创建收藏分组
+// @Summary 创建收藏分组
+// @Tags 收藏
+// @Accept json
+// @Produce json
+// @Param request body model.FavoriteFolderRequest true "分组"
+// @Success 200 {object} response.Response{data=model.FavoriteFolder} "创建成功"
+// @Router /api/v1/favorites/folders [post]
+// @Security ApiKeyAuth
+func (h *Handler) CreateFolder(c *gin.Context) {
+	var req model.FavoriteFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	folder, err := h.service.CreateFolder(c.GetUint("user_id"), &req)
+	if err != nil {
+		h.respondError(c, "创建收藏分组失败", err)
+		return
+	}
+
+	response.Success(c, "创建成功", folder)
+}
+
+// UpdateFolder 重命名收藏分组
+// @Summary 重命名收藏分组
+// @Tags 收藏
+// @Accept json
+// @Produce json
+// @Param id path int true "分组ID"
+// @Param request body model.FavoriteFolderRequest true "分组"
+// @Success 200 {object} response.Response{data=model.FavoriteFolder} "更新成功"
+// @Router /api/v1/favorites/folders/{id} [put]
+// @Security ApiKeyAuth
+func (h *Handler) UpdateFolder(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "分组ID格式错误")
+	if !ok {
+		return
+	}
+	var req model.FavoriteFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	folder, err := h.service.UpdateFolder(c.GetUint("user_id"), id, &req)
+	if err != nil {
+		h.respondError(c, "更新收藏分组失败", err)
+		return
+	}
+
+	response.Success(c, "更新成功", folder)
+}
+
+// DeleteFolder 删除收藏分组
+// @Summary 删除收藏分组
+// @Description 分组内的收藏移回默认分组
+// @Tags 收藏
+// @Produce json
+// @Param id path int true "分组ID"
+// @Success 200 {object} response.Response "删除成功"
+// @Router /api/v1/favorites/folders/{id} [delete]
+// @Security ApiKeyAuth
+func (h *Handler) DeleteFolder(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "分组ID格式错误")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteFolder(c.GetUint("user_id"), id); err != nil {
+		h.respondError(c, "删除收藏分组失败", err)
+		return
+	}
+
+	response.Success(c, "删除成功", nil)
+}
+
+// ListAlerts 查询我的降价、到货提醒
+// @Summary 查询我的降价、到货提醒
+// @Tags 收藏
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.FavoriteAlert} "查询成功"
+// @Router /api/v1/favorites/alerts [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListAlerts(c *gin.Context) {
+	alerts, err := h.service.ListAlerts(c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "查询提醒失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", alerts)
+}
+
+// CreateAlert 订阅降价或到货提醒
+// @Summary 订阅降价或到货提醒
+// @Description 降价提醒在价格降至目标价及以下时触发，到货提醒在库存由0恢复时触发，触发一次后失效
+// @Tags 收藏
+// @Accept json
+// @Produce json
+// @Param request body model.FavoriteAlertRequest true "提醒条件"
+// @Success 200 {object} response.Response{data=model.FavoriteAlert} "订阅成功"
+// @Router /api/v1/favorites/alerts [post]
+// @Security ApiKeyAuth
+func (h *Handler) CreateAlert(c *gin.Context) {
+	var req model.FavoriteAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	alert, err := h.service.CreateAlert(c.GetUint("user_id"), &req)
+	if err != nil {
+		h.respondError(c, "订阅提醒失败", err)
+		return
+	}
+
+	response.Success(c, "订阅成功", alert)
+}
+
+// CancelAlert 取消提醒
+// @Summary 取消提醒
+// @Tags 收藏
+// @Produce json
+// @Param id path int true "提醒ID"
+// @Success 200 {object} response.Response "取消成功"
+// @Router /api/v1/favorites/alerts/{id} [delete]
+// @Security ApiKeyAuth
+func (h *Handler) CancelAlert(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "提醒ID格式错误")
+	if !ok {
+		return
+	}
+
+	if err := h.service.CancelAlert(c.GetUint("user_id"), id); err != nil {
+		h.respondError(c, "取消提醒失败", err)
+		return
+	}
+
+	response.Success(c, "取消成功", nil)
+}
+
+// respondError 按收藏错误类型返回响应
+func (h *Handler) respondError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrFavoriteNotFound),
+		errors.Is(err, model.ErrFavoriteFolderNotFound),
+		errors.Is(err, model.ErrFavoriteAlertNotFound),
+		errors.Is(err, model.ErrFavoriteProduct):
+		response.Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, model.ErrFavoriteExists):
+		response.Error(c, http.StatusConflict, err.Error())
+	case errors.Is(err, model.ErrInvalidFavoriteAlert):
+		response.Error(c, http.StatusBadRequest, err.Error())
+	default:
+		logger.Error(message, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, message)
+	}
+}
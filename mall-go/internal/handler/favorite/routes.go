@@ -0,0 +1,30 @@
+package favorite
+
+import (
+	"mall-go/internal/handler/middleware"
+	"mall-go/pkg/favorite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 注册收藏、收藏分组及降价、到货提醒路由
+func RegisterRoutes(router *gin.RouterGroup, service *favorite.Service) {
+	handler := NewHandler(service)
+
+	favoriteGroup := router.Group("/favorites")
+	favoriteGroup.Use(middleware.AuthMiddleware())
+	{
+		favoriteGroup.GET("", handler.List)                        // 我的收藏
+		favoriteGroup.POST("", handler.Add)                        // 收藏商品
+		favoriteGroup.DELETE("/:id", handler.Remove)               // 取消收藏
+		favoriteGroup.PUT("/move", handler.Move)                   // 移动到分组
+		favoriteGroup.POST("/to-cart", handler.AddToCart)          // 加入购物车
+		favoriteGroup.GET("/folders", handler.ListFolders)         // 收藏分组
+		favoriteGroup.POST("/folders", handler.CreateFolder)       // 创建分组
+		favoriteGroup.PUT("/folders/:id", handler.UpdateFolder)    // 重命名分组
+		favoriteGroup.DELETE("/folders/:id", handler.DeleteFolder) // 删除分组
+		favoriteGroup.GET("/alerts", handler.ListAlerts)           // 降价、到货提醒
+		favoriteGroup.POST("/alerts", handler.CreateAlert)         // 订阅提醒
+		favoriteGroup.DELETE("/alerts/:id", handler.CancelAlert)   // 取消提醒
+	}
+}
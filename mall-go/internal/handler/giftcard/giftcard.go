@@ -0,0 +1,293 @@
+package giftcard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/giftcard"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Handler 礼品卡处理器
+type Handler struct {
+	service *giftcard.Service
+}
+
+// NewHandler 创建礼品卡处理器
+func NewHandler(service *giftcard.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ListMyCards 查询我的礼品卡
+// @Summary 查询我的礼品卡
+// @Tags 礼品卡
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.GiftCard} "查询成功"
+// @Router /api/v1/gift-cards [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListMyCards(c *gin.Context) {
+	cards, err := h.service.ListUserCards(c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "查询礼品卡失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", cards)
+}
+
+// BindCard 绑定礼品卡
+// @Summary 绑定礼品卡
+// @Description 输入卡密将礼品卡绑定到当前账户，绑定后仅本人可用
+// @Tags 礼品卡
+// @Accept json
+// @Produce json
+// @Param request body model.BindGiftCardRequest true "卡密"
+// @Success 200 {object} response.Response{data=model.GiftCard} "绑定成功"
+// @Router /api/v1/gift-cards/bind [post]
+// @Security ApiKeyAuth
+func (h *Handler) BindCard(c *gin.Context) {
+	var req model.BindGiftCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	card, err := h.service.Bind(c.GetUint("user_id"), req.Code)
+	if err != nil {
+		h.respondError(c, "绑定礼品卡失败", err)
+		return
+	}
+
+	response.Success(c, "绑定成功", card)
+}
+
+// ListMyTransactions 查询礼品卡流水
+// @Summary 查询礼品卡流水
+// @Tags 礼品卡
+// @Produce json
+// @Param id path int true "礼品卡ID"
+// @Success 200 {object} response.Response{data=[]model.GiftCardTransaction} "查询成功"
+// @Router /api/v1/gift-cards/{id}/transactions [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListMyTransactions(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "礼品卡ID格式错误")
+	if !ok {
+		return
+	}
+
+	transactions, err := h.service.ListUserTransactions(c.GetUint("user_id"), id)
+	if err != nil {
+		h.respondError(c, "查询礼品卡流水失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", transactions)
+}
+
+// CreateBatch 生成礼品卡批次
+// @Summary 生成礼品卡批次
+// @Description 按面值和有效期批量生成礼品卡，卡密仅在本次响应中返回
+// @Tags 礼品卡管理
+// @Accept json
+// @Produce json
+// @Param request body model.CreateGiftCardBatchRequest true "批次信息"
+// @Success 200 {object} response.Response{data=model.GiftCardBatchResponse} "生成成功"
+// @Router /api/v1/admin/gift-cards/batches [post]
+// @Security ApiKeyAuth
+func (h *Handler) CreateBatch(c *gin.Context) {
+	var req model.CreateGiftCardBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	result, err := h.service.CreateBatch(&req, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "生成礼品卡失败", err)
+		return
+	}
+
+	response.Success(c, "生成成功", result)
+}
+
+// ListBatches 查询礼品卡批次
+// @Summary 查询礼品卡批次
+// @Tags 礼品卡管理
+// @Produce json
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/admin/gift-cards/batches [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListBatches(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	batches, total, err := h.service.ListBatches(page, pageSize)
+	if err != nil {
+		h.respondError(c, "查询礼品卡批次失败", err)
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", batches, total, page, pageSize)
+}
+
+// ListCards 查询礼品卡
+// @Summary 查询礼品卡
+// @Tags 礼品卡管理
+// @Produce json
+// @Param batch_id query uint false "批次ID"
+// @Param user_id query uint false "绑定用户ID"
+// @Param status query string false "状态(active/disabled)"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/admin/gift-cards [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListCards(c *gin.Context) {
+	var query model.GiftCardQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	cards, total, err := h.service.ListCards(&query)
+	if err != nil {
+		h.respondError(c, "查询礼品卡失败", err)
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", cards, total, query.Page, query.PageSize)
+}
+
+// UpdateStatus 冻结或解冻礼品卡
+// @Summary 冻结或解冻礼品卡
+// @Description 冻结后礼品卡不可绑定和下单抵扣，余额仍计入负债
+// @Tags 礼品卡管理
+// @Accept json
+// @Produce json
+// @Param id path int true "礼品卡ID"
+// @Param request body model.UpdateGiftCardStatusRequest true "状态"
+// @Success 200 {object} response.Response{data=model.GiftCard} "更新成功"
+// @Router /api/v1/admin/gift-cards/{id}/status [put]
+// @Security ApiKeyAuth
+func (h *Handler) UpdateStatus(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "礼品卡ID格式错误")
+	if !ok {
+		return
+	}
+	var req model.UpdateGiftCardStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	card, err := h.service.SetStatus(id, req.Status, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "更新礼品卡状态失败", err)
+		return
+	}
+
+	response.Success(c, "更新成功", card)
+}
+
+// GetLiability 查询礼品卡负债
+// @Summary 查询礼品卡负债
+// @Description 统计指定日期日终的礼品卡发卡、抵扣、退回及未使用余额，date 为空时统计当前时点
+// @Tags 财务
+// @Produce json
+// @Param date query string false "统计日期(YYYY-MM-DD)"
+// @Success 200 {object} response.Response{data=model.GiftCardLiability} "查询成功"
+// @Router /api/v1/admin/finance/gift-card-liability [get]
+// @Security ApiKeyAuth
+func (h *Handler) GetLiability(c *gin.Context) {
+	liability, err := h.service.Liability(c.Query("date"))
+	if err != nil {
+		h.respondError(c, "查询礼品卡负债失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", liability)
+}
+
+// ExportLiability 导出礼品卡负债明细
+// @Summary 导出礼品卡负债明细
+// @Description 导出指定日期日终有余额的礼品卡明细（CSV）
+// @Tags 财务
+// @Produce text/csv
+// @Param date query string false "统计日期(YYYY-MM-DD)"
+// @Success 200 {file} file "CSV文件"
+// @Router /api/v1/admin/finance/exports/gift-card-liability [get]
+// @Security ApiKeyAuth
+func (h *Handler) ExportLiability(c *gin.Context) {
+	date := c.Query("date")
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
+
+	var buf bytes.Buffer
+	if err := h.service.WriteLiabilityCSV(&buf, date); err != nil {
+		h.respondError(c, "导出礼品卡负债失败", err)
+		return
+	}
+
+	writeCSV(c, fmt.Sprintf("gift_card_liability_%s.csv", date), buf.Bytes())
+}
+
+// ExportTransactions 导出礼品卡流水
+// @Summary 导出礼品卡流水
+// @Description 导出日期范围内的礼品卡发卡、抵扣、退回流水（CSV）
+// @Tags 财务
+// @Produce text/csv
+// @Param start_date query string true "开始日期(YYYY-MM-DD)"
+// @Param end_date query string true "结束日期(YYYY-MM-DD)"
+// @Success 200 {file} file "CSV文件"
+// @Router /api/v1/admin/finance/exports/gift-card-transactions [get]
+// @Security ApiKeyAuth
+func (h *Handler) ExportTransactions(c *gin.Context) {
+	startDate, endDate := c.Query("start_date"), c.Query("end_date")
+
+	var buf bytes.Buffer
+	if err := h.service.WriteTransactionsCSV(&buf, startDate, endDate); err != nil {
+		h.respondError(c, "导出礼品卡流水失败", err)
+		return
+	}
+
+	writeCSV(c, fmt.Sprintf("gift_card_transactions_%s_%s.csv", startDate, endDate), buf.Bytes())
+}
+
+// writeCSV 以附件形式返回CSV内容
+func writeCSV(c *gin.Context, filename string, data []byte) {
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+}
+
+// respondError 按礼品卡错误类型返回响应
+func (h *Handler) respondError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrGiftCardNotFound):
+		response.Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, model.ErrGiftCardBound):
+		response.Error(c, http.StatusConflict, err.Error())
+	case errors.Is(err, model.ErrGiftCardDisabled),
+		errors.Is(err, model.ErrGiftCardExpired),
+		errors.Is(err, model.ErrInvalidGiftCardAmount),
+		errors.Is(err, model.ErrInvalidGiftCardExpiry),
+		errors.Is(err, model.ErrGiftCardExportRange):
+		response.Error(c, http.StatusBadRequest, err.Error())
+	default:
+		logger.Error(message, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, message)
+	}
+}
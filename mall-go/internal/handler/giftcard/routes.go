@@ -0,0 +1,38 @@
+package giftcard
+
+import (
+	"mall-go/internal/handler/middleware"
+	"mall-go/pkg/giftcard"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 注册礼品卡及礼品卡负债报表路由
+func RegisterRoutes(router *gin.RouterGroup, service *giftcard.Service) {
+	handler := NewHandler(service)
+
+	giftCardGroup := router.Group("/gift-cards")
+	giftCardGroup.Use(middleware.AuthMiddleware())
+	{
+		giftCardGroup.GET("", handler.ListMyCards)                         // 我的礼品卡
+		giftCardGroup.POST("/bind", handler.BindCard)                      // 绑定礼品卡
+		giftCardGroup.GET("/:id/transactions", handler.ListMyTransactions) // 礼品卡流水
+	}
+
+	adminGroup := router.Group("/admin/gift-cards")
+	adminGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		adminGroup.POST("/batches", handler.CreateBatch)    // 生成礼品卡批次
+		adminGroup.GET("/batches", handler.ListBatches)     // 礼品卡批次列表
+		adminGroup.GET("", handler.ListCards)               // 礼品卡列表
+		adminGroup.PUT("/:id/status", handler.UpdateStatus) // 冻结或解冻礼品卡
+	}
+
+	financeGroup := router.Group("/admin/finance")
+	financeGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		financeGroup.GET("/gift-card-liability", handler.GetLiability)                  // 礼品卡负债汇总
+		financeGroup.GET("/exports/gift-card-liability", handler.ExportLiability)       // 导出礼品卡负债明细
+		financeGroup.GET("/exports/gift-card-transactions", handler.ExportTransactions) // 导出礼品卡流水
+	}
+}
@@ -0,0 +1,260 @@
+package member
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/member"
+	"mall-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Handler 会员处理器
+type Handler struct {
+	service *member.Service
+}
+
+// NewHandler 创建会员处理器
+func NewHandler(service *member.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ListLevels 查询会员等级及权益
+// @Summary 查询会员等级及权益
+// @Tags 会员
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.MemberLevel} "查询成功"
+// @Router /api/v1/member-levels [get]
+func (h *Handler) ListLevels(c *gin.Context) {
+	levels, err := h.service.ListLevels(true)
+	if err != nil {
+		h.respondError(c, "查询会员等级失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", levels)
+}
+
+// GetProfile 查询我的会员信息
+// @Summary 查询我的会员信息
+// @Description 返回当前等级、成长值、距下一等级所需成长值及今日签到状态
+// @Tags 会员
+// @Produce json
+// @Success 200 {object} response.Response{data=model.MemberProfile} "查询成功"
+// @Router /api/v1/members/me [get]
+// @Security ApiKeyAuth
+func (h *Handler) GetProfile(c *gin.Context) {
+	profile, err := h.service.Profile(c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "查询会员信息失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", profile)
+}
+
+// ListGrowthLogs 查询我的成长值流水
+// @Summary 查询我的成长值流水
+// @Tags 会员
+// @Produce json
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/members/me/growth-logs [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListGrowthLogs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	logs, total, err := h.service.ListGrowthLogs(c.GetUint("user_id"), page, pageSize)
+	if err != nil {
+		h.respondError(c, "查询成长值流水失败", err)
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", logs, total, page, pageSize)
+}
+
+// ListLevelChanges 查询我的等级变更记录
+// @Summary 查询我的等级变更记录
+// @Tags 会员
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.MemberLevelChange} "查询成功"
+// @Router /api/v1/members/me/level-changes [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListLevelChanges(c *gin.Context) {
+	changes, err := h.service.ListLevelChanges(c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "查询等级变更记录失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", changes)
+}
+
+// CheckIn 每日签到
+// @Summary 每日签到
+// @Tags 会员
+// @Produce json
+// @Success 200 {object} response.Response{data=model.UserMembership} "签到成功"
+// @Router /api/v1/members/check-in [post]
+// @Security ApiKeyAuth
+func (h *Handler) CheckIn(c *gin.Context) {
+	membership, err := h.service.CheckIn(c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "签到失败", err)
+		return
+	}
+
+	response.Success(c, "签到成功", membership)
+}
+
+// ListAllLevels 查询全部会员等级
+// @Summary 查询全部会员等级
+// @Tags 会员管理
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.MemberLevel} "查询成功"
+// @Router /api/v1/admin/member-levels [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListAllLevels(c *gin.Context) {
+	levels, err := h.service.ListLevels(false)
+	if err != nil {
+		h.respondError(c, "查询会员等级失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", levels)
+}
+
+// CreateLevel 创建会员等级
+// @Summary 创建会员等级
+// @Description 配置成长值门槛、会员折扣、包邮门槛、积分倍数和专享优惠券
+// @Tags 会员管理
+// @Accept json
+// @Produce json
+// @Param request body model.MemberLevelRequest true "会员等级"
+// @Success 200 {object} response.Response{data=model.MemberLevel} "创建成功"
+// @Router /api/v1/admin/member-levels [post]
+// @Security ApiKeyAuth
+func (h *Handler) CreateLevel(c *gin.Context) {
+	var req model.MemberLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	level, err := h.service.CreateLevel(&req, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "创建会员等级失败", err)
+		return
+	}
+
+	response.Success(c, "创建成功", level)
+}
+
+// UpdateLevel 更新会员等级
+// @Summary 更新会员等级
+// @Tags 会员管理
+// @Accept json
+// @Produce json
+// @Param id path int true "等级ID"
+// @Param request body model.MemberLevelRequest true "会员等级"
+// @Success 200 {object} response.Response{data=model.MemberLevel} "更新成功"
+// @Router /api/v1/admin/member-levels/{id} [put]
+// @Security ApiKeyAuth
+func (h *Handler) UpdateLevel(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "等级ID格式错误")
+	if !ok {
+		return
+	}
+	var req model.MemberLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	level, err := h.service.UpdateLevel(id, &req, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "更新会员等级失败", err)
+		return
+	}
+
+	response.Success(c, "更新成功", level)
+}
+
+// GetUserProfile 查询用户会员信息
+// @Summary 查询用户会员信息
+// @Tags 会员管理
+// @Produce json
+// @Param user_id path int true "用户ID"
+// @Success 200 {object} response.Response{data=model.MemberProfile} "查询成功"
+// @Router /api/v1/admin/members/{user_id} [get]
+// @Security ApiKeyAuth
+func (h *Handler) GetUserProfile(c *gin.Context) {
+	userID, ok := response.ParseID(c, "user_id", "用户ID格式错误")
+	if !ok {
+		return
+	}
+
+	profile, err := h.service.Profile(userID)
+	if err != nil {
+		h.respondError(c, "查询会员信息失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", profile)
+}
+
+// AdjustGrowth 调整用户成长值
+// @Summary 调整用户成长值
+// @Description 增加或扣减成长值并立即重新评定等级
+// @Tags 会员管理
+// @Accept json
+// @Produce json
+// @Param user_id path int true "用户ID"
+// @Param request body model.AdjustGrowthRequest true "调整内容"
+// @Success 200 {object} response.Response{data=model.UserMembership} "调整成功"
+// @Router /api/v1/admin/members/{user_id}/growth [post]
+// @Security ApiKeyAuth
+func (h *Handler) AdjustGrowth(c *gin.Context) {
+	userID, ok := response.ParseID(c, "user_id", "用户ID格式错误")
+	if !ok {
+		return
+	}
+	var req model.AdjustGrowthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	membership, err := h.service.AdjustGrowth(userID, &req, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "调整成长值失败", err)
+		return
+	}
+
+	response.Success(c, "调整成功", membership)
+}
+
+// respondError 按会员错误类型返回响应
+func (h *Handler) respondError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrMemberLevelNotFound):
+		response.Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, model.ErrMemberLevelDuplicate),
+		errors.Is(err, model.ErrMemberCheckedIn):
+		response.Error(c, http.StatusConflict, err.Error())
+	case errors.Is(err, model.ErrInvalidMemberLevel):
+		response.Error(c, http.StatusBadRequest, err.Error())
+	default:
+		logger.Error(message, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, message)
+	}
+}
@@ -0,0 +1,34 @@
+package member
+
+import (
+	"mall-go/internal/handler/middleware"
+	"mall-go/pkg/member"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 注册会员等级、成长值及签到路由
+func RegisterRoutes(router *gin.RouterGroup, service *member.Service) {
+	handler := NewHandler(service)
+
+	router.GET("/member-levels", handler.ListLevels) // 会员等级及权益
+
+	memberGroup := router.Group("/members")
+	memberGroup.Use(middleware.AuthMiddleware())
+	{
+		memberGroup.GET("/me", handler.GetProfile)                     // 我的会员信息
+		memberGroup.GET("/me/growth-logs", handler.ListGrowthLogs)     // 成长值流水
+		memberGroup.GET("/me/level-changes", handler.ListLevelChanges) // 等级变更记录
+		memberGroup.POST("/check-in", handler.CheckIn)                 // 每日签到
+	}
+
+	adminGroup := router.Group("/admin")
+	adminGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		adminGroup.GET("/member-levels", handler.ListAllLevels)           // 会员等级列表
+		adminGroup.POST("/member-levels", handler.CreateLevel)            // 创建会员等级
+		adminGroup.PUT("/member-levels/:id", handler.UpdateLevel)         // 更新会员等级
+		adminGroup.GET("/members/:user_id", handler.GetUserProfile)       // 用户会员信息
+		adminGroup.POST("/members/:user_id/growth", handler.AdjustGrowth) // 调整成长值
+	}
+}
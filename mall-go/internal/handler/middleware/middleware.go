@@ -103,6 +103,37 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// OptionalAuthMiddleware 可选认证中间件
+// 未携带认证令牌时按游客放行；携带了令牌则必须有效，避免过期登录态被静默当作游客处理
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	auth := AuthMiddleware()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		auth(c)
+	}
+}
+
+// LenientAuthMiddleware 宽松的可选认证中间件
+// 令牌有效时设置用户信息，未携带或无效、过期时按游客放行，用于商品目录等公开接口按登录用户展示个性化内容
+func LenientAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const bearerPrefix = "Bearer "
+		authHeader := c.GetHeader("Authorization")
+		if strings.HasPrefix(authHeader, bearerPrefix) {
+			if claims, err := auth.ParseToken(authHeader[len(bearerPrefix):]); err == nil {
+				c.Set("user_id", claims.UserID)
+				c.Set("username", claims.Username)
+				c.Set("user_role", claims.Role)
+				c.Set("user_claims", claims)
+			}
+		}
+		c.Next()
+	}
+}
+
 // GetUserFromContext 从上下文中获取用户信息
 func GetUserFromContext(c *gin.Context) (userID uint, username string, role string, exists bool) {
 	userIDVal, exists1 := c.Get("user_id")
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"mall-go/internal/config"
 	"mall-go/internal/model"
@@ -302,3 +303,65 @@ func TestAdminOrMerchantMiddleware_UserForbidden(t *testing.T) {
 	assert.Equal(t, http.StatusForbidden, w.Code)
 	assert.Contains(t, w.Body.String(), "需要管理员或商家权限")
 }
+
+func TestOptionalAuthMiddleware(t *testing.T) {
+	router := gin.New()
+	router.Use(OptionalAuthMiddleware())
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetUint("user_id")})
+	})
+
+	// 未携带令牌按游客放行
+	req, _ := http.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"user_id":0`)
+
+	// 携带有效令牌时设置用户信息
+	token, err := auth.GenerateToken(7, "testuser", model.RoleUser)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"user_id":7`)
+
+	// 携带无效令牌时拒绝
+	req, _ = http.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer invalid")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestLenientAuthMiddleware(t *testing.T) {
+	router := gin.New()
+	router.Use(LenientAuthMiddleware())
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetUint("user_id")})
+	})
+
+	// 携带有效令牌时设置用户信息
+	token, err := auth.GenerateToken(7, "testuser", model.RoleUser)
+	assert.NoError(t, err)
+	req, _ := http.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"user_id":7`)
+
+	// 无效、过期或格式错误的令牌按游客放行
+	expired, _, err := auth.GenerateTokenWithExpiry(7, "testuser", model.RoleUser, -time.Hour)
+	assert.NoError(t, err)
+	for _, header := range []string{"Bearer invalid", "Bearer " + expired, "Token " + token} {
+		req, _ = http.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", header)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, header)
+		assert.Contains(t, w.Body.String(), `"user_id":0`, header)
+	}
+}
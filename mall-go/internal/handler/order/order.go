@@ -1,14 +1,21 @@
 package order
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"mall-go/internal/model"
 	"mall-go/pkg/cart"
+	"mall-go/pkg/currency"
+	"mall-go/pkg/giftcard"
 	"mall-go/pkg/inventory"
 	"mall-go/pkg/logger"
+	"mall-go/pkg/member"
 	"mall-go/pkg/order"
+	"mall-go/pkg/payment"
+	"mall-go/pkg/pricelist"
+	"mall-go/pkg/promotion"
 	"mall-go/pkg/response"
 
 	"github.com/gin-gonic/gin"
@@ -26,6 +33,7 @@ type OrderHandler struct {
 	shippingService  *order.ShippingService
 	afterSaleService *order.AfterSaleService
 	cacheService     *order.CacheService
+	shareService     *cart.ShareService
 }
 
 // NewOrderHandler 创建订单处理器
@@ -39,7 +47,15 @@ func NewOrderHandler(db *gorm.DB, rdb *redis.Client) *OrderHandler {
 
 	// 创建订单相关服务
 	orderService := order.NewOrderService(db, cartService, calculationService, inventoryService)
+	orderService.SetCurrencyService(currency.NewService(db))
+	orderService.SetGiftCardService(giftcard.NewService(db, giftcard.DefaultOptions()))
+	orderService.SetPromotionService(promotion.NewService(db))
+	memberService := member.NewService(db, member.DefaultOptions())
+	orderService.SetMemberService(memberService)
+	orderService.SetPriceListService(pricelist.NewService(db))
 	statusService := order.NewStatusService(db)
+	// 订单完成时累积会员成长值并重新评定等级
+	statusService.OnCompleted(memberService.OnOrderCompleted)
 	paymentService := order.NewPaymentService(db, statusService)
 	shippingService := order.NewShippingService(db, statusService)
 	afterSaleService := order.NewAfterSaleService(db, statusService, paymentService)
@@ -53,9 +69,15 @@ func NewOrderHandler(db *gorm.DB, rdb *redis.Client) *OrderHandler {
 		shippingService:  shippingService,
 		afterSaleService: afterSaleService,
 		cacheService:     cacheService,
+		shareService:     cart.NewShareService(db, cartService, cart.DefaultShareOptions()),
 	}
 }
 
+// SetPaymentService 设置支付服务，渠道支付的售后退款通过支付服务执行并等待渠道确认
+func (h *OrderHandler) SetPaymentService(paymentService *payment.Service) {
+	h.afterSaleService.SetRefundService(paymentService)
+}
+
 // Handler 保持向后兼容
 type Handler = OrderHandler
 
@@ -319,6 +341,117 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	response.Success(c, "创建订单成功", order)
 }
 
+// CheckoutSharedCart 分享购物车直接结算
+// 按分享清单的商品和数量以当前价格下单，不经过也不修改结算人的购物车
+func (h *OrderHandler) CheckoutSharedCart(c *gin.Context) {
+	var req model.SharedCartCheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	userID := h.getUserID(c)
+	if userID == 0 {
+		response.Error(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	share, items, err := h.shareService.Items(c.Param("token"), req.ItemIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrCartShareNotFound):
+			response.Error(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, model.ErrCartShareExpired):
+			response.Error(c, http.StatusGone, err.Error())
+		case errors.Is(err, model.ErrCartShareEmpty):
+			response.Error(c, http.StatusBadRequest, err.Error())
+		default:
+			logger.Error("查询分享购物车失败", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "查询分享购物车失败")
+		}
+		return
+	}
+
+	// 获取订单锁
+	lockValue, err := h.cacheService.AcquireOrderLock(userID)
+	if err != nil {
+		response.Error(c, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	defer h.cacheService.ReleaseOrderLock(userID, lockValue)
+
+	orderItems := make([]order.DirectOrderItem, 0, len(items))
+	for _, item := range items {
+		orderItems = append(orderItems, order.DirectOrderItem{
+			ProductID: item.ProductID,
+			SKUID:     item.SKUID,
+			Quantity:  item.Quantity,
+		})
+	}
+	created, err := h.orderService.CreateDirectOrder(userID, &model.OrderCreateRequest{
+		CouponID:        req.CouponID,
+		PointsUsed:      req.PointsUsed,
+		ReceiverName:    req.ReceiverName,
+		ReceiverPhone:   req.ReceiverPhone,
+		ReceiverAddress: req.ReceiverAddress,
+		ReceiverZipCode: req.ReceiverZipCode,
+		Province:        req.Province,
+		City:            req.City,
+		District:        req.District,
+		ShippingMethod:  req.ShippingMethod,
+		BuyerMessage:    req.BuyerMessage,
+		Currency:        req.Currency,
+		GiftCardIDs:     req.GiftCardIDs,
+		GiftCardCodes:   req.GiftCardCodes,
+	}, orderItems)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.shareService.RecordCheckout(share.ID); err != nil {
+		logger.Warn("记录分享结算次数失败", zap.Uint("share_id", share.ID), zap.Error(err))
+	}
+
+	// 清除相关缓存
+	h.cacheService.InvalidateUserOrdersCache(userID)
+	h.cacheService.InvalidateOrderStatsCache()
+
+	response.Success(c, "创建订单成功", created)
+}
+
+// PreviewOrder 下单预览
+// @Summary 下单预览
+// @Description 按当前价格、促销规则、优惠券和积分计算订单金额，返回各商品命中的促销明细，不创建订单
+// @Tags 订单
+// @Accept json
+// @Produce json
+// @Param request body model.OrderPreviewRequest true "预览参数"
+// @Success 200 {object} response.Response{data=order.OrderCalculation} "预览成功"
+// @Router /api/v1/orders/preview [post]
+// @Security ApiKeyAuth
+func (h *OrderHandler) PreviewOrder(c *gin.Context) {
+	var req model.OrderPreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	userID := h.getUserID(c)
+	if userID == 0 {
+		response.Error(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	calculation, err := h.orderService.PreviewOrder(userID, &req)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(c, "预览成功", calculation)
+}
+
 // GetOrder 获取订单详情
 func (h *OrderHandler) GetOrder(c *gin.Context) {
 	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -1,17 +1,21 @@
 package payment
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"mall-go/internal/model"
 	"mall-go/pkg/logger"
 	"mall-go/pkg/order"
 	"mall-go/pkg/payment"
 	"mall-go/pkg/payment/alipay"
+	"mall-go/pkg/payment/callbacklog"
+	"mall-go/pkg/payment/dispute"
 	"mall-go/pkg/payment/wechat"
 
 	"github.com/gin-gonic/gin"
@@ -20,6 +24,7 @@ import (
 )
 
 // CallbackHandler 回调处理器
+// 收到的回调先原样落库再处理，处理失败的回调可在修复后通过管理接口重放
 type CallbackHandler struct {
 	db                 *gorm.DB
 	paymentService     *payment.Service
@@ -27,6 +32,8 @@ type CallbackHandler struct {
 	callbackValidator  *payment.CallbackValidator
 	alipayClient       *alipay.Client
 	wechatClient       *wechat.Client
+	store              *callbacklog.Store
+	disputes           *dispute.Service
 }
 
 // NewCallbackHandler 创建回调处理器
@@ -38,9 +45,17 @@ func NewCallbackHandler(db *gorm.DB, paymentService *payment.Service, orderStatu
 		callbackValidator:  callbackValidator,
 		alipayClient:       alipayClient,
 		wechatClient:       wechatClient,
+		store:              callbacklog.NewStore(db),
+		disputes:           dispute.NewService(db, dispute.DefaultOptions()),
 	}
 }
 
+// errPaymentServiceUnavailable 支付服务未初始化
+var errPaymentServiceUnavailable = errors.New("支付服务未初始化")
+
+// callbackProcessor 回调处理函数，replay 为true表示人工重放保存的回调
+type callbackProcessor func(header http.Header, body []byte, replay bool) error
+
 // AlipayCallback 支付宝回调处理
 // @Summary 支付宝支付回调
 // @Description 处理支付宝异步通知回调
@@ -53,48 +68,132 @@ func NewCallbackHandler(db *gorm.DB, paymentService *payment.Service, orderStatu
 func (h *CallbackHandler) AlipayCallback(c *gin.Context) {
 	logger.Info("收到支付宝回调通知")
 
-	// 解析POST参数
-	if err := c.Request.ParseForm(); err != nil {
-		logger.Error("解析支付宝回调参数失败", zap.Error(err))
+	// 读取请求体
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		logger.Error("读取支付宝回调数据失败", zap.Error(err))
+		c.String(http.StatusBadRequest, "fail")
+		return
+	}
+
+	if err := h.handleCallback(c, model.PaymentMethodAlipay, alipayCallbackKind(body), "", body, h.processAlipayNotify); err != nil {
+		logger.Error("处理支付宝回调失败", zap.Error(err))
 		c.String(http.StatusBadRequest, "fail")
 		return
 	}
 
+	// 返回成功响应
+	c.String(http.StatusOK, "success")
+}
+
+// handleCallback 保存回调原文、处理并记录处理结果
+// 回调保存失败不影响处理，避免因日志表异常导致渠道反复通知
+func (h *CallbackHandler) handleCallback(c *gin.Context, channel model.PaymentMethod, kind model.PaymentCallbackKind, apiVersion string, body []byte, process callbackProcessor) error {
+	callback, err := h.store.Save(&callbacklog.Notification{
+		Channel:    channel,
+		Kind:       kind,
+		APIVersion: apiVersion,
+		Path:       c.Request.URL.Path,
+		Header:     c.Request.Header,
+		Body:       body,
+		ClientIP:   c.ClientIP(),
+	})
+	if err != nil {
+		logger.Error("保存回调通知失败", zap.String("channel", string(channel)), zap.Error(err))
+	}
+
+	start := time.Now()
+	processErr := runCallbackProcessor(process, c.Request.Header, body, false)
+	h.recordCallbackMetrics(channel, processErr)
+
+	if callback != nil {
+		if err := h.store.Complete(callback, processErr, time.Since(start)); err != nil {
+			logger.Error("记录回调处理结果失败", zap.Uint("callback_id", callback.ID), zap.Error(err))
+		}
+	}
+	return processErr
+}
+
+// runCallbackProcessor 执行回调处理，处理过程中的panic转换为错误以便记录处理结果
+func runCallbackProcessor(process callbackProcessor, header http.Header, body []byte, replay bool) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("处理回调时发生panic", zap.Any("panic", r))
+			err = fmt.Errorf("处理回调时发生panic: %v", r)
+		}
+	}()
+	return process(header, body, replay)
+}
+
+// recordCallbackMetrics 按渠道记录回调指标
+func (h *CallbackHandler) recordCallbackMetrics(channel model.PaymentMethod, processErr error) {
+	if h.paymentService == nil {
+		return
+	}
+	status := "success"
+	if processErr != nil {
+		status = "failed"
+	}
+	h.paymentService.Router().Metrics().RecordCallback(channel, status)
+}
+
+// processAlipayNotify 验签并处理支付宝支付或退款通知
+func (h *CallbackHandler) processAlipayNotify(header http.Header, body []byte, replay bool) error {
+	// 解析POST参数
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return model.NewPaymentCallbackValidationError(fmt.Errorf("解析支付宝回调参数失败: %v", err))
+	}
+
 	// 转换为map
 	params := make(map[string]string)
-	for key, values := range c.Request.PostForm {
-		if len(values) > 0 {
-			params[key] = values[0]
-		}
+	for key := range values {
+		params[key] = values.Get(key)
 	}
 
 	logger.Info("支付宝回调参数",
 		zap.String("out_trade_no", params["out_trade_no"]),
 		zap.String("trade_no", params["trade_no"]),
-		zap.String("trade_status", params["trade_status"]))
+		zap.String("trade_status", params["trade_status"]),
+		zap.Bool("replay", replay))
 
 	// 验证签名
 	if h.alipayClient != nil {
 		if err := h.alipayClient.VerifyCallback(params); err != nil {
-			logger.Error("支付宝回调签名验证失败", zap.Error(err))
-			c.String(http.StatusBadRequest, "fail")
-			return
+			return model.NewPaymentCallbackValidationError(fmt.Errorf("支付宝回调签名验证失败: %v", err))
 		}
 	}
 
-	// 处理回调数据
-	if err := h.processAlipayCallback(params); err != nil {
-		logger.Error("处理支付宝回调失败", zap.Error(err))
-		c.String(http.StatusBadRequest, "fail")
-		return
+	// 退款通知（部分退款时支付宝通过同一通知地址下发）
+	if alipay.ParseRefundNotify(params) != nil {
+		if h.paymentService == nil {
+			return errPaymentServiceUnavailable
+		}
+		return h.paymentService.HandleAlipayRefundNotify(params)
 	}
 
-	// 返回成功响应
-	c.String(http.StatusOK, "success")
+	// 处理回调数据
+	return h.processAlipayCallback(params, replay)
+}
+
+// alipayCallbackKind 判断支付宝通知类型，退款与支付使用同一通知地址
+func alipayCallbackKind(body []byte) model.PaymentCallbackKind {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return model.PaymentCallbackKindPayment
+	}
+	params := make(map[string]string)
+	for key := range values {
+		params[key] = values.Get(key)
+	}
+	if alipay.ParseRefundNotify(params) != nil {
+		return model.PaymentCallbackKindRefund
+	}
+	return model.PaymentCallbackKindPayment
 }
 
 // processAlipayCallback 处理支付宝回调数据
-func (h *CallbackHandler) processAlipayCallback(params map[string]string) error {
+func (h *CallbackHandler) processAlipayCallback(params map[string]string, replay bool) error {
 	// 构建回调数据结构
 	callbackData := &payment.AlipayCallbackData{
 		AppID:       params["app_id"],
@@ -120,15 +219,22 @@ func (h *CallbackHandler) processAlipayCallback(params map[string]string) error
 		zap.String("trade_status", callbackData.TradeStatus))
 
 	// 验证回调数据
+	if h.callbackValidator == nil {
+		return fmt.Errorf("回调验证器未初始化")
+	}
 	secretKey := "your_alipay_secret_key" // 应该从配置中获取
-	validationResult := h.callbackValidator.ValidateAlipayCallback(callbackData, secretKey)
+	validate := h.callbackValidator.ValidateAlipayCallback
+	if replay {
+		validate = h.callbackValidator.ValidateReplayedAlipayCallback
+	}
+	validationResult := validate(callbackData, secretKey)
 
 	if !validationResult.Valid {
 		logger.Error("支付宝回调验证失败",
 			zap.String("error_code", validationResult.ErrorCode),
 			zap.String("error_message", validationResult.ErrorMessage),
 			zap.String("out_trade_no", callbackData.OutTradeNo))
-		return fmt.Errorf("回调验证失败: %s", validationResult.ErrorMessage)
+		return model.NewPaymentCallbackValidationError(fmt.Errorf("回调验证失败: %s", validationResult.ErrorMessage))
 	}
 
 	// 获取支付记录
@@ -212,6 +318,11 @@ func (h *CallbackHandler) processAlipayCallback(params map[string]string) error
 		return err
 	}
 
+	// 支付失败或关闭时释放预占的累计限额
+	if h.paymentService != nil {
+		h.paymentService.ReleasePaymentLimit(&payment)
+	}
+
 	logger.Info("支付宝回调处理成功",
 		zap.String("payment_no", callbackData.OutTradeNo),
 		zap.String("trade_no", callbackData.TradeNo),
@@ -242,30 +353,147 @@ func (h *CallbackHandler) WechatCallback(c *gin.Context) {
 
 	logger.Info("微信回调原始数据", zap.String("body", string(body)))
 
+	err = h.handleCallback(c, model.PaymentMethodWechat, model.PaymentCallbackKindPayment, wechatAPIVersion(c.Request.Header), body, h.processWechatNotify)
+	if err != nil {
+		logger.Error("处理微信回调失败", zap.Error(err))
+	}
+	h.respondWechat(c, err)
+}
+
+// WechatRefundCallback 微信退款结果通知处理
+// @Summary 微信退款结果通知
+// @Description 处理微信支付退款结果异步通知，通知内容使用商户密钥加密
+// @Tags 支付回调
+// @Accept application/xml
+// @Produce application/xml
+// @Success 200 {string} string "success xml"
+// @Failure 400 {string} string "fail xml"
+// @Router /api/v1/payments/callback/wechat/refund [post]
+func (h *CallbackHandler) WechatRefundCallback(c *gin.Context) {
+	logger.Info("收到微信退款结果通知")
+
+	// 读取请求体
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		logger.Error("读取微信退款通知失败", zap.Error(err))
+		c.Data(http.StatusBadRequest, "application/xml", []byte(wechat.BuildFailResponse("读取数据失败")))
+		return
+	}
+
+	err = h.handleCallback(c, model.PaymentMethodWechat, model.PaymentCallbackKindRefund, wechatAPIVersion(c.Request.Header), body, h.processWechatRefundNotify)
+	if err != nil {
+		logger.Error("处理微信退款通知失败", zap.Error(err))
+	}
+	h.respondWechat(c, err)
+}
+
+// WechatComplaintCallback 微信支付消费者投诉通知处理
+// @Summary 微信支付投诉通知
+// @Description 处理微信支付APIv3消费者投诉通知，为投诉关联的支付登记争议
+// @Tags 支付回调
+// @Accept application/json
+// @Produce application/json
+// @Success 200 {object} map[string]string "成功"
+// @Failure 400 {object} map[string]string "处理失败"
+// @Router /api/v1/payments/callback/wechat/complaint [post]
+func (h *CallbackHandler) WechatComplaintCallback(c *gin.Context) {
+	logger.Info("收到微信支付投诉通知")
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		logger.Error("读取微信投诉通知失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"code": "FAIL", "message": "读取数据失败"})
+		return
+	}
+
+	err = h.handleCallback(c, model.PaymentMethodWechat, model.PaymentCallbackKindComplaint, "v3", body, h.processWechatComplaintNotify)
+	if err != nil {
+		logger.Error("处理微信投诉通知失败", zap.Error(err))
+	}
+	h.respondWechat(c, err)
+}
+
+// processWechatComplaintNotify 验签解密微信投诉通知，为投诉关联的每笔支付登记争议
+// 投诉单号相同的重复通知不会重复登记；非本系统的订单或已有未结案争议的支付忽略
+func (h *CallbackHandler) processWechatComplaintNotify(header http.Header, body []byte, replay bool) error {
+	if h.paymentService == nil {
+		return errPaymentServiceUnavailable
+	}
+
+	notify, complaint, err := h.paymentService.ParseWechatComplaint(header, body, replay)
+	if err != nil {
+		return err
+	}
+	if notify.ActionType != wechat.ComplaintActionCreate && notify.ActionType != wechat.ComplaintActionContinue {
+		logger.Info("忽略微信投诉通知",
+			zap.String("complaint_id", notify.ComplaintID),
+			zap.String("action_type", notify.ActionType))
+		return nil
+	}
+
+	for _, complaintOrder := range complaint.Orders {
+		amount := complaintOrder.Amount
+		if complaint.ApplyRefundAmount.IsPositive() && complaint.ApplyRefundAmount.LessThan(amount) {
+			amount = complaint.ApplyRefundAmount
+		}
+
+		_, created, err := h.disputes.OpenFromChannel(&dispute.ChannelDispute{
+			Channel:          model.PaymentMethodWechat,
+			ChannelDisputeID: complaint.ComplaintID,
+			Type:             model.PaymentDisputeComplaint,
+			OutTradeNo:       complaintOrder.OutTradeNo,
+			Amount:           amount,
+			Reason:           complaint.ComplaintDetail,
+			OpenedAt:         complaint.ComplaintTime,
+		})
+		switch {
+		case errors.Is(err, model.ErrPaymentNotFound), errors.Is(err, model.ErrPaymentDisputeExists), errors.Is(err, model.ErrPaymentNotDisputable):
+			logger.Warn("微信投诉未登记争议",
+				zap.String("complaint_id", complaint.ComplaintID),
+				zap.String("out_trade_no", complaintOrder.OutTradeNo),
+				zap.Error(err))
+		case err != nil:
+			return err
+		case created:
+			logger.Info("微信投诉已登记争议",
+				zap.String("complaint_id", complaint.ComplaintID),
+				zap.String("out_trade_no", complaintOrder.OutTradeNo))
+		}
+	}
+	return nil
+}
+
+// processWechatNotify 验签并处理微信支付结果通知，APIv3通知的签名位于请求头中
+func (h *CallbackHandler) processWechatNotify(header http.Header, body []byte, replay bool) error {
+	if isWechatV3Header(header) {
+		if h.paymentService == nil {
+			return errPaymentServiceUnavailable
+		}
+		if replay {
+			return h.paymentService.ReplayWechatV3Callback(header, body)
+		}
+		return h.paymentService.ProcessWechatV3Callback(header, body)
+	}
+
 	// 解析回调数据
 	if h.wechatClient == nil {
-		logger.Error("微信支付客户端未初始化")
-		c.Data(http.StatusBadRequest, "application/xml", []byte(wechat.BuildFailResponse("客户端未初始化")))
-		return
+		return fmt.Errorf("微信支付客户端未初始化")
 	}
 
 	callback, err := h.wechatClient.ParseCallback(body)
 	if err != nil {
-		logger.Error("解析微信回调数据失败", zap.Error(err))
-		c.Data(http.StatusBadRequest, "application/xml", []byte(wechat.BuildFailResponse("解析数据失败")))
-		return
+		return model.NewPaymentCallbackValidationError(fmt.Errorf("解析微信回调数据失败: %v", err))
 	}
 
 	logger.Info("微信回调数据",
 		zap.String("out_trade_no", callback.OutTradeNo),
 		zap.String("transaction_id", callback.TransactionID),
-		zap.String("result_code", callback.ResultCode))
+		zap.String("result_code", callback.ResultCode),
+		zap.Bool("replay", replay))
 
 	// 验证回调数据
 	if err := callback.Validate(); err != nil {
-		logger.Error("微信回调数据验证失败", zap.Error(err))
-		c.Data(http.StatusBadRequest, "application/xml", []byte(wechat.BuildFailResponse("数据验证失败")))
-		return
+		return model.NewPaymentCallbackValidationError(fmt.Errorf("微信回调数据验证失败: %v", err))
 	}
 
 	// 验证签名
@@ -279,20 +507,68 @@ func (h *CallbackHandler) WechatCallback(c *gin.Context) {
 	}
 
 	if err := h.wechatClient.VerifyCallback(params); err != nil {
-		logger.Error("微信回调签名验证失败", zap.Error(err))
-		c.Data(http.StatusBadRequest, "application/xml", []byte(wechat.BuildFailResponse("签名验证失败")))
-		return
+		return model.NewPaymentCallbackValidationError(fmt.Errorf("微信回调签名验证失败: %v", err))
 	}
 
 	// 处理回调数据
-	if err := h.processWechatCallback(callback); err != nil {
-		logger.Error("处理微信回调失败", zap.Error(err))
-		c.Data(http.StatusBadRequest, "application/xml", []byte(wechat.BuildFailResponse("处理失败")))
+	return h.processWechatCallback(callback)
+}
+
+// processWechatRefundNotify 处理微信退款结果通知
+func (h *CallbackHandler) processWechatRefundNotify(header http.Header, body []byte, replay bool) error {
+	if h.paymentService == nil {
+		return errPaymentServiceUnavailable
+	}
+
+	if isWechatV3Header(header) {
+		if replay {
+			return h.paymentService.ReplayWechatV3RefundCallback(header, body)
+		}
+		return h.paymentService.ProcessWechatV3RefundCallback(header, body)
+	}
+
+	return h.paymentService.ProcessRefundCallback(model.PaymentMethodWechat, body)
+}
+
+// isWechatV3Header 是否为微信支付APIv3通知
+func isWechatV3Header(header http.Header) bool {
+	return header.Get(wechat.HeaderSignature) != ""
+}
+
+// wechatAPIVersion 微信通知的接口版本
+func wechatAPIVersion(header http.Header) string {
+	if isWechatV3Header(header) {
+		return "v3"
+	}
+	return "v2"
+}
+
+// respondWechat 应答微信支付通知，APIv3为JSON格式，v2为XML格式
+// 处理失败时返回非200状态码，微信支付会按策略重新通知
+func (h *CallbackHandler) respondWechat(c *gin.Context, err error) {
+	if isWechatV3Header(c.Request.Header) {
+		switch {
+		case errors.Is(err, errPaymentServiceUnavailable):
+			c.JSON(http.StatusInternalServerError, gin.H{"code": "FAIL", "message": "服务未初始化"})
+		case err != nil:
+			c.JSON(http.StatusBadRequest, gin.H{"code": "FAIL", "message": "处理失败"})
+		default:
+			c.JSON(http.StatusOK, gin.H{"code": "SUCCESS", "message": "成功"})
+		}
 		return
 	}
 
-	// 返回成功响应
-	c.Data(http.StatusOK, "application/xml", []byte(wechat.BuildSuccessResponse()))
+	var validationErr *model.PaymentCallbackValidationError
+	switch {
+	case errors.Is(err, errPaymentServiceUnavailable):
+		c.Data(http.StatusBadRequest, "application/xml", []byte(wechat.BuildFailResponse("服务未初始化")))
+	case errors.As(err, &validationErr):
+		c.Data(http.StatusBadRequest, "application/xml", []byte(wechat.BuildFailResponse("数据验证失败")))
+	case err != nil:
+		c.Data(http.StatusBadRequest, "application/xml", []byte(wechat.BuildFailResponse("处理失败")))
+	default:
+		c.Data(http.StatusOK, "application/xml", []byte(wechat.BuildSuccessResponse()))
+	}
 }
 
 // processWechatCallback 处理微信回调数据
@@ -386,6 +662,11 @@ func (h *CallbackHandler) processWechatCallback(callback *wechat.CallbackData) e
 		return err
 	}
 
+	// 支付失败或关闭时释放预占的累计限额
+	if h.paymentService != nil {
+		h.paymentService.ReleasePaymentLimit(&payment)
+	}
+
 	logger.Info("微信支付回调处理成功",
 		zap.String("payment_no", outTradeNo),
 		zap.String("transaction_id", transactionID),
@@ -0,0 +1,198 @@
+package payment
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/payment/callbacklog"
+	"mall-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ListCallbacks 查询支付回调通知
+// @Summary 查询支付回调通知
+// @Description 查询保存的回调原文与处理状态，failed_only=true 时仅返回校验失败或处理失败的回调（死信）
+// @Tags 支付回调
+// @Produce json
+// @Param channel query string false "支付渠道"
+// @Param kind query string false "通知类型(payment/refund)"
+// @Param status query string false "处理状态"
+// @Param failed_only query bool false "仅查询失败的回调"
+// @Param out_trade_no query string false "商户订单号"
+// @Param start_time query string false "接收时间起(2006-01-02 15:04:05)"
+// @Param end_time query string false "接收时间止(2006-01-02 15:04:05)"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/admin/payments/callbacks [get]
+// @Security ApiKeyAuth
+func (h *CallbackHandler) ListCallbacks(c *gin.Context) {
+	var query model.PaymentCallbackQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	callbacks, total, err := h.store.List(&query)
+	if err != nil {
+		logger.Error("查询回调通知失败", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "查询回调通知失败")
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", callbacks, total, query.Page, query.PageSize)
+}
+
+// GetCallbackStats 按渠道统计回调数量与失败情况
+// @Summary 支付回调统计
+// @Tags 支付回调
+// @Produce json
+// @Param start_time query string false "接收时间起(2006-01-02 15:04:05)"
+// @Param end_time query string false "接收时间止(2006-01-02 15:04:05)"
+// @Success 200 {object} response.Response{data=[]model.PaymentCallbackStats} "查询成功"
+// @Router /api/v1/admin/payments/callbacks/stats [get]
+// @Security ApiKeyAuth
+func (h *CallbackHandler) GetCallbackStats(c *gin.Context) {
+	var query struct {
+		StartTime *time.Time `form:"start_time" time_format:"2006-01-02 15:04:05"`
+		EndTime   *time.Time `form:"end_time" time_format:"2006-01-02 15:04:05"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	stats, err := h.store.Stats(query.StartTime, query.EndTime)
+	if err != nil {
+		logger.Error("统计回调通知失败", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "统计回调通知失败")
+		return
+	}
+
+	response.Success(c, "查询成功", stats)
+}
+
+// GetCallback 查询回调通知详情
+// @Summary 查询支付回调详情
+// @Description 返回回调原文、请求头及首次处理与每次重放的处理记录
+// @Tags 支付回调
+// @Produce json
+// @Param id path uint true "回调ID"
+// @Success 200 {object} response.Response{data=model.PaymentCallback} "查询成功"
+// @Router /api/v1/admin/payments/callbacks/{id} [get]
+// @Security ApiKeyAuth
+func (h *CallbackHandler) GetCallback(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "回调ID格式错误")
+		return
+	}
+
+	callback, err := h.store.Get(uint(id))
+	if err != nil {
+		if errors.Is(err, model.ErrPaymentCallbackNotFound) {
+			response.Error(c, http.StatusNotFound, err.Error())
+			return
+		}
+		logger.Error("查询回调通知失败", zap.Uint64("id", id), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "查询回调通知失败")
+		return
+	}
+
+	response.Success(c, "查询成功", callback)
+}
+
+// ReplayCallback 重放回调通知
+// @Summary 重放支付回调
+// @Description 使用当前的签名校验与业务处理逻辑重新处理保存的回调原文，跳过通知时间、通知ID等防重放校验，签名仍需校验通过
+// @Tags 支付回调
+// @Produce json
+// @Param id path uint true "回调ID"
+// @Success 200 {object} response.Response{data=model.PaymentCallback} "重放完成"
+// @Router /api/v1/admin/payments/callbacks/{id}/replay [post]
+// @Security ApiKeyAuth
+func (h *CallbackHandler) ReplayCallback(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "回调ID格式错误")
+		return
+	}
+
+	callback, err := h.store.Get(uint(id))
+	if err != nil {
+		if errors.Is(err, model.ErrPaymentCallbackNotFound) {
+			response.Error(c, http.StatusNotFound, err.Error())
+			return
+		}
+		logger.Error("查询回调通知失败", zap.Uint64("id", id), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "查询回调通知失败")
+		return
+	}
+	if callback.Status == model.PaymentCallbackSucceeded {
+		response.Error(c, http.StatusConflict, model.ErrPaymentCallbackAlreadySucceeded.Error())
+		return
+	}
+
+	process := h.processorFor(callback.Channel, callback.Kind)
+	if process == nil {
+		response.Error(c, http.StatusBadRequest, "不支持重放该渠道的回调")
+		return
+	}
+	header, err := callbacklog.Header(callback)
+	if err != nil {
+		logger.Error("还原回调请求头失败", zap.Uint64("id", id), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "还原回调请求头失败")
+		return
+	}
+
+	operatorID := c.GetUint("user_id")
+	start := time.Now()
+	processErr := runCallbackProcessor(process, header, []byte(callback.Body), true)
+	if processErr != nil {
+		logger.Warn("重放回调处理失败",
+			zap.Uint64("id", id),
+			zap.Uint("operator_id", operatorID),
+			zap.Error(processErr))
+	} else {
+		logger.Info("重放回调处理成功", zap.Uint64("id", id), zap.Uint("operator_id", operatorID))
+	}
+
+	if err := h.store.CompleteReplay(callback, operatorID, processErr, time.Since(start)); err != nil {
+		logger.Error("记录回调重放结果失败", zap.Uint64("id", id), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "记录回调重放结果失败")
+		return
+	}
+
+	callback, err = h.store.Get(uint(id))
+	if err != nil {
+		logger.Error("查询回调通知失败", zap.Uint64("id", id), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "查询回调通知失败")
+		return
+	}
+
+	response.Success(c, "重放完成", callback)
+}
+
+// processorFor 按渠道与通知类型选择回调处理函数
+func (h *CallbackHandler) processorFor(channel model.PaymentMethod, kind model.PaymentCallbackKind) callbackProcessor {
+	switch channel {
+	case model.PaymentMethodAlipay:
+		return h.processAlipayNotify
+	case model.PaymentMethodWechat:
+		switch kind {
+		case model.PaymentCallbackKindRefund:
+			return h.processWechatRefundNotify
+		case model.PaymentCallbackKindComplaint:
+			return h.processWechatComplaintNotify
+		}
+		return h.processWechatNotify
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,230 @@
+package payment
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/payment/dispute"
+	"mall-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// DisputeHandler 支付争议管理处理器
+type DisputeHandler struct {
+	service *dispute.Service
+}
+
+// NewDisputeHandler 创建支付争议管理处理器
+func NewDisputeHandler(service *dispute.Service) *DisputeHandler {
+	return &DisputeHandler{
+		service: service,
+	}
+}
+
+// ListDisputes 查询支付争议
+// @Summary 查询支付争议
+// @Description due_before 用于查询证据截止时间临近的未结案争议
+// @Tags 支付争议
+// @Produce json
+// @Param status query string false "争议状态(open/submitted/won/lost)"
+// @Param channel query string false "支付渠道"
+// @Param order_id query uint false "订单ID"
+// @Param due_before query string false "证据截止时间早于(2006-01-02 15:04:05)"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/admin/payments/disputes [get]
+// @Security ApiKeyAuth
+func (h *DisputeHandler) ListDisputes(c *gin.Context) {
+	var query model.PaymentDisputeQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	disputes, total, err := h.service.List(&query)
+	if err != nil {
+		logger.Error("查询支付争议失败", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "查询支付争议失败")
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", disputes, total, query.Page, query.PageSize)
+}
+
+// CreateDispute 登记支付争议
+// @Summary 登记支付争议
+// @Description 人工登记银行卡拒付或渠道投诉，争议处理期间订单暂不结算
+// @Tags 支付争议
+// @Accept json
+// @Produce json
+// @Param request body model.CreatePaymentDisputeRequest true "争议信息"
+// @Success 200 {object} response.Response{data=model.PaymentDispute} "登记成功"
+// @Router /api/v1/admin/payments/disputes [post]
+// @Security ApiKeyAuth
+func (h *DisputeHandler) CreateDispute(c *gin.Context) {
+	var req model.CreatePaymentDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	result, err := h.service.Create(&req, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "登记支付争议失败", err)
+		return
+	}
+
+	response.Success(c, "登记成功", result)
+}
+
+// GetDispute 查询支付争议详情
+// @Summary 查询支付争议详情
+// @Tags 支付争议
+// @Produce json
+// @Param id path uint true "争议ID"
+// @Success 200 {object} response.Response{data=model.PaymentDispute} "查询成功"
+// @Router /api/v1/admin/payments/disputes/{id} [get]
+// @Security ApiKeyAuth
+func (h *DisputeHandler) GetDispute(c *gin.Context) {
+	id, ok := parseDisputeID(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.Get(id)
+	if err != nil {
+		h.respondError(c, "查询支付争议失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", result)
+}
+
+// UploadEvidence 上传争议证据
+// @Summary 上传争议证据
+// @Description 证据须在截止时间前上传，文件通过上传服务保存
+// @Tags 支付争议
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path uint true "争议ID"
+// @Param file formData file true "证据文件"
+// @Param description formData string false "证据说明"
+// @Success 200 {object} response.Response{data=model.PaymentDisputeEvidence} "上传成功"
+// @Router /api/v1/admin/payments/disputes/{id}/evidence [post]
+// @Security ApiKeyAuth
+func (h *DisputeHandler) UploadEvidence(c *gin.Context) {
+	id, ok := parseDisputeID(c)
+	if !ok {
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "请选择证据文件")
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "读取证据文件失败")
+		return
+	}
+	defer file.Close()
+
+	evidence, err := h.service.AddEvidence(id, c.GetUint("user_id"), header.Filename, file, header.Size, c.PostForm("description"))
+	if err != nil {
+		h.respondError(c, "上传争议证据失败", err)
+		return
+	}
+
+	response.Success(c, "上传成功", evidence)
+}
+
+// SubmitEvidence 提交争议证据
+// @Summary 提交争议证据
+// @Description 至少上传一份证据后提交，争议进入等待渠道裁决状态
+// @Tags 支付争议
+// @Produce json
+// @Param id path uint true "争议ID"
+// @Success 200 {object} response.Response{data=model.PaymentDispute} "提交成功"
+// @Router /api/v1/admin/payments/disputes/{id}/submit [post]
+// @Security ApiKeyAuth
+func (h *DisputeHandler) SubmitEvidence(c *gin.Context) {
+	id, ok := parseDisputeID(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.SubmitEvidence(id, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "提交争议证据失败", err)
+		return
+	}
+
+	response.Success(c, "提交成功", result)
+}
+
+// ResolveDispute 登记争议裁决结果
+// @Summary 登记争议裁决结果
+// @Description 败诉的争议金额在商家下一期结算单中冲回，订单禁止继续发货
+// @Tags 支付争议
+// @Accept json
+// @Produce json
+// @Param id path uint true "争议ID"
+// @Param request body model.ResolvePaymentDisputeRequest true "裁决结果"
+// @Success 200 {object} response.Response{data=model.PaymentDispute} "登记成功"
+// @Router /api/v1/admin/payments/disputes/{id}/resolve [post]
+// @Security ApiKeyAuth
+func (h *DisputeHandler) ResolveDispute(c *gin.Context) {
+	id, ok := parseDisputeID(c)
+	if !ok {
+		return
+	}
+
+	var req model.ResolvePaymentDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	result, err := h.service.Resolve(id, &req, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "登记争议裁决结果失败", err)
+		return
+	}
+
+	response.Success(c, "登记成功", result)
+}
+
+// respondError 按争议错误类型返回响应
+func (h *DisputeHandler) respondError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrPaymentDisputeNotFound), errors.Is(err, model.ErrPaymentNotFound):
+		response.Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, model.ErrPaymentDisputeExists), errors.Is(err, model.ErrPaymentDisputeClosed):
+		response.Error(c, http.StatusConflict, err.Error())
+	case errors.Is(err, model.ErrPaymentNotDisputable),
+		errors.Is(err, model.ErrInvalidDisputeAmount),
+		errors.Is(err, model.ErrDisputeEvidenceRequired),
+		errors.Is(err, model.ErrDisputeEvidenceOverdue):
+		response.Error(c, http.StatusBadRequest, err.Error())
+	default:
+		logger.Error(message, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, message)
+	}
+}
+
+// parseDisputeID 解析路径中的争议ID
+func parseDisputeID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "争议ID格式错误")
+		return 0, false
+	}
+	return uint(id), true
+}
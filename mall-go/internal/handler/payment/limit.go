@@ -0,0 +1,132 @@
+package payment
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/payment/limit"
+	"mall-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// LimitHandler 用户累计支付限额管理处理器
+type LimitHandler struct {
+	limiter *limit.Limiter
+}
+
+// NewLimitHandler 创建用户累计支付限额管理处理器
+func NewLimitHandler(limiter *limit.Limiter) *LimitHandler {
+	return &LimitHandler{
+		limiter: limiter,
+	}
+}
+
+// UserLimitDetail 用户累计限额详情
+type UserLimitDetail struct {
+	Usage     []model.PaymentLimitUsage `json:"usage"`     // 本日、本月使用情况
+	Overrides []model.PaymentUserLimit  `json:"overrides"` // 用户单独设置的限额
+}
+
+// GetUserLimits 查询用户累计限额与使用情况
+// @Summary 查询用户累计支付限额
+// @Description 返回用户本日、本月各支付方式的已用额度（含支付中）与生效限额
+// @Tags 支付限额
+// @Produce json
+// @Param user_id path uint true "用户ID"
+// @Success 200 {object} response.Response{data=UserLimitDetail} "查询成功"
+// @Router /api/v1/admin/payments/limits/users/{user_id} [get]
+// @Security ApiKeyAuth
+func (h *LimitHandler) GetUserLimits(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "用户ID格式错误")
+		return
+	}
+
+	usage, err := h.limiter.Usage(uint(userID))
+	if err != nil {
+		logger.Error("查询用户累计支付额度失败", zap.Uint64("user_id", userID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "查询用户累计支付额度失败")
+		return
+	}
+	overrides, err := h.limiter.ListOverrides(uint(userID))
+	if err != nil {
+		logger.Error("查询用户支付限额失败", zap.Uint64("user_id", userID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "查询用户支付限额失败")
+		return
+	}
+
+	response.Success(c, "查询成功", UserLimitDetail{Usage: usage, Overrides: overrides})
+}
+
+// SaveUserLimit 设置用户累计限额
+// @Summary 设置用户累计支付限额
+// @Description 按支付方式与统计周期覆盖默认限额，支付方式为空表示全部支付方式合计，保存后立即生效
+// @Tags 支付限额
+// @Accept json
+// @Produce json
+// @Param user_id path uint true "用户ID"
+// @Param request body model.PaymentUserLimitRequest true "限额信息"
+// @Success 200 {object} response.Response{data=model.PaymentUserLimit} "保存成功"
+// @Router /api/v1/admin/payments/limits/users/{user_id}/overrides [post]
+// @Security ApiKeyAuth
+func (h *LimitHandler) SaveUserLimit(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "用户ID格式错误")
+		return
+	}
+
+	var req model.PaymentUserLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	override, err := h.limiter.SaveOverride(uint(userID), &req, c.GetUint("user_id"))
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidPaymentLimit) {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		logger.Error("保存用户支付限额失败", zap.Uint64("user_id", userID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "保存用户支付限额失败")
+		return
+	}
+
+	response.Success(c, "保存成功", override)
+}
+
+// DeleteUserLimit 删除用户累计限额
+// @Summary 删除用户累计支付限额
+// @Description 删除后恢复使用默认限额
+// @Tags 支付限额
+// @Produce json
+// @Param id path uint true "限额配置ID"
+// @Success 200 {object} response.Response "删除成功"
+// @Router /api/v1/admin/payments/limits/overrides/{id} [delete]
+// @Security ApiKeyAuth
+func (h *LimitHandler) DeleteUserLimit(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "限额配置ID格式错误")
+		return
+	}
+
+	if err := h.limiter.DeleteOverride(uint(id)); err != nil {
+		if errors.Is(err, model.ErrPaymentUserLimitNotFound) {
+			response.Error(c, http.StatusNotFound, err.Error())
+			return
+		}
+		logger.Error("删除用户支付限额失败", zap.Uint64("id", id), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "删除用户支付限额失败")
+		return
+	}
+
+	response.Success(c, "删除成功", nil)
+}
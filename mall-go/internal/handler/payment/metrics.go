@@ -0,0 +1,32 @@
+package payment
+
+import (
+	"mall-go/pkg/payment"
+	"mall-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsHandler 支付指标处理器
+type MetricsHandler struct {
+	metrics *payment.PaymentMetrics
+}
+
+// NewMetricsHandler 创建支付指标处理器
+func NewMetricsHandler(metrics *payment.PaymentMetrics) *MetricsHandler {
+	return &MetricsHandler{
+		metrics: metrics,
+	}
+}
+
+// GetMetrics 获取支付指标
+// @Summary 支付指标
+// @Description 按支付方式返回创建、查询、回调指标，以及主动轮询补单(poll_rescued)和过期关单(poll_closed)笔数
+// @Tags 支付管理
+// @Produce json
+// @Success 200 {object} response.Response{data=[]payment.PaymentMetricsSummary} "查询成功"
+// @Router /api/v1/admin/payments/metrics [get]
+// @Security ApiKeyAuth
+func (h *MetricsHandler) GetMetrics(c *gin.Context) {
+	response.Success(c, "查询成功", h.metrics.GetSummary())
+}
@@ -1,12 +1,14 @@
 package payment
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"mall-go/internal/model"
 	"mall-go/pkg/logger"
 	"mall-go/pkg/payment"
+	"mall-go/pkg/payment/qrcode"
 	"mall-go/pkg/response"
 
 	"github.com/gin-gonic/gin"
@@ -39,6 +41,8 @@ func NewHandler(db *gorm.DB, paymentService *payment.Service) *Handler {
 // @Success 200 {object} response.Response{data=model.PaymentCreateResponse} "创建成功"
 // @Failure 400 {object} response.Response "请求参数错误"
 // @Failure 401 {object} response.Response "未授权"
+// @Failure 403 {object} response.Response{data=model.PaymentLimitExceededError} "超出累计支付限额或风控拒绝"
+// @Failure 428 {object} response.Response{data=model.PaymentRiskChallengeError} "需要二次验证"
 // @Failure 500 {object} response.Response "服务器错误"
 // @Router /api/v1/payments [post]
 // @Security ApiKeyAuth
@@ -49,6 +53,10 @@ func (h *Handler) CreatePayment(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, "请求参数错误")
 		return
 	}
+	req.ClientIP = c.ClientIP()
+	if req.DeviceID == "" {
+		req.DeviceID = c.GetHeader("X-Device-ID")
+	}
 
 	logger.Info("创建支付请求",
 		zap.Uint("order_id", req.OrderID),
@@ -58,6 +66,22 @@ func (h *Handler) CreatePayment(c *gin.Context) {
 	// 创建支付
 	resp, err := h.paymentService.CreatePayment(&req)
 	if err != nil {
+		var challenge *model.PaymentRiskChallengeError
+		var exceeded *model.PaymentLimitExceededError
+		switch {
+		case errors.As(err, &challenge):
+			response.JSON(c, http.StatusPreconditionRequired, http.StatusPreconditionRequired, challenge.Error(), challenge)
+			return
+		case errors.As(err, &exceeded):
+			response.JSON(c, http.StatusForbidden, http.StatusForbidden, exceeded.Error(), exceeded)
+			return
+		case errors.Is(err, model.ErrPaymentRiskDenied):
+			response.Forbidden(c, err.Error())
+			return
+		case errors.Is(err, model.ErrRiskChallengeInvalid), errors.Is(err, model.ErrRiskChallengeFailed):
+			response.BadRequest(c, err.Error())
+			return
+		}
 		logger.Error("创建支付失败", zap.Error(err))
 		response.Error(c, http.StatusInternalServerError, "创建支付失败")
 		return
@@ -148,6 +172,62 @@ func (h *Handler) GetPaymentByID(c *gin.Context) {
 	response.Success(c, "获取支付详情成功", resp)
 }
 
+// GetPaymentQRCode 获取扫码支付二维码图片
+// @Summary 获取支付二维码
+// @Description 将扫码支付的二维码内容渲染为PNG/SVG图片，默认直接返回图片，output=data_uri 时返回data URI；图片缓存至支付过期
+// @Tags 支付管理
+// @Produce png
+// @Produce image/svg+xml
+// @Produce json
+// @Param id path uint true "支付ID"
+// @Param format query string false "图片格式(png/svg)" default(png)
+// @Param size query int false "边长(像素)"
+// @Param output query string false "返回形式(image/data_uri)" default(image)
+// @Success 200 {file} file "二维码图片"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 404 {object} response.Response "支付记录不存在或无二维码"
+// @Failure 409 {object} response.Response "支付已完成或已过期"
+// @Router /api/v1/payments/{id}/qrcode [get]
+// @Security ApiKeyAuth
+func (h *Handler) GetPaymentQRCode(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "无效的支付ID")
+		return
+	}
+	format, err := qrcode.ParseFormat(c.Query("format"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	size, _ := strconv.Atoi(c.Query("size"))
+
+	image, err := h.paymentService.PaymentQRCode(uint(id), c.GetUint("user_id"), format, size)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrPaymentNotFound), errors.Is(err, model.ErrPaymentQRCodeUnavailable):
+			response.Error(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, model.ErrPaymentAlreadyPaid), errors.Is(err, model.ErrPaymentExpired):
+			response.Error(c, http.StatusConflict, err.Error())
+		default:
+			logger.Error("获取支付二维码失败", zap.Uint64("payment_id", id), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, "获取支付二维码失败")
+		}
+		return
+	}
+
+	if c.Query("output") == "data_uri" {
+		response.Success(c, "获取支付二维码成功", gin.H{
+			"format":   image.Format,
+			"data_uri": image.DataURI(),
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-transform")
+	c.Data(http.StatusOK, image.ContentType(), image.Data)
+}
+
 // ListPayments 获取支付列表
 // @Summary 获取支付列表
 // @Description 分页获取支付列表，支持多种筛选条件
@@ -302,6 +382,10 @@ func (h *Handler) RefundPayment(c *gin.Context) {
 			response.Error(c, http.StatusNotFound, "支付记录不存在")
 			return
 		}
+		if err == model.ErrRefundAmountExceeded {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
 		logger.Error("申请退款失败", zap.Error(err))
 		response.Error(c, http.StatusInternalServerError, "申请退款失败")
 		return
@@ -310,6 +394,39 @@ func (h *Handler) RefundPayment(c *gin.Context) {
 	response.Success(c, "申请退款成功", resp)
 }
 
+// QueryRefund 查询退款状态
+// @Summary 查询退款状态
+// @Description 根据退款单号查询退款状态，处理中的退款会向第三方同步最新结果
+// @Tags 支付管理
+// @Accept json
+// @Produce json
+// @Param refund_no path string true "退款单号"
+// @Success 200 {object} response.Response{data=model.PaymentRefundResponse} "查询成功"
+// @Failure 404 {object} response.Response "退款记录不存在"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /api/v1/payments/refund/{refund_no} [get]
+// @Security ApiKeyAuth
+func (h *Handler) QueryRefund(c *gin.Context) {
+	refundNo := c.Param("refund_no")
+	if refundNo == "" {
+		response.Error(c, http.StatusBadRequest, "退款单号不能为空")
+		return
+	}
+
+	resp, err := h.paymentService.QueryRefund(refundNo)
+	if err != nil {
+		if err == model.ErrRefundNotFound {
+			response.Error(c, http.StatusNotFound, "退款记录不存在")
+			return
+		}
+		logger.Error("查询退款状态失败", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "查询退款状态失败")
+		return
+	}
+
+	response.Success(c, "查询成功", resp)
+}
+
 // GetPaymentMethods 获取支付方式列表
 // @Summary 获取支付方式列表
 // @Description 获取所有可用的支付方式配置
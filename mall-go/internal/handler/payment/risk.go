@@ -0,0 +1,213 @@
+package payment
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/payment/risk"
+	"mall-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RiskHandler 支付风控管理处理器
+type RiskHandler struct {
+	engine *risk.Engine
+}
+
+// NewRiskHandler 创建支付风控管理处理器
+func NewRiskHandler(engine *risk.Engine) *RiskHandler {
+	return &RiskHandler{
+		engine: engine,
+	}
+}
+
+// ListRules 查询风控规则
+// @Summary 查询支付风控规则
+// @Tags 支付风控
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.PaymentRiskRule} "查询成功"
+// @Router /api/v1/admin/payments/risk/rules [get]
+// @Security ApiKeyAuth
+func (h *RiskHandler) ListRules(c *gin.Context) {
+	rules, err := h.engine.ListRules()
+	if err != nil {
+		logger.Error("查询风控规则失败", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "查询风控规则失败")
+		return
+	}
+
+	response.Success(c, "查询成功", rules)
+}
+
+// SaveRule 新增或更新风控规则
+// @Summary 维护支付风控规则
+// @Description 按规则编码新增或更新规则，保存后立即生效，无需重新部署
+// @Tags 支付风控
+// @Accept json
+// @Produce json
+// @Param request body model.PaymentRiskRuleRequest true "规则信息"
+// @Success 200 {object} response.Response{data=model.PaymentRiskRule} "保存成功"
+// @Router /api/v1/admin/payments/risk/rules [post]
+// @Security ApiKeyAuth
+func (h *RiskHandler) SaveRule(c *gin.Context) {
+	var req model.PaymentRiskRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	rule, err := h.engine.SaveRule(&req)
+	if err != nil {
+		logger.Error("保存风控规则失败", zap.String("code", req.Code), zap.Error(err))
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(c, "保存成功", rule)
+}
+
+// SetRuleEnabled 启用或停用风控规则
+// @Summary 启用或停用支付风控规则
+// @Tags 支付风控
+// @Accept json
+// @Produce json
+// @Param id path uint true "规则ID"
+// @Success 200 {object} response.Response "操作成功"
+// @Router /api/v1/admin/payments/risk/rules/{id}/enabled [put]
+// @Security ApiKeyAuth
+func (h *RiskHandler) SetRuleEnabled(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "规则ID格式错误")
+		return
+	}
+
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+
+	if err := h.engine.SetRuleEnabled(uint(id), req.Enabled); err != nil {
+		if errors.Is(err, model.ErrRiskRuleNotFound) {
+			response.Error(c, http.StatusNotFound, err.Error())
+			return
+		}
+		logger.Error("更新风控规则失败", zap.Uint64("id", id), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "更新风控规则失败")
+		return
+	}
+
+	response.Success(c, "操作成功", nil)
+}
+
+// ListBlacklist 查询风控黑名单
+// @Summary 查询支付风控黑名单
+// @Tags 支付风控
+// @Produce json
+// @Param list_type query string false "名单类型(user/ip/device/phone)"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/admin/payments/risk/blacklist [get]
+// @Security ApiKeyAuth
+func (h *RiskHandler) ListBlacklist(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	entries, total, err := h.engine.ListBlacklist(c.Query("list_type"), page, pageSize)
+	if err != nil {
+		logger.Error("查询黑名单失败", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "查询黑名单失败")
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", entries, total, page, pageSize)
+}
+
+// AddBlacklist 添加风控黑名单
+// @Summary 添加支付风控黑名单
+// @Tags 支付风控
+// @Accept json
+// @Produce json
+// @Param request body model.PaymentRiskBlacklistRequest true "黑名单信息"
+// @Success 200 {object} response.Response{data=model.PaymentRiskBlacklist} "添加成功"
+// @Router /api/v1/admin/payments/risk/blacklist [post]
+// @Security ApiKeyAuth
+func (h *RiskHandler) AddBlacklist(c *gin.Context) {
+	var req model.PaymentRiskBlacklistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	entry, err := h.engine.AddBlacklist(&req, c.GetUint("user_id"))
+	if err != nil {
+		logger.Error("添加黑名单失败", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "添加黑名单失败")
+		return
+	}
+
+	response.Success(c, "添加成功", entry)
+}
+
+// RemoveBlacklist 移除风控黑名单
+// @Summary 移除支付风控黑名单
+// @Tags 支付风控
+// @Produce json
+// @Param id path uint true "黑名单ID"
+// @Success 200 {object} response.Response "移除成功"
+// @Router /api/v1/admin/payments/risk/blacklist/{id} [delete]
+// @Security ApiKeyAuth
+func (h *RiskHandler) RemoveBlacklist(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "黑名单ID格式错误")
+		return
+	}
+
+	if err := h.engine.RemoveBlacklist(uint(id)); err != nil {
+		logger.Error("移除黑名单失败", zap.Uint64("id", id), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "移除黑名单失败")
+		return
+	}
+
+	response.Success(c, "移除成功", nil)
+}
+
+// ListDecisions 查询风控决策
+// @Summary 查询支付风控决策
+// @Description 查询风控评估记录及命中规则，可按处置动作、用户、订单筛选
+// @Tags 支付风控
+// @Produce json
+// @Param action query string false "处置动作(allow/challenge/deny)"
+// @Param user_id query uint false "用户ID"
+// @Param order_id query uint false "订单ID"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/admin/payments/risk/decisions [get]
+// @Security ApiKeyAuth
+func (h *RiskHandler) ListDecisions(c *gin.Context) {
+	var query model.PaymentRiskDecisionQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+
+	decisions, total, err := h.engine.ListDecisions(&query)
+	if err != nil {
+		logger.Error("查询风控决策失败", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "查询风控决策失败")
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", decisions, total, query.Page, query.PageSize)
+}
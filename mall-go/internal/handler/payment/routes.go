@@ -1,43 +1,144 @@
 package payment
 
 import (
+	"mall-go/internal/handler/middleware"
 	"mall-go/pkg/payment"
 	"mall-go/pkg/payment/alipay"
+	"mall-go/pkg/payment/dispute"
+	"mall-go/pkg/payment/stats"
 	"mall-go/pkg/payment/wechat"
+	"mall-go/pkg/upload"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-// RegisterRoutes 注册支付相关路由
+// RegisterRoutes 注册支付相关路由，包括用户支付与退款、渠道回调以及回调记录管理路由
 func RegisterRoutes(router *gin.RouterGroup, db *gorm.DB, paymentService *payment.Service, alipayClient *alipay.Client, wechatClient *wechat.Client) {
 	handler := NewHandler(db, paymentService)
-	// 创建回调处理器（使用nil占位符，需要完整实现）
+	// 创建回调处理器（简化版本，实际应用中应该通过依赖注入配置完整的依赖）
 	callbackHandler := NewCallbackHandler(db, paymentService, nil, nil, alipayClient, wechatClient)
 
+	// 支付方式列表（无需认证）
+	router.GET("/payments/methods", handler.GetPaymentMethods)
+
 	// 支付相关路由组
 	paymentGroup := router.Group("/payments")
+	paymentGroup.Use(middleware.AuthMiddleware())
+	{
+		paymentGroup.POST("", handler.CreatePayment)                // 创建支付
+		paymentGroup.GET("", handler.ListPayments)                  // 获取支付列表
+		paymentGroup.GET("/:id", handler.GetPaymentByID)            // 根据ID获取支付详情
+		paymentGroup.GET("/:id/qrcode", handler.GetPaymentQRCode)   // 获取扫码支付二维码图片
+		paymentGroup.GET("/query", handler.QueryPayment)            // 查询支付状态
+		paymentGroup.POST("/refund", handler.RefundPayment)         // 申请退款
+		paymentGroup.GET("/refund/:refund_no", handler.QueryRefund) // 查询退款状态
+	}
+
+	// 支付回调路由（无需认证）
+	callbackGroup := router.Group("/payments/callback")
+	{
+		callbackGroup.POST("/alipay", callbackHandler.AlipayCallback)                    // 支付宝回调
+		callbackGroup.POST("/wechat", callbackHandler.WechatCallback)                    // 微信支付回调
+		callbackGroup.POST("/wechat/refund", callbackHandler.WechatRefundCallback)       // 微信退款结果通知
+		callbackGroup.POST("/wechat/complaint", callbackHandler.WechatComplaintCallback) // 微信支付消费者投诉通知
+	}
+
+	// 支付回调记录与重放路由（管理员）
+	RegisterCallbackAdminRoutes(router, callbackHandler)
+}
+
+// RegisterCallbackAdminRoutes 注册支付回调记录与重放路由（管理员）
+func RegisterCallbackAdminRoutes(router *gin.RouterGroup, callbackHandler *CallbackHandler) {
+	callbackGroup := router.Group("/admin/payments/callbacks")
+	callbackGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		callbackGroup.GET("", callbackHandler.ListCallbacks)              // 查询回调通知，failed_only=true 为死信列表
+		callbackGroup.GET("/stats", callbackHandler.GetCallbackStats)     // 按渠道统计回调
+		callbackGroup.GET("/:id", callbackHandler.GetCallback)            // 回调详情及处理记录
+		callbackGroup.POST("/:id/replay", callbackHandler.ReplayCallback) // 重放回调
+	}
+}
+
+// RegisterAdminRoutes 注册支付管理路由，依赖支付服务中未启用的组件时不注册对应路由
+func RegisterAdminRoutes(router *gin.RouterGroup, db *gorm.DB, paymentService *payment.Service) {
+	// 支付争议管理路由
+	disputeService := dispute.NewService(db, dispute.DefaultOptions())
+	if fileManager := upload.GetGlobalFileManager(); fileManager != nil {
+		disputeService.SetUploader(fileManager)
+	}
+	disputeHandler := NewDisputeHandler(disputeService)
+	disputeGroup := router.Group("/admin/payments/disputes")
+	disputeGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
 	{
-		// 支付基础功能
-		paymentGroup.POST("", handler.CreatePayment)     // 创建支付
-		paymentGroup.GET("", handler.ListPayments)       // 获取支付列表
-		paymentGroup.GET("/query", handler.QueryPayment) // 查询支付状态
-		paymentGroup.GET("/:id", handler.GetPaymentByID) // 获取支付详情
+		disputeGroup.GET("", disputeHandler.ListDisputes)                 // 争议列表
+		disputeGroup.POST("", disputeHandler.CreateDispute)               // 登记争议
+		disputeGroup.GET("/:id", disputeHandler.GetDispute)               // 争议详情及证据
+		disputeGroup.POST("/:id/evidence", disputeHandler.UploadEvidence) // 上传证据
+		disputeGroup.POST("/:id/submit", disputeHandler.SubmitEvidence)   // 提交证据
+		disputeGroup.POST("/:id/resolve", disputeHandler.ResolveDispute)  // 登记裁决结果
+	}
+
+	// 支付统计路由，数据来自按日汇总表
+	statisticsHandler := NewStatisticsHandler(stats.NewService(db, stats.DefaultOptions()))
+	statisticsGroup := router.Group("/admin/payments/statistics")
+	statisticsGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		statisticsGroup.GET("", statisticsHandler.GetStatistics)      // 按日期范围查询统计
+		statisticsGroup.POST("/backfill", statisticsHandler.Backfill) // 补算历史汇总
+	}
 
-		// 退款功能
-		paymentGroup.POST("/refund", handler.RefundPayment) // 申请退款
+	if paymentService == nil {
+		return
+	}
+
+	// 支付指标路由
+	metricsHandler := NewMetricsHandler(paymentService.Router().Metrics())
+	paymentAdmin := router.Group("/admin/payments")
+	paymentAdmin.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		paymentAdmin.GET("/metrics", metricsHandler.GetMetrics) // 支付渠道与轮询补单指标
+	}
 
-		// 支付方式和配置
-		paymentGroup.GET("/methods", handler.GetPaymentMethods) // 获取支付方式列表
+	// 支付风控管理路由
+	if paymentService.RiskEngine() != nil {
+		riskHandler := NewRiskHandler(paymentService.RiskEngine())
+		riskGroup := router.Group("/admin/payments/risk")
+		riskGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+		{
+			riskGroup.GET("/rules", riskHandler.ListRules)                  // 风控规则列表
+			riskGroup.POST("/rules", riskHandler.SaveRule)                  // 新增或更新规则
+			riskGroup.PUT("/rules/:id/enabled", riskHandler.SetRuleEnabled) // 启用或停用规则
+			riskGroup.GET("/blacklist", riskHandler.ListBlacklist)          // 黑名单列表
+			riskGroup.POST("/blacklist", riskHandler.AddBlacklist)          // 添加黑名单
+			riskGroup.DELETE("/blacklist/:id", riskHandler.RemoveBlacklist) // 移除黑名单
+			riskGroup.GET("/decisions", riskHandler.ListDecisions)          // 风控决策记录
+		}
+	}
 
-		// 统计功能
-		paymentGroup.GET("/statistics", handler.GetPaymentStatistics) // 获取支付统计
+	// 用户累计支付限额管理路由
+	if paymentService.Limiter() != nil {
+		limitHandler := NewLimitHandler(paymentService.Limiter())
+		limitGroup := router.Group("/admin/payments/limits")
+		limitGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+		{
+			limitGroup.GET("/users/:user_id", limitHandler.GetUserLimits)            // 用户限额与使用情况
+			limitGroup.POST("/users/:user_id/overrides", limitHandler.SaveUserLimit) // 设置用户限额
+			limitGroup.DELETE("/overrides/:id", limitHandler.DeleteUserLimit)        // 删除用户限额
+		}
+	}
 
-		// 回调处理路由组
-		callbackGroup := paymentGroup.Group("/callback")
+	// 支付同步事件管理路由
+	if paymentService.SyncManager() != nil {
+		syncEventHandler := NewSyncEventHandler(paymentService.SyncManager())
+		syncEventGroup := router.Group("/admin/payments/sync-events")
+		syncEventGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
 		{
-			callbackGroup.POST("/alipay", callbackHandler.AlipayCallback) // 支付宝回调
-			callbackGroup.POST("/wechat", callbackHandler.WechatCallback) // 微信支付回调
+			syncEventGroup.GET("", syncEventHandler.ListEvents)                      // 查询同步事件
+			syncEventGroup.GET("/stats", syncEventHandler.GetStats)                  // 同步事件统计
+			syncEventGroup.GET("/:event_id", syncEventHandler.GetEvent)              // 同步事件详情
+			syncEventGroup.POST("/:event_id/replay", syncEventHandler.ReplayEvent)   // 重放事件
+			syncEventGroup.POST("/:event_id/discard", syncEventHandler.DiscardEvent) // 丢弃死信事件
 		}
 	}
 }
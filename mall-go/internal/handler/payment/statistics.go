@@ -0,0 +1,99 @@
+package payment
+
+import (
+	"errors"
+	"net/http"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/payment/stats"
+	"mall-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// StatisticsHandler 支付统计处理器，数据来自按日汇总表
+type StatisticsHandler struct {
+	service *stats.Service
+}
+
+// NewStatisticsHandler 创建支付统计处理器
+func NewStatisticsHandler(service *stats.Service) *StatisticsHandler {
+	return &StatisticsHandler{
+		service: service,
+	}
+}
+
+// GetStatistics 查询支付统计
+// @Summary 查询支付统计
+// @Description 按日期范围累加每日汇总，返回总体、各支付方式及按日/月/年分组的笔数、金额、成功率、退款金额与平均支付耗时；未指定日期时查询最近30天
+// @Tags 支付管理
+// @Produce json
+// @Param payment_method query string false "支付方式"
+// @Param start_date query string false "开始日期(YYYY-MM-DD)"
+// @Param end_date query string false "结束日期(YYYY-MM-DD)"
+// @Param group_by query string false "分组方式(day/month/year)" default(day)
+// @Success 200 {object} response.Response{data=model.PaymentStatisticsResponse} "查询成功"
+// @Router /api/v1/admin/payments/statistics [get]
+// @Security ApiKeyAuth
+func (h *StatisticsHandler) GetStatistics(c *gin.Context) {
+	var req model.PaymentStatisticsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	result, err := h.service.Statistics(&req)
+	if err != nil {
+		h.respondError(c, "查询支付统计失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", result)
+}
+
+// Backfill 补算历史支付统计
+// @Summary 补算支付统计
+// @Description 按支付与退款记录重算日期范围内每一天的汇总，用于历史数据初始化或数据修复后重算
+// @Tags 支付管理
+// @Accept json
+// @Produce json
+// @Param request body model.PaymentStatisticsBackfillRequest true "补算日期范围"
+// @Success 200 {object} response.Response{data=model.PaymentStatisticsBackfillResponse} "补算完成"
+// @Router /api/v1/admin/payments/statistics/backfill [post]
+// @Security ApiKeyAuth
+func (h *StatisticsHandler) Backfill(c *gin.Context) {
+	var req model.PaymentStatisticsBackfillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	result, err := h.service.Backfill(req.StartDate, req.EndDate)
+	if err != nil {
+		h.respondError(c, "补算支付统计失败", err)
+		return
+	}
+
+	logger.Info("补算支付统计",
+		zap.String("start_date", req.StartDate),
+		zap.String("end_date", req.EndDate),
+		zap.Int("days", result.Days),
+		zap.Uint("operator_id", c.GetUint("user_id")))
+	response.Success(c, "补算完成", result)
+}
+
+// respondError 按统计错误类型返回响应
+func (h *StatisticsHandler) respondError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrInvalidStatisticsDate),
+		errors.Is(err, model.ErrInvalidStatisticsRange),
+		errors.Is(err, model.ErrStatisticsRangeTooLarge),
+		errors.Is(err, model.ErrStatisticsFilterUnsupported):
+		response.Error(c, http.StatusBadRequest, err.Error())
+	default:
+		logger.Error(message, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, message)
+	}
+}
@@ -0,0 +1,155 @@
+package payment
+
+import (
+	"net/http"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/payment"
+	"mall-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SyncEventHandler 支付同步事件管理处理器
+type SyncEventHandler struct {
+	syncManager *payment.SyncManager
+}
+
+// NewSyncEventHandler 创建支付同步事件管理处理器
+func NewSyncEventHandler(syncManager *payment.SyncManager) *SyncEventHandler {
+	return &SyncEventHandler{
+		syncManager: syncManager,
+	}
+}
+
+// ListEvents 查询同步事件列表
+// @Summary 查询支付同步事件
+// @Description 按状态、事件类型、支付ID或订单ID查询支付同步事件，可用于查看死信事件
+// @Tags 支付管理
+// @Produce json
+// @Param status query string false "事件状态(pending/processing/succeeded/dead/discarded)"
+// @Param event_type query string false "事件类型"
+// @Param payment_id query uint false "支付ID"
+// @Param order_id query uint false "订单ID"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/admin/payments/sync-events [get]
+// @Security ApiKeyAuth
+func (h *SyncEventHandler) ListEvents(c *gin.Context) {
+	var query model.PaymentSyncEventQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+
+	events, total, err := h.syncManager.ListEvents(&query)
+	if err != nil {
+		logger.Error("查询同步事件失败", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "查询同步事件失败")
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", events, total, query.Page, query.PageSize)
+}
+
+// GetStats 同步事件状态统计
+// @Summary 支付同步事件统计
+// @Description 按状态统计支付同步事件数量
+// @Tags 支付管理
+// @Produce json
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/admin/payments/sync-events/stats [get]
+// @Security ApiKeyAuth
+func (h *SyncEventHandler) GetStats(c *gin.Context) {
+	counts, err := h.syncManager.CountByStatus()
+	if err != nil {
+		logger.Error("统计同步事件失败", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "统计同步事件失败")
+		return
+	}
+
+	response.Success(c, "查询成功", counts)
+}
+
+// GetEvent 查询同步事件详情
+// @Summary 查询支付同步事件详情
+// @Tags 支付管理
+// @Produce json
+// @Param event_id path string true "事件ID"
+// @Success 200 {object} response.Response{data=model.PaymentSyncEvent} "查询成功"
+// @Failure 404 {object} response.Response "事件不存在"
+// @Router /api/v1/admin/payments/sync-events/{event_id} [get]
+// @Security ApiKeyAuth
+func (h *SyncEventHandler) GetEvent(c *gin.Context) {
+	event, err := h.syncManager.GetEvent(c.Param("event_id"))
+	if err != nil {
+		h.handleError(c, err, "查询同步事件失败")
+		return
+	}
+
+	response.Success(c, "查询成功", event)
+}
+
+// ReplayEvent 重放同步事件
+// @Summary 重放支付同步事件
+// @Description 将死信或已丢弃的事件重新放入队列，尝试次数清零
+// @Tags 支付管理
+// @Produce json
+// @Param event_id path string true "事件ID"
+// @Success 200 {object} response.Response "重放成功"
+// @Failure 404 {object} response.Response "事件不存在"
+// @Failure 409 {object} response.Response "事件状态不允许重放"
+// @Router /api/v1/admin/payments/sync-events/{event_id}/replay [post]
+// @Security ApiKeyAuth
+func (h *SyncEventHandler) ReplayEvent(c *gin.Context) {
+	eventID := c.Param("event_id")
+	if err := h.syncManager.ReplayEvent(eventID); err != nil {
+		h.handleError(c, err, "重放同步事件失败")
+		return
+	}
+
+	logger.Info("管理员重放同步事件",
+		zap.String("event_id", eventID),
+		zap.Uint("operator_id", c.GetUint("user_id")))
+	response.Success(c, "重放成功", nil)
+}
+
+// DiscardEvent 丢弃同步事件
+// @Summary 丢弃支付同步死信事件
+// @Description 确认死信事件无需处理后将其丢弃，丢弃后仍可重放
+// @Tags 支付管理
+// @Produce json
+// @Param event_id path string true "事件ID"
+// @Success 200 {object} response.Response "丢弃成功"
+// @Failure 404 {object} response.Response "事件不存在"
+// @Failure 409 {object} response.Response "事件状态不允许丢弃"
+// @Router /api/v1/admin/payments/sync-events/{event_id}/discard [post]
+// @Security ApiKeyAuth
+func (h *SyncEventHandler) DiscardEvent(c *gin.Context) {
+	eventID := c.Param("event_id")
+	if err := h.syncManager.DiscardEvent(eventID); err != nil {
+		h.handleError(c, err, "丢弃同步事件失败")
+		return
+	}
+
+	logger.Info("管理员丢弃同步事件",
+		zap.String("event_id", eventID),
+		zap.Uint("operator_id", c.GetUint("user_id")))
+	response.Success(c, "丢弃成功", nil)
+}
+
+// handleError 统一处理同步事件操作错误
+func (h *SyncEventHandler) handleError(c *gin.Context, err error, message string) {
+	switch err {
+	case model.ErrSyncEventNotFound:
+		response.Error(c, http.StatusNotFound, err.Error())
+	case model.ErrSyncEventState:
+		response.Error(c, http.StatusConflict, err.Error())
+	default:
+		logger.Error(message, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, message)
+	}
+}
@@ -0,0 +1,330 @@
+package pricelist
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/pricelist"
+	"mall-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Handler 客户分组价处理器
+type Handler struct {
+	service *pricelist.Service
+}
+
+// NewHandler 创建客户分组价处理器
+func NewHandler(service *pricelist.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ListGroups 查询客户分组
+// @Summary 查询客户分组
+// @Tags 客户分组价管理
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.CustomerGroup} "查询成功"
+// @Router /api/v1/admin/customer-groups [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListGroups(c *gin.Context) {
+	groups, err := h.service.ListGroups()
+	if err != nil {
+		h.respondError(c, "查询客户分组失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", groups)
+}
+
+// CreateGroup 创建客户分组
+// @Summary 创建客户分组
+// @Description 创建批发商、企业客户等分组，分组成员按分组的价目表定价
+// @Tags 客户分组价管理
+// @Accept json
+// @Produce json
+// @Param request body model.CustomerGroupRequest true "客户分组"
+// @Success 200 {object} response.Response{data=model.CustomerGroup} "创建成功"
+// @Router /api/v1/admin/customer-groups [post]
+// @Security ApiKeyAuth
+func (h *Handler) CreateGroup(c *gin.Context) {
+	var req model.CustomerGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	group, err := h.service.CreateGroup(&req, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "创建客户分组失败", err)
+		return
+	}
+
+	response.Success(c, "创建成功", group)
+}
+
+// UpdateGroup 更新客户分组
+// @Summary 更新客户分组
+// @Description 停用分组后分组的价目表不再生效
+// @Tags 客户分组价管理
+// @Accept json
+// @Produce json
+// @Param id path int true "分组ID"
+// @Param request body model.CustomerGroupRequest true "客户分组"
+// @Success 200 {object} response.Response{data=model.CustomerGroup} "更新成功"
+// @Router /api/v1/admin/customer-groups/{id} [put]
+// @Security ApiKeyAuth
+func (h *Handler) UpdateGroup(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "分组ID格式错误")
+	if !ok {
+		return
+	}
+	var req model.CustomerGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	group, err := h.service.UpdateGroup(id, &req, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "更新客户分组失败", err)
+		return
+	}
+
+	response.Success(c, "更新成功", group)
+}
+
+// ListMembers 查询分组成员
+// @Summary 查询分组成员
+// @Tags 客户分组价管理
+// @Produce json
+// @Param id path int true "分组ID"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/admin/customer-groups/{id}/members [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListMembers(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "分组ID格式错误")
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	members, total, err := h.service.ListMembers(id, page, pageSize)
+	if err != nil {
+		h.respondError(c, "查询分组成员失败", err)
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", members, total, page, pageSize)
+}
+
+// AddMembers 添加分组成员
+// @Summary 添加分组成员
+// @Description 已在分组中的用户跳过，返回新增人数
+// @Tags 客户分组价管理
+// @Accept json
+// @Produce json
+// @Param id path int true "分组ID"
+// @Param request body model.CustomerGroupMembersRequest true "用户ID"
+// @Success 200 {object} response.Response "添加成功"
+// @Router /api/v1/admin/customer-groups/{id}/members [post]
+// @Security ApiKeyAuth
+func (h *Handler) AddMembers(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "分组ID格式错误")
+	if !ok {
+		return
+	}
+	var req model.CustomerGroupMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	added, err := h.service.AddMembers(id, req.UserIDs, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "添加分组成员失败", err)
+		return
+	}
+
+	response.Success(c, "添加成功", gin.H{"added": added})
+}
+
+// RemoveMember 移出分组成员
+// @Summary 移出分组成员
+// @Tags 客户分组价管理
+// @Produce json
+// @Param id path int true "分组ID"
+// @Param user_id path int true "用户ID"
+// @Success 200 {object} response.Response "移出成功"
+// @Router /api/v1/admin/customer-groups/{id}/members/{user_id} [delete]
+// @Security ApiKeyAuth
+func (h *Handler) RemoveMember(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "分组ID格式错误")
+	if !ok {
+		return
+	}
+	userID, ok := response.ParseID(c, "user_id", "用户ID格式错误")
+	if !ok {
+		return
+	}
+
+	if err := h.service.RemoveMember(id, userID, c.GetUint("user_id")); err != nil {
+		h.respondError(c, "移出分组成员失败", err)
+		return
+	}
+
+	response.Success(c, "移出成功", nil)
+}
+
+// ListPriceLists 查询价目表
+// @Summary 查询价目表
+// @Tags 客户分组价管理
+// @Produce json
+// @Param group_id query int false "分组ID"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/admin/price-lists [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListPriceLists(c *gin.Context) {
+	var query model.PriceListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	lists, total, err := h.service.ListPriceLists(&query)
+	if err != nil {
+		h.respondError(c, "查询价目表失败", err)
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", lists, total, query.Page, query.PageSize)
+}
+
+// GetPriceList 查询价目表详情
+// @Summary 查询价目表详情
+// @Tags 客户分组价管理
+// @Produce json
+// @Param id path int true "价目表ID"
+// @Success 200 {object} response.Response{data=model.PriceList} "查询成功"
+// @Router /api/v1/admin/price-lists/{id} [get]
+// @Security ApiKeyAuth
+func (h *Handler) GetPriceList(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "价目表ID格式错误")
+	if !ok {
+		return
+	}
+
+	list, err := h.service.GetPriceList(id)
+	if err != nil {
+		h.respondError(c, "查询价目表失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", list)
+}
+
+// CreatePriceList 创建价目表
+// @Summary 创建价目表
+// @Description 按商品或SKU配置固定价或百分比调整，同一商品可按起订数量分档，可设置有效期和币种
+// @Tags 客户分组价管理
+// @Accept json
+// @Produce json
+// @Param request body model.PriceListRequest true "价目表"
+// @Success 200 {object} response.Response{data=model.PriceList} "创建成功"
+// @Router /api/v1/admin/price-lists [post]
+// @Security ApiKeyAuth
+func (h *Handler) CreatePriceList(c *gin.Context) {
+	var req model.PriceListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	list, err := h.service.CreatePriceList(&req, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "创建价目表失败", err)
+		return
+	}
+
+	response.Success(c, "创建成功", list)
+}
+
+// UpdatePriceList 更新价目表
+// @Summary 更新价目表
+// @Description 条目整体替换
+// @Tags 客户分组价管理
+// @Accept json
+// @Produce json
+// @Param id path int true "价目表ID"
+// @Param request body model.PriceListRequest true "价目表"
+// @Success 200 {object} response.Response{data=model.PriceList} "更新成功"
+// @Router /api/v1/admin/price-lists/{id} [put]
+// @Security ApiKeyAuth
+func (h *Handler) UpdatePriceList(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "价目表ID格式错误")
+	if !ok {
+		return
+	}
+	var req model.PriceListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	list, err := h.service.UpdatePriceList(id, &req, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "更新价目表失败", err)
+		return
+	}
+
+	response.Success(c, "更新成功", list)
+}
+
+// DeletePriceList 删除价目表
+// @Summary 删除价目表
+// @Tags 客户分组价管理
+// @Produce json
+// @Param id path int true "价目表ID"
+// @Success 200 {object} response.Response "删除成功"
+// @Router /api/v1/admin/price-lists/{id} [delete]
+// @Security ApiKeyAuth
+func (h *Handler) DeletePriceList(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "价目表ID格式错误")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeletePriceList(id, c.GetUint("user_id")); err != nil {
+		h.respondError(c, "删除价目表失败", err)
+		return
+	}
+
+	response.Success(c, "删除成功", nil)
+}
+
+// respondError 按客户分组价错误类型返回响应
+func (h *Handler) respondError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrCustomerGroupNotFound),
+		errors.Is(err, model.ErrPriceListNotFound):
+		response.Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, model.ErrCustomerGroupDuplicate):
+		response.Error(c, http.StatusConflict, err.Error())
+	case errors.Is(err, model.ErrInvalidPriceList):
+		response.Error(c, http.StatusBadRequest, err.Error())
+	default:
+		logger.Error(message, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, message)
+	}
+}
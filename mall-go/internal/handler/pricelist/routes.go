@@ -0,0 +1,29 @@
+package pricelist
+
+import (
+	"mall-go/internal/handler/middleware"
+	"mall-go/pkg/pricelist"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 注册客户分组与分组价目表管理路由
+func RegisterRoutes(router *gin.RouterGroup, service *pricelist.Service) {
+	handler := NewHandler(service)
+
+	adminGroup := router.Group("/admin")
+	adminGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		adminGroup.GET("/customer-groups", handler.ListGroups)                           // 客户分组列表
+		adminGroup.POST("/customer-groups", handler.CreateGroup)                         // 创建客户分组
+		adminGroup.PUT("/customer-groups/:id", handler.UpdateGroup)                      // 更新客户分组
+		adminGroup.GET("/customer-groups/:id/members", handler.ListMembers)              // 分组成员列表
+		adminGroup.POST("/customer-groups/:id/members", handler.AddMembers)              // 添加分组成员
+		adminGroup.DELETE("/customer-groups/:id/members/:user_id", handler.RemoveMember) // 移出分组成员
+		adminGroup.GET("/price-lists", handler.ListPriceLists)                           // 价目表列表
+		adminGroup.POST("/price-lists", handler.CreatePriceList)                         // 创建价目表
+		adminGroup.GET("/price-lists/:id", handler.GetPriceList)                         // 价目表详情
+		adminGroup.PUT("/price-lists/:id", handler.UpdatePriceList)                      // 更新价目表
+		adminGroup.DELETE("/price-lists/:id", handler.DeletePriceList)                   // 删除价目表
+	}
+}
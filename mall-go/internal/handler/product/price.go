@@ -0,0 +1,172 @@
+package product
+
+import (
+	"errors"
+	"strconv"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// CreatePriceSchedule 创建定时调价
+// @Summary 创建定时调价
+// @Description 预先配置商品或SKU的调价，不填结束时间为永久调价，填写时为限时价，结束后恢复原价
+// @Tags 商品价格管理
+// @Accept json
+// @Produce json
+// @Param request body model.PriceScheduleRequest true "定时调价"
+// @Success 200 {object} model.PriceSchedule
+// @Failure 400 {object} map[string]interface{}
+// @Router /admin/price-schedules [post]
+// @Security ApiKeyAuth
+func (h *Handler) CreatePriceSchedule(c *gin.Context) {
+	var req model.PriceScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+
+	schedule, err := h.priceService.Schedule(&req, c.GetUint("user_id"))
+	if err != nil {
+		h.respondPriceError(c, "创建定时调价失败", err)
+		return
+	}
+
+	response.SuccessWithData(c, schedule)
+}
+
+// ListPriceSchedules 查询定时调价
+// @Summary 查询定时调价
+// @Tags 商品价格管理
+// @Produce json
+// @Param product_id query int false "商品ID"
+// @Param status query string false "状态(pending/active/completed/cancelled)"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/price-schedules [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListPriceSchedules(c *gin.Context) {
+	var query model.PriceScheduleQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.BadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+
+	schedules, total, err := h.priceService.List(&query)
+	if err != nil {
+		h.respondPriceError(c, "查询定时调价失败", err)
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", schedules, total, query.Page, query.PageSize)
+}
+
+// CancelPriceSchedule 取消定时调价
+// @Summary 取消定时调价
+// @Description 取消待生效的调价；生效中的限时价立即结束并恢复原价
+// @Tags 商品价格管理
+// @Produce json
+// @Param id path int true "定时调价ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /admin/price-schedules/{id} [delete]
+// @Security ApiKeyAuth
+func (h *Handler) CancelPriceSchedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的定时调价ID")
+		return
+	}
+
+	if err := h.priceService.Cancel(uint(id), c.GetUint("user_id")); err != nil {
+		h.respondPriceError(c, "取消定时调价失败", err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "取消成功")
+}
+
+// GetPriceHistory 查询价格变更记录
+// @Summary 查询价格变更记录
+// @Tags 商品价格管理
+// @Produce json
+// @Param id path int true "商品ID"
+// @Param sku_id query int false "SKU ID，不填为商品价格"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/products/{id}/price-history [get]
+// @Security ApiKeyAuth
+func (h *Handler) GetPriceHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的商品ID")
+		return
+	}
+	var query model.PriceHistoryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.BadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+
+	histories, total, err := h.priceService.History(uint(id), &query)
+	if err != nil {
+		h.respondPriceError(c, "查询价格变更记录失败", err)
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", histories, total, query.Page, query.PageSize)
+}
+
+// GetLowestPrice 获取当前价格生效前30天最低价
+// @Summary 获取当前价格生效前30天最低价
+// @Description 按价格变更记录计算当前价格生效前30天内的最低价格，限时价期间不含折扣价本身，用于划线原价等价格宣传
+// @Tags 商品管理
+// @Produce json
+// @Param id path int true "商品ID"
+// @Param sku_id query int false "SKU ID，不填为商品价格"
+// @Success 200 {object} model.LowestPriceView
+// @Failure 404 {object} map[string]interface{}
+// @Router /products/{id}/lowest-price [get]
+func (h *Handler) GetLowestPrice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的商品ID")
+		return
+	}
+	skuID, err := strconv.ParseUint(c.DefaultQuery("sku_id", "0"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的SKU ID")
+		return
+	}
+
+	view, err := h.priceService.LowestPrice(uint(id), uint(skuID))
+	if err != nil {
+		h.respondPriceError(c, "查询最低价失败", err)
+		return
+	}
+
+	response.SuccessWithData(c, view)
+}
+
+// respondPriceError 按定时调价错误类型返回响应
+func (h *Handler) respondPriceError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrPriceScheduleNotFound),
+		errors.Is(err, model.ErrPriceTargetNotFound):
+		response.NotFound(c, err.Error())
+	case errors.Is(err, model.ErrInvalidPriceSchedule):
+		response.BadRequest(c, err.Error())
+	case errors.Is(err, model.ErrPriceScheduleOverlap),
+		errors.Is(err, model.ErrPriceScheduleFinished):
+		response.Conflict(c, err.Error())
+	default:
+		logger.Error(message, zap.Error(err))
+		response.ServerError(c, message)
+	}
+}
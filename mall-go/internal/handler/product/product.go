@@ -1,11 +1,17 @@
 package product
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"mall-go/internal/model"
+	"mall-go/pkg/bundle"
+	"mall-go/pkg/cart"
+	"mall-go/pkg/currency"
 	"mall-go/pkg/logger"
+	"mall-go/pkg/pricelist"
 	"mall-go/pkg/product"
 	"mall-go/pkg/response"
 
@@ -23,6 +29,10 @@ type Handler struct {
 	inventoryService *product.InventoryService
 	imageService     *product.ImageService
 	searchService    *product.SearchService
+	currencyService  *currency.Service
+	bundleService    *bundle.Service
+	priceService     *product.PriceService
+	priceListService *pricelist.Service
 }
 
 func NewHandler(db *gorm.DB) *Handler {
@@ -34,9 +44,25 @@ func NewHandler(db *gorm.DB) *Handler {
 		inventoryService: product.NewInventoryService(db),
 		imageService:     product.NewImageService(db, nil), // TODO: 注入文件管理器
 		searchService:    product.NewSearchService(db),
+		currencyService:  currency.NewService(db),
+		bundleService:    bundle.NewService(db, cart.NewCartService(db), bundle.DefaultOptions()),
+		priceService:     product.NewPriceService(db, product.DefaultPriceOptions()),
+		priceListService: pricelist.NewService(db),
 	}
 }
 
+// SetPriceService 替换定时调价服务，与定时调价任务共用已注册监听和缓存失效的实例
+func (h *Handler) SetPriceService(priceService *product.PriceService) {
+	h.priceService = priceService
+}
+
+// AddChangeListener 监听商品、SKU价格和库存变化，如收藏的降价、到货提醒
+func (h *Handler) AddChangeListener(listener product.ChangeListener) {
+	h.productService.AddChangeListener(listener)
+	h.skuService.AddChangeListener(listener)
+	h.inventoryService.AddChangeListener(listener)
+}
+
 // List 获取商品列表
 // @Summary 获取商品列表
 // @Description 分页获取商品列表，支持分类筛选和关键词搜索
@@ -48,6 +74,7 @@ func NewHandler(db *gorm.DB) *Handler {
 // @Param category_id query int false "分类ID"
 // @Param keyword query string false "搜索关键词"
 // @Param status query string false "商品状态"
+// @Param currency query string false "展示币种"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
 // @Router /products [get]
@@ -97,6 +124,23 @@ func (h *Handler) List(c *gin.Context) {
 		return
 	}
 
+	prices, err := h.customerPrices(c, products)
+	if err != nil {
+		logger.Error("查询客户分组价失败", zap.Error(err))
+		response.ServerError(c, "查询商品列表失败")
+		return
+	}
+
+	if code := currency.Normalize(req.Currency); code != model.BaseCurrency || len(prices) > 0 {
+		views, err := h.toPriceViews(products, code, prices)
+		if err != nil {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		response.SuccessWithPage(c, "查询成功", views, total, req.Page, req.PageSize)
+		return
+	}
+
 	response.SuccessWithPage(c, "查询成功", products, total, req.Page, req.PageSize)
 }
 
@@ -107,6 +151,7 @@ func (h *Handler) List(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "商品ID"
+// @Param currency query string false "展示币种"
 // @Success 200 {object} model.Product
 // @Failure 404 {object} map[string]interface{}
 // @Router /products/{id} [get]
@@ -128,9 +173,140 @@ func (h *Handler) Get(c *gin.Context) {
 		return
 	}
 
+	price, err := h.customerPriceWithTiers(c, &product)
+	if err != nil {
+		logger.Error("查询客户分组价失败", zap.Error(err))
+		response.ServerError(c, "查询商品详情失败")
+		return
+	}
+
+	if code := currency.Normalize(c.Query("currency")); code != model.BaseCurrency || price != nil {
+		views, err := h.toPriceViews([]model.Product{product}, code, map[uint]*model.CustomerPrice{product.ID: price})
+		if err != nil {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		response.SuccessWithData(c, views[0])
+		return
+	}
+
 	response.SuccessWithData(c, product)
 }
 
+// GetBundle 获取商品搭配推荐
+// @Summary 获取商品搭配推荐
+// @Description 获取"经常一起买"的搭配商品及一起购买的价格，价格包含打包价等促销优惠
+// @Tags 商品管理
+// @Accept json
+// @Produce json
+// @Param id path int true "商品ID"
+// @Success 200 {object} bundle.Suggestion
+// @Failure 404 {object} map[string]interface{}
+// @Router /products/{id}/bundle [get]
+func (h *Handler) GetBundle(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的商品ID")
+		return
+	}
+
+	suggestion, err := h.bundleService.Suggest(uint(id))
+	if err != nil {
+		if errors.Is(err, model.ErrBundleNotFound) {
+			response.NotFound(c, err.Error())
+			return
+		}
+		logger.Error("查询商品搭配失败", zap.Error(err))
+		response.ServerError(c, "查询商品搭配失败")
+		return
+	}
+
+	response.SuccessWithData(c, suggestion)
+}
+
+// toPriceViews 按请求时生效的汇率换算商品展示价格，并附上商品的客户分组价
+func (h *Handler) toPriceViews(products []model.Product, code string, prices map[uint]*model.CustomerPrice) ([]model.ProductPriceView, error) {
+	now := time.Now()
+	views := make([]model.ProductPriceView, 0, len(products))
+	for _, p := range products {
+		view := model.ProductPriceView{
+			Product:            p,
+			DisplayCurrency:    model.BaseCurrency,
+			DisplayPrice:       p.Price,
+			DisplayOriginPrice: p.OriginPrice,
+			ExchangeRate:       decimal.NewFromInt(1),
+			CustomerPrice:      prices[p.ID],
+		}
+		if code != model.BaseCurrency {
+			price, err := h.currencyService.Convert(p.Price, code, now)
+			if err != nil {
+				return nil, err
+			}
+			originPrice, err := h.currencyService.Convert(p.OriginPrice, code, now)
+			if err != nil {
+				return nil, err
+			}
+			view.DisplayCurrency = price.Currency
+			view.DisplayPrice = price.Amount
+			view.DisplayOriginPrice = originPrice.Amount
+			view.ExchangeRate = price.Rate
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// customerPrices 登录用户的商品价格替换为所属分组价目表的单件价格，返回按商品ID的分组价
+func (h *Handler) customerPrices(c *gin.Context, products []model.Product) (map[uint]*model.CustomerPrice, error) {
+	userID := privatePrices(c)
+	if userID == 0 || len(products) == 0 {
+		return nil, nil
+	}
+
+	lines := make([]pricelist.Line, len(products))
+	for i, p := range products {
+		lines[i] = pricelist.Line{ProductID: p.ID, Quantity: 1, BasePrice: p.Price}
+	}
+	resolved, err := h.priceListService.Resolve(userID, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[uint]*model.CustomerPrice)
+	for i, price := range resolved {
+		if price != nil {
+			products[i].Price = price.Price
+			prices[products[i].ID] = price
+		}
+	}
+	return prices, nil
+}
+
+// customerPriceWithTiers 登录用户的商品价格替换为所属分组的单件价格，并返回各数量档位的价格
+func (h *Handler) customerPriceWithTiers(c *gin.Context, p *model.Product) (*model.CustomerPrice, error) {
+	userID := privatePrices(c)
+	if userID == 0 {
+		return nil, nil
+	}
+
+	price, err := h.priceListService.PriceWithTiers(userID, p.ID, 0, p.Price)
+	if err != nil || price == nil {
+		return nil, err
+	}
+	p.Price = price.Price
+	return price, nil
+}
+
+// privatePrices 商品价格随登录用户的客户分组变化，响应按认证信息区分且登录用户的响应不进入共享缓存，返回登录用户ID
+func privatePrices(c *gin.Context) uint {
+	c.Header("Vary", "Authorization")
+	userID := c.GetUint("user_id")
+	if userID > 0 {
+		c.Header("Cache-Control", "private")
+	}
+	return userID
+}
+
 // Create 创建商品
 // @Summary 创建商品
 // @Description 创建新商品（需要管理员权限）
@@ -226,6 +402,7 @@ func (h *Handler) Update(c *gin.Context) {
 	}
 
 	// 更新商品信息
+	beforePrice := product.Price
 	product.Name = req.Name
 	product.Description = req.Description
 	product.Price = decimal.NewFromFloat(req.Price)
@@ -237,6 +414,9 @@ func (h *Handler) Update(c *gin.Context) {
 		response.ServerError(c, "更新商品失败")
 		return
 	}
+	if err := h.priceService.RecordChange(product.ID, 0, beforePrice, product.Price, c.GetUint("user_id")); err != nil {
+		logger.Error("记录价格变更失败", zap.Uint("product_id", product.ID), zap.Error(err))
+	}
 
 	// 更新商品图片
 	if len(req.Images) > 0 {
@@ -340,6 +520,7 @@ func (h *Handler) UpdateProductEnhanced(c *gin.Context) {
 		return
 	}
 
+	req.OperatorID = c.GetUint("user_id")
 	productInfo, err := h.productService.UpdateProduct(uint(id), &req)
 	if err != nil {
 		response.Error(c, http.StatusBadRequest, err.Error())
@@ -0,0 +1,19 @@
+package product
+
+import (
+	"mall-go/internal/handler/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterPriceRoutes 注册定时调价与价格变更记录管理路由
+func RegisterPriceRoutes(router *gin.RouterGroup, handler *Handler) {
+	adminGroup := router.Group("/admin")
+	adminGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		adminGroup.GET("/price-schedules", handler.ListPriceSchedules)         // 定时调价列表
+		adminGroup.POST("/price-schedules", handler.CreatePriceSchedule)       // 创建定时调价
+		adminGroup.DELETE("/price-schedules/:id", handler.CancelPriceSchedule) // 取消定时调价
+		adminGroup.GET("/products/:id/price-history", handler.GetPriceHistory) // 价格变更记录
+	}
+}
@@ -0,0 +1,226 @@
+package promotion
+
+import (
+	"errors"
+	"net/http"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/promotion"
+	"mall-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Handler 促销处理器
+type Handler struct {
+	service *promotion.Service
+}
+
+// NewHandler 创建促销处理器
+func NewHandler(service *promotion.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ListRunning 查询进行中的促销
+// @Summary 查询进行中的促销
+// @Tags 促销
+// @Produce json
+// @Param type query string false "促销类型"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/promotions [get]
+func (h *Handler) ListRunning(c *gin.Context) {
+	var query model.PromotionQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+	query.Status = ""
+	query.Running = true
+
+	promotions, total, err := h.service.List(&query)
+	if err != nil {
+		h.respondError(c, "查询促销失败", err)
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", promotions, total, query.Page, query.PageSize)
+}
+
+// Create 创建促销
+// @Summary 创建促销
+// @Description 配置满减、折扣、N件M元、第二件半价、打包价、满赠、限时价等促销规则
+// @Tags 促销管理
+// @Accept json
+// @Produce json
+// @Param request body model.PromotionRequest true "促销规则"
+// @Success 200 {object} response.Response{data=model.Promotion} "创建成功"
+// @Router /api/v1/admin/promotions [post]
+// @Security ApiKeyAuth
+func (h *Handler) Create(c *gin.Context) {
+	var req model.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	promotion, err := h.service.Create(&req, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "创建促销失败", err)
+		return
+	}
+
+	response.Success(c, "创建成功", promotion)
+}
+
+// Update 更新促销
+// @Summary 更新促销
+// @Tags 促销管理
+// @Accept json
+// @Produce json
+// @Param id path int true "促销ID"
+// @Param request body model.PromotionRequest true "促销规则"
+// @Success 200 {object} response.Response{data=model.Promotion} "更新成功"
+// @Router /api/v1/admin/promotions/{id} [put]
+// @Security ApiKeyAuth
+func (h *Handler) Update(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "促销ID格式错误")
+	if !ok {
+		return
+	}
+	var req model.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	promotion, err := h.service.Update(id, &req, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "更新促销失败", err)
+		return
+	}
+
+	response.Success(c, "更新成功", promotion)
+}
+
+// Get 查询促销详情
+// @Summary 查询促销详情
+// @Tags 促销管理
+// @Produce json
+// @Param id path int true "促销ID"
+// @Success 200 {object} response.Response{data=model.Promotion} "查询成功"
+// @Router /api/v1/admin/promotions/{id} [get]
+// @Security ApiKeyAuth
+func (h *Handler) Get(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "促销ID格式错误")
+	if !ok {
+		return
+	}
+
+	promotion, err := h.service.Get(id)
+	if err != nil {
+		h.respondError(c, "查询促销失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", promotion)
+}
+
+// List 查询促销
+// @Summary 查询促销
+// @Tags 促销管理
+// @Produce json
+// @Param type query string false "促销类型"
+// @Param status query string false "状态(active/inactive)"
+// @Param running query bool false "仅查询进行中的促销"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/admin/promotions [get]
+// @Security ApiKeyAuth
+func (h *Handler) List(c *gin.Context) {
+	var query model.PromotionQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	promotions, total, err := h.service.List(&query)
+	if err != nil {
+		h.respondError(c, "查询促销失败", err)
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", promotions, total, query.Page, query.PageSize)
+}
+
+// UpdateStatus 启用或停用促销
+// @Summary 启用或停用促销
+// @Tags 促销管理
+// @Accept json
+// @Produce json
+// @Param id path int true "促销ID"
+// @Param request body model.UpdatePromotionStatusRequest true "状态"
+// @Success 200 {object} response.Response{data=model.Promotion} "更新成功"
+// @Router /api/v1/admin/promotions/{id}/status [put]
+// @Security ApiKeyAuth
+func (h *Handler) UpdateStatus(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "促销ID格式错误")
+	if !ok {
+		return
+	}
+	var req model.UpdatePromotionStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	promotion, err := h.service.SetStatus(id, req.Status, c.GetUint("user_id"))
+	if err != nil {
+		h.respondError(c, "更新促销状态失败", err)
+		return
+	}
+
+	response.Success(c, "更新成功", promotion)
+}
+
+// Delete 删除促销
+// @Summary 删除促销
+// @Tags 促销管理
+// @Produce json
+// @Param id path int true "促销ID"
+// @Success 200 {object} response.Response "删除成功"
+// @Router /api/v1/admin/promotions/{id} [delete]
+// @Security ApiKeyAuth
+func (h *Handler) Delete(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "促销ID格式错误")
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(id, c.GetUint("user_id")); err != nil {
+		h.respondError(c, "删除促销失败", err)
+		return
+	}
+
+	response.Success(c, "删除成功", nil)
+}
+
+// respondError 按促销错误类型返回响应
+func (h *Handler) respondError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrPromotionNotFound):
+		response.Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, model.ErrInvalidPromotion),
+		errors.Is(err, model.ErrInvalidPromotionTime):
+		response.Error(c, http.StatusBadRequest, err.Error())
+	default:
+		logger.Error(message, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, message)
+	}
+}
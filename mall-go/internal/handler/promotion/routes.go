@@ -0,0 +1,26 @@
+package promotion
+
+import (
+	"mall-go/internal/handler/middleware"
+	"mall-go/pkg/promotion"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 注册促销路由
+func RegisterRoutes(router *gin.RouterGroup, service *promotion.Service) {
+	handler := NewHandler(service)
+
+	router.GET("/promotions", handler.ListRunning) // 进行中的促销
+
+	adminGroup := router.Group("/admin/promotions")
+	adminGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		adminGroup.GET("", handler.List)                    // 促销列表
+		adminGroup.POST("", handler.Create)                 // 创建促销
+		adminGroup.GET("/:id", handler.Get)                 // 促销详情
+		adminGroup.PUT("/:id", handler.Update)              // 更新促销
+		adminGroup.PUT("/:id/status", handler.UpdateStatus) // 启用或停用促销
+		adminGroup.DELETE("/:id", handler.Delete)           // 删除促销
+	}
+}
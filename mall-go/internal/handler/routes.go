@@ -3,14 +3,36 @@ package handler
 import (
 	"mall-go/internal/handler/address"
 	"mall-go/internal/handler/cart"
+	"mall-go/internal/handler/currency"
+	"mall-go/internal/handler/favorite"
 	"mall-go/internal/handler/file"
+	"mall-go/internal/handler/giftcard"
+	"mall-go/internal/handler/member"
 	"mall-go/internal/handler/middleware"
 	"mall-go/internal/handler/order"
 	"mall-go/internal/handler/payment"
+	"mall-go/internal/handler/pricelist"
 	"mall-go/internal/handler/product"
+	"mall-go/internal/handler/promotion"
+	"mall-go/internal/handler/settlement"
+	"mall-go/internal/handler/subscription"
 	"mall-go/internal/handler/user"
 	"mall-go/internal/model"
+	"mall-go/pkg/cache"
+	cartpkg "mall-go/pkg/cart"
+	currencypkg "mall-go/pkg/currency"
+	favoritepkg "mall-go/pkg/favorite"
+	giftcardpkg "mall-go/pkg/giftcard"
+	"mall-go/pkg/inventory"
+	memberpkg "mall-go/pkg/member"
+	orderpkg "mall-go/pkg/order"
 	paymentpkg "mall-go/pkg/payment"
+	"mall-go/pkg/payment/wechat"
+	pricelistpkg "mall-go/pkg/pricelist"
+	productpkg "mall-go/pkg/product"
+	promotionpkg "mall-go/pkg/promotion"
+	settlementpkg "mall-go/pkg/settlement"
+	subscriptionpkg "mall-go/pkg/subscription"
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
@@ -41,6 +63,7 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB, rdb *redis.Client, paymentServic
 				"POST /api/v1/cart/add - 添加商品到购物车",
 				"POST /api/v1/payments - 创建支付",
 				"GET /api/v1/payments/:id - 查询支付状态",
+				"GET /api/v1/payments/:id/qrcode - 获取支付二维码",
 				"POST /api/v1/payments/callback/alipay - 支付宝回调",
 				"POST /api/v1/payments/callback/wechat - 微信支付回调",
 			},
@@ -70,31 +93,65 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB, rdb *redis.Client, paymentServic
 
 	// 商品相关路由
 	productHandler := product.NewHandler(db)
+	productHandler.SetPriceService(NewPriceService(db, rdb))
 	productGroup := v1.Group("/products")
 	{
-		productGroup.GET("", productHandler.List)
-		productGroup.GET("/:id", productHandler.Get)
+		// 登录用户按所属客户分组的价目表展示价格，令牌无效时按游客展示公开售价
+		productGroup.GET("", middleware.LenientAuthMiddleware(), productHandler.List)
+		productGroup.GET("/:id", middleware.LenientAuthMiddleware(), productHandler.Get)
+		productGroup.GET("/:id/bundle", productHandler.GetBundle)
+		productGroup.GET("/:id/lowest-price", productHandler.GetLowestPrice)
 		productGroup.POST("", middleware.AuthMiddleware(), middleware.AdminMiddleware(), productHandler.Create)
 		productGroup.PUT("/:id", middleware.AuthMiddleware(), middleware.AdminMiddleware(), productHandler.Update)
 		productGroup.DELETE("/:id", middleware.AuthMiddleware(), middleware.AdminMiddleware(), productHandler.Delete)
 	}
 
+	// 定时调价与价格变更记录管理
+	product.RegisterPriceRoutes(v1, productHandler)
+
+	// 客户分组与分组价目表管理，分组成员在购物车、下单和商品列表详情中按价目表定价
+	pricelist.RegisterRoutes(v1, pricelistpkg.NewService(db))
+
+	// 收藏路由，商品价格、库存变化时触发收藏的降价、到货提醒
+	favoriteService := favoritepkg.NewService(db, cartpkg.NewCartService(db))
+	productHandler.AddChangeListener(favoriteService)
+	productHandler.AddChangeListener(newCartSyncService(db, rdb))
+	favorite.RegisterRoutes(v1, favoriteService)
+
 	// 订单相关路由
 	orderHandler := order.NewOrderHandler(db, rdb) // 使用正确的构造函数，传递Redis客户端
+	if paymentService != nil {
+		orderHandler.SetPaymentService(paymentService)
+	}
 	orderGroup := v1.Group("/orders")
 	orderGroup.Use(middleware.AuthMiddleware())
 	{
 		orderGroup.GET("", orderHandler.GetOrderList)                 // 使用正确的方法名
 		orderGroup.GET("/:id", orderHandler.GetOrder)                 // 使用正确的方法名
 		orderGroup.POST("", orderHandler.CreateOrder)                 // 使用正确的方法名
+		orderGroup.POST("/preview", orderHandler.PreviewOrder)        // 下单预览（含促销明细）
 		orderGroup.PUT("/:id/status", orderHandler.UpdateOrderStatus) // 使用正确的方法名
 		orderGroup.PUT("/:id/cancel", orderHandler.CancelOrder)       // 取消订单
 	}
 
+	// 促销路由，购物车、下单预览和下单共用同一套促销规则
+	promotion.RegisterRoutes(v1, promotionpkg.NewService(db))
+
+	// 会员路由，等级由成长值评定，购物车和下单按当前等级计算会员权益
+	member.RegisterRoutes(v1, memberpkg.NewService(db, memberpkg.DefaultOptions()))
+
+	// 商品订阅路由，周期下单由 cmd/server 启动的订阅调度任务执行
+	subscription.RegisterRoutes(v1, NewSubscriptionService(db, rdb))
+
+	// 礼品卡路由
+	giftcard.RegisterRoutes(v1, giftcardpkg.NewService(db, giftcardpkg.DefaultOptions()))
+
 	// 购物车相关路由
+	// 未登录用户使用签名Cookie标识的游客购物车，登录时合并到用户购物车
 	cartHandler := cart.NewCartHandler(db, rdb)
+	userHandler.OnLogin(cartHandler.MergeOnLogin)
 	cartGroup := v1.Group("/cart")
-	cartGroup.Use(middleware.AuthMiddleware())
+	cartGroup.Use(middleware.OptionalAuthMiddleware(), cartHandler.GuestSession())
 	{
 		cartGroup.GET("", cartHandler.GetCart)                    // 获取购物车
 		cartGroup.POST("/add", cartHandler.AddToCart)             // 添加商品到购物车
@@ -105,31 +162,30 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB, rdb *redis.Client, paymentServic
 		cartGroup.POST("/select-all", cartHandler.SelectAllItems) // 全选/取消全选
 		cartGroup.GET("/count", cartHandler.GetCartItemCount)     // 获取购物车商品数量
 		cartGroup.POST("/sync", cartHandler.SyncCartItems)        // 同步购物车商品信息
+		cartGroup.POST("/merge", cartHandler.MergeGuestCart)      // 合并游客购物车
+
+		// 购物车分享，查看和导入支持游客，直接结算需登录
+		cartGroup.POST("/share", cartHandler.ShareCart)                            // 分享购物车
+		cartGroup.GET("/shares", cartHandler.ListShares)                           // 我的购物车分享
+		cartGroup.DELETE("/shares/:id", cartHandler.RevokeShare)                   // 结束分享
+		cartGroup.GET("/shared/:token", cartHandler.GetSharedCart)                 // 查看分享的购物车
+		cartGroup.POST("/shared/:token/import", cartHandler.ImportSharedCart)      // 导入分享的购物车
+		cartGroup.POST("/shared/:token/checkout", orderHandler.CheckoutSharedCart) // 直接结算分享的购物车
+		cartGroup.POST("/bundles/:product_id", cartHandler.AddBundle)              // 搭配商品一键加购
 	}
+	cart.RegisterAdminRoutes(v1, cartHandler)
 
 	// 支付相关路由
-	paymentHandler := payment.NewHandler(db, paymentService)
-	paymentGroup := v1.Group("/payments")
-	paymentGroup.Use(middleware.AuthMiddleware())
-	{
-		paymentGroup.POST("", paymentHandler.CreatePayment)        // 创建支付
-		paymentGroup.GET("", paymentHandler.ListPayments)          // 获取支付列表
-		paymentGroup.GET("/:id", paymentHandler.GetPaymentByID)    // 根据ID获取支付详情
-		paymentGroup.GET("/query", paymentHandler.QueryPayment)    // 查询支付状态
-		paymentGroup.POST("/refund", paymentHandler.RefundPayment) // 申请退款
-	}
+	payment.RegisterRoutes(v1, db, paymentService, nil, nil)
 
-	// 支付回调路由（无需认证）
-	// 创建回调处理器（简化版本，实际应用中应该通过依赖注入配置完整的依赖）
-	callbackHandler := payment.NewCallbackHandler(db, paymentService, nil, nil, nil, nil)
-	callbackGroup := v1.Group("/payments/callback")
-	{
-		// 支付宝回调路由
-		callbackGroup.POST("/alipay", callbackHandler.AlipayCallback)
+	// 支付管理路由（管理员）
+	payment.RegisterAdminRoutes(v1, db, paymentService)
 
-		// 微信支付回调路由
-		callbackGroup.POST("/wechat", callbackHandler.WechatCallback)
-	}
+	// 币种与汇率路由
+	currency.RegisterRoutes(v1, db)
+
+	// 商家结算路由
+	settlement.RegisterRoutes(v1, newSettlementService(db, paymentService))
 
 	// 文件管理路由
 	fileHandler := file.NewFileHandler(db, "uploads", "http://localhost:8080")
@@ -172,6 +228,66 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB, rdb *redis.Client, paymentServic
 	}
 }
 
+// NewSubscriptionService 创建商品订阅服务，周期订单通过订单服务生成
+func NewSubscriptionService(db *gorm.DB, rdb *redis.Client) *subscriptionpkg.Service {
+	orderService := orderpkg.NewOrderService(db, cartpkg.NewCartService(db), cartpkg.NewCalculationService(db), inventory.NewInventoryService(db, rdb))
+	orderService.SetCurrencyService(currencypkg.NewService(db))
+	orderService.SetPriceListService(pricelistpkg.NewService(db))
+	return subscriptionpkg.NewService(db, orderService, orderpkg.NewStatusService(db), subscriptionpkg.DefaultOptions())
+}
+
+// NewPriceService 创建定时调价服务，调价生效或结束时触发收藏提醒、标记购物车旧价格并失效商品价格缓存
+func NewPriceService(db *gorm.DB, rdb *redis.Client) *productpkg.PriceService {
+	priceService := productpkg.NewPriceService(db, productpkg.DefaultPriceOptions())
+	priceService.AddChangeListener(favoritepkg.NewService(db, cartpkg.NewCartService(db)))
+	priceService.AddChangeListener(newCartSyncService(db, rdb))
+	if rdb != nil {
+		cacheManager := cache.NewRedisCacheManager(cache.WrapRedisClient(rdb))
+		priceCache := cache.NewPriceCacheService(cacheManager, cache.GetKeyManager())
+		productCache := cache.NewProductCacheService(cacheManager, cache.GetKeyManager())
+		priceService.AddCacheInvalidator(productpkg.CacheInvalidatorFunc(func(productID uint) {
+			_ = priceCache.DeletePrice(productID)
+			_ = productCache.DeleteProduct(productID)
+		}))
+	}
+	return priceService
+}
+
+// newSettlementService 创建商家结算服务，按支付服务当前的渠道客户端注册分账实现
+func newSettlementService(db *gorm.DB, paymentService *paymentpkg.Service) *settlementpkg.Service {
+	settlementService := settlementpkg.NewService(db, settlementpkg.DefaultOptions())
+	if paymentService == nil {
+		return settlementService
+	}
+
+	registerSharers := func(*paymentpkg.PaymentConfig) {
+		if client := paymentService.AlipayClient(); client != nil {
+			settlementService.RegisterSharer(model.PayoutMethodAlipay, settlementpkg.NewAlipaySharer(client))
+		} else {
+			settlementService.UnregisterSharer(model.PayoutMethodAlipay)
+		}
+		if client, ok := paymentService.WechatClient().(*wechat.ClientV3); ok {
+			settlementService.RegisterSharer(model.PayoutMethodWechat, settlementpkg.NewWechatSharer(client))
+		} else {
+			settlementService.UnregisterSharer(model.PayoutMethodWechat)
+		}
+	}
+	registerSharers(nil)
+	// 支付配置热加载后按新的渠道客户端重新注册分账实现
+	paymentService.AddConfigWatcher(paymentpkg.ConfigWatcherFunc(registerSharers))
+	return settlementService
+}
+
+// newCartSyncService 创建购物车同步服务，用于改价后标记持有旧价格的购物车商品
+func newCartSyncService(db *gorm.DB, rdb *redis.Client) *cartpkg.SyncService {
+	cartService := cartpkg.NewCartService(db)
+	var cacheService *cartpkg.CacheService
+	if rdb != nil {
+		cacheService = cartpkg.NewCacheService(rdb, cartService)
+	}
+	return cartpkg.NewSyncService(db, cartService, cacheService)
+}
+
 // RegisterMiddleware 注册中间件
 func RegisterMiddleware(r *gin.Engine) {
 	// 跨域中间件
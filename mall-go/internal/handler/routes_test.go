@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mall-go/internal/config"
+	"mall-go/internal/model"
+	"mall-go/pkg/auth"
+	"mall-go/pkg/pricelist"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestProductRoutes_CustomerPriceWithLenientAuth(t *testing.T) {
+	config.GlobalConfig.JWT = config.JWTConfig{Secret: "test-secret-key-for-routes-testing", Expire: "24h"}
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.Category{}, &model.Product{}, &model.ProductImage{}, &model.ProductSKU{},
+		&model.Currency{}, &model.ExchangeRate{}, &model.CustomerGroup{}, &model.CustomerGroupMember{},
+		&model.PriceList{}, &model.PriceListItem{}))
+
+	p := &model.Product{Name: "打印纸", Price: decimal.NewFromInt(100), Stock: 10, Status: model.ProductStatusActive}
+	require.NoError(t, db.Create(p).Error)
+	priceListService := pricelist.NewService(db)
+	group, err := priceListService.CreateGroup(&model.CustomerGroupRequest{Code: "wholesale", Name: "批发商", IsActive: true}, 1)
+	require.NoError(t, err)
+	_, err = priceListService.AddMembers(group.ID, []uint{7}, 1)
+	require.NoError(t, err)
+	_, err = priceListService.CreatePriceList(&model.PriceListRequest{GroupID: group.ID, Name: "批发价", IsActive: true,
+		Items: []model.PriceListItemRequest{{ProductID: p.ID, Type: model.PriceAdjustmentFixed, Value: decimal.NewFromInt(80)}}}, 1)
+	require.NoError(t, err)
+
+	r := gin.New()
+	RegisterRoutes(r, db, nil, nil)
+
+	listPrice := func(authorization string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var body struct {
+			Data struct {
+				List []struct {
+					Price decimal.Decimal `json:"price"`
+				} `json:"list"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+		require.Len(t, body.Data.List, 1, w.Body.String())
+		return w.Code, body.Data.List[0].Price.String()
+	}
+
+	// 无效令牌不拒绝请求，按游客展示公开售价
+	code, price := listPrice("Bearer invalid")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "100", price)
+
+	code, price = listPrice("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "100", price)
+
+	// 分组成员展示分组价
+	token, err := auth.GenerateToken(7, "buyer", model.RoleUser)
+	require.NoError(t, err)
+	code, price = listPrice("Bearer " + token)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "80", price)
+}
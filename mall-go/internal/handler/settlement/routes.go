@@ -0,0 +1,35 @@
+package settlement
+
+import (
+	"mall-go/internal/handler/middleware"
+	"mall-go/pkg/settlement"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 注册商家结算路由，管理员管理费率、结算单与打款，商家只读本商家结算单
+func RegisterRoutes(router *gin.RouterGroup, service *settlement.Service) {
+	handler := NewHandler(service)
+
+	adminGroup := router.Group("/admin/settlements")
+	adminGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		adminGroup.GET("/commission-rates", handler.ListCommissionRates)         // 佣金费率列表
+		adminGroup.POST("/commission-rates", handler.SaveCommissionRate)         // 设置佣金费率
+		adminGroup.DELETE("/commission-rates/:id", handler.DeleteCommissionRate) // 删除佣金费率
+		adminGroup.GET("/accounts/:merchant_id", handler.ListMerchantAccounts)   // 商家分账账户
+		adminGroup.POST("/accounts/:merchant_id", handler.SaveMerchantAccount)   // 设置商家分账账户
+		adminGroup.POST("/generate", handler.GenerateStatements)                 // 生成结算单
+		adminGroup.GET("", handler.ListStatements)                               // 结算单列表
+		adminGroup.GET("/:id", handler.GetStatement)                             // 结算单详情
+		adminGroup.POST("/:id/payouts", handler.RecordPayout)                    // 登记线下打款
+		adminGroup.POST("/:id/profit-sharing/:method", handler.PayoutViaChannel) // 渠道分账打款
+	}
+
+	merchantGroup := router.Group("/merchant/settlements")
+	merchantGroup.Use(middleware.AuthMiddleware(), middleware.MerchantMiddleware())
+	{
+		merchantGroup.GET("", handler.ListMyStatements)   // 本商家结算单列表
+		merchantGroup.GET("/:id", handler.GetMyStatement) // 本商家结算单详情
+	}
+}
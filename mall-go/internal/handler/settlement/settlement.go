@@ -0,0 +1,382 @@
+package settlement
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/pagination"
+	"mall-go/pkg/response"
+	"mall-go/pkg/settlement"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Handler 商家结算处理器
+type Handler struct {
+	service *settlement.Service
+}
+
+// NewHandler 创建商家结算处理器
+func NewHandler(service *settlement.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ListCommissionRates 查询佣金费率
+// @Summary 查询佣金费率
+// @Tags 商家结算
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.CommissionRate} "查询成功"
+// @Router /api/v1/admin/settlements/commission-rates [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListCommissionRates(c *gin.Context) {
+	rates, err := h.service.ListCommissionRates()
+	if err != nil {
+		logger.Error("查询佣金费率失败", zap.Error(err))
+		response.ServerError(c, "查询佣金费率失败")
+		return
+	}
+
+	response.Success(c, "查询成功", rates)
+}
+
+// SaveCommissionRate 设置佣金费率
+// @Summary 设置佣金费率
+// @Description 按平台默认、商品分类或商家设置佣金比例，商家费率优先于分类费率，分类费率对下级分类生效
+// @Tags 商家结算
+// @Accept json
+// @Produce json
+// @Param request body model.CommissionRateRequest true "费率信息"
+// @Success 200 {object} response.Response{data=model.CommissionRate} "保存成功"
+// @Router /api/v1/admin/settlements/commission-rates [post]
+// @Security ApiKeyAuth
+func (h *Handler) SaveCommissionRate(c *gin.Context) {
+	var req model.CommissionRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+
+	rate, err := h.service.SaveCommissionRate(&req, c.GetUint("user_id"))
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidCommissionRate) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		logger.Error("保存佣金费率失败", zap.String("scope", req.Scope), zap.Uint("scope_id", req.ScopeID), zap.Error(err))
+		response.ServerError(c, "保存佣金费率失败")
+		return
+	}
+
+	response.Success(c, "保存成功", rate)
+}
+
+// DeleteCommissionRate 删除佣金费率
+// @Summary 删除佣金费率
+// @Tags 商家结算
+// @Produce json
+// @Param id path uint true "费率ID"
+// @Success 200 {object} response.Response "删除成功"
+// @Router /api/v1/admin/settlements/commission-rates/{id} [delete]
+// @Security ApiKeyAuth
+func (h *Handler) DeleteCommissionRate(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "费率ID格式错误")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteCommissionRate(id); err != nil {
+		logger.Error("删除佣金费率失败", zap.Uint("id", id), zap.Error(err))
+		response.ServerError(c, "删除佣金费率失败")
+		return
+	}
+
+	response.Success(c, "删除成功", nil)
+}
+
+// GenerateStatements 生成结算单
+// @Summary 生成商家结算单
+// @Description 为指定商家或全部商家结算已完成且售后期结束的订单，结算后产生的退款在本期冲减
+// @Tags 商家结算
+// @Accept json
+// @Produce json
+// @Param request body model.GenerateSettlementRequest false "结算参数"
+// @Success 200 {object} response.Response{data=[]model.SettlementStatement} "生成成功"
+// @Router /api/v1/admin/settlements/generate [post]
+// @Security ApiKeyAuth
+func (h *Handler) GenerateStatements(c *gin.Context) {
+	var req model.GenerateSettlementRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, "请求参数错误: "+err.Error())
+			return
+		}
+	}
+
+	var periodEnd time.Time
+	if req.PeriodEnd != nil {
+		periodEnd = *req.PeriodEnd
+	}
+
+	if req.MerchantID == 0 {
+		statements, err := h.service.GenerateStatements(periodEnd)
+		if err != nil {
+			logger.Error("生成结算单失败", zap.Error(err))
+			response.ServerError(c, "生成结算单失败")
+			return
+		}
+		response.Success(c, "生成成功", statements)
+		return
+	}
+
+	statement, err := h.service.GenerateStatement(req.MerchantID, periodEnd)
+	if err != nil {
+		if errors.Is(err, model.ErrSettlementEmpty) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		logger.Error("生成结算单失败", zap.Uint("merchant_id", req.MerchantID), zap.Error(err))
+		response.ServerError(c, "生成结算单失败")
+		return
+	}
+
+	response.Success(c, "生成成功", []*model.SettlementStatement{statement})
+}
+
+// ListStatements 查询结算单（管理员）
+// @Summary 查询商家结算单
+// @Tags 商家结算
+// @Produce json
+// @Param merchant_id query uint false "商家ID"
+// @Param status query string false "结算状态"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/admin/settlements [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListStatements(c *gin.Context) {
+	var query model.SettlementStatementQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.BadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+
+	h.listStatements(c, &query)
+}
+
+// GetStatement 查询结算单详情（管理员）
+// @Summary 查询结算单详情
+// @Tags 商家结算
+// @Produce json
+// @Param id path uint true "结算单ID"
+// @Success 200 {object} response.Response{data=model.SettlementStatement} "查询成功"
+// @Router /api/v1/admin/settlements/{id} [get]
+// @Security ApiKeyAuth
+func (h *Handler) GetStatement(c *gin.Context) {
+	h.getStatement(c, 0)
+}
+
+// RecordPayout 登记线下打款
+// @Summary 登记结算线下打款
+// @Tags 商家结算
+// @Accept json
+// @Produce json
+// @Param id path uint true "结算单ID"
+// @Param request body model.SettlementPayoutRequest true "打款信息"
+// @Success 200 {object} response.Response{data=model.SettlementPayout} "登记成功"
+// @Router /api/v1/admin/settlements/{id}/payouts [post]
+// @Security ApiKeyAuth
+func (h *Handler) RecordPayout(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "结算单ID格式错误")
+	if !ok {
+		return
+	}
+
+	var req model.SettlementPayoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+
+	payout, err := h.service.RecordManualPayout(id, &req, c.GetUint("user_id"))
+	if err != nil {
+		h.handlePayoutError(c, id, err)
+		return
+	}
+
+	response.Success(c, "登记成功", payout)
+}
+
+// PayoutViaChannel 通过支付渠道分账打款
+// @Summary 通过支付渠道分账打款
+// @Description 对结算单中通过该渠道支付的订单逐笔发起分账，分账金额不超过待打款金额
+// @Tags 商家结算
+// @Produce json
+// @Param id path uint true "结算单ID"
+// @Param method path string true "分账渠道(alipay/wechat)"
+// @Success 200 {object} response.Response{data=[]model.SettlementPayout} "分账已提交"
+// @Router /api/v1/admin/settlements/{id}/profit-sharing/{method} [post]
+// @Security ApiKeyAuth
+func (h *Handler) PayoutViaChannel(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "结算单ID格式错误")
+	if !ok {
+		return
+	}
+
+	payouts, err := h.service.PayoutViaChannel(id, c.Param("method"), c.GetUint("user_id"))
+	if err != nil {
+		h.handlePayoutError(c, id, err)
+		return
+	}
+
+	response.Success(c, "分账已提交", payouts)
+}
+
+// SaveMerchantAccount 设置商家分账收款账户（管理员）
+// @Summary 设置商家分账收款账户
+// @Tags 商家结算
+// @Accept json
+// @Produce json
+// @Param merchant_id path uint true "商家ID"
+// @Param request body model.MerchantSettlementAccountRequest true "账户信息"
+// @Success 200 {object} response.Response{data=model.MerchantSettlementAccount} "保存成功"
+// @Router /api/v1/admin/settlements/accounts/{merchant_id} [post]
+// @Security ApiKeyAuth
+func (h *Handler) SaveMerchantAccount(c *gin.Context) {
+	merchantID, err := strconv.ParseUint(c.Param("merchant_id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "商家ID格式错误")
+		return
+	}
+
+	var req model.MerchantSettlementAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+
+	account, err := h.service.SaveAccount(uint(merchantID), &req)
+	if err != nil {
+		logger.Error("保存分账账户失败", zap.Uint64("merchant_id", merchantID), zap.Error(err))
+		response.ServerError(c, "保存分账账户失败")
+		return
+	}
+
+	response.Success(c, "保存成功", account)
+}
+
+// ListMerchantAccounts 查询商家分账收款账户（管理员）
+// @Summary 查询商家分账收款账户
+// @Tags 商家结算
+// @Produce json
+// @Param merchant_id path uint true "商家ID"
+// @Success 200 {object} response.Response{data=[]model.MerchantSettlementAccount} "查询成功"
+// @Router /api/v1/admin/settlements/accounts/{merchant_id} [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListMerchantAccounts(c *gin.Context) {
+	merchantID, err := strconv.ParseUint(c.Param("merchant_id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "商家ID格式错误")
+		return
+	}
+
+	accounts, err := h.service.ListAccounts(uint(merchantID))
+	if err != nil {
+		logger.Error("查询分账账户失败", zap.Uint64("merchant_id", merchantID), zap.Error(err))
+		response.ServerError(c, "查询分账账户失败")
+		return
+	}
+
+	response.Success(c, "查询成功", accounts)
+}
+
+// ListMyStatements 查询本商家结算单
+// @Summary 商家查询结算单
+// @Tags 商家结算
+// @Produce json
+// @Param status query string false "结算状态"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/merchant/settlements [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListMyStatements(c *gin.Context) {
+	var query model.SettlementStatementQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.BadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+	query.MerchantID = c.GetUint("user_id")
+
+	h.listStatements(c, &query)
+}
+
+// GetMyStatement 查询本商家结算单详情（含明细）
+// @Summary 商家查询结算单详情
+// @Tags 商家结算
+// @Produce json
+// @Param id path uint true "结算单ID"
+// @Success 200 {object} response.Response{data=model.SettlementStatement} "查询成功"
+// @Router /api/v1/merchant/settlements/{id} [get]
+// @Security ApiKeyAuth
+func (h *Handler) GetMyStatement(c *gin.Context) {
+	h.getStatement(c, c.GetUint("user_id"))
+}
+
+// listStatements 分页查询结算单
+func (h *Handler) listStatements(c *gin.Context, query *model.SettlementStatementQuery) {
+	statements, total, err := h.service.ListStatements(query)
+	if err != nil {
+		logger.Error("查询结算单失败", zap.Uint("merchant_id", query.MerchantID), zap.Error(err))
+		response.ServerError(c, "查询结算单失败")
+		return
+	}
+
+	page, pageSize := pagination.Normalize(query.Page, query.PageSize)
+	response.SuccessWithPage(c, "查询成功", statements, total, page, pageSize)
+}
+
+// getStatement 查询结算单详情，merchantID 不为0时只能查询该商家的结算单
+func (h *Handler) getStatement(c *gin.Context, merchantID uint) {
+	id, ok := response.ParseID(c, "id", "结算单ID格式错误")
+	if !ok {
+		return
+	}
+
+	statement, err := h.service.GetStatement(id, merchantID)
+	if err != nil {
+		if errors.Is(err, model.ErrSettlementNotFound) {
+			response.NotFound(c, err.Error())
+			return
+		}
+		logger.Error("查询结算单失败", zap.Uint("id", id), zap.Error(err))
+		response.ServerError(c, "查询结算单失败")
+		return
+	}
+
+	response.Success(c, "查询成功", statement)
+}
+
+// handlePayoutError 打款错误响应
+func (h *Handler) handlePayoutError(c *gin.Context, statementID uint, err error) {
+	switch {
+	case errors.Is(err, model.ErrSettlementNotFound):
+		response.NotFound(c, err.Error())
+	case errors.Is(err, model.ErrSettlementNotPayable),
+		errors.Is(err, model.ErrInvalidPayoutAmount),
+		errors.Is(err, model.ErrPayoutAmountExceeded),
+		errors.Is(err, model.ErrSettlementAccountNotSet),
+		errors.Is(err, model.ErrProfitSharingNotEnabled),
+		errors.Is(err, model.ErrNoShareableOrders):
+		response.BadRequest(c, err.Error())
+	default:
+		logger.Error("结算打款失败", zap.Uint("statement_id", statementID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, "结算打款失败")
+	}
+}
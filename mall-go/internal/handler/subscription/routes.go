@@ -0,0 +1,36 @@
+package subscription
+
+import (
+	"mall-go/internal/handler/middleware"
+	"mall-go/pkg/subscription"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 注册商品订阅及订阅计划管理路由
+func RegisterRoutes(router *gin.RouterGroup, service *subscription.Service) {
+	handler := NewHandler(service)
+
+	router.GET("/subscription-plans", handler.ListPlans) // 商品可订阅计划
+
+	subscriptionGroup := router.Group("/subscriptions")
+	subscriptionGroup.Use(middleware.AuthMiddleware())
+	{
+		subscriptionGroup.GET("", handler.ListSubscriptions)              // 我的订阅
+		subscriptionGroup.POST("", handler.Subscribe)                     // 订阅商品
+		subscriptionGroup.GET("/:id", handler.GetSubscription)            // 订阅详情及周期记录
+		subscriptionGroup.POST("/:id/skip-next", handler.SkipNext)        // 跳过下一期
+		subscriptionGroup.DELETE("/:id/skip-next", handler.UndoSkipNext)  // 撤销跳过下一期
+		subscriptionGroup.POST("/:id/pause", handler.PauseSubscription)   // 暂停订阅
+		subscriptionGroup.POST("/:id/resume", handler.ResumeSubscription) // 恢复订阅
+		subscriptionGroup.POST("/:id/cancel", handler.CancelSubscription) // 取消订阅
+	}
+
+	adminGroup := router.Group("/admin/subscription-plans")
+	adminGroup.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		adminGroup.GET("", handler.ListAllPlans)   // 订阅计划列表
+		adminGroup.POST("", handler.CreatePlan)    // 创建订阅计划
+		adminGroup.PUT("/:id", handler.UpdatePlan) // 更新订阅计划
+	}
+}
@@ -0,0 +1,339 @@
+package subscription
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/response"
+	"mall-go/pkg/subscription"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Handler 商品订阅处理器
+type Handler struct {
+	service *subscription.Service
+}
+
+// NewHandler 创建商品订阅处理器
+func NewHandler(service *subscription.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ListPlans 查询商品可订阅的计划
+// @Summary 查询订阅计划
+// @Tags 商品订阅
+// @Produce json
+// @Param product_id query uint false "商品ID"
+// @Success 200 {object} response.Response{data=[]model.SubscriptionPlan} "查询成功"
+// @Router /api/v1/subscription-plans [get]
+func (h *Handler) ListPlans(c *gin.Context) {
+	productID, _ := strconv.ParseUint(c.Query("product_id"), 10, 32)
+	plans, err := h.service.ListPlans(uint(productID), true)
+	if err != nil {
+		h.respondError(c, "查询订阅计划失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", plans)
+}
+
+// ListAllPlans 查询全部订阅计划（含已停用）
+// @Summary 查询全部订阅计划
+// @Tags 商品订阅
+// @Produce json
+// @Param product_id query uint false "商品ID"
+// @Success 200 {object} response.Response{data=[]model.SubscriptionPlan} "查询成功"
+// @Router /api/v1/admin/subscription-plans [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListAllPlans(c *gin.Context) {
+	productID, _ := strconv.ParseUint(c.Query("product_id"), 10, 32)
+	plans, err := h.service.ListPlans(uint(productID), false)
+	if err != nil {
+		h.respondError(c, "查询订阅计划失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", plans)
+}
+
+// CreatePlan 创建订阅计划
+// @Summary 创建订阅计划
+// @Description 为商品设置订阅周期、订阅折扣、最少履约期数及缺货处理方式
+// @Tags 商品订阅
+// @Accept json
+// @Produce json
+// @Param request body model.SubscriptionPlanRequest true "计划信息"
+// @Success 200 {object} response.Response{data=model.SubscriptionPlan} "创建成功"
+// @Router /api/v1/admin/subscription-plans [post]
+// @Security ApiKeyAuth
+func (h *Handler) CreatePlan(c *gin.Context) {
+	var req model.SubscriptionPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	plan, err := h.service.CreatePlan(&req)
+	if err != nil {
+		h.respondError(c, "创建订阅计划失败", err)
+		return
+	}
+
+	response.Success(c, "创建成功", plan)
+}
+
+// UpdatePlan 更新订阅计划
+// @Summary 更新订阅计划
+// @Description 已有订阅从下一期起按新计划执行；停用后不可新订阅，已有订阅不受影响
+// @Tags 商品订阅
+// @Accept json
+// @Produce json
+// @Param id path int true "计划ID"
+// @Param request body model.SubscriptionPlanRequest true "计划信息"
+// @Success 200 {object} response.Response{data=model.SubscriptionPlan} "更新成功"
+// @Router /api/v1/admin/subscription-plans/{id} [put]
+// @Security ApiKeyAuth
+func (h *Handler) UpdatePlan(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "计划ID格式错误")
+	if !ok {
+		return
+	}
+	var req model.SubscriptionPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	plan, err := h.service.UpdatePlan(id, &req)
+	if err != nil {
+		h.respondError(c, "更新订阅计划失败", err)
+		return
+	}
+
+	response.Success(c, "更新成功", plan)
+}
+
+// ListSubscriptions 查询我的订阅
+// @Summary 查询我的订阅
+// @Tags 商品订阅
+// @Produce json
+// @Param status query string false "订阅状态(active/paused/cancelled)"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} response.Response "查询成功"
+// @Router /api/v1/subscriptions [get]
+// @Security ApiKeyAuth
+func (h *Handler) ListSubscriptions(c *gin.Context) {
+	var req model.SubscriptionListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	subs, total, err := h.service.List(c.GetUint("user_id"), &req)
+	if err != nil {
+		h.respondError(c, "查询订阅失败", err)
+		return
+	}
+
+	response.SuccessWithPage(c, "查询成功", subs, total, req.Page, req.PageSize)
+}
+
+// Subscribe 订阅商品
+// @Summary 订阅商品
+// @Description 按计划周期自动下单，从账户余额或已签约的代扣协议扣款；start_at 为空时立即生成首期订单
+// @Tags 商品订阅
+// @Accept json
+// @Produce json
+// @Param request body model.CreateSubscriptionRequest true "订阅信息"
+// @Success 200 {object} response.Response{data=model.Subscription} "订阅成功"
+// @Router /api/v1/subscriptions [post]
+// @Security ApiKeyAuth
+func (h *Handler) Subscribe(c *gin.Context) {
+	var req model.CreateSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	sub, err := h.service.Subscribe(c.GetUint("user_id"), &req)
+	if err != nil {
+		h.respondError(c, "订阅失败", err)
+		return
+	}
+
+	response.Success(c, "订阅成功", sub)
+}
+
+// GetSubscription 查询订阅详情
+// @Summary 查询订阅详情
+// @Description 返回订阅及每期的下单、扣款记录
+// @Tags 商品订阅
+// @Produce json
+// @Param id path int true "订阅ID"
+// @Success 200 {object} response.Response{data=model.Subscription} "查询成功"
+// @Router /api/v1/subscriptions/{id} [get]
+// @Security ApiKeyAuth
+func (h *Handler) GetSubscription(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "订阅ID格式错误")
+	if !ok {
+		return
+	}
+
+	sub, err := h.service.Get(c.GetUint("user_id"), id)
+	if err != nil {
+		h.respondError(c, "查询订阅失败", err)
+		return
+	}
+
+	response.Success(c, "查询成功", sub)
+}
+
+// SkipNext 跳过下一期
+// @Summary 跳过下一期
+// @Tags 商品订阅
+// @Produce json
+// @Param id path int true "订阅ID"
+// @Success 200 {object} response.Response{data=model.Subscription} "设置成功"
+// @Router /api/v1/subscriptions/{id}/skip-next [post]
+// @Security ApiKeyAuth
+func (h *Handler) SkipNext(c *gin.Context) {
+	h.setSkipNext(c, true)
+}
+
+// UndoSkipNext 撤销跳过下一期
+// @Summary 撤销跳过下一期
+// @Tags 商品订阅
+// @Produce json
+// @Param id path int true "订阅ID"
+// @Success 200 {object} response.Response{data=model.Subscription} "设置成功"
+// @Router /api/v1/subscriptions/{id}/skip-next [delete]
+// @Security ApiKeyAuth
+func (h *Handler) UndoSkipNext(c *gin.Context) {
+	h.setSkipNext(c, false)
+}
+
+// setSkipNext 设置是否跳过下一期
+func (h *Handler) setSkipNext(c *gin.Context, skip bool) {
+	id, ok := response.ParseID(c, "id", "订阅ID格式错误")
+	if !ok {
+		return
+	}
+
+	sub, err := h.service.SkipNext(c.GetUint("user_id"), id, skip)
+	if err != nil {
+		h.respondError(c, "设置跳过下一期失败", err)
+		return
+	}
+
+	response.Success(c, "设置成功", sub)
+}
+
+// PauseSubscription 暂停订阅
+// @Summary 暂停订阅
+// @Tags 商品订阅
+// @Produce json
+// @Param id path int true "订阅ID"
+// @Success 200 {object} response.Response{data=model.Subscription} "暂停成功"
+// @Router /api/v1/subscriptions/{id}/pause [post]
+// @Security ApiKeyAuth
+func (h *Handler) PauseSubscription(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "订阅ID格式错误")
+	if !ok {
+		return
+	}
+
+	sub, err := h.service.Pause(c.GetUint("user_id"), id)
+	if err != nil {
+		h.respondError(c, "暂停订阅失败", err)
+		return
+	}
+
+	response.Success(c, "暂停成功", sub)
+}
+
+// ResumeSubscription 恢复订阅
+// @Summary 恢复订阅
+// @Description 恢复用户暂停、缺货暂停或扣款失败暂停的订阅，错过的周期不补单
+// @Tags 商品订阅
+// @Produce json
+// @Param id path int true "订阅ID"
+// @Success 200 {object} response.Response{data=model.Subscription} "恢复成功"
+// @Router /api/v1/subscriptions/{id}/resume [post]
+// @Security ApiKeyAuth
+func (h *Handler) ResumeSubscription(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "订阅ID格式错误")
+	if !ok {
+		return
+	}
+
+	sub, err := h.service.Resume(c.GetUint("user_id"), id)
+	if err != nil {
+		h.respondError(c, "恢复订阅失败", err)
+		return
+	}
+
+	response.Success(c, "恢复成功", sub)
+}
+
+// CancelSubscription 取消订阅
+// @Summary 取消订阅
+// @Description 未满计划最少履约期数时不可取消；催缴中的待支付周期订单一并取消
+// @Tags 商品订阅
+// @Accept json
+// @Produce json
+// @Param id path int true "订阅ID"
+// @Param request body model.CancelSubscriptionRequest false "取消原因"
+// @Success 200 {object} response.Response{data=model.Subscription} "取消成功"
+// @Router /api/v1/subscriptions/{id}/cancel [post]
+// @Security ApiKeyAuth
+func (h *Handler) CancelSubscription(c *gin.Context) {
+	id, ok := response.ParseID(c, "id", "订阅ID格式错误")
+	if !ok {
+		return
+	}
+	var req model.CancelSubscriptionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+			return
+		}
+	}
+
+	sub, err := h.service.Cancel(c.GetUint("user_id"), id, req.Reason)
+	if err != nil {
+		h.respondError(c, "取消订阅失败", err)
+		return
+	}
+
+	response.Success(c, "取消成功", sub)
+}
+
+// respondError 按订阅错误类型返回响应
+func (h *Handler) respondError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, model.ErrSubscriptionNotFound), errors.Is(err, model.ErrSubscriptionPlanNotFound):
+		response.Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, model.ErrSubscriptionBusy),
+		errors.Is(err, model.ErrSubscriptionCancelled),
+		errors.Is(err, model.ErrSubscriptionNotActive),
+		errors.Is(err, model.ErrSubscriptionNotPaused):
+		response.Error(c, http.StatusConflict, err.Error())
+	case errors.Is(err, model.ErrSubscriptionPlanInactive),
+		errors.Is(err, model.ErrSubscriptionProductInvalid),
+		errors.Is(err, model.ErrSubscriptionMinCycles),
+		errors.Is(err, model.ErrInvalidSubscriptionDiscount),
+		errors.Is(err, model.ErrWithholdingAgreementInvalid):
+		response.Error(c, http.StatusBadRequest, err.Error())
+	default:
+		logger.Error(message, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, message)
+	}
+}
@@ -18,8 +18,12 @@ type Handler struct {
 	profileService    *user.ProfileService
 	permissionService *user.PermissionService
 	securityService   *user.SecurityService
+	loginHooks        []LoginHook
 }
 
+// LoginHook 登录成功后的回调，在返回令牌前执行，可读写当前请求的Cookie
+type LoginHook func(c *gin.Context, userID uint)
+
 func NewHandler(db *gorm.DB) *Handler {
 	return &Handler{
 		db:                db,
@@ -30,6 +34,11 @@ func NewHandler(db *gorm.DB) *Handler {
 	}
 }
 
+// OnLogin 注册登录成功回调，如合并游客购物车
+func (h *Handler) OnLogin(hook LoginHook) {
+	h.loginHooks = append(h.loginHooks, hook)
+}
+
 // Register 用户注册
 // @Summary 用户注册
 // @Description 创建新用户账户
@@ -147,6 +156,10 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
+	for _, hook := range h.loginHooks {
+		hook(c, user.ID)
+	}
+
 	loginData := map[string]interface{}{
 		"token":      token,
 		"expires_in": 86400, // 24小时，单位：秒
@@ -20,6 +20,11 @@ type Cart struct {
 	TotalQty    int             `gorm:"default:0" json:"total_qty"`                       // 商品总数量
 	TotalAmount decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"total_amount"` // 总金额
 
+	// 活跃度追踪，长时间未修改的购物车判定为弃购，再次修改后恢复
+	LastActivityAt *time.Time `gorm:"index" json:"last_activity_at"`            // 最近一次修改购物车的时间
+	AbandonedAt    *time.Time `gorm:"index" json:"abandoned_at"`                // 判定为弃购的时间
+	RemindersSent  int        `gorm:"not null;default:0" json:"reminders_sent"` // 本次弃购已发送的提醒数
+
 	// 并发控制
 	Version int `gorm:"not null;default:1" json:"version"` // 乐观锁版本号
 
@@ -79,6 +84,19 @@ type CartSummary struct {
 	ShippingFee    decimal.Decimal `json:"shipping_fee"`    // 运费
 	FinalAmount    decimal.Decimal `json:"final_amount"`    // 最终金额
 	InvalidItems   []CartItem      `json:"invalid_items"`   // 失效商品列表
+
+	Presentment *CartPresentment `json:"presentment,omitempty"` // 展示币种金额，请求指定currency时返回
+}
+
+// CartPresentment 购物车展示币种金额
+type CartPresentment struct {
+	Currency       string          `json:"currency"`        // 展示币种
+	ExchangeRate   decimal.Decimal `json:"exchange_rate"`   // 汇率
+	TotalAmount    decimal.Decimal `json:"total_amount"`    // 总金额
+	SelectedAmount decimal.Decimal `json:"selected_amount"` // 选中商品总金额
+	DiscountAmount decimal.Decimal `json:"discount_amount"` // 优惠金额
+	ShippingFee    decimal.Decimal `json:"shipping_fee"`    // 运费
+	FinalAmount    decimal.Decimal `json:"final_amount"`    // 最终金额
 }
 
 // CartMergeLog 购物车合并日志
@@ -282,6 +300,9 @@ type CartStatsResponse struct {
 	ConversionRate float64         `json:"conversion_rate"`
 	TopProducts    []ProductStats  `json:"top_products"`
 	CartsByHour    []HourlyStats   `json:"carts_by_hour"`
+
+	// 弃购提醒按轮次统计的发送量和转化
+	Reminders []ReminderStageStats `json:"reminders"`
 }
 
 type ProductStats struct {
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CartReminder 弃购提醒记录，提醒后用户下单即记为该提醒的转化
+type CartReminder struct {
+	ID              uint            `gorm:"primarykey" json:"id"`
+	CartID          uint            `gorm:"not null;index" json:"cart_id"`
+	UserID          uint            `gorm:"not null;index" json:"user_id"`
+	Stage           int             `gorm:"not null;index" json:"stage"`                // 提醒轮次，从1开始
+	ItemCount       int             `json:"item_count"`                                 // 提醒时的商品种类数
+	CartAmount      decimal.Decimal `gorm:"type:decimal(10,2)" json:"cart_amount"`      // 提醒时的购物车金额
+	CouponID        uint            `json:"coupon_id"`                                  // 随提醒发放的优惠券，0为未发券
+	CouponCode      string          `gorm:"size:64" json:"coupon_code"`                 // 发放的券码
+	SentAt          time.Time       `gorm:"not null;index" json:"sent_at"`              // 发送时间
+	OrderID         uint            `gorm:"index" json:"order_id"`                      // 转化订单
+	ConvertedAt     *time.Time      `json:"converted_at"`                               // 转化时间
+	ConvertedAmount decimal.Decimal `gorm:"type:decimal(10,2)" json:"converted_amount"` // 转化订单金额
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+// TableName 指定表名
+func (CartReminder) TableName() string {
+	return "cart_reminders"
+}
+
+// ReminderStageStats 弃购提醒单轮统计
+type ReminderStageStats struct {
+	Stage           int             `json:"stage"`            // 提醒轮次
+	Sent            int64           `json:"sent"`             // 发送数
+	CouponIssued    int64           `json:"coupon_issued"`    // 附带优惠券的提醒数
+	Converted       int64           `json:"converted"`        // 转化数
+	ConversionRate  float64         `json:"conversion_rate"`  // 转化率
+	ConvertedAmount decimal.Decimal `json:"converted_amount"` // 转化订单金额
+}
+
+// ErrInvalidCartStatsDate 购物车统计日期格式错误
+var ErrInvalidCartStatsDate = errors.New("统计日期格式错误，应为YYYY-MM-DD")
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CartShare 购物车分享快照，创建后商品清单不再变化，凭令牌在有效期内查看、导入或直接结算
+type CartShare struct {
+	ID            uint            `gorm:"primarykey" json:"id"`
+	Token         string          `gorm:"uniqueIndex;not null;size:64" json:"token"` // 分享令牌
+	UserID        uint            `gorm:"not null;index" json:"user_id"`             // 分享人
+	Title         string          `gorm:"size:100" json:"title"`                     // 分享标题
+	ItemCount     int             `json:"item_count"`                                // 商品种类数
+	TotalQty      int             `json:"total_qty"`                                 // 商品总数量
+	TotalAmount   decimal.Decimal `gorm:"type:decimal(10,2)" json:"total_amount"`    // 分享时总金额
+	ExpiresAt     time.Time       `gorm:"not null;index" json:"expires_at"`          // 过期时间
+	ImportCount   int             `gorm:"not null;default:0" json:"import_count"`    // 导入购物车次数
+	CheckoutCount int             `gorm:"not null;default:0" json:"checkout_count"`  // 直接结算次数
+	LastUsedAt    *time.Time      `json:"last_used_at"`                              // 最近导入或结算时间
+	Items         []CartShareItem `gorm:"foreignKey:ShareID" json:"items,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (CartShare) TableName() string {
+	return "cart_shares"
+}
+
+// CartShareItem 购物车分享商品快照
+type CartShareItem struct {
+	ID           uint            `gorm:"primarykey" json:"id"`
+	ShareID      uint            `gorm:"not null;index" json:"share_id"`
+	ProductID    uint            `gorm:"not null" json:"product_id"`
+	SKUID        uint            `gorm:"column:sku_id;not null" json:"sku_id"` // SKU ID，0表示商品本身
+	ProductName  string          `gorm:"size:255" json:"product_name"`
+	ProductImage string          `gorm:"size:500" json:"product_image"`
+	SKUName      string          `gorm:"size:255" json:"sku_name"`
+	Price        decimal.Decimal `gorm:"type:decimal(10,2)" json:"price"` // 分享时单价
+	Quantity     int             `json:"quantity"`
+}
+
+// TableName 指定表名
+func (CartShareItem) TableName() string {
+	return "cart_share_items"
+}
+
+// ShareCartRequest 分享购物车请求
+type ShareCartRequest struct {
+	ItemIDs        []uint `json:"item_ids"`                                   // 分享的购物车商品项，为空时分享已选中的商品
+	Title          string `json:"title" binding:"max=100"`                    // 分享标题
+	ExpiresInHours int    `json:"expires_in_hours" binding:"omitempty,min=1"` // 有效时长，默认72小时
+}
+
+// CartShareView 分享购物车查看结果，附带商品当前价格和库存
+type CartShareView struct {
+	Share *CartShare          `json:"share"`
+	Items []CartShareItemView `json:"items"`
+}
+
+// CartShareItemView 分享商品当前状态
+type CartShareItemView struct {
+	CartShareItem
+	CurrentPrice  decimal.Decimal `json:"current_price"`  // 当前单价
+	Stock         int             `json:"stock"`          // 当前库存
+	Available     bool            `json:"available"`      // 商品及规格在售
+	PriceChanged  bool            `json:"price_changed"`  // 价格较分享时变化
+	StockShortage bool            `json:"stock_shortage"` // 库存不足分享数量
+}
+
+// SharedCartItemsRequest 导入或结算分享购物车请求
+type SharedCartItemsRequest struct {
+	ItemIDs []uint `json:"item_ids"` // 分享商品ID，为空时取全部
+}
+
+// SharedCartImportResult 分享购物车导入结果
+type SharedCartImportResult struct {
+	Added  []uint                    `json:"added"`  // 成功导入的分享商品ID
+	Failed []SharedCartImportFailure `json:"failed"` // 导入失败的分享商品
+}
+
+// SharedCartImportFailure 导入失败的分享商品及原因
+type SharedCartImportFailure struct {
+	ItemID uint   `json:"item_id"`
+	Reason string `json:"reason"`
+}
+
+// SharedCartCheckoutRequest 分享购物车直接结算请求
+type SharedCartCheckoutRequest struct {
+	ItemIDs         []uint   `json:"item_ids"` // 分享商品ID，为空时结算全部
+	CouponID        uint     `json:"coupon_id"`
+	PointsUsed      int      `json:"points_used"`
+	ReceiverName    string   `json:"receiver_name" binding:"required"`
+	ReceiverPhone   string   `json:"receiver_phone" binding:"required"`
+	ReceiverAddress string   `json:"receiver_address" binding:"required"`
+	ReceiverZipCode string   `json:"receiver_zip_code"`
+	Province        string   `json:"province" binding:"required"`
+	City            string   `json:"city" binding:"required"`
+	District        string   `json:"district" binding:"required"`
+	ShippingMethod  string   `json:"shipping_method"`
+	BuyerMessage    string   `json:"buyer_message"`
+	Currency        string   `json:"currency" binding:"omitempty,len=3"`
+	GiftCardIDs     []uint   `json:"gift_card_ids"`
+	GiftCardCodes   []string `json:"gift_card_codes"`
+}
+
+// 购物车分享相关错误
+var (
+	ErrCartShareNotFound = errors.New("分享不存在")
+	ErrCartShareExpired  = errors.New("分享已过期")
+	ErrCartShareEmpty    = errors.New("没有可分享的商品")
+)
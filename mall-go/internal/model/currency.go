@@ -0,0 +1,86 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// BaseCurrency 结算币种，商品、订单、购物车中的金额均以该币种计价
+const BaseCurrency = "CNY"
+
+// 汇率来源
+const (
+	ExchangeRateSourceFile  = "file"  // 汇率文件导入
+	ExchangeRateSourceAdmin = "admin" // 管理后台录入
+)
+
+// Currency 币种
+type Currency struct {
+	ID        uint   `gorm:"primarykey" json:"id"`
+	Code      string `gorm:"uniqueIndex;not null;size:3" json:"code"` // ISO 4217 币种代码
+	Name      string `gorm:"size:50" json:"name"`                     // 币种名称
+	Symbol    string `gorm:"size:10" json:"symbol"`                   // 货币符号
+	Decimals  int32  `gorm:"not null" json:"decimals"`                // 小数位数，如JPY为0
+	IsEnabled bool   `gorm:"not null" json:"is_enabled"`              // 是否启用
+	SortOrder int    `gorm:"default:0" json:"sort_order"`             // 排序
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Currency) TableName() string {
+	return "currencies"
+}
+
+// ExchangeRate 汇率
+// 汇率只追加不修改，按生效时间区分版本，订单锁定汇率时记录所用版本ID
+type ExchangeRate struct {
+	ID            uint            `gorm:"primarykey" json:"id"`
+	BaseCurrency  string          `gorm:"not null;size:3;index:idx_exchange_rate_version,priority:1" json:"base_currency"`  // 基准币种
+	QuoteCurrency string          `gorm:"not null;size:3;index:idx_exchange_rate_version,priority:2" json:"quote_currency"` // 报价币种
+	Rate          decimal.Decimal `gorm:"type:decimal(20,8);not null" json:"rate"`                                          // 1基准币种兑换的报价币种数量
+	EffectiveAt   time.Time       `gorm:"not null;index:idx_exchange_rate_version,priority:3" json:"effective_at"`          // 生效时间
+	Source        string          `gorm:"size:20" json:"source"`                                                            // 来源
+	OperatorID    uint            `json:"operator_id"`                                                                      // 操作人
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (ExchangeRate) TableName() string {
+	return "exchange_rates"
+}
+
+// CurrencyRequest 币种维护请求
+type CurrencyRequest struct {
+	Code      string `json:"code" binding:"required,len=3"`            // 币种代码
+	Name      string `json:"name" binding:"max=50"`                    // 币种名称
+	Symbol    string `json:"symbol" binding:"max=10"`                  // 货币符号
+	Decimals  *int32 `json:"decimals" binding:"omitempty,min=0,max=4"` // 小数位数，不传时按ISO 4217默认值
+	IsEnabled *bool  `json:"is_enabled"`                               // 是否启用
+	SortOrder int    `json:"sort_order"`                               // 排序
+}
+
+// ExchangeRateRequest 录入汇率请求
+type ExchangeRateRequest struct {
+	QuoteCurrency string          `json:"quote_currency" binding:"required,len=3"` // 报价币种
+	Rate          decimal.Decimal `json:"rate" binding:"required"`                 // 1结算币种兑换的报价币种数量
+	EffectiveAt   *time.Time      `json:"effective_at"`                            // 生效时间，默认立即生效
+}
+
+// ExchangeRateQuery 汇率历史查询参数
+type ExchangeRateQuery struct {
+	QuoteCurrency string `form:"quote_currency"` // 报价币种
+	Page          int    `form:"page"`           // 页码
+	PageSize      int    `form:"page_size"`      // 每页数量
+}
+
+// 币种错误定义
+var (
+	ErrCurrencyNotSupported = errors.New("不支持的币种")
+	ErrExchangeRateNotFound = errors.New("汇率不存在")
+	ErrInvalidExchangeRate  = errors.New("无效的汇率")
+)
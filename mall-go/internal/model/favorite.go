@@ -0,0 +1,144 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// FavoriteFolder 收藏夹分组
+type FavoriteFolder struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Name      string    `gorm:"not null;size:50" json:"name"` // 分组名称
+	Sort      int       `gorm:"default:0" json:"sort"`        // 排序
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (FavoriteFolder) TableName() string {
+	return "favorite_folders"
+}
+
+// FavoriteItem 收藏商品，同一用户对同一商品规格只收藏一次
+type FavoriteItem struct {
+	ID         uint            `gorm:"primarykey" json:"id"`
+	UserID     uint            `gorm:"not null;uniqueIndex:idx_favorite_item" json:"user_id"`
+	ProductID  uint            `gorm:"not null;uniqueIndex:idx_favorite_item;index" json:"product_id"`
+	SKUID      uint            `gorm:"column:sku_id;not null;uniqueIndex:idx_favorite_item" json:"sku_id"` // SKU ID，0表示商品本身
+	FolderID   uint            `gorm:"not null;index" json:"folder_id"`                                    // 分组ID，0为默认分组
+	PriceAtAdd decimal.Decimal `gorm:"type:decimal(10,2)" json:"price_at_add"`                             // 收藏时价格
+	Product    *Product        `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	SKU        *ProductSKU     `gorm:"foreignKey:SKUID" json:"sku,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (FavoriteItem) TableName() string {
+	return "favorite_items"
+}
+
+// FavoriteAlertType 收藏提醒类型
+type FavoriteAlertType string
+
+const (
+	FavoriteAlertPriceDrop   FavoriteAlertType = "price_drop"    // 降价提醒：价格降至目标价及以下
+	FavoriteAlertBackInStock FavoriteAlertType = "back_in_stock" // 到货提醒：库存由0恢复
+)
+
+// FavoriteAlertStatus 收藏提醒状态
+type FavoriteAlertStatus string
+
+const (
+	FavoriteAlertStatusActive    FavoriteAlertStatus = "active"    // 等待触发
+	FavoriteAlertStatusTriggered FavoriteAlertStatus = "triggered" // 已触发并通知
+	FavoriteAlertStatusCancelled FavoriteAlertStatus = "cancelled" // 已取消
+)
+
+// FavoriteAlert 降价、到货提醒订阅，触发一次后失效
+type FavoriteAlert struct {
+	ID             uint                `gorm:"primarykey" json:"id"`
+	UserID         uint                `gorm:"not null;index" json:"user_id"`
+	ProductID      uint                `gorm:"not null;index:idx_favorite_alert_target" json:"product_id"`
+	SKUID          uint                `gorm:"column:sku_id;not null;index:idx_favorite_alert_target" json:"sku_id"` // SKU ID，0表示商品本身
+	Type           FavoriteAlertType   `gorm:"not null;size:20" json:"type"`                                         // 提醒类型
+	TargetPrice    decimal.Decimal     `gorm:"type:decimal(10,2)" json:"target_price"`                               // 降价提醒的目标价
+	BasePrice      decimal.Decimal     `gorm:"type:decimal(10,2)" json:"base_price"`                                 // 订阅时价格
+	Status         FavoriteAlertStatus `gorm:"not null;size:20;index" json:"status"`                                 // 状态
+	TriggeredAt    *time.Time          `json:"triggered_at"`                                                         // 触发时间
+	TriggeredPrice decimal.Decimal     `gorm:"type:decimal(10,2)" json:"triggered_price"`                            // 触发时价格
+	TriggeredStock int                 `json:"triggered_stock"`                                                      // 触发时库存
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (FavoriteAlert) TableName() string {
+	return "favorite_alerts"
+}
+
+// AddFavoriteRequest 收藏商品请求
+type AddFavoriteRequest struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	SKUID     uint `json:"sku_id"`    // SKU ID，可选
+	FolderID  uint `json:"folder_id"` // 分组ID，0为默认分组
+}
+
+// FavoriteQuery 收藏查询条件
+type FavoriteQuery struct {
+	FolderID *uint `form:"folder_id"` // 分组ID，为空时查询全部
+	Page     int   `form:"page"`      // 页码
+	PageSize int   `form:"page_size"` // 每页数量
+}
+
+// FavoriteFolderRequest 创建或重命名收藏分组请求
+type FavoriteFolderRequest struct {
+	Name string `json:"name" binding:"required,max=50"`
+	Sort int    `json:"sort"`
+}
+
+// MoveFavoritesRequest 移动收藏到分组请求
+type MoveFavoritesRequest struct {
+	ItemIDs  []uint `json:"item_ids" binding:"required,min=1"`
+	FolderID uint   `json:"folder_id"` // 目标分组，0为默认分组
+}
+
+// FavoritesToCartRequest 收藏加入购物车请求
+type FavoritesToCartRequest struct {
+	ItemIDs  []uint `json:"item_ids" binding:"required,min=1"`
+	Quantity int    `json:"quantity"` // 每件加购数量，默认1
+	Keep     bool   `json:"keep"`     // 加购后是否保留收藏，默认移出收藏
+}
+
+// FavoritesToCartResult 收藏加入购物车结果
+type FavoritesToCartResult struct {
+	Added  []uint                   `json:"added"`  // 成功加购的收藏ID
+	Failed []FavoritesToCartFailure `json:"failed"` // 加购失败的收藏
+}
+
+// FavoritesToCartFailure 加购失败的收藏及原因
+type FavoritesToCartFailure struct {
+	ItemID uint   `json:"item_id"`
+	Reason string `json:"reason"`
+}
+
+// FavoriteAlertRequest 订阅降价或到货提醒请求
+type FavoriteAlertRequest struct {
+	ProductID   uint              `json:"product_id" binding:"required"`
+	SKUID       uint              `json:"sku_id"`
+	Type        FavoriteAlertType `json:"type" binding:"required,oneof=price_drop back_in_stock"`
+	TargetPrice decimal.Decimal   `json:"target_price"` // 降价提醒的目标价，需低于当前价
+}
+
+// 收藏相关错误
+var (
+	ErrFavoriteNotFound       = errors.New("收藏不存在")
+	ErrFavoriteExists         = errors.New("商品已在收藏列表中")
+	ErrFavoriteFolderNotFound = errors.New("收藏分组不存在")
+	ErrFavoriteProduct        = errors.New("商品或规格不存在")
+	ErrFavoriteAlertNotFound  = errors.New("提醒不存在")
+	ErrInvalidFavoriteAlert   = errors.New("提醒条件无效")
+)
@@ -43,6 +43,7 @@ const (
 	BusinessTypeAvatar  BusinessType = "avatar"  // 用户头像
 	BusinessTypeProduct BusinessType = "product" // 商品图片
 	BusinessTypeStore   BusinessType = "store"   // 店铺图片
+	BusinessTypeDispute BusinessType = "dispute" // 支付争议证据
 	BusinessTypeOther   BusinessType = "other"   // 其他业务
 )
 
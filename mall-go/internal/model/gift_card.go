@@ -0,0 +1,149 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// GiftCardBatch 礼品卡批次，后台按面值和有效期批量生成卡密
+type GiftCardBatch struct {
+	ID           uint            `gorm:"primarykey" json:"id"`
+	BatchNo      string          `gorm:"uniqueIndex;not null;size:32" json:"batch_no"`    // 批次号
+	Name         string          `gorm:"not null;size:100" json:"name"`                   // 批次名称
+	Denomination decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"denomination"` // 面值
+	Quantity     int             `gorm:"not null" json:"quantity"`                        // 生成数量
+	ExpiresAt    *time.Time      `json:"expires_at"`                                      // 过期时间，为空表示长期有效
+	OperatorID   uint            `gorm:"not null" json:"operator_id"`                     // 生成人
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// TableName 指定表名
+func (GiftCardBatch) TableName() string {
+	return "gift_card_batches"
+}
+
+// GiftCardStatus 礼品卡状态
+type GiftCardStatus string
+
+const (
+	GiftCardActive   GiftCardStatus = "active"   // 可用
+	GiftCardDisabled GiftCardStatus = "disabled" // 已冻结
+)
+
+// GiftCard 礼品卡（储值卡）
+// 卡密只在生成时返回一次，库中仅保存哈希与末四位；余额扣减使用条件更新防止超额使用
+type GiftCard struct {
+	ID           uint            `gorm:"primarykey" json:"id"`
+	BatchID      uint            `gorm:"not null;index" json:"batch_id"`                  // 批次ID
+	CodeHash     string          `gorm:"uniqueIndex;not null;size:64" json:"-"`           // 卡密哈希
+	CodeLast4    string          `gorm:"not null;size:4" json:"code_last4"`               // 卡密末四位，用于展示
+	Denomination decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"denomination"` // 面值
+	Balance      decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"balance"`      // 余额
+	Status       GiftCardStatus  `gorm:"not null;size:20;index" json:"status"`            // 状态
+	UserID       uint            `gorm:"index" json:"user_id"`                            // 绑定用户，为0表示未绑定
+	BoundAt      *time.Time      `json:"bound_at"`                                        // 绑定时间
+	ExpiresAt    *time.Time      `gorm:"index" json:"expires_at"`                         // 过期时间
+	Batch        *GiftCardBatch  `gorm:"foreignKey:BatchID" json:"batch,omitempty"`       // 批次
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (GiftCard) TableName() string {
+	return "gift_cards"
+}
+
+// IsExpired 礼品卡在指定时间是否已过期
+func (c *GiftCard) IsExpired(at time.Time) bool {
+	return c.ExpiresAt != nil && !c.ExpiresAt.After(at)
+}
+
+// GiftCardTransactionType 礼品卡流水类型
+type GiftCardTransactionType string
+
+const (
+	GiftCardTxIssue  GiftCardTransactionType = "issue"  // 发卡
+	GiftCardTxRedeem GiftCardTransactionType = "redeem" // 下单抵扣
+	GiftCardTxRefund GiftCardTransactionType = "refund" // 退款退回
+)
+
+// GiftCardTransaction 礼品卡余额流水，余额变动均有对应流水，用于对账和负债核算
+type GiftCardTransaction struct {
+	ID           uint                    `gorm:"primarykey" json:"id"`
+	CardID       uint                    `gorm:"not null;index" json:"card_id"`                    // 礼品卡ID
+	UserID       uint                    `gorm:"index" json:"user_id"`                             // 用户ID
+	OrderID      uint                    `gorm:"index" json:"order_id"`                            // 订单ID
+	Type         GiftCardTransactionType `gorm:"not null;size:20" json:"type"`                     // 流水类型
+	Amount       decimal.Decimal         `gorm:"type:decimal(10,2);not null" json:"amount"`        // 变动金额，抵扣为负
+	BalanceAfter decimal.Decimal         `gorm:"type:decimal(10,2);not null" json:"balance_after"` // 变动后余额
+	Reference    string                  `gorm:"size:64;index" json:"reference"`                   // 业务单号，如售后单号，用于幂等
+	Remark       string                  `gorm:"size:255" json:"remark"`                           // 备注
+	CreatedAt    time.Time               `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (GiftCardTransaction) TableName() string {
+	return "gift_card_transactions"
+}
+
+// CreateGiftCardBatchRequest 生成礼品卡批次请求
+type CreateGiftCardBatchRequest struct {
+	Name         string          `json:"name" binding:"required,max=100"`             // 批次名称
+	Denomination decimal.Decimal `json:"denomination" binding:"required"`             // 面值
+	Quantity     int             `json:"quantity" binding:"required,min=1,max=10000"` // 生成数量
+	ExpiresAt    *time.Time      `json:"expires_at"`                                  // 过期时间
+}
+
+// GiftCardBatchResponse 生成礼品卡批次结果，卡密仅在此返回一次
+type GiftCardBatchResponse struct {
+	Batch *GiftCardBatch `json:"batch"`
+	Codes []string       `json:"codes"`
+}
+
+// GiftCardQuery 礼品卡查询条件
+type GiftCardQuery struct {
+	BatchID  uint           `form:"batch_id"`  // 批次ID
+	UserID   uint           `form:"user_id"`   // 绑定用户
+	Status   GiftCardStatus `form:"status"`    // 状态
+	Page     int            `form:"page"`      // 页码
+	PageSize int            `form:"page_size"` // 每页数量
+}
+
+// BindGiftCardRequest 绑定礼品卡请求
+type BindGiftCardRequest struct {
+	Code string `json:"code" binding:"required"` // 卡密
+}
+
+// UpdateGiftCardStatusRequest 冻结或解冻礼品卡请求
+type UpdateGiftCardStatusRequest struct {
+	Status GiftCardStatus `json:"status" binding:"required,oneof=active disabled"`
+}
+
+// GiftCardLiability 礼品卡负债汇总
+// 未过期可用卡的余额为负债；已过期卡的剩余余额为沉淀收入
+type GiftCardLiability struct {
+	AsOf             time.Time       `json:"as_of"`             // 统计时点
+	IssuedCount      int64           `json:"issued_count"`      // 已发卡数
+	IssuedAmount     decimal.Decimal `json:"issued_amount"`     // 发卡总面值
+	RedeemedAmount   decimal.Decimal `json:"redeemed_amount"`   // 累计抵扣
+	RefundedAmount   decimal.Decimal `json:"refunded_amount"`   // 累计退回
+	OutstandingCount int64           `json:"outstanding_count"` // 有余额的可用卡数
+	Outstanding      decimal.Decimal `json:"outstanding"`       // 未使用余额（负债）
+	DisabledBalance  decimal.Decimal `json:"disabled_balance"`  // 冻结卡余额，仍计入负债
+	ExpiredBalance   decimal.Decimal `json:"expired_balance"`   // 过期卡余额（沉淀）
+}
+
+// 礼品卡错误定义
+var (
+	ErrGiftCardNotFound      = errors.New("礼品卡不存在或卡密错误")
+	ErrGiftCardBound         = errors.New("礼品卡已被其他用户绑定")
+	ErrGiftCardDisabled      = errors.New("礼品卡已冻结")
+	ErrGiftCardExpired       = errors.New("礼品卡已过期")
+	ErrGiftCardNoBalance     = errors.New("礼品卡余额不足")
+	ErrGiftCardUnavailable   = errors.New("礼品卡暂不可用")
+	ErrInvalidGiftCardAmount = errors.New("礼品卡面值必须大于0")
+	ErrInvalidGiftCardExpiry = errors.New("礼品卡过期时间必须晚于当前时间")
+	ErrGiftCardExportRange   = errors.New("导出日期范围无效")
+)
@@ -0,0 +1,157 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// MemberLevel 会员等级及权益
+type MemberLevel struct {
+	ID                    uint            `gorm:"primarykey" json:"id"`
+	Code                  string          `gorm:"uniqueIndex;not null;size:20" json:"code"`                    // 等级编码，如 silver/gold/platinum
+	Name                  string          `gorm:"not null;size:50" json:"name"`                                // 等级名称
+	MinGrowth             int             `gorm:"not null;index" json:"min_growth"`                            // 达到该等级所需成长值
+	DiscountRate          decimal.Decimal `gorm:"type:decimal(5,4);default:0" json:"discount_rate"`            // 会员折扣率，0.05表示优惠5%
+	FreeShippingThreshold decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"free_shipping_threshold"` // 包邮门槛，0为无门槛包邮
+	PointsMultiplier      decimal.Decimal `gorm:"type:decimal(5,2);default:1" json:"points_multiplier"`        // 积分倍数
+	CouponIDs             string          `gorm:"type:json" json:"coupon_ids"`                                 // 专享优惠券ID列表（JSON数组）
+	IsActive              bool            `gorm:"not null" json:"is_active"`                                   // 是否启用
+	CreatedAt             time.Time       `json:"created_at"`
+	UpdatedAt             time.Time       `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (MemberLevel) TableName() string {
+	return "member_levels"
+}
+
+// CouponIDList 解析专享优惠券ID列表
+func (l *MemberLevel) CouponIDList() []uint {
+	var ids []uint
+	if l.CouponIDs == "" {
+		return ids
+	}
+	_ = json.Unmarshal([]byte(l.CouponIDs), &ids)
+	return ids
+}
+
+// SetCouponIDs 设置专享优惠券ID列表
+func (l *MemberLevel) SetCouponIDs(ids []uint) {
+	if len(ids) == 0 {
+		l.CouponIDs = ""
+		return
+	}
+	data, _ := json.Marshal(ids)
+	l.CouponIDs = string(data)
+}
+
+// HasCoupon 是否包含专享优惠券
+func (l *MemberLevel) HasCoupon(couponID uint) bool {
+	for _, id := range l.CouponIDList() {
+		if id == couponID {
+			return true
+		}
+	}
+	return false
+}
+
+// UserMembership 用户会员信息
+type UserMembership struct {
+	ID             uint         `gorm:"primarykey" json:"id"`
+	UserID         uint         `gorm:"uniqueIndex;not null" json:"user_id"`
+	LevelID        uint         `gorm:"not null;index" json:"level_id"`            // 当前等级
+	Growth         int          `gorm:"not null" json:"growth"`                    // 有效期内的成长值
+	EvaluatedAt    time.Time    `gorm:"index" json:"evaluated_at"`                 // 最近一次评定时间
+	LevelChangedAt *time.Time   `json:"level_changed_at"`                          // 最近一次等级变化时间
+	Level          *MemberLevel `gorm:"foreignKey:LevelID" json:"level,omitempty"` // 当前等级
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (UserMembership) TableName() string {
+	return "user_memberships"
+}
+
+// GrowthSource 成长值来源
+type GrowthSource string
+
+const (
+	GrowthSourceOrder   GrowthSource = "order"    // 订单完成
+	GrowthSourceReview  GrowthSource = "review"   // 商品评价
+	GrowthSourceCheckIn GrowthSource = "check_in" // 每日签到
+	GrowthSourceAdjust  GrowthSource = "adjust"   // 后台调整
+)
+
+// MemberGrowthLog 成长值流水，同一来源只记录一次
+type MemberGrowthLog struct {
+	ID        uint         `gorm:"primarykey" json:"id"`
+	UserID    uint         `gorm:"not null;index" json:"user_id"`
+	Source    GrowthSource `gorm:"not null;size:20;uniqueIndex:idx_growth_source" json:"source"`     // 来源
+	SourceKey string       `gorm:"not null;size:64;uniqueIndex:idx_growth_source" json:"source_key"` // 来源标识，如订单ID、签到日期
+	Growth    int          `gorm:"not null" json:"growth"`                                           // 成长值变化，后台扣减时为负数
+	Remark    string       `gorm:"size:255" json:"remark"`
+	CreatedAt time.Time    `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (MemberGrowthLog) TableName() string {
+	return "member_growth_logs"
+}
+
+// MemberLevelChange 会员等级变更记录
+type MemberLevelChange struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	FromLevelID uint      `json:"from_level_id"`               // 原等级，首次评定为0
+	FromLevel   string    `gorm:"size:50" json:"from_level"`   // 原等级名称
+	ToLevelID   uint      `gorm:"not null" json:"to_level_id"` // 新等级
+	ToLevel     string    `gorm:"size:50" json:"to_level"`     // 新等级名称
+	Growth      int       `gorm:"not null" json:"growth"`      // 评定时的成长值
+	Reason      string    `gorm:"size:50" json:"reason"`       // 评定原因
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (MemberLevelChange) TableName() string {
+	return "member_level_changes"
+}
+
+// MemberProfile 用户会员概况
+type MemberProfile struct {
+	Membership     *UserMembership `json:"membership"`
+	Level          *MemberLevel    `json:"level"`            // 当前等级及权益
+	NextLevel      *MemberLevel    `json:"next_level"`       // 下一等级，已是最高等级时为空
+	NeedGrowth     int             `json:"need_growth"`      // 距下一等级还差的成长值
+	CheckedInToday bool            `json:"checked_in_today"` // 今日是否已签到
+}
+
+// MemberLevelRequest 创建或更新会员等级请求
+type MemberLevelRequest struct {
+	Code                  string          `json:"code" binding:"required,max=20"` // 等级编码
+	Name                  string          `json:"name" binding:"required,max=50"` // 等级名称
+	MinGrowth             int             `json:"min_growth" binding:"min=0"`     // 所需成长值
+	DiscountRate          decimal.Decimal `json:"discount_rate"`                  // 会员折扣率
+	FreeShippingThreshold decimal.Decimal `json:"free_shipping_threshold"`        // 包邮门槛
+	PointsMultiplier      decimal.Decimal `json:"points_multiplier"`              // 积分倍数，默认1
+	CouponIDs             []uint          `json:"coupon_ids"`                     // 专享优惠券
+	IsActive              bool            `json:"is_active"`                      // 是否启用
+}
+
+// AdjustGrowthRequest 后台调整成长值请求
+type AdjustGrowthRequest struct {
+	Change int    `json:"change" binding:"required"`         // 调整值，可为负数
+	Remark string `json:"remark" binding:"required,max=255"` // 调整原因
+}
+
+// 会员相关错误
+var (
+	ErrMemberLevelNotFound   = errors.New("会员等级不存在")
+	ErrMemberLevelDuplicate  = errors.New("会员等级编码或成长值门槛重复")
+	ErrInvalidMemberLevel    = errors.New("会员等级配置无效")
+	ErrMemberCheckedIn       = errors.New("今日已签到")
+	ErrMemberCouponExclusive = errors.New("该优惠券为会员等级专享")
+)
@@ -26,12 +26,29 @@ type Order struct {
 	ShippingFee    decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"shipping_fee"`    // 运费
 	TaxAmount      decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"tax_amount"`      // 税费
 
+	// 币种信息：以上金额均为结算币种，外币订单下单时锁定汇率并记录展示币种金额
+	SettlementCurrency       string          `gorm:"size:3;default:'CNY'" json:"settlement_currency"`                // 结算币种
+	Currency                 string          `gorm:"size:3;default:'CNY'" json:"currency"`                           // 展示（支付）币种
+	ExchangeRate             decimal.Decimal `gorm:"type:decimal(20,8);default:1" json:"exchange_rate"`              // 锁定汇率
+	ExchangeRateID           uint            `gorm:"default:0" json:"exchange_rate_id"`                              // 汇率版本ID
+	PresentmentTotalAmount   decimal.Decimal `gorm:"type:decimal(14,3);default:0" json:"presentment_total_amount"`   // 展示币种订单总金额
+	PresentmentPayableAmount decimal.Decimal `gorm:"type:decimal(14,3);default:0" json:"presentment_payable_amount"` // 展示币种应付金额
+
 	// 优惠信息
 	CouponID     uint            `gorm:"index" json:"coupon_id"`                            // 优惠券ID
 	CouponAmount decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"coupon_amount"` // 优惠券金额
 	PointsUsed   int             `gorm:"default:0" json:"points_used"`                      // 使用积分
 	PointsAmount decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"points_amount"` // 积分抵扣金额
 
+	// 促销优惠金额，已计入优惠金额，并按商品分摊到订单商品项
+	PromotionAmount decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"promotion_amount"`
+
+	// 会员折扣金额，按下单时的会员等级计算，已计入优惠金额
+	MemberAmount decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"member_amount"`
+
+	// 礼品卡抵扣金额，不计入应付金额，退款时优先退回礼品卡
+	GiftCardAmount decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"gift_card_amount"`
+
 	// 收货信息
 	ReceiverName    string `gorm:"size:50;not null" json:"receiver_name"`     // 收货人姓名
 	ReceiverPhone   string `gorm:"size:20;not null" json:"receiver_phone"`    // 收货人电话
@@ -112,6 +129,10 @@ type OrderItem struct {
 	Price        decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"price"`
 	TotalPrice   decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"total_price"`
 
+	// 促销信息
+	DiscountAmount decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"discount_amount"` // 分摊的促销优惠
+	IsGift         bool            `gorm:"default:false" json:"is_gift"`                        // 是否为满赠赠品
+
 	// 售后状态
 	RefundStatus   string          `gorm:"size:20;default:'none'" json:"refund_status"`
 	RefundQuantity int             `gorm:"default:0" json:"refund_quantity"`
@@ -280,11 +301,12 @@ const (
 
 // 订单类型常量
 const (
-	OrderTypeNormal   = "normal"   // 普通订单
-	OrderTypePresale  = "presale"  // 预售订单
-	OrderTypeGroup    = "group"    // 团购订单
-	OrderTypeSeckill  = "seckill"  // 秒杀订单
-	OrderTypeExchange = "exchange" // 换货订单
+	OrderTypeNormal       = "normal"       // 普通订单
+	OrderTypePresale      = "presale"      // 预售订单
+	OrderTypeGroup        = "group"        // 团购订单
+	OrderTypeSeckill      = "seckill"      // 秒杀订单
+	OrderTypeExchange     = "exchange"     // 换货订单
+	OrderTypeSubscription = "subscription" // 订阅周期订单
 )
 
 // 支付方式常量
@@ -315,12 +337,14 @@ const (
 
 // 售后状态常量
 const (
-	AfterSaleStatusPending   = "pending"   // 待处理
-	AfterSaleStatusApproved  = "approved"  // 已同意
-	AfterSaleStatusRejected  = "rejected"  // 已拒绝
-	AfterSaleStatusReturning = "returning" // 退货中
-	AfterSaleStatusCompleted = "completed" // 已完成
-	AfterSaleStatusCancelled = "cancelled" // 已取消
+	AfterSaleStatusPending      = "pending"       // 待处理
+	AfterSaleStatusApproved     = "approved"      // 已同意
+	AfterSaleStatusRejected     = "rejected"      // 已拒绝
+	AfterSaleStatusReturning    = "returning"     // 退货中
+	AfterSaleStatusRefunding    = "refunding"     // 退款中，等待渠道确认
+	AfterSaleStatusRefundFailed = "refund_failed" // 渠道退款失败
+	AfterSaleStatusCompleted    = "completed"     // 已完成
+	AfterSaleStatusCancelled    = "cancelled"     // 已取消
 )
 
 // 退款状态常量
@@ -370,6 +394,25 @@ func (o *Order) GenerateOrderNo() string {
 	return fmt.Sprintf("ORD%d%d", time.Now().Unix(), o.UserID)
 }
 
+// ChargedAmount 订单实收金额，即应付金额与礼品卡抵扣金额之和
+func (o *Order) ChargedAmount() decimal.Decimal {
+	return o.PayableAmount.Add(o.GiftCardAmount)
+}
+
+// RefundAmountFor 按促销分摊后的实付金额计算退回指定数量的金额
+func (item *OrderItem) RefundAmountFor(quantity int) decimal.Decimal {
+	if item.Quantity <= 0 {
+		return decimal.Zero
+	}
+	paid := item.TotalPrice.Sub(item.DiscountAmount)
+	return paid.Mul(decimal.NewFromInt(int64(quantity))).Div(decimal.NewFromInt(int64(item.Quantity))).Round(2)
+}
+
+// IsForeignCurrency 是否以非结算币种支付
+func (o *Order) IsForeignCurrency() bool {
+	return o.Currency != "" && o.Currency != BaseCurrency
+}
+
 func (o *Order) IsExpired() bool {
 	return o.PayExpireTime != nil && o.PayExpireTime.Before(time.Now())
 }
@@ -395,18 +438,29 @@ func (o *Order) GetStatusText() string {
 
 // 订单请求结构体
 type OrderCreateRequest struct {
-	CartItemIDs     []uint `json:"cart_item_ids" binding:"required,min=1"`
-	CouponID        uint   `json:"coupon_id"`
-	PointsUsed      int    `json:"points_used"`
-	ReceiverName    string `json:"receiver_name" binding:"required"`
-	ReceiverPhone   string `json:"receiver_phone" binding:"required"`
-	ReceiverAddress string `json:"receiver_address" binding:"required"`
-	ReceiverZipCode string `json:"receiver_zip_code"`
-	Province        string `json:"province" binding:"required"`
-	City            string `json:"city" binding:"required"`
-	District        string `json:"district" binding:"required"`
-	ShippingMethod  string `json:"shipping_method"`
-	BuyerMessage    string `json:"buyer_message"`
+	CartItemIDs     []uint   `json:"cart_item_ids" binding:"required,min=1"`
+	CouponID        uint     `json:"coupon_id"`
+	PointsUsed      int      `json:"points_used"`
+	ReceiverName    string   `json:"receiver_name" binding:"required"`
+	ReceiverPhone   string   `json:"receiver_phone" binding:"required"`
+	ReceiverAddress string   `json:"receiver_address" binding:"required"`
+	ReceiverZipCode string   `json:"receiver_zip_code"`
+	Province        string   `json:"province" binding:"required"`
+	City            string   `json:"city" binding:"required"`
+	District        string   `json:"district" binding:"required"`
+	ShippingMethod  string   `json:"shipping_method"`
+	BuyerMessage    string   `json:"buyer_message"`
+	Currency        string   `json:"currency" binding:"omitempty,len=3"` // 支付币种，默认CNY
+	GiftCardIDs     []uint   `json:"gift_card_ids"`                      // 使用的已绑定礼品卡
+	GiftCardCodes   []string `json:"gift_card_codes"`                    // 使用的礼品卡卡密，使用后绑定到当前用户
+}
+
+// OrderPreviewRequest 下单预览请求，不指定购物车商品项时预览整个购物车
+type OrderPreviewRequest struct {
+	CartItemIDs []uint `json:"cart_item_ids"`
+	CouponID    uint   `json:"coupon_id"`
+	PointsUsed  int    `json:"points_used"`
+	Province    string `json:"province"` // 收货省份，用于计算运费
 }
 
 type OrderUpdateStatusRequest struct {
@@ -55,14 +55,19 @@ type Payment struct {
 	PaymentMethod PaymentMethod `gorm:"not null;size:20" json:"payment_method"`                   // 支付方式
 	PaymentStatus PaymentStatus `gorm:"not null;size:20;default:'pending'" json:"payment_status"` // 支付状态
 
-	// 金额信息
-	Amount       decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"amount"` // 支付金额
-	ActualAmount decimal.Decimal `gorm:"type:decimal(10,2)" json:"actual_amount"`   // 实际支付金额
+	// 金额信息，按支付币种保留小数位，与订单展示币种金额一致支持KWD等三位小数币种
+	Amount       decimal.Decimal `gorm:"type:decimal(14,3);not null" json:"amount"` // 支付金额
+	ActualAmount decimal.Decimal `gorm:"type:decimal(14,3)" json:"actual_amount"`   // 实际支付金额
 	Currency     string          `gorm:"size:3;default:'CNY'" json:"currency"`      // 货币类型
 
+	// 外币支付时记录结算币种金额及下单锁定的汇率
+	SettlementAmount decimal.Decimal `gorm:"type:decimal(14,3)" json:"settlement_amount"`       // 结算币种金额
+	ExchangeRate     decimal.Decimal `gorm:"type:decimal(20,8);default:1" json:"exchange_rate"` // 汇率
+
 	// 第三方支付信息
 	ThirdPartyID   string `gorm:"size:128;index" json:"third_party_id"` // 第三方支付单号
 	ThirdPartyData string `gorm:"type:text" json:"third_party_data"`    // 第三方返回数据
+	CodeURL        string `gorm:"size:512" json:"code_url"`             // 扫码支付二维码内容
 
 	// 支付详情
 	Subject     string `gorm:"size:256" json:"subject"`      // 支付主题
@@ -70,6 +75,10 @@ type Payment struct {
 	NotifyURL   string `gorm:"size:512" json:"notify_url"`   // 异步通知地址
 	ReturnURL   string `gorm:"size:512" json:"return_url"`   // 同步跳转地址
 
+	// 主动轮询（回调丢失时补单）
+	PollCount  int        `gorm:"not null;default:0" json:"poll_count"` // 已轮询次数
+	NextPollAt *time.Time `gorm:"index" json:"next_poll_at"`            // 下次轮询时间
+
 	// 时间信息
 	ExpiredAt *time.Time     `json:"expired_at"` // 过期时间
 	PaidAt    *time.Time     `json:"paid_at"`    // 支付时间
@@ -88,7 +97,7 @@ type PaymentRefund struct {
 	User      User    `gorm:"foreignKey:UserID" json:"user,omitempty"`       // 关联用户
 
 	// 退款信息
-	RefundAmount decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"refund_amount"`        // 退款金额
+	RefundAmount decimal.Decimal `gorm:"type:decimal(14,3);not null" json:"refund_amount"`        // 退款金额
 	RefundReason string          `gorm:"size:512" json:"refund_reason"`                           // 退款原因
 	RefundStatus PaymentStatus   `gorm:"not null;size:20;default:'pending'" json:"refund_status"` // 退款状态
 
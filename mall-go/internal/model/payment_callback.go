@@ -0,0 +1,130 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// PaymentCallbackKind 回调通知类型
+type PaymentCallbackKind string
+
+const (
+	PaymentCallbackKindPayment   PaymentCallbackKind = "payment"   // 支付结果通知
+	PaymentCallbackKindRefund    PaymentCallbackKind = "refund"    // 退款结果通知
+	PaymentCallbackKindComplaint PaymentCallbackKind = "complaint" // 消费者投诉通知
+)
+
+// PaymentCallbackStatus 回调处理状态
+type PaymentCallbackStatus string
+
+const (
+	PaymentCallbackReceived         PaymentCallbackStatus = "received"          // 已接收，处理中或处理过程中进程退出
+	PaymentCallbackSucceeded        PaymentCallbackStatus = "succeeded"         // 处理成功
+	PaymentCallbackValidationFailed PaymentCallbackStatus = "validation_failed" // 签名或参数校验失败
+	PaymentCallbackFailed           PaymentCallbackStatus = "failed"            // 校验通过但业务处理失败
+)
+
+// IsFailed 是否为失败状态
+func (s PaymentCallbackStatus) IsFailed() bool {
+	return s == PaymentCallbackValidationFailed || s == PaymentCallbackFailed
+}
+
+// PaymentCallback 支付渠道回调通知原文
+// 每次收到的回调均原样保存请求头与报文，处理失败的回调修复后可人工重放
+type PaymentCallback struct {
+	ID         uint                  `gorm:"primarykey" json:"id"`
+	Channel    PaymentMethod         `gorm:"not null;size:20;index:idx_payment_callback_channel,priority:1" json:"channel"` // 支付渠道
+	Kind       PaymentCallbackKind   `gorm:"not null;size:20" json:"kind"`                                                  // 通知类型
+	APIVersion string                `gorm:"size:10" json:"api_version"`                                                    // 渠道接口版本，如微信v2/v3
+	Path       string                `gorm:"size:255" json:"path"`                                                          // 请求路径
+	Headers    string                `gorm:"type:text" json:"headers"`                                                      // 请求头(JSON)
+	Body       string                `gorm:"type:text" json:"body"`                                                         // 请求报文原文
+	ClientIP   string                `gorm:"size:64" json:"client_ip"`                                                      // 来源IP
+	OutTradeNo string                `gorm:"size:64;index" json:"out_trade_no"`                                             // 商户订单号，加密报文无法解析时为空
+	Status     PaymentCallbackStatus `gorm:"not null;size:20;index:idx_payment_callback_channel,priority:2" json:"status"`  // 最近一次处理状态
+	Error      string                `gorm:"size:1000" json:"error"`                                                        // 最近一次处理错误
+
+	ReplayCount    int        `gorm:"not null" json:"replay_count"` // 人工重放次数
+	LastReplayedAt *time.Time `json:"last_replayed_at"`             // 最近一次重放时间
+
+	CreatedAt time.Time `gorm:"index:idx_payment_callback_channel,priority:3" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Attempts []PaymentCallbackAttempt `gorm:"foreignKey:CallbackID" json:"attempts,omitempty"`
+}
+
+// TableName 指定表名
+func (PaymentCallback) TableName() string {
+	return "payment_callbacks"
+}
+
+// PaymentCallbackAttempt 回调处理记录，首次接收与每次人工重放各一条
+type PaymentCallbackAttempt struct {
+	ID         uint                  `gorm:"primarykey" json:"id"`
+	CallbackID uint                  `gorm:"not null;index" json:"callback_id"` // 回调ID
+	Replay     bool                  `gorm:"not null" json:"replay"`            // 是否为人工重放
+	OperatorID uint                  `json:"operator_id"`                       // 重放操作人
+	Validated  bool                  `gorm:"not null" json:"validated"`         // 签名与参数校验是否通过
+	Status     PaymentCallbackStatus `gorm:"not null;size:20" json:"status"`    // 处理状态
+	Error      string                `gorm:"size:1000" json:"error"`            // 错误信息
+	DurationMs int64                 `gorm:"not null" json:"duration_ms"`       // 处理耗时(毫秒)
+	CreatedAt  time.Time             `json:"created_at"`
+}
+
+// TableName 指定表名
+func (PaymentCallbackAttempt) TableName() string {
+	return "payment_callback_attempts"
+}
+
+// PaymentCallbackQuery 回调查询条件
+type PaymentCallbackQuery struct {
+	Channel    PaymentMethod         `form:"channel"`                                      // 支付渠道
+	Kind       PaymentCallbackKind   `form:"kind"`                                         // 通知类型
+	Status     PaymentCallbackStatus `form:"status"`                                       // 处理状态
+	FailedOnly bool                  `form:"failed_only"`                                  // 仅查询失败的回调
+	OutTradeNo string                `form:"out_trade_no"`                                 // 商户订单号
+	StartTime  *time.Time            `form:"start_time" time_format:"2006-01-02 15:04:05"` // 接收时间起
+	EndTime    *time.Time            `form:"end_time" time_format:"2006-01-02 15:04:05"`   // 接收时间止
+	Page       int                   `form:"page"`                                         // 页码
+	PageSize   int                   `form:"page_size"`                                    // 每页数量
+}
+
+// PaymentCallbackStats 按渠道统计的回调数量
+type PaymentCallbackStats struct {
+	Channel          PaymentMethod `json:"channel"`           // 支付渠道
+	Total            int64         `json:"total"`             // 回调总数
+	Succeeded        int64         `json:"succeeded"`         // 处理成功
+	ValidationFailed int64         `json:"validation_failed"` // 校验失败
+	Failed           int64         `json:"failed"`            // 处理失败
+	Pending          int64         `json:"pending"`           // 未完成处理
+	Replayed         int64         `json:"replayed"`          // 被人工重放过的回调数
+}
+
+// PaymentCallbackValidationError 回调签名或参数校验失败
+// 与校验通过后的业务处理失败区分，便于定位问题
+type PaymentCallbackValidationError struct {
+	Err error
+}
+
+// NewPaymentCallbackValidationError 包装回调校验错误
+func NewPaymentCallbackValidationError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PaymentCallbackValidationError{Err: err}
+}
+
+func (e *PaymentCallbackValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap 返回原始错误
+func (e *PaymentCallbackValidationError) Unwrap() error {
+	return e.Err
+}
+
+// 支付回调错误定义
+var (
+	ErrPaymentCallbackNotFound         = errors.New("回调记录不存在")
+	ErrPaymentCallbackAlreadySucceeded = errors.New("回调已处理成功，无需重放")
+)
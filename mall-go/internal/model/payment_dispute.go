@@ -0,0 +1,136 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PaymentDisputeType 支付争议类型
+type PaymentDisputeType string
+
+const (
+	PaymentDisputeChargeback PaymentDisputeType = "chargeback" // 银行卡拒付
+	PaymentDisputeComplaint  PaymentDisputeType = "complaint"  // 渠道消费者投诉，如微信支付投诉
+)
+
+// PaymentDisputeSource 支付争议来源
+type PaymentDisputeSource string
+
+const (
+	PaymentDisputeSourceManual  PaymentDisputeSource = "manual"  // 人工登记
+	PaymentDisputeSourceChannel PaymentDisputeSource = "channel" // 渠道通知自动创建
+)
+
+// PaymentDisputeStatus 支付争议状态
+type PaymentDisputeStatus string
+
+const (
+	PaymentDisputeOpen      PaymentDisputeStatus = "open"      // 待提交证据
+	PaymentDisputeSubmitted PaymentDisputeStatus = "submitted" // 已提交证据，等待渠道裁决
+	PaymentDisputeWon       PaymentDisputeStatus = "won"       // 胜诉，资金保留
+	PaymentDisputeLost      PaymentDisputeStatus = "lost"      // 败诉，资金被扣回
+)
+
+// OpenPaymentDisputeStatuses 未结案的争议状态，争议处理中的订单暂不结算
+var OpenPaymentDisputeStatuses = []PaymentDisputeStatus{PaymentDisputeOpen, PaymentDisputeSubmitted}
+
+// IsClosed 争议是否已结案
+func (s PaymentDisputeStatus) IsClosed() bool {
+	return s == PaymentDisputeWon || s == PaymentDisputeLost
+}
+
+// PaymentDispute 支付争议（拒付、投诉）
+// 败诉后争议金额在商家下一期结算单中以拒付明细冲回，订单禁止继续发货
+type PaymentDispute struct {
+	ID               uint                 `gorm:"primarykey" json:"id"`
+	DisputeNo        string               `gorm:"uniqueIndex;not null;size:32" json:"dispute_no"`                                 // 争议单号
+	PaymentID        uint                 `gorm:"not null;index" json:"payment_id"`                                               // 支付记录ID
+	PaymentNo        string               `gorm:"size:64" json:"payment_no"`                                                      // 支付单号
+	OrderID          uint                 `gorm:"not null;index" json:"order_id"`                                                 // 订单ID
+	UserID           uint                 `gorm:"index" json:"user_id"`                                                           // 用户ID
+	Channel          PaymentMethod        `gorm:"not null;size:20;index:idx_payment_dispute_channel,priority:1" json:"channel"`   // 支付渠道
+	ChannelDisputeID string               `gorm:"size:64;index:idx_payment_dispute_channel,priority:2" json:"channel_dispute_id"` // 渠道争议单号，如微信投诉单号
+	Type             PaymentDisputeType   `gorm:"not null;size:20" json:"type"`                                                   // 争议类型
+	Source           PaymentDisputeSource `gorm:"not null;size:20" json:"source"`                                                 // 争议来源
+	Status           PaymentDisputeStatus `gorm:"not null;size:20;index" json:"status"`                                           // 争议状态
+	Amount           decimal.Decimal      `gorm:"type:decimal(10,2);not null" json:"amount"`                                      // 争议金额
+	Reason           string               `gorm:"size:500" json:"reason"`                                                         // 争议原因
+	EvidenceDueAt    *time.Time           `gorm:"index" json:"evidence_due_at"`                                                   // 证据提交截止时间
+
+	SubmittedAt      *time.Time `json:"submitted_at"`                      // 证据提交时间
+	ResolvedAt       *time.Time `json:"resolved_at"`                       // 结案时间
+	ResolutionRemark string     `gorm:"size:500" json:"resolution_remark"` // 结案说明
+	OperatorID       uint       `json:"operator_id"`                       // 最近操作人
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Evidence []PaymentDisputeEvidence `gorm:"foreignKey:DisputeID" json:"evidence,omitempty"`
+}
+
+// TableName 指定表名
+func (PaymentDispute) TableName() string {
+	return "payment_disputes"
+}
+
+// IsOverdue 证据提交是否已超过截止时间
+func (d *PaymentDispute) IsOverdue(now time.Time) bool {
+	return d.EvidenceDueAt != nil && now.After(*d.EvidenceDueAt)
+}
+
+// PaymentDisputeEvidence 争议证据，文件通过上传服务保存
+type PaymentDisputeEvidence struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	DisputeID   uint      `gorm:"not null;index" json:"dispute_id"` // 争议ID
+	FileID      uint      `gorm:"not null" json:"file_id"`          // 文件ID
+	FileName    string    `gorm:"size:255" json:"file_name"`        // 原始文件名
+	FileURL     string    `gorm:"size:500" json:"file_url"`         // 文件访问地址
+	Description string    `gorm:"size:500" json:"description"`      // 证据说明
+	UploaderID  uint      `json:"uploader_id"`                      // 上传人
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (PaymentDisputeEvidence) TableName() string {
+	return "payment_dispute_evidence"
+}
+
+// CreatePaymentDisputeRequest 登记支付争议请求
+type CreatePaymentDisputeRequest struct {
+	PaymentNo        string             `json:"payment_no" binding:"required"`                      // 支付单号
+	Type             PaymentDisputeType `json:"type" binding:"required,oneof=chargeback complaint"` // 争议类型
+	ChannelDisputeID string             `json:"channel_dispute_id" binding:"max=64"`                // 渠道争议单号
+	Amount           decimal.Decimal    `json:"amount"`                                             // 争议金额，为0时取支付金额
+	Reason           string             `json:"reason" binding:"required,max=500"`                  // 争议原因
+	EvidenceDueAt    *time.Time         `json:"evidence_due_at"`                                    // 证据提交截止时间，为空时按默认期限计算
+}
+
+// ResolvePaymentDisputeRequest 登记争议裁决结果请求
+type ResolvePaymentDisputeRequest struct {
+	Outcome PaymentDisputeStatus `json:"outcome" binding:"required,oneof=won lost"` // 裁决结果
+	Remark  string               `json:"remark" binding:"max=500"`                  // 结案说明
+}
+
+// PaymentDisputeQuery 支付争议查询条件
+type PaymentDisputeQuery struct {
+	Status    PaymentDisputeStatus `form:"status"`                                       // 争议状态
+	Channel   PaymentMethod        `form:"channel"`                                      // 支付渠道
+	OrderID   uint                 `form:"order_id"`                                     // 订单ID
+	DueBefore *time.Time           `form:"due_before" time_format:"2006-01-02 15:04:05"` // 证据截止时间早于该时间的未结案争议
+	Page      int                  `form:"page"`                                         // 页码
+	PageSize  int                  `form:"page_size"`                                    // 每页数量
+}
+
+// 支付争议错误定义
+var (
+	ErrPaymentDisputeNotFound  = errors.New("支付争议不存在")
+	ErrPaymentDisputeExists    = errors.New("该支付已存在未结案的争议")
+	ErrPaymentDisputeClosed    = errors.New("支付争议已结案")
+	ErrPaymentNotDisputable    = errors.New("仅支付成功的订单可登记争议")
+	ErrInvalidDisputeAmount    = errors.New("争议金额必须大于0且不超过支付金额")
+	ErrDisputeEvidenceRequired = errors.New("请先上传争议证据")
+	ErrDisputeEvidenceOverdue  = errors.New("已超过证据提交截止时间")
+	ErrOrderShipmentBlocked    = errors.New("订单支付争议已败诉，禁止发货")
+)
@@ -17,19 +17,34 @@ type PaymentCreateRequest struct {
 	ReturnURL      string          `json:"return_url" binding:"url,max=512"`         // 同步跳转地址
 	NotifyURL      string          `json:"notify_url" binding:"url,max=512"`         // 异步通知地址
 	ExpiredMinutes int             `json:"expired_minutes" binding:"min=1,max=1440"` // 过期时间(分钟)
+
+	// 微信支付场景参数
+	TradeType string `json:"trade_type" binding:"omitempty,oneof=JSAPI NATIVE H5 APP"` // 交易类型，默认NATIVE
+	OpenID    string `json:"openid" binding:"max=128"`                                 // 用户openid，JSAPI支付必填
+	ClientIP  string `json:"-"`                                                        // 用户终端IP，由服务端填充
+
+	// 风控参数
+	DeviceID         string `json:"device_id" binding:"max=100"`        // 设备ID，未传时取 X-Device-ID 请求头
+	RiskDecisionID   uint   `json:"risk_decision_id"`                   // 风控要求二次验证时返回的决策ID
+	VerificationCode string `json:"verification_code" binding:"max=10"` // 二次验证码
+
+	// 扫码支付二维码图片，为空时只返回二维码内容
+	QRCodeFormat string `json:"qr_code_format" binding:"omitempty,oneof=png svg"` // 二维码图片格式
+	QRCodeSize   int    `json:"qr_code_size" binding:"omitempty,min=64,max=1024"` // 二维码边长(像素)
 }
 
 // PaymentCreateResponse 创建支付响应
 type PaymentCreateResponse struct {
-	PaymentID     uint            `json:"payment_id"`             // 支付ID
-	PaymentNo     string          `json:"payment_no"`             // 支付单号
-	PaymentMethod PaymentMethod   `json:"payment_method"`         // 支付方式
-	Amount        decimal.Decimal `json:"amount"`                 // 支付金额
-	PaymentURL    string          `json:"payment_url,omitempty"`  // 支付链接
-	QRCode        string          `json:"qr_code,omitempty"`      // 二维码内容
-	PaymentData   interface{}     `json:"payment_data,omitempty"` // 支付数据
-	ExpiredAt     time.Time       `json:"expired_at"`             // 过期时间
-	CreatedAt     time.Time       `json:"created_at"`             // 创建时间
+	PaymentID     uint            `json:"payment_id"`              // 支付ID
+	PaymentNo     string          `json:"payment_no"`              // 支付单号
+	PaymentMethod PaymentMethod   `json:"payment_method"`          // 支付方式
+	Amount        decimal.Decimal `json:"amount"`                  // 支付金额
+	PaymentURL    string          `json:"payment_url,omitempty"`   // 支付链接
+	QRCode        string          `json:"qr_code,omitempty"`       // 二维码内容
+	QRCodeImage   string          `json:"qr_code_image,omitempty"` // 二维码图片data URI，请求指定 qr_code_format 时返回
+	PaymentData   interface{}     `json:"payment_data,omitempty"`  // 支付数据
+	ExpiredAt     time.Time       `json:"expired_at"`              // 过期时间
+	CreatedAt     time.Time       `json:"created_at"`              // 创建时间
 }
 
 // PaymentQueryRequest 查询支付请求
@@ -77,17 +92,20 @@ type PaymentRefundRequest struct {
 	PaymentID    uint            `json:"payment_id" binding:"required"`            // 支付ID
 	RefundAmount decimal.Decimal `json:"refund_amount" binding:"required"`         // 退款金额
 	RefundReason string          `json:"refund_reason" binding:"required,max=512"` // 退款原因
+	RefundNo     string          `json:"refund_no" binding:"max=64"`               // 商户退款单号(可选，重复提交时保证幂等)
 }
 
 // PaymentRefundResponse 退款响应
 type PaymentRefundResponse struct {
-	RefundID     uint            `json:"refund_id"`     // 退款ID
-	RefundNo     string          `json:"refund_no"`     // 退款单号
-	PaymentID    uint            `json:"payment_id"`    // 支付ID
-	RefundAmount decimal.Decimal `json:"refund_amount"` // 退款金额
-	RefundStatus PaymentStatus   `json:"refund_status"` // 退款状态
-	RefundReason string          `json:"refund_reason"` // 退款原因
-	CreatedAt    time.Time       `json:"created_at"`    // 创建时间
+	RefundID     uint            `json:"refund_id"`      // 退款ID
+	RefundNo     string          `json:"refund_no"`      // 退款单号
+	PaymentID    uint            `json:"payment_id"`     // 支付ID
+	RefundAmount decimal.Decimal `json:"refund_amount"`  // 退款金额
+	RefundStatus PaymentStatus   `json:"refund_status"`  // 退款状态
+	RefundReason string          `json:"refund_reason"`  // 退款原因
+	ThirdPartyID string          `json:"third_party_id"` // 第三方退款单号
+	RefundedAt   *time.Time      `json:"refunded_at"`    // 退款完成时间
+	CreatedAt    time.Time       `json:"created_at"`     // 创建时间
 }
 
 // PaymentCallbackData 支付回调数据
@@ -127,18 +145,24 @@ type PaymentStatisticsResponse struct {
 
 // PaymentMethodStat 支付方式统计
 type PaymentMethodStat struct {
-	Method      PaymentMethod   `json:"method"`       // 支付方式
-	Amount      decimal.Decimal `json:"amount"`       // 金额
-	Count       int64           `json:"count"`        // 笔数
-	SuccessRate float64         `json:"success_rate"` // 成功率
+	Method        PaymentMethod   `json:"method"`          // 支付方式
+	Amount        decimal.Decimal `json:"amount"`          // 金额
+	Count         int64           `json:"count"`           // 笔数
+	SuccessRate   float64         `json:"success_rate"`    // 成功率
+	SuccessAmount decimal.Decimal `json:"success_amount"`  // 成功金额
+	RefundAmount  decimal.Decimal `json:"refund_amount"`   // 退款金额
+	AvgPaySeconds float64         `json:"avg_pay_seconds"` // 平均支付耗时(秒)，从创建到支付成功
 }
 
 // PaymentDailyStat 日统计
 type PaymentDailyStat struct {
-	Date        string          `json:"date"`         // 日期
-	Amount      decimal.Decimal `json:"amount"`       // 金额
-	Count       int64           `json:"count"`        // 笔数
-	SuccessRate float64         `json:"success_rate"` // 成功率
+	Date          string          `json:"date"`            // 日期，按月或按年分组时为 YYYY-MM 或 YYYY
+	Amount        decimal.Decimal `json:"amount"`          // 金额
+	Count         int64           `json:"count"`           // 笔数
+	SuccessRate   float64         `json:"success_rate"`    // 成功率
+	SuccessAmount decimal.Decimal `json:"success_amount"`  // 成功金额
+	RefundAmount  decimal.Decimal `json:"refund_amount"`   // 退款金额
+	AvgPaySeconds float64         `json:"avg_pay_seconds"` // 平均支付耗时(秒)
 }
 
 // PaymentConfigRequest 支付配置请求
@@ -200,5 +224,16 @@ var (
 	ErrPaymentExpired       = errors.New("支付已过期")
 	ErrPaymentAlreadyPaid   = errors.New("支付已完成")
 	ErrInsufficientAmount   = errors.New("金额不足")
+	ErrTradeNotExist        = errors.New("渠道交易不存在")
 	ErrRefundFailed         = errors.New("退款失败")
+	ErrRefundNotFound       = errors.New("退款记录不存在")
+	ErrRefundAmountExceeded = errors.New("退款金额超过可退金额")
+	ErrSyncEventNotFound    = errors.New("同步事件不存在")
+	ErrSyncEventState       = errors.New("同步事件当前状态不允许该操作")
+)
+
+// 支付二维码错误定义
+var (
+	ErrPaymentQRCodeUnavailable = errors.New("该支付没有可用的支付二维码")
+	ErrInvalidQRCodeFormat      = errors.New("不支持的二维码图片格式")
 )
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PaymentLimitPeriod 累计限额统计周期
+type PaymentLimitPeriod string
+
+const (
+	PaymentLimitDaily   PaymentLimitPeriod = "daily"   // 自然日
+	PaymentLimitMonthly PaymentLimitPeriod = "monthly" // 自然月
+)
+
+// Label 周期名称
+func (p PaymentLimitPeriod) Label() string {
+	if p == PaymentLimitMonthly {
+		return "每月"
+	}
+	return "每日"
+}
+
+// PaymentUserLimit 用户累计支付限额覆盖
+// 覆盖配置文件中的默认限额，PaymentMethod 为空表示该用户全部支付方式合计；金额或笔数为0表示不限制
+type PaymentUserLimit struct {
+	ID            uint               `gorm:"primarykey" json:"id"`
+	UserID        uint               `gorm:"not null;uniqueIndex:idx_payment_user_limit,priority:1" json:"user_id"`                // 用户ID
+	PaymentMethod PaymentMethod      `gorm:"not null;size:20;uniqueIndex:idx_payment_user_limit,priority:2" json:"payment_method"` // 支付方式，为空表示全部
+	Period        PaymentLimitPeriod `gorm:"not null;size:20;uniqueIndex:idx_payment_user_limit,priority:3" json:"period"`         // 统计周期
+	MaxAmount     decimal.Decimal    `gorm:"type:decimal(12,2);not null" json:"max_amount"`                                        // 累计金额上限
+	MaxCount      int                `gorm:"not null" json:"max_count"`                                                            // 累计笔数上限
+	ExpiresAt     *time.Time         `json:"expires_at"`                                                                           // 过期时间，为空表示长期有效
+	Reason        string             `gorm:"size:255" json:"reason"`                                                               // 调整原因
+	OperatorID    uint               `json:"operator_id"`                                                                          // 操作人
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (PaymentUserLimit) TableName() string {
+	return "payment_user_limits"
+}
+
+// IsActive 覆盖配置是否在有效期内
+func (l *PaymentUserLimit) IsActive(now time.Time) bool {
+	return l.ExpiresAt == nil || l.ExpiresAt.After(now)
+}
+
+// PaymentUserLimitRequest 设置用户累计限额请求
+type PaymentUserLimitRequest struct {
+	PaymentMethod PaymentMethod      `json:"payment_method"`                                // 支付方式，为空表示全部
+	Period        PaymentLimitPeriod `json:"period" binding:"required,oneof=daily monthly"` // 统计周期
+	MaxAmount     decimal.Decimal    `json:"max_amount"`                                    // 累计金额上限，0表示不限制
+	MaxCount      int                `json:"max_count" binding:"min=0"`                     // 累计笔数上限，0表示不限制
+	ExpiresAt     *time.Time         `json:"expires_at"`                                    // 过期时间
+	Reason        string             `json:"reason" binding:"max=255"`                      // 调整原因
+}
+
+// PaymentLimitUsage 用户累计限额使用情况
+type PaymentLimitUsage struct {
+	PaymentMethod PaymentMethod      `json:"payment_method"` // 支付方式，为空表示全部
+	Period        PaymentLimitPeriod `json:"period"`         // 统计周期
+	PeriodKey     string             `json:"period_key"`     // 周期标识，如20240101、202401
+	UsedAmount    decimal.Decimal    `json:"used_amount"`    // 已用金额（含支付中）
+	UsedCount     int64              `json:"used_count"`     // 已用笔数（含支付中）
+	MaxAmount     decimal.Decimal    `json:"max_amount"`     // 金额上限，0表示不限制
+	MaxCount      int                `json:"max_count"`      // 笔数上限，0表示不限制
+	Overridden    bool               `json:"overridden"`     // 是否为用户单独设置的限额
+}
+
+// PaymentLimitExceededError 超出累计支付限额
+type PaymentLimitExceededError struct {
+	PaymentMethod PaymentMethod      `json:"payment_method"` // 支付方式，为空表示全部
+	Period        PaymentLimitPeriod `json:"period"`         // 统计周期
+	MaxAmount     decimal.Decimal    `json:"max_amount"`     // 金额上限
+	MaxCount      int                `json:"max_count"`      // 笔数上限
+}
+
+func (e *PaymentLimitExceededError) Error() string {
+	scope := "累计"
+	if e.PaymentMethod != "" {
+		scope = string(e.PaymentMethod) + "支付"
+	}
+	return fmt.Sprintf("超出%s%s限额", e.Period.Label(), scope)
+}
+
+// 支付限额错误定义
+var (
+	ErrPaymentUserLimitNotFound = errors.New("用户限额配置不存在")
+	ErrInvalidPaymentLimit      = errors.New("限额不能为负数")
+)
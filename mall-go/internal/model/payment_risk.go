@@ -0,0 +1,183 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// RiskAction 风控处置动作
+type RiskAction string
+
+const (
+	RiskActionAllow     RiskAction = "allow"     // 放行
+	RiskActionChallenge RiskAction = "challenge" // 需要二次验证
+	RiskActionDeny      RiskAction = "deny"      // 拒绝
+)
+
+// Severity 处置动作的严重程度，用于多条规则命中时取最严格的动作
+func (a RiskAction) Severity() int {
+	switch a {
+	case RiskActionDeny:
+		return 2
+	case RiskActionChallenge:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// 风控规则类型
+const (
+	RiskRuleVelocityUser     = "velocity_user"      // 用户维度支付频率
+	RiskRuleVelocityDevice   = "velocity_device"    // 设备维度支付频率
+	RiskRuleVelocityIP       = "velocity_ip"        // IP维度支付频率
+	RiskRuleFirstOrderAmount = "first_order_amount" // 首单大额
+	RiskRuleAddressMismatch  = "address_mismatch"   // 收货地址与地址簿不符
+	RiskRulePasswordChanged  = "password_changed"   // 近期修改过密码
+	RiskRuleBlacklist        = "blacklist"          // 黑名单
+)
+
+// PaymentRiskRule 支付风控规则
+// 规则存储在数据库中，修改后风控引擎定时重新加载，无需重新部署
+type PaymentRiskRule struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	Code      string     `gorm:"uniqueIndex;not null;size:50" json:"code"` // 规则编码
+	Name      string     `gorm:"not null;size:100" json:"name"`            // 规则名称
+	RuleType  string     `gorm:"not null;size:30" json:"rule_type"`        // 规则类型
+	Params    string     `gorm:"type:text" json:"params"`                  // 规则参数(JSON)
+	Action    RiskAction `gorm:"not null;size:20" json:"action"`           // 命中后的处置动作，allow表示仅计分
+	Score     int        `gorm:"not null" json:"score"`                    // 命中后累计的风险分
+	Priority  int        `gorm:"not null" json:"priority"`                 // 执行顺序，越小越先执行
+	IsEnabled bool       `gorm:"not null;index" json:"is_enabled"`         // 是否启用
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (PaymentRiskRule) TableName() string {
+	return "payment_risk_rules"
+}
+
+// 风控黑名单类型
+const (
+	RiskBlacklistUser   = "user"   // 用户ID
+	RiskBlacklistIP     = "ip"     // 客户端IP
+	RiskBlacklistDevice = "device" // 设备ID
+	RiskBlacklistPhone  = "phone"  // 收货手机号
+)
+
+// PaymentRiskBlacklist 支付风控黑名单
+type PaymentRiskBlacklist struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	ListType   string     `gorm:"not null;size:20;uniqueIndex:idx_risk_blacklist_value,priority:1" json:"list_type"` // 名单类型
+	Value      string     `gorm:"not null;size:100;uniqueIndex:idx_risk_blacklist_value,priority:2" json:"value"`    // 名单值
+	Reason     string     `gorm:"size:255" json:"reason"`                                                            // 加入原因
+	ExpiresAt  *time.Time `json:"expires_at"`                                                                        // 过期时间，为空表示永久
+	OperatorID uint       `json:"operator_id"`                                                                       // 操作人
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (PaymentRiskBlacklist) TableName() string {
+	return "payment_risk_blacklists"
+}
+
+// 风控二次验证状态
+const (
+	RiskChallengeNone    = ""        // 无需验证
+	RiskChallengePending = "pending" // 等待验证
+	RiskChallengePassed  = "passed"  // 验证通过
+	RiskChallengeFailed  = "failed"  // 验证失败次数过多
+)
+
+// PaymentRiskDecision 支付风控决策记录
+type PaymentRiskDecision struct {
+	ID            uint            `gorm:"primarykey" json:"id"`
+	UserID        uint            `gorm:"index:idx_risk_decision_user,priority:1" json:"user_id"` // 用户ID
+	OrderID       uint            `gorm:"index" json:"order_id"`                                  // 订单ID
+	PaymentID     uint            `gorm:"index" json:"payment_id"`                                // 放行后创建的支付ID
+	PaymentMethod PaymentMethod   `gorm:"size:20" json:"payment_method"`                          // 支付方式
+	Amount        decimal.Decimal `gorm:"type:decimal(10,2)" json:"amount"`                       // 结算币种金额
+	ClientIP      string          `gorm:"size:45;index:idx_risk_decision_ip,priority:1" json:"client_ip"`
+	DeviceID      string          `gorm:"size:100;index:idx_risk_decision_device,priority:1" json:"device_id"`
+
+	Action   RiskAction `gorm:"not null;size:20;index" json:"action"` // 处置动作
+	Score    int        `json:"score"`                                // 风险分
+	HitRules string     `gorm:"type:text" json:"hit_rules"`           // 命中规则(JSON)
+
+	ChallengeStatus   string     `gorm:"size:20" json:"challenge_status"`  // 二次验证状态
+	ChallengeChannel  string     `gorm:"size:20" json:"challenge_channel"` // 验证渠道 sms/email
+	ChallengeAttempts int        `json:"challenge_attempts"`               // 验证尝试次数
+	VerifiedAt        *time.Time `json:"verified_at"`                      // 验证通过时间
+
+	CreatedAt time.Time `gorm:"index:idx_risk_decision_user,priority:2;index:idx_risk_decision_ip,priority:2;index:idx_risk_decision_device,priority:2" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (PaymentRiskDecision) TableName() string {
+	return "payment_risk_decisions"
+}
+
+// RiskRuleHit 命中的风控规则
+type RiskRuleHit struct {
+	Code   string     `json:"code"`   // 规则编码
+	Name   string     `json:"name"`   // 规则名称
+	Action RiskAction `json:"action"` // 规则处置动作
+	Score  int        `json:"score"`  // 规则风险分
+	Detail string     `json:"detail"` // 命中说明
+}
+
+// PaymentRiskRuleRequest 风控规则维护请求
+type PaymentRiskRuleRequest struct {
+	Code      string     `json:"code" binding:"required,max=50"`                       // 规则编码
+	Name      string     `json:"name" binding:"required,max=100"`                      // 规则名称
+	RuleType  string     `json:"rule_type" binding:"required"`                         // 规则类型
+	Params    string     `json:"params"`                                               // 规则参数(JSON)
+	Action    RiskAction `json:"action" binding:"required,oneof=allow challenge deny"` // 处置动作
+	Score     int        `json:"score" binding:"min=0"`                                // 风险分
+	Priority  int        `json:"priority"`                                             // 执行顺序
+	IsEnabled *bool      `json:"is_enabled"`                                           // 是否启用
+}
+
+// PaymentRiskBlacklistRequest 添加黑名单请求
+type PaymentRiskBlacklistRequest struct {
+	ListType  string     `json:"list_type" binding:"required,oneof=user ip device phone"` // 名单类型
+	Value     string     `json:"value" binding:"required,max=100"`                        // 名单值
+	Reason    string     `json:"reason" binding:"max=255"`                                // 加入原因
+	ExpiresAt *time.Time `json:"expires_at"`                                              // 过期时间
+}
+
+// PaymentRiskDecisionQuery 风控决策查询参数
+type PaymentRiskDecisionQuery struct {
+	Action   RiskAction `form:"action"`    // 处置动作
+	UserID   uint       `form:"user_id"`   // 用户ID
+	OrderID  uint       `form:"order_id"`  // 订单ID
+	Page     int        `form:"page"`      // 页码
+	PageSize int        `form:"page_size"` // 每页数量
+}
+
+// PaymentRiskChallengeError 支付需要二次验证
+// 客户端完成验证后携带 risk_decision_id 和 verification_code 重新发起支付
+type PaymentRiskChallengeError struct {
+	DecisionID uint   `json:"risk_decision_id"` // 风控决策ID
+	Channel    string `json:"channel"`          // 验证码发送渠道 sms/email
+	Target     string `json:"target"`           // 脱敏后的接收方
+}
+
+func (e *PaymentRiskChallengeError) Error() string {
+	return "支付需要进行安全验证"
+}
+
+// 支付风控错误定义
+var (
+	ErrPaymentRiskDenied       = errors.New("支付存在风险，已被拒绝")
+	ErrRiskChallengeInvalid    = errors.New("安全验证无效或已过期")
+	ErrRiskChallengeFailed     = errors.New("验证码错误")
+	ErrRiskRuleTypeUnsupported = errors.New("不支持的风控规则类型")
+	ErrRiskRuleNotFound        = errors.New("风控规则不存在")
+)
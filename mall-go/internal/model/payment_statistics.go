@@ -0,0 +1,56 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PaymentDailyRollup 按日、按支付方式汇总的支付统计
+// 由汇总任务定期重算写入，统计接口按日期范围累加汇总行，不再扫描支付表；金额均为结算币种
+type PaymentDailyRollup struct {
+	ID            uint          `gorm:"primarykey" json:"id"`
+	Date          string        `gorm:"not null;size:10;uniqueIndex:idx_payment_rollup_date_method,priority:1" json:"date"`           // 日期(YYYY-MM-DD)
+	PaymentMethod PaymentMethod `gorm:"not null;size:20;uniqueIndex:idx_payment_rollup_date_method,priority:2" json:"payment_method"` // 支付方式
+
+	TotalCount    int64           `gorm:"not null" json:"total_count"`                       // 当日创建的支付笔数
+	TotalAmount   decimal.Decimal `gorm:"type:decimal(14,2);not null" json:"total_amount"`   // 当日创建的支付金额
+	SuccessCount  int64           `gorm:"not null" json:"success_count"`                     // 其中支付成功笔数（含之后退款的）
+	SuccessAmount decimal.Decimal `gorm:"type:decimal(14,2);not null" json:"success_amount"` // 其中支付成功金额
+	FailedCount   int64           `gorm:"not null" json:"failed_count"`                      // 其中支付失败笔数
+	RefundCount   int64           `gorm:"not null" json:"refund_count"`                      // 当日退款成功笔数
+	RefundAmount  decimal.Decimal `gorm:"type:decimal(14,2);not null" json:"refund_amount"`  // 当日退款成功金额
+
+	PaySeconds int64 `gorm:"not null" json:"pay_seconds"` // 有支付时间的成功支付，从创建到支付的累计秒数
+	PayTimed   int64 `gorm:"not null" json:"pay_timed"`   // 有支付时间的成功支付笔数，用于计算平均支付耗时
+
+	RolledUpAt time.Time `json:"rolled_up_at"` // 最近一次汇总时间
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (PaymentDailyRollup) TableName() string {
+	return "payment_daily_rollups"
+}
+
+// PaymentStatisticsBackfillRequest 补算历史支付统计请求
+type PaymentStatisticsBackfillRequest struct {
+	StartDate string `json:"start_date" binding:"required"` // 开始日期(YYYY-MM-DD)
+	EndDate   string `json:"end_date" binding:"required"`   // 结束日期(YYYY-MM-DD)
+}
+
+// PaymentStatisticsBackfillResponse 补算历史支付统计结果
+type PaymentStatisticsBackfillResponse struct {
+	Days int `json:"days"` // 重算的天数
+	Rows int `json:"rows"` // 写入的汇总行数
+}
+
+// 支付统计错误定义
+var (
+	ErrInvalidStatisticsDate       = errors.New("统计日期格式错误，应为YYYY-MM-DD")
+	ErrInvalidStatisticsRange      = errors.New("统计开始日期不能晚于结束日期")
+	ErrStatisticsRangeTooLarge     = errors.New("补算日期范围过大")
+	ErrStatisticsFilterUnsupported = errors.New("汇总统计不支持按用户或支付状态筛选")
+)
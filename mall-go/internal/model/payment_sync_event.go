@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// PaymentSyncEventStatus 支付同步事件状态
+type PaymentSyncEventStatus string
+
+const (
+	PaymentSyncEventPending    PaymentSyncEventStatus = "pending"    // 待处理（含等待重试）
+	PaymentSyncEventProcessing PaymentSyncEventStatus = "processing" // 处理中（已被工作协程租用）
+	PaymentSyncEventSucceeded  PaymentSyncEventStatus = "succeeded"  // 处理成功
+	PaymentSyncEventDead       PaymentSyncEventStatus = "dead"       // 死信（超过最大尝试次数）
+	PaymentSyncEventDiscarded  PaymentSyncEventStatus = "discarded"  // 已丢弃（管理员确认无需处理）
+)
+
+// PaymentSyncEvent 支付同步事件
+// 支付状态变化后需要同步到订单、库存的事件，持久化后由工作协程租用处理，保证进程重启不丢失
+type PaymentSyncEvent struct {
+	ID        uint   `gorm:"primarykey" json:"id"`
+	EventID   string `gorm:"uniqueIndex;not null;size:64" json:"event_id"` // 事件ID
+	EventType string `gorm:"not null;size:32;index" json:"event_type"`     // 事件类型
+	PaymentID uint   `gorm:"not null;index" json:"payment_id"`             // 关联支付ID
+	OrderID   uint   `gorm:"not null;index" json:"order_id"`               // 关联订单ID
+	UserID    uint   `gorm:"index" json:"user_id"`                         // 用户ID
+	Payload   string `gorm:"type:text" json:"payload"`                     // 事件数据(JSON)
+
+	// 处理状态
+	Status      PaymentSyncEventStatus `gorm:"not null;size:20;index:idx_payment_sync_due,priority:1" json:"status"` // 事件状态
+	Attempts    int                    `gorm:"not null;default:0" json:"attempts"`                                   // 已尝试次数
+	MaxAttempts int                    `gorm:"not null;default:5" json:"max_attempts"`                               // 最大尝试次数
+	NextRunAt   time.Time              `gorm:"not null;index:idx_payment_sync_due,priority:2" json:"next_run_at"`    // 下次执行时间
+	LockedBy    string                 `gorm:"size:64" json:"locked_by"`                                             // 租用者
+	LockedUntil *time.Time             `json:"locked_until"`                                                         // 租约到期时间
+	LastError   string                 `gorm:"type:text" json:"last_error"`                                          // 最近一次错误
+	ProcessedAt *time.Time             `json:"processed_at"`                                                         // 处理完成时间
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (PaymentSyncEvent) TableName() string {
+	return "payment_sync_events"
+}
+
+// PaymentSyncEventQuery 同步事件查询参数
+type PaymentSyncEventQuery struct {
+	Status    PaymentSyncEventStatus `form:"status"`     // 事件状态
+	EventType string                 `form:"event_type"` // 事件类型
+	PaymentID uint                   `form:"payment_id"` // 支付ID
+	OrderID   uint                   `form:"order_id"`   // 订单ID
+	Page      int                    `form:"page"`       // 页码
+	PageSize  int                    `form:"page_size"`  // 每页数量
+}
@@ -0,0 +1,146 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CustomerGroup 客户分组，如批发商、企业客户，分组成员按分组的价目表定价
+type CustomerGroup struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Code        string    `gorm:"size:50;not null;uniqueIndex" json:"code"`
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	IsActive    bool      `gorm:"not null" json:"is_active"` // 停用后分组的价目表不再生效
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (CustomerGroup) TableName() string {
+	return "customer_groups"
+}
+
+// CustomerGroupMember 客户分组成员，一个用户可属于多个分组
+type CustomerGroupMember struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	GroupID   uint      `gorm:"not null;uniqueIndex:idx_customer_group_member,priority:1" json:"group_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_customer_group_member,priority:2;index" json:"user_id"`
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (CustomerGroupMember) TableName() string {
+	return "customer_group_members"
+}
+
+// PriceAdjustmentType 价目表定价方式
+type PriceAdjustmentType string
+
+const (
+	PriceAdjustmentFixed      PriceAdjustmentType = "fixed"      // 固定价，按价目表币种
+	PriceAdjustmentPercentage PriceAdjustmentType = "percentage" // 在公开售价上按百分比调整，如-15为降价15%
+)
+
+// PriceList 分组价目表，在有效期内对分组成员生效
+type PriceList struct {
+	ID        uint            `gorm:"primarykey" json:"id"`
+	GroupID   uint            `gorm:"not null;index" json:"group_id"`
+	Name      string          `gorm:"size:100;not null" json:"name"`
+	Currency  string          `gorm:"size:3;not null" json:"currency"` // 固定价的币种，非结算币种时按当前汇率换算
+	StartAt   *time.Time      `gorm:"index" json:"start_at"`           // 为空时立即生效
+	EndAt     *time.Time      `gorm:"index" json:"end_at"`             // 为空时长期有效
+	IsActive  bool            `gorm:"not null" json:"is_active"`
+	CreatedBy uint            `json:"created_by"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Items     []PriceListItem `gorm:"foreignKey:PriceListID" json:"items,omitempty"`
+}
+
+// TableName 指定表名
+func (PriceList) TableName() string {
+	return "price_lists"
+}
+
+// PriceListItem 价目表条目，同一商品或SKU按起订数量分档，如1、10、100对应1-9、10-99、100以上
+type PriceListItem struct {
+	ID          uint                `gorm:"primarykey" json:"id"`
+	PriceListID uint                `gorm:"not null;index" json:"price_list_id"`
+	ProductID   uint                `gorm:"not null;index" json:"product_id"`
+	SKUID       uint                `gorm:"column:sku_id;not null;default:0" json:"sku_id"` // 为0时适用于商品及其全部规格，规格条目优先
+	MinQuantity int                 `gorm:"not null" json:"min_quantity"`                   // 数量档位下限
+	Type        PriceAdjustmentType `gorm:"size:20;not null" json:"type"`
+	Value       decimal.Decimal     `gorm:"type:decimal(12,4);not null" json:"value"` // 固定价或调整百分比
+}
+
+// TableName 指定表名
+func (PriceListItem) TableName() string {
+	return "price_list_items"
+}
+
+// CustomerGroupRequest 创建或更新客户分组请求
+type CustomerGroupRequest struct {
+	Code        string `json:"code" binding:"required,max=50"`
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=255"`
+	IsActive    bool   `json:"is_active"`
+}
+
+// CustomerGroupMembersRequest 添加分组成员请求
+type CustomerGroupMembersRequest struct {
+	UserIDs []uint `json:"user_ids" binding:"required,min=1,max=500"`
+}
+
+// PriceListRequest 创建或更新价目表请求，条目整体替换
+type PriceListRequest struct {
+	GroupID  uint                   `json:"group_id" binding:"required"`
+	Name     string                 `json:"name" binding:"required,max=100"`
+	Currency string                 `json:"currency"` // 为空时为结算币种
+	StartAt  *time.Time             `json:"start_at"`
+	EndAt    *time.Time             `json:"end_at"`
+	IsActive bool                   `json:"is_active"`
+	Items    []PriceListItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+// PriceListItemRequest 价目表条目
+type PriceListItemRequest struct {
+	ProductID   uint                `json:"product_id" binding:"required"`
+	SKUID       uint                `json:"sku_id"`
+	MinQuantity int                 `json:"min_quantity"` // 为空时为1
+	Type        PriceAdjustmentType `json:"type" binding:"required,oneof=fixed percentage"`
+	Value       decimal.Decimal     `json:"value"`
+}
+
+// PriceListQuery 价目表查询
+type PriceListQuery struct {
+	GroupID  uint `form:"group_id"`
+	Page     int  `form:"page"`
+	PageSize int  `form:"page_size"`
+}
+
+// CustomerPrice 用户适用的分组价，按结算币种
+type CustomerPrice struct {
+	PriceListID   uint                `json:"price_list_id"`
+	PriceListName string              `json:"price_list_name"`
+	BasePrice     decimal.Decimal     `json:"base_price"` // 公开售价
+	Price         decimal.Decimal     `json:"price"`
+	Tiers         []CustomerPriceTier `json:"tiers,omitempty"` // 数量阶梯价
+}
+
+// CustomerPriceTier 数量阶梯价，购买数量达到 MinQuantity 时的单价
+type CustomerPriceTier struct {
+	MinQuantity int             `json:"min_quantity"`
+	Price       decimal.Decimal `json:"price"`
+	PriceListID uint            `json:"price_list_id"`
+}
+
+// 分组价相关错误
+var (
+	ErrCustomerGroupNotFound  = errors.New("客户分组不存在")
+	ErrCustomerGroupDuplicate = errors.New("客户分组编码已存在")
+	ErrPriceListNotFound      = errors.New("价目表不存在")
+	ErrInvalidPriceList       = errors.New("价目表配置无效")
+)
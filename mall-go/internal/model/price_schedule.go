@@ -0,0 +1,116 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PriceScheduleStatus 定时调价状态
+type PriceScheduleStatus string
+
+const (
+	PriceSchedulePending   PriceScheduleStatus = "pending"   // 待生效
+	PriceScheduleActive    PriceScheduleStatus = "active"    // 限时价生效中
+	PriceScheduleCompleted PriceScheduleStatus = "completed" // 已完成：永久调价已生效或限时价已恢复
+	PriceScheduleCancelled PriceScheduleStatus = "cancelled" // 已取消
+)
+
+// PriceSchedule 定时调价
+// EndAt 为空时为永久调价，到点改价后即完成；否则为限时价，结束时恢复为生效前的价格
+type PriceSchedule struct {
+	ID            uint                `gorm:"primarykey" json:"id"`
+	ProductID     uint                `gorm:"not null;index" json:"product_id"`
+	SKUID         uint                `gorm:"column:sku_id;not null;default:0;index" json:"sku_id"` // 为0时调整商品价格
+	Price         decimal.Decimal     `gorm:"type:decimal(10,2);not null" json:"price"`
+	OriginalPrice decimal.Decimal     `gorm:"type:decimal(10,2)" json:"original_price"` // 生效前的价格，限时价结束时恢复
+	StartAt       time.Time           `gorm:"not null;index" json:"start_at"`
+	EndAt         *time.Time          `gorm:"index" json:"end_at"`
+	Status        PriceScheduleStatus `gorm:"size:20;not null;index" json:"status"`
+	Reason        string              `gorm:"size:255" json:"reason"`
+	CreatedBy     uint                `json:"created_by"`
+	CancelledBy   uint                `json:"cancelled_by"`
+	ActivatedAt   *time.Time          `json:"activated_at"`
+	FinishedAt    *time.Time          `json:"finished_at"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (PriceSchedule) TableName() string {
+	return "price_schedules"
+}
+
+// PriceChangeSource 价格变更来源
+type PriceChangeSource string
+
+const (
+	PriceChangeManual        PriceChangeSource = "manual"         // 后台直接改价
+	PriceChangeSchedule      PriceChangeSource = "schedule"       // 定时调价生效
+	PriceChangeScheduleEnd   PriceChangeSource = "schedule_end"   // 限时价结束恢复
+	PriceChangeScheduleAbort PriceChangeSource = "schedule_abort" // 限时价提前取消恢复
+)
+
+// PriceHistory 价格变更记录，商品和SKU的每次改价都会记录
+type PriceHistory struct {
+	ID         uint              `gorm:"primarykey" json:"id"`
+	ProductID  uint              `gorm:"not null;index:idx_price_history_target" json:"product_id"`
+	SKUID      uint              `gorm:"column:sku_id;not null;default:0;index:idx_price_history_target" json:"sku_id"`
+	OldPrice   decimal.Decimal   `gorm:"type:decimal(10,2);not null" json:"old_price"`
+	NewPrice   decimal.Decimal   `gorm:"type:decimal(10,2);not null" json:"new_price"`
+	Source     PriceChangeSource `gorm:"size:20;not null" json:"source"`
+	ScheduleID uint              `gorm:"index" json:"schedule_id"`
+	OperatorID uint              `json:"operator_id"` // 定时任务自动改价时为创建调价的管理员
+	Reason     string            `gorm:"size:255" json:"reason"`
+	CreatedAt  time.Time         `gorm:"index:idx_price_history_target" json:"created_at"`
+}
+
+// TableName 指定表名
+func (PriceHistory) TableName() string {
+	return "price_histories"
+}
+
+// PriceScheduleRequest 创建定时调价请求
+type PriceScheduleRequest struct {
+	ProductID uint            `json:"product_id" binding:"required"`
+	SKUID     uint            `json:"sku_id"`
+	Price     decimal.Decimal `json:"price" binding:"required"`
+	StartAt   time.Time       `json:"start_at" binding:"required"`
+	EndAt     *time.Time      `json:"end_at"`
+	Reason    string          `json:"reason" binding:"max=255"`
+}
+
+// PriceScheduleQuery 定时调价查询
+type PriceScheduleQuery struct {
+	ProductID uint   `form:"product_id"`
+	Status    string `form:"status"`
+	Page      int    `form:"page"`
+	PageSize  int    `form:"page_size"`
+}
+
+// PriceHistoryQuery 价格变更记录查询
+type PriceHistoryQuery struct {
+	SKUID    uint `form:"sku_id"`
+	Page     int  `form:"page"`
+	PageSize int  `form:"page_size"`
+}
+
+// LowestPriceView 当前价格生效前的近期最低价，用于划线原价等价格宣传的合规校验
+type LowestPriceView struct {
+	ProductID    uint            `json:"product_id"`
+	SKUID        uint            `json:"sku_id"`
+	Days         int             `json:"days"`
+	CurrentPrice decimal.Decimal `json:"current_price"`
+	LowestPrice  decimal.Decimal `json:"lowest_price"` // 当前价格生效前统计期内的最低价格
+	Since        *time.Time      `json:"since"`        // 统计期开始时间，无变更记录时为空
+}
+
+// 定时调价相关错误
+var (
+	ErrPriceScheduleNotFound = errors.New("定时调价不存在")
+	ErrInvalidPriceSchedule  = errors.New("定时调价参数无效")
+	ErrPriceScheduleOverlap  = errors.New("与已有的限时价时间重叠")
+	ErrPriceScheduleFinished = errors.New("定时调价已结束，无法取消")
+	ErrPriceTargetNotFound   = errors.New("调价的商品或规格不存在")
+)
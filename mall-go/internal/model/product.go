@@ -238,6 +238,17 @@ type ProductListRequest struct {
 	CategoryID *uint  `form:"category_id"`
 	Keyword    string `form:"keyword"`
 	Status     string `form:"status"`
+	Currency   string `form:"currency" binding:"omitempty,len=3"` // 展示币种
+}
+
+// ProductPriceView 带展示币种价格或客户分组价的商品
+type ProductPriceView struct {
+	Product
+	DisplayCurrency    string          `json:"display_currency"`         // 展示币种
+	DisplayPrice       decimal.Decimal `json:"display_price"`            // 展示币种售价
+	DisplayOriginPrice decimal.Decimal `json:"display_origin_price"`     // 展示币种原价
+	ExchangeRate       decimal.Decimal `json:"exchange_rate"`            // 换算汇率
+	CustomerPrice      *CustomerPrice  `json:"customer_price,omitempty"` // 登录用户适用的分组价，此时 Price 为分组价
 }
 
 // TableName 方法
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ProductBundle 商品的"经常一起买"搭配，由订单频繁项集挖掘生成，每个商品一条
+type ProductBundle struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	ProductID  uint      `gorm:"not null;uniqueIndex" json:"product_id"`
+	ItemIDs    string    `gorm:"type:json" json:"item_ids"`  // 搭配商品ID列表（JSON数组），不含商品本身
+	Support    int       `gorm:"not null" json:"support"`    // 同时购买整组商品的订单数
+	Confidence float64   `gorm:"not null" json:"confidence"` // 购买该商品的订单中同时购买搭配商品的比例
+	Lift       float64   `json:"lift"`                       // 提升度，大于1表示搭配购买多于随机
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ProductBundle) TableName() string {
+	return "product_bundles"
+}
+
+// ItemIDList 解析搭配商品ID列表
+func (b *ProductBundle) ItemIDList() []uint {
+	var ids []uint
+	if b.ItemIDs == "" {
+		return ids
+	}
+	_ = json.Unmarshal([]byte(b.ItemIDs), &ids)
+	return ids
+}
+
+// SetItemIDs 设置搭配商品ID列表
+func (b *ProductBundle) SetItemIDs(ids []uint) {
+	data, _ := json.Marshal(ids)
+	b.ItemIDs = string(data)
+}
+
+// BundleToCartRequest 搭配一键加购请求，SKUIDs 按商品ID指定规格，未指定的商品按商品加购
+type BundleToCartRequest struct {
+	SKUIDs map[uint]uint `json:"sku_ids"`
+}
+
+// 搭配购买相关错误
+var (
+	ErrBundleNotFound    = errors.New("暂无搭配推荐")
+	ErrBundleUnavailable = errors.New("搭配商品已下架或库存不足")
+)
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// ProductSimilarity 商品相似度，由协同过滤任务按共同购买和共同浏览计算，每个商品保留前K个相似商品
+type ProductSimilarity struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	ProductID  uint      `gorm:"not null;uniqueIndex:idx_product_similarity" json:"product_id"`
+	NeighborID uint      `gorm:"not null;uniqueIndex:idx_product_similarity" json:"neighbor_id"` // 相似商品ID
+	Score      float64   `gorm:"not null" json:"score"`                                          // 综合相似度，0-1
+	CoPurchase int       `json:"co_purchase"`                                                    // 同一订单中共同购买次数
+	CoView     int       `json:"co_view"`                                                        // 同一用户共同浏览、点击次数
+	Rank       int       `gorm:"not null" json:"rank"`                                           // 在该商品相似列表中的排名，从1开始
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ProductSimilarity) TableName() string {
+	return "product_similarities"
+}
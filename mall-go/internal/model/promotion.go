@@ -0,0 +1,162 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PromotionType 促销类型
+type PromotionType string
+
+const (
+	PromotionFullReduction PromotionType = "full_reduction" // 满减：满 Threshold 减 Discount，Repeatable 时每满减
+	PromotionPercentage    PromotionType = "percentage"     // 折扣：满 Threshold 按 Discount 比例优惠，MaxDiscount 封顶
+	PromotionNForM         PromotionType = "n_for_m"        // N件M元：每买 BuyQuantity 件只付 PayQuantity 件
+	PromotionSecondHalf    PromotionType = "second_half"    // 第二件半价：每两件中的第二件按 Discount 比例优惠，默认5折
+	PromotionBundlePrice   PromotionType = "bundle_price"   // 任选打包价：任选 BuyQuantity 件共 Price 元
+	PromotionFreeGift      PromotionType = "free_gift"      // 满赠：满 Threshold 赠送商品
+	PromotionLimitedPrice  PromotionType = "limited_price"  // 限时价：活动期间单价为 Price
+)
+
+// Valid 是否为支持的促销类型
+func (t PromotionType) Valid() bool {
+	switch t {
+	case PromotionFullReduction, PromotionPercentage, PromotionNForM, PromotionSecondHalf,
+		PromotionBundlePrice, PromotionFreeGift, PromotionLimitedPrice:
+		return true
+	}
+	return false
+}
+
+// PromotionScope 促销适用范围
+type PromotionScope string
+
+const (
+	PromotionScopeAll      PromotionScope = "all"      // 全场
+	PromotionScopeProduct  PromotionScope = "product"  // 指定商品
+	PromotionScopeCategory PromotionScope = "category" // 指定分类
+	PromotionScopeBrand    PromotionScope = "brand"    // 指定品牌
+	PromotionScopeMerchant PromotionScope = "merchant" // 指定商家
+)
+
+// Valid 是否为支持的适用范围
+func (s PromotionScope) Valid() bool {
+	switch s {
+	case PromotionScopeAll, PromotionScopeProduct, PromotionScopeCategory, PromotionScopeBrand, PromotionScopeMerchant:
+		return true
+	}
+	return false
+}
+
+// PromotionStatus 促销状态
+type PromotionStatus string
+
+const (
+	PromotionStatusActive   PromotionStatus = "active"   // 启用
+	PromotionStatusInactive PromotionStatus = "inactive" // 停用
+)
+
+// Promotion 促销规则
+// 规则按 Priority 从高到低依次计算；Exclusive 的规则与其他规则不可叠加，
+// 同一 StackGroup 内的规则对同一商品只生效一个，不同分组之间可以叠加。
+type Promotion struct {
+	ID            uint            `gorm:"primarykey" json:"id"`
+	Name          string          `gorm:"not null;size:100" json:"name"`                    // 活动名称
+	Description   string          `gorm:"size:500" json:"description"`                      // 活动说明
+	Type          PromotionType   `gorm:"not null;size:20;index" json:"type"`               // 促销类型
+	ScopeType     PromotionScope  `gorm:"not null;size:20" json:"scope_type"`               // 适用范围
+	ScopeIDs      string          `gorm:"type:json" json:"scope_ids"`                       // 范围ID列表（JSON数组），全场时为空
+	Threshold     decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"threshold"`    // 门槛金额
+	Discount      decimal.Decimal `gorm:"type:decimal(10,4);default:0" json:"discount"`     // 优惠金额或优惠比例
+	MaxDiscount   decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"max_discount"` // 最高优惠金额，0为不限
+	Repeatable    bool            `json:"repeatable"`                                       // 满减是否每满减
+	BuyQuantity   int             `json:"buy_quantity"`                                     // N件M元、打包价的件数
+	PayQuantity   int             `json:"pay_quantity"`                                     // N件M元中付费的件数
+	Price         decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"price"`        // 打包价或限时价
+	GiftProductID uint            `json:"gift_product_id"`                                  // 赠品商品ID
+	GiftSKUID     uint            `json:"gift_sku_id"`                                      // 赠品SKU ID
+	GiftQuantity  int             `json:"gift_quantity"`                                    // 赠品数量
+	Priority      int             `gorm:"default:0;index" json:"priority"`                  // 优先级，越大越先计算
+	Exclusive     bool            `json:"exclusive"`                                        // 是否与其他促销互斥
+	StackGroup    string          `gorm:"size:50" json:"stack_group"`                       // 叠加分组
+	StartAt       time.Time       `gorm:"not null;index" json:"start_at"`                   // 开始时间
+	EndAt         time.Time       `gorm:"not null;index" json:"end_at"`                     // 结束时间
+	Status        PromotionStatus `gorm:"not null;size:20;index" json:"status"`             // 状态
+	CreatedBy     uint            `json:"created_by"`                                       // 创建人
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Promotion) TableName() string {
+	return "promotions"
+}
+
+// ScopeIDList 解析适用范围ID列表
+func (p *Promotion) ScopeIDList() []uint {
+	var ids []uint
+	if p.ScopeIDs == "" {
+		return ids
+	}
+	_ = json.Unmarshal([]byte(p.ScopeIDs), &ids)
+	return ids
+}
+
+// SetScopeIDs 设置适用范围ID列表
+func (p *Promotion) SetScopeIDs(ids []uint) {
+	if len(ids) == 0 {
+		p.ScopeIDs = ""
+		return
+	}
+	data, _ := json.Marshal(ids)
+	p.ScopeIDs = string(data)
+}
+
+// PromotionRequest 创建或更新促销请求
+type PromotionRequest struct {
+	Name          string          `json:"name" binding:"required,max=100"` // 活动名称
+	Description   string          `json:"description" binding:"max=500"`   // 活动说明
+	Type          PromotionType   `json:"type" binding:"required"`         // 促销类型
+	ScopeType     PromotionScope  `json:"scope_type"`                      // 适用范围，默认全场
+	ScopeIDs      []uint          `json:"scope_ids"`                       // 范围ID列表
+	Threshold     decimal.Decimal `json:"threshold"`                       // 门槛金额
+	Discount      decimal.Decimal `json:"discount"`                        // 优惠金额或优惠比例
+	MaxDiscount   decimal.Decimal `json:"max_discount"`                    // 最高优惠金额
+	Repeatable    bool            `json:"repeatable"`                      // 满减是否每满减
+	BuyQuantity   int             `json:"buy_quantity"`                    // 件数
+	PayQuantity   int             `json:"pay_quantity"`                    // 付费件数
+	Price         decimal.Decimal `json:"price"`                           // 打包价或限时价
+	GiftProductID uint            `json:"gift_product_id"`                 // 赠品商品ID
+	GiftSKUID     uint            `json:"gift_sku_id"`                     // 赠品SKU ID
+	GiftQuantity  int             `json:"gift_quantity"`                   // 赠品数量
+	Priority      int             `json:"priority"`                        // 优先级
+	Exclusive     bool            `json:"exclusive"`                       // 是否互斥
+	StackGroup    string          `json:"stack_group" binding:"max=50"`    // 叠加分组
+	StartAt       time.Time       `json:"start_at" binding:"required"`     // 开始时间
+	EndAt         time.Time       `json:"end_at" binding:"required"`       // 结束时间
+	Status        PromotionStatus `json:"status"`                          // 状态，默认启用
+}
+
+// PromotionQuery 促销查询条件
+type PromotionQuery struct {
+	Type     PromotionType   `form:"type"`      // 促销类型
+	Status   PromotionStatus `form:"status"`    // 状态
+	Running  bool            `form:"running"`   // 仅查询当前生效中的促销
+	Page     int             `form:"page"`      // 页码
+	PageSize int             `form:"page_size"` // 每页数量
+}
+
+// UpdatePromotionStatusRequest 启用或停用促销请求
+type UpdatePromotionStatusRequest struct {
+	Status PromotionStatus `json:"status" binding:"required,oneof=active inactive"`
+}
+
+// 促销相关错误
+var (
+	ErrPromotionNotFound    = errors.New("促销活动不存在")
+	ErrInvalidPromotion     = errors.New("促销规则配置无效")
+	ErrInvalidPromotionTime = errors.New("促销结束时间必须晚于开始时间")
+)
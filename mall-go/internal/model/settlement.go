@@ -0,0 +1,224 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// 佣金费率作用范围，优先级：商家 > 分类（含上级分类） > 默认
+const (
+	CommissionScopeDefault  = "default"  // 平台默认
+	CommissionScopeCategory = "category" // 按商品分类
+	CommissionScopeMerchant = "merchant" // 按商家
+)
+
+// CommissionRate 平台佣金费率
+type CommissionRate struct {
+	ID         uint            `gorm:"primarykey" json:"id"`
+	Scope      string          `gorm:"not null;size:20;uniqueIndex:idx_commission_scope,priority:1" json:"scope"` // 作用范围
+	ScopeID    uint            `gorm:"not null;uniqueIndex:idx_commission_scope,priority:2" json:"scope_id"`      // 商家ID或分类ID，默认费率为0
+	Rate       decimal.Decimal `gorm:"type:decimal(6,4);not null" json:"rate"`                                    // 佣金比例，如0.0500表示5%
+	Remark     string          `gorm:"size:255" json:"remark"`                                                    // 备注
+	OperatorID uint            `json:"operator_id"`                                                               // 操作人
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (CommissionRate) TableName() string {
+	return "commission_rates"
+}
+
+// 结算单状态
+const (
+	SettlementStatusPending       = "pending"        // 待打款
+	SettlementStatusPartiallyPaid = "partially_paid" // 部分打款
+	SettlementStatusPaid          = "paid"           // 已打款
+	SettlementStatusCarried       = "carried"        // 应结金额为负，已结转至下期
+)
+
+// SettlementStatement 商家结算单
+type SettlementStatement struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	StatementNo string    `gorm:"uniqueIndex;not null;size:32" json:"statement_no"`                     // 结算单号
+	MerchantID  uint      `gorm:"not null;index:idx_settlement_merchant,priority:1" json:"merchant_id"` // 商家ID
+	Status      string    `gorm:"not null;size:20;index" json:"status"`                                 // 结算状态
+	Currency    string    `gorm:"size:3;not null" json:"currency"`                                      // 结算币种
+	PeriodStart time.Time `json:"period_start"`                                                         // 结算周期开始
+	PeriodEnd   time.Time `gorm:"index:idx_settlement_merchant,priority:2" json:"period_end"`           // 结算周期结束（不含）
+
+	GrossAmount      decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"gross_amount"`                // 商品销售额
+	RefundAmount     decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"refund_amount"`               // 退款金额
+	ChargebackAmount decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"chargeback_amount"` // 支付争议败诉冲回金额
+	CommissionAmount decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"commission_amount"`           // 平台佣金（已扣除退款及拒付对应佣金）
+	CarryAmount      decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"carry_amount"`                // 上期结转金额（负数为待扣回）
+	NetAmount        decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"net_amount"`                  // 应结金额
+	PaidAmount       decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"paid_amount"`                 // 已打款金额
+	LineCount        int             `json:"line_count"`                                                     // 明细数量
+
+	PaidAt    *time.Time `json:"paid_at"` // 结清时间
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	Lines   []SettlementLine   `gorm:"foreignKey:StatementID" json:"lines,omitempty"`
+	Payouts []SettlementPayout `gorm:"foreignKey:StatementID" json:"payouts,omitempty"`
+}
+
+// TableName 指定表名
+func (SettlementStatement) TableName() string {
+	return "settlement_statements"
+}
+
+// RemainingAmount 待打款金额
+func (s *SettlementStatement) RemainingAmount() decimal.Decimal {
+	return s.NetAmount.Sub(s.PaidAmount)
+}
+
+// 结算明细类型
+const (
+	SettlementLineSale       = "sale"       // 销售
+	SettlementLineRefund     = "refund"     // 结算后发生的退款
+	SettlementLineCarry      = "carry"      // 上期结转
+	SettlementLineChargeback = "chargeback" // 支付争议败诉冲回
+)
+
+// SettlementLine 结算明细
+// 销售明细按订单商品项生成且只生成一次；结算后发生的退款以退款明细冲减，佣金按原费率退回；
+// 支付争议败诉后按争议金额占支付金额的比例以拒付明细冲回，佣金同样退回。
+// (order_item_id, line_type, source_id) 唯一，并发生成结算单时同一明细只能写入一次
+type SettlementLine struct {
+	ID          uint   `gorm:"primarykey" json:"id"`
+	StatementID uint   `gorm:"not null;index" json:"statement_id"`                                                // 结算单ID
+	MerchantID  uint   `gorm:"not null;index" json:"merchant_id"`                                                 // 商家ID
+	LineType    string `gorm:"not null;size:20;uniqueIndex:idx_settlement_line_item,priority:2" json:"line_type"` // 明细类型
+	OrderID     uint   `gorm:"index" json:"order_id"`                                                             // 订单ID
+	OrderNo     string `gorm:"size:32" json:"order_no"`                                                           // 订单号
+	OrderItemID uint   `gorm:"uniqueIndex:idx_settlement_line_item,priority:1" json:"order_item_id"`              // 订单商品项ID
+	ProductID   uint   `json:"product_id"`                                                                        // 商品ID
+	ProductName string `gorm:"size:255" json:"product_name"`                                                      // 商品名称
+	CategoryID  uint   `json:"category_id"`                                                                       // 分类ID
+	Quantity    int    `json:"quantity"`                                                                          // 数量
+	SourceID    uint   `gorm:"uniqueIndex:idx_settlement_line_item,priority:3" json:"source_id"`                  // 来源ID：结转明细为来源结算单ID，拒付明细为支付争议ID，退款明细为该商品项的退款冲减序号
+
+	Amount           decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"amount"`                      // 销售金额
+	RefundAmount     decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"refund_amount"`               // 退款金额
+	ChargebackAmount decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"chargeback_amount"` // 拒付冲回金额
+	CommissionRate   decimal.Decimal `gorm:"type:decimal(6,4);not null" json:"commission_rate"`              // 佣金比例
+	CommissionAmount decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"commission_amount"`           // 佣金金额
+	NetAmount        decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"net_amount"`                  // 应结金额
+
+	FinishTime *time.Time `json:"finish_time"` // 订单完成时间
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TableName 指定表名
+func (SettlementLine) TableName() string {
+	return "settlement_lines"
+}
+
+// 打款方式
+const (
+	PayoutMethodManual = "manual" // 线下打款，人工登记
+	PayoutMethodAlipay = "alipay" // 支付宝交易分账
+	PayoutMethodWechat = "wechat" // 微信支付分账
+)
+
+// 打款状态
+const (
+	PayoutStatusProcessing = "processing" // 渠道处理中
+	PayoutStatusSuccess    = "success"    // 成功
+	PayoutStatusFailed     = "failed"     // 失败
+)
+
+// SettlementPayout 结算打款记录
+type SettlementPayout struct {
+	ID             uint            `gorm:"primarykey" json:"id"`
+	PayoutNo       string          `gorm:"uniqueIndex;not null;size:40" json:"payout_no"` // 打款单号，渠道分账时作为分账请求号
+	StatementID    uint            `gorm:"not null;index" json:"statement_id"`            // 结算单ID
+	MerchantID     uint            `gorm:"not null;index" json:"merchant_id"`             // 商家ID
+	OrderID        uint            `gorm:"index" json:"order_id"`                         // 渠道分账对应的订单ID
+	Method         string          `gorm:"not null;size:20" json:"method"`                // 打款方式
+	Amount         decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"amount"`     // 打款金额
+	Status         string          `gorm:"not null;size:20" json:"status"`                // 打款状态
+	ChannelOrderNo string          `gorm:"size:64" json:"channel_order_no"`               // 渠道分账单号
+	Reference      string          `gorm:"size:100" json:"reference"`                     // 线下打款流水号
+	FailReason     string          `gorm:"size:255" json:"fail_reason"`                   // 失败原因
+	OperatorID     uint            `json:"operator_id"`                                   // 操作人
+	PaidAt         *time.Time      `json:"paid_at"`                                       // 打款时间
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (SettlementPayout) TableName() string {
+	return "settlement_payouts"
+}
+
+// MerchantSettlementAccount 商家分账收款账户
+type MerchantSettlementAccount struct {
+	ID          uint   `gorm:"primarykey" json:"id"`
+	MerchantID  uint   `gorm:"not null;uniqueIndex:idx_merchant_account,priority:1" json:"merchant_id"`    // 商家ID
+	Method      string `gorm:"not null;size:20;uniqueIndex:idx_merchant_account,priority:2" json:"method"` // 渠道 alipay/wechat
+	Account     string `gorm:"not null;size:64" json:"account"`                                            // 支付宝userId或微信商户号
+	AccountName string `gorm:"size:100" json:"account_name"`                                               // 账户名称
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (MerchantSettlementAccount) TableName() string {
+	return "merchant_settlement_accounts"
+}
+
+// CommissionRateRequest 设置佣金费率请求
+type CommissionRateRequest struct {
+	Scope   string          `json:"scope" binding:"required,oneof=default category merchant"` // 作用范围
+	ScopeID uint            `json:"scope_id"`                                                 // 商家ID或分类ID
+	Rate    decimal.Decimal `json:"rate" binding:"required"`                                  // 佣金比例
+	Remark  string          `json:"remark" binding:"max=255"`                                 // 备注
+}
+
+// GenerateSettlementRequest 生成结算单请求
+type GenerateSettlementRequest struct {
+	MerchantID uint       `json:"merchant_id"` // 商家ID，为空时为所有商家生成
+	PeriodEnd  *time.Time `json:"period_end"`  // 结算截止时间，默认当前时间
+}
+
+// SettlementPayoutRequest 登记线下打款请求
+type SettlementPayoutRequest struct {
+	Amount    decimal.Decimal `json:"amount" binding:"required"`            // 打款金额
+	Reference string          `json:"reference" binding:"required,max=100"` // 打款流水号
+}
+
+// MerchantSettlementAccountRequest 设置商家分账账户请求
+type MerchantSettlementAccountRequest struct {
+	Method      string `json:"method" binding:"required,oneof=alipay wechat"` // 渠道
+	Account     string `json:"account" binding:"required,max=64"`             // 账号
+	AccountName string `json:"account_name" binding:"max=100"`                // 账户名称
+}
+
+// SettlementStatementQuery 结算单查询参数
+type SettlementStatementQuery struct {
+	MerchantID uint   `form:"merchant_id"` // 商家ID
+	Status     string `form:"status"`      // 结算状态
+	Page       int    `form:"page"`        // 页码
+	PageSize   int    `form:"page_size"`   // 每页数量
+}
+
+// 结算错误定义
+var (
+	ErrSettlementNotFound      = errors.New("结算单不存在")
+	ErrSettlementEmpty         = errors.New("没有可结算的订单")
+	ErrInvalidCommissionRate   = errors.New("佣金比例必须在0到1之间")
+	ErrSettlementNotPayable    = errors.New("结算单当前状态不可打款")
+	ErrInvalidPayoutAmount     = errors.New("打款金额必须大于0")
+	ErrPayoutAmountExceeded    = errors.New("打款金额超过待打款金额")
+	ErrNoShareableOrders       = errors.New("没有可通过该渠道分账的订单")
+	ErrSettlementAccountNotSet = errors.New("商家未设置分账收款账户")
+	ErrProfitSharingNotEnabled = errors.New("分账渠道未启用")
+)
@@ -0,0 +1,245 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// SubscriptionIntervalUnit 订阅周期单位
+type SubscriptionIntervalUnit string
+
+const (
+	SubscriptionIntervalDay   SubscriptionIntervalUnit = "day"   // 天
+	SubscriptionIntervalWeek  SubscriptionIntervalUnit = "week"  // 周
+	SubscriptionIntervalMonth SubscriptionIntervalUnit = "month" // 月
+)
+
+// SubscriptionStockAction 周期下单时缺货的处理方式
+type SubscriptionStockAction string
+
+const (
+	SubscriptionStockSkip  SubscriptionStockAction = "skip"  // 跳过本期
+	SubscriptionStockPause SubscriptionStockAction = "pause" // 暂停订阅，等待用户恢复
+)
+
+// SubscriptionPlan 商品订阅计划
+type SubscriptionPlan struct {
+	ID               uint                     `gorm:"primarykey" json:"id"`
+	ProductID        uint                     `gorm:"not null;index" json:"product_id"`                // 商品ID
+	SKUID            uint                     `json:"sku_id"`                                          // SKU ID，为0表示商品本身
+	Name             string                   `gorm:"not null;size:100" json:"name"`                   // 计划名称，如"每月一箱"
+	IntervalUnit     SubscriptionIntervalUnit `gorm:"not null;size:10" json:"interval_unit"`           // 周期单位
+	IntervalCount    int                      `gorm:"not null" json:"interval_count"`                  // 周期数，如每2周
+	DiscountRate     decimal.Decimal          `gorm:"type:decimal(5,4);not null" json:"discount_rate"` // 订阅折扣率，0.05表示优惠5%
+	MinCycles        int                      `gorm:"not null" json:"min_cycles"`                      // 最少履约期数，未满不可取消
+	OutOfStockAction SubscriptionStockAction  `gorm:"not null;size:10" json:"out_of_stock_action"`     // 缺货处理方式
+	IsActive         bool                     `gorm:"not null" json:"is_active"`                       // 是否可订阅
+	Product          *Product                 `gorm:"foreignKey:ProductID" json:"product,omitempty"`   // 商品
+	CreatedAt        time.Time                `json:"created_at"`
+	UpdatedAt        time.Time                `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (SubscriptionPlan) TableName() string {
+	return "subscription_plans"
+}
+
+// NextRun 从 from 起经过一个周期的时间
+func (p *SubscriptionPlan) NextRun(from time.Time) time.Time {
+	switch p.IntervalUnit {
+	case SubscriptionIntervalWeek:
+		return from.AddDate(0, 0, 7*p.IntervalCount)
+	case SubscriptionIntervalMonth:
+		return from.AddDate(0, p.IntervalCount, 0)
+	default:
+		return from.AddDate(0, 0, p.IntervalCount)
+	}
+}
+
+// SubscriptionStatus 订阅状态
+type SubscriptionStatus string
+
+const (
+	SubscriptionActive    SubscriptionStatus = "active"    // 生效中
+	SubscriptionPaused    SubscriptionStatus = "paused"    // 已暂停
+	SubscriptionCancelled SubscriptionStatus = "cancelled" // 已取消
+)
+
+// SubscriptionPauseReason 订阅暂停原因
+type SubscriptionPauseReason string
+
+const (
+	SubscriptionPauseByUser       SubscriptionPauseReason = "user"           // 用户暂停
+	SubscriptionPauseOutOfStock   SubscriptionPauseReason = "out_of_stock"   // 缺货暂停
+	SubscriptionPausePaymentFails SubscriptionPauseReason = "payment_failed" // 扣款重试用尽
+)
+
+// SubscriptionPayMethod 订阅扣款方式
+type SubscriptionPayMethod string
+
+const (
+	SubscriptionPayWallet      SubscriptionPayMethod = "wallet"      // 账户余额
+	SubscriptionPayWithholding SubscriptionPayMethod = "withholding" // 已签约的渠道代扣
+)
+
+// Subscription 用户订阅
+// 调度器在 NextRunAt 到期时生成周期订单并扣款，扣款失败时按重试计划催缴，DunningCycleID 指向催缴中的周期
+type Subscription struct {
+	ID             uint                  `gorm:"primarykey" json:"id"`
+	SubscriptionNo string                `gorm:"uniqueIndex;not null;size:32" json:"subscription_no"` // 订阅编号
+	UserID         uint                  `gorm:"not null;index" json:"user_id"`                       // 用户ID
+	PlanID         uint                  `gorm:"not null;index" json:"plan_id"`                       // 订阅计划ID
+	Quantity       int                   `gorm:"not null" json:"quantity"`                            // 每期数量
+	PayMethod      SubscriptionPayMethod `gorm:"not null;size:20" json:"pay_method"`                  // 扣款方式
+	AgreementID    uint                  `json:"agreement_id"`                                        // 代扣协议ID
+
+	ReceiverName    string `gorm:"size:50" json:"receiver_name"`
+	ReceiverPhone   string `gorm:"size:20" json:"receiver_phone"`
+	ReceiverAddress string `gorm:"size:255" json:"receiver_address"`
+	ReceiverZipCode string `gorm:"size:10" json:"receiver_zip_code"`
+	Province        string `gorm:"size:50" json:"province"`
+	City            string `gorm:"size:50" json:"city"`
+	District        string `gorm:"size:50" json:"district"`
+
+	Status          SubscriptionStatus      `gorm:"not null;size:20;index:idx_subscription_due,priority:1" json:"status"` // 订阅状态
+	NextRunAt       time.Time               `gorm:"index:idx_subscription_due,priority:2" json:"next_run_at"`             // 下次下单或催缴重试时间
+	SkipNext        bool                    `gorm:"not null" json:"skip_next"`                                            // 跳过下一期
+	CycleCount      int                     `gorm:"not null" json:"cycle_count"`                                          // 已排期的周期数（含跳过）
+	CompletedCycles int                     `gorm:"not null" json:"completed_cycles"`                                     // 已扣款成功的周期数
+	DunningCycleID  uint                    `json:"dunning_cycle_id"`                                                     // 催缴中的周期ID
+	PauseReason     SubscriptionPauseReason `gorm:"size:20" json:"pause_reason"`                                          // 暂停原因
+	PausedAt        *time.Time              `json:"paused_at"`
+	CancelledAt     *time.Time              `json:"cancelled_at"`
+	CancelReason    string                  `gorm:"size:255" json:"cancel_reason"`
+	LockedUntil     *time.Time              `json:"-"` // 调度器认领租约，防止多实例重复处理
+
+	Plan      *SubscriptionPlan   `gorm:"foreignKey:PlanID" json:"plan,omitempty"`
+	Cycles    []SubscriptionCycle `gorm:"foreignKey:SubscriptionID" json:"cycles,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Subscription) TableName() string {
+	return "subscriptions"
+}
+
+// SubscriptionCycleStatus 订阅周期状态
+type SubscriptionCycleStatus string
+
+const (
+	SubscriptionCyclePaid          SubscriptionCycleStatus = "paid"           // 已下单并扣款成功
+	SubscriptionCycleDunning       SubscriptionCycleStatus = "dunning"        // 扣款失败，催缴重试中
+	SubscriptionCyclePaymentFailed SubscriptionCycleStatus = "payment_failed" // 重试用尽，订单已取消
+	SubscriptionCycleSkipped       SubscriptionCycleStatus = "skipped"        // 用户跳过
+	SubscriptionCycleOutOfStock    SubscriptionCycleStatus = "out_of_stock"   // 缺货未下单
+	SubscriptionCycleCancelled     SubscriptionCycleStatus = "cancelled"      // 催缴期间订阅被取消
+)
+
+// SubscriptionCycle 订阅周期记录，每期一条
+type SubscriptionCycle struct {
+	ID             uint                    `gorm:"primarykey" json:"id"`
+	SubscriptionID uint                    `gorm:"not null;uniqueIndex:idx_subscription_cycle,priority:1" json:"subscription_id"` // 订阅ID
+	CycleNo        int                     `gorm:"not null;uniqueIndex:idx_subscription_cycle,priority:2" json:"cycle_no"`        // 期数
+	ScheduledAt    time.Time               `json:"scheduled_at"`                                                                  // 计划下单时间
+	Status         SubscriptionCycleStatus `gorm:"not null;size:20" json:"status"`                                                // 周期状态
+	OrderID        uint                    `gorm:"index" json:"order_id"`                                                         // 周期订单ID
+	Amount         decimal.Decimal         `gorm:"type:decimal(10,2);not null;default:0" json:"amount"`                           // 扣款金额
+	Attempts       int                     `gorm:"not null" json:"attempts"`                                                      // 扣款次数
+	Error          string                  `gorm:"size:500" json:"error"`                                                         // 最近一次失败原因
+	PaidAt         *time.Time              `json:"paid_at"`
+	CreatedAt      time.Time               `json:"created_at"`
+	UpdatedAt      time.Time               `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (SubscriptionCycle) TableName() string {
+	return "subscription_cycles"
+}
+
+// WithholdingAgreementStatus 代扣协议状态
+type WithholdingAgreementStatus string
+
+const (
+	WithholdingAgreementSigned     WithholdingAgreementStatus = "signed"     // 已签约
+	WithholdingAgreementTerminated WithholdingAgreementStatus = "terminated" // 已解约
+)
+
+// WithholdingAgreement 用户与支付渠道签订的代扣协议（如支付宝周期扣款、微信委托代扣）
+type WithholdingAgreement struct {
+	ID           uint                       `gorm:"primarykey" json:"id"`
+	UserID       uint                       `gorm:"not null;index" json:"user_id"`                                                    // 用户ID
+	Channel      PaymentMethod              `gorm:"not null;size:20;uniqueIndex:idx_withholding_agreement,priority:1" json:"channel"` // 支付渠道
+	AgreementNo  string                     `gorm:"not null;size:64;uniqueIndex:idx_withholding_agreement,priority:2" json:"agreement_no"`
+	Status       WithholdingAgreementStatus `gorm:"not null;size:20" json:"status"` // 协议状态
+	SignedAt     time.Time                  `json:"signed_at"`
+	TerminatedAt *time.Time                 `json:"terminated_at"`
+	CreatedAt    time.Time                  `json:"created_at"`
+	UpdatedAt    time.Time                  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (WithholdingAgreement) TableName() string {
+	return "payment_withholding_agreements"
+}
+
+// SubscriptionPlanRequest 创建或更新订阅计划请求
+type SubscriptionPlanRequest struct {
+	ProductID        uint                     `json:"product_id" binding:"required"`                            // 商品ID
+	SKUID            uint                     `json:"sku_id"`                                                   // SKU ID
+	Name             string                   `json:"name" binding:"required,max=100"`                          // 计划名称
+	IntervalUnit     SubscriptionIntervalUnit `json:"interval_unit" binding:"required,oneof=day week month"`    // 周期单位
+	IntervalCount    int                      `json:"interval_count" binding:"required,min=1,max=365"`          // 周期数
+	DiscountRate     decimal.Decimal          `json:"discount_rate"`                                            // 订阅折扣率，0~1
+	MinCycles        int                      `json:"min_cycles" binding:"min=0"`                               // 最少履约期数
+	OutOfStockAction SubscriptionStockAction  `json:"out_of_stock_action" binding:"omitempty,oneof=skip pause"` // 缺货处理方式，默认跳过
+	IsActive         bool                     `json:"is_active"`                                                // 是否可订阅
+}
+
+// CreateSubscriptionRequest 创建订阅请求
+type CreateSubscriptionRequest struct {
+	PlanID          uint                  `json:"plan_id" binding:"required"`                             // 订阅计划ID
+	Quantity        int                   `json:"quantity" binding:"required,min=1,max=99"`               // 每期数量
+	PayMethod       SubscriptionPayMethod `json:"pay_method" binding:"required,oneof=wallet withholding"` // 扣款方式
+	AgreementID     uint                  `json:"agreement_id"`                                           // 代扣协议ID，代扣时必填
+	StartAt         *time.Time            `json:"start_at"`                                               // 首期下单时间，为空时立即下单
+	ReceiverName    string                `json:"receiver_name" binding:"required"`
+	ReceiverPhone   string                `json:"receiver_phone" binding:"required"`
+	ReceiverAddress string                `json:"receiver_address" binding:"required"`
+	ReceiverZipCode string                `json:"receiver_zip_code"`
+	Province        string                `json:"province" binding:"required"`
+	City            string                `json:"city" binding:"required"`
+	District        string                `json:"district" binding:"required"`
+}
+
+// SubscriptionListRequest 订阅列表查询请求
+type SubscriptionListRequest struct {
+	Status   SubscriptionStatus `form:"status"`    // 订阅状态
+	Page     int                `form:"page"`      // 页码
+	PageSize int                `form:"page_size"` // 每页数量
+}
+
+// CancelSubscriptionRequest 取消订阅请求
+type CancelSubscriptionRequest struct {
+	Reason string `json:"reason" binding:"max=255"` // 取消原因
+}
+
+// 订阅错误定义
+var (
+	ErrSubscriptionPlanNotFound    = errors.New("订阅计划不存在")
+	ErrSubscriptionPlanInactive    = errors.New("订阅计划已停用")
+	ErrSubscriptionProductInvalid  = errors.New("订阅商品或规格不存在")
+	ErrSubscriptionNotFound        = errors.New("订阅不存在")
+	ErrSubscriptionNotActive       = errors.New("订阅未生效")
+	ErrSubscriptionNotPaused       = errors.New("订阅未暂停")
+	ErrSubscriptionCancelled       = errors.New("订阅已取消")
+	ErrSubscriptionMinCycles       = errors.New("未满最少履约期数，暂不能取消")
+	ErrSubscriptionBusy            = errors.New("订阅正在下单处理中，请稍后重试")
+	ErrInvalidSubscriptionDiscount = errors.New("订阅折扣率必须在0到1之间")
+	ErrWithholdingAgreementInvalid = errors.New("代扣协议不存在或已解约")
+	ErrWithholdingNotConfigured    = errors.New("未配置代扣渠道")
+	ErrInsufficientBalance         = errors.New("账户余额不足")
+	ErrOrderItemUnavailable        = errors.New("商品已下架或库存不足")
+)
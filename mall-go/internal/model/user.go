@@ -344,6 +344,7 @@ const (
 	VerificationTypeResetPassword = "reset_password" // 重置密码
 	VerificationTypeChangeEmail   = "change_email"   // 修改邮箱
 	VerificationTypeChangePhone   = "change_phone"   // 修改手机号
+	VerificationTypePaymentRisk   = "payment_risk"   // 支付风控二次验证
 )
 
 // 性别常量
@@ -0,0 +1,78 @@
+package bundle
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mall-go/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// Job 搭配定期挖掘任务
+type Job struct {
+	service *Service
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewJob 创建并启动搭配挖掘任务，搭配表为空时立即挖掘一次
+func NewJob(service *Service) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		service: service,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	job.wg.Add(1)
+	go job.run()
+
+	logger.Info("搭配挖掘任务启动",
+		zap.Duration("interval", service.options.Interval),
+		zap.Int("min_support", service.options.MinSupport),
+		zap.Float64("min_confidence", service.options.MinConfidence))
+
+	return job
+}
+
+// run 挖掘主循环
+func (j *Job) run() {
+	defer j.wg.Done()
+
+	if j.service.Empty() {
+		j.mine()
+	}
+
+	ticker := time.NewTicker(j.service.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+			j.mine()
+		}
+	}
+}
+
+// mine 执行一次挖掘并记录结果
+func (j *Job) mine() {
+	start := time.Now()
+	count, err := j.service.Mine()
+	if err != nil {
+		logger.Error("挖掘搭配失败", zap.Error(err))
+		return
+	}
+	logger.Info("搭配挖掘完成", zap.Int("bundles", count), zap.Duration("elapsed", time.Since(start)))
+}
+
+// Stop 停止搭配挖掘任务
+func (j *Job) Stop() {
+	logger.Info("停止搭配挖掘任务")
+	j.cancel()
+	j.wg.Wait()
+}
@@ -0,0 +1,295 @@
+package bundle
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"mall-go/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// itemset 按商品ID升序排列的商品组合，二元组第三位为0
+type itemset [3]uint
+
+// size 组合中的商品数
+func (s itemset) size() int {
+	if s[2] == 0 {
+		return 2
+	}
+	return 3
+}
+
+// countEntry 计数项，delta 为加入时可能漏计的最大次数
+type countEntry struct {
+	count int
+	delta int
+}
+
+// boundedCounter 内存受限的组合计数器
+// 采用 Lossy Counting：项数超过容量时逐步提高下限 floor 并淘汰 count+delta 不超过下限的低频组合，
+// 保留组合的计数最多少计 floor 次，频次高于 floor 的组合不会被淘汰
+type boundedCounter struct {
+	capacity int
+	floor    int
+	entries  map[itemset]*countEntry
+}
+
+// newBoundedCounter 创建组合计数器
+func newBoundedCounter(capacity int) *boundedCounter {
+	return &boundedCounter{
+		capacity: capacity,
+		entries:  make(map[itemset]*countEntry),
+	}
+}
+
+// add 计数一次
+func (c *boundedCounter) add(key itemset) {
+	if entry, ok := c.entries[key]; ok {
+		entry.count++
+		return
+	}
+	c.entries[key] = &countEntry{count: 1, delta: c.floor}
+	if len(c.entries) > c.capacity {
+		c.prune()
+	}
+}
+
+// prune 提高下限淘汰低频组合，直到项数降至容量的四分之三以下
+func (c *boundedCounter) prune() {
+	target := c.capacity * 3 / 4
+	for len(c.entries) > target {
+		c.floor++
+		for key, entry := range c.entries {
+			if entry.count+entry.delta <= c.floor {
+				delete(c.entries, key)
+			}
+		}
+	}
+}
+
+// get 组合计数，已淘汰或未出现的组合返回0
+func (c *boundedCounter) get(key itemset) int {
+	if entry, ok := c.entries[key]; ok {
+		return entry.count
+	}
+	return 0
+}
+
+// rule 挖掘出的搭配规则：购买 ProductID 的订单中同时购买 Items
+type rule struct {
+	productID  uint
+	items      []uint
+	support    int
+	confidence float64
+	lift       float64
+}
+
+// miner 订单频繁项集挖掘
+// 分三遍流式读取订单：第一遍统计单品频次，第二遍只对频繁单品统计二元组，
+// 第三遍只对子组合均频繁的三元组计数，每遍按批读取订单，内存由计数器容量约束
+type miner struct {
+	db      *gorm.DB
+	options Options
+	since   time.Time
+
+	baskets int
+	items   map[uint]int
+	pairs   *boundedCounter
+	triples *boundedCounter
+}
+
+// newMiner 创建挖掘器
+func newMiner(db *gorm.DB, options Options, since time.Time) *miner {
+	return &miner{
+		db:      db,
+		options: options,
+		since:   since,
+		items:   make(map[uint]int),
+		pairs:   newBoundedCounter(options.MaxCandidates),
+		triples: newBoundedCounter(options.MaxCandidates),
+	}
+}
+
+// run 执行挖掘，返回每个商品置信度最高的搭配规则
+func (m *miner) run() ([]rule, error) {
+	if err := m.scan(func(basket []uint) {
+		m.baskets++
+		for _, productID := range basket {
+			m.items[productID]++
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := m.scan(func(basket []uint) {
+		basket = m.frequentItems(basket)
+		for i := 0; i < len(basket); i++ {
+			for j := i + 1; j < len(basket); j++ {
+				m.pairs.add(itemset{basket[i], basket[j]})
+			}
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := m.scan(func(basket []uint) {
+		basket = m.frequentItems(basket)
+		for i := 0; i < len(basket); i++ {
+			for j := i + 1; j < len(basket); j++ {
+				if !m.frequent(m.pairs, itemset{basket[i], basket[j]}) {
+					continue
+				}
+				for k := j + 1; k < len(basket); k++ {
+					if m.frequent(m.pairs, itemset{basket[i], basket[k]}) && m.frequent(m.pairs, itemset{basket[j], basket[k]}) {
+						m.triples.add(itemset{basket[i], basket[j], basket[k]})
+					}
+				}
+			}
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	return m.rules(), nil
+}
+
+// scan 按订单ID分批流式读取有效订单的商品，每个订单去重后按商品ID升序回调
+func (m *miner) scan(visit func(basket []uint)) error {
+	var orders []model.Order
+	err := m.db.Select("id").
+		Where("created_at >= ? AND status NOT IN ?", m.since,
+			[]string{model.OrderStatusCancelled, model.OrderStatusClosed}).
+		FindInBatches(&orders, m.options.BatchSize, func(tx *gorm.DB, batch int) error {
+			orderIDs := make([]uint, 0, len(orders))
+			for _, order := range orders {
+				orderIDs = append(orderIDs, order.ID)
+			}
+
+			var items []model.OrderItem
+			if err := m.db.Select("order_id, product_id").
+				Where("order_id IN ?", orderIDs).
+				Order("order_id").
+				Find(&items).Error; err != nil {
+				return err
+			}
+
+			for start := 0; start < len(items); {
+				end := start
+				for end < len(items) && items[end].OrderID == items[start].OrderID {
+					end++
+				}
+				visit(basketOf(items[start:end]))
+				start = end
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return fmt.Errorf("读取订单商品失败: %v", err)
+	}
+	return nil
+}
+
+// frequentItems 保留购物篮中的频繁单品，超出上限时只取前 MaxBasketSize 个
+func (m *miner) frequentItems(basket []uint) []uint {
+	frequent := basket[:0:0]
+	for _, productID := range basket {
+		if m.items[productID] >= m.options.MinSupport {
+			frequent = append(frequent, productID)
+			if len(frequent) >= m.options.MaxBasketSize {
+				break
+			}
+		}
+	}
+	return frequent
+}
+
+// frequent 组合计数是否达到最小支持度
+func (m *miner) frequent(counter *boundedCounter, key itemset) bool {
+	return counter.get(key) >= m.options.MinSupport
+}
+
+// rules 由频繁二元组、三元组生成各商品的搭配规则，每个商品取置信度最高者，相同时取商品更多的组合
+func (m *miner) rules() []rule {
+	best := make(map[uint]rule)
+	consider := func(key itemset, support int) {
+		members := key[:key.size()]
+		for i, productID := range members {
+			others := make([]uint, 0, len(members)-1)
+			others = append(others, members[:i]...)
+			others = append(others, members[i+1:]...)
+
+			confidence := float64(support) / float64(m.items[productID])
+			if confidence < m.options.MinConfidence {
+				continue
+			}
+			othersSupport := m.items[others[0]]
+			if len(others) == 2 {
+				othersSupport = m.pairs.get(itemset{others[0], others[1]})
+			}
+			lift := 0.0
+			if othersSupport > 0 {
+				lift = confidence / (float64(othersSupport) / float64(m.baskets))
+			}
+
+			candidate := rule{productID: productID, items: others, support: support, confidence: confidence, lift: lift}
+			if current, ok := best[productID]; !ok || better(candidate, current) {
+				best[productID] = candidate
+			}
+		}
+	}
+
+	for key, entry := range m.pairs.entries {
+		if entry.count >= m.options.MinSupport {
+			consider(key, entry.count)
+		}
+	}
+	for key, entry := range m.triples.entries {
+		if entry.count >= m.options.MinSupport {
+			consider(key, entry.count)
+		}
+	}
+
+	rules := make([]rule, 0, len(best))
+	for _, r := range best {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].productID < rules[j].productID
+	})
+	return rules
+}
+
+// better 规则比较：置信度高者优先，其次商品多者、支持度高者，最后按商品ID保证结果稳定
+func better(a, b rule) bool {
+	if a.confidence != b.confidence {
+		return a.confidence > b.confidence
+	}
+	if len(a.items) != len(b.items) {
+		return len(a.items) > len(b.items)
+	}
+	if a.support != b.support {
+		return a.support > b.support
+	}
+	for i := range a.items {
+		if a.items[i] != b.items[i] {
+			return a.items[i] < b.items[i]
+		}
+	}
+	return false
+}
+
+// basketOf 订单商品去重并按商品ID升序排列
+func basketOf(items []model.OrderItem) []uint {
+	basket := make([]uint, 0, len(items))
+	seen := make(map[uint]bool, len(items))
+	for _, item := range items {
+		if !seen[item.ProductID] {
+			seen[item.ProductID] = true
+			basket = append(basket, item.ProductID)
+		}
+	}
+	sort.Slice(basket, func(i, j int) bool { return basket[i] < basket[j] })
+	return basket
+}
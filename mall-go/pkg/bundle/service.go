@@ -0,0 +1,240 @@
+package bundle
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/cart"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/promotion"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Options 搭配挖掘配置
+type Options struct {
+	Interval      time.Duration // 重新挖掘间隔
+	Lookback      time.Duration // 参与挖掘的订单时间范围
+	MinSupport    int           // 最小支持度，整组商品同时出现的订单数下限
+	MinConfidence float64       // 最小置信度，购买商品的订单中同时购买搭配商品的比例下限
+	MaxCandidates int           // 二元组、三元组计数器各自保留的组合数上限，约束挖掘内存
+	MaxBasketSize int           // 单个订单参与组合计数的商品数上限，避免大单放大计算量
+	BatchSize     int           // 分批读取订单和写入搭配的数量
+}
+
+// DefaultOptions 默认搭配挖掘配置
+func DefaultOptions() Options {
+	return Options{
+		Interval:      24 * time.Hour,
+		Lookback:      365 * 24 * time.Hour,
+		MinSupport:    5,
+		MinConfidence: 0.1,
+		MaxCandidates: 1000000,
+		MaxBasketSize: 30,
+		BatchSize:     1000,
+	}
+}
+
+// Suggestion 商品详情页的搭配购买推荐
+type Suggestion struct {
+	ProductID   uint                    `json:"product_id"`
+	Products    []model.Product         `json:"products"` // 第一个为当前商品，其后为搭配商品
+	Support     int                     `json:"support"`
+	Confidence  float64                 `json:"confidence"`
+	TotalAmount decimal.Decimal         `json:"total_amount"` // 各商品一件的原价合计
+	Discount    decimal.Decimal         `json:"discount"`     // 促销优惠，含打包价
+	BundlePrice decimal.Decimal         `json:"bundle_price"` // 一起购买的价格
+	Promotions  []promotion.Explanation `json:"promotions"`   // 命中的促销
+	Available   bool                    `json:"available"`    // 全部商品有货，可一键加购
+}
+
+// Service 搭配购买服务
+// 定期挖掘订单中的频繁二元组和三元组，为每个商品保留置信度最高的搭配，
+// 商品详情页展示搭配及一起购买的价格，并支持一键全部加入购物车
+type Service struct {
+	db          *gorm.DB
+	cartService *cart.CartService
+	promotions  *promotion.Service
+	options     Options
+	now         func() time.Time
+}
+
+// NewService 创建搭配购买服务
+func NewService(db *gorm.DB, cartService *cart.CartService, options Options) *Service {
+	return &Service{
+		db:          db,
+		cartService: cartService,
+		promotions:  promotion.NewService(db),
+		options:     options,
+		now:         time.Now,
+	}
+}
+
+// Empty 搭配表是否为空
+func (s *Service) Empty() bool {
+	var count int64
+	if err := s.db.Model(&model.ProductBundle{}).Limit(1).Count(&count).Error; err != nil {
+		return true
+	}
+	return count == 0
+}
+
+// Mine 重新挖掘搭配并整体替换搭配表，返回写入的记录数
+func (s *Service) Mine() (int, error) {
+	m := newMiner(s.db, s.options, s.now().Add(-s.options.Lookback))
+	rules, err := m.run()
+	if err != nil {
+		return 0, err
+	}
+	if floor := max(m.pairs.floor, m.triples.floor); floor >= s.options.MinSupport {
+		logger.Warn("搭配挖掘候选组合超出上限，部分低频搭配可能被遗漏",
+			zap.Int("floor", floor),
+			zap.Int("min_support", s.options.MinSupport),
+			zap.Int("max_candidates", s.options.MaxCandidates))
+	}
+
+	updatedAt := s.now()
+	rows := make([]model.ProductBundle, 0, len(rules))
+	for _, r := range rules {
+		row := model.ProductBundle{
+			ProductID:  r.productID,
+			Support:    r.support,
+			Confidence: r.confidence,
+			Lift:       r.lift,
+			UpdatedAt:  updatedAt,
+		}
+		row.SetItemIDs(r.items)
+		rows = append(rows, row)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&model.ProductBundle{}).Error; err != nil {
+			return fmt.Errorf("清理搭配失败: %v", err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		if err := tx.CreateInBatches(rows, s.options.BatchSize).Error; err != nil {
+			return fmt.Errorf("保存搭配失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+// Suggest 查询商品的搭配推荐，已下架的搭配商品不展示，价格按各商品一件经促销计算
+func (s *Service) Suggest(productID uint) (*Suggestion, error) {
+	bundle, products, err := s.load(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]promotion.Line, 0, len(products))
+	available := true
+	for _, product := range products {
+		lines = append(lines, promotion.Line{ProductID: product.ID, Price: product.Price, Quantity: 1})
+		if product.Stock < 1 {
+			available = false
+		}
+	}
+	result, err := s.promotions.Evaluate(lines)
+	if err != nil {
+		return nil, fmt.Errorf("计算搭配价格失败: %v", err)
+	}
+
+	return &Suggestion{
+		ProductID:   productID,
+		Products:    products,
+		Support:     bundle.Support,
+		Confidence:  bundle.Confidence,
+		TotalAmount: result.TotalAmount,
+		Discount:    result.Discount,
+		BundlePrice: result.PayAmount,
+		Promotions:  result.Applied,
+		Available:   available,
+	}, nil
+}
+
+// AddToCart 将商品及搭配商品各一件加入购物车，任一商品无货时不加入任何商品
+func (s *Service) AddToCart(userID uint, sessionID string, productID uint, req *model.BundleToCartRequest) ([]model.CartItem, error) {
+	_, products, err := s.load(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make([]*model.AddToCartRequest, 0, len(products))
+	for _, product := range products {
+		addReq := &model.AddToCartRequest{ProductID: product.ID, Quantity: 1}
+		if req != nil {
+			addReq.SKUID = req.SKUIDs[product.ID]
+		}
+		stock := product.Stock
+		if addReq.SKUID > 0 {
+			var sku model.ProductSKU
+			if err := s.db.Where("id = ? AND product_id = ? AND status = ?", addReq.SKUID, product.ID, model.SKUStatusActive).
+				First(&sku).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, model.ErrBundleUnavailable
+				}
+				return nil, fmt.Errorf("查询商品规格失败: %v", err)
+			}
+			stock = sku.Stock
+		}
+		if stock < 1 {
+			return nil, model.ErrBundleUnavailable
+		}
+		requests = append(requests, addReq)
+	}
+
+	items := make([]model.CartItem, 0, len(requests))
+	for _, addReq := range requests {
+		item, err := s.cartService.AddToCart(userID, sessionID, addReq)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, nil
+}
+
+// load 加载商品的搭配及在售商品，当前商品排在第一位，没有在售的搭配商品时视为暂无搭配
+func (s *Service) load(productID uint) (*model.ProductBundle, []model.Product, error) {
+	var bundle model.ProductBundle
+	if err := s.db.Where("product_id = ?", productID).First(&bundle).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, model.ErrBundleNotFound
+		}
+		return nil, nil, fmt.Errorf("查询搭配失败: %v", err)
+	}
+
+	ids := append([]uint{productID}, bundle.ItemIDList()...)
+	var found []model.Product
+	if err := s.db.Where("id IN ? AND status = ?", ids, model.ProductStatusActive).Find(&found).Error; err != nil {
+		return nil, nil, fmt.Errorf("查询搭配商品失败: %v", err)
+	}
+	byID := make(map[uint]model.Product, len(found))
+	for _, product := range found {
+		byID[product.ID] = product
+	}
+
+	if _, ok := byID[productID]; !ok {
+		return nil, nil, model.ErrBundleNotFound
+	}
+	products := make([]model.Product, 0, len(ids))
+	for _, id := range ids {
+		if product, ok := byID[id]; ok {
+			products = append(products, product)
+		}
+	}
+	if len(products) < 2 {
+		return nil, nil, model.ErrBundleNotFound
+	}
+	return &bundle, products, nil
+}
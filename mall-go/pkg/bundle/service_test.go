@@ -0,0 +1,134 @@
+package bundle
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/cart"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// BundleServiceTestSuite 组合推荐服务测试套件
+type BundleServiceTestSuite struct {
+	suite.Suite
+	db       *gorm.DB
+	service  *Service
+	products []*model.Product
+}
+
+// SetupTest 每个用例使用独立的内存数据库和5个测试商品，降低挖掘阈值便于构造订单
+func (suite *BundleServiceTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.AutoMigrate(&model.Product{}, &model.ProductSKU{}, &model.Cart{}, &model.CartItem{},
+		&model.Order{}, &model.OrderItem{}, &model.Promotion{}, &model.ProductBundle{}))
+
+	suite.products = nil
+	for i := 1; i <= 5; i++ {
+		product := &model.Product{Name: fmt.Sprintf("商品%d", i), Price: decimal.NewFromInt(20), Stock: 10, Status: model.ProductStatusActive}
+		suite.Require().NoError(db.Create(product).Error)
+		suite.products = append(suite.products, product)
+	}
+
+	options := DefaultOptions()
+	options.MinSupport = 2
+	options.MinConfidence = 0.5
+	options.BatchSize = 2
+	suite.db = db
+	suite.service = NewService(db, cart.NewCartService(db), options)
+}
+
+func (suite *BundleServiceTestSuite) createOrder(orderNo, status string, products ...*model.Product) {
+	order := &model.Order{OrderNo: orderNo, UserID: 9, Status: status,
+		PaymentStatus: "paid", TotalAmount: decimal.NewFromInt(20), PayableAmount: decimal.NewFromInt(20)}
+	suite.Require().NoError(suite.db.Create(order).Error)
+	for _, product := range products {
+		suite.Require().NoError(suite.db.Create(&model.OrderItem{OrderID: order.ID, ProductID: product.ID, Quantity: 1,
+			ProductName: product.Name, Price: product.Price, TotalPrice: product.Price}).Error)
+	}
+}
+
+func (suite *BundleServiceTestSuite) TestService_MineSuggestAndAddToCart() {
+	suite.createOrder("O1", model.OrderStatusPaid, suite.products[0], suite.products[1], suite.products[2])
+	suite.createOrder("O2", model.OrderStatusCompleted, suite.products[0], suite.products[1], suite.products[2], suite.products[1])
+	suite.createOrder("O3", model.OrderStatusPaid, suite.products[0], suite.products[1])
+	suite.createOrder("O4", model.OrderStatusPaid, suite.products[0], suite.products[3])
+	// 取消的订单不参与挖掘
+	suite.createOrder("O5", model.OrderStatusCancelled, suite.products[3], suite.products[4])
+	suite.createOrder("O6", model.OrderStatusCancelled, suite.products[3], suite.products[4])
+
+	suite.True(suite.service.Empty())
+	count, err := suite.service.Mine()
+	suite.Require().NoError(err)
+	suite.Equal(3, count)
+
+	var bundles []model.ProductBundle
+	suite.Require().NoError(suite.db.Order("product_id").Find(&bundles).Error)
+	suite.Require().Len(bundles, 3)
+	// 商品1：1+2 置信度 3/4 高于 1+3 和 1+2+3 的 2/4
+	suite.Equal([]uint{suite.products[1].ID}, bundles[0].ItemIDList())
+	suite.Equal(3, bundles[0].Support)
+	suite.InDelta(0.75, bundles[0].Confidence, 1e-9)
+	// 商品3：置信度相同时取商品更多的三元组，提升度相对 2+1 的出现比例 3/4
+	suite.Equal(suite.products[2].ID, bundles[2].ProductID)
+	suite.Equal([]uint{suite.products[0].ID, suite.products[1].ID}, bundles[2].ItemIDList())
+	suite.InDelta(1.0, bundles[2].Confidence, 1e-9)
+	suite.InDelta(4.0/3, bundles[2].Lift, 1e-9)
+
+	// 打包价促销计入一起购买的价格
+	suite.Require().NoError(suite.db.Create(&model.Promotion{Name: "任选3件50元", Type: model.PromotionBundlePrice,
+		ScopeType: model.PromotionScopeAll, BuyQuantity: 3, Price: decimal.NewFromInt(50), Status: model.PromotionStatusActive,
+		StartAt: time.Now().Add(-time.Hour), EndAt: time.Now().Add(time.Hour)}).Error)
+	suggestion, err := suite.service.Suggest(suite.products[2].ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(suggestion.Products, 3)
+	suite.Equal(suite.products[2].ID, suggestion.Products[0].ID)
+	suite.True(suggestion.TotalAmount.Equal(decimal.NewFromInt(60)))
+	suite.True(suggestion.BundlePrice.Equal(decimal.NewFromInt(50)))
+	suite.Require().Len(suggestion.Promotions, 1)
+	suite.True(suggestion.Available)
+
+	_, err = suite.service.Suggest(suite.products[3].ID)
+	suite.ErrorIs(err, model.ErrBundleNotFound)
+
+	items, err := suite.service.AddToCart(1, "", suite.products[2].ID, nil)
+	suite.Require().NoError(err)
+	suite.Len(items, 3)
+
+	// 任一商品无货时不加入任何商品
+	suite.Require().NoError(suite.db.Model(suite.products[1]).Update("stock", 0).Error)
+	_, err = suite.service.AddToCart(2, "", suite.products[2].ID, nil)
+	suite.ErrorIs(err, model.ErrBundleUnavailable)
+	var cartCount int64
+	suite.Require().NoError(suite.db.Model(&model.Cart{}).Where("user_id = ?", 2).Count(&cartCount).Error)
+	suite.Zero(cartCount)
+
+	// 搭配商品全部下架时视为暂无搭配
+	suite.Require().NoError(suite.db.Model(suite.products[0]).Update("status", model.ProductStatusInactive).Error)
+	_, err = suite.service.Suggest(suite.products[1].ID)
+	suite.ErrorIs(err, model.ErrBundleNotFound)
+}
+
+func TestBoundedCounter_KeepsFrequentItemsets(t *testing.T) {
+	counter := newBoundedCounter(8)
+	frequent := itemset{1, 2}
+	for i := uint(0); i < 100; i++ {
+		counter.add(frequent)
+		counter.add(itemset{10, 100 + i})
+		assert.LessOrEqual(t, len(counter.entries), 8)
+	}
+	assert.Equal(t, 100, counter.get(frequent))
+	assert.Positive(t, counter.floor)
+	assert.Zero(t, counter.get(itemset{10, 100}))
+}
+
+func TestBundleServiceSuite(t *testing.T) {
+	suite.Run(t, new(BundleServiceTestSuite))
+}
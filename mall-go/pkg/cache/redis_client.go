@@ -56,6 +56,14 @@ func NewRedisClient(cfg config.RedisConfig) (*RedisClient, error) {
 	}, nil
 }
 
+// WrapRedisClient 使用已创建的redis.Client构造封装客户端
+func WrapRedisClient(client *redis.Client) *RedisClient {
+	return &RedisClient{
+		client: client,
+		ctx:    context.Background(),
+	}
+}
+
 // GetClient 获取Redis客户端
 func (r *RedisClient) GetClient() *redis.Client {
 	return r.client
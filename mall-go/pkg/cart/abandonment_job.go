@@ -0,0 +1,84 @@
+package cart
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mall-go/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// AbandonmentJob 弃购追踪定时任务，标记弃购购物车、发送提醒并归因转化
+type AbandonmentJob struct {
+	service *AbandonmentService
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewAbandonmentJob 创建并启动弃购追踪任务
+func NewAbandonmentJob(service *AbandonmentService) *AbandonmentJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &AbandonmentJob{
+		service: service,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	job.wg.Add(1)
+	go job.run()
+
+	logger.Info("弃购追踪任务启动",
+		zap.Duration("interval", service.options.ScanInterval),
+		zap.Duration("abandon_after", service.options.AbandonAfter),
+		zap.Int("reminder_stages", len(service.options.Reminders)))
+
+	return job
+}
+
+// run 追踪主循环，标记和提醒处理满批时立即继续下一批
+func (j *AbandonmentJob) run() {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(j.service.options.ScanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+			abandoned := j.drain(j.service.MarkAbandoned)
+			reminded := j.drain(j.service.SendReminders)
+			converted := j.service.AttributeConversions()
+			if abandoned+reminded+converted > 0 {
+				logger.Info("弃购追踪完成",
+					zap.Int("abandoned", abandoned),
+					zap.Int("reminded", reminded),
+					zap.Int("converted", converted))
+			}
+		}
+	}
+}
+
+// drain 重复执行单批处理直到不足一批或任务停止
+func (j *AbandonmentJob) drain(step func() int) int {
+	total := 0
+	for j.ctx.Err() == nil {
+		processed := step()
+		total += processed
+		if processed < j.service.options.BatchSize {
+			break
+		}
+	}
+	return total
+}
+
+// Stop 停止弃购追踪任务
+func (j *AbandonmentJob) Stop() {
+	logger.Info("停止弃购追踪任务")
+	j.cancel()
+	j.wg.Wait()
+}
@@ -0,0 +1,443 @@
+package cart
+
+import (
+	"fmt"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ReminderStage 弃购提醒轮次配置
+type ReminderStage struct {
+	Delay    time.Duration // 距最后一次修改购物车多久后发送
+	CouponID uint          // 随提醒自动发放的优惠券，0为不发券
+}
+
+// AbandonmentOptions 弃购追踪配置
+type AbandonmentOptions struct {
+	AbandonAfter     time.Duration   // 购物车闲置多久后判定为弃购
+	Reminders        []ReminderStage // 提醒轮次，按延迟从短到长配置
+	ConversionWindow time.Duration   // 提醒后多久内下单计为该提醒的转化
+	ScanInterval     time.Duration   // 调度扫描间隔
+	BatchSize        int             // 每轮处理的购物车数
+}
+
+// DefaultAbandonmentOptions 默认弃购追踪配置
+func DefaultAbandonmentOptions() AbandonmentOptions {
+	return AbandonmentOptions{
+		AbandonAfter: 2 * time.Hour,
+		Reminders: []ReminderStage{
+			{Delay: 2 * time.Hour},
+			{Delay: 24 * time.Hour},
+		},
+		ConversionWindow: 7 * 24 * time.Hour,
+		ScanInterval:     10 * time.Minute,
+		BatchSize:        200,
+	}
+}
+
+// CouponIssuer 弃购提醒发券，返回发放的券码
+type CouponIssuer interface {
+	Issue(userID, couponID uint) (string, error)
+}
+
+// ReminderNotifier 弃购提醒通知，向用户推送购物车提醒
+type ReminderNotifier interface {
+	Notify(reminder *model.CartReminder, items []model.CartItem, message string)
+}
+
+// logReminderNotifier 默认通知实现，仅记录日志
+type logReminderNotifier struct{}
+
+// Notify 记录通知日志
+func (logReminderNotifier) Notify(reminder *model.CartReminder, items []model.CartItem, message string) {
+	logger.Info("弃购提醒",
+		zap.Uint("user_id", reminder.UserID),
+		zap.Uint("cart_id", reminder.CartID),
+		zap.Int("stage", reminder.Stage),
+		zap.String("coupon_code", reminder.CouponCode),
+		zap.String("message", message))
+}
+
+// AbandonmentService 购物车弃购追踪服务
+// 闲置超过配置时长的购物车标记为弃购，按轮次向登录用户发送提醒（可附带优惠券），
+// 提醒后窗口期内的首笔订单计为该提醒的转化
+type AbandonmentService struct {
+	db       *gorm.DB
+	issuer   CouponIssuer
+	notifier ReminderNotifier
+	options  AbandonmentOptions
+	now      func() time.Time
+}
+
+// NewAbandonmentService 创建弃购追踪服务
+func NewAbandonmentService(db *gorm.DB, options AbandonmentOptions) *AbandonmentService {
+	return &AbandonmentService{
+		db:       db,
+		notifier: logReminderNotifier{},
+		options:  options,
+		now:      time.Now,
+	}
+}
+
+// SetCouponIssuer 设置发券实现，未设置时提醒不附带优惠券
+func (s *AbandonmentService) SetCouponIssuer(issuer CouponIssuer) {
+	s.issuer = issuer
+}
+
+// SetNotifier 设置提醒通知实现
+func (s *AbandonmentService) SetNotifier(notifier ReminderNotifier) {
+	s.notifier = notifier
+}
+
+// MarkAbandoned 将闲置超时且有商品的购物车标记为弃购，返回本轮标记数
+func (s *AbandonmentService) MarkAbandoned() int {
+	now := s.now()
+
+	// 早于活跃度追踪创建的购物车以更新时间作为最后活跃时间
+	if err := s.db.Model(&model.Cart{}).
+		Where("last_activity_at IS NULL").
+		Update("last_activity_at", gorm.Expr("updated_at")).Error; err != nil {
+		logger.Error("补齐购物车活跃时间失败", zap.Error(err))
+		return 0
+	}
+
+	var ids []uint
+	if err := s.db.Model(&model.Cart{}).
+		Where("status = ? AND abandoned_at IS NULL AND item_count > 0 AND last_activity_at <= ?",
+			model.CartStatusActive, now.Add(-s.options.AbandonAfter)).
+		Order("id").Limit(s.options.BatchSize).
+		Pluck("id", &ids).Error; err != nil {
+		logger.Error("查询闲置购物车失败", zap.Error(err))
+		return 0
+	}
+	if len(ids) == 0 {
+		return 0
+	}
+
+	// 更新列显式列出，避免自动写入 updated_at
+	result := s.db.Model(&model.Cart{}).
+		Where("id IN ? AND abandoned_at IS NULL", ids).
+		UpdateColumn("abandoned_at", now)
+	if result.Error != nil {
+		logger.Error("标记弃购购物车失败", zap.Error(result.Error))
+		return 0
+	}
+	return len(ids)
+}
+
+// SendReminders 向到达提醒时间的弃购购物车发送下一轮提醒，返回本轮处理的购物车数
+func (s *AbandonmentService) SendReminders() int {
+	now := s.now()
+	processed := 0
+
+	for i, stage := range s.options.Reminders {
+		var carts []model.Cart
+		if err := s.db.Where("status = ? AND user_id > 0 AND abandoned_at IS NOT NULL AND reminders_sent = ? AND last_activity_at <= ?",
+			model.CartStatusActive, i, now.Add(-stage.Delay)).
+			Order("id").Limit(s.options.BatchSize - processed).
+			Find(&carts).Error; err != nil {
+			logger.Error("查询待提醒购物车失败", zap.Int("stage", i+1), zap.Error(err))
+			continue
+		}
+
+		for j := range carts {
+			if err := s.remind(&carts[j], i+1, stage, now); err != nil {
+				logger.Error("发送弃购提醒失败",
+					zap.Uint("cart_id", carts[j].ID),
+					zap.Int("stage", i+1),
+					zap.Error(err))
+			}
+		}
+
+		processed += len(carts)
+		if processed >= s.options.BatchSize {
+			break
+		}
+	}
+
+	return processed
+}
+
+// remind 认领并发送单个购物车的提醒，认领后即使未发送也不再重复处理该轮次
+func (s *AbandonmentService) remind(cart *model.Cart, stage int, config ReminderStage, now time.Time) error {
+	result := s.db.Model(&model.Cart{}).
+		Where("id = ? AND reminders_sent = ?", cart.ID, stage-1).
+		UpdateColumn("reminders_sent", stage)
+	if result.Error != nil {
+		return fmt.Errorf("认领购物车失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	var items []model.CartItem
+	if err := s.db.Where("cart_id = ? AND status = ?", cart.ID, model.CartItemStatusNormal).
+		Find(&items).Error; err != nil {
+		return fmt.Errorf("查询购物车商品失败: %v", err)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	// 最后一次修改后已下单的用户不再提醒
+	var ordered int64
+	if err := s.db.Model(&model.Order{}).
+		Where("user_id = ? AND created_at > ?", cart.UserID, cart.LastActivityAt).
+		Count(&ordered).Error; err != nil {
+		return fmt.Errorf("查询用户订单失败: %v", err)
+	}
+	if ordered > 0 {
+		return nil
+	}
+
+	amount := decimal.Zero
+	for _, item := range items {
+		amount = amount.Add(item.Price.Mul(decimal.NewFromInt(int64(item.Quantity))))
+	}
+
+	reminder := &model.CartReminder{
+		CartID:     cart.ID,
+		UserID:     cart.UserID,
+		Stage:      stage,
+		ItemCount:  len(items),
+		CartAmount: amount,
+		SentAt:     now,
+	}
+
+	if config.CouponID > 0 && s.issuer != nil {
+		code, err := s.issuer.Issue(cart.UserID, config.CouponID)
+		if err != nil {
+			// 发券失败不影响提醒发送
+			logger.Warn("弃购提醒发券失败",
+				zap.Uint("user_id", cart.UserID),
+				zap.Uint("coupon_id", config.CouponID),
+				zap.Error(err))
+		} else {
+			reminder.CouponID = config.CouponID
+			reminder.CouponCode = code
+		}
+	}
+
+	if err := s.db.Create(reminder).Error; err != nil {
+		return fmt.Errorf("保存提醒记录失败: %v", err)
+	}
+
+	message := fmt.Sprintf("您的购物车中还有%d件商品等待结算", len(items))
+	if reminder.CouponCode != "" {
+		message += fmt.Sprintf("，已为您发放优惠券%s", reminder.CouponCode)
+	}
+	s.notifier.Notify(reminder, items, message)
+
+	return nil
+}
+
+// AttributeConversions 将提醒后窗口期内的首笔有效订单归因到最近一次提醒，返回本轮转化数
+func (s *AbandonmentService) AttributeConversions() int {
+	now := s.now()
+	converted := 0
+
+	var reminders []model.CartReminder
+	err := s.db.Where("converted_at IS NULL AND sent_at >= ?", now.Add(-s.options.ConversionWindow)).
+		Order("id").
+		FindInBatches(&reminders, s.options.BatchSize, func(tx *gorm.DB, batch int) error {
+			for i := range reminders {
+				ok, err := s.attribute(&reminders[i])
+				if err != nil {
+					logger.Error("弃购提醒转化归因失败", zap.Uint("reminder_id", reminders[i].ID), zap.Error(err))
+					continue
+				}
+				if ok {
+					converted++
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		logger.Error("查询待归因提醒失败", zap.Error(err))
+	}
+
+	return converted
+}
+
+// attribute 归因单个提醒，订单已由同一购物车更晚的提醒覆盖时不计入
+func (s *AbandonmentService) attribute(reminder *model.CartReminder) (bool, error) {
+	var order model.Order
+	err := s.db.Where("user_id = ? AND created_at > ? AND created_at <= ? AND status NOT IN ?",
+		reminder.UserID, reminder.SentAt, reminder.SentAt.Add(s.options.ConversionWindow),
+		[]string{model.OrderStatusCancelled, model.OrderStatusClosed}).
+		Order("created_at, id").
+		First(&order).Error
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("查询转化订单失败: %v", err)
+	}
+
+	var later int64
+	if err := s.db.Model(&model.CartReminder{}).
+		Where("cart_id = ? AND id <> ? AND sent_at > ? AND sent_at < ?",
+			reminder.CartID, reminder.ID, reminder.SentAt, order.CreatedAt).
+		Count(&later).Error; err != nil {
+		return false, fmt.Errorf("查询后续提醒失败: %v", err)
+	}
+	if later > 0 {
+		return false, nil
+	}
+
+	result := s.db.Model(&model.CartReminder{}).
+		Where("id = ? AND converted_at IS NULL", reminder.ID).
+		Updates(map[string]interface{}{
+			"order_id":         order.ID,
+			"converted_at":     order.CreatedAt,
+			"converted_amount": order.PayableAmount,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("保存转化结果失败: %v", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetStats 购物车统计，日期范围按购物车创建时间和提醒发送时间筛选
+// 转化率为收到提醒的购物车中产生转化的比例
+func (s *AbandonmentService) GetStats(req *model.CartStatsRequest) (*model.CartStatsResponse, error) {
+	from, to, err := parseStatsRange(req.DateFrom, req.DateTo)
+	if err != nil {
+		return nil, err
+	}
+
+	carts := s.db.Model(&model.Cart{})
+	reminders := s.db.Model(&model.CartReminder{})
+	if req.UserID > 0 {
+		carts = carts.Where("carts.user_id = ?", req.UserID)
+		reminders = reminders.Where("user_id = ?", req.UserID)
+	}
+	if req.SessionID != "" {
+		carts = carts.Where("carts.session_id = ?", req.SessionID)
+	}
+	if !from.IsZero() {
+		carts = carts.Where("carts.created_at >= ?", from)
+		reminders = reminders.Where("sent_at >= ?", from)
+	}
+	if !to.IsZero() {
+		carts = carts.Where("carts.created_at < ?", to)
+		reminders = reminders.Where("sent_at < ?", to)
+	}
+
+	stats := &model.CartStatsResponse{
+		TopProducts: []model.ProductStats{},
+		CartsByHour: []model.HourlyStats{},
+		Reminders:   []model.ReminderStageStats{},
+	}
+
+	if err := carts.Session(&gorm.Session{}).Count(&stats.TotalCarts).Error; err != nil {
+		return nil, fmt.Errorf("统计购物车数失败: %v", err)
+	}
+	if err := carts.Session(&gorm.Session{}).
+		Where("carts.status = ? AND carts.abandoned_at IS NULL", model.CartStatusActive).
+		Count(&stats.ActiveCarts).Error; err != nil {
+		return nil, fmt.Errorf("统计活跃购物车失败: %v", err)
+	}
+	if err := carts.Session(&gorm.Session{}).
+		Where("carts.status = ? AND carts.abandoned_at IS NOT NULL", model.CartStatusActive).
+		Count(&stats.AbandonedCarts).Error; err != nil {
+		return nil, fmt.Errorf("统计弃购购物车失败: %v", err)
+	}
+
+	var summaries []struct {
+		ItemCount   int
+		TotalAmount decimal.Decimal
+		CreatedAt   time.Time
+	}
+	if err := carts.Session(&gorm.Session{}).
+		Select("carts.item_count, carts.total_amount, carts.created_at").
+		Scan(&summaries).Error; err != nil {
+		return nil, fmt.Errorf("查询购物车汇总失败: %v", err)
+	}
+	hours := make([]int, 24)
+	totalItems := 0
+	totalAmount := decimal.Zero
+	for _, summary := range summaries {
+		totalItems += summary.ItemCount
+		totalAmount = totalAmount.Add(summary.TotalAmount)
+		hours[summary.CreatedAt.Hour()]++
+	}
+	if len(summaries) > 0 {
+		stats.AverageItems = float64(totalItems) / float64(len(summaries))
+		stats.AverageAmount = totalAmount.Div(decimal.NewFromInt(int64(len(summaries)))).Round(2)
+	}
+	for hour, count := range hours {
+		if count > 0 {
+			stats.CartsByHour = append(stats.CartsByHour, model.HourlyStats{Hour: hour, Count: int64(count)})
+		}
+	}
+
+	if err := carts.Session(&gorm.Session{}).
+		Select("cart_items.product_id, MAX(cart_items.product_name) AS product_name, COUNT(*) AS add_count, COALESCE(SUM(cart_items.quantity), 0) AS total_qty").
+		Joins("JOIN cart_items ON cart_items.cart_id = carts.id").
+		Group("cart_items.product_id").
+		Order("add_count DESC, cart_items.product_id").
+		Limit(10).
+		Scan(&stats.TopProducts).Error; err != nil {
+		return nil, fmt.Errorf("统计热门商品失败: %v", err)
+	}
+
+	var sent []model.CartReminder
+	if err := reminders.Order("stage").Find(&sent).Error; err != nil {
+		return nil, fmt.Errorf("查询提醒记录失败: %v", err)
+	}
+	stages := make(map[int]int)
+	remindedCarts := make(map[uint]bool)
+	convertedCarts := make(map[uint]bool)
+	for _, reminder := range sent {
+		index, ok := stages[reminder.Stage]
+		if !ok {
+			index = len(stats.Reminders)
+			stages[reminder.Stage] = index
+			stats.Reminders = append(stats.Reminders, model.ReminderStageStats{Stage: reminder.Stage, ConvertedAmount: decimal.Zero})
+		}
+		stage := &stats.Reminders[index]
+		stage.Sent++
+		if reminder.CouponID > 0 {
+			stage.CouponIssued++
+		}
+		remindedCarts[reminder.CartID] = true
+		if reminder.ConvertedAt != nil {
+			stage.Converted++
+			stage.ConvertedAmount = stage.ConvertedAmount.Add(reminder.ConvertedAmount)
+			convertedCarts[reminder.CartID] = true
+		}
+	}
+	for i := range stats.Reminders {
+		stats.Reminders[i].ConversionRate = float64(stats.Reminders[i].Converted) / float64(stats.Reminders[i].Sent)
+	}
+	if len(remindedCarts) > 0 {
+		stats.ConversionRate = float64(len(convertedCarts)) / float64(len(remindedCarts))
+	}
+
+	return stats, nil
+}
+
+// parseStatsRange 解析统计日期范围，截止日期当天计入范围
+func parseStatsRange(dateFrom, dateTo string) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+	if dateFrom != "" {
+		if from, err = time.ParseInLocation("2006-01-02", dateFrom, time.Local); err != nil {
+			return from, to, model.ErrInvalidCartStatsDate
+		}
+	}
+	if dateTo != "" {
+		if to, err = time.ParseInLocation("2006-01-02", dateTo, time.Local); err != nil {
+			return from, to, model.ErrInvalidCartStatsDate
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
@@ -0,0 +1,112 @@
+package cart
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakeCouponIssuer struct {
+	issued []uint
+}
+
+func (f *fakeCouponIssuer) Issue(userID, couponID uint) (string, error) {
+	f.issued = append(f.issued, userID)
+	return fmt.Sprintf("CART-%d-%d", couponID, userID), nil
+}
+
+type recordReminderNotifier struct {
+	reminders []model.CartReminder
+}
+
+func (n *recordReminderNotifier) Notify(reminder *model.CartReminder, items []model.CartItem, message string) {
+	n.reminders = append(n.reminders, *reminder)
+}
+
+func setupAbandonmentTest(t *testing.T) (*gorm.DB, *CartService, *AbandonmentService, *recordReminderNotifier, *model.Product) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.Cart{}, &model.CartItem{}, &model.CartReminder{}, &model.Product{}, &model.ProductSKU{}, &model.Order{}))
+
+	product := &model.Product{Name: "测试商品", Price: decimal.NewFromInt(20), Stock: 100, Status: model.ProductStatusActive}
+	require.NoError(t, db.Create(product).Error)
+
+	options := DefaultAbandonmentOptions()
+	options.Reminders[0].CouponID = 7
+	service := NewAbandonmentService(db, options)
+	service.SetCouponIssuer(&fakeCouponIssuer{})
+	notifier := &recordReminderNotifier{}
+	service.SetNotifier(notifier)
+	return db, NewCartService(db), service, notifier, product
+}
+
+func TestAbandonmentService_RemindersAndConversion(t *testing.T) {
+	db, cartService, service, notifier, product := setupAbandonmentTest(t)
+	start := time.Now()
+	service.now = func() time.Time { return start.Add(time.Hour) }
+
+	addItem(t, cartService, 1, "", product.ID, 2)
+	addItem(t, cartService, 2, "", product.ID, 1)
+	addItem(t, cartService, 0, "guest", product.ID, 1)
+
+	// 未到闲置时长不标记
+	assert.Equal(t, 0, service.MarkAbandoned())
+
+	service.now = func() time.Time { return start.Add(3 * time.Hour) }
+	assert.Equal(t, 3, service.MarkAbandoned())
+	assert.Equal(t, 2, service.SendReminders())
+	require.Len(t, notifier.reminders, 2)
+	assert.Equal(t, 1, notifier.reminders[0].Stage)
+	assert.Equal(t, "CART-7-1", notifier.reminders[0].CouponCode)
+	assert.True(t, notifier.reminders[0].CartAmount.Equal(decimal.NewFromInt(40)))
+	// 同一轮次不重复发送，游客购物车不提醒
+	assert.Equal(t, 0, service.SendReminders())
+
+	// 用户1提醒后下单，计为第一轮提醒的转化
+	order := &model.Order{OrderNo: "O1", UserID: 1, Status: model.OrderStatusPaid, PaymentStatus: "paid",
+		TotalAmount: decimal.NewFromInt(40), PayableAmount: decimal.NewFromInt(38), CreatedAt: start.Add(4 * time.Hour)}
+	require.NoError(t, db.Create(order).Error)
+	service.now = func() time.Time { return start.Add(5 * time.Hour) }
+	assert.Equal(t, 1, service.AttributeConversions())
+	assert.Equal(t, 0, service.AttributeConversions())
+
+	// 第二轮只提醒未下单的用户2
+	service.now = func() time.Time { return start.Add(25 * time.Hour) }
+	service.SendReminders()
+	require.Len(t, notifier.reminders, 3)
+	assert.Equal(t, uint(2), notifier.reminders[2].UserID)
+	assert.Equal(t, 2, notifier.reminders[2].Stage)
+	assert.Empty(t, notifier.reminders[2].CouponCode)
+
+	stats, err := service.GetStats(&model.CartStatsRequest{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, stats.TotalCarts)
+	assert.EqualValues(t, 3, stats.AbandonedCarts)
+	assert.Equal(t, 0.5, stats.ConversionRate)
+	require.Len(t, stats.TopProducts, 1)
+	assert.EqualValues(t, 4, stats.TopProducts[0].TotalQty)
+	require.Len(t, stats.Reminders, 2)
+	assert.EqualValues(t, 2, stats.Reminders[0].Sent)
+	assert.EqualValues(t, 2, stats.Reminders[0].CouponIssued)
+	assert.EqualValues(t, 1, stats.Reminders[0].Converted)
+	assert.True(t, stats.Reminders[0].ConvertedAmount.Equal(decimal.NewFromInt(38)))
+	assert.EqualValues(t, 1, stats.Reminders[1].Sent)
+
+	// 再次修改购物车后恢复为活跃
+	addItem(t, cartService, 2, "", product.ID, 1)
+	var cart model.Cart
+	require.NoError(t, db.Where("user_id = ?", 2).First(&cart).Error)
+	assert.Nil(t, cart.AbandonedAt)
+	assert.Equal(t, 0, cart.RemindersSent)
+
+	_, err = service.GetStats(&model.CartStatsRequest{DateFrom: "2024/01/01"})
+	assert.ErrorIs(t, err, model.ErrInvalidCartStatsDate)
+}
@@ -286,6 +286,14 @@ func (cs *CacheService) clearCartCache(userID uint, sessionID string) {
 	cs.rdb.Del(cs.ctx, cartKey, countKey)
 }
 
+// DeleteGuestCart 删除游客购物车缓存，游客购物车合并到用户购物车后调用
+func (cs *CacheService) DeleteGuestCart(sessionID string) error {
+	if err := cs.rdb.Del(cs.ctx, cs.getCartCacheKey(0, sessionID), cs.getCartCountKey(0, sessionID)).Err(); err != nil {
+		return fmt.Errorf("删除游客购物车缓存失败: %v", err)
+	}
+	return nil
+}
+
 // acquireLock 获取分布式锁
 func (cs *CacheService) acquireLock(lockKey string, expire time.Duration) (string, error) {
 	lockValue := fmt.Sprintf("%d", time.Now().UnixNano())
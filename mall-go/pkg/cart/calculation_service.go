@@ -5,20 +5,31 @@ import (
 	"math"
 
 	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/member"
+	"mall-go/pkg/pricelist"
+	"mall-go/pkg/promotion"
 
 	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 // CalculationService 购物车计算服务
 type CalculationService struct {
-	db *gorm.DB
+	db               *gorm.DB
+	promotionService *promotion.Service
+	memberService    *member.Service
+	priceListService *pricelist.Service
 }
 
 // NewCalculationService 创建购物车计算服务
 func NewCalculationService(db *gorm.DB) *CalculationService {
 	return &CalculationService{
-		db: db,
+		db:               db,
+		promotionService: promotion.NewService(db),
+		memberService:    member.NewService(db, member.DefaultOptions()),
+		priceListService: pricelist.NewService(db),
 	}
 }
 
@@ -34,6 +45,9 @@ type CartCalculation struct {
 	MemberDiscount    decimal.Decimal `json:"member_discount"`    // 会员折扣
 	TotalDiscount     decimal.Decimal `json:"total_discount"`     // 总折扣
 
+	// 会员等级：决定会员折扣、包邮门槛和积分倍数
+	MemberLevel *model.MemberLevel `json:"member_level,omitempty"`
+
 	// 运费信息
 	ShippingFee           decimal.Decimal `json:"shipping_fee"`            // 运费
 	FreeShippingThreshold decimal.Decimal `json:"free_shipping_threshold"` // 包邮门槛
@@ -59,19 +73,12 @@ type CartCalculation struct {
 	EarnPoints     int             `json:"earn_points"`     // 可获得积分
 	UsedPoints     int             `json:"used_points"`     // 使用积分
 	PointsDiscount decimal.Decimal `json:"points_discount"` // 积分抵扣金额
-}
 
-// PromotionRule 促销规则
-type PromotionRule struct {
-	ID          uint            `json:"id"`
-	Name        string          `json:"name"`
-	Type        string          `json:"type"`         // full_reduction, discount, gift
-	Threshold   decimal.Decimal `json:"threshold"`    // 门槛金额
-	Discount    decimal.Decimal `json:"discount"`     // 折扣金额或比例
-	MaxDiscount decimal.Decimal `json:"max_discount"` // 最大折扣金额
-	StartTime   string          `json:"start_time"`
-	EndTime     string          `json:"end_time"`
-	Status      string          `json:"status"`
+	// 促销明细：各商品命中的促销、赠品及凑单建议
+	Promotion *promotion.Result `json:"promotion,omitempty"`
+
+	// 客户分组价：按购物车商品项ID，金额已按分组价计算
+	CustomerPrices map[uint]*model.CustomerPrice `json:"customer_prices,omitempty"`
 }
 
 // ShippingRule 运费规则
@@ -107,17 +114,22 @@ func (cs *CalculationService) CalculateCart(cart *model.Cart, userID uint, regio
 		PointsDiscount:        decimal.Zero,
 	}
 
+	// 按用户所属分组的价目表定价
+	if userID > 0 {
+		cart = cs.applyCustomerPrices(cart, userID, calculation)
+	}
+
 	// 计算基础金额和统计信息
 	cs.calculateBasicAmount(cart, calculation)
 
-	// 计算会员折扣
+	// 计算促销折扣
+	cs.calculatePromotionDiscount(cart, calculation)
+
+	// 计算会员折扣，在促销后的金额上优惠
 	if userID > 0 {
 		cs.calculateMemberDiscount(userID, calculation)
 	}
 
-	// 计算促销折扣
-	cs.calculatePromotionDiscount(cart, calculation)
-
 	// 计算运费
 	cs.calculateShippingFee(cart, region, calculation)
 
@@ -133,6 +145,30 @@ func (cs *CalculationService) CalculateCart(cart *model.Cart, userID uint, regio
 	return calculation, nil
 }
 
+// applyCustomerPrices 返回按分组价定价的购物车副本，传入的购物车可能正在异步写入缓存，不能修改
+func (cs *CalculationService) applyCustomerPrices(cart *model.Cart, userID uint, calc *CartCalculation) *model.Cart {
+	items := make([]model.CartItem, len(cart.Items))
+	copy(items, cart.Items)
+	prices, err := cs.priceListService.ApplyToCartItems(userID, items)
+	if err != nil {
+		logger.Warn("计算客户分组价失败", zap.Uint("user_id", userID), zap.Error(err))
+		return cart
+	}
+
+	for i, price := range prices {
+		if price == nil {
+			continue
+		}
+		if calc.CustomerPrices == nil {
+			calc.CustomerPrices = make(map[uint]*model.CustomerPrice)
+		}
+		calc.CustomerPrices[items[i].ID] = price
+	}
+	priced := *cart
+	priced.Items = items
+	return &priced
+}
+
 // calculateBasicAmount 计算基础金额
 func (cs *CalculationService) calculateBasicAmount(cart *model.Cart, calc *CartCalculation) {
 	for _, item := range cart.Items {
@@ -158,81 +194,49 @@ func (cs *CalculationService) calculateBasicAmount(cart *model.Cart, calc *CartC
 	}
 }
 
-// calculateMemberDiscount 计算会员折扣
+// calculateMemberDiscount 按用户当前会员等级计算会员折扣和包邮门槛
 func (cs *CalculationService) calculateMemberDiscount(userID uint, calc *CartCalculation) {
-	// 查询用户会员等级
-	var user model.User
-	if err := cs.db.First(&user, userID).Error; err != nil {
+	level, err := cs.memberService.Level(userID)
+	if err != nil {
+		logger.Warn("查询会员等级失败", zap.Uint("user_id", userID), zap.Error(err))
 		return
 	}
-
-	// 根据会员等级计算折扣
-	discountRate := decimal.Zero
-	switch user.Role {
-	case "vip1":
-		discountRate = decimal.NewFromFloat(0.05) // 5%折扣
-	case "vip2":
-		discountRate = decimal.NewFromFloat(0.08) // 8%折扣
-	case "vip3":
-		discountRate = decimal.NewFromFloat(0.10) // 10%折扣
+	if level == nil {
+		return
 	}
 
-	if discountRate.GreaterThan(decimal.Zero) {
-		calc.MemberDiscount = calc.SelectedAmount.Mul(discountRate)
+	calc.MemberLevel = level
+	calc.FreeShippingThreshold = level.FreeShippingThreshold
+	if level.DiscountRate.GreaterThan(decimal.Zero) {
+		calc.MemberDiscount = calc.SelectedAmount.Sub(calc.PromotionDiscount).Mul(level.DiscountRate).Round(2)
 	}
 }
 
 // calculatePromotionDiscount 计算促销折扣
 func (cs *CalculationService) calculatePromotionDiscount(cart *model.Cart, calc *CartCalculation) {
-	// 这里简化处理，实际应该查询数据库中的促销规则
-	promotions := []PromotionRule{
-		{
-			ID:        1,
-			Name:      "满100减10",
-			Type:      "full_reduction",
-			Threshold: decimal.NewFromFloat(100.0),
-			Discount:  decimal.NewFromFloat(10.0),
-			Status:    "active",
-		},
-		{
-			ID:        2,
-			Name:      "满200减25",
-			Type:      "full_reduction",
-			Threshold: decimal.NewFromFloat(200.0),
-			Discount:  decimal.NewFromFloat(25.0),
-			Status:    "active",
-		},
-		{
-			ID:        3,
-			Name:      "满500减60",
-			Type:      "full_reduction",
-			Threshold: decimal.NewFromFloat(500.0),
-			Discount:  decimal.NewFromFloat(60.0),
-			Status:    "active",
-		},
+	result, err := cs.evaluatePromotions(cart)
+	if err != nil {
+		logger.Warn("计算购物车促销失败", zap.Uint("cart_id", cart.ID), zap.Error(err))
+		return
 	}
+	calc.Promotion = result
+	calc.PromotionDiscount = result.Discount
+}
 
-	// 找到最优促销规则
-	bestDiscount := decimal.Zero
-	for _, promotion := range promotions {
-		if promotion.Status == "active" && calc.SelectedAmount.GreaterThanOrEqual(promotion.Threshold) {
-			if promotion.Type == "full_reduction" {
-				if promotion.Discount.GreaterThan(bestDiscount) {
-					bestDiscount = promotion.Discount
-				}
-			} else if promotion.Type == "discount" {
-				discount := calc.SelectedAmount.Mul(promotion.Discount)
-				if promotion.MaxDiscount.GreaterThan(decimal.Zero) && discount.GreaterThan(promotion.MaxDiscount) {
-					discount = promotion.MaxDiscount
-				}
-				if discount.GreaterThan(bestDiscount) {
-					bestDiscount = discount
-				}
-			}
+// evaluatePromotions 按促销规则计算选中商品的优惠
+func (cs *CalculationService) evaluatePromotions(cart *model.Cart) (*promotion.Result, error) {
+	var lines []promotion.Line
+	for _, item := range cart.Items {
+		if item.Selected && item.Status == model.CartItemStatusNormal {
+			lines = append(lines, promotion.Line{
+				ProductID: item.ProductID,
+				SKUID:     item.SKUID,
+				Price:     item.Price,
+				Quantity:  item.Quantity,
+			})
 		}
 	}
-
-	calc.PromotionDiscount = bestDiscount
+	return cs.promotionService.Evaluate(lines)
 }
 
 // calculateShippingFee 计算运费
@@ -291,8 +295,12 @@ func (cs *CalculationService) calculatePoints(userID uint, calc *CartCalculation
 		return
 	}
 
-	// 简化处理：每消费1元获得1积分
-	calc.EarnPoints = int(calc.SelectedAmount.InexactFloat64())
+	// 每消费1元获得1积分，按会员等级的积分倍数放大
+	points := calc.SelectedAmount
+	if calc.MemberLevel != nil && calc.MemberLevel.PointsMultiplier.GreaterThan(decimal.Zero) {
+		points = points.Mul(calc.MemberLevel.PointsMultiplier)
+	}
+	calc.EarnPoints = int(points.IntPart())
 
 	// 这里可以添加积分使用逻辑
 	// calc.UsedPoints = ...
@@ -326,6 +334,9 @@ func (cs *CalculationService) CalculateCartWithCoupon(cart *model.Cart, userID u
 
 	// 应用优惠券
 	if couponID > 0 {
+		if err := cs.memberService.CouponAllowed(userID, couponID); err != nil {
+			return calculation, err
+		}
 		couponDiscount, err := cs.applyCoupon(couponID, userID, calculation.SelectedAmount)
 		if err != nil {
 			return calculation, err // 返回基础计算结果，但包含错误信息
@@ -412,31 +423,25 @@ func (cs *CalculationService) EstimateShipping(cart *model.Cart, region string)
 }
 
 // GetPromotionSuggestions 获取促销建议
-func (cs *CalculationService) GetPromotionSuggestions(selectedAmount decimal.Decimal) []map[string]interface{} {
+func (cs *CalculationService) GetPromotionSuggestions(cart *model.Cart) []map[string]interface{} {
 	suggestions := []map[string]interface{}{}
 
-	// 满减促销建议
-	promotions := []struct {
-		Threshold decimal.Decimal
-		Discount  decimal.Decimal
-		Name      string
-	}{
-		{decimal.NewFromFloat(100.0), decimal.NewFromFloat(10.0), "满100减10"},
-		{decimal.NewFromFloat(200.0), decimal.NewFromFloat(25.0), "满200减25"},
-		{decimal.NewFromFloat(500.0), decimal.NewFromFloat(60.0), "满500减60"},
-	}
-
-	for _, promo := range promotions {
-		if selectedAmount.LessThan(promo.Threshold) {
-			needAmount := promo.Threshold.Sub(selectedAmount)
+	// 未达门槛的促销凑单建议
+	selectedAmount := decimal.Zero
+	result, err := cs.evaluatePromotions(cart)
+	if err != nil {
+		logger.Warn("计算购物车促销失败", zap.Uint("cart_id", cart.ID), zap.Error(err))
+	} else {
+		selectedAmount = result.TotalAmount
+		for _, suggestion := range result.Suggestions {
 			suggestions = append(suggestions, map[string]interface{}{
-				"type":        "promotion",
-				"name":        promo.Name,
-				"need_amount": needAmount,
-				"discount":    promo.Discount,
-				"message":     fmt.Sprintf("再买%.2f元即可享受%s优惠", needAmount.InexactFloat64(), promo.Name),
+				"type":          "promotion",
+				"promotion_id":  suggestion.PromotionID,
+				"name":          suggestion.Name,
+				"need_amount":   suggestion.NeedAmount,
+				"need_quantity": suggestion.NeedQuantity,
+				"message":       suggestion.Message,
 			})
-			break // 只推荐最近的一个促销
 		}
 	}
 
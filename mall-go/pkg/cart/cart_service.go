@@ -371,13 +371,15 @@ func (cs *CartService) getOrCreateCart(userID uint, sessionID string) (*model.Ca
 	}
 
 	// 创建新购物车
+	now := time.Now()
 	cart = &model.Cart{
-		UserID:      userID,
-		SessionID:   sessionID,
-		Status:      model.CartStatusActive,
-		ItemCount:   0,
-		TotalQty:    0,
-		TotalAmount: decimal.Zero,
+		UserID:         userID,
+		SessionID:      sessionID,
+		Status:         model.CartStatusActive,
+		ItemCount:      0,
+		TotalQty:       0,
+		TotalAmount:    decimal.Zero,
+		LastActivityAt: &now,
 	}
 
 	if err := cs.db.Create(cart).Error; err != nil {
@@ -446,12 +448,16 @@ func (cs *CartService) updateCartSummary(cartID uint) error {
 		}
 	}
 
-	// 更新购物车统计
+	// 更新购物车统计，修改购物车即视为活跃，清除弃购标记并重新计算提醒轮次
+	now := time.Now()
 	return cs.db.Model(&cart).Updates(map[string]interface{}{
-		"item_count":   itemCount,
-		"total_qty":    totalQty,
-		"total_amount": totalAmount,
-		"updated_at":   time.Now(),
+		"item_count":       itemCount,
+		"total_qty":        totalQty,
+		"total_amount":     totalAmount,
+		"last_activity_at": now,
+		"abandoned_at":     nil,
+		"reminders_sent":   0,
+		"updated_at":       now,
 	}).Error
 }
 
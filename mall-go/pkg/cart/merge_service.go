@@ -0,0 +1,303 @@
+package cart
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// EnvMergePolicy 游客购物车合并冲突策略环境变量
+const EnvMergePolicy = "CART_MERGE_POLICY"
+
+// MergePolicy 游客购物车与用户购物车存在相同商品（SKU）时的数量处理策略
+type MergePolicy string
+
+const (
+	MergePolicySum      MergePolicy = "sum"       // 数量相加
+	MergePolicyMax      MergePolicy = "max"       // 取较大数量
+	MergePolicyKeepUser MergePolicy = "keep_user" // 保留用户购物车数量
+)
+
+// Valid 是否为支持的合并策略
+func (p MergePolicy) Valid() bool {
+	switch p {
+	case MergePolicySum, MergePolicyMax, MergePolicyKeepUser:
+		return true
+	}
+	return false
+}
+
+// MergeOptions 购物车合并配置
+type MergeOptions struct {
+	Policy MergePolicy // 冲突商品的数量策略
+}
+
+// DefaultMergeOptions 默认合并配置，策略可通过环境变量 CART_MERGE_POLICY 调整
+func DefaultMergeOptions() MergeOptions {
+	options := MergeOptions{Policy: MergePolicySum}
+	if policy := MergePolicy(os.Getenv(EnvMergePolicy)); policy.Valid() {
+		options.Policy = policy
+	}
+	return options
+}
+
+// GuestCartCache 游客购物车缓存，合并完成后删除
+type GuestCartCache interface {
+	DeleteGuestCart(sessionID string) error
+}
+
+// MergeService 游客购物车合并服务
+// 用户登录时将游客购物车并入用户购物车，合并后的数量不超过当前可用库存
+type MergeService struct {
+	db          *gorm.DB
+	cartService *CartService
+	options     MergeOptions
+	caches      []GuestCartCache
+}
+
+// NewMergeService 创建购物车合并服务
+func NewMergeService(db *gorm.DB, cartService *CartService, options MergeOptions) *MergeService {
+	if !options.Policy.Valid() {
+		options.Policy = MergePolicySum
+	}
+	return &MergeService{
+		db:          db,
+		cartService: cartService,
+		options:     options,
+	}
+}
+
+// AddGuestCartCache 添加合并后需要清理的游客购物车缓存
+func (ms *MergeService) AddGuestCartCache(cache GuestCartCache) {
+	ms.caches = append(ms.caches, cache)
+}
+
+// Policy 当前的冲突策略
+func (ms *MergeService) Policy() MergePolicy {
+	return ms.options.Policy
+}
+
+// Merge 将游客购物车合并到用户购物车，游客购物车不存在或已被合并时返回 nil
+func (ms *MergeService) Merge(userID uint, sessionID string) (*model.CartMergeLog, error) {
+	if userID == 0 || sessionID == "" {
+		return nil, nil
+	}
+
+	var mergeLog *model.CartMergeLog
+	err := ms.db.Transaction(func(tx *gorm.DB) error {
+		var guestCart model.Cart
+		if err := tx.Preload("Items").
+			Where("user_id = 0 AND session_id = ? AND status = ?", sessionID, model.CartStatusActive).
+			First(&guestCart).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return fmt.Errorf("查询游客购物车失败: %v", err)
+		}
+
+		// 先认领游客购物车，并发登录时只有一个请求执行合并
+		result := tx.Model(&model.Cart{}).
+			Where("id = ? AND status = ?", guestCart.ID, model.CartStatusActive).
+			Update("status", model.CartStatusMerged)
+		if result.Error != nil {
+			return fmt.Errorf("更新游客购物车状态失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		userCart, err := ms.userCart(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		merged, conflicts, err := ms.mergeItems(tx, userCart.ID, guestCart.Items)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("cart_id = ?", guestCart.ID).Delete(&model.CartItem{}).Error; err != nil {
+			return fmt.Errorf("删除游客购物车商品失败: %v", err)
+		}
+		if err := tx.Delete(&guestCart).Error; err != nil {
+			return fmt.Errorf("删除游客购物车失败: %v", err)
+		}
+
+		mergeLog = &model.CartMergeLog{
+			UserID:        userID,
+			SessionID:     sessionID,
+			GuestCartID:   guestCart.ID,
+			UserCartID:    userCart.ID,
+			MergedItems:   merged,
+			ConflictItems: conflicts,
+			Status:        model.CartMergeStatusSuccess,
+		}
+		if err := tx.Create(mergeLog).Error; err != nil {
+			return fmt.Errorf("记录购物车合并日志失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if mergeLog == nil {
+		return nil, nil
+	}
+
+	if err := ms.cartService.updateCartSummary(mergeLog.UserCartID); err != nil {
+		logger.Warn("更新购物车统计失败", zap.Uint("cart_id", mergeLog.UserCartID), zap.Error(err))
+	}
+	for _, cache := range ms.caches {
+		if err := cache.DeleteGuestCart(sessionID); err != nil {
+			logger.Warn("删除游客购物车缓存失败", zap.String("session_id", sessionID), zap.Error(err))
+		}
+	}
+
+	logger.Info("游客购物车合并完成",
+		zap.Uint("user_id", userID),
+		zap.Uint("guest_cart_id", mergeLog.GuestCartID),
+		zap.Uint("user_cart_id", mergeLog.UserCartID),
+		zap.Int("merged_items", mergeLog.MergedItems),
+		zap.Int("conflict_items", mergeLog.ConflictItems),
+		zap.String("policy", string(ms.options.Policy)))
+	return mergeLog, nil
+}
+
+// userCart 获取或创建用户购物车
+func (ms *MergeService) userCart(tx *gorm.DB, userID uint) (*model.Cart, error) {
+	var cart model.Cart
+	err := tx.Where("user_id = ? AND status = ?", userID, model.CartStatusActive).First(&cart).Error
+	if err == nil {
+		return &cart, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("查询用户购物车失败: %v", err)
+	}
+
+	cart = model.Cart{
+		UserID: userID,
+		Status: model.CartStatusActive,
+	}
+	if err := tx.Create(&cart).Error; err != nil {
+		return nil, fmt.Errorf("创建购物车失败: %v", err)
+	}
+	return &cart, nil
+}
+
+// mergeItems 将游客购物车商品逐项并入用户购物车，返回新增与冲突的商品项数量
+// 已下架或无库存的商品不合并；合并后的数量按当前可用库存截断
+func (ms *MergeService) mergeItems(tx *gorm.DB, userCartID uint, items []model.CartItem) (int, int, error) {
+	var userItems []model.CartItem
+	if err := tx.Where("cart_id = ?", userCartID).Find(&userItems).Error; err != nil {
+		return 0, 0, fmt.Errorf("查询购物车商品失败: %v", err)
+	}
+	type itemKey struct{ productID, skuID uint }
+	existingItems := make(map[itemKey]*model.CartItem, len(userItems))
+	for i := range userItems {
+		existingItems[itemKey{userItems[i].ProductID, userItems[i].SKUID}] = &userItems[i]
+	}
+
+	merged, conflicts := 0, 0
+	now := time.Now()
+	for _, item := range items {
+		stock, price, ok, err := availability(tx, item.ProductID, item.SKUID)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !ok || stock <= 0 {
+			continue
+		}
+
+		if existing, ok := existingItems[itemKey{item.ProductID, item.SKUID}]; ok {
+			conflicts++
+			quantity := resolveQuantity(ms.options.Policy, existing.Quantity, item.Quantity)
+			if quantity > stock {
+				quantity = stock
+			}
+			if quantity == existing.Quantity {
+				continue
+			}
+			if err := tx.Model(existing).Updates(map[string]interface{}{
+				"quantity":   quantity,
+				"price":      price,
+				"version":    gorm.Expr("version + 1"),
+				"updated_at": now,
+			}).Error; err != nil {
+				return 0, 0, fmt.Errorf("更新购物车商品失败: %v", err)
+			}
+			continue
+		}
+
+		quantity := item.Quantity
+		if quantity > stock {
+			quantity = stock
+		}
+		newItem := item
+		newItem.ID = 0
+		newItem.CartID = userCartID
+		newItem.Quantity = quantity
+		newItem.Price = price
+		newItem.Status = model.CartItemStatusNormal
+		newItem.Version = 1
+		newItem.CreatedAt = time.Time{}
+		newItem.UpdatedAt = time.Time{}
+		if err := tx.Create(&newItem).Error; err != nil {
+			return 0, 0, fmt.Errorf("合并购物车商品失败: %v", err)
+		}
+		// Selected 字段带默认值，未选中的状态需要单独写入
+		if !item.Selected {
+			if err := tx.Model(&newItem).Update("selected", false).Error; err != nil {
+				return 0, 0, fmt.Errorf("合并购物车商品失败: %v", err)
+			}
+		}
+		existingItems[itemKey{item.ProductID, item.SKUID}] = &newItem
+		merged++
+	}
+	return merged, conflicts, nil
+}
+
+// resolveQuantity 按策略计算冲突商品的合并数量
+func resolveQuantity(policy MergePolicy, userQty, guestQty int) int {
+	switch policy {
+	case MergePolicyMax:
+		if guestQty > userQty {
+			return guestQty
+		}
+		return userQty
+	case MergePolicyKeepUser:
+		return userQty
+	default:
+		return userQty + guestQty
+	}
+}
+
+// availability 查询商品（SKU）当前的可用库存与价格，ok 为 false 表示已下架或不存在
+func availability(tx *gorm.DB, productID, skuID uint) (int, decimal.Decimal, bool, error) {
+	var product model.Product
+	if err := tx.Where("id = ? AND status = ?", productID, model.ProductStatusActive).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, decimal.Zero, false, nil
+		}
+		return 0, decimal.Zero, false, fmt.Errorf("查询商品失败: %v", err)
+	}
+	if skuID == 0 {
+		return product.Stock, product.Price, true, nil
+	}
+
+	var sku model.ProductSKU
+	if err := tx.Where("id = ? AND product_id = ? AND status = ?", skuID, productID, model.SKUStatusActive).First(&sku).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, decimal.Zero, false, nil
+		}
+		return 0, decimal.Zero, false, fmt.Errorf("查询商品规格失败: %v", err)
+	}
+	return sku.Stock, sku.Price, true, nil
+}
@@ -0,0 +1,138 @@
+package cart
+
+import (
+	"testing"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakeGuestCartCache struct {
+	deleted []string
+}
+
+func (f *fakeGuestCartCache) DeleteGuestCart(sessionID string) error {
+	f.deleted = append(f.deleted, sessionID)
+	return nil
+}
+
+func setupMergeTest(t *testing.T) (*gorm.DB, *CartService, []*model.Product) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.Cart{}, &model.CartItem{}, &model.CartMergeLog{}, &model.Product{}, &model.ProductSKU{}))
+
+	var products []*model.Product
+	for _, stock := range []int{5, 10, 10} {
+		product := &model.Product{Name: "测试商品", Price: decimal.NewFromInt(20), Stock: stock, Status: model.ProductStatusActive}
+		require.NoError(t, db.Create(product).Error)
+		products = append(products, product)
+	}
+	return db, NewCartService(db), products
+}
+
+func addItem(t *testing.T, cartService *CartService, userID uint, sessionID string, productID uint, quantity int) {
+	_, err := cartService.AddToCart(userID, sessionID, &model.AddToCartRequest{ProductID: productID, Quantity: quantity})
+	require.NoError(t, err)
+}
+
+func cartQuantities(t *testing.T, cartService *CartService, userID uint) map[uint]int {
+	resp, err := cartService.GetCart(userID, "", true)
+	require.NoError(t, err)
+	quantities := make(map[uint]int)
+	for _, item := range resp.Cart.Items {
+		quantities[item.ProductID] = item.Quantity
+	}
+	return quantities
+}
+
+func TestMergeService_Policies(t *testing.T) {
+	tests := []struct {
+		policy MergePolicy
+		want   int
+	}{
+		{MergePolicySum, 5}, // 3+4 按库存5截断
+		{MergePolicyMax, 4},
+		{MergePolicyKeepUser, 3},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			db, cartService, products := setupMergeTest(t)
+			addItem(t, cartService, 1, "", products[0].ID, 3)
+			addItem(t, cartService, 0, "guest", products[0].ID, 4)
+			addItem(t, cartService, 0, "guest", products[1].ID, 2)
+
+			cache := &fakeGuestCartCache{}
+			service := NewMergeService(db, cartService, MergeOptions{Policy: tt.policy})
+			service.AddGuestCartCache(cache)
+
+			mergeLog, err := service.Merge(1, "guest")
+			require.NoError(t, err)
+			require.NotNil(t, mergeLog)
+			assert.Equal(t, 1, mergeLog.MergedItems)
+			assert.Equal(t, 1, mergeLog.ConflictItems)
+			assert.Equal(t, model.CartMergeStatusSuccess, mergeLog.Status)
+			assert.Equal(t, []string{"guest"}, cache.deleted)
+
+			quantities := cartQuantities(t, cartService, 1)
+			assert.Equal(t, tt.want, quantities[products[0].ID])
+			assert.Equal(t, 2, quantities[products[1].ID])
+
+			// 游客购物车已删除，重复合并不再处理
+			var guestCarts int64
+			require.NoError(t, db.Model(&model.Cart{}).Where("session_id = ?", "guest").Count(&guestCarts).Error)
+			assert.Zero(t, guestCarts)
+			mergeLog, err = service.Merge(1, "guest")
+			require.NoError(t, err)
+			assert.Nil(t, mergeLog)
+		})
+	}
+}
+
+func TestMergeService_SkipsUnavailable(t *testing.T) {
+	db, cartService, products := setupMergeTest(t)
+	addItem(t, cartService, 0, "guest", products[0].ID, 5)
+	addItem(t, cartService, 0, "guest", products[1].ID, 8)
+	addItem(t, cartService, 0, "guest", products[2].ID, 1)
+
+	// 加购后库存减少、商品下架
+	require.NoError(t, db.Model(products[0]).Update("stock", 0).Error)
+	require.NoError(t, db.Model(products[1]).Update("stock", 6).Error)
+	require.NoError(t, db.Model(products[2]).Update("status", model.ProductStatusInactive).Error)
+
+	// 用户没有购物车时自动创建
+	mergeLog, err := NewMergeService(db, cartService, DefaultMergeOptions()).Merge(2, "guest")
+	require.NoError(t, err)
+	require.NotNil(t, mergeLog)
+	assert.Equal(t, 1, mergeLog.MergedItems)
+	assert.Zero(t, mergeLog.ConflictItems)
+
+	quantities := cartQuantities(t, cartService, 2)
+	assert.Equal(t, map[uint]int{products[1].ID: 6}, quantities)
+
+	var logs int64
+	require.NoError(t, db.Model(&model.CartMergeLog{}).Where("user_id = ?", 2).Count(&logs).Error)
+	assert.Equal(t, int64(1), logs)
+}
+
+func TestSessionSigner(t *testing.T) {
+	signer := NewSessionSigner("secret")
+	sessionID, value, err := signer.NewSession()
+	require.NoError(t, err)
+
+	got, ok := signer.Verify(value)
+	assert.True(t, ok)
+	assert.Equal(t, sessionID, got)
+
+	// 篡改会话ID或使用其他密钥签名均无效
+	_, ok = signer.Verify("other" + value[len(sessionID):])
+	assert.False(t, ok)
+	_, ok = NewSessionSigner("other").Verify(value)
+	assert.False(t, ok)
+	_, ok = signer.Verify(sessionID)
+	assert.False(t, ok)
+}
@@ -2,6 +2,8 @@ package cart
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"mall-go/internal/model"
 
@@ -9,18 +11,73 @@ import (
 	"gorm.io/gorm"
 )
 
+// 推荐类型
+const (
+	RecommendTypeItemBased       = "item_based"
+	RecommendTypeCartBased       = "cart_based"
+	RecommendTypeCategoryBased   = "category_based"
+	RecommendTypeBrandBased      = "brand_based"
+	RecommendTypePriceBased      = "price_based"
+	RecommendTypeComplementary   = "complementary"
+	RecommendTypePromotional     = "promotional"
+	RecommendTypePersonalHistory = "personal_history"
+	RecommendTypeDefault         = "default"
+)
+
+// RecommendationOptions 推荐混合配置
+// 混合结果中各策略的权重按购物车商品的相似商品覆盖率在 Weights 与 ColdStartWeights 之间插值，
+// 购物车商品均无相似商品（新品等冷启动商品）时完全使用 ColdStartWeights
+type RecommendationOptions struct {
+	Weights          map[string]float64 // 各推荐类型的权重
+	ColdStartWeights map[string]float64 // 冷启动时各推荐类型的权重
+}
+
+// DefaultRecommendationOptions 默认推荐混合配置
+func DefaultRecommendationOptions() RecommendationOptions {
+	return RecommendationOptions{
+		Weights: map[string]float64{
+			RecommendTypeItemBased:       1.0,
+			RecommendTypeCartBased:       0.3,
+			RecommendTypeCategoryBased:   0.3,
+			RecommendTypeBrandBased:      0.2,
+			RecommendTypePriceBased:      0.1,
+			RecommendTypeComplementary:   0.2,
+			RecommendTypePromotional:     0.1,
+			RecommendTypePersonalHistory: 0.3,
+			RecommendTypeDefault:         0.1,
+		},
+		ColdStartWeights: map[string]float64{
+			RecommendTypeCartBased:       0.5,
+			RecommendTypeCategoryBased:   0.6,
+			RecommendTypeBrandBased:      0.4,
+			RecommendTypePriceBased:      0.2,
+			RecommendTypeComplementary:   0.4,
+			RecommendTypePromotional:     0.3,
+			RecommendTypePersonalHistory: 0.5,
+			RecommendTypeDefault:         0.3,
+		},
+	}
+}
+
 // RecommendationService 购物车推荐服务
 type RecommendationService struct {
-	db *gorm.DB
+	db      *gorm.DB
+	options RecommendationOptions
 }
 
 // NewRecommendationService 创建购物车推荐服务
 func NewRecommendationService(db *gorm.DB) *RecommendationService {
 	return &RecommendationService{
-		db: db,
+		db:      db,
+		options: DefaultRecommendationOptions(),
 	}
 }
 
+// SetOptions 设置推荐混合配置
+func (rs *RecommendationService) SetOptions(options RecommendationOptions) {
+	rs.options = options
+}
+
 // RecommendationResult 推荐结果
 type RecommendationResult struct {
 	Products      []*model.Product `json:"products"`
@@ -31,13 +88,22 @@ type RecommendationResult struct {
 
 // RecommendationResponse 推荐响应
 type RecommendationResponse struct {
-	CartBased       []*RecommendationResult `json:"cart_based"`       // 基于购物车的推荐
-	CategoryBased   []*RecommendationResult `json:"category_based"`   // 基于分类的推荐
-	BrandBased      []*RecommendationResult `json:"brand_based"`      // 基于品牌的推荐
-	PriceBased      []*RecommendationResult `json:"price_based"`      // 基于价格的推荐
-	Complementary   []*RecommendationResult `json:"complementary"`    // 互补商品推荐
-	Promotional     []*RecommendationResult `json:"promotional"`      // 促销商品推荐
-	PersonalHistory []*RecommendationResult `json:"personal_history"` // 个人历史推荐
+	Blended         []*BlendedRecommendation `json:"blended"`          // 按权重混合各策略后的推荐
+	ItemBased       []*RecommendationResult  `json:"item_based"`       // 基于商品相似度的推荐
+	CartBased       []*RecommendationResult  `json:"cart_based"`       // 基于购物车的推荐
+	CategoryBased   []*RecommendationResult  `json:"category_based"`   // 基于分类的推荐
+	BrandBased      []*RecommendationResult  `json:"brand_based"`      // 基于品牌的推荐
+	PriceBased      []*RecommendationResult  `json:"price_based"`      // 基于价格的推荐
+	Complementary   []*RecommendationResult  `json:"complementary"`    // 互补商品推荐
+	Promotional     []*RecommendationResult  `json:"promotional"`      // 促销商品推荐
+	PersonalHistory []*RecommendationResult  `json:"personal_history"` // 个人历史推荐
+}
+
+// BlendedRecommendation 混合推荐商品
+type BlendedRecommendation struct {
+	Product *model.Product `json:"product"`
+	Score   float64        `json:"score"`   // 混合得分
+	Sources []string       `json:"sources"` // 贡献该商品的推荐类型
 }
 
 // ProductScore 商品评分
@@ -56,6 +122,7 @@ func (rs *RecommendationService) GetCartRecommendations(userID uint, sessionID s
 	}
 
 	response := &RecommendationResponse{
+		ItemBased:       []*RecommendationResult{},
 		CartBased:       []*RecommendationResult{},
 		CategoryBased:   []*RecommendationResult{},
 		BrandBased:      []*RecommendationResult{},
@@ -65,6 +132,12 @@ func (rs *RecommendationService) GetCartRecommendations(userID uint, sessionID s
 		PersonalHistory: []*RecommendationResult{},
 	}
 
+	// 基于商品相似度的推荐
+	itemBased, coverage, err := rs.getItemBasedRecommendations(cart, limit)
+	if err == nil {
+		response.ItemBased = itemBased
+	}
+
 	// 基于购物车内容的推荐
 	cartBased, err := rs.getCartBasedRecommendations(cart, limit)
 	if err == nil {
@@ -109,9 +182,124 @@ func (rs *RecommendationService) GetCartRecommendations(userID uint, sessionID s
 		}
 	}
 
+	response.Blended = rs.blend(response, coverage, limit)
 	return response, nil
 }
 
+// getItemBasedRecommendations 基于商品相似度的推荐
+// 汇总购物车商品的相似商品得分，coverage 为购物车商品中有相似商品的比例
+func (rs *RecommendationService) getItemBasedRecommendations(cart *model.Cart, limit int) ([]*RecommendationResult, float64, error) {
+	productIDs := make([]uint, 0)
+	inCart := make(map[uint]bool)
+	for _, item := range cart.Items {
+		if item.Status == model.CartItemStatusNormal && !inCart[item.ProductID] {
+			inCart[item.ProductID] = true
+			productIDs = append(productIDs, item.ProductID)
+		}
+	}
+	if len(productIDs) == 0 {
+		return []*RecommendationResult{}, 0, nil
+	}
+
+	var similarities []model.ProductSimilarity
+	if err := rs.db.Where("product_id IN ?", productIDs).Find(&similarities).Error; err != nil {
+		return []*RecommendationResult{}, 0, err
+	}
+
+	covered := make(map[uint]bool)
+	scores := make(map[uint]float64)
+	for _, similarity := range similarities {
+		covered[similarity.ProductID] = true
+		if !inCart[similarity.NeighborID] {
+			scores[similarity.NeighborID] += similarity.Score
+		}
+	}
+	coverage := float64(len(covered)) / float64(len(productIDs))
+	if len(scores) == 0 {
+		return []*RecommendationResult{}, coverage, nil
+	}
+
+	neighborIDs := make([]uint, 0, len(scores))
+	for id := range scores {
+		neighborIDs = append(neighborIDs, id)
+	}
+	var products []*model.Product
+	if err := rs.db.Where("id IN ? AND status = ?", neighborIDs, model.ProductStatusActive).
+		Preload("Images", func(db *gorm.DB) *gorm.DB {
+			return db.Where("is_main = ?", true).Order("sort ASC").Limit(1)
+		}).
+		Find(&products).Error; err != nil {
+		return []*RecommendationResult{}, coverage, err
+	}
+	sort.Slice(products, func(i, j int) bool {
+		if scores[products[i].ID] != scores[products[j].ID] {
+			return scores[products[i].ID] > scores[products[j].ID]
+		}
+		return products[i].ID < products[j].ID
+	})
+	if len(products) > limit {
+		products = products[:limit]
+	}
+
+	result := &RecommendationResult{
+		Products:      products,
+		Reason:        "购买或浏览过这些商品的用户也喜欢",
+		RecommendType: RecommendTypeItemBased,
+		Score:         0.95,
+	}
+
+	return []*RecommendationResult{result}, coverage, nil
+}
+
+// blend 按权重混合各策略的推荐结果
+// 商品在每个策略中的得分为 权重 × 策略得分 / 名次，同一商品累加各策略得分
+func (rs *RecommendationService) blend(response *RecommendationResponse, coverage float64, limit int) []*BlendedRecommendation {
+	groups := [][]*RecommendationResult{
+		response.ItemBased,
+		response.CartBased,
+		response.CategoryBased,
+		response.BrandBased,
+		response.PriceBased,
+		response.Complementary,
+		response.Promotional,
+		response.PersonalHistory,
+	}
+
+	blended := make(map[uint]*BlendedRecommendation)
+	var order []uint
+	for _, group := range groups {
+		for _, result := range group {
+			weight := coverage*rs.options.Weights[result.RecommendType] +
+				(1-coverage)*rs.options.ColdStartWeights[result.RecommendType]
+			if weight <= 0 {
+				continue
+			}
+			for rank, product := range result.Products {
+				item, ok := blended[product.ID]
+				if !ok {
+					item = &BlendedRecommendation{Product: product, Sources: []string{}}
+					blended[product.ID] = item
+					order = append(order, product.ID)
+				}
+				item.Score += weight * result.Score / float64(rank+1)
+				item.Sources = append(item.Sources, result.RecommendType)
+			}
+		}
+	}
+
+	items := make([]*BlendedRecommendation, 0, len(order))
+	for _, id := range order {
+		items = append(items, blended[id])
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Score > items[j].Score
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
 // getCartBasedRecommendations 基于购物车内容的推荐
 func (rs *RecommendationService) getCartBasedRecommendations(cart *model.Cart, limit int) ([]*RecommendationResult, error) {
 	if len(cart.Items) == 0 {
@@ -154,7 +342,7 @@ func (rs *RecommendationService) getCartBasedRecommendations(cart *model.Cart, l
 	result := &RecommendationResult{
 		Products:      relatedProducts,
 		Reason:        "经常一起购买的商品",
-		RecommendType: "cart_based",
+		RecommendType: RecommendTypeCartBased,
 		Score:         0.9,
 	}
 
@@ -201,7 +389,7 @@ func (rs *RecommendationService) getCategoryBasedRecommendations(cart *model.Car
 	result := &RecommendationResult{
 		Products:      categoryProducts,
 		Reason:        "同分类热销商品",
-		RecommendType: "category_based",
+		RecommendType: RecommendTypeCategoryBased,
 		Score:         0.8,
 	}
 
@@ -248,7 +436,7 @@ func (rs *RecommendationService) getBrandBasedRecommendations(cart *model.Cart,
 	result := &RecommendationResult{
 		Products:      brandProducts,
 		Reason:        "同品牌推荐商品",
-		RecommendType: "brand_based",
+		RecommendType: RecommendTypeBrandBased,
 		Score:         0.7,
 	}
 
@@ -291,7 +479,7 @@ func (rs *RecommendationService) getPriceBasedRecommendations(cart *model.Cart,
 	result := &RecommendationResult{
 		Products:      priceProducts,
 		Reason:        fmt.Sprintf("相似价位商品(%.2f-%.2f元)", minPrice.InexactFloat64(), maxPrice.InexactFloat64()),
-		RecommendType: "price_based",
+		RecommendType: RecommendTypePriceBased,
 		Score:         0.6,
 	}
 
@@ -315,7 +503,7 @@ func (rs *RecommendationService) getComplementaryRecommendations(cart *model.Car
 	for _, item := range cart.Items {
 		if item.Status == model.CartItemStatusNormal {
 			for keyword, complements := range complementaryMap {
-				if contains(item.ProductName, keyword) {
+				if strings.Contains(item.ProductName, keyword) {
 					complementaryKeywords = append(complementaryKeywords, complements...)
 				}
 			}
@@ -352,7 +540,7 @@ func (rs *RecommendationService) getComplementaryRecommendations(cart *model.Car
 	result := &RecommendationResult{
 		Products:      complementaryProducts,
 		Reason:        "互补商品推荐",
-		RecommendType: "complementary",
+		RecommendType: RecommendTypeComplementary,
 		Score:         0.8,
 	}
 
@@ -378,7 +566,7 @@ func (rs *RecommendationService) getPromotionalRecommendations(cart *model.Cart,
 	result := &RecommendationResult{
 		Products:      promotionalProducts,
 		Reason:        "热销推荐商品",
-		RecommendType: "promotional",
+		RecommendType: RecommendTypePromotional,
 		Score:         0.7,
 	}
 
@@ -410,7 +598,7 @@ func (rs *RecommendationService) getPersonalHistoryRecommendations(userID uint,
 	result := &RecommendationResult{
 		Products:      historyProducts,
 		Reason:        "基于购买历史推荐",
-		RecommendType: "personal_history",
+		RecommendType: RecommendTypePersonalHistory,
 		Score:         0.9,
 	}
 
@@ -432,13 +620,15 @@ func (rs *RecommendationService) getDefaultRecommendations(limit int) *Recommend
 	result := &RecommendationResult{
 		Products:      hotProducts,
 		Reason:        "热销商品推荐",
-		RecommendType: "default",
+		RecommendType: RecommendTypeDefault,
 		Score:         0.5,
 	}
 
-	return &RecommendationResponse{
+	response := &RecommendationResponse{
 		Promotional: []*RecommendationResult{result},
 	}
+	response.Blended = rs.blend(response, 0, limit)
+	return response
 }
 
 // getCart 获取购物车
@@ -461,24 +651,6 @@ func (rs *RecommendationService) getCart(userID uint, sessionID string) (*model.
 	return &cart, nil
 }
 
-// contains 检查字符串是否包含子字符串
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr ||
-		(len(s) > len(substr) &&
-			(s[:len(substr)] == substr ||
-				s[len(s)-len(substr):] == substr ||
-				containsSubstring(s, substr))))
-}
-
-func containsSubstring(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
-}
-
 // 全局购物车推荐服务实例
 var globalRecommendationService *RecommendationService
 
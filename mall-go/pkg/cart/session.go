@@ -0,0 +1,78 @@
+package cart
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"mall-go/pkg/logger"
+)
+
+// EnvGuestSessionSecret 游客购物车会话签名密钥环境变量
+const EnvGuestSessionSecret = "CART_SESSION_SECRET"
+
+// GuestSessionCookie 游客购物车会话Cookie名称
+const GuestSessionCookie = "mall_guest_cart"
+
+// SessionSigner 游客会话签名器
+// Cookie 值为 "会话ID.签名"，服务端只接受签名有效的会话ID，防止伪造他人的游客购物车
+type SessionSigner struct {
+	key []byte
+}
+
+// NewSessionSigner 创建游客会话签名器，secret 为空时使用进程内随机密钥（重启后已发放的会话失效）
+func NewSessionSigner(secret string) *SessionSigner {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(fmt.Sprintf("生成游客会话密钥失败: %v", err))
+		}
+		logger.Warn("未配置" + EnvGuestSessionSecret + "，游客购物车会话使用随机密钥，服务重启后游客购物车将失效")
+	}
+	return &SessionSigner{key: key}
+}
+
+// NewSessionSignerFromEnv 从环境变量读取密钥创建游客会话签名器
+func NewSessionSignerFromEnv() *SessionSigner {
+	return NewSessionSigner(os.Getenv(EnvGuestSessionSecret))
+}
+
+// NewSession 生成新的游客会话，返回会话ID及签名后的Cookie值
+func (s *SessionSigner) NewSession() (string, string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("生成游客会话ID失败: %v", err)
+	}
+	sessionID := hex.EncodeToString(buf)
+	return sessionID, s.Sign(sessionID), nil
+}
+
+// Sign 对会话ID签名
+func (s *SessionSigner) Sign(sessionID string) string {
+	return sessionID + "." + s.signature(sessionID)
+}
+
+// Verify 校验Cookie值，返回其中的会话ID
+func (s *SessionSigner) Verify(value string) (string, bool) {
+	sessionID, signature, ok := strings.Cut(value, ".")
+	if !ok || sessionID == "" {
+		return "", false
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.signature(sessionID))) {
+		return "", false
+	}
+	return sessionID, true
+}
+
+// signature 计算会话ID的签名
+func (s *SessionSigner) signature(sessionID string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(sessionID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
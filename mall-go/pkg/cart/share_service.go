@@ -0,0 +1,292 @@
+package cart
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// ShareOptions 购物车分享配置
+type ShareOptions struct {
+	DefaultTTL time.Duration // 默认有效期
+	MaxTTL     time.Duration // 最长有效期
+}
+
+// DefaultShareOptions 默认购物车分享配置
+func DefaultShareOptions() ShareOptions {
+	return ShareOptions{
+		DefaultTTL: 72 * time.Hour,
+		MaxTTL:     30 * 24 * time.Hour,
+	}
+}
+
+// ShareService 购物车分享服务
+// 分享时将购物车商品快照为不可变清单，他人凭令牌查看当前价格和库存、导入自己的购物车或直接结算
+type ShareService struct {
+	db          *gorm.DB
+	cartService *CartService
+	options     ShareOptions
+	now         func() time.Time
+}
+
+// NewShareService 创建购物车分享服务
+func NewShareService(db *gorm.DB, cartService *CartService, options ShareOptions) *ShareService {
+	return &ShareService{
+		db:          db,
+		cartService: cartService,
+		options:     options,
+		now:         time.Now,
+	}
+}
+
+// Share 将购物车商品快照为分享清单，未指定商品时分享已选中的商品
+func (s *ShareService) Share(userID uint, req *model.ShareCartRequest) (*model.CartShare, error) {
+	cart, err := s.cartService.getCart(userID, "")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, model.ErrCartShareEmpty
+		}
+		return nil, fmt.Errorf("查询购物车失败: %v", err)
+	}
+
+	query := s.db.Where("cart_id = ? AND status = ?", cart.ID, model.CartItemStatusNormal)
+	if len(req.ItemIDs) > 0 {
+		query = query.Where("id IN ?", req.ItemIDs)
+	} else {
+		query = query.Where("selected = ?", true)
+	}
+	var cartItems []model.CartItem
+	if err := query.Order("id").Find(&cartItems).Error; err != nil {
+		return nil, fmt.Errorf("查询购物车商品失败: %v", err)
+	}
+	if len(cartItems) == 0 {
+		return nil, model.ErrCartShareEmpty
+	}
+
+	ttl := s.options.DefaultTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	if ttl > s.options.MaxTTL {
+		ttl = s.options.MaxTTL
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	share := &model.CartShare{
+		Token:       token,
+		UserID:      userID,
+		Title:       req.Title,
+		ItemCount:   len(cartItems),
+		TotalAmount: decimal.Zero,
+		ExpiresAt:   s.now().Add(ttl),
+	}
+	for _, item := range cartItems {
+		share.Items = append(share.Items, model.CartShareItem{
+			ProductID:    item.ProductID,
+			SKUID:        item.SKUID,
+			ProductName:  item.ProductName,
+			ProductImage: item.ProductImage,
+			SKUName:      item.SKUName,
+			Price:        item.Price,
+			Quantity:     item.Quantity,
+		})
+		share.TotalQty += item.Quantity
+		share.TotalAmount = share.TotalAmount.Add(item.Price.Mul(decimal.NewFromInt(int64(item.Quantity))))
+	}
+
+	if err := s.db.Create(share).Error; err != nil {
+		return nil, fmt.Errorf("创建购物车分享失败: %v", err)
+	}
+	return share, nil
+}
+
+// List 查询用户创建的分享
+func (s *ShareService) List(userID uint) ([]model.CartShare, error) {
+	var shares []model.CartShare
+	if err := s.db.Where("user_id = ?", userID).Order("id DESC").Find(&shares).Error; err != nil {
+		return nil, fmt.Errorf("查询购物车分享失败: %v", err)
+	}
+	return shares, nil
+}
+
+// Revoke 提前结束分享，令牌立即失效
+func (s *ShareService) Revoke(userID, shareID uint) error {
+	result := s.db.Model(&model.CartShare{}).
+		Where("id = ? AND user_id = ? AND expires_at > ?", shareID, userID, s.now()).
+		Update("expires_at", s.now())
+	if result.Error != nil {
+		return fmt.Errorf("结束购物车分享失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrCartShareNotFound
+	}
+	return nil
+}
+
+// Get 查看分享清单，逐项标记当前价格变化、下架和库存不足
+func (s *ShareService) Get(token string) (*model.CartShareView, error) {
+	share, err := s.load(token)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &model.CartShareView{
+		Share: share,
+		Items: make([]model.CartShareItemView, 0, len(share.Items)),
+	}
+	for _, item := range share.Items {
+		itemView := model.CartShareItemView{CartShareItem: item, CurrentPrice: item.Price}
+		price, stock, available, err := s.current(item.ProductID, item.SKUID)
+		if err != nil {
+			return nil, err
+		}
+		if available {
+			itemView.CurrentPrice = price
+			itemView.Stock = stock
+			itemView.Available = true
+			itemView.PriceChanged = !price.Equal(item.Price)
+			itemView.StockShortage = stock < item.Quantity
+		}
+		view.Items = append(view.Items, itemView)
+	}
+	return view, nil
+}
+
+// Import 将分享商品按分享数量加入当前用户或游客的购物车，逐项返回导入结果
+func (s *ShareService) Import(userID uint, sessionID, token string, req *model.SharedCartItemsRequest) (*model.SharedCartImportResult, error) {
+	share, items, err := s.Items(token, req.ItemIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.SharedCartImportResult{
+		Added:  []uint{},
+		Failed: []model.SharedCartImportFailure{},
+	}
+	for _, item := range items {
+		_, err := s.cartService.AddToCart(userID, sessionID, &model.AddToCartRequest{
+			ProductID: item.ProductID,
+			SKUID:     item.SKUID,
+			Quantity:  item.Quantity,
+		})
+		if err != nil {
+			result.Failed = append(result.Failed, model.SharedCartImportFailure{ItemID: item.ID, Reason: err.Error()})
+			continue
+		}
+		result.Added = append(result.Added, item.ID)
+	}
+
+	if len(result.Added) > 0 {
+		if err := s.record(share.ID, "import_count"); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Items 查询有效分享中的商品，itemIDs 为空时返回全部商品
+func (s *ShareService) Items(token string, itemIDs []uint) (*model.CartShare, []model.CartShareItem, error) {
+	share, err := s.load(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(itemIDs) == 0 {
+		return share, share.Items, nil
+	}
+
+	wanted := make(map[uint]bool, len(itemIDs))
+	for _, id := range itemIDs {
+		wanted[id] = true
+	}
+	var items []model.CartShareItem
+	for _, item := range share.Items {
+		if wanted[item.ID] {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return nil, nil, model.ErrCartShareEmpty
+	}
+	return share, items, nil
+}
+
+// RecordCheckout 记录分享被直接结算一次
+func (s *ShareService) RecordCheckout(shareID uint) error {
+	return s.record(shareID, "checkout_count")
+}
+
+// record 累加分享的导入或结算次数
+func (s *ShareService) record(shareID uint, column string) error {
+	if err := s.db.Model(&model.CartShare{}).Where("id = ?", shareID).Updates(map[string]interface{}{
+		column:         gorm.Expr(column + " + 1"),
+		"last_used_at": s.now(),
+	}).Error; err != nil {
+		return fmt.Errorf("记录分享使用次数失败: %v", err)
+	}
+	return nil
+}
+
+// load 按令牌加载未过期的分享及商品
+func (s *ShareService) load(token string) (*model.CartShare, error) {
+	var share model.CartShare
+	if err := s.db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("token = ?", token).First(&share).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, model.ErrCartShareNotFound
+		}
+		return nil, fmt.Errorf("查询购物车分享失败: %v", err)
+	}
+	if !s.now().Before(share.ExpiresAt) {
+		return nil, model.ErrCartShareExpired
+	}
+	return &share, nil
+}
+
+// current 查询商品或规格的当前价格和库存，available 为false表示已下架或不存在
+func (s *ShareService) current(productID, skuID uint) (decimal.Decimal, int, bool, error) {
+	var product model.Product
+	if err := s.db.First(&product, productID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return decimal.Zero, 0, false, nil
+		}
+		return decimal.Zero, 0, false, fmt.Errorf("查询商品失败: %v", err)
+	}
+	if product.Status != model.ProductStatusActive {
+		return decimal.Zero, 0, false, nil
+	}
+	if skuID == 0 {
+		return product.Price, product.Stock, true, nil
+	}
+
+	var sku model.ProductSKU
+	if err := s.db.Where("id = ? AND product_id = ?", skuID, productID).First(&sku).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return decimal.Zero, 0, false, nil
+		}
+		return decimal.Zero, 0, false, fmt.Errorf("查询商品规格失败: %v", err)
+	}
+	if sku.Status != model.SKUStatusActive {
+		return decimal.Zero, 0, false, nil
+	}
+	return sku.Price, sku.Stock, true, nil
+}
+
+// newShareToken 生成分享令牌
+func newShareToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成分享令牌失败: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,80 @@
+package cart
+
+import (
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestShareService_ShareViewAndImport(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.Cart{}, &model.CartItem{}, &model.Product{}, &model.ProductSKU{}, &model.CartShare{}, &model.CartShareItem{}))
+
+	var products []*model.Product
+	for _, stock := range []int{10, 3} {
+		product := &model.Product{Name: "测试商品", Price: decimal.NewFromInt(20), Stock: stock, Status: model.ProductStatusActive}
+		require.NoError(t, db.Create(product).Error)
+		products = append(products, product)
+	}
+	cartService := NewCartService(db)
+	service := NewShareService(db, cartService, DefaultShareOptions())
+
+	_, err = service.Share(1, &model.ShareCartRequest{})
+	assert.ErrorIs(t, err, model.ErrCartShareEmpty)
+
+	addItem(t, cartService, 1, "", products[0].ID, 2)
+	addItem(t, cartService, 1, "", products[1].ID, 3)
+	share, err := service.Share(1, &model.ShareCartRequest{Title: "办公用品", ExpiresInHours: 24 * 365})
+	require.NoError(t, err)
+	assert.Len(t, share.Token, 32)
+	assert.Equal(t, 5, share.TotalQty)
+	assert.True(t, share.TotalAmount.Equal(decimal.NewFromInt(100)))
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), share.ExpiresAt, time.Minute)
+
+	// 分享后调价、缺货，查看时逐项标记
+	require.NoError(t, db.Model(products[0]).Update("price", decimal.NewFromInt(18)).Error)
+	require.NoError(t, db.Model(products[1]).Update("stock", 1).Error)
+	view, err := service.Get(share.Token)
+	require.NoError(t, err)
+	require.Len(t, view.Items, 2)
+	assert.True(t, view.Items[0].PriceChanged)
+	assert.True(t, view.Items[0].CurrentPrice.Equal(decimal.NewFromInt(18)))
+	assert.True(t, view.Items[0].Price.Equal(decimal.NewFromInt(20)))
+	assert.False(t, view.Items[0].StockShortage)
+	assert.True(t, view.Items[1].StockShortage)
+
+	// 游客导入，库存不足的商品导入失败
+	result, err := service.Import(0, "guest", share.Token, &model.SharedCartItemsRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{view.Items[0].ID}, result.Added)
+	require.Len(t, result.Failed, 1)
+	assert.Equal(t, view.Items[1].ID, result.Failed[0].ItemID)
+
+	_, items, err := service.Items(share.Token, []uint{view.Items[1].ID})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.NoError(t, service.RecordCheckout(share.ID))
+
+	shares, err := service.List(1)
+	require.NoError(t, err)
+	require.Len(t, shares, 1)
+	assert.Equal(t, 1, shares[0].ImportCount)
+	assert.Equal(t, 1, shares[0].CheckoutCount)
+	assert.NotNil(t, shares[0].LastUsedAt)
+
+	// 只有分享人可以结束分享，结束后令牌失效
+	assert.ErrorIs(t, service.Revoke(2, share.ID), model.ErrCartShareNotFound)
+	require.NoError(t, service.Revoke(1, share.ID))
+	_, err = service.Get(share.Token)
+	assert.ErrorIs(t, err, model.ErrCartShareExpired)
+	_, err = service.Get("missing")
+	assert.ErrorIs(t, err, model.ErrCartShareNotFound)
+}
@@ -0,0 +1,77 @@
+package cart
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mall-go/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// SimilarityJob 商品相似度定期重建任务
+type SimilarityJob struct {
+	service *SimilarityService
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewSimilarityJob 创建并启动商品相似度重建任务，相似商品表为空时立即重建一次
+func NewSimilarityJob(service *SimilarityService) *SimilarityJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &SimilarityJob{
+		service: service,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	job.wg.Add(1)
+	go job.run()
+
+	logger.Info("商品相似度重建任务启动",
+		zap.Duration("interval", service.options.Interval),
+		zap.Int("top_k", service.options.TopK))
+
+	return job
+}
+
+// run 重建主循环
+func (j *SimilarityJob) run() {
+	defer j.wg.Done()
+
+	if j.service.Empty() {
+		j.rebuild()
+	}
+
+	ticker := time.NewTicker(j.service.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+			j.rebuild()
+		}
+	}
+}
+
+// rebuild 执行一次重建并记录结果
+func (j *SimilarityJob) rebuild() {
+	start := time.Now()
+	count, err := j.service.Rebuild()
+	if err != nil {
+		logger.Error("重建商品相似度失败", zap.Error(err))
+		return
+	}
+	logger.Info("商品相似度重建完成", zap.Int("neighbors", count), zap.Duration("elapsed", time.Since(start)))
+}
+
+// Stop 停止商品相似度重建任务
+func (j *SimilarityJob) Stop() {
+	logger.Info("停止商品相似度重建任务")
+	j.cancel()
+	j.wg.Wait()
+}
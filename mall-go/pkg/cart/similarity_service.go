@@ -0,0 +1,298 @@
+package cart
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/cache"
+	"mall-go/pkg/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// SimilarityOptions 商品相似度计算配置
+type SimilarityOptions struct {
+	Interval       time.Duration // 重建间隔
+	Lookback       time.Duration // 参与计算的订单时间范围
+	TopK           int           // 每个商品保留的相似商品数
+	MinSupport     int           // 共同购买或共同浏览次数下限，过滤偶然共现
+	PurchaseWeight float64       // 共同购买相似度权重
+	ViewWeight     float64       // 共同浏览相似度权重
+	MaxBasketSize  int           // 单个订单或用户参与计算的商品数上限，避免大单放大计算量
+	BatchSize      int           // 分批读取订单和用户的数量
+}
+
+// DefaultSimilarityOptions 默认商品相似度计算配置
+func DefaultSimilarityOptions() SimilarityOptions {
+	return SimilarityOptions{
+		Interval:       24 * time.Hour,
+		Lookback:       180 * 24 * time.Hour,
+		TopK:           20,
+		MinSupport:     2,
+		PurchaseWeight: 0.7,
+		ViewWeight:     0.3,
+		MaxBasketSize:  50,
+		BatchSize:      500,
+	}
+}
+
+// BehaviorSource 用户浏览、点击行为来源，通常为用户偏好缓存
+type BehaviorSource interface {
+	GetUserBrowseHistory(userID uint) (*cache.UserBrowseHistoryCacheData, error)
+	GetUserBehavior(userID uint) (*cache.UserBehaviorCacheData, error)
+}
+
+// SimilarityService 商品相似度计算服务
+// 以订单为购物篮统计共同购买，以用户浏览和点击记录统计共同浏览，
+// 分别计算余弦相似度后加权合并，每个商品保留前K个相似商品供购物车推荐使用
+type SimilarityService struct {
+	db       *gorm.DB
+	behavior BehaviorSource
+	options  SimilarityOptions
+	now      func() time.Time
+}
+
+// NewSimilarityService 创建商品相似度计算服务
+func NewSimilarityService(db *gorm.DB, options SimilarityOptions) *SimilarityService {
+	return &SimilarityService{
+		db:      db,
+		options: options,
+		now:     time.Now,
+	}
+}
+
+// SetBehaviorSource 设置用户行为来源，未设置时仅按共同购买计算
+func (s *SimilarityService) SetBehaviorSource(source BehaviorSource) {
+	s.behavior = source
+}
+
+// Empty 相似商品表是否为空
+func (s *SimilarityService) Empty() bool {
+	var count int64
+	if err := s.db.Model(&model.ProductSimilarity{}).Limit(1).Count(&count).Error; err != nil {
+		return true
+	}
+	return count == 0
+}
+
+// Rebuild 重新计算商品相似度并整体替换相似商品表，返回写入的记录数
+func (s *SimilarityService) Rebuild() (int, error) {
+	purchases := newCooccurrence(s.options.MaxBasketSize)
+	if err := s.collectPurchases(purchases); err != nil {
+		return 0, err
+	}
+	views := newCooccurrence(s.options.MaxBasketSize)
+	if s.behavior != nil {
+		if err := s.collectViews(views); err != nil {
+			return 0, err
+		}
+	}
+
+	rows := s.neighbors(purchases, views)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&model.ProductSimilarity{}).Error; err != nil {
+			return fmt.Errorf("清理相似商品失败: %v", err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		if err := tx.CreateInBatches(rows, s.options.BatchSize).Error; err != nil {
+			return fmt.Errorf("保存相似商品失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+// collectPurchases 按订单统计共同购买，取消和关闭的订单不计入
+func (s *SimilarityService) collectPurchases(purchases *cooccurrence) error {
+	var orders []model.Order
+	err := s.db.Select("id").
+		Where("created_at >= ? AND status NOT IN ?", s.now().Add(-s.options.Lookback),
+			[]string{model.OrderStatusCancelled, model.OrderStatusClosed}).
+		FindInBatches(&orders, s.options.BatchSize, func(tx *gorm.DB, batch int) error {
+			orderIDs := make([]uint, 0, len(orders))
+			for _, order := range orders {
+				orderIDs = append(orderIDs, order.ID)
+			}
+
+			var items []model.OrderItem
+			if err := s.db.Select("order_id, product_id").
+				Where("order_id IN ?", orderIDs).
+				Order("order_id, id").
+				Find(&items).Error; err != nil {
+				return err
+			}
+
+			baskets := make(map[uint][]uint, len(orderIDs))
+			for _, item := range items {
+				baskets[item.OrderID] = append(baskets[item.OrderID], item.ProductID)
+			}
+			for _, basket := range baskets {
+				purchases.add(basket)
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return fmt.Errorf("统计共同购买失败: %v", err)
+	}
+	return nil
+}
+
+// collectViews 按用户统计共同浏览和点击，读取失败的用户跳过
+func (s *SimilarityService) collectViews(views *cooccurrence) error {
+	var users []model.User
+	err := s.db.Select("id").
+		FindInBatches(&users, s.options.BatchSize, func(tx *gorm.DB, batch int) error {
+			for _, user := range users {
+				var basket []uint
+				history, err := s.behavior.GetUserBrowseHistory(user.ID)
+				if err != nil {
+					logger.Warn("读取用户浏览历史失败", zap.Uint("user_id", user.ID), zap.Error(err))
+				} else if history != nil {
+					for _, item := range history.Items {
+						basket = append(basket, item.ProductID)
+					}
+				}
+				behavior, err := s.behavior.GetUserBehavior(user.ID)
+				if err != nil {
+					logger.Warn("读取用户点击行为失败", zap.Uint("user_id", user.ID), zap.Error(err))
+				} else if behavior != nil {
+					for _, item := range behavior.ClickBehavior {
+						basket = append(basket, item.ProductID)
+					}
+				}
+				views.add(basket)
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return fmt.Errorf("统计共同浏览失败: %v", err)
+	}
+	return nil
+}
+
+// neighbors 合并共同购买和共同浏览相似度，为每个商品选出前K个相似商品
+func (s *SimilarityService) neighbors(purchases, views *cooccurrence) []model.ProductSimilarity {
+	totalWeight := s.options.PurchaseWeight + s.options.ViewWeight
+	if totalWeight <= 0 {
+		return nil
+	}
+
+	pairs := make(map[[2]uint]bool)
+	for pair, count := range purchases.pairs {
+		if count >= s.options.MinSupport {
+			pairs[pair] = true
+		}
+	}
+	for pair, count := range views.pairs {
+		if count >= s.options.MinSupport {
+			pairs[pair] = true
+		}
+	}
+
+	lists := make(map[uint][]model.ProductSimilarity)
+	for pair := range pairs {
+		score := (s.options.PurchaseWeight*purchases.cosine(pair) + s.options.ViewWeight*views.cosine(pair)) / totalWeight
+		if score <= 0 {
+			continue
+		}
+		for _, direction := range [][2]uint{{pair[0], pair[1]}, {pair[1], pair[0]}} {
+			lists[direction[0]] = append(lists[direction[0]], model.ProductSimilarity{
+				ProductID:  direction[0],
+				NeighborID: direction[1],
+				Score:      score,
+				CoPurchase: purchases.pairs[pair],
+				CoView:     views.pairs[pair],
+			})
+		}
+	}
+
+	updatedAt := s.now()
+	var rows []model.ProductSimilarity
+	for _, list := range lists {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Score != list[j].Score {
+				return list[i].Score > list[j].Score
+			}
+			return list[i].NeighborID < list[j].NeighborID
+		})
+		if len(list) > s.options.TopK {
+			list = list[:s.options.TopK]
+		}
+		for i := range list {
+			list[i].Rank = i + 1
+			list[i].UpdatedAt = updatedAt
+		}
+		rows = append(rows, list...)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].ProductID != rows[j].ProductID {
+			return rows[i].ProductID < rows[j].ProductID
+		}
+		return rows[i].Rank < rows[j].Rank
+	})
+	return rows
+}
+
+// cooccurrence 商品共现统计，items 为包含商品的购物篮数，pairs 以较小ID在前的商品对计数
+type cooccurrence struct {
+	maxBasket int
+	items     map[uint]int
+	pairs     map[[2]uint]int
+}
+
+// newCooccurrence 创建商品共现统计
+func newCooccurrence(maxBasket int) *cooccurrence {
+	return &cooccurrence{
+		maxBasket: maxBasket,
+		items:     make(map[uint]int),
+		pairs:     make(map[[2]uint]int),
+	}
+}
+
+// add 统计一个购物篮，重复商品只计一次，超出上限的商品不计入
+func (c *cooccurrence) add(basket []uint) {
+	seen := make(map[uint]bool, len(basket))
+	products := make([]uint, 0, len(basket))
+	for _, productID := range basket {
+		if productID == 0 || seen[productID] {
+			continue
+		}
+		seen[productID] = true
+		products = append(products, productID)
+		if c.maxBasket > 0 && len(products) >= c.maxBasket {
+			break
+		}
+	}
+	if len(products) < 2 {
+		return
+	}
+
+	for i, a := range products {
+		c.items[a]++
+		for _, b := range products[i+1:] {
+			if a < b {
+				c.pairs[[2]uint{a, b}]++
+			} else {
+				c.pairs[[2]uint{b, a}]++
+			}
+		}
+	}
+}
+
+// cosine 商品对的余弦相似度
+func (c *cooccurrence) cosine(pair [2]uint) float64 {
+	count := c.pairs[pair]
+	if count == 0 {
+		return 0
+	}
+	return float64(count) / math.Sqrt(float64(c.items[pair[0]])*float64(c.items[pair[1]]))
+}
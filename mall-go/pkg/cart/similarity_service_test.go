@@ -0,0 +1,116 @@
+package cart
+
+import (
+	"fmt"
+	"testing"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/cache"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakeBehaviorSource struct {
+	views map[uint][]uint
+}
+
+func (f *fakeBehaviorSource) GetUserBrowseHistory(userID uint) (*cache.UserBrowseHistoryCacheData, error) {
+	history := &cache.UserBrowseHistoryCacheData{UserID: userID}
+	for _, productID := range f.views[userID] {
+		history.Items = append(history.Items, cache.BrowseHistoryItem{ProductID: productID})
+	}
+	return history, nil
+}
+
+func (f *fakeBehaviorSource) GetUserBehavior(userID uint) (*cache.UserBehaviorCacheData, error) {
+	return nil, nil
+}
+
+func setupSimilarityTest(t *testing.T) (*gorm.DB, []*model.Product) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.Cart{}, &model.CartItem{}, &model.Product{}, &model.ProductImage{}, &model.ProductSKU{},
+		&model.Order{}, &model.OrderItem{}, &model.User{}, &model.ProductSimilarity{}))
+
+	var products []*model.Product
+	for i := 1; i <= 5; i++ {
+		product := &model.Product{Name: fmt.Sprintf("商品%d", i), Price: decimal.NewFromInt(20), Stock: 10, CategoryID: 1, Status: model.ProductStatusActive}
+		require.NoError(t, db.Create(product).Error)
+		products = append(products, product)
+	}
+	return db, products
+}
+
+func createOrder(t *testing.T, db *gorm.DB, orderNo, status string, products ...*model.Product) {
+	order := &model.Order{OrderNo: orderNo, UserID: 9, Status: status,
+		PaymentStatus: "paid", TotalAmount: decimal.NewFromInt(20), PayableAmount: decimal.NewFromInt(20)}
+	require.NoError(t, db.Create(order).Error)
+	for _, product := range products {
+		require.NoError(t, db.Create(&model.OrderItem{OrderID: order.ID, ProductID: product.ID, Quantity: 1,
+			ProductName: product.Name, Price: product.Price, TotalPrice: product.Price}).Error)
+	}
+}
+
+func TestSimilarityService_RebuildAndBlend(t *testing.T) {
+	db, products := setupSimilarityTest(t)
+	createOrder(t, db, "O1", model.OrderStatusPaid, products[0], products[1])
+	createOrder(t, db, "O2", model.OrderStatusCompleted, products[0], products[1], products[2])
+	createOrder(t, db, "O3", model.OrderStatusCancelled, products[0], products[3])
+	createOrder(t, db, "O4", model.OrderStatusCancelled, products[0], products[2], products[3])
+	for i := 1; i <= 2; i++ {
+		require.NoError(t, db.Create(&model.User{Username: fmt.Sprintf("u%d", i), Email: fmt.Sprintf("u%d@example.com", i), Password: "x"}).Error)
+	}
+
+	service := NewSimilarityService(db, DefaultSimilarityOptions())
+	service.SetBehaviorSource(&fakeBehaviorSource{views: map[uint][]uint{
+		1: {products[2].ID, products[3].ID},
+		2: {products[3].ID, products[2].ID, products[3].ID},
+	}})
+	assert.True(t, service.Empty())
+	count, err := service.Rebuild()
+	require.NoError(t, err)
+	// 共同购买 1-2、共同浏览 3-4 达到支持度，取消订单不计入
+	assert.Equal(t, 4, count)
+
+	var similarities []model.ProductSimilarity
+	require.NoError(t, db.Order("product_id, rank").Find(&similarities).Error)
+	require.Len(t, similarities, 4)
+	assert.Equal(t, products[1].ID, similarities[0].NeighborID)
+	assert.Equal(t, 2, similarities[0].CoPurchase)
+	assert.InDelta(t, 0.7, similarities[0].Score, 1e-9)
+	assert.Equal(t, products[3].ID, similarities[2].NeighborID)
+	assert.Equal(t, 2, similarities[2].CoView)
+	assert.InDelta(t, 0.3, similarities[2].Score, 1e-9)
+
+	// 重建整体替换旧结果
+	count, err = service.Rebuild()
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	cartService := NewCartService(db)
+	recommendations := NewRecommendationService(db)
+	addItem(t, cartService, 5, "", products[0].ID, 1)
+	response, err := recommendations.GetCartRecommendations(5, "", 3)
+	require.NoError(t, err)
+	require.Len(t, response.ItemBased, 1)
+	require.Len(t, response.ItemBased[0].Products, 1)
+	assert.Equal(t, products[1].ID, response.ItemBased[0].Products[0].ID)
+	require.NotEmpty(t, response.Blended)
+	assert.Equal(t, products[1].ID, response.Blended[0].Product.ID)
+	assert.Contains(t, response.Blended[0].Sources, RecommendTypeItemBased)
+
+	// 冷启动商品没有相似商品，按冷启动权重使用其他策略
+	addItem(t, cartService, 6, "", products[4].ID, 1)
+	response, err = recommendations.GetCartRecommendations(6, "", 3)
+	require.NoError(t, err)
+	assert.Empty(t, response.ItemBased)
+	require.NotEmpty(t, response.Blended)
+	for _, item := range response.Blended {
+		assert.NotContains(t, item.Sources, RecommendTypeItemBased)
+		assert.NotEqual(t, products[4].ID, item.Product.ID)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"time"
 
 	"mall-go/internal/model"
+	"mall-go/pkg/logger"
 
 	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -155,7 +157,7 @@ func (ss *SyncService) SyncAllCartItems() (*SyncResult, error) {
 // syncCartItem 同步单个购物车商品项
 func (ss *SyncService) syncCartItem(tx *gorm.DB, item *model.CartItem, result *SyncResult) error {
 	updated := false
-	_ = item.Status // 避免未使用变量警告
+	priceChanged := false
 
 	// 检查商品状态
 	var product model.Product
@@ -236,6 +238,7 @@ func (ss *SyncService) syncCartItem(tx *gorm.DB, item *model.CartItem, result *S
 				if item.Status == model.CartItemStatusNormal {
 					item.Status = model.CartItemStatusPriceChange
 				}
+				priceChanged = true
 				updated = true
 			}
 
@@ -260,7 +263,7 @@ func (ss *SyncService) syncCartItem(tx *gorm.DB, item *model.CartItem, result *S
 			}
 
 			// 如果价格没有变化且库存充足，恢复正常状态
-			if item.Status == model.CartItemStatusPriceChange && availableStock >= item.Quantity {
+			if item.Status == model.CartItemStatusPriceChange && !priceChanged && availableStock >= item.Quantity {
 				item.Status = model.CartItemStatusNormal
 				updated = true
 			}
@@ -283,6 +286,57 @@ func (ss *SyncService) syncCartItem(tx *gorm.DB, item *model.CartItem, result *S
 	return nil
 }
 
+// PriceChanged 商品或SKU改价后标记仍持有旧价格的购物车商品，价格在下次同步时更新并返回变动明细
+func (ss *SyncService) PriceChanged(productID, skuID uint, before, after decimal.Decimal) {
+	var items []model.CartItem
+	if err := ss.db.Where("product_id = ? AND status = ?", productID, model.CartItemStatusNormal).
+		Find(&items).Error; err != nil {
+		logger.Error("查询改价商品的购物车失败", zap.Uint("product_id", productID), zap.Uint("sku_id", skuID), zap.Error(err))
+		return
+	}
+
+	var itemIDs []uint
+	cartIDs := make(map[uint]bool)
+	for _, item := range items {
+		if item.SKUID == skuID && !item.Price.Equal(after) {
+			itemIDs = append(itemIDs, item.ID)
+			cartIDs[item.CartID] = true
+		}
+	}
+	if len(itemIDs) == 0 {
+		return
+	}
+
+	if err := ss.db.Model(&model.CartItem{}).
+		Where("id IN ? AND status = ?", itemIDs, model.CartItemStatusNormal).
+		Update("status", model.CartItemStatusPriceChange).Error; err != nil {
+		logger.Error("标记购物车商品价格变动失败", zap.Uint("product_id", productID), zap.Uint("sku_id", skuID), zap.Error(err))
+		return
+	}
+
+	if ss.cacheService != nil {
+		ids := make([]uint, 0, len(cartIDs))
+		for cartID := range cartIDs {
+			ids = append(ids, cartID)
+		}
+		var carts []model.Cart
+		if err := ss.db.Select("id, user_id, session_id").Where("id IN ?", ids).Find(&carts).Error; err != nil {
+			logger.Warn("查询购物车失败，缓存将在过期后刷新", zap.Error(err))
+		}
+		for _, cart := range carts {
+			ss.cacheService.clearCartCache(cart.UserID, cart.SessionID)
+		}
+	}
+
+	logger.Info("标记购物车商品价格变动",
+		zap.Uint("product_id", productID),
+		zap.Uint("sku_id", skuID),
+		zap.Int("items", len(itemIDs)))
+}
+
+// StockChanged 库存变化在同步购物车时检查，无需处理
+func (ss *SyncService) StockChanged(productID, skuID uint, before, after int) {}
+
 // ValidateCartItems 验证购物车商品
 func (ss *SyncService) ValidateCartItems(userID uint, sessionID string) (*model.CartResponse, error) {
 	// 先同步商品信息
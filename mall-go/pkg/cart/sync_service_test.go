@@ -0,0 +1,114 @@
+package cart
+
+import (
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/product"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSyncService_FlagsCartsOnScheduledPriceChange(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.Product{}, &model.ProductSKU{}, &model.Cart{}, &model.CartItem{},
+		&model.PriceSchedule{}, &model.PriceHistory{}))
+
+	p := &model.Product{Name: "测试商品", Price: decimal.NewFromInt(100), Stock: 10, Status: model.ProductStatusActive}
+	require.NoError(t, db.Create(p).Error)
+
+	cartService := NewCartService(db)
+	item, err := cartService.AddToCart(1, "", &model.AddToCartRequest{ProductID: p.ID, Quantity: 1})
+	require.NoError(t, err)
+
+	syncService := NewSyncService(db, cartService, nil)
+	priceService := product.NewPriceService(db, product.DefaultPriceOptions())
+	priceService.AddChangeListener(syncService)
+	var invalidated []uint
+	priceService.AddCacheInvalidator(product.CacheInvalidatorFunc(func(productID uint) {
+		invalidated = append(invalidated, productID)
+	}))
+
+	// 开始时间已到的限时价立即生效，购物车中旧价格的商品被标记
+	endAt := time.Now().Add(time.Hour)
+	schedule, err := priceService.Schedule(&model.PriceScheduleRequest{
+		ProductID: p.ID, Price: decimal.NewFromInt(80), StartAt: time.Now().Add(-time.Minute), EndAt: &endAt,
+	}, 7)
+	require.NoError(t, err)
+	assert.Equal(t, model.PriceScheduleActive, schedule.Status)
+	assert.True(t, schedule.OriginalPrice.Equal(decimal.NewFromInt(100)))
+	assert.Equal(t, []uint{p.ID}, invalidated)
+	assertProductPrice(t, db, p.ID, "80")
+	assertCartItemStatus(t, db, item.ID, model.CartItemStatusPriceChange)
+
+	// 限时价期间不能再安排重叠的限时价或永久调价
+	overlapEnd := endAt.Add(time.Hour)
+	_, err = priceService.Schedule(&model.PriceScheduleRequest{
+		ProductID: p.ID, Price: decimal.NewFromInt(70), StartAt: time.Now().Add(30 * time.Minute), EndAt: &overlapEnd,
+	}, 7)
+	assert.ErrorIs(t, err, model.ErrPriceScheduleOverlap)
+	_, err = priceService.Schedule(&model.PriceScheduleRequest{
+		ProductID: p.ID, Price: decimal.NewFromInt(90), StartAt: time.Now().Add(30 * time.Minute),
+	}, 7)
+	assert.ErrorIs(t, err, model.ErrPriceScheduleOverlap)
+
+	// 同步时返回价格变动并保留标记，再次同步后恢复正常
+	result, err := syncService.SyncCartItems(1, "")
+	require.NoError(t, err)
+	require.Len(t, result.PriceChanges, 1)
+	assert.True(t, result.PriceChanges[0].OldPrice.Equal(decimal.NewFromInt(100)))
+	assert.True(t, result.PriceChanges[0].NewPrice.Equal(decimal.NewFromInt(80)))
+	assertCartItemStatus(t, db, item.ID, model.CartItemStatusPriceChange)
+	_, err = syncService.SyncCartItems(1, "")
+	require.NoError(t, err)
+	assertCartItemStatus(t, db, item.ID, model.CartItemStatusNormal)
+
+	// 提前取消限时价恢复原价
+	require.NoError(t, priceService.Cancel(schedule.ID, 8))
+	assertProductPrice(t, db, p.ID, "100")
+	assertCartItemStatus(t, db, item.ID, model.CartItemStatusPriceChange)
+	assert.ErrorIs(t, priceService.Cancel(schedule.ID, 8), model.ErrPriceScheduleFinished)
+
+	histories, total, err := priceService.History(p.ID, &model.PriceHistoryQuery{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+	assert.Equal(t, model.PriceChangeScheduleAbort, histories[0].Source)
+	assert.Equal(t, uint(8), histories[0].OperatorID)
+	assert.Equal(t, model.PriceChangeSchedule, histories[1].Source)
+	assert.Equal(t, uint(7), histories[1].OperatorID)
+
+	lowest, err := priceService.LowestPrice(p.ID, 0)
+	require.NoError(t, err)
+	assert.True(t, lowest.CurrentPrice.Equal(decimal.NewFromInt(100)))
+	assert.True(t, lowest.LowestPrice.Equal(decimal.NewFromInt(80)))
+
+	// 未到开始时间的调价保持待生效
+	pending, err := priceService.Schedule(&model.PriceScheduleRequest{
+		ProductID: p.ID, Price: decimal.NewFromInt(90), StartAt: time.Now().Add(2 * time.Hour),
+	}, 7)
+	require.NoError(t, err)
+	assert.Equal(t, model.PriceSchedulePending, pending.Status)
+	activated, finished, err := priceService.Run()
+	require.NoError(t, err)
+	assert.Zero(t, activated)
+	assert.Zero(t, finished)
+	assertProductPrice(t, db, p.ID, "100")
+}
+
+func assertProductPrice(t *testing.T, db *gorm.DB, productID uint, price string) {
+	var p model.Product
+	require.NoError(t, db.First(&p, productID).Error)
+	assert.True(t, p.Price.Equal(decimal.RequireFromString(price)), "price %s", p.Price)
+}
+
+func assertCartItemStatus(t *testing.T, db *gorm.DB, itemID uint, status string) {
+	var item model.CartItem
+	require.NoError(t, db.First(&item, itemID).Error)
+	assert.Equal(t, status, item.Status)
+}
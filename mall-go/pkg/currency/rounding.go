@@ -0,0 +1,86 @@
+package currency
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// defaultDecimals ISO 4217 中小数位数不为2的常用币种
+var defaultDecimals = map[string]int32{
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"CLP": 0,
+	"ISK": 0,
+	"UGX": 0,
+	"BHD": 3,
+	"JOD": 3,
+	"KWD": 3,
+	"OMR": 3,
+	"TND": 3,
+}
+
+// configuredDecimals 币种表中配置的小数位数（Currency.Decimals），由 Service 加载或保存币种时登记
+var (
+	decimalsMu         sync.RWMutex
+	configuredDecimals = make(map[string]int32)
+)
+
+// Normalize 规范化币种代码，空值视为结算币种
+func Normalize(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return "CNY"
+	}
+	return code
+}
+
+// DefaultDecimals 返回币种的ISO 4217默认小数位数
+func DefaultDecimals(code string) int32 {
+	if decimals, ok := defaultDecimals[Normalize(code)]; ok {
+		return decimals
+	}
+	return 2
+}
+
+// RegisterDecimals 登记币种表中配置的小数位数
+func RegisterDecimals(code string, decimals int32) {
+	decimalsMu.Lock()
+	defer decimalsMu.Unlock()
+	configuredDecimals[Normalize(code)] = decimals
+}
+
+// Decimals 返回币种的小数位数，优先使用币种表配置的 Currency.Decimals，未配置的币种取ISO 4217默认值
+func Decimals(code string) int32 {
+	code = Normalize(code)
+
+	decimalsMu.RLock()
+	decimals, ok := configuredDecimals[code]
+	decimalsMu.RUnlock()
+	if ok {
+		return decimals
+	}
+	return DefaultDecimals(code)
+}
+
+// Round 按币种小数位数四舍五入
+func Round(amount decimal.Decimal, code string) decimal.Decimal {
+	return amount.Round(Decimals(code))
+}
+
+// Format 按币种小数位数格式化金额
+func Format(amount decimal.Decimal, code string) string {
+	return amount.StringFixed(Decimals(code))
+}
+
+// ToMinorUnits 金额转换为最小货币单位，如CNY的分、JPY的円、KWD的费尔
+func ToMinorUnits(amount decimal.Decimal, code string) int64 {
+	return amount.Shift(Decimals(code)).Round(0).IntPart()
+}
+
+// FromMinorUnits 最小货币单位转换为金额
+func FromMinorUnits(value int64, code string) decimal.Decimal {
+	return decimal.New(value, -Decimals(code))
+}
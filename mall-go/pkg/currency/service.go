@@ -0,0 +1,275 @@
+package currency
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/pagination"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Service 币种与汇率服务
+// 商品、订单以结算币种(CNY)计价，展示与支付时按生效汇率换算为展示币种
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService 创建币种与汇率服务，并登记币种表中配置的小数位数
+func NewService(db *gorm.DB) *Service {
+	service := &Service{db: db}
+	if _, err := service.ListCurrencies(false); err != nil {
+		logger.Warn("加载币种小数位数失败，使用ISO 4217默认值", zap.Error(err))
+	}
+	return service
+}
+
+// RateFile 汇率文件格式
+//
+//	{"base": "CNY", "effective_at": "2024-01-02T00:00:00+08:00", "rates": {"USD": "0.1381", "JPY": "20.57"}}
+type RateFile struct {
+	Base        string                     `json:"base"`
+	EffectiveAt *time.Time                 `json:"effective_at"`
+	Rates       map[string]decimal.Decimal `json:"rates"`
+}
+
+// Conversion 金额换算结果
+type Conversion struct {
+	Currency       string          `json:"currency"`         // 展示币种
+	Amount         decimal.Decimal `json:"amount"`           // 展示币种金额
+	Rate           decimal.Decimal `json:"rate"`             // 使用的汇率
+	ExchangeRateID uint            `json:"exchange_rate_id"` // 汇率版本ID，结算币种为0
+}
+
+// ListCurrencies 获取币种列表
+func (s *Service) ListCurrencies(enabledOnly bool) ([]model.Currency, error) {
+	var currencies []model.Currency
+	query := s.db.Model(&model.Currency{})
+	if enabledOnly {
+		query = query.Where("is_enabled = ?", true)
+	}
+	if err := query.Order("sort_order ASC, code ASC").Find(&currencies).Error; err != nil {
+		return nil, fmt.Errorf("查询币种失败: %v", err)
+	}
+	for _, currency := range currencies {
+		RegisterDecimals(currency.Code, currency.Decimals)
+	}
+	return currencies, nil
+}
+
+// SaveCurrency 新增或更新币种
+func (s *Service) SaveCurrency(req *model.CurrencyRequest) (*model.Currency, error) {
+	code := Normalize(req.Code)
+
+	var currency model.Currency
+	err := s.db.Where("code = ?", code).First(&currency).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("查询币种失败: %v", err)
+	}
+	if err == gorm.ErrRecordNotFound {
+		currency = model.Currency{Code: code, Decimals: DefaultDecimals(code), IsEnabled: true}
+	}
+
+	currency.Name = req.Name
+	currency.Symbol = req.Symbol
+	currency.SortOrder = req.SortOrder
+	if req.Decimals != nil {
+		currency.Decimals = *req.Decimals
+	}
+	if req.IsEnabled != nil {
+		currency.IsEnabled = *req.IsEnabled
+	}
+
+	if err := s.db.Save(&currency).Error; err != nil {
+		return nil, fmt.Errorf("保存币种失败: %v", err)
+	}
+	RegisterDecimals(currency.Code, currency.Decimals)
+	return &currency, nil
+}
+
+// GetCurrency 获取启用的币种，结算币种未配置时返回默认定义
+func (s *Service) GetCurrency(code string) (*model.Currency, error) {
+	code = Normalize(code)
+
+	var currency model.Currency
+	err := s.db.Where("code = ?", code).First(&currency).Error
+	if err == gorm.ErrRecordNotFound {
+		if code == model.BaseCurrency {
+			return &model.Currency{Code: code, Name: "人民币", Symbol: "¥", Decimals: 2, IsEnabled: true}, nil
+		}
+		return nil, model.ErrCurrencyNotSupported
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询币种失败: %v", err)
+	}
+	RegisterDecimals(currency.Code, currency.Decimals)
+	if !currency.IsEnabled {
+		return nil, model.ErrCurrencyNotSupported
+	}
+	return &currency, nil
+}
+
+// Round 按币种配置的小数位数四舍五入
+func (s *Service) Round(amount decimal.Decimal, code string) (decimal.Decimal, error) {
+	currency, err := s.GetCurrency(code)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return amount.Round(currency.Decimals), nil
+}
+
+// SetRate 录入新版本汇率
+func (s *Service) SetRate(quote string, rate decimal.Decimal, effectiveAt time.Time, source string, operatorID uint) (*model.ExchangeRate, error) {
+	quote = Normalize(quote)
+	if quote == model.BaseCurrency || !rate.IsPositive() {
+		return nil, model.ErrInvalidExchangeRate
+	}
+	if _, err := s.GetCurrency(quote); err != nil {
+		return nil, err
+	}
+
+	exchangeRate := &model.ExchangeRate{
+		BaseCurrency:  model.BaseCurrency,
+		QuoteCurrency: quote,
+		Rate:          rate,
+		EffectiveAt:   effectiveAt,
+		Source:        source,
+		OperatorID:    operatorID,
+	}
+	if err := s.db.Create(exchangeRate).Error; err != nil {
+		return nil, fmt.Errorf("保存汇率失败: %v", err)
+	}
+
+	logger.Info("录入汇率",
+		zap.String("quote_currency", quote),
+		zap.String("rate", rate.String()),
+		zap.Time("effective_at", effectiveAt),
+		zap.String("source", source))
+
+	return exchangeRate, nil
+}
+
+// LoadRatesFromFile 从汇率文件导入一批汇率，返回导入数量
+func (s *Service) LoadRatesFromFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("读取汇率文件失败: %v", err)
+	}
+
+	var file RateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, fmt.Errorf("解析汇率文件失败: %v", err)
+	}
+
+	return s.ImportRates(&file, model.ExchangeRateSourceFile, 0)
+}
+
+// ImportRates 在同一事务中导入一批汇率，任一失败则整批回滚
+func (s *Service) ImportRates(file *RateFile, source string, operatorID uint) (int, error) {
+	if Normalize(file.Base) != model.BaseCurrency {
+		return 0, fmt.Errorf("汇率文件基准币种必须为 %s", model.BaseCurrency)
+	}
+	if len(file.Rates) == 0 {
+		return 0, model.ErrInvalidExchangeRate
+	}
+
+	effectiveAt := time.Now()
+	if file.EffectiveAt != nil {
+		effectiveAt = *file.EffectiveAt
+	}
+
+	count := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txService := &Service{db: tx}
+		for quote, rate := range file.Rates {
+			if _, err := txService.SetRate(quote, rate, effectiveAt, source, operatorID); err != nil {
+				return fmt.Errorf("导入 %s 汇率失败: %v", quote, err)
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetRate 获取指定时间生效的汇率，结算币种返回汇率1
+func (s *Service) GetRate(quote string, at time.Time) (*model.ExchangeRate, error) {
+	quote = Normalize(quote)
+	if quote == model.BaseCurrency {
+		return &model.ExchangeRate{
+			BaseCurrency:  model.BaseCurrency,
+			QuoteCurrency: model.BaseCurrency,
+			Rate:          decimal.NewFromInt(1),
+			EffectiveAt:   at,
+		}, nil
+	}
+
+	var rate model.ExchangeRate
+	err := s.db.Where("base_currency = ? AND quote_currency = ? AND effective_at <= ?", model.BaseCurrency, quote, at).
+		Order("effective_at DESC, id DESC").
+		First(&rate).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, model.ErrExchangeRateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询汇率失败: %v", err)
+	}
+	return &rate, nil
+}
+
+// ListRates 查询汇率历史版本
+func (s *Service) ListRates(query *model.ExchangeRateQuery) ([]model.ExchangeRate, int64, error) {
+	query.Page, query.PageSize = pagination.Normalize(query.Page, query.PageSize)
+
+	db := s.db.Model(&model.ExchangeRate{})
+	if query.QuoteCurrency != "" {
+		db = db.Where("quote_currency = ?", Normalize(query.QuoteCurrency))
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计汇率失败: %v", err)
+	}
+
+	var rates []model.ExchangeRate
+	if err := db.Order("effective_at DESC, id DESC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&rates).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询汇率失败: %v", err)
+	}
+
+	return rates, total, nil
+}
+
+// Convert 将结算币种金额换算为展示币种金额，按展示币种小数位数舍入
+func (s *Service) Convert(amount decimal.Decimal, quote string, at time.Time) (*Conversion, error) {
+	quote = Normalize(quote)
+
+	currency, err := s.GetCurrency(quote)
+	if err != nil {
+		return nil, err
+	}
+
+	rate, err := s.GetRate(quote, at)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conversion{
+		Currency:       quote,
+		Amount:         amount.Mul(rate.Rate).Round(currency.Decimals),
+		Rate:           rate.Rate,
+		ExchangeRateID: rate.ID,
+	}, nil
+}
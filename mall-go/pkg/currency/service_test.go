@@ -0,0 +1,147 @@
+package currency
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// CurrencyServiceTestSuite 币种与汇率服务测试套件
+type CurrencyServiceTestSuite struct {
+	suite.Suite
+	service *Service
+}
+
+// SetupTest 每个用例使用独立的内存数据库，并登记测试币种
+func (suite *CurrencyServiceTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.AutoMigrate(&model.Currency{}, &model.ExchangeRate{}))
+
+	suite.service = NewService(db)
+	for _, code := range []string{"USD", "JPY", "KWD"} {
+		_, err := suite.service.SaveCurrency(&model.CurrencyRequest{Code: code, Name: code})
+		suite.Require().NoError(err)
+	}
+}
+
+func TestRounding(t *testing.T) {
+	amount := decimal.RequireFromString("1234.5678")
+
+	assert.Equal(t, "1235", Format(amount, "JPY"))
+	assert.Equal(t, "1234.568", Format(amount, "KWD"))
+	assert.Equal(t, "1234.57", Format(amount, "usd"))
+	assert.Equal(t, int64(1235), ToMinorUnits(amount, "JPY"))
+	assert.Equal(t, int64(123457), ToMinorUnits(amount, ""))
+	assert.True(t, FromMinorUnits(1234568, "KWD").Equal(decimal.RequireFromString("1234.568")))
+}
+
+func (suite *CurrencyServiceTestSuite) TestMinorUnitsFollowCurrencyDecimals() {
+	amount := decimal.RequireFromString("1.2345")
+
+	// 未登记的币种按ISO 4217默认两位小数换算
+	suite.Equal(int64(123), ToMinorUnits(amount, "CLF"))
+
+	// 币种表配置的小数位数优先于默认值
+	decimals := int32(4)
+	_, err := suite.service.SaveCurrency(&model.CurrencyRequest{Code: "CLF", Name: "CLF", Decimals: &decimals})
+	suite.Require().NoError(err)
+	suite.Equal(int32(4), Decimals("clf"))
+	suite.Equal(int64(12345), ToMinorUnits(amount, "CLF"))
+	suite.True(FromMinorUnits(12345, "CLF").Equal(amount))
+	suite.Equal("1.2345", Format(amount, "CLF"))
+
+	// 新建服务时从币种表加载已配置的小数位数
+	suite.Require().NoError(suite.service.db.Create(&model.Currency{Code: "UYW", Name: "UYW", Decimals: 4, IsEnabled: true}).Error)
+	suite.Equal(int32(2), Decimals("UYW"))
+	NewService(suite.service.db)
+	suite.Equal(int64(12345), ToMinorUnits(amount, "UYW"))
+}
+
+func (suite *CurrencyServiceTestSuite) TestService_GetRateVersions() {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(24 * time.Hour)
+
+	_, err := suite.service.SetRate("USD", decimal.RequireFromString("0.14"), t1, model.ExchangeRateSourceAdmin, 1)
+	suite.Require().NoError(err)
+	_, err = suite.service.SetRate("USD", decimal.RequireFromString("0.15"), t2, model.ExchangeRateSourceAdmin, 1)
+	suite.Require().NoError(err)
+
+	_, err = suite.service.GetRate("USD", t1.Add(-time.Second))
+	suite.ErrorIs(err, model.ErrExchangeRateNotFound)
+
+	rate, err := suite.service.GetRate("USD", t1.Add(time.Hour))
+	suite.Require().NoError(err)
+	suite.Equal("0.14", rate.Rate.String())
+
+	rate, err = suite.service.GetRate("USD", t2)
+	suite.Require().NoError(err)
+	suite.Equal("0.15", rate.Rate.String())
+
+	rate, err = suite.service.GetRate("CNY", t1)
+	suite.Require().NoError(err)
+	suite.True(rate.Rate.Equal(decimal.NewFromInt(1)))
+
+	_, err = suite.service.SetRate("EUR", decimal.RequireFromString("0.13"), t1, model.ExchangeRateSourceAdmin, 1)
+	suite.ErrorIs(err, model.ErrCurrencyNotSupported)
+	_, err = suite.service.SetRate("USD", decimal.Zero, t1, model.ExchangeRateSourceAdmin, 1)
+	suite.ErrorIs(err, model.ErrInvalidExchangeRate)
+}
+
+func (suite *CurrencyServiceTestSuite) TestService_Convert() {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := suite.service.SetRate("JPY", decimal.RequireFromString("20.57"), at, model.ExchangeRateSourceAdmin, 1)
+	suite.Require().NoError(err)
+
+	conversion, err := suite.service.Convert(decimal.RequireFromString("99.99"), "jpy", at)
+	suite.Require().NoError(err)
+	suite.Equal("JPY", conversion.Currency)
+	suite.Equal("2057", conversion.Amount.String())
+	suite.NotZero(conversion.ExchangeRateID)
+
+	_, err = suite.service.Convert(decimal.NewFromInt(1), "EUR", at)
+	suite.ErrorIs(err, model.ErrCurrencyNotSupported)
+}
+
+func (suite *CurrencyServiceTestSuite) TestService_LoadRatesFromFile() {
+	dir := suite.T().TempDir()
+
+	path := filepath.Join(dir, "rates.json")
+	content := `{"base":"CNY","effective_at":"2024-01-02T00:00:00Z","rates":{"USD":"0.1381","JPY":"20.57"}}`
+	suite.Require().NoError(os.WriteFile(path, []byte(content), 0644))
+
+	count, err := suite.service.LoadRatesFromFile(path)
+	suite.Require().NoError(err)
+	suite.Equal(2, count)
+
+	rate, err := suite.service.GetRate("USD", time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC))
+	suite.Require().NoError(err)
+	suite.Equal(model.ExchangeRateSourceFile, rate.Source)
+
+	// 任一币种不支持时整批回滚
+	badPath := filepath.Join(dir, "bad.json")
+	bad := `{"base":"CNY","effective_at":"2024-02-01T00:00:00Z","rates":{"USD":"0.2","EUR":"0.13"}}`
+	suite.Require().NoError(os.WriteFile(badPath, []byte(bad), 0644))
+
+	_, err = suite.service.LoadRatesFromFile(badPath)
+	suite.Error(err)
+
+	rates, total, err := suite.service.ListRates(&model.ExchangeRateQuery{QuoteCurrency: "USD"})
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), total)
+	suite.Len(rates, 1)
+}
+
+func TestCurrencyServiceSuite(t *testing.T) {
+	suite.Run(t, new(CurrencyServiceTestSuite))
+}
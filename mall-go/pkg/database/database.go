@@ -146,10 +146,10 @@ func InitDB() (*gorm.DB, error) {
 func autoMigrate(db *gorm.DB) error {
 	log.Println("开始数据库迁移...")
 
-	// 检查表是否存在，如果存在则跳过迁移
+	// 检查表是否存在，如果存在则只迁移新增模型
 	if db.Migrator().HasTable(&model.User{}) {
-		log.Println("数据库表已存在，跳过迁移")
-		return nil
+		log.Println("数据库表已存在，跳过全量迁移")
+		return migrateNewModels(db)
 	}
 
 	// 迁移所有模型
@@ -171,16 +171,83 @@ func autoMigrate(db *gorm.DB) error {
 		&model.Address{},
 		&model.Cart{},
 		&model.CartItem{},
+		&model.CartMergeLog{},
 	)
 
 	if err != nil {
 		return fmt.Errorf("数据库迁移失败: %v", err)
 	}
 
+	if err := migrateNewModels(db); err != nil {
+		return err
+	}
+
 	log.Println("数据库迁移完成")
 	return nil
 }
 
+// newModels 后续新增的模型，已有数据库启动时同样会自动迁移
+var newModels = []interface{}{
+	&model.PaymentSyncEvent{},
+	&model.Payment{},       // 新增轮询、结算币种与二维码内容字段
+	&model.PaymentRefund{}, // 退款金额扩展为三位小数
+	&model.Currency{},
+	&model.ExchangeRate{},
+	&model.Order{}, // 新增展示币种与锁定汇率字段
+	&model.PaymentRiskRule{},
+	&model.PaymentRiskBlacklist{},
+	&model.PaymentRiskDecision{},
+	&model.CommissionRate{},
+	&model.SettlementStatement{},
+	&model.SettlementLine{},
+	&model.SettlementPayout{},
+	&model.MerchantSettlementAccount{},
+	&model.PaymentUserLimit{},
+	&model.PaymentCallback{},
+	&model.PaymentCallbackAttempt{},
+	&model.PaymentDispute{},
+	&model.PaymentDisputeEvidence{},
+	&model.PaymentDailyRollup{},
+	&model.SubscriptionPlan{},
+	&model.Subscription{},
+	&model.SubscriptionCycle{},
+	&model.WithholdingAgreement{},
+	&model.GiftCardBatch{},
+	&model.GiftCard{},
+	&model.GiftCardTransaction{},
+	&model.Promotion{},
+	&model.OrderItem{}, // 新增促销分摊金额与赠品标记字段
+	&model.MemberLevel{},
+	&model.UserMembership{},
+	&model.MemberGrowthLog{},
+	&model.MemberLevelChange{},
+	&model.FavoriteFolder{},
+	&model.FavoriteItem{},
+	&model.FavoriteAlert{},
+	&model.Cart{}, // 新增最近活跃、弃购时间与提醒次数字段
+	&model.CartReminder{},
+	&model.CartShare{},
+	&model.CartShareItem{},
+	&model.ProductSimilarity{},
+	&model.ProductBundle{},
+	&model.PriceSchedule{},
+	&model.PriceHistory{},
+	&model.CustomerGroup{},
+	&model.CustomerGroupMember{},
+	&model.PriceList{},
+	&model.PriceListItem{},
+}
+
+// migrateNewModels 迁移新增模型
+func migrateNewModels(db *gorm.DB) error {
+	for _, m := range newModels {
+		if err := db.AutoMigrate(m); err != nil {
+			return fmt.Errorf("迁移模型 %T 失败: %v", m, err)
+		}
+	}
+	return nil
+}
+
 // GetDB 获取数据库实例
 func GetDB() *gorm.DB {
 	if DB == nil {
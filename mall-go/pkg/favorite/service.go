@@ -0,0 +1,404 @@
+package favorite
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/cart"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/pagination"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Notifier 收藏提醒通知，向用户推送降价、到货消息
+type Notifier interface {
+	Notify(alert *model.FavoriteAlert, message string)
+}
+
+// logNotifier 默认通知实现，仅记录日志
+type logNotifier struct{}
+
+// Notify 记录通知日志
+func (logNotifier) Notify(alert *model.FavoriteAlert, message string) {
+	logger.Info("收藏提醒通知",
+		zap.Uint("alert_id", alert.ID),
+		zap.Uint("user_id", alert.UserID),
+		zap.String("type", string(alert.Type)),
+		zap.String("message", message))
+}
+
+// Service 收藏服务
+// 收藏和提醒订阅持久化在数据库中；作为商品服务的变化监听，
+// 在价格降至目标价或库存由0恢复时触发提醒
+type Service struct {
+	db          *gorm.DB
+	cartService *cart.CartService
+	notifier    Notifier
+	now         func() time.Time
+}
+
+// NewService 创建收藏服务
+func NewService(db *gorm.DB, cartService *cart.CartService) *Service {
+	return &Service{
+		db:          db,
+		cartService: cartService,
+		notifier:    logNotifier{},
+		now:         time.Now,
+	}
+}
+
+// SetNotifier 设置提醒通知实现
+func (s *Service) SetNotifier(notifier Notifier) {
+	s.notifier = notifier
+}
+
+// ListFolders 查询用户的收藏分组
+func (s *Service) ListFolders(userID uint) ([]model.FavoriteFolder, error) {
+	var folders []model.FavoriteFolder
+	if err := s.db.Where("user_id = ?", userID).Order("sort ASC, id ASC").Find(&folders).Error; err != nil {
+		return nil, fmt.Errorf("查询收藏分组失败: %v", err)
+	}
+	return folders, nil
+}
+
+// CreateFolder 创建收藏分组
+func (s *Service) CreateFolder(userID uint, req *model.FavoriteFolderRequest) (*model.FavoriteFolder, error) {
+	folder := &model.FavoriteFolder{UserID: userID, Name: req.Name, Sort: req.Sort}
+	if err := s.db.Create(folder).Error; err != nil {
+		return nil, fmt.Errorf("创建收藏分组失败: %v", err)
+	}
+	return folder, nil
+}
+
+// UpdateFolder 重命名收藏分组
+func (s *Service) UpdateFolder(userID, folderID uint, req *model.FavoriteFolderRequest) (*model.FavoriteFolder, error) {
+	folder, err := s.folder(s.db, userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+	folder.Name = req.Name
+	folder.Sort = req.Sort
+	if err := s.db.Save(folder).Error; err != nil {
+		return nil, fmt.Errorf("更新收藏分组失败: %v", err)
+	}
+	return folder, nil
+}
+
+// DeleteFolder 删除收藏分组，分组内的收藏移回默认分组
+func (s *Service) DeleteFolder(userID, folderID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		folder, err := s.folder(tx, userID, folderID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&model.FavoriteItem{}).
+			Where("user_id = ? AND folder_id = ?", userID, folder.ID).
+			Update("folder_id", 0).Error; err != nil {
+			return fmt.Errorf("移动收藏失败: %v", err)
+		}
+		if err := tx.Delete(folder).Error; err != nil {
+			return fmt.Errorf("删除收藏分组失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// Add 收藏商品，记录收藏时的价格
+func (s *Service) Add(userID uint, req *model.AddFavoriteRequest) (*model.FavoriteItem, error) {
+	if req.FolderID > 0 {
+		if _, err := s.folder(s.db, userID, req.FolderID); err != nil {
+			return nil, err
+		}
+	}
+	price, _, err := s.current(req.ProductID, req.SKUID)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&model.FavoriteItem{}).
+		Where("user_id = ? AND product_id = ? AND sku_id = ?", userID, req.ProductID, req.SKUID).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询收藏失败: %v", err)
+	}
+	if count > 0 {
+		return nil, model.ErrFavoriteExists
+	}
+
+	item := &model.FavoriteItem{
+		UserID:     userID,
+		ProductID:  req.ProductID,
+		SKUID:      req.SKUID,
+		FolderID:   req.FolderID,
+		PriceAtAdd: price,
+	}
+	if err := s.db.Create(item).Error; err != nil {
+		return nil, fmt.Errorf("收藏商品失败: %v", err)
+	}
+	return item, nil
+}
+
+// Remove 取消收藏
+func (s *Service) Remove(userID, itemID uint) error {
+	result := s.db.Where("id = ? AND user_id = ?", itemID, userID).Delete(&model.FavoriteItem{})
+	if result.Error != nil {
+		return fmt.Errorf("取消收藏失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrFavoriteNotFound
+	}
+	return nil
+}
+
+// List 分页查询收藏，附带商品和规格的当前信息
+func (s *Service) List(userID uint, query *model.FavoriteQuery) ([]model.FavoriteItem, int64, error) {
+	query.Page, query.PageSize = pagination.Normalize(query.Page, query.PageSize)
+
+	db := s.db.Model(&model.FavoriteItem{}).Where("user_id = ?", userID)
+	if query.FolderID != nil {
+		db = db.Where("folder_id = ?", *query.FolderID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计收藏失败: %v", err)
+	}
+	var items []model.FavoriteItem
+	if err := db.Preload("Product").Preload("SKU").
+		Order("id DESC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&items).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询收藏失败: %v", err)
+	}
+	return items, total, nil
+}
+
+// Move 将收藏移动到分组
+func (s *Service) Move(userID uint, req *model.MoveFavoritesRequest) error {
+	if req.FolderID > 0 {
+		if _, err := s.folder(s.db, userID, req.FolderID); err != nil {
+			return err
+		}
+	}
+	if err := s.db.Model(&model.FavoriteItem{}).
+		Where("user_id = ? AND id IN ?", userID, req.ItemIDs).
+		Update("folder_id", req.FolderID).Error; err != nil {
+		return fmt.Errorf("移动收藏失败: %v", err)
+	}
+	return nil
+}
+
+// AddToCart 将收藏加入购物车，逐件加购，单件失败不影响其他收藏
+func (s *Service) AddToCart(userID uint, req *model.FavoritesToCartRequest) (*model.FavoritesToCartResult, error) {
+	quantity := req.Quantity
+	if quantity <= 0 {
+		quantity = 1
+	}
+
+	var items []model.FavoriteItem
+	if err := s.db.Where("user_id = ? AND id IN ?", userID, req.ItemIDs).Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("查询收藏失败: %v", err)
+	}
+	found := make(map[uint]model.FavoriteItem, len(items))
+	for _, item := range items {
+		found[item.ID] = item
+	}
+
+	result := &model.FavoritesToCartResult{
+		Added:  []uint{},
+		Failed: []model.FavoritesToCartFailure{},
+	}
+	for _, itemID := range req.ItemIDs {
+		item, ok := found[itemID]
+		if !ok {
+			result.Failed = append(result.Failed, model.FavoritesToCartFailure{ItemID: itemID, Reason: model.ErrFavoriteNotFound.Error()})
+			continue
+		}
+		if _, err := s.cartService.AddToCart(userID, "", &model.AddToCartRequest{
+			ProductID: item.ProductID,
+			SKUID:     item.SKUID,
+			Quantity:  quantity,
+		}); err != nil {
+			result.Failed = append(result.Failed, model.FavoritesToCartFailure{ItemID: itemID, Reason: err.Error()})
+			continue
+		}
+		result.Added = append(result.Added, itemID)
+	}
+
+	if !req.Keep && len(result.Added) > 0 {
+		if err := s.db.Where("user_id = ? AND id IN ?", userID, result.Added).Delete(&model.FavoriteItem{}).Error; err != nil {
+			return nil, fmt.Errorf("移出收藏失败: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// CreateAlert 订阅降价或到货提醒，同一商品规格的同类提醒只保留一个
+func (s *Service) CreateAlert(userID uint, req *model.FavoriteAlertRequest) (*model.FavoriteAlert, error) {
+	price, stock, err := s.current(req.ProductID, req.SKUID)
+	if err != nil {
+		return nil, err
+	}
+	switch req.Type {
+	case model.FavoriteAlertPriceDrop:
+		if !req.TargetPrice.IsPositive() || !req.TargetPrice.LessThan(price) {
+			return nil, fmt.Errorf("%w: 目标价需大于0且低于当前价格%s", model.ErrInvalidFavoriteAlert, price.StringFixed(2))
+		}
+	case model.FavoriteAlertBackInStock:
+		if stock > 0 {
+			return nil, fmt.Errorf("%w: 商品当前有货", model.ErrInvalidFavoriteAlert)
+		}
+	default:
+		return nil, model.ErrInvalidFavoriteAlert
+	}
+
+	var alert model.FavoriteAlert
+	err = s.db.Where("user_id = ? AND product_id = ? AND sku_id = ? AND type = ? AND status = ?",
+		userID, req.ProductID, req.SKUID, req.Type, model.FavoriteAlertStatusActive).
+		First(&alert).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("查询提醒失败: %v", err)
+	}
+
+	alert.UserID = userID
+	alert.ProductID = req.ProductID
+	alert.SKUID = req.SKUID
+	alert.Type = req.Type
+	alert.TargetPrice = req.TargetPrice
+	alert.BasePrice = price
+	alert.Status = model.FavoriteAlertStatusActive
+	if err := s.db.Save(&alert).Error; err != nil {
+		return nil, fmt.Errorf("订阅提醒失败: %v", err)
+	}
+	return &alert, nil
+}
+
+// ListAlerts 查询用户的提醒订阅
+func (s *Service) ListAlerts(userID uint) ([]model.FavoriteAlert, error) {
+	var alerts []model.FavoriteAlert
+	if err := s.db.Where("user_id = ? AND status <> ?", userID, model.FavoriteAlertStatusCancelled).
+		Order("id DESC").Find(&alerts).Error; err != nil {
+		return nil, fmt.Errorf("查询提醒失败: %v", err)
+	}
+	return alerts, nil
+}
+
+// CancelAlert 取消提醒订阅
+func (s *Service) CancelAlert(userID, alertID uint) error {
+	result := s.db.Model(&model.FavoriteAlert{}).
+		Where("id = ? AND user_id = ? AND status = ?", alertID, userID, model.FavoriteAlertStatusActive).
+		Update("status", model.FavoriteAlertStatusCancelled)
+	if result.Error != nil {
+		return fmt.Errorf("取消提醒失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrFavoriteAlertNotFound
+	}
+	return nil
+}
+
+// StockChanged 库存由0恢复时触发到货提醒
+func (s *Service) StockChanged(productID, skuID uint, before, after int) {
+	if before > 0 || after <= 0 {
+		return
+	}
+
+	var alerts []model.FavoriteAlert
+	if err := s.db.Where("product_id = ? AND sku_id = ? AND type = ? AND status = ?",
+		productID, skuID, model.FavoriteAlertBackInStock, model.FavoriteAlertStatusActive).
+		Find(&alerts).Error; err != nil {
+		logger.Error("查询到货提醒失败", zap.Uint("product_id", productID), zap.Uint("sku_id", skuID), zap.Error(err))
+		return
+	}
+	for i := range alerts {
+		alerts[i].TriggeredStock = after
+		s.trigger(&alerts[i], map[string]interface{}{"triggered_stock": after},
+			fmt.Sprintf("您关注的商品已到货，当前库存%d件", after))
+	}
+}
+
+// PriceChanged 价格降至目标价及以下时触发降价提醒
+func (s *Service) PriceChanged(productID, skuID uint, before, after decimal.Decimal) {
+	if !after.LessThan(before) {
+		return
+	}
+
+	var alerts []model.FavoriteAlert
+	if err := s.db.Where("product_id = ? AND sku_id = ? AND type = ? AND status = ?",
+		productID, skuID, model.FavoriteAlertPriceDrop, model.FavoriteAlertStatusActive).
+		Find(&alerts).Error; err != nil {
+		logger.Error("查询降价提醒失败", zap.Uint("product_id", productID), zap.Uint("sku_id", skuID), zap.Error(err))
+		return
+	}
+	for i := range alerts {
+		if after.GreaterThan(alerts[i].TargetPrice) {
+			continue
+		}
+		alerts[i].TriggeredPrice = after
+		s.trigger(&alerts[i], map[string]interface{}{"triggered_price": after},
+			fmt.Sprintf("您关注的商品已降价至%s元，低于目标价%s元", after.StringFixed(2), alerts[i].TargetPrice.StringFixed(2)))
+	}
+}
+
+// trigger 标记提醒已触发并通知用户，并发触发时只通知一次
+func (s *Service) trigger(alert *model.FavoriteAlert, updates map[string]interface{}, message string) {
+	now := s.now()
+	updates["status"] = model.FavoriteAlertStatusTriggered
+	updates["triggered_at"] = now
+
+	result := s.db.Model(&model.FavoriteAlert{}).
+		Where("id = ? AND status = ?", alert.ID, model.FavoriteAlertStatusActive).
+		Updates(updates)
+	if result.Error != nil {
+		logger.Error("更新提醒状态失败", zap.Uint("alert_id", alert.ID), zap.Error(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	alert.Status = model.FavoriteAlertStatusTriggered
+	alert.TriggeredAt = &now
+	s.notifier.Notify(alert, message)
+}
+
+// folder 查询用户的收藏分组
+func (s *Service) folder(db *gorm.DB, userID, folderID uint) (*model.FavoriteFolder, error) {
+	var folder model.FavoriteFolder
+	if err := db.Where("id = ? AND user_id = ?", folderID, userID).First(&folder).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrFavoriteFolderNotFound
+		}
+		return nil, fmt.Errorf("查询收藏分组失败: %v", err)
+	}
+	return &folder, nil
+}
+
+// current 查询商品或规格的当前价格和库存
+func (s *Service) current(productID, skuID uint) (decimal.Decimal, int, error) {
+	if skuID > 0 {
+		var sku model.ProductSKU
+		if err := s.db.Where("id = ? AND product_id = ?", skuID, productID).First(&sku).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return decimal.Zero, 0, model.ErrFavoriteProduct
+			}
+			return decimal.Zero, 0, fmt.Errorf("查询商品规格失败: %v", err)
+		}
+		return sku.Price, sku.Stock, nil
+	}
+
+	var product model.Product
+	if err := s.db.First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return decimal.Zero, 0, model.ErrFavoriteProduct
+		}
+		return decimal.Zero, 0, fmt.Errorf("查询商品失败: %v", err)
+	}
+	return product.Price, product.Stock, nil
+}
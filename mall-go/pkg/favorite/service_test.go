@@ -0,0 +1,133 @@
+package favorite
+
+import (
+	"testing"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/cart"
+	"mall-go/pkg/product"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// recordNotifier 记录发出的提醒
+type recordNotifier struct {
+	alerts []model.FavoriteAlert
+}
+
+func (n *recordNotifier) Notify(alert *model.FavoriteAlert, message string) {
+	n.alerts = append(n.alerts, *alert)
+}
+
+// FavoriteServiceTestSuite 收藏服务测试套件
+type FavoriteServiceTestSuite struct {
+	suite.Suite
+	service  *Service
+	notifier *recordNotifier
+	product  *model.Product
+	sku      *model.ProductSKU
+}
+
+// SetupTest 每个用例使用独立的内存数据库，创建有货商品和缺货规格
+func (suite *FavoriteServiceTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.AutoMigrate(&model.Product{}, &model.ProductSKU{}, &model.Cart{}, &model.CartItem{},
+		&product.InventoryLog{}, &model.FavoriteFolder{}, &model.FavoriteItem{}, &model.FavoriteAlert{}))
+
+	suite.product = &model.Product{Name: "测试商品", Price: decimal.NewFromInt(100), Stock: 10, Status: model.ProductStatusActive}
+	suite.Require().NoError(db.Create(suite.product).Error)
+	suite.sku = &model.ProductSKU{ProductID: suite.product.ID, SKUCode: "SKU-1", Name: "红色", Price: decimal.NewFromInt(120), Stock: 0, Status: model.SKUStatusActive}
+	suite.Require().NoError(db.Create(suite.sku).Error)
+
+	suite.notifier = &recordNotifier{}
+	suite.service = NewService(db, cart.NewCartService(db))
+	suite.service.SetNotifier(suite.notifier)
+}
+
+func (suite *FavoriteServiceTestSuite) TestService_FavoritesAndFolders() {
+	folder, err := suite.service.CreateFolder(1, &model.FavoriteFolderRequest{Name: "想买"})
+	suite.Require().NoError(err)
+
+	item, err := suite.service.Add(1, &model.AddFavoriteRequest{ProductID: suite.product.ID, FolderID: folder.ID})
+	suite.Require().NoError(err)
+	suite.True(item.PriceAtAdd.Equal(decimal.NewFromInt(100)))
+	_, err = suite.service.Add(1, &model.AddFavoriteRequest{ProductID: suite.product.ID})
+	suite.ErrorIs(err, model.ErrFavoriteExists)
+
+	skuItem, err := suite.service.Add(1, &model.AddFavoriteRequest{ProductID: suite.product.ID, SKUID: suite.sku.ID})
+	suite.Require().NoError(err)
+	suite.True(skuItem.PriceAtAdd.Equal(decimal.NewFromInt(120)))
+
+	_, err = suite.service.Add(1, &model.AddFavoriteRequest{ProductID: suite.product.ID, SKUID: 99})
+	suite.ErrorIs(err, model.ErrFavoriteProduct)
+	_, err = suite.service.Add(2, &model.AddFavoriteRequest{ProductID: suite.product.ID, FolderID: folder.ID})
+	suite.ErrorIs(err, model.ErrFavoriteFolderNotFound)
+
+	folderID := folder.ID
+	items, total, err := suite.service.List(1, &model.FavoriteQuery{FolderID: &folderID})
+	suite.Require().NoError(err)
+	suite.EqualValues(1, total)
+	suite.Require().NotNil(items[0].Product)
+
+	// 删除分组后收藏回到默认分组
+	suite.Require().NoError(suite.service.DeleteFolder(1, folder.ID))
+	defaultFolder := uint(0)
+	_, total, err = suite.service.List(1, &model.FavoriteQuery{FolderID: &defaultFolder})
+	suite.Require().NoError(err)
+	suite.EqualValues(2, total)
+
+	// 有货的商品加入购物车后移出收藏，缺货的规格保留
+	result, err := suite.service.AddToCart(1, &model.FavoritesToCartRequest{ItemIDs: []uint{item.ID, skuItem.ID}})
+	suite.Require().NoError(err)
+	suite.Equal([]uint{item.ID}, result.Added)
+	suite.Require().Len(result.Failed, 1)
+	suite.Equal(skuItem.ID, result.Failed[0].ItemID)
+	_, total, err = suite.service.List(1, &model.FavoriteQuery{})
+	suite.Require().NoError(err)
+	suite.EqualValues(1, total)
+}
+
+func (suite *FavoriteServiceTestSuite) TestService_AlertsTriggeredByProductServices() {
+	_, err := suite.service.CreateAlert(1, &model.FavoriteAlertRequest{ProductID: suite.product.ID, Type: model.FavoriteAlertPriceDrop, TargetPrice: decimal.NewFromInt(100)})
+	suite.ErrorIs(err, model.ErrInvalidFavoriteAlert)
+	_, err = suite.service.CreateAlert(1, &model.FavoriteAlertRequest{ProductID: suite.product.ID, Type: model.FavoriteAlertBackInStock})
+	suite.ErrorIs(err, model.ErrInvalidFavoriteAlert)
+
+	priceAlert, err := suite.service.CreateAlert(1, &model.FavoriteAlertRequest{ProductID: suite.product.ID, Type: model.FavoriteAlertPriceDrop, TargetPrice: decimal.NewFromInt(80)})
+	suite.Require().NoError(err)
+	stockAlert, err := suite.service.CreateAlert(2, &model.FavoriteAlertRequest{ProductID: suite.product.ID, SKUID: suite.sku.ID, Type: model.FavoriteAlertBackInStock})
+	suite.Require().NoError(err)
+
+	// 降价未达到目标价不触发
+	suite.service.PriceChanged(suite.product.ID, 0, decimal.NewFromInt(100), decimal.NewFromInt(90))
+	suite.Empty(suite.notifier.alerts)
+	suite.service.PriceChanged(suite.product.ID, 0, decimal.NewFromInt(90), decimal.NewFromFloat(79.9))
+	suite.Require().Len(suite.notifier.alerts, 1)
+	suite.Equal(priceAlert.ID, suite.notifier.alerts[0].ID)
+	suite.True(suite.notifier.alerts[0].TriggeredPrice.Equal(decimal.NewFromFloat(79.9)))
+	// 触发一次后失效
+	suite.service.PriceChanged(suite.product.ID, 0, decimal.NewFromFloat(79.9), decimal.NewFromInt(60))
+	suite.Len(suite.notifier.alerts, 1)
+
+	// SKU入库使库存由0恢复，触发到货提醒
+	inventory := product.NewInventoryService(suite.service.db)
+	inventory.AddChangeListener(suite.service)
+	suite.Require().NoError(inventory.StockIn(&product.StockInRequest{ProductID: suite.product.ID, SKUID: suite.sku.ID, Quantity: 5, Reason: "purchase", UserID: 9}))
+	suite.Require().Len(suite.notifier.alerts, 2)
+	suite.Equal(stockAlert.ID, suite.notifier.alerts[1].ID)
+	suite.Equal(5, suite.notifier.alerts[1].TriggeredStock)
+
+	alerts, err := suite.service.ListAlerts(2)
+	suite.Require().NoError(err)
+	suite.Require().Len(alerts, 1)
+	suite.Equal(model.FavoriteAlertStatusTriggered, alerts[0].Status)
+	suite.ErrorIs(suite.service.CancelAlert(2, stockAlert.ID), model.ErrFavoriteAlertNotFound)
+}
+
+func TestFavoriteServiceSuite(t *testing.T) {
+	suite.Run(t, new(FavoriteServiceTestSuite))
+}
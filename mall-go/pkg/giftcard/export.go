@@ -0,0 +1,243 @@
+package giftcard
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// dateLayout 导出日期格式
+const dateLayout = "2006-01-02"
+
+// utf8BOM 写在CSV开头，Excel据此按UTF-8打开中文内容
+const utf8BOM = "\xEF\xBB\xBF"
+
+// liabilityRow 单张礼品卡在统计时点的余额
+type liabilityRow struct {
+	CardID       uint
+	BatchNo      string
+	CodeLast4    string
+	UserID       uint
+	Denomination decimal.Decimal
+	Status       model.GiftCardStatus
+	ExpiresAt    *time.Time
+	Balance      decimal.Decimal
+}
+
+// Liability 统计指定日期日终的礼品卡负债，date 为空时统计当前时点
+// 各卡余额由截止时点前的流水累加得出，过期状态按卡当前的过期时间判断
+func (s *Service) Liability(date string) (*model.GiftCardLiability, error) {
+	cutoff, err := s.cutoff(date)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.GiftCardLiability{AsOf: cutoff}
+	err = s.eachCardBalance(cutoff, func(row *liabilityRow) error {
+		switch {
+		case !row.Balance.IsPositive():
+		case row.ExpiresAt != nil && !row.ExpiresAt.After(cutoff):
+			result.ExpiredBalance = result.ExpiredBalance.Add(row.Balance)
+		default:
+			result.OutstandingCount++
+			result.Outstanding = result.Outstanding.Add(row.Balance)
+			if row.Status == model.GiftCardDisabled {
+				result.DisabledBalance = result.DisabledBalance.Add(row.Balance)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type totalRow struct {
+		Type   model.GiftCardTransactionType
+		Count  int64
+		Amount decimal.Decimal
+	}
+	var totals []totalRow
+	if err := s.db.Model(&model.GiftCardTransaction{}).
+		Select("type, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").
+		Where("created_at < ?", cutoff).
+		Group("type").
+		Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("汇总礼品卡流水失败: %v", err)
+	}
+	for _, total := range totals {
+		switch total.Type {
+		case model.GiftCardTxIssue:
+			result.IssuedCount = total.Count
+			result.IssuedAmount = total.Amount
+		case model.GiftCardTxRedeem:
+			result.RedeemedAmount = total.Amount.Neg()
+		case model.GiftCardTxRefund:
+			result.RefundedAmount = total.Amount
+		}
+	}
+	return result, nil
+}
+
+// WriteLiabilityCSV 导出指定日期日终有余额的礼品卡明细，供财务核对负债
+func (s *Service) WriteLiabilityCSV(w io.Writer, date string) error {
+	cutoff, err := s.cutoff(date)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"卡ID", "批次号", "卡密末四位", "绑定用户ID", "面值", "余额", "状态", "过期时间"}); err != nil {
+		return err
+	}
+
+	err = s.eachCardBalance(cutoff, func(row *liabilityRow) error {
+		if !row.Balance.IsPositive() {
+			return nil
+		}
+		status := string(row.Status)
+		expiresAt := ""
+		if row.ExpiresAt != nil {
+			expiresAt = row.ExpiresAt.In(s.options.Location).Format("2006-01-02 15:04:05")
+			if !row.ExpiresAt.After(cutoff) {
+				status = "expired"
+			}
+		}
+		return writer.Write([]string{
+			fmt.Sprint(row.CardID),
+			row.BatchNo,
+			row.CodeLast4,
+			fmt.Sprint(row.UserID),
+			row.Denomination.StringFixed(2),
+			row.Balance.StringFixed(2),
+			status,
+			expiresAt,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteTransactionsCSV 导出日期范围内的礼品卡流水
+func (s *Service) WriteTransactionsCSV(w io.Writer, startDate, endDate string) error {
+	start, err := time.ParseInLocation(dateLayout, startDate, s.options.Location)
+	if err != nil {
+		return model.ErrGiftCardExportRange
+	}
+	end, err := time.ParseInLocation(dateLayout, endDate, s.options.Location)
+	if err != nil || end.Before(start) {
+		return model.ErrGiftCardExportRange
+	}
+	if days := int(end.Sub(start).Round(24*time.Hour)/(24*time.Hour)) + 1; days > s.options.MaxExportDays {
+		return fmt.Errorf("%w: 最多导出%d天", model.ErrGiftCardExportRange, s.options.MaxExportDays)
+	}
+	end = end.AddDate(0, 0, 1)
+
+	rows, err := s.db.Table("gift_card_transactions AS t").
+		Select("t.id, t.created_at, t.card_id, c.code_last4, t.type, t.amount, t.balance_after, t.user_id, t.order_id, t.reference, t.remark").
+		Joins("JOIN gift_cards AS c ON c.id = t.card_id").
+		Where("t.created_at >= ? AND t.created_at < ?", start, end).
+		Order("t.id").
+		Rows()
+	if err != nil {
+		return fmt.Errorf("查询礼品卡流水失败: %v", err)
+	}
+	defer rows.Close()
+
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"流水ID", "时间", "卡ID", "卡密末四位", "类型", "金额", "变动后余额", "用户ID", "订单ID", "业务单号", "备注"}); err != nil {
+		return err
+	}
+	for rows.Next() {
+		var (
+			id, cardID, userID, orderID uint
+			createdAt                   time.Time
+			last4, reference, remark    string
+			txType                      string
+			amount, balanceAfter        decimal.Decimal
+		)
+		if err := rows.Scan(&id, &createdAt, &cardID, &last4, &txType, &amount, &balanceAfter, &userID, &orderID, &reference, &remark); err != nil {
+			return fmt.Errorf("读取礼品卡流水失败: %v", err)
+		}
+		if err := writer.Write([]string{
+			fmt.Sprint(id),
+			createdAt.In(s.options.Location).Format("2006-01-02 15:04:05"),
+			fmt.Sprint(cardID),
+			last4,
+			txType,
+			amount.StringFixed(2),
+			balanceAfter.StringFixed(2),
+			fmt.Sprint(userID),
+			fmt.Sprint(orderID),
+			reference,
+			remark,
+		}); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("读取礼品卡流水失败: %v", err)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// eachCardBalance 逐张遍历截止时点前有流水的礼品卡及其余额
+func (s *Service) eachCardBalance(cutoff time.Time, fn func(row *liabilityRow) error) error {
+	balances := s.db.Model(&model.GiftCardTransaction{}).
+		Select("card_id, SUM(amount) AS balance").
+		Where("created_at < ?", cutoff).
+		Group("card_id")
+
+	rows, err := s.db.Table("gift_cards AS c").
+		Select("c.id, b.batch_no, c.code_last4, c.user_id, c.denomination, c.status, c.expires_at, t.balance").
+		Joins("JOIN (?) AS t ON t.card_id = c.id", balances).
+		Joins("JOIN gift_card_batches AS b ON b.id = c.batch_id").
+		Order("c.id").
+		Rows()
+	if err != nil {
+		return fmt.Errorf("查询礼品卡余额失败: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row liabilityRow
+		if err := rows.Scan(&row.CardID, &row.BatchNo, &row.CodeLast4, &row.UserID, &row.Denomination, &row.Status, &row.ExpiresAt, &row.Balance); err != nil {
+			return fmt.Errorf("读取礼品卡余额失败: %v", err)
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("读取礼品卡余额失败: %v", err)
+	}
+	return nil
+}
+
+// cutoff 统计截止时点：指定日期次日零点，未指定时为当前时间
+func (s *Service) cutoff(date string) (time.Time, error) {
+	if date == "" {
+		return s.now(), nil
+	}
+	day, err := time.ParseInLocation(dateLayout, date, s.options.Location)
+	if err != nil {
+		return time.Time{}, model.ErrGiftCardExportRange
+	}
+	return day.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,136 @@
+package giftcard
+
+import (
+	"fmt"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// RefundGracePeriod 退回已过期礼品卡时延长的有效期，保证退回的余额可继续使用
+const RefundGracePeriod = 30 * 24 * time.Hour
+
+// cardRedemption 订单在单张卡上的抵扣与已退回金额
+type cardRedemption struct {
+	CardID   uint
+	UserID   uint
+	Redeemed decimal.Decimal
+	Refunded decimal.Decimal
+}
+
+// RefundOrder 将订单的礼品卡抵扣退回原卡，需在退款或取消事务内调用
+// amount 为本次最多退回的金额，不大于0时退回全部未退部分；后抵扣的卡先退回。
+// reference 为退款业务单号，同一单号重复调用时返回首次退回的金额而不重复入账。
+func RefundOrder(tx *gorm.DB, orderID uint, amount decimal.Decimal, reference, remark string) (decimal.Decimal, error) {
+	if reference != "" {
+		var refunded decimal.Decimal
+		var count int64
+		if err := tx.Model(&model.GiftCardTransaction{}).
+			Where("order_id = ? AND type = ? AND reference = ?", orderID, model.GiftCardTxRefund, reference).
+			Count(&count).Error; err != nil {
+			return decimal.Zero, fmt.Errorf("查询礼品卡退款流水失败: %v", err)
+		}
+		if count > 0 {
+			if err := tx.Model(&model.GiftCardTransaction{}).
+				Select("COALESCE(SUM(amount), 0)").
+				Where("order_id = ? AND type = ? AND reference = ?", orderID, model.GiftCardTxRefund, reference).
+				Scan(&refunded).Error; err != nil {
+				return decimal.Zero, fmt.Errorf("查询礼品卡退款流水失败: %v", err)
+			}
+			return refunded, nil
+		}
+	}
+
+	redemptions, err := orderRedemptions(tx, orderID)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	now := time.Now()
+	total := decimal.Zero
+	for i := len(redemptions) - 1; i >= 0; i-- {
+		r := redemptions[i]
+		refundable := r.Redeemed.Sub(r.Refunded)
+		if amount.IsPositive() {
+			refundable = decimal.Min(refundable, amount.Sub(total))
+		}
+		if !refundable.IsPositive() {
+			continue
+		}
+
+		var card model.GiftCard
+		if err := tx.First(&card, r.CardID).Error; err != nil {
+			return decimal.Zero, fmt.Errorf("查询礼品卡失败: %v", err)
+		}
+		updates := map[string]interface{}{
+			"balance": gorm.Expr("balance + ?", refundable),
+		}
+		if card.IsExpired(now) {
+			updates["expires_at"] = now.Add(RefundGracePeriod)
+		}
+		if err := tx.Model(&model.GiftCard{}).Where("id = ?", card.ID).Updates(updates).Error; err != nil {
+			return decimal.Zero, fmt.Errorf("退回礼品卡余额失败: %v", err)
+		}
+		if err := recordTransaction(tx, card.ID, r.UserID, orderID, model.GiftCardTxRefund, refundable, reference, remark); err != nil {
+			return decimal.Zero, err
+		}
+
+		total = total.Add(refundable)
+		if amount.IsPositive() && total.GreaterThanOrEqual(amount) {
+			break
+		}
+	}
+	return total, nil
+}
+
+// orderRedemptions 按首次抵扣顺序汇总订单在各卡上的抵扣与退回金额
+func orderRedemptions(tx *gorm.DB, orderID uint) ([]cardRedemption, error) {
+	var transactions []model.GiftCardTransaction
+	if err := tx.Where("order_id = ? AND type IN ?", orderID, []model.GiftCardTransactionType{model.GiftCardTxRedeem, model.GiftCardTxRefund}).
+		Order("id").Find(&transactions).Error; err != nil {
+		return nil, fmt.Errorf("查询礼品卡流水失败: %v", err)
+	}
+
+	index := make(map[uint]int)
+	var redemptions []cardRedemption
+	for _, t := range transactions {
+		i, ok := index[t.CardID]
+		if !ok {
+			i = len(redemptions)
+			index[t.CardID] = i
+			redemptions = append(redemptions, cardRedemption{CardID: t.CardID, UserID: t.UserID})
+		}
+		if t.Type == model.GiftCardTxRedeem {
+			redemptions[i].Redeemed = redemptions[i].Redeemed.Add(t.Amount.Neg())
+		} else {
+			redemptions[i].Refunded = redemptions[i].Refunded.Add(t.Amount)
+		}
+	}
+	return redemptions, nil
+}
+
+// recordTransaction 记录礼品卡流水，变动后余额以更新后的卡余额为准
+func recordTransaction(tx *gorm.DB, cardID, userID, orderID uint, txType model.GiftCardTransactionType, amount decimal.Decimal, reference, remark string) error {
+	var card model.GiftCard
+	if err := tx.Select("id", "balance").First(&card, cardID).Error; err != nil {
+		return fmt.Errorf("查询礼品卡余额失败: %v", err)
+	}
+
+	transaction := &model.GiftCardTransaction{
+		CardID:       cardID,
+		UserID:       userID,
+		OrderID:      orderID,
+		Type:         txType,
+		Amount:       amount,
+		BalanceAfter: card.Balance,
+		Reference:    reference,
+		Remark:       remark,
+	}
+	if err := tx.Create(transaction).Error; err != nil {
+		return fmt.Errorf("记录礼品卡流水失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,447 @@
+package giftcard
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/pagination"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// EnvCodeSecret 卡密哈希密钥环境变量
+const EnvCodeSecret = "GIFT_CARD_CODE_SECRET"
+
+// codeAlphabet 卡密字符集，去除易混淆的 0/O、1/I/L
+const codeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// codeLength 卡密长度（不含分隔符）
+const codeLength = 16
+
+// Options 礼品卡配置
+type Options struct {
+	CodeSecret    string         // 卡密哈希密钥，为空时使用不带密钥的SHA-256
+	Location      *time.Location // 导出按该时区划分自然日
+	MaxExportDays int            // 流水导出的最大天数
+}
+
+// DefaultOptions 默认礼品卡配置，卡密哈希密钥从环境变量读取
+func DefaultOptions() Options {
+	return Options{
+		CodeSecret:    os.Getenv(EnvCodeSecret),
+		Location:      time.Local,
+		MaxExportDays: 366,
+	}
+}
+
+// Service 礼品卡服务
+// 后台批量生成卡密，用户可绑定到账户或下单时直接使用；部分使用后余额保留在卡上，退款退回原卡
+type Service struct {
+	db      *gorm.DB
+	options Options
+	now     func() time.Time
+}
+
+// NewService 创建礼品卡服务
+func NewService(db *gorm.DB, options Options) *Service {
+	if options.Location == nil {
+		options.Location = time.Local
+	}
+	if options.MaxExportDays <= 0 {
+		options.MaxExportDays = DefaultOptions().MaxExportDays
+	}
+	return &Service{
+		db:      db,
+		options: options,
+		now:     time.Now,
+	}
+}
+
+// CreateBatch 批量生成礼品卡，卡密仅在返回结果中出现一次
+func (s *Service) CreateBatch(req *model.CreateGiftCardBatchRequest, operatorID uint) (*model.GiftCardBatchResponse, error) {
+	if !req.Denomination.IsPositive() {
+		return nil, model.ErrInvalidGiftCardAmount
+	}
+	now := s.now()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return nil, model.ErrInvalidGiftCardExpiry
+	}
+	denomination := req.Denomination.Round(2)
+
+	batch := &model.GiftCardBatch{
+		BatchNo:      fmt.Sprintf("GB%d%04d", time.Now().UnixNano(), operatorID%10000),
+		Name:         req.Name,
+		Denomination: denomination,
+		Quantity:     req.Quantity,
+		ExpiresAt:    req.ExpiresAt,
+		OperatorID:   operatorID,
+	}
+
+	codes := make([]string, 0, req.Quantity)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(batch).Error; err != nil {
+			return fmt.Errorf("创建礼品卡批次失败: %v", err)
+		}
+
+		seen := make(map[string]bool, req.Quantity)
+		cards := make([]model.GiftCard, 0, req.Quantity)
+		for len(cards) < req.Quantity {
+			code, err := generateCode()
+			if err != nil {
+				return err
+			}
+			hash := s.hashCode(code)
+			if seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			codes = append(codes, formatCode(code))
+			cards = append(cards, model.GiftCard{
+				BatchID:      batch.ID,
+				CodeHash:     hash,
+				CodeLast4:    code[codeLength-4:],
+				Denomination: denomination,
+				Balance:      denomination,
+				Status:       model.GiftCardActive,
+				ExpiresAt:    req.ExpiresAt,
+			})
+		}
+		if err := tx.CreateInBatches(&cards, 500).Error; err != nil {
+			return fmt.Errorf("创建礼品卡失败: %v", err)
+		}
+
+		transactions := make([]model.GiftCardTransaction, len(cards))
+		for i := range cards {
+			transactions[i] = model.GiftCardTransaction{
+				CardID:       cards[i].ID,
+				Type:         model.GiftCardTxIssue,
+				Amount:       denomination,
+				BalanceAfter: denomination,
+				Reference:    batch.BatchNo,
+				Remark:       "批量发卡",
+			}
+		}
+		if err := tx.CreateInBatches(&transactions, 500).Error; err != nil {
+			return fmt.Errorf("记录发卡流水失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("生成礼品卡批次",
+		zap.String("batch_no", batch.BatchNo),
+		zap.String("denomination", denomination.String()),
+		zap.Int("quantity", req.Quantity),
+		zap.Uint("operator_id", operatorID))
+	return &model.GiftCardBatchResponse{Batch: batch, Codes: codes}, nil
+}
+
+// ListBatches 分页查询礼品卡批次
+func (s *Service) ListBatches(page, pageSize int) ([]model.GiftCardBatch, int64, error) {
+	page, pageSize = pagination.Normalize(page, pageSize)
+
+	var total int64
+	if err := s.db.Model(&model.GiftCardBatch{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计礼品卡批次失败: %v", err)
+	}
+	var batches []model.GiftCardBatch
+	if err := s.db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&batches).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询礼品卡批次失败: %v", err)
+	}
+	return batches, total, nil
+}
+
+// ListCards 分页查询礼品卡
+func (s *Service) ListCards(query *model.GiftCardQuery) ([]model.GiftCard, int64, error) {
+	page, pageSize := pagination.Normalize(query.Page, query.PageSize)
+
+	db := s.db.Model(&model.GiftCard{})
+	if query.BatchID > 0 {
+		db = db.Where("batch_id = ?", query.BatchID)
+	}
+	if query.UserID > 0 {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计礼品卡失败: %v", err)
+	}
+	var cards []model.GiftCard
+	if err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&cards).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询礼品卡失败: %v", err)
+	}
+	return cards, total, nil
+}
+
+// SetStatus 冻结或解冻礼品卡
+func (s *Service) SetStatus(id uint, status model.GiftCardStatus, operatorID uint) (*model.GiftCard, error) {
+	var card model.GiftCard
+	if err := s.db.First(&card, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrGiftCardNotFound
+		}
+		return nil, fmt.Errorf("查询礼品卡失败: %v", err)
+	}
+	if err := s.db.Model(&card).Update("status", status).Error; err != nil {
+		return nil, fmt.Errorf("更新礼品卡状态失败: %v", err)
+	}
+	card.Status = status
+
+	logger.Info("更新礼品卡状态",
+		zap.Uint("card_id", id),
+		zap.String("status", string(status)),
+		zap.Uint("operator_id", operatorID))
+	return &card, nil
+}
+
+// Bind 将礼品卡绑定到用户账户，重复绑定本人的卡直接返回
+func (s *Service) Bind(userID uint, code string) (*model.GiftCard, error) {
+	card, err := s.findByCode(s.db, code)
+	if err != nil {
+		return nil, err
+	}
+	if card.UserID == userID {
+		return card, nil
+	}
+	if card.UserID != 0 {
+		return nil, model.ErrGiftCardBound
+	}
+	if card.Status == model.GiftCardDisabled {
+		return nil, model.ErrGiftCardDisabled
+	}
+
+	now := s.now()
+	result := s.db.Model(&model.GiftCard{}).
+		Where("id = ? AND user_id = 0", card.ID).
+		Updates(map[string]interface{}{"user_id": userID, "bound_at": now})
+	if result.Error != nil {
+		return nil, fmt.Errorf("绑定礼品卡失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, model.ErrGiftCardBound
+	}
+
+	card.UserID = userID
+	card.BoundAt = &now
+	return card, nil
+}
+
+// ListUserCards 查询用户绑定的礼品卡，按过期时间先后排列
+func (s *Service) ListUserCards(userID uint) ([]model.GiftCard, error) {
+	var cards []model.GiftCard
+	if err := s.db.Where("user_id = ?", userID).
+		Order("CASE WHEN expires_at IS NULL THEN 1 ELSE 0 END, expires_at, id").
+		Find(&cards).Error; err != nil {
+		return nil, fmt.Errorf("查询礼品卡失败: %v", err)
+	}
+	return cards, nil
+}
+
+// ListUserTransactions 查询用户礼品卡流水
+func (s *Service) ListUserTransactions(userID, cardID uint) ([]model.GiftCardTransaction, error) {
+	var card model.GiftCard
+	if err := s.db.Where("id = ? AND user_id = ?", cardID, userID).First(&card).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrGiftCardNotFound
+		}
+		return nil, fmt.Errorf("查询礼品卡失败: %v", err)
+	}
+
+	var transactions []model.GiftCardTransaction
+	if err := s.db.Where("card_id = ?", cardID).Order("id DESC").Find(&transactions).Error; err != nil {
+		return nil, fmt.Errorf("查询礼品卡流水失败: %v", err)
+	}
+	return transactions, nil
+}
+
+// Resolve 校验下单使用的礼品卡，返回可用卡（先过期的先用）及可抵扣总额
+// ids 为用户已绑定的卡，codes 为直接输入的卡密，使用后绑定到当前用户
+func (s *Service) Resolve(tx *gorm.DB, userID uint, ids []uint, codes []string) ([]model.GiftCard, decimal.Decimal, error) {
+	now := s.now()
+	seen := make(map[uint]bool)
+	var cards []model.GiftCard
+	add := func(card *model.GiftCard) error {
+		if seen[card.ID] {
+			return nil
+		}
+		seen[card.ID] = true
+		if card.UserID != 0 && card.UserID != userID {
+			return model.ErrGiftCardBound
+		}
+		if card.Status == model.GiftCardDisabled {
+			return fmt.Errorf("%w: 尾号%s", model.ErrGiftCardDisabled, card.CodeLast4)
+		}
+		if card.IsExpired(now) {
+			return fmt.Errorf("%w: 尾号%s", model.ErrGiftCardExpired, card.CodeLast4)
+		}
+		if !card.Balance.IsPositive() {
+			return fmt.Errorf("%w: 尾号%s", model.ErrGiftCardNoBalance, card.CodeLast4)
+		}
+		cards = append(cards, *card)
+		return nil
+	}
+
+	for _, id := range ids {
+		var card model.GiftCard
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&card).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, decimal.Zero, model.ErrGiftCardNotFound
+			}
+			return nil, decimal.Zero, fmt.Errorf("查询礼品卡失败: %v", err)
+		}
+		if err := add(&card); err != nil {
+			return nil, decimal.Zero, err
+		}
+	}
+	for _, code := range codes {
+		card, err := s.findByCode(tx, code)
+		if err != nil {
+			return nil, decimal.Zero, err
+		}
+		if err := add(card); err != nil {
+			return nil, decimal.Zero, err
+		}
+	}
+
+	sortByExpiry(cards)
+	total := decimal.Zero
+	for _, card := range cards {
+		total = total.Add(card.Balance)
+	}
+	return cards, total, nil
+}
+
+// Redeem 按顺序从礼品卡扣减 amount 用于支付订单，需在下单事务内调用
+// 余额使用条件更新扣减，并发使用导致余额不足时返回错误由调用方回滚
+func (s *Service) Redeem(tx *gorm.DB, userID, orderID uint, cards []model.GiftCard, amount decimal.Decimal) error {
+	now := s.now()
+	remaining := amount
+	for _, card := range cards {
+		if !remaining.IsPositive() {
+			break
+		}
+		take := decimal.Min(remaining, card.Balance)
+
+		result := tx.Model(&model.GiftCard{}).
+			Where("id = ? AND status = ? AND balance >= ? AND (user_id = 0 OR user_id = ?)", card.ID, model.GiftCardActive, take, userID).
+			Updates(map[string]interface{}{
+				"balance":  gorm.Expr("balance - ?", take),
+				"user_id":  userID,
+				"bound_at": gorm.Expr("COALESCE(bound_at, ?)", now),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("扣减礼品卡余额失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: 尾号%s", model.ErrGiftCardNoBalance, card.CodeLast4)
+		}
+
+		if err := recordTransaction(tx, card.ID, userID, orderID, model.GiftCardTxRedeem, take.Neg(), "", "下单抵扣"); err != nil {
+			return err
+		}
+		remaining = remaining.Sub(take)
+	}
+	if remaining.IsPositive() {
+		return model.ErrGiftCardNoBalance
+	}
+	return nil
+}
+
+// findByCode 按卡密查询礼品卡
+func (s *Service) findByCode(db *gorm.DB, code string) (*model.GiftCard, error) {
+	normalized := normalizeCode(code)
+	if len(normalized) != codeLength {
+		return nil, model.ErrGiftCardNotFound
+	}
+
+	var card model.GiftCard
+	if err := db.Where("code_hash = ?", s.hashCode(normalized)).First(&card).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrGiftCardNotFound
+		}
+		return nil, fmt.Errorf("查询礼品卡失败: %v", err)
+	}
+	return &card, nil
+}
+
+// hashCode 计算卡密哈希，配置了密钥时使用HMAC，防止泄露的哈希被离线穷举
+func (s *Service) hashCode(normalized string) string {
+	if s.options.CodeSecret == "" {
+		sum := sha256.Sum256([]byte(normalized))
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, []byte(s.options.CodeSecret))
+	mac.Write([]byte(normalized))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateCode 生成随机卡密
+func generateCode() (string, error) {
+	max := big.NewInt(int64(len(codeAlphabet)))
+	code := make([]byte, codeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("生成卡密失败: %v", err)
+		}
+		code[i] = codeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// formatCode 卡密每4位以短横线分隔，便于抄录
+func formatCode(code string) string {
+	var b strings.Builder
+	for i := 0; i < len(code); i += 4 {
+		if i > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(code[i : i+4])
+	}
+	return b.String()
+}
+
+// normalizeCode 去除分隔符与空白并转为大写
+func normalizeCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// sortByExpiry 先过期的卡排在前面，长期有效的卡最后使用
+func sortByExpiry(cards []model.GiftCard) {
+	sort.SliceStable(cards, func(i, j int) bool {
+		return expiresBefore(&cards[i], &cards[j])
+	})
+}
+
+// expiresBefore a 是否比 b 先过期
+func expiresBefore(a, b *model.GiftCard) bool {
+	if a.ExpiresAt == nil {
+		return false
+	}
+	return b.ExpiresAt == nil || a.ExpiresAt.Before(*b.ExpiresAt)
+}
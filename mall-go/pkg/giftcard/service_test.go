@@ -0,0 +1,186 @@
+package giftcard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// GiftCardServiceTestSuite 礼品卡服务测试套件
+type GiftCardServiceTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service *Service
+}
+
+// SetupTest 每个用例使用独立的内存数据库
+func (suite *GiftCardServiceTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.AutoMigrate(&model.GiftCardBatch{}, &model.GiftCard{}, &model.GiftCardTransaction{}))
+
+	suite.db = db
+	suite.service = NewService(db, Options{CodeSecret: "test-secret", Location: time.UTC})
+}
+
+// createCards 生成一批面值100的礼品卡
+func (suite *GiftCardServiceTestSuite) createCards(quantity int) []string {
+	result, err := suite.service.CreateBatch(&model.CreateGiftCardBatchRequest{
+		Name:         "测试批次",
+		Denomination: decimal.NewFromInt(100),
+		Quantity:     quantity,
+	}, 1)
+	suite.Require().NoError(err)
+	suite.Require().Len(result.Codes, quantity)
+	return result.Codes
+}
+
+// redeem 在事务内校验并扣减礼品卡
+func (suite *GiftCardServiceTestSuite) redeem(userID, orderID uint, ids []uint, codes []string, amount string) {
+	suite.Require().NoError(suite.db.Transaction(func(tx *gorm.DB) error {
+		cards, available, err := suite.service.Resolve(tx, userID, ids, codes)
+		if err != nil {
+			return err
+		}
+		return suite.service.Redeem(tx, userID, orderID, cards, decimal.Min(available, decimal.RequireFromString(amount)))
+	}))
+}
+
+func (suite *GiftCardServiceTestSuite) balanceOf(id uint) decimal.Decimal {
+	var card model.GiftCard
+	suite.Require().NoError(suite.db.First(&card, id).Error)
+	return card.Balance
+}
+
+func (suite *GiftCardServiceTestSuite) TestService_BindAndRedeem() {
+	codes := suite.createCards(2)
+
+	// 卡密不区分大小写与分隔符
+	first, err := suite.service.Bind(7, strings.ToLower(strings.ReplaceAll(codes[0], "-", "")))
+	suite.Require().NoError(err)
+	suite.Equal(uint(7), first.UserID)
+	_, err = suite.service.Bind(8, codes[0])
+	suite.ErrorIs(err, model.ErrGiftCardBound)
+	_, err = suite.service.Bind(7, "AAAA-BBBB-CCCC-DDDD")
+	suite.ErrorIs(err, model.ErrGiftCardNotFound)
+
+	// 绑定的卡与直接输入卡密的卡一起使用，部分使用后余额保留
+	suite.redeem(7, 1, []uint{first.ID}, codes[1:], "150")
+
+	second, err := suite.service.findByCode(suite.db, codes[1])
+	suite.Require().NoError(err)
+	suite.True(suite.balanceOf(first.ID).IsZero())
+	suite.True(second.Balance.Equal(decimal.NewFromInt(50)))
+	suite.Equal(uint(7), second.UserID)
+
+	transactions, err := suite.service.ListUserTransactions(7, second.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(transactions, 2)
+	suite.Equal(model.GiftCardTxRedeem, transactions[0].Type)
+	suite.True(transactions[0].Amount.Equal(decimal.NewFromInt(-50)))
+	suite.True(transactions[0].BalanceAfter.Equal(decimal.NewFromInt(50)))
+
+	// 其他用户不可使用已绑定的卡，冻结的卡不可使用
+	err = suite.db.Transaction(func(tx *gorm.DB) error {
+		_, _, err := suite.service.Resolve(tx, 8, nil, codes[1:])
+		return err
+	})
+	suite.ErrorIs(err, model.ErrGiftCardBound)
+	_, err = suite.service.SetStatus(second.ID, model.GiftCardDisabled, 1)
+	suite.Require().NoError(err)
+	err = suite.db.Transaction(func(tx *gorm.DB) error {
+		_, _, err := suite.service.Resolve(tx, 7, []uint{second.ID}, nil)
+		return err
+	})
+	suite.ErrorIs(err, model.ErrGiftCardDisabled)
+}
+
+func (suite *GiftCardServiceTestSuite) TestRefundOrder() {
+	codes := suite.createCards(2)
+	suite.redeem(7, 1, nil, codes, "150")
+
+	first, err := suite.service.findByCode(suite.db, codes[0])
+	suite.Require().NoError(err)
+	second, err := suite.service.findByCode(suite.db, codes[1])
+	suite.Require().NoError(err)
+
+	// 已过期的卡退回时延长有效期
+	expired := time.Now().Add(-time.Hour)
+	suite.Require().NoError(suite.db.Model(first).Update("expires_at", expired).Error)
+
+	// 后抵扣的卡先退回
+	refunded, err := RefundOrder(suite.db, 1, decimal.NewFromInt(60), "AS001", "售后退款")
+	suite.Require().NoError(err)
+	suite.True(refunded.Equal(decimal.NewFromInt(60)))
+	suite.True(suite.balanceOf(second.ID).Equal(decimal.NewFromInt(100)))
+	suite.True(suite.balanceOf(first.ID).Equal(decimal.NewFromInt(10)))
+
+	// 同一售后单重复退款不重复入账
+	refunded, err = RefundOrder(suite.db, 1, decimal.NewFromInt(60), "AS001", "售后退款")
+	suite.Require().NoError(err)
+	suite.True(refunded.Equal(decimal.NewFromInt(60)))
+	suite.True(suite.balanceOf(first.ID).Equal(decimal.NewFromInt(10)))
+
+	// 不指定金额时退回剩余全部
+	refunded, err = RefundOrder(suite.db, 1, decimal.Zero, "", "订单取消")
+	suite.Require().NoError(err)
+	suite.True(refunded.Equal(decimal.NewFromInt(90)))
+	suite.Require().NoError(suite.db.First(first, first.ID).Error)
+	suite.True(first.Balance.Equal(decimal.NewFromInt(100)))
+	suite.False(first.IsExpired(time.Now()))
+}
+
+func (suite *GiftCardServiceTestSuite) TestService_Liability() {
+	codes := suite.createCards(3)
+	suite.redeem(7, 1, nil, codes[:1], "30")
+	_, err := RefundOrder(suite.db, 1, decimal.NewFromInt(10), "AS001", "售后退款")
+	suite.Require().NoError(err)
+
+	// 第三张卡已过期，余额计入沉淀
+	expired, err := suite.service.findByCode(suite.db, codes[2])
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.db.Model(expired).Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+	liability, err := suite.service.Liability("")
+	suite.Require().NoError(err)
+	suite.Equal(int64(3), liability.IssuedCount)
+	suite.True(liability.IssuedAmount.Equal(decimal.NewFromInt(300)))
+	suite.True(liability.RedeemedAmount.Equal(decimal.NewFromInt(30)))
+	suite.True(liability.RefundedAmount.Equal(decimal.NewFromInt(10)))
+	suite.Equal(int64(2), liability.OutstandingCount)
+	suite.True(liability.Outstanding.Equal(decimal.NewFromInt(180)))
+	suite.True(liability.ExpiredBalance.Equal(decimal.NewFromInt(100)))
+
+	// 统计日期早于发卡时没有负债
+	liability, err = suite.service.Liability("2000-01-01")
+	suite.Require().NoError(err)
+	suite.Zero(liability.IssuedCount)
+	suite.True(liability.Outstanding.IsZero())
+
+	var buf bytes.Buffer
+	suite.Require().NoError(suite.service.WriteLiabilityCSV(&buf, time.Now().UTC().Format(dateLayout)))
+	lines := strings.Split(strings.TrimSpace(strings.TrimPrefix(buf.String(), utf8BOM)), "\n")
+	suite.Require().Len(lines, 4)
+	suite.Contains(lines[3], "expired")
+
+	buf.Reset()
+	today := time.Now().UTC().Format(dateLayout)
+	suite.Require().NoError(suite.service.WriteTransactionsCSV(&buf, today, today))
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	suite.Len(lines, 6)
+
+	suite.ErrorIs(suite.service.WriteTransactionsCSV(&buf, "2026-01-01", "2027-06-01"), model.ErrGiftCardExportRange)
+	suite.ErrorIs(suite.service.WriteTransactionsCSV(&buf, "2026-02-01", "2026-01-01"), model.ErrGiftCardExportRange)
+}
+
+func TestGiftCardServiceSuite(t *testing.T) {
+	suite.Run(t, new(GiftCardServiceTestSuite))
+}
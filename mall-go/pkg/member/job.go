@@ -0,0 +1,72 @@
+package member
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mall-go/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// Scheduler 会员等级定期评定任务，扣除过期成长值并调整等级
+type Scheduler struct {
+	service *Service
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewScheduler 创建并启动会员等级评定任务
+func NewScheduler(service *Service) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler := &Scheduler{
+		service: service,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	scheduler.wg.Add(1)
+	go scheduler.run()
+
+	logger.Info("会员等级评定任务启动",
+		zap.Duration("interval", service.options.EvaluateInterval),
+		zap.Int("batch_size", service.options.BatchSize))
+
+	return scheduler
+}
+
+// run 评定主循环，单轮处理一批，处理满批时立即继续下一批
+func (j *Scheduler) run() {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(j.service.options.EvaluateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+			total := 0
+			for j.ctx.Err() == nil {
+				evaluated := j.service.EvaluateDue()
+				total += evaluated
+				if evaluated < j.service.options.BatchSize {
+					break
+				}
+			}
+			if total > 0 {
+				logger.Info("会员等级评定完成", zap.Int("evaluated", total))
+			}
+		}
+	}
+}
+
+// Stop 停止会员等级评定任务
+func (j *Scheduler) Stop() {
+	logger.Info("停止会员等级评定任务")
+	j.cancel()
+	j.wg.Wait()
+}
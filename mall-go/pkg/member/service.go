@@ -0,0 +1,523 @@
+package member
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/pagination"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Options 会员配置
+type Options struct {
+	GrowthPerYuan    decimal.Decimal // 订单完成时每实付1元获得的成长值
+	ReviewGrowth     int             // 每条评价获得的成长值
+	CheckInGrowth    int             // 每日签到获得的成长值
+	ValidityDays     int             // 成长值有效天数，等级按有效期内的成长值评定
+	EvaluateInterval time.Duration   // 定期评定间隔，过期成长值在评定时扣除
+	BatchSize        int             // 每轮评定的会员数
+	Location         *time.Location  // 签到按该时区划分自然日
+}
+
+// DefaultOptions 默认会员配置
+func DefaultOptions() Options {
+	return Options{
+		GrowthPerYuan:    decimal.NewFromInt(1),
+		ReviewGrowth:     10,
+		CheckInGrowth:    5,
+		ValidityDays:     365,
+		EvaluateInterval: time.Hour,
+		BatchSize:        200,
+		Location:         time.Local,
+	}
+}
+
+// 评定原因
+const (
+	ReasonOrderCompleted = "order_completed" // 订单完成
+	ReasonReview         = "review"          // 评价
+	ReasonCheckIn        = "check_in"        // 签到
+	ReasonAdjust         = "adjust"          // 后台调整
+	ReasonPeriodic       = "periodic"        // 定期评定
+)
+
+// dateLayout 签到日期格式
+const dateLayout = "2006-01-02"
+
+// Service 会员服务
+// 成长值由订单完成、评价、签到累积，等级按有效期内的成长值评定，定价读取用户当前等级的权益
+type Service struct {
+	db      *gorm.DB
+	options Options
+	now     func() time.Time
+}
+
+// NewService 创建会员服务
+func NewService(db *gorm.DB, options Options) *Service {
+	if options.Location == nil {
+		options.Location = time.Local
+	}
+	if options.ValidityDays <= 0 {
+		options.ValidityDays = 365
+	}
+	if options.EvaluateInterval <= 0 {
+		options.EvaluateInterval = time.Hour
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = 200
+	}
+	return &Service{
+		db:      db,
+		options: options,
+		now:     time.Now,
+	}
+}
+
+// SeedDefaultLevels 未配置等级时写入默认的白银、黄金、铂金等级
+func (s *Service) SeedDefaultLevels() error {
+	var count int64
+	if err := s.db.Model(&model.MemberLevel{}).Count(&count).Error; err != nil {
+		return fmt.Errorf("统计会员等级失败: %v", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	levels := []model.MemberLevel{
+		{Code: "silver", Name: "白银会员", MinGrowth: 0, DiscountRate: decimal.Zero, FreeShippingThreshold: decimal.NewFromInt(99), PointsMultiplier: decimal.NewFromInt(1), IsActive: true},
+		{Code: "gold", Name: "黄金会员", MinGrowth: 2000, DiscountRate: decimal.NewFromFloat(0.05), FreeShippingThreshold: decimal.NewFromInt(59), PointsMultiplier: decimal.NewFromFloat(1.5), IsActive: true},
+		{Code: "platinum", Name: "铂金会员", MinGrowth: 10000, DiscountRate: decimal.NewFromFloat(0.08), FreeShippingThreshold: decimal.Zero, PointsMultiplier: decimal.NewFromInt(2), IsActive: true},
+	}
+	if err := s.db.Create(&levels).Error; err != nil {
+		return fmt.Errorf("写入默认会员等级失败: %v", err)
+	}
+	return nil
+}
+
+// ListLevels 查询会员等级，activeOnly 为 true 时只返回启用的等级，按成长值门槛升序
+func (s *Service) ListLevels(activeOnly bool) ([]model.MemberLevel, error) {
+	return s.levels(s.db, activeOnly)
+}
+
+// CreateLevel 创建会员等级
+func (s *Service) CreateLevel(req *model.MemberLevelRequest, operatorID uint) (*model.MemberLevel, error) {
+	level := &model.MemberLevel{}
+	if err := s.applyLevelRequest(level, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(level).Error; err != nil {
+		return nil, fmt.Errorf("创建会员等级失败: %v", err)
+	}
+
+	logger.Info("创建会员等级", zap.Uint("level_id", level.ID), zap.String("code", level.Code), zap.Uint("operator_id", operatorID))
+	return level, nil
+}
+
+// UpdateLevel 更新会员等级，已有会员在下次评定时按新门槛调整等级
+func (s *Service) UpdateLevel(id uint, req *model.MemberLevelRequest, operatorID uint) (*model.MemberLevel, error) {
+	var level model.MemberLevel
+	if err := s.db.First(&level, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrMemberLevelNotFound
+		}
+		return nil, fmt.Errorf("查询会员等级失败: %v", err)
+	}
+	if err := s.applyLevelRequest(&level, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(&level).Error; err != nil {
+		return nil, fmt.Errorf("更新会员等级失败: %v", err)
+	}
+
+	logger.Info("更新会员等级", zap.Uint("level_id", id), zap.Uint("operator_id", operatorID))
+	return &level, nil
+}
+
+// Level 查询用户当前等级，未评定过的用户为最低等级；未配置等级时返回 nil
+func (s *Service) Level(userID uint) (*model.MemberLevel, error) {
+	if userID == 0 {
+		return nil, nil
+	}
+
+	var membership model.UserMembership
+	err := s.db.Preload("Level").Where("user_id = ?", userID).First(&membership).Error
+	if err == nil && membership.Level != nil && membership.Level.IsActive {
+		return membership.Level, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("查询会员信息失败: %v", err)
+	}
+
+	levels, err := s.levels(s.db, true)
+	if err != nil {
+		return nil, err
+	}
+	return levelFor(levels, membership.Growth), nil
+}
+
+// CouponAllowed 校验用户能否使用优惠券，某等级专享的优惠券仅该等级会员可用
+func (s *Service) CouponAllowed(userID, couponID uint) error {
+	levels, err := s.levels(s.db, true)
+	if err != nil {
+		return err
+	}
+	exclusive := false
+	for i := range levels {
+		if levels[i].HasCoupon(couponID) {
+			exclusive = true
+			break
+		}
+	}
+	if !exclusive {
+		return nil
+	}
+
+	level, err := s.Level(userID)
+	if err != nil {
+		return err
+	}
+	if level == nil || !level.HasCoupon(couponID) {
+		return model.ErrMemberCouponExclusive
+	}
+	return nil
+}
+
+// OnOrderCompleted 订单完成时按实收金额累积成长值并重新评定等级，需在订单状态事务内调用
+func (s *Service) OnOrderCompleted(tx *gorm.DB, order *model.Order) error {
+	growth := int(order.ChargedAmount().Sub(order.RefundAmount).Mul(s.options.GrowthPerYuan).IntPart())
+	if growth <= 0 {
+		return nil
+	}
+	added, err := s.addGrowth(tx, order.UserID, model.GrowthSourceOrder, strconv.FormatUint(uint64(order.ID), 10), growth, "订单完成 "+order.OrderNo)
+	if err != nil || !added {
+		return err
+	}
+	_, err = s.evaluate(tx, order.UserID, ReasonOrderCompleted)
+	return err
+}
+
+// AwardReview 评价通过后发放成长值，同一评价只发放一次
+func (s *Service) AwardReview(userID, reviewID uint) error {
+	if s.options.ReviewGrowth <= 0 {
+		return nil
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		added, err := s.addGrowth(tx, userID, model.GrowthSourceReview, strconv.FormatUint(uint64(reviewID), 10), s.options.ReviewGrowth, "商品评价")
+		if err != nil || !added {
+			return err
+		}
+		_, err = s.evaluate(tx, userID, ReasonReview)
+		return err
+	})
+}
+
+// CheckIn 每日签到，获得成长值
+func (s *Service) CheckIn(userID uint) (*model.UserMembership, error) {
+	today := s.now().In(s.options.Location).Format(dateLayout)
+
+	var membership *model.UserMembership
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		added, err := s.addGrowth(tx, userID, model.GrowthSourceCheckIn, checkInKey(userID, today), s.options.CheckInGrowth, "每日签到")
+		if err != nil {
+			return err
+		}
+		if !added {
+			return model.ErrMemberCheckedIn
+		}
+		membership, err = s.evaluate(tx, userID, ReasonCheckIn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// AdjustGrowth 后台调整成长值
+func (s *Service) AdjustGrowth(userID uint, req *model.AdjustGrowthRequest, operatorID uint) (*model.UserMembership, error) {
+	var membership *model.UserMembership
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		key := fmt.Sprintf("%d-%d", operatorID, s.now().UnixNano())
+		if _, err := s.addGrowth(tx, userID, model.GrowthSourceAdjust, key, req.Change, req.Remark); err != nil {
+			return err
+		}
+		var err error
+		membership, err = s.evaluate(tx, userID, ReasonAdjust)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("调整会员成长值",
+		zap.Uint("user_id", userID),
+		zap.Int("change", req.Change),
+		zap.Uint("operator_id", operatorID))
+	return membership, nil
+}
+
+// Profile 查询用户会员概况，首次查询时评定等级
+func (s *Service) Profile(userID uint) (*model.MemberProfile, error) {
+	var membership model.UserMembership
+	err := s.db.Where("user_id = ?", userID).First(&membership).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var evaluated *model.UserMembership
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			evaluated, err = s.evaluate(tx, userID, ReasonPeriodic)
+			return err
+		})
+		if evaluated != nil {
+			membership = *evaluated
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询会员信息失败: %v", err)
+	}
+
+	levels, err := s.levels(s.db, true)
+	if err != nil {
+		return nil, err
+	}
+	profile := &model.MemberProfile{Membership: &membership}
+	for i := range levels {
+		if levels[i].ID == membership.LevelID {
+			profile.Level = &levels[i]
+		}
+		if levels[i].MinGrowth > membership.Growth && profile.NextLevel == nil {
+			profile.NextLevel = &levels[i]
+			profile.NeedGrowth = levels[i].MinGrowth - membership.Growth
+		}
+	}
+
+	today := s.now().In(s.options.Location).Format(dateLayout)
+	var checkIns int64
+	if err := s.db.Model(&model.MemberGrowthLog{}).
+		Where("source = ? AND source_key = ?", model.GrowthSourceCheckIn, checkInKey(userID, today)).
+		Count(&checkIns).Error; err != nil {
+		return nil, fmt.Errorf("查询签到记录失败: %v", err)
+	}
+	profile.CheckedInToday = checkIns > 0
+	return profile, nil
+}
+
+// ListGrowthLogs 分页查询用户成长值流水
+func (s *Service) ListGrowthLogs(userID uint, page, pageSize int) ([]model.MemberGrowthLog, int64, error) {
+	page, pageSize = pagination.Normalize(page, pageSize)
+
+	db := s.db.Model(&model.MemberGrowthLog{}).Where("user_id = ?", userID)
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计成长值流水失败: %v", err)
+	}
+	var logs []model.MemberGrowthLog
+	if err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询成长值流水失败: %v", err)
+	}
+	return logs, total, nil
+}
+
+// ListLevelChanges 查询用户等级变更记录
+func (s *Service) ListLevelChanges(userID uint) ([]model.MemberLevelChange, error) {
+	var changes []model.MemberLevelChange
+	if err := s.db.Where("user_id = ?", userID).Order("id DESC").Find(&changes).Error; err != nil {
+		return nil, fmt.Errorf("查询等级变更记录失败: %v", err)
+	}
+	return changes, nil
+}
+
+// EvaluateDue 定期评定超过评定间隔的会员，扣除过期成长值后调整等级，返回评定的会员数
+func (s *Service) EvaluateDue() int {
+	cutoff := s.now().Add(-s.options.EvaluateInterval)
+
+	var userIDs []uint
+	if err := s.db.Model(&model.UserMembership{}).
+		Where("evaluated_at < ?", cutoff).
+		Order("evaluated_at ASC").
+		Limit(s.options.BatchSize).
+		Pluck("user_id", &userIDs).Error; err != nil {
+		logger.Error("查询待评定会员失败", zap.Error(err))
+		return 0
+	}
+
+	evaluated := 0
+	for _, userID := range userIDs {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			_, err := s.evaluate(tx, userID, ReasonPeriodic)
+			return err
+		})
+		if err != nil {
+			logger.Error("评定会员等级失败", zap.Uint("user_id", userID), zap.Error(err))
+			continue
+		}
+		evaluated++
+	}
+	return evaluated
+}
+
+// addGrowth 记录成长值流水，同一来源已记录时返回 false
+func (s *Service) addGrowth(tx *gorm.DB, userID uint, source model.GrowthSource, key string, change int, remark string) (bool, error) {
+	if change == 0 {
+		return false, nil
+	}
+	var count int64
+	if err := tx.Model(&model.MemberGrowthLog{}).Where("source = ? AND source_key = ?", source, key).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询成长值流水失败: %v", err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	growthLog := &model.MemberGrowthLog{
+		UserID:    userID,
+		Source:    source,
+		SourceKey: key,
+		Growth:    change,
+		Remark:    remark,
+		CreatedAt: s.now(),
+	}
+	if err := tx.Create(growthLog).Error; err != nil {
+		return false, fmt.Errorf("记录成长值流水失败: %v", err)
+	}
+	return true, nil
+}
+
+// evaluate 按有效期内的成长值评定用户等级，等级变化时记录变更
+func (s *Service) evaluate(tx *gorm.DB, userID uint, reason string) (*model.UserMembership, error) {
+	now := s.now()
+	since := now.AddDate(0, 0, -s.options.ValidityDays)
+
+	var growth int
+	if err := tx.Model(&model.MemberGrowthLog{}).
+		Select("COALESCE(SUM(growth), 0)").
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Scan(&growth).Error; err != nil {
+		return nil, fmt.Errorf("统计成长值失败: %v", err)
+	}
+	if growth < 0 {
+		growth = 0
+	}
+
+	levels, err := s.levels(tx, true)
+	if err != nil {
+		return nil, err
+	}
+	level := levelFor(levels, growth)
+	if level == nil {
+		return nil, model.ErrMemberLevelNotFound
+	}
+
+	var membership model.UserMembership
+	err = tx.Where("user_id = ?", userID).First(&membership).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("查询会员信息失败: %v", err)
+	}
+	fromLevelID := membership.LevelID
+
+	membership.UserID = userID
+	membership.Growth = growth
+	membership.EvaluatedAt = now
+	if fromLevelID != level.ID {
+		membership.LevelID = level.ID
+		membership.LevelChangedAt = &now
+	}
+	if err := tx.Save(&membership).Error; err != nil {
+		return nil, fmt.Errorf("更新会员信息失败: %v", err)
+	}
+
+	if fromLevelID != level.ID {
+		change := &model.MemberLevelChange{
+			UserID:      userID,
+			FromLevelID: fromLevelID,
+			ToLevelID:   level.ID,
+			ToLevel:     level.Name,
+			Growth:      growth,
+			Reason:      reason,
+		}
+		for i := range levels {
+			if levels[i].ID == fromLevelID {
+				change.FromLevel = levels[i].Name
+			}
+		}
+		if err := tx.Create(change).Error; err != nil {
+			return nil, fmt.Errorf("记录等级变更失败: %v", err)
+		}
+		logger.Info("会员等级变更",
+			zap.Uint("user_id", userID),
+			zap.Uint("from_level_id", fromLevelID),
+			zap.Uint("to_level_id", level.ID),
+			zap.Int("growth", growth),
+			zap.String("reason", reason))
+	}
+	membership.Level = level
+	return &membership, nil
+}
+
+// levels 查询会员等级，按成长值门槛升序
+func (s *Service) levels(db *gorm.DB, activeOnly bool) ([]model.MemberLevel, error) {
+	query := db.Order("min_growth ASC")
+	if activeOnly {
+		query = query.Where("is_active = ?", true)
+	}
+	var levels []model.MemberLevel
+	if err := query.Find(&levels).Error; err != nil {
+		return nil, fmt.Errorf("查询会员等级失败: %v", err)
+	}
+	return levels, nil
+}
+
+// applyLevelRequest 校验请求并写入会员等级
+func (s *Service) applyLevelRequest(level *model.MemberLevel, req *model.MemberLevelRequest) error {
+	if req.DiscountRate.IsNegative() || !req.DiscountRate.LessThan(decimal.NewFromInt(1)) {
+		return fmt.Errorf("%w: 折扣率需在0到1之间", model.ErrInvalidMemberLevel)
+	}
+	if req.FreeShippingThreshold.IsNegative() || req.PointsMultiplier.IsNegative() {
+		return fmt.Errorf("%w: 包邮门槛和积分倍数不能为负数", model.ErrInvalidMemberLevel)
+	}
+
+	var count int64
+	if err := s.db.Model(&model.MemberLevel{}).
+		Where("id <> ? AND (code = ? OR min_growth = ?)", level.ID, req.Code, req.MinGrowth).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("查询会员等级失败: %v", err)
+	}
+	if count > 0 {
+		return model.ErrMemberLevelDuplicate
+	}
+
+	level.Code = req.Code
+	level.Name = req.Name
+	level.MinGrowth = req.MinGrowth
+	level.DiscountRate = req.DiscountRate
+	level.FreeShippingThreshold = req.FreeShippingThreshold
+	level.PointsMultiplier = req.PointsMultiplier
+	if level.PointsMultiplier.IsZero() {
+		level.PointsMultiplier = decimal.NewFromInt(1)
+	}
+	level.SetCouponIDs(req.CouponIDs)
+	level.IsActive = req.IsActive
+	return nil
+}
+
+// levelFor 成长值对应的最高等级，levels 需按门槛升序
+func levelFor(levels []model.MemberLevel, growth int) *model.MemberLevel {
+	var level *model.MemberLevel
+	for i := range levels {
+		if levels[i].MinGrowth <= growth {
+			level = &levels[i]
+		}
+	}
+	return level
+}
+
+// checkInKey 签到流水的来源标识
+func checkInKey(userID uint, date string) string {
+	return fmt.Sprintf("%d:%s", userID, date)
+}
@@ -0,0 +1,147 @@
+package member
+
+import (
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// MemberServiceTestSuite 会员服务测试套件
+type MemberServiceTestSuite struct {
+	suite.Suite
+	service *Service
+}
+
+// SetupTest 每个用例使用独立的内存数据库，并初始化默认会员等级
+func (suite *MemberServiceTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.AutoMigrate(&model.MemberLevel{}, &model.UserMembership{}, &model.MemberGrowthLog{}, &model.MemberLevelChange{}))
+
+	suite.service = NewService(db, DefaultOptions())
+	suite.Require().NoError(suite.service.SeedDefaultLevels())
+}
+
+func completedOrder(id uint, amount string) *model.Order {
+	return &model.Order{
+		ID:            id,
+		OrderNo:       "ORD" + amount,
+		UserID:        1,
+		PayableAmount: decimal.RequireFromString(amount),
+	}
+}
+
+func (suite *MemberServiceTestSuite) TestService_OrderGrowthUpgradesLevel() {
+	level, err := suite.service.Level(1)
+	suite.Require().NoError(err)
+	suite.Equal("silver", level.Code)
+
+	suite.Require().NoError(suite.service.OnOrderCompleted(suite.service.db, completedOrder(1, "1500")))
+	// 同一订单重复回调不重复累积
+	suite.Require().NoError(suite.service.OnOrderCompleted(suite.service.db, completedOrder(1, "1500")))
+	suite.Require().NoError(suite.service.OnOrderCompleted(suite.service.db, completedOrder(2, "600.80")))
+
+	level, err = suite.service.Level(1)
+	suite.Require().NoError(err)
+	suite.Equal("gold", level.Code)
+	suite.True(level.DiscountRate.Equal(decimal.NewFromFloat(0.05)))
+
+	profile, err := suite.service.Profile(1)
+	suite.Require().NoError(err)
+	suite.Equal(2100, profile.Membership.Growth)
+	suite.Require().NotNil(profile.NextLevel)
+	suite.Equal("platinum", profile.NextLevel.Code)
+	suite.Equal(7900, profile.NeedGrowth)
+
+	changes, err := suite.service.ListLevelChanges(1)
+	suite.Require().NoError(err)
+	suite.Require().Len(changes, 2)
+	suite.Equal("黄金会员", changes[0].ToLevel)
+	suite.Equal("白银会员", changes[0].FromLevel)
+	suite.Equal(ReasonOrderCompleted, changes[0].Reason)
+}
+
+func (suite *MemberServiceTestSuite) TestService_EvaluateDueExpiresGrowth() {
+	now := time.Now()
+	suite.service.now = func() time.Time { return now }
+
+	suite.Require().NoError(suite.service.OnOrderCompleted(suite.service.db, completedOrder(1, "2000")))
+	level, err := suite.service.Level(1)
+	suite.Require().NoError(err)
+	suite.Equal("gold", level.Code)
+
+	// 一年后成长值过期，定期评定降级
+	now = now.AddDate(1, 0, 1)
+	suite.Equal(1, suite.service.EvaluateDue())
+	suite.Equal(0, suite.service.EvaluateDue())
+
+	level, err = suite.service.Level(1)
+	suite.Require().NoError(err)
+	suite.Equal("silver", level.Code)
+
+	changes, err := suite.service.ListLevelChanges(1)
+	suite.Require().NoError(err)
+	suite.Equal(ReasonPeriodic, changes[0].Reason)
+}
+
+func (suite *MemberServiceTestSuite) TestService_CheckInOncePerDay() {
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local)
+	suite.service.now = func() time.Time { return now }
+
+	membership, err := suite.service.CheckIn(1)
+	suite.Require().NoError(err)
+	suite.Equal(5, membership.Growth)
+
+	_, err = suite.service.CheckIn(1)
+	suite.ErrorIs(err, model.ErrMemberCheckedIn)
+
+	now = now.Add(24 * time.Hour)
+	membership, err = suite.service.CheckIn(1)
+	suite.Require().NoError(err)
+	suite.Equal(10, membership.Growth)
+
+	logs, total, err := suite.service.ListGrowthLogs(1, 1, 20)
+	suite.Require().NoError(err)
+	suite.EqualValues(2, total)
+	suite.Equal(model.GrowthSourceCheckIn, logs[0].Source)
+}
+
+func (suite *MemberServiceTestSuite) TestService_ExclusiveCoupon() {
+	levels, err := suite.service.ListLevels(true)
+	suite.Require().NoError(err)
+	suite.Require().Len(levels, 3)
+
+	gold := levels[1]
+	_, err = suite.service.UpdateLevel(gold.ID, &model.MemberLevelRequest{
+		Code:                  gold.Code,
+		Name:                  gold.Name,
+		MinGrowth:             gold.MinGrowth,
+		DiscountRate:          gold.DiscountRate,
+		FreeShippingThreshold: gold.FreeShippingThreshold,
+		PointsMultiplier:      gold.PointsMultiplier,
+		CouponIDs:             []uint{9},
+		IsActive:              true,
+	}, 1)
+	suite.Require().NoError(err)
+
+	suite.NoError(suite.service.CouponAllowed(1, 3))
+	suite.ErrorIs(suite.service.CouponAllowed(1, 9), model.ErrMemberCouponExclusive)
+
+	_, err = suite.service.AdjustGrowth(1, &model.AdjustGrowthRequest{Change: 2000, Remark: "补偿"}, 1)
+	suite.Require().NoError(err)
+	suite.NoError(suite.service.CouponAllowed(1, 9))
+
+	// 门槛与已有等级重复
+	_, err = suite.service.CreateLevel(&model.MemberLevelRequest{Code: "diamond", Name: "钻石会员", MinGrowth: 2000}, 1)
+	suite.ErrorIs(err, model.ErrMemberLevelDuplicate)
+}
+
+func TestMemberServiceSuite(t *testing.T) {
+	suite.Run(t, new(MemberServiceTestSuite))
+}
@@ -6,8 +6,13 @@ import (
 	"time"
 
 	"mall-go/internal/model"
+	"mall-go/pkg/currency"
+	"mall-go/pkg/giftcard"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/payment"
 
 	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -16,6 +21,7 @@ type AfterSaleService struct {
 	db             *gorm.DB
 	statusService  *StatusService
 	paymentService *PaymentService
+	refundService  *payment.Service // 第三方渠道支付的退款通过支付服务执行
 }
 
 // NewAfterSaleService 创建订单售后服务
@@ -27,6 +33,13 @@ func NewAfterSaleService(db *gorm.DB, statusService *StatusService, paymentServi
 	}
 }
 
+// SetRefundService 设置支付服务，渠道支付的售后退款通过支付服务创建退款单，
+// 退款单确认成功后才完成售后
+func (as *AfterSaleService) SetRefundService(refundService *payment.Service) {
+	as.refundService = refundService
+	refundService.OnRefundSettled(as.onRefundSettled)
+}
+
 // AfterSaleRequest 售后申请请求
 type AfterSaleRequest struct {
 	OrderID     uint            `json:"order_id" binding:"required"`
@@ -93,18 +106,19 @@ func (as *AfterSaleService) CreateAfterSale(userID uint, req *AfterSaleRequest)
 
 		// 如果没有指定金额，计算退款金额
 		if req.Amount.IsZero() {
-			req.Amount = orderItem.Price.Mul(decimal.NewFromInt(int64(req.Quantity)))
+			req.Amount = orderItem.RefundAmountFor(req.Quantity)
 		}
 	} else {
 		// 整单退款
 		if req.Amount.IsZero() {
-			req.Amount = order.PayableAmount.Sub(order.RefundAmount)
+			req.Amount = order.ChargedAmount().Sub(order.RefundAmount)
 		}
 		req.Quantity = 1
 	}
 
 	// 检查退款金额
-	maxRefundAmount := order.PayableAmount.Sub(order.RefundAmount)
+	// 可退金额包含礼品卡抵扣部分
+	maxRefundAmount := order.ChargedAmount().Sub(order.RefundAmount)
 	if req.Amount.GreaterThan(maxRefundAmount) {
 		tx.Rollback()
 		return nil, fmt.Errorf("退款金额超过可退款金额：%.2f", maxRefundAmount.InexactFloat64())
@@ -146,16 +160,27 @@ func (as *AfterSaleService) CreateAfterSale(userID uint, req *AfterSaleRequest)
 	}
 
 	// 如果是仅退款且订单已收货，可以直接处理
+	var refundReq *model.PaymentRefundRequest
 	if req.Type == model.AfterSaleTypeRefund && order.Status == model.OrderStatusReceived {
 		// 自动同意退款申请
-		if err := as.handleAfterSaleApproval(tx, afterSale, 0, model.OperatorTypeSystem,
+		var err error
+		if refundReq, err = as.handleAfterSaleApproval(tx, afterSale, 0, model.OperatorTypeSystem,
 			"自动同意", "仅退款申请自动处理"); err != nil {
 			tx.Rollback()
 			return nil, fmt.Errorf("处理退款申请失败: %v", err)
 		}
 	}
 
-	tx.Commit()
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("提交事务失败: %v", err)
+	}
+
+	// 售后申请已创建，渠道退款提交失败时售后单保持退款中，不影响申请结果
+	if refundReq != nil {
+		if err := as.submitRefund(refundReq); err == nil {
+			as.db.Select("status").First(afterSale, afterSale.ID)
+		}
+	}
 
 	return &AfterSaleResponse{
 		AfterSaleNo: afterSale.AfterSaleNo,
@@ -193,9 +218,11 @@ func (as *AfterSaleService) HandleAfterSale(afterSaleID uint, action string, han
 		return fmt.Errorf("售后申请状态不允许处理")
 	}
 
+	var refundReq *model.PaymentRefundRequest
 	switch action {
 	case "approve":
-		if err := as.handleAfterSaleApproval(tx, &afterSale, handleUserID, model.OperatorTypeAdmin, "同意申请", remark); err != nil {
+		var err error
+		if refundReq, err = as.handleAfterSaleApproval(tx, &afterSale, handleUserID, model.OperatorTypeAdmin, "同意申请", remark); err != nil {
 			tx.Rollback()
 			return err
 		}
@@ -209,12 +236,15 @@ func (as *AfterSaleService) HandleAfterSale(afterSaleID uint, action string, han
 		return fmt.Errorf("无效的处理动作: %s", action)
 	}
 
-	tx.Commit()
-	return nil
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("提交事务失败: %v", err)
+	}
+
+	return as.submitRefund(refundReq)
 }
 
-// handleAfterSaleApproval 处理售后申请同意
-func (as *AfterSaleService) handleAfterSaleApproval(tx *gorm.DB, afterSale *model.OrderAfterSale, handleUserID uint, operatorType, reason, remark string) error {
+// handleAfterSaleApproval 处理售后申请同意，需要渠道退款时返回待提交的退款请求
+func (as *AfterSaleService) handleAfterSaleApproval(tx *gorm.DB, afterSale *model.OrderAfterSale, handleUserID uint, operatorType, reason, remark string) (*model.PaymentRefundRequest, error) {
 	now := time.Now()
 
 	// 更新售后申请状态
@@ -226,7 +256,7 @@ func (as *AfterSaleService) handleAfterSaleApproval(tx *gorm.DB, afterSale *mode
 	}
 
 	if err := tx.Model(afterSale).Updates(updates).Error; err != nil {
-		return fmt.Errorf("更新售后申请状态失败: %v", err)
+		return nil, fmt.Errorf("更新售后申请状态失败: %v", err)
 	}
 
 	// 根据售后类型处理
@@ -234,11 +264,11 @@ func (as *AfterSaleService) handleAfterSaleApproval(tx *gorm.DB, afterSale *mode
 	case model.AfterSaleTypeRefund:
 		return as.processRefund(tx, afterSale)
 	case model.AfterSaleTypeReturn:
-		return as.processReturn(tx, afterSale)
+		return nil, as.processReturn(tx, afterSale)
 	case model.AfterSaleTypeExchange:
-		return as.processExchange(tx, afterSale)
+		return nil, as.processExchange(tx, afterSale)
 	default:
-		return fmt.Errorf("不支持的售后类型: %s", afterSale.Type)
+		return nil, fmt.Errorf("不支持的售后类型: %s", afterSale.Type)
 	}
 }
 
@@ -262,24 +292,124 @@ func (as *AfterSaleService) handleAfterSaleRejection(tx *gorm.DB, afterSale *mod
 }
 
 // processRefund 处理退款
-func (as *AfterSaleService) processRefund(tx *gorm.DB, afterSale *model.OrderAfterSale) error {
-	// 获取订单的支付记录
-	var payment model.OrderPayment
+// 礼品卡和余额部分在事务内直接退回；第三方渠道支付部分将售后单标记为退款中，
+// 返回的退款请求需在事务提交后通过 submitRefund 提交，渠道确认退款成功后由 onRefundSettled 完成售后
+func (as *AfterSaleService) processRefund(tx *gorm.DB, afterSale *model.OrderAfterSale) (*model.PaymentRefundRequest, error) {
+	// 礼品卡抵扣部分优先退回原卡，售后单号保证重复处理时不会重复退回
+	giftCardRefund, err := giftcard.RefundOrder(tx, afterSale.OrderID, afterSale.Amount, afterSale.AfterSaleNo, afterSale.Reason)
+	if err != nil {
+		return nil, fmt.Errorf("退回礼品卡失败: %v", err)
+	}
+
+	cashRefund := afterSale.Amount.Sub(giftCardRefund)
+	if !cashRefund.IsPositive() {
+		return nil, as.completeRefund(tx, afterSale, "gift_card")
+	}
+
+	// 第三方渠道支付，创建退款单等待渠道确认
+	if as.refundService != nil {
+		var channelPayment model.Payment
+		err := tx.Where("order_id = ? AND payment_status = ?", afterSale.OrderID, model.PaymentStatusSuccess).
+			Order("id DESC").First(&channelPayment).Error
+		if err == nil {
+			return as.startChannelRefund(tx, afterSale, &channelPayment, cashRefund)
+		}
+		if err != gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("查询支付记录失败: %v", err)
+		}
+	}
+
+	// 余额支付直接退回余额
+	var orderPayment model.OrderPayment
 	if err := tx.Where("order_id = ? AND status = ?", afterSale.OrderID, model.PaymentStatusPaid).
-		Order("created_at DESC").First(&payment).Error; err != nil {
-		return fmt.Errorf("未找到有效的支付记录")
+		Order("created_at DESC").First(&orderPayment).Error; err != nil {
+		return nil, fmt.Errorf("未找到有效的支付记录")
+	}
+
+	if err := as.paymentService.RefundPayment(orderPayment.PaymentNo, cashRefund, afterSale.Reason); err != nil {
+		return nil, fmt.Errorf("退款处理失败: %v", err)
+	}
+
+	return nil, as.completeRefund(tx, afterSale, orderPayment.PaymentMethod)
+}
+
+// startChannelRefund 将售后单标记为退款中并构建渠道退款请求
+// 售后单号作为退款单号，重复提交时支付服务返回同一退款单，不会重复退款
+func (as *AfterSaleService) startChannelRefund(tx *gorm.DB, afterSale *model.OrderAfterSale, channelPayment *model.Payment, cashRefund decimal.Decimal) (*model.PaymentRefundRequest, error) {
+	if err := tx.Model(afterSale).Updates(map[string]interface{}{
+		"status":        model.AfterSaleStatusRefunding,
+		"refund_method": string(channelPayment.PaymentMethod),
+	}).Error; err != nil {
+		return nil, fmt.Errorf("更新售后申请失败: %v", err)
+	}
+
+	if err := tx.Model(&model.Order{}).Where("id = ?", afterSale.OrderID).
+		Update("refund_status", model.RefundStatusPending).Error; err != nil {
+		return nil, fmt.Errorf("更新订单退款信息失败: %v", err)
+	}
+
+	// 外币支付按支付时锁定的汇率退回支付币种金额
+	refundAmount := cashRefund
+	if channelPayment.Currency != "" && channelPayment.Currency != model.BaseCurrency {
+		refundAmount = currency.Round(cashRefund.Mul(channelPayment.ExchangeRate), channelPayment.Currency)
+	}
+
+	return &model.PaymentRefundRequest{
+		PaymentID:    channelPayment.ID,
+		RefundAmount: refundAmount,
+		RefundReason: afterSale.Reason,
+		RefundNo:     afterSale.AfterSaleNo,
+	}, nil
+}
+
+// submitRefund 提交渠道退款，渠道同步返回成功时售后单在支付服务的退款回调中完成
+// 提交失败时售后单保持退款中，退款单处理中时由退款通知或主动轮询确认结果
+func (as *AfterSaleService) submitRefund(req *model.PaymentRefundRequest) error {
+	if req == nil {
+		return nil
 	}
 
-	// 调用退款接口
-	if err := as.paymentService.RefundPayment(payment.PaymentNo, afterSale.Amount, afterSale.Reason); err != nil {
+	if _, err := as.refundService.RefundPayment(req); err != nil {
+		logger.Error("提交售后渠道退款失败", zap.String("after_sale_no", req.RefundNo), zap.Error(err))
 		return fmt.Errorf("退款处理失败: %v", err)
 	}
+	return nil
+}
+
+// onRefundSettled 渠道退款单进入终态时完成或标记售后单，退款单号即售后单号
+func (as *AfterSaleService) onRefundSettled(tx *gorm.DB, refund *model.PaymentRefund) error {
+	var afterSale model.OrderAfterSale
+	err := tx.Where("after_sale_no = ? AND status = ?", refund.RefundNo, model.AfterSaleStatusRefunding).
+		First(&afterSale).Error
+	if err == gorm.ErrRecordNotFound {
+		// 非售后发起的退款
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("查询售后申请失败: %v", err)
+	}
 
+	if refund.RefundStatus == model.PaymentStatusSuccess {
+		return as.completeRefund(tx, &afterSale, afterSale.RefundMethod)
+	}
+
+	if err := tx.Model(&afterSale).Update("status", model.AfterSaleStatusRefundFailed).Error; err != nil {
+		return fmt.Errorf("更新售后申请失败: %v", err)
+	}
+	if err := tx.Model(&model.Order{}).Where("id = ?", afterSale.OrderID).
+		Update("refund_status", model.RefundStatusFailed).Error; err != nil {
+		return fmt.Errorf("更新订单退款信息失败: %v", err)
+	}
+	return nil
+}
+
+// completeRefund 退款完成后更新售后单、订单商品项和订单退款信息
+func (as *AfterSaleService) completeRefund(tx *gorm.DB, afterSale *model.OrderAfterSale, refundMethod string) error {
 	// 更新售后申请状态
 	now := time.Now()
 	updates := map[string]interface{}{
 		"status":        model.AfterSaleStatusCompleted,
-		"refund_method": payment.PaymentMethod,
+		"refund_method": refundMethod,
 		"refund_amount": afterSale.Amount,
 		"refund_time":   &now,
 	}
@@ -315,7 +445,7 @@ func (as *AfterSaleService) processRefund(tx *gorm.DB, afterSale *model.OrderAft
 	}
 
 	// 如果全额退款，更新订单状态为已退款
-	if order.RefundAmount.GreaterThanOrEqual(order.PayableAmount) {
+	if order.RefundAmount.GreaterThanOrEqual(order.ChargedAmount()) {
 		as.statusService.UpdateOrderStatus(afterSale.OrderID, model.OrderStatusRefunded,
 			0, model.OperatorTypeSystem, "全额退款", "售后退款完成")
 	}
@@ -371,7 +501,8 @@ func (as *AfterSaleService) ConfirmReturn(afterSaleID uint, handleUserID uint, r
 	}
 
 	// 处理退款
-	if err := as.processRefund(tx, &afterSale); err != nil {
+	refundReq, err := as.processRefund(tx, &afterSale)
+	if err != nil {
 		tx.Rollback()
 		return fmt.Errorf("处理退款失败: %v", err)
 	}
@@ -392,8 +523,11 @@ func (as *AfterSaleService) ConfirmReturn(afterSaleID uint, handleUserID uint, r
 		}
 	}
 
-	tx.Commit()
-	return nil
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("提交事务失败: %v", err)
+	}
+
+	return as.submitRefund(refundReq)
 }
 
 // GetAfterSaleList 获取售后申请列表
@@ -6,9 +6,16 @@ import (
 
 	"mall-go/internal/model"
 	"mall-go/pkg/cart"
+	"mall-go/pkg/currency"
+	"mall-go/pkg/giftcard"
 	"mall-go/pkg/inventory"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/member"
+	"mall-go/pkg/pricelist"
+	"mall-go/pkg/promotion"
 
 	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -18,6 +25,11 @@ type OrderService struct {
 	cartService        *cart.CartService
 	calculationService *cart.CalculationService
 	inventoryService   *inventory.InventoryService
+	currencyService    *currency.Service  // 外币下单时锁定汇率，未设置时仅支持结算币种
+	giftCardService    *giftcard.Service  // 礼品卡抵扣，未设置时下单不可使用礼品卡
+	promotionService   *promotion.Service // 促销计算，未设置时下单不参与促销
+	memberService      *member.Service    // 会员权益，未设置时下单不享受会员折扣和包邮门槛
+	priceListService   *pricelist.Service // 客户分组价，未设置时按公开售价下单
 }
 
 // NewOrderService 创建订单服务
@@ -30,9 +42,103 @@ func NewOrderService(db *gorm.DB, cartService *cart.CartService, calculationServ
 	}
 }
 
+// SetCurrencyService 设置币种服务
+func (os *OrderService) SetCurrencyService(currencyService *currency.Service) {
+	os.currencyService = currencyService
+}
+
+// SetGiftCardService 设置礼品卡服务
+func (os *OrderService) SetGiftCardService(giftCardService *giftcard.Service) {
+	os.giftCardService = giftCardService
+}
+
+// SetPromotionService 设置促销服务
+func (os *OrderService) SetPromotionService(promotionService *promotion.Service) {
+	os.promotionService = promotionService
+}
+
+// SetMemberService 设置会员服务
+func (os *OrderService) SetMemberService(memberService *member.Service) {
+	os.memberService = memberService
+}
+
+// SetPriceListService 设置客户分组价服务
+func (os *OrderService) SetPriceListService(priceListService *pricelist.Service) {
+	os.priceListService = priceListService
+}
+
 // CreateOrder 创建订单 - 优化版本，先扣库存后创建订单，避免双重事务嵌套
 func (os *OrderService) CreateOrder(userID uint, req *model.OrderCreateRequest) (*model.Order, error) {
 	// 第一步：验证购物车和获取商品项（轻量级查询）
+	cartItems, err := os.loadCartItems(userID, req.CartItemIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 第二步：先扣减库存（独立事务，避免长时间锁定）
+	if err := os.deductStockWithInventoryService(cartItems); err != nil {
+		return nil, fmt.Errorf("扣减库存失败: %v", err)
+	}
+
+	// 第三步：创建订单（短事务）
+	var order *model.Order
+	err = os.db.Transaction(func(tx *gorm.DB) error {
+		// 再次验证购物车商品项（防止并发修改）
+		if err := os.validateCartItemsForOrder(tx, cartItems); err != nil {
+			return err
+		}
+
+		// 创建订单对象
+		var createErr error
+		order, createErr = os.createOrderWithItems(tx, userID, req, cartItems, orderOptions{orderType: model.OrderTypeNormal})
+		if createErr != nil {
+			return createErr
+		}
+
+		// 清理购物车商品项
+		if err := tx.Where("id IN ?", req.CartItemIDs).Delete(&model.CartItem{}).Error; err != nil {
+			return fmt.Errorf("清理购物车失败: %v", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		// 如果订单创建失败，需要回滚库存
+		os.rollbackStock(cartItems)
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// PreviewOrder 下单预览，按当前价格和促销规则计算订单金额，不扣库存、不创建订单
+func (os *OrderService) PreviewOrder(userID uint, req *model.OrderPreviewRequest) (*OrderCalculation, error) {
+	cartItems, err := os.loadCartItems(userID, req.CartItemIDs)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.validateCartItemsForOrder(os.db, cartItems); err != nil {
+		return nil, err
+	}
+	refreshPrices(cartItems)
+	if err := os.applyCustomerPrices(userID, cartItems); err != nil {
+		return nil, err
+	}
+
+	calculation, err := os.calculateOrderAmount(userID, cartItems, &model.OrderCreateRequest{
+		CouponID:   req.CouponID,
+		PointsUsed: req.PointsUsed,
+		Province:   req.Province,
+	}, true)
+	if err != nil {
+		return nil, fmt.Errorf("计算订单金额失败: %v", err)
+	}
+	return calculation, nil
+}
+
+// loadCartItems 查询用户购物车中待下单的商品项，cartItemIDs 为空时取全部商品项
+func (os *OrderService) loadCartItems(userID uint, cartItemIDs []uint) ([]model.CartItem, error) {
 	var userCart model.Cart
 	if err := os.db.Where("user_id = ? AND status = ?", userID, model.CartStatusActive).First(&userCart).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -48,8 +154,8 @@ func (os *OrderService) CreateOrder(userID uint, req *model.OrderCreateRequest)
 		Preload("SKU")
 
 	// 如果指定了特定的购物车商品项ID，则只查询这些项
-	if len(req.CartItemIDs) > 0 {
-		query = query.Where("id IN ?", req.CartItemIDs)
+	if len(cartItemIDs) > 0 {
+		query = query.Where("id IN ?", cartItemIDs)
 	}
 
 	if err := query.Find(&cartItems).Error; err != nil {
@@ -57,42 +163,82 @@ func (os *OrderService) CreateOrder(userID uint, req *model.OrderCreateRequest)
 	}
 
 	if len(cartItems) == 0 {
-		if len(req.CartItemIDs) > 0 {
-			return nil, fmt.Errorf("指定的购物车商品项不存在，请检查商品项ID: %v", req.CartItemIDs)
+		if len(cartItemIDs) > 0 {
+			return nil, fmt.Errorf("指定的购物车商品项不存在，请检查商品项ID: %v", cartItemIDs)
 		}
 		return nil, fmt.Errorf("购物车为空，请先添加商品到购物车")
 	}
+	return cartItems, nil
+}
 
-	// 第二步：先扣减库存（独立事务，避免长时间锁定）
+// SubscriptionOrderItem 订阅周期订单商品
+type SubscriptionOrderItem struct {
+	ProductID uint
+	SKUID     uint
+	Quantity  int
+}
+
+// CreateSubscriptionOrder 按订阅计划生成周期订单，不经过购物车
+// discountRate 为订阅折扣率；订单不设支付超时，扣款失败后由订阅催缴流程取消。
+// 商品下架或库存不足时返回 model.ErrOrderItemUnavailable
+func (os *OrderService) CreateSubscriptionOrder(userID uint, orderNo string, req *model.OrderCreateRequest, item SubscriptionOrderItem, discountRate decimal.Decimal) (*model.Order, error) {
+	cartItems, err := os.directCartItems([]DirectOrderItem{{
+		ProductID: item.ProductID,
+		SKUID:     item.SKUID,
+		Quantity:  item.Quantity,
+	}})
+	if err != nil {
+		return nil, err
+	}
 	if err := os.deductStockWithInventoryService(cartItems); err != nil {
-		return nil, fmt.Errorf("扣减库存失败: %v", err)
+		return nil, fmt.Errorf("%w: %v", model.ErrOrderItemUnavailable, err)
 	}
 
-	// 第三步：创建订单（短事务）
 	var order *model.Order
-	err := os.db.Transaction(func(tx *gorm.DB) error {
-		// 再次验证购物车商品项（防止并发修改）
-		if err := os.validateCartItemsForOrder(tx, cartItems); err != nil {
-			return err
-		}
-
-		// 创建订单对象
+	err = os.db.Transaction(func(tx *gorm.DB) error {
 		var createErr error
-		order, createErr = os.createOrderWithItems(tx, userID, req, cartItems)
-		if createErr != nil {
-			return createErr
-		}
+		order, createErr = os.createOrderWithItems(tx, userID, req, cartItems, orderOptions{
+			orderType:    model.OrderTypeSubscription,
+			orderNo:      orderNo,
+			discountRate: discountRate,
+			noPayExpire:  true,
+			noPromotion:  true,
+		})
+		return createErr
+	})
+	if err != nil {
+		os.rollbackStock(cartItems)
+		return nil, err
+	}
 
-		// 清理购物车商品项
-		if err := tx.Where("id IN ?", req.CartItemIDs).Delete(&model.CartItem{}).Error; err != nil {
-			return fmt.Errorf("清理购物车失败: %v", err)
-		}
+	return order, nil
+}
 
-		return nil
-	})
+// DirectOrderItem 不经过购物车直接下单的商品
+type DirectOrderItem struct {
+	ProductID uint
+	SKUID     uint
+	Quantity  int
+}
 
+// CreateDirectOrder 按指定商品直接下单，不读取也不清理购物车，如分享购物车直接结算
+// 订单按当前价格参与促销和会员折扣；商品下架或库存不足时返回 model.ErrOrderItemUnavailable
+func (os *OrderService) CreateDirectOrder(userID uint, req *model.OrderCreateRequest, items []DirectOrderItem) (*model.Order, error) {
+	cartItems, err := os.directCartItems(items)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.deductStockWithInventoryService(cartItems); err != nil {
+		return nil, fmt.Errorf("%w: %v", model.ErrOrderItemUnavailable, err)
+	}
+
+	var order *model.Order
+	err = os.db.Transaction(func(tx *gorm.DB) error {
+		var createErr error
+		order, createErr = os.createOrderWithItems(tx, userID, req, cartItems, orderOptions{orderType: model.OrderTypeNormal})
+		return createErr
+	})
 	if err != nil {
-		// 如果订单创建失败，需要回滚库存
 		os.rollbackStock(cartItems)
 		return nil, err
 	}
@@ -100,6 +246,42 @@ func (os *OrderService) CreateOrder(userID uint, req *model.OrderCreateRequest)
 	return order, nil
 }
 
+// directCartItems 将直接下单商品组装为带商品信息的购物车商品项并校验可售
+func (os *OrderService) directCartItems(items []DirectOrderItem) ([]model.CartItem, error) {
+	cartItems := make([]model.CartItem, 0, len(items))
+	for _, item := range items {
+		cartItem := model.CartItem{
+			ProductID: item.ProductID,
+			SKUID:     item.SKUID,
+			Quantity:  item.Quantity,
+		}
+		var product model.Product
+		if err := os.db.First(&product, item.ProductID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, fmt.Errorf("%w: 商品ID %d 不存在", model.ErrOrderItemUnavailable, item.ProductID)
+			}
+			return nil, fmt.Errorf("查询商品失败: %v", err)
+		}
+		cartItem.Product = &product
+		if item.SKUID > 0 {
+			var sku model.ProductSKU
+			if err := os.db.First(&sku, item.SKUID).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return nil, fmt.Errorf("%w: 商品规格ID %d 不存在", model.ErrOrderItemUnavailable, item.SKUID)
+				}
+				return nil, fmt.Errorf("查询商品规格失败: %v", err)
+			}
+			cartItem.SKU = &sku
+		}
+		cartItems = append(cartItems, cartItem)
+	}
+
+	if err := os.validateCartItemsForOrder(os.db, cartItems); err != nil {
+		return nil, fmt.Errorf("%w: %v", model.ErrOrderItemUnavailable, err)
+	}
+	return cartItems, nil
+}
+
 // rollbackStock 回滚库存（订单创建失败时使用）
 func (os *OrderService) rollbackStock(cartItems []model.CartItem) {
 	var requests []inventory.StockDeductionRequest
@@ -146,29 +328,70 @@ func (os *OrderService) validateCartItemsForOrder(tx *gorm.DB, cartItems []model
 	return nil
 }
 
+// orderOptions 下单选项，区分普通订单与订阅等特殊订单
+type orderOptions struct {
+	orderType    string          // 订单类型
+	orderNo      string          // 订单号，为空时自动生成
+	discountRate decimal.Decimal // 额外折扣率，如订阅折扣
+	noPayExpire  bool            // 不设支付超时
+	noPromotion  bool            // 不参与促销和会员折扣
+}
+
 // createOrderWithItems 创建订单和订单商品项
-func (os *OrderService) createOrderWithItems(tx *gorm.DB, userID uint, req *model.OrderCreateRequest, cartItems []model.CartItem) (*model.Order, error) {
-	// 更新商品价格为当前价格
-	for i := range cartItems {
-		currentPrice := cartItems[i].Product.Price
-		if cartItems[i].SKUID > 0 && cartItems[i].SKU != nil {
-			currentPrice = cartItems[i].SKU.Price
-		}
-		cartItems[i].Price = currentPrice
+func (os *OrderService) createOrderWithItems(tx *gorm.DB, userID uint, req *model.OrderCreateRequest, cartItems []model.CartItem, options orderOptions) (*model.Order, error) {
+	// 更新商品价格为当前价格，分组客户按价目表定价
+	refreshPrices(cartItems)
+	if err := os.applyCustomerPrices(userID, cartItems); err != nil {
+		return nil, err
 	}
 
 	// 计算订单金额
-	calculation, err := os.calculateOrderAmount(cartItems, req)
+	calculation, err := os.calculateOrderAmount(userID, cartItems, req, !options.noPromotion)
 	if err != nil {
 		return nil, fmt.Errorf("计算订单金额失败: %v", err)
 	}
 
+	// 订阅等额外折扣按商品总金额计算
+	if options.discountRate.IsPositive() {
+		discount := calculation.TotalAmount.Mul(options.discountRate).Round(2)
+		calculation.DiscountAmount = calculation.DiscountAmount.Add(discount)
+		calculation.PayableAmount = calculation.PayableAmount.Sub(discount)
+		if calculation.PayableAmount.LessThan(decimal.Zero) {
+			calculation.PayableAmount = decimal.Zero
+		}
+	}
+
+	// 礼品卡作为支付来源，在优惠券、积分之后抵扣应付金额
+	var giftCards []model.GiftCard
+	giftCardAmount := decimal.Zero
+	if len(req.GiftCardIDs) > 0 || len(req.GiftCardCodes) > 0 {
+		if os.giftCardService == nil {
+			return nil, model.ErrGiftCardUnavailable
+		}
+		cards, available, err := os.giftCardService.Resolve(tx, userID, req.GiftCardIDs, req.GiftCardCodes)
+		if err != nil {
+			return nil, err
+		}
+		giftCards = cards
+		giftCardAmount = decimal.Min(available, calculation.PayableAmount)
+		calculation.PayableAmount = calculation.PayableAmount.Sub(giftCardAmount)
+	}
+
+	orderNo := options.orderNo
+	if orderNo == "" {
+		orderNo = os.generateOrderNo(userID)
+	}
+	payExpireTime := os.getPayExpireTime()
+	if options.noPayExpire {
+		payExpireTime = nil
+	}
+
 	// 创建订单
 	order := &model.Order{
-		OrderNo:         os.generateOrderNo(userID),
+		OrderNo:         orderNo,
 		UserID:          userID,
 		Status:          model.OrderStatusPending,
-		OrderType:       model.OrderTypeNormal,
+		OrderType:       options.orderType,
 		TotalAmount:     calculation.TotalAmount,
 		PayableAmount:   calculation.PayableAmount,
 		DiscountAmount:  calculation.DiscountAmount,
@@ -178,6 +401,9 @@ func (os *OrderService) createOrderWithItems(tx *gorm.DB, userID uint, req *mode
 		CouponAmount:    calculation.CouponAmount,
 		PointsUsed:      req.PointsUsed,
 		PointsAmount:    calculation.PointsAmount,
+		PromotionAmount: calculation.PromotionAmount,
+		MemberAmount:    calculation.MemberAmount,
+		GiftCardAmount:  giftCardAmount,
 		ReceiverName:    req.ReceiverName,
 		ReceiverPhone:   req.ReceiverPhone,
 		ReceiverAddress: req.ReceiverAddress,
@@ -188,27 +414,36 @@ func (os *OrderService) createOrderWithItems(tx *gorm.DB, userID uint, req *mode
 		ShippingMethod:  req.ShippingMethod,
 		BuyerMessage:    req.BuyerMessage,
 		OrderTime:       time.Now(),
-		PayExpireTime:   os.getPayExpireTime(),
+		PayExpireTime:   payExpireTime,
 		RefundStatus:    model.RefundStatusNone,
 	}
 
+	// 锁定汇率，记录展示币种金额
+	if err := os.applyCurrency(order, req.Currency); err != nil {
+		return nil, err
+	}
+
 	if err := tx.Create(order).Error; err != nil {
 		return nil, fmt.Errorf("创建订单失败: %v", err)
 	}
 
 	// 创建订单商品项
 	var orderItems []model.OrderItem
-	for _, cartItem := range cartItems {
+	for i, cartItem := range cartItems {
 		orderItem := model.OrderItem{
-			OrderID:      order.ID,
-			ProductID:    cartItem.ProductID,
-			SKUID:        cartItem.SKUID,
-			Quantity:     cartItem.Quantity,
-			ProductName:  cartItem.Product.Name,
-			ProductImage: cartItem.Product.GetMainImage(),
-			Price:        cartItem.Price,
-			TotalPrice:   cartItem.Price.Mul(decimal.NewFromInt(int64(cartItem.Quantity))),
-			RefundStatus: model.RefundStatusNone,
+			OrderID:        order.ID,
+			ProductID:      cartItem.ProductID,
+			SKUID:          cartItem.SKUID,
+			Quantity:       cartItem.Quantity,
+			ProductName:    cartItem.Product.Name,
+			ProductImage:   cartItem.Product.GetMainImage(),
+			Price:          cartItem.Price,
+			TotalPrice:     cartItem.Price.Mul(decimal.NewFromInt(int64(cartItem.Quantity))),
+			DiscountAmount: decimal.Zero,
+			RefundStatus:   model.RefundStatusNone,
+		}
+		if calculation.Promotion != nil {
+			orderItem.DiscountAmount = calculation.Promotion.Lines[i].Discount
 		}
 
 		// 如果有SKU，填充SKU信息
@@ -221,6 +456,10 @@ func (os *OrderService) createOrderWithItems(tx *gorm.DB, userID uint, req *mode
 		orderItems = append(orderItems, orderItem)
 	}
 
+	if calculation.Promotion != nil {
+		orderItems = append(orderItems, os.giftItems(tx, order.ID, calculation.Promotion.Gifts)...)
+	}
+
 	if err := tx.Create(&orderItems).Error; err != nil {
 		return nil, fmt.Errorf("创建订单商品失败: %v", err)
 	}
@@ -239,18 +478,158 @@ func (os *OrderService) createOrderWithItems(tx *gorm.DB, userID uint, req *mode
 		return nil, fmt.Errorf("记录订单日志失败: %v", err)
 	}
 
+	if giftCardAmount.IsPositive() {
+		if err := os.giftCardService.Redeem(tx, userID, order.ID, giftCards, giftCardAmount); err != nil {
+			return nil, err
+		}
+		// 礼品卡全额抵扣时订单直接置为已支付
+		if !order.PayableAmount.IsPositive() {
+			if err := os.markPaidByGiftCard(tx, order); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	return order, nil
 }
 
-// calculateOrderAmount 计算订单金额
-func (os *OrderService) calculateOrderAmount(cartItems []model.CartItem, req *model.OrderCreateRequest) (*OrderCalculation, error) {
+// giftItems 生成满赠赠品的订单商品项并扣减赠品库存，赠品下架或库存不足时不再赠送
+func (os *OrderService) giftItems(tx *gorm.DB, orderID uint, gifts []promotion.Gift) []model.OrderItem {
+	var items []model.OrderItem
+	for _, gift := range gifts {
+		if gift.Quantity <= 0 {
+			continue
+		}
+		var product model.Product
+		if err := tx.Where("id = ? AND status = ?", gift.ProductID, model.ProductStatusActive).First(&product).Error; err != nil {
+			logger.Warn("赠品不可用", zap.Uint("promotion_id", gift.PromotionID), zap.Uint("product_id", gift.ProductID), zap.Error(err))
+			continue
+		}
+		item := model.OrderItem{
+			OrderID:        orderID,
+			ProductID:      gift.ProductID,
+			SKUID:          gift.SKUID,
+			Quantity:       gift.Quantity,
+			ProductName:    product.Name,
+			ProductImage:   product.GetMainImage(),
+			Price:          decimal.Zero,
+			TotalPrice:     decimal.Zero,
+			DiscountAmount: decimal.Zero,
+			IsGift:         true,
+			RefundStatus:   model.RefundStatusNone,
+		}
+		if gift.SKUID > 0 {
+			var sku model.ProductSKU
+			if err := tx.Where("id = ? AND product_id = ? AND status = ?", gift.SKUID, gift.ProductID, model.SKUStatusActive).First(&sku).Error; err != nil {
+				logger.Warn("赠品规格不可用", zap.Uint("promotion_id", gift.PromotionID), zap.Uint("sku_id", gift.SKUID), zap.Error(err))
+				continue
+			}
+			item.SKUName = sku.Name
+			item.SKUImage = sku.Image
+			item.SKUAttrs = sku.Attributes
+		}
+		if err := os.deductStock(tx, []model.CartItem{{ProductID: gift.ProductID, SKUID: gift.SKUID, Quantity: gift.Quantity}}); err != nil {
+			logger.Warn("赠品库存不足", zap.Uint("promotion_id", gift.PromotionID), zap.Uint("product_id", gift.ProductID), zap.Error(err))
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// refreshPrices 将商品项价格更新为当前价格
+func refreshPrices(cartItems []model.CartItem) {
+	for i := range cartItems {
+		currentPrice := cartItems[i].Product.Price
+		if cartItems[i].SKUID > 0 && cartItems[i].SKU != nil {
+			currentPrice = cartItems[i].SKU.Price
+		}
+		cartItems[i].Price = currentPrice
+	}
+}
+
+// applyCustomerPrices 按用户所属分组的价目表更新商品项价格，需在 refreshPrices 之后调用
+func (os *OrderService) applyCustomerPrices(userID uint, cartItems []model.CartItem) error {
+	if os.priceListService == nil {
+		return nil
+	}
+	if _, err := os.priceListService.ApplyToCartItems(userID, cartItems); err != nil {
+		return fmt.Errorf("计算客户分组价失败: %v", err)
+	}
+	return nil
+}
+
+// markPaidByGiftCard 礼品卡全额抵扣的订单置为已支付
+func (os *OrderService) markPaidByGiftCard(tx *gorm.DB, order *model.Order) error {
+	now := time.Now()
+	if err := tx.Model(order).Updates(map[string]interface{}{
+		"status":         model.OrderStatusPaid,
+		"payment_status": model.PaymentStatusPaid,
+		"payment_type":   "gift_card",
+		"pay_time":       now,
+	}).Error; err != nil {
+		return fmt.Errorf("更新订单支付状态失败: %v", err)
+	}
+
+	statusLog := &model.OrderStatusLog{
+		OrderID:      order.ID,
+		FromStatus:   model.OrderStatusPending,
+		ToStatus:     model.OrderStatusPaid,
+		OperatorID:   order.UserID,
+		OperatorType: model.OperatorTypeSystem,
+		Reason:       "礼品卡全额支付",
+	}
+	if err := tx.Create(statusLog).Error; err != nil {
+		return fmt.Errorf("记录订单日志失败: %v", err)
+	}
+	return nil
+}
+
+// applyCurrency 按下单时生效的汇率换算展示币种金额并锁定汇率
+func (os *OrderService) applyCurrency(order *model.Order, code string) error {
+	code = currency.Normalize(code)
+
+	order.SettlementCurrency = model.BaseCurrency
+	order.Currency = code
+	order.ExchangeRate = decimal.NewFromInt(1)
+	order.PresentmentTotalAmount = order.TotalAmount
+	order.PresentmentPayableAmount = order.PayableAmount
+
+	if code == model.BaseCurrency {
+		return nil
+	}
+	if os.currencyService == nil {
+		return model.ErrCurrencyNotSupported
+	}
+
+	now := time.Now()
+	total, err := os.currencyService.Convert(order.TotalAmount, code, now)
+	if err != nil {
+		return fmt.Errorf("换算订单金额失败: %v", err)
+	}
+	payable, err := os.currencyService.Convert(order.PayableAmount, code, now)
+	if err != nil {
+		return fmt.Errorf("换算订单金额失败: %v", err)
+	}
+
+	order.ExchangeRate = total.Rate
+	order.ExchangeRateID = total.ExchangeRateID
+	order.PresentmentTotalAmount = total.Amount
+	order.PresentmentPayableAmount = payable.Amount
+	return nil
+}
+
+// calculateOrderAmount 计算订单金额，withPromotion 为 true 时先按促销规则和用户会员等级计算商品优惠
+func (os *OrderService) calculateOrderAmount(userID uint, cartItems []model.CartItem, req *model.OrderCreateRequest, withPromotion bool) (*OrderCalculation, error) {
 	calculation := &OrderCalculation{
-		TotalAmount:    decimal.Zero,
-		DiscountAmount: decimal.Zero,
-		ShippingFee:    decimal.Zero,
-		TaxAmount:      decimal.Zero,
-		CouponAmount:   decimal.Zero,
-		PointsAmount:   decimal.Zero,
+		TotalAmount:     decimal.Zero,
+		DiscountAmount:  decimal.Zero,
+		ShippingFee:     decimal.Zero,
+		TaxAmount:       decimal.Zero,
+		CouponAmount:    decimal.Zero,
+		PointsAmount:    decimal.Zero,
+		PromotionAmount: decimal.Zero,
+		MemberAmount:    decimal.Zero,
 	}
 
 	// 计算商品总金额
@@ -259,9 +638,49 @@ func (os *OrderService) calculateOrderAmount(cartItems []model.CartItem, req *mo
 		calculation.TotalAmount = calculation.TotalAmount.Add(itemTotal)
 	}
 
-	// 计算优惠券折扣
+	// 计算促销优惠
+	if withPromotion && os.promotionService != nil {
+		lines := make([]promotion.Line, 0, len(cartItems))
+		for _, item := range cartItems {
+			lines = append(lines, promotion.Line{
+				ProductID: item.ProductID,
+				SKUID:     item.SKUID,
+				Price:     item.Price,
+				Quantity:  item.Quantity,
+			})
+		}
+		result, err := os.promotionService.Evaluate(lines)
+		if err != nil {
+			return nil, err
+		}
+		calculation.Promotion = result
+		calculation.PromotionAmount = result.Discount
+		calculation.DiscountAmount = calculation.DiscountAmount.Add(result.Discount)
+	}
+
+	// 计算会员折扣，在促销后的金额上按会员等级优惠
+	var level *model.MemberLevel
+	if os.memberService != nil {
+		var err error
+		if level, err = os.memberService.Level(userID); err != nil {
+			return nil, err
+		}
+		if withPromotion && level != nil && level.DiscountRate.IsPositive() {
+			memberAmount := calculation.TotalAmount.Sub(calculation.PromotionAmount).Mul(level.DiscountRate).Round(2)
+			calculation.MemberLevel = level
+			calculation.MemberAmount = memberAmount
+			calculation.DiscountAmount = calculation.DiscountAmount.Add(memberAmount)
+		}
+	}
+
+	// 计算优惠券折扣，门槛按促销后的金额判断，等级专享券仅对应等级可用
 	if req.CouponID > 0 {
-		couponAmount, err := os.applyCoupon(req.CouponID, calculation.TotalAmount)
+		if os.memberService != nil {
+			if err := os.memberService.CouponAllowed(userID, req.CouponID); err != nil {
+				return nil, err
+			}
+		}
+		couponAmount, err := os.applyCoupon(req.CouponID, calculation.TotalAmount.Sub(calculation.PromotionAmount))
 		if err != nil {
 			return nil, fmt.Errorf("应用优惠券失败: %v", err)
 		}
@@ -277,7 +696,7 @@ func (os *OrderService) calculateOrderAmount(cartItems []model.CartItem, req *mo
 	}
 
 	// 计算运费
-	calculation.ShippingFee = os.calculateShippingFee(calculation.TotalAmount, req.Province)
+	calculation.ShippingFee = os.calculateShippingFee(calculation.TotalAmount, req.Province, level)
 
 	// 计算应付金额
 	calculation.PayableAmount = calculation.TotalAmount.
@@ -409,10 +828,13 @@ func (os *OrderService) calculatePointsAmount(points int) decimal.Decimal {
 	return decimal.NewFromInt(int64(points)).Div(decimal.NewFromInt(100))
 }
 
-// calculateShippingFee 计算运费
-func (os *OrderService) calculateShippingFee(totalAmount decimal.Decimal, province string) decimal.Decimal {
-	// 满99元包邮
+// calculateShippingFee 计算运费，包邮门槛按会员等级确定
+func (os *OrderService) calculateShippingFee(totalAmount decimal.Decimal, province string, level *model.MemberLevel) decimal.Decimal {
+	// 默认满99元包邮
 	freeShippingThreshold := decimal.NewFromFloat(99.0)
+	if level != nil {
+		freeShippingThreshold = level.FreeShippingThreshold
+	}
 	if totalAmount.GreaterThanOrEqual(freeShippingThreshold) {
 		return decimal.Zero
 	}
@@ -451,6 +873,14 @@ type OrderCalculation struct {
 	CouponAmount   decimal.Decimal `json:"coupon_amount"`
 	PointsAmount   decimal.Decimal `json:"points_amount"`
 	PayableAmount  decimal.Decimal `json:"payable_amount"`
+
+	// 促销优惠及各商品命中的促销明细
+	PromotionAmount decimal.Decimal   `json:"promotion_amount"`
+	Promotion       *promotion.Result `json:"promotion,omitempty"`
+
+	// 会员折扣及计算所用的会员等级
+	MemberAmount decimal.Decimal    `json:"member_amount"`
+	MemberLevel  *model.MemberLevel `json:"member_level,omitempty"`
 }
 
 // 全局订单服务实例
@@ -252,6 +252,9 @@ func (osm *OrderStatusManager) executePreAction(tx *gorm.DB, order *model.Order,
 		now := time.Now()
 		order.PayTime = &now
 	case model.OrderStatusShipped:
+		if err := checkShipmentAllowed(tx, order.ID); err != nil {
+			return err
+		}
 		now := time.Now()
 		order.ShipTime = &now
 		order.ShippingStatus = model.ShippingStatusShipped
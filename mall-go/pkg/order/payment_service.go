@@ -396,6 +396,7 @@ func (ps *PaymentService) generateNonceStr() string {
 }
 
 // RefundPayment 退款
+// 订单支付记录仅支持余额退款，第三方渠道支付的退款通过支付服务创建退款单并等待渠道确认
 func (ps *PaymentService) RefundPayment(paymentNo string, refundAmount decimal.Decimal, reason string) error {
 	// 开始事务
 	tx := ps.db.Begin()
@@ -419,10 +420,15 @@ func (ps *PaymentService) RefundPayment(paymentNo string, refundAmount decimal.D
 		return fmt.Errorf("退款金额不能大于支付金额")
 	}
 
-	// 调用第三方退款接口
-	if err := ps.callThirdPartyRefund(&payment, refundAmount, reason); err != nil {
+	if payment.PaymentMethod != model.PaymentTypeBalance {
 		tx.Rollback()
-		return fmt.Errorf("调用退款接口失败: %v", err)
+		return fmt.Errorf("不支持的退款方式: %s", payment.PaymentMethod)
+	}
+
+	// 退回余额
+	if err := ps.processBalanceRefund(&payment, refundAmount, reason); err != nil {
+		tx.Rollback()
+		return err
 	}
 
 	// 更新支付状态
@@ -445,34 +451,6 @@ func (ps *PaymentService) RefundPayment(paymentNo string, refundAmount decimal.D
 	return nil
 }
 
-// callThirdPartyRefund 调用第三方退款接口
-func (ps *PaymentService) callThirdPartyRefund(payment *model.OrderPayment, refundAmount decimal.Decimal, reason string) error {
-	switch payment.PaymentMethod {
-	case model.PaymentTypeAlipay:
-		return ps.callAlipayRefund(payment, refundAmount, reason)
-	case model.PaymentTypeWechat:
-		return ps.callWechatRefund(payment, refundAmount, reason)
-	case model.PaymentTypeBalance:
-		return ps.processBalanceRefund(payment, refundAmount, reason)
-	default:
-		return fmt.Errorf("不支持的退款方式: %s", payment.PaymentMethod)
-	}
-}
-
-// callAlipayRefund 调用支付宝退款
-func (ps *PaymentService) callAlipayRefund(payment *model.OrderPayment, refundAmount decimal.Decimal, reason string) error {
-	// 这里应该调用真实的支付宝退款接口
-	// 为了演示，直接返回成功
-	return nil
-}
-
-// callWechatRefund 调用微信退款
-func (ps *PaymentService) callWechatRefund(payment *model.OrderPayment, refundAmount decimal.Decimal, reason string) error {
-	// 这里应该调用真实的微信退款接口
-	// 为了演示，直接返回成功
-	return nil
-}
-
 // processBalanceRefund 处理余额退款
 func (ps *PaymentService) processBalanceRefund(payment *model.OrderPayment, refundAmount decimal.Decimal, reason string) error {
 	// 退款到用户余额
@@ -87,6 +87,12 @@ func (ss *ShippingService) CreateShipment(req *ShippingRequest) (*ShippingRespon
 		return nil, fmt.Errorf("订单状态不允许发货")
 	}
 
+	// 支付争议败诉的订单禁止发货
+	if err := checkShipmentAllowed(tx, order.ID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	// 检查是否已经发货
 	var existingShipment model.OrderShipment
 	if err := tx.Where("order_id = ?", req.OrderID).First(&existingShipment).Error; err == nil {
@@ -490,6 +496,20 @@ func (ss *ShippingService) GetShippingStatistics() (map[string]interface{}, erro
 	return stats, nil
 }
 
+// checkShipmentAllowed 检查订单是否允许发货，支付争议败诉的订单返回 model.ErrOrderShipmentBlocked
+func checkShipmentAllowed(tx *gorm.DB, orderID uint) error {
+	var count int64
+	if err := tx.Model(&model.PaymentDispute{}).
+		Where("order_id = ? AND status = ?", orderID, model.PaymentDisputeLost).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("查询支付争议失败: %v", err)
+	}
+	if count > 0 {
+		return model.ErrOrderShipmentBlocked
+	}
+	return nil
+}
+
 // generateShipmentNo 生成发货单号
 func (ss *ShippingService) generateShipmentNo() string {
 	return fmt.Sprintf("SHIP%d", time.Now().UnixNano())
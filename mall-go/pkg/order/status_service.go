@@ -5,13 +5,16 @@ import (
 	"time"
 
 	"mall-go/internal/model"
+	"mall-go/pkg/giftcard"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 // StatusService 订单状态管理服务
 type StatusService struct {
-	db *gorm.DB
+	db             *gorm.DB
+	completedHooks []func(*gorm.DB, *model.Order) error // 订单完成时在状态事务内执行
 }
 
 // NewStatusService 创建订单状态管理服务
@@ -21,6 +24,11 @@ func NewStatusService(db *gorm.DB) *StatusService {
 	}
 }
 
+// OnCompleted 注册订单完成回调，如累积会员成长值；回调返回错误时状态变更回滚
+func (ss *StatusService) OnCompleted(hook func(*gorm.DB, *model.Order) error) {
+	ss.completedHooks = append(ss.completedHooks, hook)
+}
+
 // StatusTransition 状态流转规则
 type StatusTransition struct {
 	From      string
@@ -53,6 +61,9 @@ func (ss *StatusService) getStatusTransitions() []StatusTransition {
 				return true // 管理员手动发货
 			},
 			Action: func(tx *gorm.DB, order *model.Order) error {
+				if err := checkShipmentAllowed(tx, order.ID); err != nil {
+					return err
+				}
 				now := time.Now()
 				order.ShipTime = &now
 				order.ShippingStatus = model.ShippingStatusShipped
@@ -102,6 +113,11 @@ func (ss *StatusService) getStatusTransitions() []StatusTransition {
 			Action: func(tx *gorm.DB, order *model.Order) error {
 				now := time.Now()
 				order.FinishTime = &now
+				for _, hook := range ss.completedHooks {
+					if err := hook(tx, order); err != nil {
+						return err
+					}
+				}
 				return nil
 			},
 		},
@@ -115,6 +131,9 @@ func (ss *StatusService) getStatusTransitions() []StatusTransition {
 			Action: func(tx *gorm.DB, order *model.Order) error {
 				now := time.Now()
 				order.CancelTime = &now
+				if err := refundGiftCards(tx, order); err != nil {
+					return err
+				}
 				// 恢复库存
 				return ss.restoreStock(tx, order)
 			},
@@ -130,6 +149,10 @@ func (ss *StatusService) getStatusTransitions() []StatusTransition {
 				now := time.Now()
 				order.CancelTime = &now
 				order.RefundStatus = model.RefundStatusPending
+				// 礼品卡抵扣部分直接退回原卡，现金部分走退款流程
+				if err := refundGiftCards(tx, order); err != nil {
+					return err
+				}
 				// 恢复库存
 				return ss.restoreStock(tx, order)
 			},
@@ -286,6 +309,15 @@ func (ss *StatusService) isValidTransition(from, to string) bool {
 	return false
 }
 
+// refundGiftCards 订单取消时将礼品卡抵扣金额全部退回原卡
+func refundGiftCards(tx *gorm.DB, order *model.Order) error {
+	if !order.GiftCardAmount.IsPositive() {
+		return nil
+	}
+	_, err := giftcard.RefundOrder(tx, order.ID, decimal.Zero, order.OrderNo, "订单取消")
+	return err
+}
+
 // restoreStock 恢复库存
 func (ss *StatusService) restoreStock(tx *gorm.DB, order *model.Order) error {
 	for _, item := range order.OrderItems {
@@ -0,0 +1,19 @@
+// Package pagination 分页参数处理
+package pagination
+
+// DefaultPageSize 未指定或超出上限时的每页数量
+const DefaultPageSize = 20
+
+// MaxPageSize 每页数量上限
+const MaxPageSize = 100
+
+// Normalize 规范化分页参数，页码从1开始，每页数量超出范围时取默认值
+func Normalize(page, pageSize int) (int, int) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > MaxPageSize {
+		pageSize = DefaultPageSize
+	}
+	return page, pageSize
+}
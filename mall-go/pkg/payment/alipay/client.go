@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"mall-go/internal/model"
+	"mall-go/pkg/currency"
 	"mall-go/pkg/logger"
 	"mall-go/pkg/payment/config"
 
@@ -143,12 +144,18 @@ func (c *Client) buildPaymentParams(req *PaymentRequest) map[string]string {
 	// 业务参数
 	bizContent := map[string]interface{}{
 		"out_trade_no":    req.OutTradeNo,
-		"total_amount":    req.TotalAmount.String(),
+		"total_amount":    currency.Format(req.TotalAmount, req.Currency),
 		"subject":         req.Subject,
 		"store_id":        "001",
 		"timeout_express": "30m",
 	}
 
+	// 外币标价时金额以标价币种计，结算币种为人民币
+	if code := currency.Normalize(req.Currency); code != "CNY" {
+		bizContent["trans_currency"] = code
+		bizContent["settle_currency"] = "CNY"
+	}
+
 	if req.Body != "" {
 		bizContent["body"] = req.Body
 	}
@@ -298,6 +305,9 @@ func (c *Client) parseQueryResponse(data []byte) (*QueryResponse, error) {
 	}
 
 	resp := response.AlipayTradeQueryResponse
+	if resp.SubCode == SubCodeTradeNotExist {
+		return nil, model.ErrTradeNotExist
+	}
 	if resp.Code != "10000" {
 		return nil, fmt.Errorf("支付宝返回错误: %s - %s", resp.SubCode, resp.SubMsg)
 	}
@@ -368,3 +378,336 @@ func (c *Client) VerifyCallback(params map[string]string) error {
 
 	return nil
 }
+
+// Refund 申请退款
+// 同一笔退款需使用相同的 OutRequestNo，支付宝据此保证重复请求不会重复退款
+func (c *Client) Refund(req *RefundRequest) (*RefundResponse, error) {
+	logger.Info("申请支付宝退款",
+		zap.String("out_trade_no", req.OutTradeNo),
+		zap.String("out_request_no", req.OutRequestNo),
+		zap.String("refund_amount", req.RefundAmount.String()))
+
+	if req.OutRequestNo == "" {
+		return nil, fmt.Errorf("退款请求号不能为空")
+	}
+
+	params := c.buildCommonParams("alipay.trade.refund")
+
+	bizContent := map[string]interface{}{
+		"refund_amount":  currency.Format(req.RefundAmount, req.Currency),
+		"out_request_no": req.OutRequestNo,
+	}
+	if code := currency.Normalize(req.Currency); code != "CNY" {
+		bizContent["refund_currency"] = code
+	}
+	if req.OutTradeNo != "" {
+		bizContent["out_trade_no"] = req.OutTradeNo
+	}
+	if req.TradeNo != "" {
+		bizContent["trade_no"] = req.TradeNo
+	}
+	if req.RefundReason != "" {
+		bizContent["refund_reason"] = req.RefundReason
+	}
+
+	bizContentJSON, _ := json.Marshal(bizContent)
+	params["biz_content"] = string(bizContentJSON)
+
+	// 签名
+	sign, err := c.sign(params)
+	if err != nil {
+		return nil, fmt.Errorf("签名失败: %v", err)
+	}
+	params["sign"] = sign
+
+	// 发送请求
+	response, err := c.sendRequest(params)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %v", err)
+	}
+
+	// 解析响应
+	return c.parseRefundResponse(response, req.OutRequestNo)
+}
+
+// parseRefundResponse 解析退款响应
+func (c *Client) parseRefundResponse(data []byte, outRequestNo string) (*RefundResponse, error) {
+	var response struct {
+		AlipayTradeRefundResponse struct {
+			Code         string `json:"code"`
+			Msg          string `json:"msg"`
+			SubCode      string `json:"sub_code"`
+			SubMsg       string `json:"sub_msg"`
+			OutTradeNo   string `json:"out_trade_no"`
+			TradeNo      string `json:"trade_no"`
+			RefundFee    string `json:"refund_fee"`
+			FundChange   string `json:"fund_change"`
+			GmtRefundPay string `json:"gmt_refund_pay"`
+		} `json:"alipay_trade_refund_response"`
+		Sign string `json:"sign"`
+	}
+
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+
+	resp := response.AlipayTradeRefundResponse
+	if !IsSuccess(resp.Code) {
+		// 明确的业务失败不返回error，由调用方将退款单标记为失败；
+		// 系统繁忙或未知错误时退款可能已受理，保持处理中，由查询或轮询使用相同的退款请求号重试
+		status := model.PaymentStatusPending
+		if IsDefinitiveFailure(resp.Code, resp.SubCode) {
+			status = model.PaymentStatusFailed
+		}
+		return &RefundResponse{
+			OutRequestNo: outRequestNo,
+			Status:       status,
+			Success:      false,
+			Message:      GetErrorMessage(resp.Code, resp.SubCode, resp.SubMsg),
+		}, nil
+	}
+
+	refundFee, _ := decimal.NewFromString(resp.RefundFee)
+
+	// fund_change=Y 表示本次请求发生了资金变化，退款已完成；
+	// 否则退款可能仍在处理中，需要通过退款查询确认
+	status := model.PaymentStatusPending
+	if resp.FundChange == "Y" {
+		status = model.PaymentStatusSuccess
+	}
+
+	return &RefundResponse{
+		OutTradeNo:   resp.OutTradeNo,
+		TradeNo:      resp.TradeNo,
+		OutRequestNo: outRequestNo,
+		RefundFee:    refundFee,
+		GmtRefundPay: resp.GmtRefundPay,
+		FundChange:   resp.FundChange,
+		Status:       status,
+		Success:      true,
+	}, nil
+}
+
+// QueryRefund 查询退款
+func (c *Client) QueryRefund(outTradeNo, outRequestNo string) (*RefundQueryResponse, error) {
+	logger.Info("查询支付宝退款状态",
+		zap.String("out_trade_no", outTradeNo),
+		zap.String("out_request_no", outRequestNo))
+
+	params := c.buildCommonParams("alipay.trade.fastpay.refund.query")
+
+	bizContent := map[string]interface{}{
+		"out_trade_no":   outTradeNo,
+		"out_request_no": outRequestNo,
+		"query_options":  []string{"gmt_refund_pay"},
+	}
+
+	bizContentJSON, _ := json.Marshal(bizContent)
+	params["biz_content"] = string(bizContentJSON)
+
+	// 签名
+	sign, err := c.sign(params)
+	if err != nil {
+		return nil, fmt.Errorf("签名失败: %v", err)
+	}
+	params["sign"] = sign
+
+	// 发送请求
+	response, err := c.sendRequest(params)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %v", err)
+	}
+
+	// 解析响应
+	return c.parseRefundQueryResponse(response)
+}
+
+// parseRefundQueryResponse 解析退款查询响应
+func (c *Client) parseRefundQueryResponse(data []byte) (*RefundQueryResponse, error) {
+	var response struct {
+		AlipayTradeFastpayRefundQueryResponse struct {
+			Code         string `json:"code"`
+			Msg          string `json:"msg"`
+			SubCode      string `json:"sub_code"`
+			SubMsg       string `json:"sub_msg"`
+			OutTradeNo   string `json:"out_trade_no"`
+			TradeNo      string `json:"trade_no"`
+			OutRequestNo string `json:"out_request_no"`
+			RefundAmount string `json:"refund_amount"`
+			RefundStatus string `json:"refund_status"`
+			GmtRefundPay string `json:"gmt_refund_pay"`
+		} `json:"alipay_trade_fastpay_refund_query_response"`
+		Sign string `json:"sign"`
+	}
+
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+
+	resp := response.AlipayTradeFastpayRefundQueryResponse
+	if !IsSuccess(resp.Code) {
+		return nil, fmt.Errorf("支付宝返回错误: %s", GetErrorMessage(resp.Code, resp.SubCode, resp.SubMsg))
+	}
+
+	// 未返回 REFUND_SUCCESS 时退款仍在处理中
+	status := model.PaymentStatusPending
+	if resp.RefundStatus == RefundStatusSuccess {
+		status = model.PaymentStatusSuccess
+	}
+
+	refundAmount, _ := decimal.NewFromString(resp.RefundAmount)
+
+	return &RefundQueryResponse{
+		OutTradeNo:   resp.OutTradeNo,
+		TradeNo:      resp.TradeNo,
+		OutRequestNo: resp.OutRequestNo,
+		RefundAmount: refundAmount,
+		RefundStatus: resp.RefundStatus,
+		GmtRefundPay: resp.GmtRefundPay,
+		Status:       status,
+		Success:      true,
+	}, nil
+}
+
+// CloseOrder 关闭交易
+// 用于支付超时后关闭未付款交易，交易不存在（用户未扫码）视为已关闭
+func (c *Client) CloseOrder(outTradeNo string) error {
+	logger.Info("关闭支付宝交易", zap.String("out_trade_no", outTradeNo))
+
+	params := c.buildCommonParams("alipay.trade.close")
+
+	bizContentJSON, _ := json.Marshal(map[string]interface{}{
+		"out_trade_no": outTradeNo,
+	})
+	params["biz_content"] = string(bizContentJSON)
+
+	// 签名
+	sign, err := c.sign(params)
+	if err != nil {
+		return fmt.Errorf("签名失败: %v", err)
+	}
+	params["sign"] = sign
+
+	// 发送请求
+	response, err := c.sendRequest(params)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %v", err)
+	}
+
+	return c.parseCloseResponse(response)
+}
+
+// parseCloseResponse 解析关闭交易响应
+func (c *Client) parseCloseResponse(data []byte) error {
+	var response struct {
+		AlipayTradeCloseResponse struct {
+			Code    string `json:"code"`
+			Msg     string `json:"msg"`
+			SubCode string `json:"sub_code"`
+			SubMsg  string `json:"sub_msg"`
+		} `json:"alipay_trade_close_response"`
+	}
+
+	if err := json.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+
+	resp := response.AlipayTradeCloseResponse
+	if resp.Code == "10000" || resp.SubCode == SubCodeTradeNotExist {
+		return nil
+	}
+
+	return fmt.Errorf("支付宝返回错误: %s - %s", resp.SubCode, resp.SubMsg)
+}
+
+// SettleOrder 交易分账（alipay.trade.order.settle）
+// 交易需以分账冻结模式支付，同一 OutRequestNo 重复请求不会重复分账
+func (c *Client) SettleOrder(req *RoyaltyRequest) (*RoyaltyResponse, error) {
+	logger.Info("支付宝交易分账",
+		zap.String("trade_no", req.TradeNo),
+		zap.String("out_request_no", req.OutRequestNo))
+
+	if req.TradeNo == "" || len(req.Receivers) == 0 {
+		return nil, fmt.Errorf("分账交易号和收入方不能为空")
+	}
+
+	royalties := make([]map[string]interface{}, 0, len(req.Receivers))
+	for _, receiver := range req.Receivers {
+		transInType := receiver.TransInType
+		if transInType == "" {
+			transInType = "userId"
+		}
+		royalties = append(royalties, map[string]interface{}{
+			"royalty_type":  "transfer",
+			"trans_in_type": transInType,
+			"trans_in":      receiver.TransIn,
+			"amount":        receiver.Amount.StringFixed(2),
+			"desc":          receiver.Desc,
+		})
+	}
+
+	params := c.buildCommonParams("alipay.trade.order.settle")
+
+	bizContentJSON, _ := json.Marshal(map[string]interface{}{
+		"out_request_no":     req.OutRequestNo,
+		"trade_no":           req.TradeNo,
+		"royalty_parameters": royalties,
+	})
+	params["biz_content"] = string(bizContentJSON)
+
+	// 签名
+	sign, err := c.sign(params)
+	if err != nil {
+		return nil, fmt.Errorf("签名失败: %v", err)
+	}
+	params["sign"] = sign
+
+	// 发送请求
+	response, err := c.sendRequest(params)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %v", err)
+	}
+
+	return c.parseSettleResponse(response)
+}
+
+// parseSettleResponse 解析交易分账响应
+func (c *Client) parseSettleResponse(data []byte) (*RoyaltyResponse, error) {
+	var response struct {
+		AlipayTradeOrderSettleResponse struct {
+			Code     string `json:"code"`
+			Msg      string `json:"msg"`
+			SubCode  string `json:"sub_code"`
+			SubMsg   string `json:"sub_msg"`
+			TradeNo  string `json:"trade_no"`
+			SettleNo string `json:"settle_no"`
+		} `json:"alipay_trade_order_settle_response"`
+	}
+
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+
+	resp := response.AlipayTradeOrderSettleResponse
+	if resp.Code != "10000" {
+		return nil, fmt.Errorf("支付宝返回错误: %s - %s", resp.SubCode, resp.SubMsg)
+	}
+
+	return &RoyaltyResponse{
+		TradeNo:  resp.TradeNo,
+		SettleNo: resp.SettleNo,
+	}, nil
+}
+
+// buildCommonParams 构建公共请求参数
+func (c *Client) buildCommonParams(method string) map[string]string {
+	return map[string]string{
+		"app_id":    c.config.AppID,
+		"method":    method,
+		"format":    c.config.Format,
+		"charset":   c.config.Charset,
+		"sign_type": c.config.SignType,
+		"timestamp": time.Now().Format("2006-01-02 15:04:05"),
+		"version":   "1.0",
+	}
+}
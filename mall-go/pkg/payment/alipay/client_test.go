@@ -0,0 +1,127 @@
+package alipay
+
+import (
+	"testing"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_parseRefundResponse(t *testing.T) {
+	client := &Client{}
+
+	tests := []struct {
+		name        string
+		data        string
+		wantStatus  model.PaymentStatus
+		wantSuccess bool
+	}{
+		{
+			name:        "资金已变化",
+			data:        `{"alipay_trade_refund_response":{"code":"10000","msg":"Success","out_trade_no":"PAY123","trade_no":"2024","refund_fee":"10.00","fund_change":"Y"}}`,
+			wantStatus:  model.PaymentStatusSuccess,
+			wantSuccess: true,
+		},
+		{
+			name:        "退款处理中",
+			data:        `{"alipay_trade_refund_response":{"code":"10000","msg":"Success","out_trade_no":"PAY123","trade_no":"2024","refund_fee":"10.00","fund_change":"N"}}`,
+			wantStatus:  model.PaymentStatusPending,
+			wantSuccess: true,
+		},
+		{
+			name:        "业务失败",
+			data:        `{"alipay_trade_refund_response":{"code":"40004","msg":"Business Failed","sub_code":"ACQ.TRADE_NOT_EXIST","sub_msg":"交易不存在"}}`,
+			wantStatus:  model.PaymentStatusFailed,
+			wantSuccess: false,
+		},
+		{
+			name:        "系统繁忙",
+			data:        `{"alipay_trade_refund_response":{"code":"40004","msg":"Business Failed","sub_code":"ACQ.SYSTEM_ERROR","sub_msg":"系统错误"}}`,
+			wantStatus:  model.PaymentStatusPending,
+			wantSuccess: false,
+		},
+		{
+			name:        "服务不可用",
+			data:        `{"alipay_trade_refund_response":{"code":"20000","msg":"Service Currently Unavailable","sub_code":"isp.unknow-error","sub_msg":"系统繁忙"}}`,
+			wantStatus:  model.PaymentStatusPending,
+			wantSuccess: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.parseRefundResponse([]byte(tt.data), "REF123")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, resp.Status)
+			assert.Equal(t, tt.wantSuccess, resp.Success)
+			assert.Equal(t, "REF123", resp.OutRequestNo)
+		})
+	}
+}
+
+func TestClient_parseRefundQueryResponse(t *testing.T) {
+	client := &Client{}
+
+	resp, err := client.parseRefundQueryResponse([]byte(`{"alipay_trade_fastpay_refund_query_response":{"code":"10000","msg":"Success",` +
+		`"out_trade_no":"PAY123","out_request_no":"REF123","refund_amount":"5.00","refund_status":"REFUND_SUCCESS","gmt_refund_pay":"2024-01-02 10:00:00"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusSuccess, resp.Status)
+	assert.True(t, resp.RefundAmount.Equal(decimal.NewFromInt(5)))
+
+	resp, err = client.parseRefundQueryResponse([]byte(`{"alipay_trade_fastpay_refund_query_response":{"code":"10000","msg":"Success","out_trade_no":"PAY123","out_request_no":"REF123"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusPending, resp.Status)
+	assert.False(t, resp.Accepted())
+}
+
+func TestParseRefundNotify(t *testing.T) {
+	assert.Nil(t, ParseRefundNotify(map[string]string{"out_trade_no": "PAY123", "trade_status": "TRADE_SUCCESS"}))
+
+	notify := ParseRefundNotify(map[string]string{
+		"out_trade_no": "PAY123",
+		"trade_no":     "2024",
+		"out_biz_no":   "REF123",
+		"refund_fee":   "3.50",
+		"gmt_refund":   "2024-01-02 10:00:00.123",
+	})
+	assert.NotNil(t, notify)
+	assert.Equal(t, "REF123", notify.OutBizNo)
+	assert.True(t, notify.RefundFee.Equal(decimal.NewFromFloat(3.5)))
+
+	refundedAt, err := notify.GetRefundTime()
+	assert.NoError(t, err)
+	assert.NotNil(t, refundedAt)
+}
+
+func TestClient_parseQueryResponse_TradeNotExist(t *testing.T) {
+	client := &Client{}
+
+	_, err := client.parseQueryResponse([]byte(`{"alipay_trade_query_response":{"code":"40004","msg":"Business Failed","sub_code":"ACQ.TRADE_NOT_EXIST","sub_msg":"交易不存在"}}`))
+	assert.ErrorIs(t, err, model.ErrTradeNotExist)
+
+	_, err = client.parseQueryResponse([]byte(`{"alipay_trade_query_response":{"code":"20000","msg":"Service Currently Unavailable","sub_code":"ACQ.SYSTEM_ERROR","sub_msg":"系统错误"}}`))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, model.ErrTradeNotExist)
+}
+
+func TestClient_parseCloseResponse(t *testing.T) {
+	client := &Client{}
+
+	assert.NoError(t, client.parseCloseResponse([]byte(`{"alipay_trade_close_response":{"code":"10000","msg":"Success","out_trade_no":"PAY123"}}`)))
+	assert.NoError(t, client.parseCloseResponse([]byte(`{"alipay_trade_close_response":{"code":"40004","msg":"Business Failed","sub_code":"ACQ.TRADE_NOT_EXIST","sub_msg":"交易不存在"}}`)))
+	assert.Error(t, client.parseCloseResponse([]byte(`{"alipay_trade_close_response":{"code":"40004","msg":"Business Failed","sub_code":"ACQ.TRADE_STATUS_ERROR","sub_msg":"交易状态不合法"}}`)))
+}
+
+func TestClient_parseSettleResponse(t *testing.T) {
+	client := &Client{}
+
+	resp, err := client.parseSettleResponse([]byte(`{"alipay_trade_order_settle_response":{"code":"10000","msg":"Success","trade_no":"2024010122001","settle_no":"20240101001"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "2024010122001", resp.TradeNo)
+	assert.Equal(t, "20240101001", resp.SettleNo)
+
+	_, err = client.parseSettleResponse([]byte(`{"alipay_trade_order_settle_response":{"code":"40004","msg":"Business Failed","sub_code":"ACQ.ALLOC_AMOUNT_VALIDATE_ERROR","sub_msg":"分账金额超过最大可分账金额"}}`))
+	assert.Error(t, err)
+}
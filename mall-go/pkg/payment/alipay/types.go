@@ -18,6 +18,7 @@ type PaymentRequest struct {
 	NotifyURL   string          `json:"notify_url"`   // 异步通知地址
 	ReturnURL   string          `json:"return_url"`   // 同步跳转地址
 	TimeExpire  *time.Time      `json:"time_expire"`  // 订单过期时间
+	Currency    string          `json:"currency"`     // 标价币种，默认CNY
 }
 
 // PaymentResponse 支付响应
@@ -90,17 +91,104 @@ type RefundRequest struct {
 	RefundAmount decimal.Decimal `json:"refund_amount"`  // 退款金额
 	RefundReason string          `json:"refund_reason"`  // 退款原因
 	OutRequestNo string          `json:"out_request_no"` // 退款请求号
+	Currency     string          `json:"currency"`       // 退款币种，与支付币种一致
 }
 
 // RefundResponse 退款响应
 type RefundResponse struct {
-	OutTradeNo   string          `json:"out_trade_no"`   // 商户订单号
-	TradeNo      string          `json:"trade_no"`       // 支付宝交易号
-	OutRequestNo string          `json:"out_request_no"` // 退款请求号
-	RefundFee    decimal.Decimal `json:"refund_fee"`     // 退款金额
-	GmtRefundPay string          `json:"gmt_refund_pay"` // 退款时间
-	Success      bool            `json:"success"`        // 是否成功
-	Message      string          `json:"message"`        // 错误信息
+	OutTradeNo   string              `json:"out_trade_no"`   // 商户订单号
+	TradeNo      string              `json:"trade_no"`       // 支付宝交易号
+	OutRequestNo string              `json:"out_request_no"` // 退款请求号
+	RefundFee    decimal.Decimal     `json:"refund_fee"`     // 退款金额
+	GmtRefundPay string              `json:"gmt_refund_pay"` // 退款时间
+	FundChange   string              `json:"fund_change"`    // 本次退款是否发生资金变化
+	Status       model.PaymentStatus `json:"status"`         // 标准化退款状态
+	Success      bool                `json:"success"`        // 是否成功
+	Message      string              `json:"message"`        // 错误信息
+}
+
+// RoyaltyReceiver 分账收入方
+type RoyaltyReceiver struct {
+	TransIn     string          `json:"trans_in"`      // 收入方账户
+	TransInType string          `json:"trans_in_type"` // 收入方账户类型 userId/loginName
+	Amount      decimal.Decimal `json:"amount"`        // 分账金额
+	Desc        string          `json:"desc"`          // 分账描述
+}
+
+// RoyaltyRequest 交易分账请求
+type RoyaltyRequest struct {
+	OutRequestNo string            `json:"out_request_no"` // 分账请求号，重复请求幂等
+	TradeNo      string            `json:"trade_no"`       // 支付宝交易号
+	Receivers    []RoyaltyReceiver `json:"receivers"`      // 分账收入方
+}
+
+// RoyaltyResponse 交易分账响应
+type RoyaltyResponse struct {
+	TradeNo  string `json:"trade_no"`  // 支付宝交易号
+	SettleNo string `json:"settle_no"` // 支付宝分账单号
+}
+
+// RefundQueryResponse 退款查询响应
+type RefundQueryResponse struct {
+	OutTradeNo   string              `json:"out_trade_no"`   // 商户订单号
+	TradeNo      string              `json:"trade_no"`       // 支付宝交易号
+	OutRequestNo string              `json:"out_request_no"` // 退款请求号
+	RefundAmount decimal.Decimal     `json:"refund_amount"`  // 退款金额
+	RefundStatus string              `json:"refund_status"`  // 退款状态
+	GmtRefundPay string              `json:"gmt_refund_pay"` // 退款时间
+	Status       model.PaymentStatus `json:"status"`         // 标准化退款状态
+	Success      bool                `json:"success"`        // 是否成功
+	Message      string              `json:"message"`        // 错误信息
+}
+
+// Accepted 查询结果是否包含退款数据，未返回退款金额和状态说明支付宝未受理该退款请求
+func (r *RefundQueryResponse) Accepted() bool {
+	return r.RefundStatus != "" || !r.RefundAmount.IsZero()
+}
+
+// RefundNotify 退款通知数据
+// 部分退款时支付宝通过交易状态同步通知下发 out_biz_no、refund_fee 与 gmt_refund
+type RefundNotify struct {
+	OutTradeNo string          `json:"out_trade_no"` // 商户订单号
+	TradeNo    string          `json:"trade_no"`     // 支付宝交易号
+	OutBizNo   string          `json:"out_biz_no"`   // 退款请求号
+	RefundFee  decimal.Decimal `json:"refund_fee"`   // 总退款金额
+	GmtRefund  string          `json:"gmt_refund"`   // 退款时间
+}
+
+// ParseRefundNotify 从回调参数中解析退款通知，非退款通知返回nil
+func ParseRefundNotify(params map[string]string) *RefundNotify {
+	if params["out_biz_no"] == "" || params["gmt_refund"] == "" {
+		return nil
+	}
+
+	refundFee, _ := decimal.NewFromString(params["refund_fee"])
+
+	return &RefundNotify{
+		OutTradeNo: params["out_trade_no"],
+		TradeNo:    params["trade_no"],
+		OutBizNo:   params["out_biz_no"],
+		RefundFee:  refundFee,
+		GmtRefund:  params["gmt_refund"],
+	}
+}
+
+// GetRefundTime 获取退款时间
+func (rn *RefundNotify) GetRefundTime() (*time.Time, error) {
+	if rn.GmtRefund == "" {
+		return nil, nil
+	}
+
+	// 解析时间格式: 2006-01-02 15:04:05.000
+	t, err := time.Parse("2006-01-02 15:04:05.000", rn.GmtRefund)
+	if err != nil {
+		t, err = time.Parse("2006-01-02 15:04:05", rn.GmtRefund)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &t, nil
 }
 
 // TradeStatus 交易状态常量
@@ -111,6 +199,11 @@ const (
 	TradeStatusFinished     = "TRADE_FINISHED" // 交易结束，不可退款
 )
 
+// RefundStatus 退款状态常量
+const (
+	RefundStatusSuccess = "REFUND_SUCCESS" // 退款处理成功
+)
+
 // NotifyType 通知类型常量
 const (
 	NotifyTypeTradePay    = "trade_status_sync" // 交易状态同步
@@ -199,11 +292,30 @@ const (
 	ErrorCodePermissionDenied   = "40006" // 权限不足
 )
 
+// SubCode 业务错误子码常量
+const (
+	SubCodeSystemError   = "ACQ.SYSTEM_ERROR"    // 系统繁忙，结果未知，需使用相同请求号重试
+	SubCodeTradeNotExist = "ACQ.TRADE_NOT_EXIST" // 交易不存在，用户未扫码时查询和关单返回
+)
+
 // IsSuccess 判断是否成功
 func IsSuccess(code string) bool {
 	return code == ErrorCodeSuccess
 }
 
+// IsDefinitiveFailure 判断是否为明确的业务失败
+// 服务不可用、系统繁忙或未知错误码时请求结果未知，不能据此判定失败
+func IsDefinitiveFailure(code, subCode string) bool {
+	switch code {
+	case ErrorCodeInvalidAuth, ErrorCodeMissingParam, ErrorCodeInvalidParam, ErrorCodePermissionDenied:
+		return true
+	case ErrorCodeBusinessFailed:
+		return subCode != SubCodeSystemError
+	default:
+		return false
+	}
+}
+
 // GetErrorMessage 获取错误信息
 func GetErrorMessage(code, subCode, subMsg string) string {
 	if code == ErrorCodeSuccess {
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"mall-go/internal/model"
+	"mall-go/pkg/currency"
 	"mall-go/pkg/logger"
 
 	"github.com/go-redis/redis/v8"
@@ -79,6 +80,18 @@ type WechatCallbackData struct {
 
 // ValidateAlipayCallback 验证支付宝回调
 func (cv *CallbackValidator) ValidateAlipayCallback(data *AlipayCallbackData, secretKey string) *ValidationResult {
+	return cv.validateAlipayCallback(data, secretKey, false)
+}
+
+// ValidateReplayedAlipayCallback 验证人工重放的支付宝回调
+// 重放的是本地保存的通知原文，跳过通知时间、通知ID与幂等标记等防重放校验，其余校验不变；
+// 重复处理由回调处理流程中的支付状态检查保证
+func (cv *CallbackValidator) ValidateReplayedAlipayCallback(data *AlipayCallbackData, secretKey string) *ValidationResult {
+	return cv.validateAlipayCallback(data, secretKey, true)
+}
+
+// validateAlipayCallback 验证支付宝回调，replay 为true时跳过防重放校验
+func (cv *CallbackValidator) validateAlipayCallback(data *AlipayCallbackData, secretKey string, replay bool) *ValidationResult {
 	result := &ValidationResult{}
 
 	// 1. 验证必要参数
@@ -89,14 +102,14 @@ func (cv *CallbackValidator) ValidateAlipayCallback(data *AlipayCallbackData, se
 	}
 
 	// 2. 验证通知时间（防重放攻击）
-	if !cv.validateNotifyTime(data.NotifyTime) {
+	if !replay && !cv.validateNotifyTime(data.NotifyTime) {
 		result.ErrorCode = "INVALID_TIME"
 		result.ErrorMessage = "通知时间无效或已过期"
 		return result
 	}
 
 	// 3. 验证通知ID唯一性（防重复通知）
-	if !cv.validateNotifyID("alipay", data.NotifyID) {
+	if !replay && !cv.validateNotifyID("alipay", data.NotifyID) {
 		result.ErrorCode = "DUPLICATE_NOTIFY"
 		result.ErrorMessage = "重复的通知"
 		return result
@@ -132,7 +145,7 @@ func (cv *CallbackValidator) ValidateAlipayCallback(data *AlipayCallbackData, se
 	}
 
 	// 8. 验证幂等性（防止重复处理）
-	if !cv.validateIdempotency("alipay", data.OutTradeNo, data.TradeNo) {
+	if !replay && !cv.validateIdempotency("alipay", data.OutTradeNo, data.TradeNo) {
 		result.ErrorCode = "ALREADY_PROCESSED"
 		result.ErrorMessage = "该支付已处理"
 		return result
@@ -169,10 +182,9 @@ func (cv *CallbackValidator) ValidateWechatCallback(data *WechatCallbackData, se
 		return result
 	}
 
-	// 4. 验证金额一致性（微信金额单位是分）
-	totalFee, _ := strconv.Atoi(data.TotalFee)
-	expectedAmount := decimal.NewFromFloat(float64(totalFee) / 100)
-	if !payment.Amount.Equal(expectedAmount) {
+	// 4. 验证金额一致性（微信金额为支付币种的最小货币单位）
+	totalFee, _ := strconv.ParseInt(data.TotalFee, 10, 64)
+	if !payment.Amount.Equal(currency.FromMinorUnits(totalFee, payment.Currency)) {
 		result.ErrorCode = "AMOUNT_MISMATCH"
 		result.ErrorMessage = "金额不匹配"
 		return result
@@ -0,0 +1,268 @@
+package callbacklog
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/pagination"
+
+	"gorm.io/gorm"
+)
+
+// maxErrorLength 错误信息最大保存长度（字符）
+const maxErrorLength = 1000
+
+// Notification 收到的回调通知原文
+type Notification struct {
+	Channel    model.PaymentMethod
+	Kind       model.PaymentCallbackKind
+	APIVersion string
+	Path       string
+	Header     http.Header
+	Body       []byte
+	ClientIP   string
+}
+
+// Store 回调通知存储
+// 回调在处理前落库，处理结果与每次人工重放另行记录，失败的回调可在修复后重放
+type Store struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+// NewStore 创建回调通知存储
+func NewStore(db *gorm.DB) *Store {
+	return &Store{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+// Save 保存收到的回调通知，状态为已接收
+func (s *Store) Save(notification *Notification) (*model.PaymentCallback, error) {
+	headers, err := json.Marshal(notification.Header)
+	if err != nil {
+		return nil, fmt.Errorf("序列化回调请求头失败: %v", err)
+	}
+
+	callback := &model.PaymentCallback{
+		Channel:    notification.Channel,
+		Kind:       notification.Kind,
+		APIVersion: notification.APIVersion,
+		Path:       notification.Path,
+		Headers:    string(headers),
+		Body:       string(notification.Body),
+		ClientIP:   notification.ClientIP,
+		OutTradeNo: extractOutTradeNo(notification.Channel, notification.Body),
+		Status:     model.PaymentCallbackReceived,
+	}
+	if err := s.db.Create(callback).Error; err != nil {
+		return nil, fmt.Errorf("保存回调通知失败: %v", err)
+	}
+	return callback, nil
+}
+
+// Complete 记录首次处理结果
+func (s *Store) Complete(callback *model.PaymentCallback, processErr error, duration time.Duration) error {
+	return s.complete(callback, &model.PaymentCallbackAttempt{DurationMs: duration.Milliseconds()}, processErr)
+}
+
+// CompleteReplay 记录人工重放的处理结果
+func (s *Store) CompleteReplay(callback *model.PaymentCallback, operatorID uint, processErr error, duration time.Duration) error {
+	now := s.now()
+	callback.ReplayCount++
+	callback.LastReplayedAt = &now
+	return s.complete(callback, &model.PaymentCallbackAttempt{
+		Replay:     true,
+		OperatorID: operatorID,
+		DurationMs: duration.Milliseconds(),
+	}, processErr)
+}
+
+// complete 更新回调最近一次处理状态并追加处理记录
+func (s *Store) complete(callback *model.PaymentCallback, attempt *model.PaymentCallbackAttempt, processErr error) error {
+	attempt.CallbackID = callback.ID
+	attempt.Validated, attempt.Status = Outcome(processErr)
+	if processErr != nil {
+		attempt.Error = truncate(processErr.Error(), maxErrorLength)
+	}
+	callback.Status = attempt.Status
+	callback.Error = attempt.Error
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(callback).Updates(map[string]interface{}{
+			"status":           callback.Status,
+			"error":            callback.Error,
+			"replay_count":     callback.ReplayCount,
+			"last_replayed_at": callback.LastReplayedAt,
+		}).Error; err != nil {
+			return fmt.Errorf("更新回调处理状态失败: %v", err)
+		}
+		if err := tx.Create(attempt).Error; err != nil {
+			return fmt.Errorf("记录回调处理结果失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// Get 获取回调通知及处理记录
+func (s *Store) Get(id uint) (*model.PaymentCallback, error) {
+	var callback model.PaymentCallback
+	err := s.db.Preload("Attempts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&callback, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrPaymentCallbackNotFound
+		}
+		return nil, fmt.Errorf("查询回调通知失败: %v", err)
+	}
+	return &callback, nil
+}
+
+// List 查询回调通知，按接收时间倒序
+func (s *Store) List(query *model.PaymentCallbackQuery) ([]model.PaymentCallback, int64, error) {
+	query.Page, query.PageSize = pagination.Normalize(query.Page, query.PageSize)
+
+	db := s.filter(s.db.Model(&model.PaymentCallback{}), query.StartTime, query.EndTime)
+	if query.Channel != "" {
+		db = db.Where("channel = ?", query.Channel)
+	}
+	if query.Kind != "" {
+		db = db.Where("kind = ?", query.Kind)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	} else if query.FailedOnly {
+		db = db.Where("status IN ?", []model.PaymentCallbackStatus{model.PaymentCallbackValidationFailed, model.PaymentCallbackFailed})
+	}
+	if query.OutTradeNo != "" {
+		db = db.Where("out_trade_no = ?", query.OutTradeNo)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计回调通知失败: %v", err)
+	}
+
+	var callbacks []model.PaymentCallback
+	if err := db.Order("id DESC").Offset((query.Page - 1) * query.PageSize).Limit(query.PageSize).Find(&callbacks).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询回调通知失败: %v", err)
+	}
+	return callbacks, total, nil
+}
+
+// Stats 按渠道统计回调数量与失败情况
+func (s *Store) Stats(startTime, endTime *time.Time) ([]model.PaymentCallbackStats, error) {
+	var rows []struct {
+		Channel  model.PaymentMethod
+		Status   model.PaymentCallbackStatus
+		Count    int64
+		Replayed int64
+	}
+	err := s.filter(s.db.Model(&model.PaymentCallback{}), startTime, endTime).
+		Select("channel, status, COUNT(*) AS count, SUM(CASE WHEN replay_count > 0 THEN 1 ELSE 0 END) AS replayed").
+		Group("channel, status").
+		Order("channel").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("统计回调通知失败: %v", err)
+	}
+
+	var stats []model.PaymentCallbackStats
+	index := make(map[model.PaymentMethod]int)
+	for _, row := range rows {
+		i, ok := index[row.Channel]
+		if !ok {
+			i = len(stats)
+			index[row.Channel] = i
+			stats = append(stats, model.PaymentCallbackStats{Channel: row.Channel})
+		}
+		item := &stats[i]
+		item.Total += row.Count
+		item.Replayed += row.Replayed
+		switch row.Status {
+		case model.PaymentCallbackSucceeded:
+			item.Succeeded += row.Count
+		case model.PaymentCallbackValidationFailed:
+			item.ValidationFailed += row.Count
+		case model.PaymentCallbackFailed:
+			item.Failed += row.Count
+		default:
+			item.Pending += row.Count
+		}
+	}
+	return stats, nil
+}
+
+// filter 按接收时间过滤
+func (s *Store) filter(db *gorm.DB, startTime, endTime *time.Time) *gorm.DB {
+	if startTime != nil {
+		db = db.Where("created_at >= ?", *startTime)
+	}
+	if endTime != nil {
+		db = db.Where("created_at <= ?", *endTime)
+	}
+	return db
+}
+
+// Header 还原保存的回调请求头
+func Header(callback *model.PaymentCallback) (http.Header, error) {
+	header := http.Header{}
+	if callback.Headers == "" {
+		return header, nil
+	}
+	if err := json.Unmarshal([]byte(callback.Headers), &header); err != nil {
+		return nil, fmt.Errorf("解析回调请求头失败: %v", err)
+	}
+	return header, nil
+}
+
+// Outcome 根据处理错误判断校验是否通过及处理状态
+func Outcome(processErr error) (bool, model.PaymentCallbackStatus) {
+	if processErr == nil {
+		return true, model.PaymentCallbackSucceeded
+	}
+	var validationErr *model.PaymentCallbackValidationError
+	if errors.As(processErr, &validationErr) {
+		return false, model.PaymentCallbackValidationFailed
+	}
+	return true, model.PaymentCallbackFailed
+}
+
+// extractOutTradeNo 从明文报文中解析商户订单号，微信APIv3等加密报文返回空
+func extractOutTradeNo(channel model.PaymentMethod, body []byte) string {
+	switch channel {
+	case model.PaymentMethodAlipay:
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return ""
+		}
+		return truncate(values.Get("out_trade_no"), 64)
+	case model.PaymentMethodWechat:
+		var notify struct {
+			OutTradeNo string `xml:"out_trade_no"`
+		}
+		if err := xml.Unmarshal(body, &notify); err != nil {
+			return ""
+		}
+		return truncate(notify.OutTradeNo, 64)
+	default:
+		return ""
+	}
+}
+
+// truncate 按字符截断
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) <= length {
+		return value
+	}
+	return string(runes[:length])
+}
@@ -0,0 +1,176 @@
+package callbacklog
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupStore(t *testing.T) *Store {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.PaymentCallback{}, &model.PaymentCallbackAttempt{}))
+
+	return NewStore(db)
+}
+
+func alipayNotification(outTradeNo string) *Notification {
+	return &Notification{
+		Channel:  model.PaymentMethodAlipay,
+		Kind:     model.PaymentCallbackKindPayment,
+		Path:     "/api/v1/payments/callback/alipay",
+		Header:   http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+		Body:     []byte("out_trade_no=" + outTradeNo + "&trade_status=TRADE_SUCCESS&sign=abc"),
+		ClientIP: "110.75.1.1",
+	}
+}
+
+func TestSaveAndComplete(t *testing.T) {
+	store := setupStore(t)
+
+	callback, err := store.Save(alipayNotification("PAY001"))
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentCallbackReceived, callback.Status)
+	assert.Equal(t, "PAY001", callback.OutTradeNo)
+
+	wechatCallback, err := store.Save(&Notification{
+		Channel:    model.PaymentMethodWechat,
+		Kind:       model.PaymentCallbackKindPayment,
+		APIVersion: "v2",
+		Body:       []byte("<xml><out_trade_no><![CDATA[PAY002]]></out_trade_no></xml>"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "PAY002", wechatCallback.OutTradeNo)
+
+	// 校验失败与业务处理失败分别记录
+	validationErr := model.NewPaymentCallbackValidationError(errors.New("签名错误"))
+	require.NoError(t, store.Complete(callback, validationErr, 5*time.Millisecond))
+	require.NoError(t, store.Complete(wechatCallback, errors.New("订单不存在"), time.Millisecond))
+
+	saved, err := store.Get(callback.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentCallbackValidationFailed, saved.Status)
+	assert.Equal(t, "签名错误", saved.Error)
+	require.Len(t, saved.Attempts, 1)
+	assert.False(t, saved.Attempts[0].Validated)
+	assert.False(t, saved.Attempts[0].Replay)
+	assert.Equal(t, int64(5), saved.Attempts[0].DurationMs)
+
+	saved, err = store.Get(wechatCallback.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentCallbackFailed, saved.Status)
+	assert.True(t, saved.Attempts[0].Validated)
+
+	header, err := Header(saved)
+	require.NoError(t, err)
+	assert.Empty(t, header)
+
+	saved, err = store.Get(callback.ID)
+	require.NoError(t, err)
+	header, err = Header(saved)
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-www-form-urlencoded", header.Get("Content-Type"))
+
+	_, err = store.Get(999)
+	assert.ErrorIs(t, err, model.ErrPaymentCallbackNotFound)
+}
+
+func TestCompleteReplay(t *testing.T) {
+	store := setupStore(t)
+
+	callback, err := store.Save(alipayNotification("PAY001"))
+	require.NoError(t, err)
+	require.NoError(t, store.Complete(callback, errors.New("数据库繁忙"), 0))
+
+	callback, err = store.Get(callback.ID)
+	require.NoError(t, err)
+	require.NoError(t, store.CompleteReplay(callback, 7, nil, 0))
+
+	saved, err := store.Get(callback.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentCallbackSucceeded, saved.Status)
+	assert.Empty(t, saved.Error)
+	assert.Equal(t, 1, saved.ReplayCount)
+	require.NotNil(t, saved.LastReplayedAt)
+	require.Len(t, saved.Attempts, 2)
+	assert.True(t, saved.Attempts[1].Replay)
+	assert.Equal(t, uint(7), saved.Attempts[1].OperatorID)
+	assert.Equal(t, model.PaymentCallbackSucceeded, saved.Attempts[1].Status)
+}
+
+func TestListAndStats(t *testing.T) {
+	store := setupStore(t)
+
+	outcomes := []error{
+		nil,
+		nil,
+		errors.New("订单不存在"),
+		model.NewPaymentCallbackValidationError(errors.New("签名错误")),
+	}
+	for i, processErr := range outcomes {
+		callback, err := store.Save(alipayNotification(fmt.Sprintf("PAY%03d", i+1)))
+		require.NoError(t, err)
+		require.NoError(t, store.Complete(callback, processErr, 0))
+	}
+	wechatCallback, err := store.Save(&Notification{
+		Channel:    model.PaymentMethodWechat,
+		Kind:       model.PaymentCallbackKindRefund,
+		APIVersion: "v3",
+		Body:       []byte(`{"resource":{}}`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.Complete(wechatCallback, errors.New("退款单不存在"), 0))
+	require.NoError(t, store.CompleteReplay(wechatCallback, 1, errors.New("退款单不存在"), 0))
+
+	// 仅失败的回调
+	failed, total, err := store.List(&model.PaymentCallbackQuery{FailedOnly: true})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Len(t, failed, 3)
+	assert.Equal(t, wechatCallback.ID, failed[0].ID)
+
+	alipayFailed, total, err := store.List(&model.PaymentCallbackQuery{
+		Channel:    model.PaymentMethodAlipay,
+		FailedOnly: true,
+		PageSize:   1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, alipayFailed, 1)
+
+	byOrder, total, err := store.List(&model.PaymentCallbackQuery{OutTradeNo: "PAY002"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "PAY002", byOrder[0].OutTradeNo)
+
+	stats, err := store.Stats(nil, nil)
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	assert.Equal(t, model.PaymentCallbackStats{
+		Channel:          model.PaymentMethodAlipay,
+		Total:            4,
+		Succeeded:        2,
+		ValidationFailed: 1,
+		Failed:           1,
+	}, stats[0])
+	assert.Equal(t, model.PaymentCallbackStats{
+		Channel:  model.PaymentMethodWechat,
+		Total:    1,
+		Failed:   1,
+		Replayed: 1,
+	}, stats[1])
+
+	future := time.Now().Add(time.Hour)
+	stats, err = store.Stats(&future, nil)
+	require.NoError(t, err)
+	assert.Empty(t, stats)
+}
@@ -0,0 +1,33 @@
+package payment
+
+import (
+	"fmt"
+	"net/http"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/payment/wechat"
+)
+
+// ParseWechatComplaint 验签解密微信支付消费者投诉通知并查询投诉详情
+// 通知中仅包含投诉单号，投诉关联的订单与金额需通过投诉查询接口获取；replay 为true时不校验签名时间戳是否过期
+func (s *Service) ParseWechatComplaint(header http.Header, body []byte, replay bool) (*wechat.ComplaintNotify, *wechat.Complaint, error) {
+	wechatV3 := s.wechatV3Client()
+	if wechatV3 == nil {
+		return nil, nil, fmt.Errorf("微信支付APIv3客户端未初始化")
+	}
+
+	parse := wechatV3.ParseComplaintNotify
+	if replay {
+		parse = wechatV3.ParseReplayedComplaintNotify
+	}
+	notify, err := parse(header, body)
+	if err != nil {
+		return nil, nil, model.NewPaymentCallbackValidationError(err)
+	}
+
+	complaint, err := wechatV3.QueryComplaint(notify.ComplaintID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询微信投诉详情失败: %v", err)
+	}
+	return notify, complaint, nil
+}
@@ -8,8 +8,11 @@ import (
 	"time"
 
 	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/payment/secret"
 
 	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
 )
 
 // PaymentConfig 支付系统配置
@@ -37,6 +40,9 @@ type PaymentConfig struct {
 
 	// 限额配置
 	Limits LimitsConfig `json:"limits" yaml:"limits"` // 限额配置
+
+	// 二维码配置
+	QRCode QRCodeConfig `json:"qrcode" yaml:"qrcode"` // 扫码支付二维码图片配置
 }
 
 // AlipayConfig 支付宝配置
@@ -66,6 +72,20 @@ type WechatConfig struct {
 	GatewayURL string        `json:"gateway_url" yaml:"gateway_url"` // 网关地址
 	NotifyURL  string        `json:"notify_url" yaml:"notify_url"`   // 异步通知地址
 	Timeout    time.Duration `json:"timeout" yaml:"timeout"`         // 超时时间
+
+	RefundNotifyURL string `json:"refund_notify_url" yaml:"refund_notify_url"` // 退款结果通知地址
+
+	// APIv3配置，APIVersion为v3时生效
+	APIVersion      string        `json:"api_version" yaml:"api_version"`             // 接口版本: v2, v3，默认v2
+	APIv3Key        string        `json:"api_v3_key" yaml:"api_v3_key"`               // APIv3密钥
+	SerialNo        string        `json:"serial_no" yaml:"serial_no"`                 // 商户API证书序列号
+	PrivateKey      string        `json:"private_key" yaml:"private_key"`             // 商户API私钥(PEM)，为空时从KeyPath读取
+	CertRefreshTime time.Duration `json:"cert_refresh_time" yaml:"cert_refresh_time"` // 平台证书刷新间隔
+}
+
+// IsV3 是否使用APIv3
+func (c *WechatConfig) IsV3() bool {
+	return c.APIVersion == "v3"
 }
 
 // UnionPayConfig 银联配置
@@ -99,17 +119,27 @@ type SecurityConfig struct {
 	AllowedIPs      []string      `json:"allowed_ips" yaml:"allowed_ips"`           // 允许的IP地址列表
 }
 
+// QRCodeConfig 扫码支付二维码图片配置
+type QRCodeConfig struct {
+	Size          int     `json:"size" yaml:"size"`                     // 默认边长(像素)
+	MaxSize       int     `json:"max_size" yaml:"max_size"`             // 允许请求的最大边长(像素)
+	RecoveryLevel string  `json:"recovery_level" yaml:"recovery_level"` // 纠错等级 L/M/Q/H，配置logo时至少为Q
+	LogoPath      string  `json:"logo_path" yaml:"logo_path"`           // 中心logo图片路径(PNG/JPEG)
+	LogoRatio     float64 `json:"logo_ratio" yaml:"logo_ratio"`         // logo边长占二维码边长的比例，不超过0.3
+	CacheSize     int     `json:"cache_size" yaml:"cache_size"`         // 最多缓存的二维码图片数量
+}
+
 // LimitsConfig 限额配置
 type LimitsConfig struct {
 	// 单笔限额
 	MinAmount decimal.Decimal `json:"min_amount" yaml:"min_amount"` // 最小金额
 	MaxAmount decimal.Decimal `json:"max_amount" yaml:"max_amount"` // 最大金额
 
-	// 日限额
+	// 日限额（单用户全部支付方式累计，0表示不限制）
 	DailyMaxAmount decimal.Decimal `json:"daily_max_amount" yaml:"daily_max_amount"` // 日最大金额
 	DailyMaxCount  int             `json:"daily_max_count" yaml:"daily_max_count"`   // 日最大笔数
 
-	// 月限额
+	// 月限额（单用户全部支付方式累计，0表示不限制）
 	MonthlyMaxAmount decimal.Decimal `json:"monthly_max_amount" yaml:"monthly_max_amount"` // 月最大金额
 	MonthlyMaxCount  int             `json:"monthly_max_count" yaml:"monthly_max_count"`   // 月最大笔数
 
@@ -117,12 +147,14 @@ type LimitsConfig struct {
 	MethodLimits map[model.PaymentMethod]MethodLimit `json:"method_limits" yaml:"method_limits"` // 支付方式限额
 }
 
-// MethodLimit 支付方式限额
+// MethodLimit 支付方式限额，日/月限额为单用户使用该支付方式的累计限额，0表示不限制
 type MethodLimit struct {
-	MinAmount      decimal.Decimal `json:"min_amount" yaml:"min_amount"`             // 最小金额
-	MaxAmount      decimal.Decimal `json:"max_amount" yaml:"max_amount"`             // 最大金额
-	DailyMaxAmount decimal.Decimal `json:"daily_max_amount" yaml:"daily_max_amount"` // 日最大金额
-	DailyMaxCount  int             `json:"daily_max_count" yaml:"daily_max_count"`   // 日最大笔数
+	MinAmount        decimal.Decimal `json:"min_amount" yaml:"min_amount"`                 // 最小金额
+	MaxAmount        decimal.Decimal `json:"max_amount" yaml:"max_amount"`                 // 最大金额
+	DailyMaxAmount   decimal.Decimal `json:"daily_max_amount" yaml:"daily_max_amount"`     // 日最大金额
+	DailyMaxCount    int             `json:"daily_max_count" yaml:"daily_max_count"`       // 日最大笔数
+	MonthlyMaxAmount decimal.Decimal `json:"monthly_max_amount" yaml:"monthly_max_amount"` // 月最大金额
+	MonthlyMaxCount  int             `json:"monthly_max_count" yaml:"monthly_max_count"`   // 月最大笔数
 }
 
 // DefaultPaymentConfig 默认支付配置
@@ -149,6 +181,9 @@ func DefaultPaymentConfig() *PaymentConfig {
 			SignType:   "MD5",
 			GatewayURL: "https://api.mch.weixin.qq.com",
 			Timeout:    30 * time.Second,
+			APIVersion: "v2",
+
+			CertRefreshTime: 12 * time.Hour,
 		},
 
 		UnionPay: UnionPayConfig{
@@ -195,11 +230,28 @@ func DefaultPaymentConfig() *PaymentConfig {
 				},
 			},
 		},
+
+		QRCode: QRCodeConfig{
+			Size:          256,
+			MaxSize:       1024,
+			RecoveryLevel: "M",
+			LogoRatio:     0.2,
+			CacheSize:     1024,
+		},
 	}
 }
 
-// LoadConfigFromFile 从文件加载配置
+// LoadConfigFromFile 从文件加载配置，密钥字段使用环境变量中的主密钥解密
 func LoadConfigFromFile(configPath string) (*PaymentConfig, error) {
+	keyring, err := ConfigKeyring()
+	if err != nil {
+		return nil, err
+	}
+	return LoadConfigFromFileWithKeyring(configPath, keyring)
+}
+
+// LoadConfigFromFileWithKeyring 从文件加载配置，密钥字段只在内存中解密
+func LoadConfigFromFileWithKeyring(configPath string, keyring *secret.Keyring) (*PaymentConfig, error) {
 	// 如果文件不存在，返回默认配置
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return DefaultPaymentConfig(), nil
@@ -215,6 +267,11 @@ func LoadConfigFromFile(configPath string) (*PaymentConfig, error) {
 		return nil, fmt.Errorf("解析配置文件失败: %v", err)
 	}
 
+	// 解密密钥字段
+	if err := config.DecryptSecrets(keyring); err != nil {
+		return nil, err
+	}
+
 	// 验证配置
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("配置验证失败: %v", err)
@@ -223,6 +280,60 @@ func LoadConfigFromFile(configPath string) (*PaymentConfig, error) {
 	return config, nil
 }
 
+// ConfigKeyring 获取支付配置主密钥环，未配置主密钥时返回nil
+func ConfigKeyring() (*secret.Keyring, error) {
+	keyring, err := secret.DefaultKeyring()
+	if err == secret.ErrNoMasterKey {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("加载支付配置主密钥失败: %v", err)
+	}
+	return keyring, nil
+}
+
+// secretFields 需要加密保存的密钥字段
+func (c *PaymentConfig) secretFields() map[string]*string {
+	return map[string]*string{
+		"alipay.private_key":  &c.Alipay.PrivateKey,
+		"wechat.api_key":      &c.Wechat.APIKey,
+		"wechat.api_v3_key":   &c.Wechat.APIv3Key,
+		"wechat.private_key":  &c.Wechat.PrivateKey,
+		"security.secret_key": &c.Security.SecretKey,
+	}
+}
+
+// EncryptSecrets 返回密钥字段加密后的配置副本，原配置保持明文
+func (c *PaymentConfig) EncryptSecrets(keyring *secret.Keyring) (*PaymentConfig, error) {
+	encrypted := *c
+	for field, value := range encrypted.secretFields() {
+		ciphertext, err := keyring.Reencrypt(*value)
+		if err != nil {
+			return nil, fmt.Errorf("加密配置项 %s 失败: %v", field, err)
+		}
+		*value = ciphertext
+	}
+	return &encrypted, nil
+}
+
+// DecryptSecrets 解密密钥字段，明文字段保持不变；存在密文但未配置主密钥时返回错误
+func (c *PaymentConfig) DecryptSecrets(keyring *secret.Keyring) error {
+	for field, value := range c.secretFields() {
+		if !secret.IsEncrypted(*value) {
+			continue
+		}
+		if keyring == nil {
+			return fmt.Errorf("配置项 %s 已加密: %v", field, secret.ErrNoMasterKey)
+		}
+		plaintext, err := keyring.Decrypt(*value)
+		if err != nil {
+			return fmt.Errorf("解密配置项 %s 失败: %v", field, err)
+		}
+		*value = plaintext
+	}
+	return nil
+}
+
 // LoadConfigFromEnv 从环境变量加载配置
 func LoadConfigFromEnv() *PaymentConfig {
 	config := DefaultPaymentConfig()
@@ -264,29 +375,85 @@ func LoadConfigFromEnv() *PaymentConfig {
 		config.Wechat.APIKey = apiKey
 	}
 
+	if apiVersion := os.Getenv("WECHAT_API_VERSION"); apiVersion != "" {
+		config.Wechat.APIVersion = apiVersion
+	}
+
+	if apiV3Key := os.Getenv("WECHAT_API_V3_KEY"); apiV3Key != "" {
+		config.Wechat.APIv3Key = apiV3Key
+	}
+
+	if serialNo := os.Getenv("WECHAT_SERIAL_NO"); serialNo != "" {
+		config.Wechat.SerialNo = serialNo
+	}
+
+	if keyPath := os.Getenv("WECHAT_KEY_PATH"); keyPath != "" {
+		config.Wechat.KeyPath = keyPath
+	}
+
 	return config
 }
 
-// SaveToFile 保存配置到文件
+// SaveToFile 保存配置到文件，密钥字段使用环境变量中的主密钥加密
+// 未配置主密钥时生产环境拒绝写入明文密钥，其他环境写入明文并告警
 func (c *PaymentConfig) SaveToFile(configPath string) error {
+	keyring, err := ConfigKeyring()
+	if err != nil {
+		return err
+	}
+	return c.SaveToFileWithKeyring(configPath, keyring)
+}
+
+// SaveToFileWithKeyring 使用指定主密钥环加密密钥字段后保存配置
+func (c *PaymentConfig) SaveToFileWithKeyring(configPath string, keyring *secret.Keyring) error {
+	toSave := c
+	if keyring != nil {
+		encrypted, err := c.EncryptSecrets(keyring)
+		if err != nil {
+			return err
+		}
+		toSave = encrypted
+	} else if c.hasPlaintextSecrets() {
+		if c.IsProduction() {
+			return fmt.Errorf("生产环境保存支付密钥前需配置主密钥: %v", secret.ErrNoMasterKey)
+		}
+		logger.Warn("未配置支付配置主密钥，密钥将以明文保存", zap.String("config_path", configPath))
+	}
+
 	// 创建目录
 	dir := filepath.Dir(configPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("创建配置目录失败: %v", err)
 	}
 
-	data, err := json.MarshalIndent(c, "", "  ")
+	data, err := json.MarshalIndent(toSave, "", "  ")
 	if err != nil {
 		return fmt.Errorf("序列化配置失败: %v", err)
 	}
 
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	// 先写临时文件再重命名，避免热加载读到写了一半的文件
+	tmpPath := configPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("写入配置文件失败: %v", err)
+	}
+	if err := os.Rename(tmpPath, configPath); err != nil {
+		os.Remove(tmpPath)
 		return fmt.Errorf("写入配置文件失败: %v", err)
 	}
 
 	return nil
 }
 
+// hasPlaintextSecrets 是否存在未加密的密钥
+func (c *PaymentConfig) hasPlaintextSecrets() bool {
+	for _, value := range c.secretFields() {
+		if *value != "" && !secret.IsEncrypted(*value) {
+			return true
+		}
+	}
+	return false
+}
+
 // Validate 验证配置
 func (c *PaymentConfig) Validate() error {
 	if c.Environment == "" {
@@ -326,8 +493,23 @@ func (c *PaymentConfig) Validate() error {
 		if c.Wechat.MchID == "" {
 			return fmt.Errorf("微信商户号不能为空")
 		}
-		if c.Wechat.APIKey == "" {
-			return fmt.Errorf("微信API密钥不能为空")
+		switch c.Wechat.APIVersion {
+		case "v3":
+			if len(c.Wechat.APIv3Key) != 32 {
+				return fmt.Errorf("微信APIv3密钥长度必须为32位")
+			}
+			if c.Wechat.SerialNo == "" {
+				return fmt.Errorf("微信商户证书序列号不能为空")
+			}
+			if c.Wechat.PrivateKey == "" && c.Wechat.KeyPath == "" {
+				return fmt.Errorf("微信商户私钥不能为空")
+			}
+		case "", "v2":
+			if c.Wechat.APIKey == "" {
+				return fmt.Errorf("微信API密钥不能为空")
+			}
+		default:
+			return fmt.Errorf("不支持的微信支付接口版本: %s", c.Wechat.APIVersion)
 		}
 	}
 
@@ -357,9 +539,11 @@ func (c *PaymentConfig) GetMethodLimit(method model.PaymentMethod) MethodLimit {
 
 	// 返回默认限额
 	return MethodLimit{
-		MinAmount:      c.Limits.MinAmount,
-		MaxAmount:      c.Limits.MaxAmount,
-		DailyMaxAmount: c.Limits.DailyMaxAmount,
-		DailyMaxCount:  c.Limits.DailyMaxCount,
+		MinAmount:        c.Limits.MinAmount,
+		MaxAmount:        c.Limits.MaxAmount,
+		DailyMaxAmount:   c.Limits.DailyMaxAmount,
+		DailyMaxCount:    c.Limits.DailyMaxCount,
+		MonthlyMaxAmount: c.Limits.MonthlyMaxAmount,
+		MonthlyMaxCount:  c.Limits.MonthlyMaxCount,
 	}
 }
@@ -60,6 +60,13 @@ type WechatConfig struct {
 	GatewayURL string        `json:"gateway_url" yaml:"gateway_url"` // 网关地址
 	NotifyURL  string        `json:"notify_url" yaml:"notify_url"`   // 异步通知地址
 	Timeout    time.Duration `json:"timeout" yaml:"timeout"`         // 超时时间
+
+	// APIv3配置
+	APIVersion      string        `json:"api_version" yaml:"api_version"`             // 接口版本: v2, v3，默认v2
+	APIv3Key        string        `json:"api_v3_key" yaml:"api_v3_key"`               // APIv3密钥，用于解密通知和平台证书
+	SerialNo        string        `json:"serial_no" yaml:"serial_no"`                 // 商户API证书序列号
+	PrivateKey      string        `json:"private_key" yaml:"private_key"`             // 商户API私钥(PEM)，为空时从KeyPath读取
+	CertRefreshTime time.Duration `json:"cert_refresh_time" yaml:"cert_refresh_time"` // 平台证书刷新间隔
 }
 
 // UnionPayConfig 银联配置
@@ -2,17 +2,37 @@ package payment
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"mall-go/internal/model"
 	"mall-go/pkg/logger"
+	"mall-go/pkg/payment/secret"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
+// configReloadDelay 配置文件变更后等待写入完成的时间，合并编辑器保存时的多次事件
+const configReloadDelay = 500 * time.Millisecond
+
+// ConfigWatcher 配置变更监听器
+type ConfigWatcher interface {
+	OnConfigChanged(config *PaymentConfig)
+}
+
+// ConfigWatcherFunc 配置变更监听器函数类型
+type ConfigWatcherFunc func(config *PaymentConfig)
+
+// OnConfigChanged 实现ConfigWatcher接口
+func (f ConfigWatcherFunc) OnConfigChanged(config *PaymentConfig) {
+	f(config)
+}
+
 // ConfigManager 配置管理器
 type ConfigManager struct {
 	db           *gorm.DB
@@ -21,6 +41,10 @@ type ConfigManager struct {
 	mutex        sync.RWMutex
 	lastUpdate   time.Time
 	updateTicker *time.Ticker
+
+	keyring     *secret.Keyring // 数据库中支付方式配置的加密主密钥，未配置时明文保存
+	watchers    []ConfigWatcher
+	fileWatcher *fsnotify.Watcher
 }
 
 // NewConfigManager 创建配置管理器
@@ -31,6 +55,12 @@ func NewConfigManager(db *gorm.DB, config *PaymentConfig) *ConfigManager {
 		dbConfigs: make(map[model.PaymentMethod]*model.PaymentConfig),
 	}
 
+	keyring, err := ConfigKeyring()
+	if err != nil {
+		logger.Error("加载支付配置主密钥失败，支付方式配置将无法解密", zap.Error(err))
+	}
+	manager.keyring = keyring
+
 	// 加载数据库配置
 	if err := manager.LoadDBConfigs(); err != nil {
 		logger.Error("加载数据库配置失败", zap.Error(err))
@@ -52,8 +82,16 @@ func (cm *ConfigManager) LoadDBConfigs() error {
 		return fmt.Errorf("查询支付配置失败: %v", err)
 	}
 
-	// 更新内存配置
+	// 更新内存配置，加密的配置只在内存中解密
 	for _, config := range configs {
+		plaintext, err := cm.decryptMethodConfig(config.Config)
+		if err != nil {
+			logger.Error("解密支付方式配置失败",
+				zap.String("method", string(config.PaymentMethod)),
+				zap.Error(err))
+			continue
+		}
+		config.Config = plaintext
 		cm.dbConfigs[config.PaymentMethod] = &config
 	}
 
@@ -127,7 +165,7 @@ func (cm *ConfigManager) UpdateMethodConfig(method model.PaymentMethod, config *
 	defer cm.mutex.Unlock()
 
 	// 更新数据库
-	if err := cm.db.Save(config).Error; err != nil {
+	if err := cm.saveMethodConfig(config, cm.db.Save); err != nil {
 		return fmt.Errorf("更新支付配置失败: %v", err)
 	}
 
@@ -152,7 +190,7 @@ func (cm *ConfigManager) CreateMethodConfig(config *model.PaymentConfig) error {
 	}
 
 	// 创建数据库记录
-	if err := cm.db.Create(config).Error; err != nil {
+	if err := cm.saveMethodConfig(config, cm.db.Create); err != nil {
 		return fmt.Errorf("创建支付配置失败: %v", err)
 	}
 
@@ -295,9 +333,153 @@ func (cm *ConfigManager) Stop() {
 	if cm.updateTicker != nil {
 		cm.updateTicker.Stop()
 	}
+	if cm.fileWatcher != nil {
+		cm.fileWatcher.Close()
+	}
 	logger.Info("配置管理器已停止")
 }
 
+// saveMethodConfig 加密支付方式配置后写入数据库，内存中的配置保持明文
+func (cm *ConfigManager) saveMethodConfig(config *model.PaymentConfig, write func(value interface{}) *gorm.DB) error {
+	stored := *config
+	if cm.keyring != nil {
+		ciphertext, err := cm.keyring.Encrypt(config.Config)
+		if err != nil {
+			return err
+		}
+		stored.Config = ciphertext
+	}
+	if err := write(&stored).Error; err != nil {
+		return err
+	}
+	config.ID = stored.ID
+	config.CreatedAt = stored.CreatedAt
+	config.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+// decryptMethodConfig 解密支付方式配置，明文配置原样返回
+func (cm *ConfigManager) decryptMethodConfig(value string) (string, error) {
+	if !secret.IsEncrypted(value) {
+		return value, nil
+	}
+	if cm.keyring == nil {
+		return "", secret.ErrNoMasterKey
+	}
+	return cm.keyring.Decrypt(value)
+}
+
+// AddWatcher 添加配置变更监听器，按添加顺序通知
+func (cm *ConfigManager) AddWatcher(watcher ConfigWatcher) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	cm.watchers = append(cm.watchers, watcher)
+}
+
+// WatchFile 监听配置文件变更并热加载
+// 监听文件所在目录，兼容编辑器与 SaveToFile 先写临时文件再重命名的保存方式
+func (cm *ConfigManager) WatchFile(configPath string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("创建配置文件监听失败: %v", err)
+	}
+	if err := watcher.Add(filepath.Dir(configPath)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("监听配置目录失败: %v", err)
+	}
+	cm.mutex.Lock()
+	cm.fileWatcher = watcher
+	cm.mutex.Unlock()
+
+	target := filepath.Clean(configPath)
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != target || event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(configReloadDelay, func() {
+					if err := cm.ReloadFile(configPath); err != nil {
+						logger.Error("热加载支付配置失败，继续使用当前配置",
+							zap.String("config_path", configPath),
+							zap.Error(err))
+					}
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Error("监听支付配置文件出错", zap.Error(err))
+			}
+		}
+	}()
+
+	logger.Info("开始监听支付配置文件", zap.String("config_path", configPath))
+	return nil
+}
+
+// ReloadFile 从文件重新加载配置，解密并验证通过后替换当前配置并通知监听器
+func (cm *ConfigManager) ReloadFile(configPath string) error {
+	// 文件被删除或重命名时保留当前配置
+	if _, err := os.Stat(configPath); err != nil {
+		return fmt.Errorf("配置文件不可用: %v", err)
+	}
+
+	config, err := LoadConfigFromFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	cm.mutex.Lock()
+	cm.config = config
+	cm.lastUpdate = time.Now()
+	watchers := append([]ConfigWatcher(nil), cm.watchers...)
+	cm.mutex.Unlock()
+
+	for _, watcher := range watchers {
+		watcher.OnConfigChanged(config)
+	}
+
+	logger.Info("支付配置已热加载",
+		zap.String("config_path", configPath),
+		zap.String("environment", config.Environment))
+	return nil
+}
+
+// RotateDBSecrets 使用当前主密钥重新加密数据库中的支付方式配置，返回重新加密的记录数
+// 密钥环需包含轮换前的主密钥；尚未加密的配置同样会被加密
+func RotateDBSecrets(db *gorm.DB, keyring *secret.Keyring) (int, error) {
+	var configs []model.PaymentConfig
+	if err := db.Find(&configs).Error; err != nil {
+		return 0, fmt.Errorf("查询支付配置失败: %v", err)
+	}
+
+	rotated := 0
+	for _, config := range configs {
+		ciphertext, err := keyring.Reencrypt(config.Config)
+		if err != nil {
+			return rotated, fmt.Errorf("重新加密支付方式 %s 配置失败: %v", config.PaymentMethod, err)
+		}
+		if ciphertext == config.Config {
+			continue
+		}
+		if err := db.Model(&model.PaymentConfig{}).Where("id = ?", config.ID).
+			Update("config", ciphertext).Error; err != nil {
+			return rotated, fmt.Errorf("更新支付方式 %s 配置失败: %v", config.PaymentMethod, err)
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
 // GetConfigSummary 获取配置摘要
 func (cm *ConfigManager) GetConfigSummary() map[string]interface{} {
 	cm.mutex.RLock()
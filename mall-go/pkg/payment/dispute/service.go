@@ -0,0 +1,388 @@
+package dispute
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/pagination"
+	"mall-go/pkg/upload"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Options 支付争议配置
+type Options struct {
+	EvidenceWindow time.Duration // 未指定截止时间时，证据提交期限（自争议发起起算）
+}
+
+// DefaultOptions 默认支付争议配置
+func DefaultOptions() Options {
+	return Options{
+		EvidenceWindow: 7 * 24 * time.Hour,
+	}
+}
+
+// EvidenceUploader 证据文件上传，由 upload.FileManager 实现
+type EvidenceUploader interface {
+	UploadFile(req *upload.UploadFileRequest) (*upload.UploadFileResponse, error)
+}
+
+// ChannelDispute 渠道通知的争议信息
+type ChannelDispute struct {
+	Channel          model.PaymentMethod      // 支付渠道
+	ChannelDisputeID string                   // 渠道争议单号
+	Type             model.PaymentDisputeType // 争议类型
+	OutTradeNo       string                   // 商户订单号（支付单号）
+	Amount           decimal.Decimal          // 争议金额，为0时取支付金额
+	Reason           string                   // 争议原因
+	OpenedAt         *time.Time               // 渠道发起时间，用于计算证据截止时间
+}
+
+// Service 支付争议服务
+// 争议可人工登记或由渠道投诉通知创建，上传证据后提交渠道裁决；
+// 败诉的争议由结算服务在商家下一期结算单中冲回，订单服务据此禁止发货
+type Service struct {
+	db       *gorm.DB
+	options  Options
+	uploader EvidenceUploader
+	now      func() time.Time
+}
+
+// NewService 创建支付争议服务
+func NewService(db *gorm.DB, options Options) *Service {
+	return &Service{
+		db:      db,
+		options: options,
+		now:     time.Now,
+	}
+}
+
+// SetUploader 设置证据文件上传实现
+func (s *Service) SetUploader(uploader EvidenceUploader) {
+	s.uploader = uploader
+}
+
+// Create 人工登记支付争议
+func (s *Service) Create(req *model.CreatePaymentDisputeRequest, operatorID uint) (*model.PaymentDispute, error) {
+	payment, err := s.findPayment(req.PaymentNo)
+	if err != nil {
+		return nil, err
+	}
+
+	dispute := &model.PaymentDispute{
+		ChannelDisputeID: req.ChannelDisputeID,
+		Type:             req.Type,
+		Source:           model.PaymentDisputeSourceManual,
+		Amount:           req.Amount,
+		Reason:           req.Reason,
+		EvidenceDueAt:    req.EvidenceDueAt,
+		OperatorID:       operatorID,
+	}
+	if err := s.open(payment, dispute, s.now()); err != nil {
+		return nil, err
+	}
+
+	logger.Info("登记支付争议",
+		zap.String("dispute_no", dispute.DisputeNo),
+		zap.String("payment_no", payment.PaymentNo),
+		zap.Uint("operator_id", operatorID))
+	return dispute, nil
+}
+
+// OpenFromChannel 根据渠道通知创建支付争议
+// 渠道重复通知同一争议时返回已有记录，created 为false
+func (s *Service) OpenFromChannel(notice *ChannelDispute) (dispute *model.PaymentDispute, created bool, err error) {
+	payment, err := s.findPayment(notice.OutTradeNo)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var existing model.PaymentDispute
+	err = s.db.Where("channel = ? AND channel_dispute_id = ? AND payment_id = ?", notice.Channel, notice.ChannelDisputeID, payment.ID).
+		First(&existing).Error
+	if err == nil {
+		return &existing, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, fmt.Errorf("查询支付争议失败: %v", err)
+	}
+
+	openedAt := s.now()
+	if notice.OpenedAt != nil {
+		openedAt = *notice.OpenedAt
+	}
+	amount := notice.Amount
+	if amount.GreaterThan(payment.Amount) {
+		amount = payment.Amount
+	}
+
+	dispute = &model.PaymentDispute{
+		ChannelDisputeID: notice.ChannelDisputeID,
+		Type:             notice.Type,
+		Source:           model.PaymentDisputeSourceChannel,
+		Amount:           amount,
+		Reason:           truncate(notice.Reason, 500),
+	}
+	if err := s.open(payment, dispute, openedAt); err != nil {
+		return nil, false, err
+	}
+
+	logger.Info("渠道通知创建支付争议",
+		zap.String("dispute_no", dispute.DisputeNo),
+		zap.String("channel", string(notice.Channel)),
+		zap.String("channel_dispute_id", notice.ChannelDisputeID),
+		zap.String("payment_no", payment.PaymentNo))
+	return dispute, true, nil
+}
+
+// open 校验支付记录并创建争议，同一支付同时只能有一个未结案的争议
+func (s *Service) open(payment *model.Payment, dispute *model.PaymentDispute, openedAt time.Time) error {
+	if payment.PaymentStatus != model.PaymentStatusSuccess && payment.PaymentStatus != model.PaymentStatusPaid {
+		return model.ErrPaymentNotDisputable
+	}
+	if dispute.Amount.IsZero() {
+		dispute.Amount = payment.Amount
+	}
+	if !dispute.Amount.IsPositive() || dispute.Amount.GreaterThan(payment.Amount) {
+		return model.ErrInvalidDisputeAmount
+	}
+	if dispute.EvidenceDueAt == nil && s.options.EvidenceWindow > 0 {
+		dueAt := openedAt.Add(s.options.EvidenceWindow)
+		dispute.EvidenceDueAt = &dueAt
+	}
+
+	dispute.DisputeNo = generateDisputeNo(payment.ID)
+	dispute.PaymentID = payment.ID
+	dispute.PaymentNo = payment.PaymentNo
+	dispute.OrderID = payment.OrderID
+	dispute.UserID = payment.UserID
+	dispute.Channel = payment.PaymentMethod
+	dispute.Status = model.PaymentDisputeOpen
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.PaymentDispute{}).
+			Where("payment_id = ? AND status IN ?", payment.ID, model.OpenPaymentDisputeStatuses).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("查询支付争议失败: %v", err)
+		}
+		if count > 0 {
+			return model.ErrPaymentDisputeExists
+		}
+		if err := tx.Create(dispute).Error; err != nil {
+			return fmt.Errorf("创建支付争议失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// AddEvidence 上传争议证据，文件通过上传服务保存
+func (s *Service) AddEvidence(id, uploaderID uint, filename string, reader io.Reader, size int64, description string) (*model.PaymentDisputeEvidence, error) {
+	dispute, err := s.find(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.Status != model.PaymentDisputeOpen {
+		return nil, model.ErrPaymentDisputeClosed
+	}
+	if dispute.IsOverdue(s.now()) {
+		return nil, model.ErrDisputeEvidenceOverdue
+	}
+	if s.uploader == nil {
+		return nil, fmt.Errorf("证据上传服务未初始化")
+	}
+
+	file, err := s.uploader.UploadFile(&upload.UploadFileRequest{
+		UserID:      uploaderID,
+		Filename:    filename,
+		Reader:      reader,
+		Size:        size,
+		Category:    string(model.BusinessTypeDispute),
+		Description: description,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("上传争议证据失败: %v", err)
+	}
+
+	evidence := &model.PaymentDisputeEvidence{
+		DisputeID:   dispute.ID,
+		FileID:      file.FileID,
+		FileName:    truncate(file.OriginalName, 255),
+		FileURL:     file.URL,
+		Description: description,
+		UploaderID:  uploaderID,
+	}
+	if err := s.db.Create(evidence).Error; err != nil {
+		return nil, fmt.Errorf("保存争议证据失败: %v", err)
+	}
+	return evidence, nil
+}
+
+// SubmitEvidence 提交证据，争议进入等待渠道裁决状态
+func (s *Service) SubmitEvidence(id, operatorID uint) (*model.PaymentDispute, error) {
+	var dispute *model.PaymentDispute
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if dispute, err = s.find(tx, id); err != nil {
+			return err
+		}
+		if dispute.Status != model.PaymentDisputeOpen {
+			return model.ErrPaymentDisputeClosed
+		}
+		now := s.now()
+		if dispute.IsOverdue(now) {
+			return model.ErrDisputeEvidenceOverdue
+		}
+
+		var count int64
+		if err := tx.Model(&model.PaymentDisputeEvidence{}).Where("dispute_id = ?", id).Count(&count).Error; err != nil {
+			return fmt.Errorf("查询争议证据失败: %v", err)
+		}
+		if count == 0 {
+			return model.ErrDisputeEvidenceRequired
+		}
+
+		dispute.Status = model.PaymentDisputeSubmitted
+		dispute.SubmittedAt = &now
+		dispute.OperatorID = operatorID
+		return s.update(tx, dispute, "status", "submitted_at", "operator_id")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dispute, nil
+}
+
+// Resolve 登记渠道裁决结果
+// 败诉的争议由结算服务在商家下一期结算单中冲回，订单禁止继续发货
+func (s *Service) Resolve(id uint, req *model.ResolvePaymentDisputeRequest, operatorID uint) (*model.PaymentDispute, error) {
+	if req.Outcome != model.PaymentDisputeWon && req.Outcome != model.PaymentDisputeLost {
+		return nil, fmt.Errorf("无效的裁决结果: %s", req.Outcome)
+	}
+
+	var dispute *model.PaymentDispute
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if dispute, err = s.find(tx, id); err != nil {
+			return err
+		}
+		if dispute.Status.IsClosed() {
+			return model.ErrPaymentDisputeClosed
+		}
+
+		now := s.now()
+		dispute.Status = req.Outcome
+		dispute.ResolvedAt = &now
+		dispute.ResolutionRemark = req.Remark
+		dispute.OperatorID = operatorID
+		return s.update(tx, dispute, "status", "resolved_at", "resolution_remark", "operator_id")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("支付争议结案",
+		zap.String("dispute_no", dispute.DisputeNo),
+		zap.String("outcome", string(dispute.Status)),
+		zap.Uint("order_id", dispute.OrderID),
+		zap.String("amount", dispute.Amount.StringFixed(2)),
+		zap.Uint("operator_id", operatorID))
+	return dispute, nil
+}
+
+// Get 查询争议详情（含证据）
+func (s *Service) Get(id uint) (*model.PaymentDispute, error) {
+	var dispute model.PaymentDispute
+	err := s.db.Preload("Evidence", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&dispute, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrPaymentDisputeNotFound
+		}
+		return nil, fmt.Errorf("查询支付争议失败: %v", err)
+	}
+	return &dispute, nil
+}
+
+// List 分页查询支付争议，指定 DueBefore 时只返回证据截止时间早于该时间的未结案争议
+func (s *Service) List(query *model.PaymentDisputeQuery) ([]model.PaymentDispute, int64, error) {
+	query.Page, query.PageSize = pagination.Normalize(query.Page, query.PageSize)
+
+	db := s.db.Model(&model.PaymentDispute{})
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.Channel != "" {
+		db = db.Where("channel = ?", query.Channel)
+	}
+	if query.OrderID > 0 {
+		db = db.Where("order_id = ?", query.OrderID)
+	}
+	if query.DueBefore != nil {
+		db = db.Where("status IN ? AND evidence_due_at <= ?", model.OpenPaymentDisputeStatuses, *query.DueBefore)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计支付争议失败: %v", err)
+	}
+
+	var disputes []model.PaymentDispute
+	if err := db.Order("id DESC").Offset((query.Page - 1) * query.PageSize).Limit(query.PageSize).Find(&disputes).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询支付争议失败: %v", err)
+	}
+	return disputes, total, nil
+}
+
+// findPayment 按支付单号查询支付记录
+func (s *Service) findPayment(paymentNo string) (*model.Payment, error) {
+	var payment model.Payment
+	if err := s.db.Where("payment_no = ?", paymentNo).First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrPaymentNotFound
+		}
+		return nil, fmt.Errorf("查询支付记录失败: %v", err)
+	}
+	return &payment, nil
+}
+
+// find 查询争议
+func (s *Service) find(tx *gorm.DB, id uint) (*model.PaymentDispute, error) {
+	var dispute model.PaymentDispute
+	if err := tx.First(&dispute, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrPaymentDisputeNotFound
+		}
+		return nil, fmt.Errorf("查询支付争议失败: %v", err)
+	}
+	return &dispute, nil
+}
+
+// update 按字段更新争议
+func (s *Service) update(tx *gorm.DB, dispute *model.PaymentDispute, columns ...string) error {
+	if err := tx.Model(dispute).Select(columns).Updates(dispute).Error; err != nil {
+		return fmt.Errorf("更新支付争议失败: %v", err)
+	}
+	return nil
+}
+
+// generateDisputeNo 生成争议单号
+func generateDisputeNo(paymentID uint) string {
+	return fmt.Sprintf("DP%d%04d", time.Now().UnixNano(), paymentID%10000)
+}
+
+// truncate 按字符截断
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) <= length {
+		return value
+	}
+	return string(runes[:length])
+}
@@ -0,0 +1,231 @@
+package dispute
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/upload"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// fakeUploader 记录上传内容的证据上传实现
+type fakeUploader struct {
+	uploads []*upload.UploadFileRequest
+}
+
+func (u *fakeUploader) UploadFile(req *upload.UploadFileRequest) (*upload.UploadFileResponse, error) {
+	if _, err := io.ReadAll(req.Reader); err != nil {
+		return nil, err
+	}
+	u.uploads = append(u.uploads, req)
+	return &upload.UploadFileResponse{
+		FileID:       uint(len(u.uploads)),
+		OriginalName: req.Filename,
+		URL:          "/uploads/dispute/" + req.Filename,
+	}, nil
+}
+
+// DisputeServiceTestSuite 支付争议服务测试套件
+type DisputeServiceTestSuite struct {
+	suite.Suite
+	db       *gorm.DB
+	service  *Service
+	uploader *fakeUploader
+}
+
+// SetupTest 每个用例使用独立的内存数据库和证据上传记录
+func (suite *DisputeServiceTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.AutoMigrate(&model.Payment{}, &model.PaymentDispute{}, &model.PaymentDisputeEvidence{}))
+
+	suite.db = db
+	suite.uploader = &fakeUploader{}
+	suite.service = NewService(db, DefaultOptions())
+	suite.service.SetUploader(suite.uploader)
+}
+
+func (suite *DisputeServiceTestSuite) createPayment(paymentNo string, orderID uint, amount string, status model.PaymentStatus) *model.Payment {
+	payment := &model.Payment{
+		PaymentNo:     paymentNo,
+		OrderID:       orderID,
+		UserID:        1,
+		PaymentMethod: model.PaymentMethodWechat,
+		PaymentStatus: status,
+		Amount:        decimal.RequireFromString(amount),
+	}
+	suite.Require().NoError(suite.db.Create(payment).Error)
+	return payment
+}
+
+func (suite *DisputeServiceTestSuite) TestService_Create() {
+	suite.createPayment("PAY001", 1, "100.00", model.PaymentStatusSuccess)
+	suite.createPayment("PAY002", 2, "50.00", model.PaymentStatusPending)
+
+	req := &model.CreatePaymentDisputeRequest{
+		PaymentNo: "PAY001",
+		Type:      model.PaymentDisputeChargeback,
+		Reason:    "持卡人否认交易",
+	}
+	dispute, err := suite.service.Create(req, 9)
+	suite.Require().NoError(err)
+	suite.Equal(model.PaymentDisputeOpen, dispute.Status)
+	suite.Equal(model.PaymentDisputeSourceManual, dispute.Source)
+	suite.True(dispute.Amount.Equal(decimal.RequireFromString("100")), "金额为0时取支付金额")
+	suite.Equal(uint(1), dispute.OrderID)
+	suite.Require().NotNil(dispute.EvidenceDueAt)
+	suite.WithinDuration(time.Now().Add(7*24*time.Hour), *dispute.EvidenceDueAt, time.Minute)
+
+	// 同一支付只能有一个未结案的争议
+	_, err = suite.service.Create(req, 9)
+	suite.ErrorIs(err, model.ErrPaymentDisputeExists)
+
+	_, err = suite.service.Create(&model.CreatePaymentDisputeRequest{PaymentNo: "PAY002", Type: model.PaymentDisputeChargeback}, 9)
+	suite.ErrorIs(err, model.ErrPaymentNotDisputable)
+
+	_, err = suite.service.Create(&model.CreatePaymentDisputeRequest{PaymentNo: "PAY404", Type: model.PaymentDisputeChargeback}, 9)
+	suite.ErrorIs(err, model.ErrPaymentNotFound)
+
+	suite.createPayment("PAY003", 3, "30.00", model.PaymentStatusSuccess)
+	_, err = suite.service.Create(&model.CreatePaymentDisputeRequest{
+		PaymentNo: "PAY003",
+		Type:      model.PaymentDisputeChargeback,
+		Amount:    decimal.RequireFromString("30.01"),
+	}, 9)
+	suite.ErrorIs(err, model.ErrInvalidDisputeAmount)
+}
+
+func (suite *DisputeServiceTestSuite) TestService_OpenFromChannel() {
+	suite.createPayment("PAY001", 1, "100.00", model.PaymentStatusSuccess)
+
+	openedAt := time.Now().Add(-time.Hour)
+	notice := &ChannelDispute{
+		Channel:          model.PaymentMethodWechat,
+		ChannelDisputeID: "200000020240101000001",
+		Type:             model.PaymentDisputeComplaint,
+		OutTradeNo:       "PAY001",
+		Amount:           decimal.RequireFromString("120.00"),
+		Reason:           "商品未收到",
+		OpenedAt:         &openedAt,
+	}
+	dispute, created, err := suite.service.OpenFromChannel(notice)
+	suite.Require().NoError(err)
+	suite.True(created)
+	suite.Equal(model.PaymentDisputeSourceChannel, dispute.Source)
+	suite.True(dispute.Amount.Equal(decimal.RequireFromString("100")), "争议金额不超过支付金额")
+	suite.WithinDuration(openedAt.Add(7*24*time.Hour), *dispute.EvidenceDueAt, time.Second)
+
+	// 重复通知返回已有争议
+	again, created, err := suite.service.OpenFromChannel(notice)
+	suite.Require().NoError(err)
+	suite.False(created)
+	suite.Equal(dispute.ID, again.ID)
+
+	var count int64
+	suite.Require().NoError(suite.db.Model(&model.PaymentDispute{}).Count(&count).Error)
+	suite.Equal(int64(1), count)
+}
+
+func (suite *DisputeServiceTestSuite) TestService_EvidenceAndResolve() {
+	suite.createPayment("PAY001", 1, "100.00", model.PaymentStatusSuccess)
+
+	dispute, err := suite.service.Create(&model.CreatePaymentDisputeRequest{
+		PaymentNo: "PAY001",
+		Type:      model.PaymentDisputeChargeback,
+		Reason:    "持卡人否认交易",
+	}, 9)
+	suite.Require().NoError(err)
+
+	// 未上传证据不能提交
+	_, err = suite.service.SubmitEvidence(dispute.ID, 9)
+	suite.ErrorIs(err, model.ErrDisputeEvidenceRequired)
+
+	evidence, err := suite.service.AddEvidence(dispute.ID, 9, "签收单.jpg", strings.NewReader("image"), 5, "快递签收记录")
+	suite.Require().NoError(err)
+	suite.Equal(uint(1), evidence.FileID)
+	suite.Equal("/uploads/dispute/签收单.jpg", evidence.FileURL)
+	suite.Require().Len(suite.uploader.uploads, 1)
+	suite.Equal(string(model.BusinessTypeDispute), suite.uploader.uploads[0].Category)
+
+	submitted, err := suite.service.SubmitEvidence(dispute.ID, 9)
+	suite.Require().NoError(err)
+	suite.Equal(model.PaymentDisputeSubmitted, submitted.Status)
+	suite.Require().NotNil(submitted.SubmittedAt)
+
+	// 已提交的争议不能再上传证据
+	_, err = suite.service.AddEvidence(dispute.ID, 9, "补充.pdf", strings.NewReader("pdf"), 3, "")
+	suite.ErrorIs(err, model.ErrPaymentDisputeClosed)
+
+	resolved, err := suite.service.Resolve(dispute.ID, &model.ResolvePaymentDisputeRequest{
+		Outcome: model.PaymentDisputeLost,
+		Remark:  "发卡行裁定拒付成立",
+	}, 9)
+	suite.Require().NoError(err)
+	suite.Equal(model.PaymentDisputeLost, resolved.Status)
+	suite.Require().NotNil(resolved.ResolvedAt)
+
+	_, err = suite.service.Resolve(dispute.ID, &model.ResolvePaymentDisputeRequest{Outcome: model.PaymentDisputeWon}, 9)
+	suite.ErrorIs(err, model.ErrPaymentDisputeClosed)
+
+	detail, err := suite.service.Get(dispute.ID)
+	suite.Require().NoError(err)
+	suite.Len(detail.Evidence, 1)
+
+	// 结案后可再次登记新的争议
+	_, err = suite.service.Create(&model.CreatePaymentDisputeRequest{
+		PaymentNo: "PAY001",
+		Type:      model.PaymentDisputeComplaint,
+		Amount:    decimal.RequireFromString("10"),
+	}, 9)
+	suite.NoError(err)
+
+	_, err = suite.service.Get(999)
+	suite.ErrorIs(err, model.ErrPaymentDisputeNotFound)
+}
+
+func (suite *DisputeServiceTestSuite) TestService_OverdueAndList() {
+	suite.createPayment("PAY001", 1, "100.00", model.PaymentStatusSuccess)
+	suite.createPayment("PAY002", 2, "80.00", model.PaymentStatusSuccess)
+
+	dueSoon := time.Now().Add(time.Hour)
+	first, err := suite.service.Create(&model.CreatePaymentDisputeRequest{
+		PaymentNo:     "PAY001",
+		Type:          model.PaymentDisputeChargeback,
+		EvidenceDueAt: &dueSoon,
+	}, 9)
+	suite.Require().NoError(err)
+	_, err = suite.service.Create(&model.CreatePaymentDisputeRequest{
+		PaymentNo: "PAY002",
+		Type:      model.PaymentDisputeChargeback,
+	}, 9)
+	suite.Require().NoError(err)
+
+	dueBefore := time.Now().Add(24 * time.Hour)
+	disputes, total, err := suite.service.List(&model.PaymentDisputeQuery{DueBefore: &dueBefore})
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), total)
+	suite.Equal(first.ID, disputes[0].ID)
+
+	disputes, total, err = suite.service.List(&model.PaymentDisputeQuery{OrderID: 2})
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), total)
+	suite.Equal("PAY002", disputes[0].PaymentNo)
+
+	// 超过截止时间后不能再上传或提交证据
+	suite.service.now = func() time.Time { return dueSoon.Add(time.Minute) }
+	_, err = suite.service.AddEvidence(first.ID, 9, "a.jpg", strings.NewReader("a"), 1, "")
+	suite.ErrorIs(err, model.ErrDisputeEvidenceOverdue)
+	_, err = suite.service.SubmitEvidence(first.ID, 9)
+	suite.ErrorIs(err, model.ErrDisputeEvidenceOverdue)
+}
+
+func TestDisputeServiceSuite(t *testing.T) {
+	suite.Run(t, new(DisputeServiceTestSuite))
+}
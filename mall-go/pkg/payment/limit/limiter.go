@@ -0,0 +1,301 @@
+package limit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/currency"
+	"mall-go/pkg/logger"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 计数器与预占记录有效期，覆盖整个统计周期
+const (
+	dailyCounterTTL   = 48 * time.Hour
+	monthlyCounterTTL = 32 * 24 * time.Hour
+	reservationTTL    = 35 * 24 * time.Hour
+	storeTimeout      = 2 * time.Second
+)
+
+// scopeAll 全部支付方式合计的计数器范围
+const scopeAll = "all"
+
+// periods 统计周期
+var periods = []model.PaymentLimitPeriod{model.PaymentLimitDaily, model.PaymentLimitMonthly}
+
+// Limit 累计限额，金额或笔数为0表示不限制
+type Limit struct {
+	MaxAmount decimal.Decimal
+	MaxCount  int
+}
+
+// Defaults 默认累计限额，来自支付配置
+type Defaults struct {
+	Total   map[model.PaymentLimitPeriod]Limit                         // 单用户全部支付方式合计
+	Methods map[model.PaymentMethod]map[model.PaymentLimitPeriod]Limit // 单用户单个支付方式
+}
+
+// DefaultsFunc 获取当前默认限额，支付配置更新后立即生效
+type DefaultsFunc func() Defaults
+
+// Limiter 用户累计支付限额
+// 按用户、支付方式和自然日/自然月统计已发起的支付金额与笔数，创建支付时原子预占额度，
+// 支付失败、取消或过期时释放；支付成功的额度保留至周期结束
+type Limiter struct {
+	db       *gorm.DB
+	store    Store
+	defaults DefaultsFunc
+	now      func() time.Time
+}
+
+// NewLimiter 创建累计限额校验器
+func NewLimiter(db *gorm.DB, store Store, defaults DefaultsFunc) *Limiter {
+	return &Limiter{
+		db:       db,
+		store:    store,
+		defaults: defaults,
+		now:      time.Now,
+	}
+}
+
+// scopedLimit 某个范围与周期的生效限额
+type scopedLimit struct {
+	method     model.PaymentMethod
+	period     model.PaymentLimitPeriod
+	limit      Limit
+	overridden bool
+}
+
+// Reserve 校验并预占用户的累计额度，reservationID 通常为支付单号，重复调用只预占一次
+func (l *Limiter) Reserve(userID uint, method model.PaymentMethod, amount decimal.Decimal, reservationID string) error {
+	now := l.now()
+	limits, err := l.effectiveLimits(userID, []model.PaymentMethod{"", method}, now)
+	if err != nil {
+		return err
+	}
+
+	checks := make([]Check, len(limits))
+	for i, limit := range limits {
+		checks[i] = Check{
+			Key:       counterKey(userID, scopeOf(limit.method), string(limit.period), periodKey(limit.period, now)),
+			MaxAmount: toMinor(limit.limit.MaxAmount),
+			MaxCount:  int64(limit.limit.MaxCount),
+			TTL:       counterTTL(limit.period),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	violated, err := l.store.Reserve(ctx, reservationKey(userID, reservationID), toMinor(amount), checks, reservationTTL)
+	if err != nil {
+		return fmt.Errorf("校验累计支付限额失败: %v", err)
+	}
+	if violated >= 0 && violated < len(limits) {
+		limit := limits[violated]
+		logger.Warn("超出累计支付限额",
+			zap.Uint("user_id", userID),
+			zap.String("payment_method", string(limit.method)),
+			zap.String("period", string(limit.period)),
+			zap.String("amount", amount.StringFixed(2)))
+		return &model.PaymentLimitExceededError{
+			PaymentMethod: limit.method,
+			Period:        limit.period,
+			MaxAmount:     limit.limit.MaxAmount,
+			MaxCount:      limit.limit.MaxCount,
+		}
+	}
+	return nil
+}
+
+// Release 释放预占的额度，只生效一次
+func (l *Limiter) Release(userID uint, reservationID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	released, err := l.store.Release(ctx, reservationKey(userID, reservationID))
+	if err != nil {
+		return fmt.Errorf("释放累计支付限额失败: %v", err)
+	}
+	if released {
+		logger.Info("释放累计支付限额", zap.Uint("user_id", userID), zap.String("reservation_id", reservationID))
+	}
+	return nil
+}
+
+// Usage 查询用户本日、本月的累计使用情况与生效限额
+func (l *Limiter) Usage(userID uint) ([]model.PaymentLimitUsage, error) {
+	now := l.now()
+
+	methods := map[model.PaymentMethod]bool{"": true}
+	for method := range l.defaults().Methods {
+		methods[method] = true
+	}
+	overrides, err := l.ListOverrides(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, override := range overrides {
+		methods[override.PaymentMethod] = true
+	}
+	scopes := make([]model.PaymentMethod, 0, len(methods))
+	for method := range methods {
+		scopes = append(scopes, method)
+	}
+	sort.Slice(scopes, func(i, j int) bool { return scopes[i] < scopes[j] })
+
+	limits, err := l.effectiveLimits(userID, scopes, now)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(limits))
+	for i, limit := range limits {
+		keys[i] = counterKey(userID, scopeOf(limit.method), string(limit.period), periodKey(limit.period, now))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	counters, err := l.store.Get(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("查询累计支付额度失败: %v", err)
+	}
+
+	usage := make([]model.PaymentLimitUsage, len(limits))
+	for i, limit := range limits {
+		usage[i] = model.PaymentLimitUsage{
+			PaymentMethod: limit.method,
+			Period:        limit.period,
+			PeriodKey:     periodKey(limit.period, now),
+			UsedAmount:    currency.FromMinorUnits(counters[i].Amount, model.BaseCurrency),
+			UsedCount:     counters[i].Count,
+			MaxAmount:     limit.limit.MaxAmount,
+			MaxCount:      limit.limit.MaxCount,
+			Overridden:    limit.overridden,
+		}
+	}
+	return usage, nil
+}
+
+// effectiveLimits 计算各范围、各周期的生效限额，用户覆盖配置优先于默认配置
+func (l *Limiter) effectiveLimits(userID uint, methods []model.PaymentMethod, now time.Time) ([]scopedLimit, error) {
+	var overrides []model.PaymentUserLimit
+	if err := l.db.Where("user_id = ? AND payment_method IN ?", userID, methods).Find(&overrides).Error; err != nil {
+		return nil, fmt.Errorf("查询用户支付限额失败: %v", err)
+	}
+	overridden := make(map[model.PaymentMethod]map[model.PaymentLimitPeriod]Limit)
+	for _, override := range overrides {
+		if !override.IsActive(now) {
+			continue
+		}
+		if overridden[override.PaymentMethod] == nil {
+			overridden[override.PaymentMethod] = make(map[model.PaymentLimitPeriod]Limit)
+		}
+		overridden[override.PaymentMethod][override.Period] = Limit{MaxAmount: override.MaxAmount, MaxCount: override.MaxCount}
+	}
+
+	defaults := l.defaults()
+	limits := make([]scopedLimit, 0, len(methods)*len(periods))
+	for _, method := range methods {
+		for _, period := range periods {
+			limit := scopedLimit{method: method, period: period}
+			if value, ok := overridden[method][period]; ok {
+				limit.limit = value
+				limit.overridden = true
+			} else if method == "" {
+				limit.limit = defaults.Total[period]
+			} else {
+				limit.limit = defaults.Methods[method][period]
+			}
+			limits = append(limits, limit)
+		}
+	}
+	return limits, nil
+}
+
+// ListOverrides 查询用户的限额覆盖配置
+func (l *Limiter) ListOverrides(userID uint) ([]model.PaymentUserLimit, error) {
+	var overrides []model.PaymentUserLimit
+	if err := l.db.Where("user_id = ?", userID).Order("payment_method ASC, period ASC").Find(&overrides).Error; err != nil {
+		return nil, fmt.Errorf("查询用户支付限额失败: %v", err)
+	}
+	return overrides, nil
+}
+
+// SaveOverride 按支付方式与周期新增或更新用户限额，保存后立即生效
+func (l *Limiter) SaveOverride(userID uint, req *model.PaymentUserLimitRequest, operatorID uint) (*model.PaymentUserLimit, error) {
+	if req.MaxAmount.IsNegative() || req.MaxCount < 0 {
+		return nil, model.ErrInvalidPaymentLimit
+	}
+
+	var override model.PaymentUserLimit
+	err := l.db.Where("user_id = ? AND payment_method = ? AND period = ?", userID, req.PaymentMethod, req.Period).First(&override).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("查询用户支付限额失败: %v", err)
+	}
+	if err == gorm.ErrRecordNotFound {
+		override = model.PaymentUserLimit{UserID: userID, PaymentMethod: req.PaymentMethod, Period: req.Period}
+	}
+
+	override.MaxAmount = req.MaxAmount
+	override.MaxCount = req.MaxCount
+	override.ExpiresAt = req.ExpiresAt
+	override.Reason = req.Reason
+	override.OperatorID = operatorID
+	if err := l.db.Save(&override).Error; err != nil {
+		return nil, fmt.Errorf("保存用户支付限额失败: %v", err)
+	}
+
+	logger.Info("调整用户支付限额",
+		zap.Uint("user_id", userID),
+		zap.String("payment_method", string(req.PaymentMethod)),
+		zap.String("period", string(req.Period)),
+		zap.String("max_amount", req.MaxAmount.StringFixed(2)),
+		zap.Int("max_count", req.MaxCount),
+		zap.Uint("operator_id", operatorID))
+	return &override, nil
+}
+
+// DeleteOverride 删除用户限额覆盖配置，恢复默认限额
+func (l *Limiter) DeleteOverride(id uint) error {
+	result := l.db.Delete(&model.PaymentUserLimit{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除用户支付限额失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrPaymentUserLimitNotFound
+	}
+	return nil
+}
+
+// scopeOf 计数器范围
+func scopeOf(method model.PaymentMethod) string {
+	if method == "" {
+		return scopeAll
+	}
+	return string(method)
+}
+
+// periodKey 统计周期标识
+func periodKey(period model.PaymentLimitPeriod, now time.Time) string {
+	if period == model.PaymentLimitMonthly {
+		return now.Format("200601")
+	}
+	return now.Format("20060102")
+}
+
+// counterTTL 计数器有效期
+func counterTTL(period model.PaymentLimitPeriod) time.Duration {
+	if period == model.PaymentLimitMonthly {
+		return monthlyCounterTTL
+	}
+	return dailyCounterTTL
+}
+
+// toMinor 结算币种金额转换为最小货币单位
+func toMinor(amount decimal.Decimal) int64 {
+	return currency.ToMinorUnits(amount, model.BaseCurrency)
+}
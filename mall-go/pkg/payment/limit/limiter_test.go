@@ -0,0 +1,179 @@
+package limit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupLimiter(t *testing.T, defaults Defaults) *Limiter {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.PaymentUserLimit{}))
+
+	return NewLimiter(db, NewMemoryStore(), func() Defaults { return defaults })
+}
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func TestReserveDailyAmountLimit(t *testing.T) {
+	limiter := setupLimiter(t, Defaults{
+		Total: map[model.PaymentLimitPeriod]Limit{
+			model.PaymentLimitDaily: {MaxAmount: amount("1000")},
+		},
+	})
+
+	require.NoError(t, limiter.Reserve(1, model.PaymentMethodAlipay, amount("600"), "P1"))
+	require.NoError(t, limiter.Reserve(1, model.PaymentMethodWechat, amount("400"), "P2"))
+
+	err := limiter.Reserve(1, model.PaymentMethodAlipay, amount("0.01"), "P3")
+	var exceeded *model.PaymentLimitExceededError
+	require.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, model.PaymentMethod(""), exceeded.PaymentMethod)
+	assert.Equal(t, model.PaymentLimitDaily, exceeded.Period)
+
+	// 其他用户不受影响
+	assert.NoError(t, limiter.Reserve(2, model.PaymentMethodAlipay, amount("1000"), "P4"))
+}
+
+func TestReserveMethodCountLimit(t *testing.T) {
+	limiter := setupLimiter(t, Defaults{
+		Methods: map[model.PaymentMethod]map[model.PaymentLimitPeriod]Limit{
+			model.PaymentMethodAlipay: {model.PaymentLimitMonthly: {MaxCount: 2}},
+		},
+	})
+
+	require.NoError(t, limiter.Reserve(1, model.PaymentMethodAlipay, amount("10"), "P1"))
+	require.NoError(t, limiter.Reserve(1, model.PaymentMethodAlipay, amount("10"), "P2"))
+	// 同一支付单重复预占只计一次
+	require.NoError(t, limiter.Reserve(1, model.PaymentMethodAlipay, amount("10"), "P2"))
+
+	err := limiter.Reserve(1, model.PaymentMethodAlipay, amount("10"), "P3")
+	var exceeded *model.PaymentLimitExceededError
+	require.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, model.PaymentMethodAlipay, exceeded.PaymentMethod)
+	assert.Equal(t, model.PaymentLimitMonthly, exceeded.Period)
+	assert.Equal(t, 2, exceeded.MaxCount)
+
+	// 其他支付方式不受该限额约束
+	assert.NoError(t, limiter.Reserve(1, model.PaymentMethodWechat, amount("10"), "P4"))
+}
+
+func TestReleaseRestoresQuotaOnce(t *testing.T) {
+	limiter := setupLimiter(t, Defaults{
+		Total: map[model.PaymentLimitPeriod]Limit{
+			model.PaymentLimitDaily: {MaxAmount: amount("100"), MaxCount: 1},
+		},
+	})
+
+	require.NoError(t, limiter.Reserve(1, model.PaymentMethodAlipay, amount("80"), "P1"))
+	require.Error(t, limiter.Reserve(1, model.PaymentMethodAlipay, amount("10"), "P2"))
+
+	require.NoError(t, limiter.Release(1, "P1"))
+	require.NoError(t, limiter.Release(1, "P1"))
+	// 重复释放不会使计数器变为负数
+	usage, err := limiter.Usage(1)
+	require.NoError(t, err)
+	for _, item := range usage {
+		assert.True(t, item.UsedAmount.IsZero())
+		assert.Zero(t, item.UsedCount)
+	}
+
+	assert.NoError(t, limiter.Reserve(1, model.PaymentMethodAlipay, amount("100"), "P2"))
+}
+
+func TestOverrideTakesPriority(t *testing.T) {
+	limiter := setupLimiter(t, Defaults{
+		Total: map[model.PaymentLimitPeriod]Limit{
+			model.PaymentLimitDaily: {MaxAmount: amount("100")},
+		},
+	})
+
+	_, err := limiter.SaveOverride(1, &model.PaymentUserLimitRequest{
+		Period:    model.PaymentLimitDaily,
+		MaxAmount: amount("500"),
+		Reason:    "大客户临时提额",
+	}, 99)
+	require.NoError(t, err)
+
+	require.NoError(t, limiter.Reserve(1, model.PaymentMethodAlipay, amount("300"), "P1"))
+	require.Error(t, limiter.Reserve(2, model.PaymentMethodAlipay, amount("300"), "P2"))
+
+	// 已过期的覆盖配置不再生效
+	expired := time.Now().Add(-time.Hour)
+	_, err = limiter.SaveOverride(2, &model.PaymentUserLimitRequest{
+		Period:    model.PaymentLimitDaily,
+		MaxAmount: amount("500"),
+		ExpiresAt: &expired,
+	}, 99)
+	require.NoError(t, err)
+	assert.Error(t, limiter.Reserve(2, model.PaymentMethodAlipay, amount("300"), "P3"))
+
+	_, err = limiter.SaveOverride(1, &model.PaymentUserLimitRequest{
+		Period:    model.PaymentLimitDaily,
+		MaxAmount: amount("-1"),
+	}, 99)
+	assert.ErrorIs(t, err, model.ErrInvalidPaymentLimit)
+}
+
+func TestUsage(t *testing.T) {
+	limiter := setupLimiter(t, Defaults{
+		Total: map[model.PaymentLimitPeriod]Limit{
+			model.PaymentLimitDaily:   {MaxAmount: amount("1000")},
+			model.PaymentLimitMonthly: {MaxAmount: amount("5000")},
+		},
+		Methods: map[model.PaymentMethod]map[model.PaymentLimitPeriod]Limit{
+			model.PaymentMethodAlipay: {model.PaymentLimitDaily: {MaxCount: 10}},
+		},
+	})
+	override, err := limiter.SaveOverride(1, &model.PaymentUserLimitRequest{
+		PaymentMethod: model.PaymentMethodWechat,
+		Period:        model.PaymentLimitDaily,
+		MaxAmount:     amount("200"),
+	}, 99)
+	require.NoError(t, err)
+
+	require.NoError(t, limiter.Reserve(1, model.PaymentMethodAlipay, amount("12.34"), "P1"))
+	require.NoError(t, limiter.Reserve(1, model.PaymentMethodWechat, amount("50"), "P2"))
+
+	usage, err := limiter.Usage(1)
+	require.NoError(t, err)
+	// 全部、支付宝、微信 × 日、月
+	require.Len(t, usage, 6)
+
+	find := func(method model.PaymentMethod, period model.PaymentLimitPeriod) model.PaymentLimitUsage {
+		for _, item := range usage {
+			if item.PaymentMethod == method && item.Period == period {
+				return item
+			}
+		}
+		t.Fatalf("missing usage %s/%s", method, period)
+		return model.PaymentLimitUsage{}
+	}
+
+	total := find("", model.PaymentLimitDaily)
+	assert.True(t, total.UsedAmount.Equal(amount("62.34")))
+	assert.Equal(t, int64(2), total.UsedCount)
+	assert.True(t, total.MaxAmount.Equal(amount("1000")))
+
+	alipay := find(model.PaymentMethodAlipay, model.PaymentLimitDaily)
+	assert.True(t, alipay.UsedAmount.Equal(amount("12.34")))
+	assert.Equal(t, 10, alipay.MaxCount)
+
+	wechat := find(model.PaymentMethodWechat, model.PaymentLimitDaily)
+	assert.True(t, wechat.Overridden)
+	assert.True(t, wechat.MaxAmount.Equal(amount("200")))
+
+	require.NoError(t, limiter.DeleteOverride(override.ID))
+	assert.ErrorIs(t, limiter.DeleteOverride(override.ID), model.ErrPaymentUserLimitNotFound)
+}
@@ -0,0 +1,312 @@
+package limit
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Check 单个计数器的额度检查，金额以最小货币单位计
+type Check struct {
+	Key       string        // 计数器键
+	MaxAmount int64         // 金额上限，0表示不限制
+	MaxCount  int64         // 笔数上限，0表示不限制
+	TTL       time.Duration // 计数器过期时间
+}
+
+// Counter 计数器当前值
+type Counter struct {
+	Amount int64
+	Count  int64
+}
+
+// Store 累计限额计数器存储
+// Reserve 在全部检查通过后原子地累加所有计数器并记录预占，返回未通过检查的下标（从0开始），全部通过返回-1；
+// 同一预占重复调用视为已通过。Release 按预占记录回退计数器，只生效一次
+type Store interface {
+	Reserve(ctx context.Context, reservationKey string, amount int64, checks []Check, reservationTTL time.Duration) (int, error)
+	Release(ctx context.Context, reservationKey string) (bool, error)
+	Get(ctx context.Context, keys []string) ([]Counter, error)
+}
+
+// NewStore 创建计数器存储，Redis不可用时使用进程内存储
+// 进程内存储仅在单实例部署时准确
+func NewStore(rdb *redis.Client) Store {
+	if rdb == nil {
+		return NewMemoryStore()
+	}
+	return NewRedisStore(rdb)
+}
+
+// reserveScript 检查并累加计数器
+// KEYS[1] 预占记录，KEYS[2..] 计数器；ARGV[1] 预占有效期(秒)，ARGV[2] 金额，之后每个计数器依次为金额上限、笔数上限、有效期(秒)
+var reserveScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return -1
+end
+local amount = tonumber(ARGV[2])
+for i = 2, #KEYS do
+	local base = 3 + (i - 2) * 3
+	local maxAmount = tonumber(ARGV[base])
+	local maxCount = tonumber(ARGV[base + 1])
+	local used = redis.call('HMGET', KEYS[i], 'amount', 'count')
+	local usedAmount = tonumber(used[1]) or 0
+	local usedCount = tonumber(used[2]) or 0
+	if maxAmount > 0 and usedAmount + amount > maxAmount then
+		return i - 2
+	end
+	if maxCount > 0 and usedCount + 1 > maxCount then
+		return i - 2
+	end
+end
+for i = 2, #KEYS do
+	local ttl = tonumber(ARGV[3 + (i - 2) * 3 + 2])
+	redis.call('HINCRBY', KEYS[i], 'amount', amount)
+	redis.call('HINCRBY', KEYS[i], 'count', 1)
+	redis.call('EXPIRE', KEYS[i], ttl)
+end
+redis.call('SET', KEYS[1], table.concat(KEYS, ',', 2) .. '|' .. amount, 'EX', ARGV[1])
+return -1
+`)
+
+// releaseScript 按预占记录回退计数器，已过期的计数器不再回退
+var releaseScript = redis.NewScript(`
+local reserved = redis.call('GET', KEYS[1])
+if not reserved then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+local sep = string.find(reserved, '|', 1, true)
+local amount = tonumber(string.sub(reserved, sep + 1))
+for key in string.gmatch(string.sub(reserved, 1, sep - 1), '[^,]+') do
+	if redis.call('EXISTS', key) == 1 then
+		redis.call('HINCRBY', key, 'amount', -amount)
+		redis.call('HINCRBY', key, 'count', -1)
+	end
+end
+return 1
+`)
+
+// RedisStore 基于Redis Lua脚本的计数器存储，多实例部署时保证检查与累加的原子性
+// 同一用户的键使用相同的哈希标签，Redis Cluster下脚本涉及的键位于同一槽位
+type RedisStore struct {
+	rdb *redis.Client
+}
+
+// NewRedisStore 创建Redis计数器存储
+func NewRedisStore(rdb *redis.Client) *RedisStore {
+	return &RedisStore{rdb: rdb}
+}
+
+// Reserve 检查并预占额度
+func (s *RedisStore) Reserve(ctx context.Context, reservationKey string, amount int64, checks []Check, reservationTTL time.Duration) (int, error) {
+	keys := make([]string, 0, len(checks)+1)
+	args := make([]interface{}, 0, len(checks)*3+2)
+	keys = append(keys, reservationKey)
+	args = append(args, ttlSeconds(reservationTTL), amount)
+	for _, check := range checks {
+		keys = append(keys, check.Key)
+		args = append(args, check.MaxAmount, check.MaxCount, ttlSeconds(check.TTL))
+	}
+
+	violated, err := reserveScript.Run(ctx, s.rdb, keys, args...).Int()
+	if err != nil {
+		return -1, err
+	}
+	return violated, nil
+}
+
+// Release 释放预占额度
+func (s *RedisStore) Release(ctx context.Context, reservationKey string) (bool, error) {
+	released, err := releaseScript.Run(ctx, s.rdb, []string{reservationKey}).Int()
+	if err != nil {
+		return false, err
+	}
+	return released == 1, nil
+}
+
+// Get 查询计数器
+func (s *RedisStore) Get(ctx context.Context, keys []string) ([]Counter, error) {
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.HMGet(ctx, key, "amount", "count")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	counters := make([]Counter, len(keys))
+	for i, cmd := range cmds {
+		values := cmd.Val()
+		if len(values) == 2 {
+			counters[i] = Counter{Amount: parseInt(values[0]), Count: parseInt(values[1])}
+		}
+	}
+	return counters, nil
+}
+
+// ttlSeconds 有效期秒数，至少1秒
+func ttlSeconds(ttl time.Duration) int64 {
+	seconds := int64(ttl / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// parseInt 解析Redis返回的计数
+func parseInt(value interface{}) int64 {
+	s, ok := value.(string)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+// memoryCounter 进程内计数器
+type memoryCounter struct {
+	Counter
+	expiresAt time.Time
+}
+
+// memoryReservation 进程内预占记录
+type memoryReservation struct {
+	keys      []string
+	amount    int64
+	expiresAt time.Time
+}
+
+// MemoryStore 进程内计数器存储，Redis不可用时的降级实现
+type MemoryStore struct {
+	mu           sync.Mutex
+	counters     map[string]*memoryCounter
+	reservations map[string]*memoryReservation
+	lastSweep    time.Time
+	now          func() time.Time
+}
+
+// NewMemoryStore 创建进程内计数器存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters:     make(map[string]*memoryCounter),
+		reservations: make(map[string]*memoryReservation),
+		now:          time.Now,
+	}
+}
+
+// Reserve 检查并预占额度
+func (s *MemoryStore) Reserve(ctx context.Context, reservationKey string, amount int64, checks []Check, reservationTTL time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	if _, ok := s.reservations[reservationKey]; ok {
+		return -1, nil
+	}
+
+	for i, check := range checks {
+		used := s.counter(check.Key, now)
+		if check.MaxAmount > 0 && used.Amount+amount > check.MaxAmount {
+			return i, nil
+		}
+		if check.MaxCount > 0 && used.Count+1 > check.MaxCount {
+			return i, nil
+		}
+	}
+
+	keys := make([]string, 0, len(checks))
+	for _, check := range checks {
+		counter, ok := s.counters[check.Key]
+		if !ok || !counter.expiresAt.After(now) {
+			counter = &memoryCounter{}
+			s.counters[check.Key] = counter
+		}
+		counter.Amount += amount
+		counter.Count++
+		counter.expiresAt = now.Add(check.TTL)
+		keys = append(keys, check.Key)
+	}
+	s.reservations[reservationKey] = &memoryReservation{
+		keys:      keys,
+		amount:    amount,
+		expiresAt: now.Add(reservationTTL),
+	}
+	return -1, nil
+}
+
+// Release 释放预占额度
+func (s *MemoryStore) Release(ctx context.Context, reservationKey string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	reservation, ok := s.reservations[reservationKey]
+	if !ok || !reservation.expiresAt.After(now) {
+		return false, nil
+	}
+	delete(s.reservations, reservationKey)
+
+	for _, key := range reservation.keys {
+		if counter, ok := s.counters[key]; ok && counter.expiresAt.After(now) {
+			counter.Amount -= reservation.amount
+			counter.Count--
+		}
+	}
+	return true, nil
+}
+
+// Get 查询计数器
+func (s *MemoryStore) Get(ctx context.Context, keys []string) ([]Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	counters := make([]Counter, len(keys))
+	for i, key := range keys {
+		counters[i] = s.counter(key, now)
+	}
+	return counters, nil
+}
+
+// counter 获取未过期的计数器值
+func (s *MemoryStore) counter(key string, now time.Time) Counter {
+	if counter, ok := s.counters[key]; ok && counter.expiresAt.After(now) {
+		return counter.Counter
+	}
+	return Counter{}
+}
+
+// sweep 定期清理过期的计数器与预占记录
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, counter := range s.counters {
+		if !counter.expiresAt.After(now) {
+			delete(s.counters, key)
+		}
+	}
+	for key, reservation := range s.reservations {
+		if !reservation.expiresAt.After(now) {
+			delete(s.reservations, key)
+		}
+	}
+}
+
+// counterKey 计数器键，用户ID作为哈希标签
+func counterKey(userID uint, scope, period, periodKey string) string {
+	return strings.Join([]string{"payment:limit:{" + strconv.FormatUint(uint64(userID), 10) + "}", scope, period, periodKey}, ":")
+}
+
+// reservationKey 预占记录键
+func reservationKey(userID uint, reservationID string) string {
+	return "payment:limit:{" + strconv.FormatUint(uint64(userID), 10) + "}:reserved:" + reservationID
+}
@@ -31,6 +31,12 @@ type PaymentMetrics struct {
 	CallbackSuccess map[string]int64 `json:"callback_success"`
 	CallbackFailed  map[string]int64 `json:"callback_failed"`
 
+	// 主动轮询指标
+	PollTotal   map[string]int64 `json:"poll_total"`
+	PollRescued map[string]int64 `json:"poll_rescued"` // 回调丢失、由轮询补单成功的笔数
+	PollClosed  map[string]int64 `json:"poll_closed"`  // 过期后由轮询关单的笔数
+	PollFailed  map[string]int64 `json:"poll_failed"`
+
 	// 系统指标
 	LastResetTime      time.Time `json:"last_reset_time"`
 	CurrentConnections int64     `json:"current_connections"`
@@ -57,6 +63,11 @@ type PaymentMetricsSummary struct {
 	CallbackSuccess     int64   `json:"callback_success"`
 	CallbackFailed      int64   `json:"callback_failed"`
 	CallbackSuccessRate float64 `json:"callback_success_rate"`
+
+	PollTotal   int64 `json:"poll_total"`
+	PollRescued int64 `json:"poll_rescued"`
+	PollClosed  int64 `json:"poll_closed"`
+	PollFailed  int64 `json:"poll_failed"`
 }
 
 // NewPaymentMetrics 创建支付指标实例
@@ -76,6 +87,11 @@ func NewPaymentMetrics() *PaymentMetrics {
 		CallbackSuccess: make(map[string]int64),
 		CallbackFailed:  make(map[string]int64),
 
+		PollTotal:   make(map[string]int64),
+		PollRescued: make(map[string]int64),
+		PollClosed:  make(map[string]int64),
+		PollFailed:  make(map[string]int64),
+
 		LastResetTime: time.Now(),
 	}
 }
@@ -167,6 +183,24 @@ func (pm *PaymentMetrics) RecordCallback(method model.PaymentMethod, status stri
 	}
 }
 
+// RecordPoll 记录主动轮询指标
+func (pm *PaymentMetrics) RecordPoll(method model.PaymentMethod, result string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	methodStr := string(method)
+	pm.PollTotal[methodStr]++
+
+	switch result {
+	case PollResultRescued:
+		pm.PollRescued[methodStr]++
+	case PollResultClosed:
+		pm.PollClosed[methodStr]++
+	case PollResultError:
+		pm.PollFailed[methodStr]++
+	}
+}
+
 // IncrementConnections 增加连接数
 func (pm *PaymentMetrics) IncrementConnections() {
 	pm.mu.Lock()
@@ -203,6 +237,10 @@ func (pm *PaymentMetrics) GetSummary() []PaymentMetricsSummary {
 			CallbackTotal:   pm.CallbackTotal[method],
 			CallbackSuccess: pm.CallbackSuccess[method],
 			CallbackFailed:  pm.CallbackFailed[method],
+			PollTotal:       pm.PollTotal[method],
+			PollRescued:     pm.PollRescued[method],
+			PollClosed:      pm.PollClosed[method],
+			PollFailed:      pm.PollFailed[method],
 		}
 
 		// 计算成功率
@@ -235,7 +273,7 @@ func (pm *PaymentMetrics) GetSummary() []PaymentMetricsSummary {
 		}
 
 		// 只有有数据的方法才添加到摘要中
-		if summary.CreateTotal > 0 || summary.QueryTotal > 0 || summary.CallbackTotal > 0 {
+		if summary.CreateTotal > 0 || summary.QueryTotal > 0 || summary.CallbackTotal > 0 || summary.PollTotal > 0 {
 			summaries = append(summaries, summary)
 		}
 	}
@@ -288,6 +326,11 @@ func (pm *PaymentMetrics) Reset() {
 	pm.CallbackSuccess = make(map[string]int64)
 	pm.CallbackFailed = make(map[string]int64)
 
+	pm.PollTotal = make(map[string]int64)
+	pm.PollRescued = make(map[string]int64)
+	pm.PollClosed = make(map[string]int64)
+	pm.PollFailed = make(map[string]int64)
+
 	pm.LastResetTime = time.Now()
 	pm.TotalRequests = 0
 
@@ -315,7 +358,10 @@ func (pm *PaymentMetrics) LogSummary() {
 			zap.Float64("query_success_rate", summary.QuerySuccessRate),
 			zap.Duration("query_avg_duration", summary.QueryAvgDuration),
 			zap.Int64("callback_total", summary.CallbackTotal),
-			zap.Float64("callback_success_rate", summary.CallbackSuccessRate))
+			zap.Float64("callback_success_rate", summary.CallbackSuccessRate),
+			zap.Int64("poll_total", summary.PollTotal),
+			zap.Int64("poll_rescued", summary.PollRescued),
+			zap.Int64("poll_closed", summary.PollClosed))
 	}
 }
 
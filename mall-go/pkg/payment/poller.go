@@ -0,0 +1,353 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 轮询结果
+const (
+	PollResultPending = "pending" // 仍未支付
+	PollResultRescued = "rescued" // 回调丢失，由轮询补单
+	PollResultClosed  = "closed"  // 已过期并关闭渠道交易
+	PollResultSettled = "settled" // 渠道侧已是终态（已关闭/失败）或已由回调处理
+	PollResultError   = "error"   // 查询或关单失败
+)
+
+// PaymentPoller 待支付订单主动轮询器
+// 渠道通知丢失时，支付记录会一直停留在待支付状态，轮询器按退避间隔主动查询渠道交易状态，
+// 查询到支付成功时走与回调相同的幂等成功流程；支付过期后关闭渠道交易，防止用户过期后仍能付款。
+// 处理中的退款单同样按固定间隔向渠道查询，退款通知丢失时由轮询确认退款结果
+type PaymentPoller struct {
+	db      *gorm.DB
+	service *Service
+	router  *PaymentRouter
+	options PollerOptions
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// PollerOptions 轮询器配置
+type PollerOptions struct {
+	Schedule     []time.Duration // 轮询间隔，第N次轮询后等待Schedule[N]，超出后沿用最后一个间隔
+	ScanInterval time.Duration   // 扫描到期支付记录的间隔
+	BatchSize    int             // 单次扫描数量
+
+	RefundInterval time.Duration // 处理中退款单两次查询的间隔
+}
+
+// DefaultPollerOptions 默认轮询配置：创建后15秒首次查询，之后间隔1分钟、5分钟、30分钟
+func DefaultPollerOptions() PollerOptions {
+	return PollerOptions{
+		Schedule:     []time.Duration{15 * time.Second, time.Minute, 5 * time.Minute, 30 * time.Minute},
+		ScanInterval: 5 * time.Second,
+		BatchSize:    50,
+
+		RefundInterval: 5 * time.Minute,
+	}
+}
+
+// NewPaymentPoller 创建并启动待支付订单轮询器
+func NewPaymentPoller(service *Service, options PollerOptions) *PaymentPoller {
+	defaults := DefaultPollerOptions()
+	if len(options.Schedule) == 0 {
+		options.Schedule = defaults.Schedule
+	}
+	if options.ScanInterval <= 0 {
+		options.ScanInterval = defaults.ScanInterval
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaults.BatchSize
+	}
+	if options.RefundInterval <= 0 {
+		options.RefundInterval = defaults.RefundInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	poller := &PaymentPoller{
+		db:      service.db,
+		service: service,
+		router:  service.Router(),
+		options: options,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	poller.wg.Add(1)
+	go poller.run()
+
+	logger.Info("待支付订单轮询器启动",
+		zap.Duration("scan_interval", options.ScanInterval),
+		zap.Int("batch_size", options.BatchSize))
+
+	return poller
+}
+
+// run 轮询主循环
+func (p *PaymentPoller) run() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.options.ScanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			p.PollDue(now)
+			p.PollRefunds(now)
+		}
+	}
+}
+
+// PollDue 轮询到期的待支付记录，返回本次轮询数量
+func (p *PaymentPoller) PollDue(now time.Time) int {
+	payments, err := p.findDuePayments(now)
+	if err != nil {
+		logger.Error("查询待轮询支付记录失败", zap.Error(err))
+		return 0
+	}
+
+	polled := 0
+	for i := range payments {
+		if p.ctx.Err() != nil {
+			break
+		}
+		if !p.claim(&payments[i], now) {
+			continue
+		}
+		p.pollPayment(&payments[i], now)
+		polled++
+	}
+
+	return polled
+}
+
+// findDuePayments 查询到期需要轮询的支付记录
+// 从未轮询过的记录在创建后经过首个间隔才开始轮询，给回调留出到达时间
+func (p *PaymentPoller) findDuePayments(now time.Time) ([]model.Payment, error) {
+	var payments []model.Payment
+	err := p.db.Where("payment_status IN ?", []model.PaymentStatus{model.PaymentStatusPending, model.PaymentStatusPaying}).
+		Where("(next_poll_at IS NULL AND created_at <= ?) OR next_poll_at <= ?", now.Add(-p.options.Schedule[0]), now).
+		Order("id ASC").
+		Limit(p.options.BatchSize).
+		Find(&payments).Error
+	return payments, err
+}
+
+// claim 推进下次轮询时间以认领本次轮询
+// 按 poll_count 做条件更新，多实例部署时同一笔支付只会被一个实例轮询
+func (p *PaymentPoller) claim(payment *model.Payment, now time.Time) bool {
+	nextPollAt := p.nextPollAt(payment, now)
+	result := p.db.Model(&model.Payment{}).
+		Where("id = ? AND poll_count = ?", payment.ID, payment.PollCount).
+		Updates(map[string]interface{}{
+			"poll_count":   gorm.Expr("poll_count + 1"),
+			"next_poll_at": nextPollAt,
+		})
+	if result.Error != nil {
+		logger.Error("认领支付轮询失败", zap.Uint("payment_id", payment.ID), zap.Error(result.Error))
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
+	}
+
+	payment.PollCount++
+	payment.NextPollAt = &nextPollAt
+	return true
+}
+
+// nextPollAt 计算下次轮询时间，未过期的支付最迟在过期时刻再查询一次以便及时关单
+func (p *PaymentPoller) nextPollAt(payment *model.Payment, now time.Time) time.Time {
+	index := payment.PollCount + 1
+	if index >= len(p.options.Schedule) {
+		index = len(p.options.Schedule) - 1
+	}
+
+	next := now.Add(p.options.Schedule[index])
+	if payment.ExpiredAt != nil && now.Before(*payment.ExpiredAt) && next.After(*payment.ExpiredAt) {
+		next = *payment.ExpiredAt
+	}
+	return next
+}
+
+// pollPayment 查询单笔支付的渠道状态并处理
+func (p *PaymentPoller) pollPayment(payment *model.Payment, now time.Time) {
+	result := p.poll(payment, now)
+	p.router.Metrics().RecordPoll(payment.PaymentMethod, result)
+
+	logger.Debug("待支付订单轮询完成",
+		zap.Uint("payment_id", payment.ID),
+		zap.String("payment_no", payment.PaymentNo),
+		zap.Int("poll_count", payment.PollCount),
+		zap.String("result", result))
+}
+
+// poll 执行一次轮询，返回轮询结果
+func (p *PaymentPoller) poll(payment *model.Payment, now time.Time) string {
+	// 未接入真实渠道客户端时路由器返回模拟结果，不能据此更新支付状态
+	if !p.router.HasClient(payment.PaymentMethod) {
+		return PollResultPending
+	}
+
+	expired := payment.ExpiredAt != nil && !now.Before(*payment.ExpiredAt)
+
+	resp, err := p.router.QueryPayment(payment.PaymentNo, payment.PaymentMethod)
+	if err != nil {
+		// 用户未扫码时渠道侧查不到交易，过期后仍需关单；其他查询失败时结果未知，按退避计划重试
+		if expired && errors.Is(err, model.ErrTradeNotExist) {
+			return p.closeExpired(payment)
+		}
+		logger.Warn("轮询查询支付状态失败",
+			zap.String("payment_no", payment.PaymentNo),
+			zap.Error(err))
+		return PollResultError
+	}
+
+	switch resp.Status {
+	case model.PaymentStatusSuccess:
+		payment.PaymentStatus = model.PaymentStatusSuccess
+		payment.ThirdPartyID = resp.TransactionID
+		paidAt := now
+		if resp.PaidAt != nil {
+			paidAt = *resp.PaidAt
+		}
+		payment.PaidAt = &paidAt
+
+		applied, err := p.service.applyPaymentSuccess(payment)
+		if err != nil {
+			logger.Error("轮询补单失败",
+				zap.String("payment_no", payment.PaymentNo),
+				zap.Error(err))
+			// 支付记录已标记成功、仅订单更新失败时仍计为补单
+			if !applied {
+				return PollResultError
+			}
+		}
+		if !applied {
+			return PollResultSettled
+		}
+
+		logger.Warn("支付回调未到达，已通过主动轮询补单",
+			zap.Uint("payment_id", payment.ID),
+			zap.String("payment_no", payment.PaymentNo),
+			zap.String("method", string(payment.PaymentMethod)),
+			zap.Int("poll_count", payment.PollCount))
+		p.service.logPaymentAction(payment.ID, "POLL_RESCUE", "SUCCESS", "主动轮询确认支付成功", "", "")
+		return PollResultRescued
+
+	case model.PaymentStatusPending, model.PaymentStatusPaying:
+		if expired {
+			return p.closeExpired(payment)
+		}
+		return PollResultPending
+
+	default:
+		// 渠道交易已关闭或失败，同步为终态后不再轮询
+		if err := p.updateStatus(payment, resp.Status); err != nil {
+			logger.Error("轮询更新支付状态失败",
+				zap.String("payment_no", payment.PaymentNo),
+				zap.Error(err))
+			return PollResultError
+		}
+		return PollResultSettled
+	}
+}
+
+// closeExpired 关闭已过期的渠道交易并将支付记录标记为已过期
+func (p *PaymentPoller) closeExpired(payment *model.Payment) string {
+	if err := p.router.ClosePayment(payment.PaymentNo, payment.PaymentMethod); err != nil {
+		logger.Warn("关闭过期渠道交易失败，等待下次轮询重试",
+			zap.String("payment_no", payment.PaymentNo),
+			zap.Error(err))
+		return PollResultError
+	}
+
+	if err := p.updateStatus(payment, model.PaymentStatusExpired); err != nil {
+		logger.Error("更新过期支付状态失败",
+			zap.String("payment_no", payment.PaymentNo),
+			zap.Error(err))
+		return PollResultError
+	}
+
+	p.service.logPaymentAction(payment.ID, "CLOSE", "SUCCESS", "支付过期，已关闭渠道交易", "", "")
+	return PollResultClosed
+}
+
+// updateStatus 将仍处于待支付的记录更新为终态，已被回调处理的记录不受影响
+// 更新为失败、关闭或过期时释放预占的累计限额
+func (p *PaymentPoller) updateStatus(payment *model.Payment, status model.PaymentStatus) error {
+	result := p.db.Model(&model.Payment{}).
+		Where("id = ? AND payment_status IN ?", payment.ID,
+			[]model.PaymentStatus{model.PaymentStatusPending, model.PaymentStatusPaying}).
+		Update("payment_status", status)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		payment.PaymentStatus = status
+		p.service.ReleasePaymentLimit(payment)
+	}
+	return nil
+}
+
+// PollRefunds 查询距上次更新已超过 RefundInterval 的处理中退款单，返回本次查询数量
+func (p *PaymentPoller) PollRefunds(now time.Time) int {
+	cutoff := now.Add(-p.options.RefundInterval)
+
+	var refunds []model.PaymentRefund
+	if err := p.db.Where("refund_status = ? AND updated_at <= ?", model.PaymentStatusPending, cutoff).
+		Order("id ASC").
+		Limit(p.options.BatchSize).
+		Find(&refunds).Error; err != nil {
+		logger.Error("查询待轮询退款单失败", zap.Error(err))
+		return 0
+	}
+
+	polled := 0
+	for i := range refunds {
+		if p.ctx.Err() != nil {
+			break
+		}
+
+		// 推进更新时间以认领本次查询，多实例部署时同一退款单只会被一个实例查询
+		result := p.db.Model(&model.PaymentRefund{}).
+			Where("id = ? AND refund_status = ? AND updated_at <= ?", refunds[i].ID, model.PaymentStatusPending, cutoff).
+			Update("updated_at", now)
+		if result.Error != nil {
+			logger.Error("认领退款轮询失败", zap.Uint("refund_id", refunds[i].ID), zap.Error(result.Error))
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		if err := p.service.syncRefundStatus(&refunds[i]); err != nil {
+			logger.Warn("轮询查询退款状态失败",
+				zap.String("refund_no", refunds[i].RefundNo),
+				zap.Error(err))
+		}
+		polled++
+	}
+
+	return polled
+}
+
+// Stop 停止轮询器
+func (p *PaymentPoller) Stop() {
+	logger.Info("停止待支付订单轮询器")
+	p.cancel()
+	p.wg.Wait()
+}
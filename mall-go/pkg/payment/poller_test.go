@@ -0,0 +1,272 @@
+package payment
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// fakeChannelClient 模拟渠道客户端，按单号返回预设的交易状态并记录查询与关单
+type fakeChannelClient struct {
+	mu       sync.Mutex
+	statuses map[string]model.PaymentStatus
+	queryErr error
+	closeErr error
+	queries  []string
+	closed   []string
+}
+
+func newFakeChannelClient() *fakeChannelClient {
+	return &fakeChannelClient{statuses: make(map[string]model.PaymentStatus)}
+}
+
+func (f *fakeChannelClient) CreatePayment(req *CreatePaymentRequest) (*CreatePaymentResponse, error) {
+	return &CreatePaymentResponse{OutTradeNo: req.OutTradeNo, Method: req.Method, Success: true}, nil
+}
+
+func (f *fakeChannelClient) QueryPayment(outTradeNo string) (*QueryPaymentResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries = append(f.queries, outTradeNo)
+	if f.queryErr != nil {
+		return nil, f.queryErr
+	}
+
+	status, ok := f.statuses[outTradeNo]
+	if !ok {
+		status = model.PaymentStatusPending
+	}
+	resp := &QueryPaymentResponse{OutTradeNo: outTradeNo, Method: model.PaymentMethodAlipay, Status: status, Success: true}
+	if status == model.PaymentStatusSuccess {
+		paidAt := time.Now()
+		resp.TransactionID = "TRADE_" + outTradeNo
+		resp.PaidAt = &paidAt
+	}
+	return resp, nil
+}
+
+func (f *fakeChannelClient) VerifyCallback(params map[string]string) error {
+	return nil
+}
+
+func (f *fakeChannelClient) ClosePayment(outTradeNo string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closeErr != nil {
+		return f.closeErr
+	}
+	f.closed = append(f.closed, outTradeNo)
+	return nil
+}
+
+// newTestPoller 创建接入模拟支付宝客户端的轮询器，扫描间隔足够长，由测试直接调用 PollDue
+func newTestPoller(t *testing.T) (*PaymentPoller, *fakeChannelClient, *gorm.DB) {
+	db := setupTestDB()
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1) // 内存库每个连接独立，共用同一连接
+
+	config := DefaultPaymentConfig()
+	config.Alipay.Enabled = true
+	service, err := NewService(db, config)
+	require.NoError(t, err)
+
+	client := newFakeChannelClient()
+	service.Router().SetAlipayClient(client)
+
+	poller := NewPaymentPoller(service, PollerOptions{
+		Schedule:     []time.Duration{15 * time.Second, time.Minute, 5 * time.Minute},
+		ScanInterval: time.Hour,
+	})
+	t.Cleanup(poller.Stop)
+
+	return poller, client, db
+}
+
+// createPollPayment 创建指定创建时间与过期时间的待支付记录
+func createPollPayment(t *testing.T, db *gorm.DB, createdAt time.Time, expiredAt time.Time) *model.Payment {
+	seq := time.Now().UnixNano()
+	user := &model.User{
+		Username: fmt.Sprintf("polluser%d", seq),
+		Email:    fmt.Sprintf("poll%d@example.com", seq),
+		Status:   "active",
+	}
+	require.NoError(t, db.Create(user).Error)
+
+	order := &model.Order{
+		OrderNo:       fmt.Sprintf("ORDER_POLL_%d", seq),
+		UserID:        user.ID,
+		TotalAmount:   decimal.NewFromInt(100),
+		PayableAmount: decimal.NewFromInt(100),
+		Status:        model.OrderStatusPending,
+		PaymentStatus: string(model.PaymentStatusPending),
+	}
+	require.NoError(t, db.Create(order).Error)
+
+	payment := &model.Payment{
+		PaymentNo:     fmt.Sprintf("PAY_POLL_%d", seq),
+		OrderID:       order.ID,
+		UserID:        user.ID,
+		PaymentMethod: model.PaymentMethodAlipay,
+		PaymentStatus: model.PaymentStatusPending,
+		Amount:        decimal.NewFromInt(100),
+		ExpiredAt:     &expiredAt,
+		CreatedAt:     createdAt,
+	}
+	require.NoError(t, db.Create(payment).Error)
+	return payment
+}
+
+func reloadPayment(t *testing.T, db *gorm.DB, id uint) *model.Payment {
+	var payment model.Payment
+	require.NoError(t, db.First(&payment, id).Error)
+	return &payment
+}
+
+func TestPaymentPoller_BackoffSchedule(t *testing.T) {
+	poller, client, db := newTestPoller(t)
+	now := time.Now()
+	payment := createPollPayment(t, db, now, now.Add(time.Hour))
+
+	// 创建后首个间隔内等待回调，不轮询
+	assert.Equal(t, 0, poller.PollDue(now.Add(10*time.Second)))
+
+	// 之后按 1分钟、5分钟 的间隔轮询，超出后沿用最后一个间隔
+	steps := []struct {
+		at   time.Duration
+		next time.Duration
+	}{
+		{15 * time.Second, time.Minute},
+		{15*time.Second + time.Minute, 5 * time.Minute},
+		{15*time.Second + 6*time.Minute, 5 * time.Minute},
+	}
+	for i, step := range steps {
+		at := now.Add(step.at)
+		require.Equal(t, 1, poller.PollDue(at), "第 %d 次轮询", i+1)
+
+		stored := reloadPayment(t, db, payment.ID)
+		assert.Equal(t, i+1, stored.PollCount)
+		require.NotNil(t, stored.NextPollAt)
+		assert.WithinDuration(t, at.Add(step.next), *stored.NextPollAt, time.Millisecond)
+
+		// 下次轮询时间之前不会重复查询
+		assert.Equal(t, 0, poller.PollDue(at.Add(step.next-time.Second)))
+	}
+
+	assert.Len(t, client.queries, len(steps))
+	assert.Equal(t, model.PaymentStatusPending, reloadPayment(t, db, payment.ID).PaymentStatus)
+}
+
+func TestPaymentPoller_NextPollCappedAtExpiry(t *testing.T) {
+	poller, _, db := newTestPoller(t)
+	now := time.Now()
+	payment := createPollPayment(t, db, now, now.Add(45*time.Second))
+
+	// 下次间隔1分钟超过过期时间，提前到过期时刻再查询一次以便关单
+	at := now.Add(15 * time.Second)
+	require.Equal(t, 1, poller.PollDue(at))
+
+	stored := reloadPayment(t, db, payment.ID)
+	require.NotNil(t, stored.NextPollAt)
+	assert.WithinDuration(t, now.Add(45*time.Second), *stored.NextPollAt, time.Millisecond)
+}
+
+func TestPaymentPoller_RescueMissedCallback(t *testing.T) {
+	poller, client, db := newTestPoller(t)
+	now := time.Now()
+	payment := createPollPayment(t, db, now, now.Add(time.Hour))
+
+	// 用户已付款但回调丢失，渠道查询为成功
+	client.statuses[payment.PaymentNo] = model.PaymentStatusSuccess
+	require.Equal(t, 1, poller.PollDue(now.Add(15*time.Second)))
+
+	stored := reloadPayment(t, db, payment.ID)
+	assert.Equal(t, model.PaymentStatusSuccess, stored.PaymentStatus)
+	assert.Equal(t, "TRADE_"+payment.PaymentNo, stored.ThirdPartyID)
+	assert.NotNil(t, stored.PaidAt)
+
+	var rescued int64
+	require.NoError(t, db.Model(&model.PaymentLog{}).
+		Where("payment_id = ? AND action = ?", payment.ID, "POLL_RESCUE").
+		Count(&rescued).Error)
+	assert.Equal(t, int64(1), rescued)
+
+	// 已成功的支付不再轮询
+	assert.Equal(t, 0, poller.PollDue(now.Add(time.Hour)))
+}
+
+func TestPaymentPoller_CloseExpiredTrade(t *testing.T) {
+	poller, client, db := newTestPoller(t)
+	now := time.Now()
+	payment := createPollPayment(t, db, now, now.Add(time.Minute))
+
+	// 过期后渠道仍为待支付，关闭渠道交易并标记为已过期
+	require.Equal(t, 1, poller.PollDue(now.Add(2*time.Minute)))
+
+	assert.Equal(t, []string{payment.PaymentNo}, client.closed)
+	assert.Equal(t, model.PaymentStatusExpired, reloadPayment(t, db, payment.ID).PaymentStatus)
+	assert.Equal(t, 0, poller.PollDue(now.Add(time.Hour)))
+}
+
+func TestPaymentPoller_CloseExpiredTradeWhenQueryFails(t *testing.T) {
+	poller, client, db := newTestPoller(t)
+	now := time.Now()
+	payment := createPollPayment(t, db, now, now.Add(time.Minute))
+
+	// 用户未扫码时渠道侧查不到交易，过期后仍需关单；关单失败时保持待支付，下次轮询重试
+	client.queryErr = model.ErrTradeNotExist
+	client.closeErr = fmt.Errorf("渠道繁忙")
+	require.Equal(t, 1, poller.PollDue(now.Add(2*time.Minute)))
+	assert.Empty(t, client.closed)
+	assert.Equal(t, model.PaymentStatusPending, reloadPayment(t, db, payment.ID).PaymentStatus)
+
+	client.closeErr = nil
+	require.Equal(t, 1, poller.PollDue(now.Add(time.Hour)))
+	assert.Equal(t, []string{payment.PaymentNo}, client.closed)
+	assert.Equal(t, model.PaymentStatusExpired, reloadPayment(t, db, payment.ID).PaymentStatus)
+}
+
+func TestPaymentPoller_KeepExpiredTradeWhenQueryResultUnknown(t *testing.T) {
+	poller, client, db := newTestPoller(t)
+	now := time.Now()
+	payment := createPollPayment(t, db, now, now.Add(time.Minute))
+
+	// 过期后查询失败（超时、渠道繁忙）时交易状态未知，用户可能已付款，不关单，按退避计划重试
+	client.queryErr = fmt.Errorf("渠道繁忙")
+	require.Equal(t, 1, poller.PollDue(now.Add(2*time.Minute)))
+	assert.Empty(t, client.closed)
+
+	stored := reloadPayment(t, db, payment.ID)
+	assert.Equal(t, model.PaymentStatusPending, stored.PaymentStatus)
+	assert.Equal(t, 1, stored.PollCount)
+	require.NotNil(t, stored.NextPollAt)
+
+	// 恢复后查询到已付款，按成功补单而非关单
+	client.queryErr = nil
+	client.statuses[payment.PaymentNo] = model.PaymentStatusSuccess
+	require.Equal(t, 1, poller.PollDue(*stored.NextPollAt))
+	assert.Empty(t, client.closed)
+	assert.Equal(t, model.PaymentStatusSuccess, reloadPayment(t, db, payment.ID).PaymentStatus)
+}
+
+func TestPaymentPoller_SettledByChannel(t *testing.T) {
+	poller, client, db := newTestPoller(t)
+	now := time.Now()
+	payment := createPollPayment(t, db, now, now.Add(time.Hour))
+
+	// 渠道交易已关闭时同步为终态，不再轮询
+	client.statuses[payment.PaymentNo] = model.PaymentStatusCancelled
+	require.Equal(t, 1, poller.PollDue(now.Add(15*time.Second)))
+
+	assert.Equal(t, model.PaymentStatusCancelled, reloadPayment(t, db, payment.ID).PaymentStatus)
+	assert.Empty(t, client.closed)
+	assert.Equal(t, 0, poller.PollDue(now.Add(time.Hour)))
+}
@@ -0,0 +1,350 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" // 支持JPEG格式的logo
+	"image/png"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"mall-go/internal/model"
+
+	goqrcode "github.com/skip2/go-qrcode"
+)
+
+// Format 二维码图片格式
+type Format string
+
+const (
+	FormatPNG Format = "png"
+	FormatSVG Format = "svg"
+)
+
+// ContentType 图片MIME类型
+func (f Format) ContentType() string {
+	if f == FormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// ParseFormat 解析图片格式，为空时默认PNG
+func ParseFormat(value string) (Format, error) {
+	switch Format(strings.ToLower(value)) {
+	case "", FormatPNG:
+		return FormatPNG, nil
+	case FormatSVG:
+		return FormatSVG, nil
+	default:
+		return "", model.ErrInvalidQRCodeFormat
+	}
+}
+
+// Options 二维码渲染配置
+type Options struct {
+	Size          int     // 默认边长(像素)
+	MaxSize       int     // 允许请求的最大边长(像素)
+	RecoveryLevel string  // 纠错等级 L/M/Q/H
+	LogoPath      string  // 中心logo图片路径(PNG/JPEG)，为空表示不加logo
+	LogoRatio     float64 // logo边长占二维码边长的比例
+	CacheSize     int     // 最多缓存的图片数量
+}
+
+// DefaultOptions 默认二维码渲染配置
+func DefaultOptions() Options {
+	return Options{
+		Size:          256,
+		MaxSize:       1024,
+		RecoveryLevel: "M",
+		LogoRatio:     0.2,
+		CacheSize:     1024,
+	}
+}
+
+// 尺寸下限，过小的二维码无法识别
+const minSize = 64
+
+// 加logo时至少使用的纠错等级，保证被遮挡的中心区域可恢复
+const logoRecoveryLevel = goqrcode.High
+
+// Image 渲染后的二维码图片
+type Image struct {
+	Format Format `json:"format"`
+	Data   []byte `json:"-"`
+}
+
+// ContentType 图片MIME类型
+func (i *Image) ContentType() string {
+	return i.Format.ContentType()
+}
+
+// DataURI 图片的data URI，可直接作为img标签的src
+func (i *Image) DataURI() string {
+	return "data:" + i.ContentType() + ";base64," + base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// cacheEntry 缓存的二维码图片
+type cacheEntry struct {
+	image     *Image
+	expiresAt time.Time
+}
+
+// Renderer 二维码渲染器
+// 在本地将扫码支付链接渲染为PNG/SVG图片，图片按支付单缓存至支付过期
+type Renderer struct {
+	options Options
+	level   goqrcode.RecoveryLevel
+	logo    image.Image
+
+	mu    sync.Mutex
+	cache map[string]*cacheEntry
+	now   func() time.Time
+}
+
+// NewRenderer 创建二维码渲染器
+func NewRenderer(options Options) (*Renderer, error) {
+	defaults := DefaultOptions()
+	if options.Size <= 0 {
+		options.Size = defaults.Size
+	}
+	if options.MaxSize <= 0 {
+		options.MaxSize = defaults.MaxSize
+	}
+	if options.LogoRatio <= 0 || options.LogoRatio > 0.3 {
+		options.LogoRatio = defaults.LogoRatio
+	}
+	if options.CacheSize <= 0 {
+		options.CacheSize = defaults.CacheSize
+	}
+
+	level, err := parseRecoveryLevel(options.RecoveryLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	renderer := &Renderer{
+		options: options,
+		level:   level,
+		cache:   make(map[string]*cacheEntry),
+		now:     time.Now,
+	}
+
+	if options.LogoPath != "" {
+		logo, err := loadLogo(options.LogoPath)
+		if err != nil {
+			return nil, err
+		}
+		renderer.logo = logo
+		if renderer.level < logoRecoveryLevel {
+			renderer.level = logoRecoveryLevel
+		}
+	}
+	return renderer, nil
+}
+
+// Render 渲染二维码图片，size 为0时使用默认尺寸
+func (r *Renderer) Render(content string, format Format, size int) (*Image, error) {
+	if content == "" {
+		return nil, model.ErrPaymentQRCodeUnavailable
+	}
+
+	code, err := goqrcode.New(content, r.level)
+	if err != nil {
+		return nil, fmt.Errorf("生成二维码失败: %v", err)
+	}
+	size = r.normalizeSize(size)
+
+	var data []byte
+	if format == FormatSVG {
+		data, err = r.renderSVG(code, size)
+	} else {
+		data, err = r.renderPNG(code, size)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Image{Format: format, Data: data}, nil
+}
+
+// RenderCached 渲染并缓存二维码图片，同一键在过期前直接返回缓存
+// 缓存键通常为支付单号，内容不同时（如重新下单后链接变化）重新渲染
+func (r *Renderer) RenderCached(key, content string, format Format, size int, expiresAt time.Time) (*Image, error) {
+	now := r.now()
+	if !expiresAt.After(now) {
+		return r.Render(content, format, size)
+	}
+
+	cacheKey := fmt.Sprintf("%s:%s:%d:%s", key, format, r.normalizeSize(size), content)
+	r.mu.Lock()
+	if entry, ok := r.cache[cacheKey]; ok && entry.expiresAt.After(now) {
+		r.mu.Unlock()
+		return entry.image, nil
+	}
+	r.mu.Unlock()
+
+	img, err := r.Render(content, format, size)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.cache) >= r.options.CacheSize {
+		r.evict(now)
+	}
+	r.cache[cacheKey] = &cacheEntry{image: img, expiresAt: expiresAt}
+	return img, nil
+}
+
+// evict 清理过期缓存，仍然超出容量时淘汰最早过期的一项
+func (r *Renderer) evict(now time.Time) {
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range r.cache {
+		if !entry.expiresAt.After(now) {
+			delete(r.cache, key)
+			continue
+		}
+		if oldestKey == "" || entry.expiresAt.Before(oldest) {
+			oldestKey, oldest = key, entry.expiresAt
+		}
+	}
+	if len(r.cache) >= r.options.CacheSize && oldestKey != "" {
+		delete(r.cache, oldestKey)
+	}
+}
+
+// normalizeSize 限制图片尺寸范围
+func (r *Renderer) normalizeSize(size int) int {
+	if size <= 0 {
+		return r.options.Size
+	}
+	if size < minSize {
+		return minSize
+	}
+	if size > r.options.MaxSize {
+		return r.options.MaxSize
+	}
+	return size
+}
+
+// renderPNG 渲染PNG图片
+func (r *Renderer) renderPNG(code *goqrcode.QRCode, size int) ([]byte, error) {
+	var img image.Image = code.Image(size)
+	if r.logo != nil {
+		canvas := image.NewRGBA(img.Bounds())
+		draw.Draw(canvas, canvas.Bounds(), img, image.Point{}, draw.Src)
+		drawLogo(canvas, r.logo, r.logoRect(canvas.Bounds().Dx()))
+		img = canvas
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("编码二维码图片失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// renderSVG 渲染SVG图片，每行相邻的深色模块合并为一个矩形
+func (r *Renderer) renderSVG(code *goqrcode.QRCode, size int) ([]byte, error) {
+	bitmap := code.Bitmap()
+	modules := len(bitmap)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#ffffff"/>`, modules, modules)
+	buf.WriteString(`<path fill="#000000" d="`)
+	for y, row := range bitmap {
+		for x := 0; x < len(row); {
+			if !row[x] {
+				x++
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/>`)
+
+	if r.logo != nil {
+		var logo bytes.Buffer
+		if err := png.Encode(&logo, r.logo); err != nil {
+			return nil, fmt.Errorf("编码logo图片失败: %v", err)
+		}
+		rect := r.logoRect(modules)
+		fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="#ffffff"/>`,
+			rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy())
+		fmt.Fprintf(&buf, `<image x="%d" y="%d" width="%d" height="%d" href="data:image/png;base64,%s"/>`,
+			rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy(), base64.StdEncoding.EncodeToString(logo.Bytes()))
+	}
+	buf.WriteString(`</svg>`)
+	return buf.Bytes(), nil
+}
+
+// logoRect 居中的logo区域
+func (r *Renderer) logoRect(size int) image.Rectangle {
+	side := int(float64(size) * r.options.LogoRatio)
+	if side < 1 {
+		side = 1
+	}
+	offset := (size - side) / 2
+	return image.Rect(offset, offset, offset+side, offset+side)
+}
+
+// drawLogo 在白色底板上按最近邻缩放绘制logo
+func drawLogo(canvas *image.RGBA, logo image.Image, rect image.Rectangle) {
+	draw.Draw(canvas, rect, image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	bounds := logo.Bounds()
+	scaled := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			sx := bounds.Min.X + x*bounds.Dx()/rect.Dx()
+			sy := bounds.Min.Y + y*bounds.Dy()/rect.Dy()
+			scaled.Set(x, y, logo.At(sx, sy))
+		}
+	}
+	draw.Draw(canvas, rect, scaled, image.Point{}, draw.Over)
+}
+
+// loadLogo 加载logo图片
+func loadLogo(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开二维码logo失败: %v", err)
+	}
+	defer file.Close()
+
+	logo, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("解析二维码logo失败: %v", err)
+	}
+	return logo, nil
+}
+
+// parseRecoveryLevel 解析纠错等级，为空时使用M级
+func parseRecoveryLevel(value string) (goqrcode.RecoveryLevel, error) {
+	switch strings.ToUpper(value) {
+	case "L", "LOW":
+		return goqrcode.Low, nil
+	case "", "M", "MEDIUM":
+		return goqrcode.Medium, nil
+	case "Q", "HIGH":
+		return goqrcode.High, nil
+	case "H", "HIGHEST":
+		return goqrcode.Highest, nil
+	default:
+		return goqrcode.Medium, fmt.Errorf("不支持的二维码纠错等级: %s", value)
+	}
+}
@@ -0,0 +1,149 @@
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const codeURL = "weixin://wxpay/bizpayurl?pr=abc123"
+
+func TestRenderPNG(t *testing.T) {
+	renderer, err := NewRenderer(DefaultOptions())
+	require.NoError(t, err)
+
+	img, err := renderer.Render(codeURL, FormatPNG, 300)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", img.ContentType())
+	assert.True(t, strings.HasPrefix(img.DataURI(), "data:image/png;base64,"))
+
+	decoded, err := png.Decode(bytes.NewReader(img.Data))
+	require.NoError(t, err)
+	assert.Equal(t, 300, decoded.Bounds().Dx())
+	assert.Equal(t, 300, decoded.Bounds().Dy())
+
+	// 尺寸超出范围时按上下限处理
+	img, err = renderer.Render(codeURL, FormatPNG, 10)
+	require.NoError(t, err)
+	decoded, err = png.Decode(bytes.NewReader(img.Data))
+	require.NoError(t, err)
+	assert.Equal(t, minSize, decoded.Bounds().Dx())
+
+	_, err = renderer.Render("", FormatPNG, 0)
+	assert.ErrorIs(t, err, model.ErrPaymentQRCodeUnavailable)
+}
+
+func TestRenderSVG(t *testing.T) {
+	renderer, err := NewRenderer(DefaultOptions())
+	require.NoError(t, err)
+
+	img, err := renderer.Render(codeURL, FormatSVG, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "image/svg+xml", img.ContentType())
+
+	svg := string(img.Data)
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="256" height="256"`))
+	assert.True(t, strings.HasSuffix(svg, "</svg>"))
+	assert.Contains(t, svg, `<path fill="#000000" d="M`)
+}
+
+func TestRenderWithLogo(t *testing.T) {
+	logo := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			logo.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "logo.png")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, png.Encode(file, logo))
+	require.NoError(t, file.Close())
+
+	options := DefaultOptions()
+	options.LogoPath = path
+	options.RecoveryLevel = "L"
+	renderer, err := NewRenderer(options)
+	require.NoError(t, err)
+	// 加logo时提升纠错等级
+	assert.Equal(t, logoRecoveryLevel, renderer.level)
+
+	img, err := renderer.Render(codeURL, FormatPNG, 256)
+	require.NoError(t, err)
+	decoded, err := png.Decode(bytes.NewReader(img.Data))
+	require.NoError(t, err)
+	r, g, b, _ := decoded.At(128, 128).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+	assert.Zero(t, g)
+	assert.Zero(t, b)
+
+	img, err = renderer.Render(codeURL, FormatSVG, 256)
+	require.NoError(t, err)
+	assert.Contains(t, string(img.Data), `href="data:image/png;base64,`)
+
+	options.LogoPath = filepath.Join(t.TempDir(), "missing.png")
+	_, err = NewRenderer(options)
+	assert.Error(t, err)
+}
+
+func TestRenderCached(t *testing.T) {
+	options := DefaultOptions()
+	options.CacheSize = 2
+	renderer, err := NewRenderer(options)
+	require.NoError(t, err)
+
+	now := time.Now()
+	renderer.now = func() time.Time { return now }
+	expiresAt := now.Add(30 * time.Minute)
+
+	first, err := renderer.RenderCached("P1", codeURL, FormatPNG, 0, expiresAt)
+	require.NoError(t, err)
+	second, err := renderer.RenderCached("P1", codeURL, FormatPNG, 0, expiresAt)
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	// 格式不同分别缓存
+	svg, err := renderer.RenderCached("P1", codeURL, FormatSVG, 0, expiresAt)
+	require.NoError(t, err)
+	assert.NotSame(t, first, svg)
+
+	// 超出容量时淘汰旧缓存
+	_, err = renderer.RenderCached("P2", codeURL, FormatPNG, 0, expiresAt.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, renderer.cache, 2)
+
+	// 支付过期后不再缓存
+	now = expiresAt.Add(time.Hour)
+	third, err := renderer.RenderCached("P1", codeURL, FormatPNG, 0, expiresAt)
+	require.NoError(t, err)
+	assert.NotSame(t, first, third)
+}
+
+func TestParseOptions(t *testing.T) {
+	format, err := ParseFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, FormatPNG, format)
+
+	format, err = ParseFormat("SVG")
+	require.NoError(t, err)
+	assert.Equal(t, FormatSVG, format)
+
+	_, err = ParseFormat("gif")
+	assert.ErrorIs(t, err, model.ErrInvalidQRCodeFormat)
+
+	options := DefaultOptions()
+	options.RecoveryLevel = "X"
+	_, err = NewRenderer(options)
+	assert.Error(t, err)
+}
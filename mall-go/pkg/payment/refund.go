@@ -0,0 +1,643 @@
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/payment/alipay"
+	"mall-go/pkg/payment/wechat"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// refundResult 第三方退款结果
+type refundResult struct {
+	Status       model.PaymentStatus // 标准化退款状态: pending/success/failed
+	ThirdPartyID string              // 第三方退款单号
+	RefundedAt   *time.Time          // 退款完成时间
+	Message      string              // 失败原因
+	RawData      string              // 第三方返回数据
+}
+
+// RefundHook 退款单终态回调，在更新退款单状态的事务内执行
+type RefundHook func(tx *gorm.DB, refund *model.PaymentRefund) error
+
+// RefundPayment 退款
+// 退款单创建后先落库再调用渠道，渠道异步处理时退款单保持 pending，
+// 由退款通知或退款查询确认最终结果。携带相同退款单号重复提交时不会重复退款。
+func (s *Service) RefundPayment(req *model.PaymentRefundRequest) (*model.PaymentRefundResponse, error) {
+	logger.Info("处理退款请求",
+		zap.Uint("payment_id", req.PaymentID),
+		zap.String("refund_no", req.RefundNo),
+		zap.String("refund_amount", req.RefundAmount.String()))
+
+	// 验证请求
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// 查询支付记录
+	var payment model.Payment
+	if err := s.db.First(&payment, req.PaymentID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, model.ErrPaymentNotFound
+		}
+		return nil, fmt.Errorf("查询支付记录失败: %v", err)
+	}
+
+	// 幂等处理：相同退款单号返回已有退款单，处理中的退款单重新提交渠道
+	if req.RefundNo != "" {
+		var existing model.PaymentRefund
+		err := s.db.Where("refund_no = ?", req.RefundNo).First(&existing).Error
+		if err == nil {
+			if existing.PaymentID != payment.ID || !existing.RefundAmount.Equal(req.RefundAmount) {
+				return nil, fmt.Errorf("退款单号 %s 已被其他退款请求使用", req.RefundNo)
+			}
+			if existing.RefundStatus == model.PaymentStatusPending {
+				return s.submitRefund(&payment, &existing)
+			}
+			return s.buildRefundResponse(&existing), nil
+		}
+		if err != gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("查询退款记录失败: %v", err)
+		}
+	}
+
+	// 检查支付状态
+	if payment.PaymentStatus != model.PaymentStatusSuccess {
+		return nil, fmt.Errorf("只有支付成功的订单才能退款")
+	}
+
+	// 检查退款金额
+	if req.RefundAmount.GreaterThan(payment.Amount) {
+		return nil, fmt.Errorf("退款金额不能大于支付金额")
+	}
+
+	// 生成退款单号
+	refundNo := req.RefundNo
+	if refundNo == "" {
+		refundNo = s.generateRefundNo()
+	}
+
+	// 创建退款记录，调用渠道前先落库，保证退款单号在重试时保持不变
+	refund := &model.PaymentRefund{
+		RefundNo:     refundNo,
+		PaymentID:    payment.ID,
+		UserID:       payment.UserID,
+		RefundAmount: req.RefundAmount,
+		RefundReason: req.RefundReason,
+		RefundStatus: model.PaymentStatusPending,
+	}
+
+	if err := s.createRefund(refund, payment.Amount); err != nil {
+		return nil, err
+	}
+
+	return s.submitRefund(&payment, refund)
+}
+
+// createRefund 校验可退金额并创建退款单
+// 锁定支付记录后统计已占用额度再写入退款单，并发退款不会超过支付金额
+func (s *Service) createRefund(refund *model.PaymentRefund, paymentAmount decimal.Decimal) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var locked model.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&locked, refund.PaymentID).Error; err != nil {
+			return fmt.Errorf("锁定支付记录失败: %v", err)
+		}
+
+		// 处理中和已成功的退款都占用额度
+		refunded, err := s.sumRefundAmount(tx, refund.PaymentID, model.PaymentStatusPending, model.PaymentStatusSuccess)
+		if err != nil {
+			return err
+		}
+		if refunded.Add(refund.RefundAmount).GreaterThan(paymentAmount) {
+			return model.ErrRefundAmountExceeded
+		}
+
+		if err := tx.Create(refund).Error; err != nil {
+			return fmt.Errorf("创建退款记录失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// submitRefund 提交退款到第三方渠道
+func (s *Service) submitRefund(payment *model.Payment, refund *model.PaymentRefund) (*model.PaymentRefundResponse, error) {
+	result, err := s.callThirdPartyRefund(payment, refund)
+	if err != nil {
+		// 调用异常时结果未知，退款单保持 pending，可使用相同退款单号重试或查询
+		s.logPaymentAction(payment.ID, "REFUND", "ERROR", "调用第三方退款失败", refund.RefundNo, err.Error())
+		return nil, fmt.Errorf("调用第三方退款失败: %v", err)
+	}
+
+	if err := s.applyRefundResult(refund, result); err != nil {
+		return nil, err
+	}
+
+	return s.buildRefundResponse(refund), nil
+}
+
+// callThirdPartyRefund 调用第三方退款
+func (s *Service) callThirdPartyRefund(payment *model.Payment, refund *model.PaymentRefund) (*refundResult, error) {
+	switch payment.PaymentMethod {
+	case model.PaymentMethodAlipay:
+		return s.refundAlipayPayment(payment, refund)
+	case model.PaymentMethodWechat:
+		return s.refundWechatPayment(payment, refund)
+	default:
+		return nil, fmt.Errorf("不支持的支付方式: %s", payment.PaymentMethod)
+	}
+}
+
+// refundAlipayPayment 支付宝退款
+func (s *Service) refundAlipayPayment(payment *model.Payment, refund *model.PaymentRefund) (*refundResult, error) {
+	alipayClient := s.AlipayClient()
+	if alipayClient == nil {
+		return nil, fmt.Errorf("支付宝客户端未初始化")
+	}
+
+	resp, err := alipayClient.Refund(&alipay.RefundRequest{
+		OutTradeNo:   payment.PaymentNo,
+		TradeNo:      payment.ThirdPartyID,
+		RefundAmount: refund.RefundAmount,
+		RefundReason: refund.RefundReason,
+		OutRequestNo: refund.RefundNo,
+		Currency:     payment.Currency,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rawData, _ := json.Marshal(resp)
+	result := &refundResult{
+		Status:       resp.Status,
+		ThirdPartyID: resp.TradeNo,
+		Message:      resp.Message,
+		RawData:      string(rawData),
+	}
+
+	if resp.Status == model.PaymentStatusSuccess {
+		result.RefundedAt = parseChannelTime(resp.GmtRefundPay)
+	}
+
+	return result, nil
+}
+
+// refundWechatPayment 微信支付退款
+func (s *Service) refundWechatPayment(payment *model.Payment, refund *model.PaymentRefund) (*refundResult, error) {
+	client := s.wechatPayClient()
+	if client == nil {
+		return nil, fmt.Errorf("微信支付客户端未初始化")
+	}
+
+	resp, err := client.Refund(&wechat.RefundRequest{
+		OutTradeNo:    payment.PaymentNo,
+		TransactionID: payment.ThirdPartyID,
+		OutRefundNo:   refund.RefundNo,
+		TotalFee:      payment.Amount,
+		RefundFee:     refund.RefundAmount,
+		Currency:      payment.Currency,
+		RefundDesc:    refund.RefundReason,
+		NotifyURL:     s.configManager.GetConfig().Wechat.RefundNotifyURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rawData, _ := json.Marshal(resp)
+	return &refundResult{
+		Status:       resp.Status,
+		ThirdPartyID: resp.RefundID,
+		Message:      resp.Message,
+		RawData:      string(rawData),
+	}, nil
+}
+
+// applyRefundResult 根据第三方结果更新退款单
+// 只有 pending 状态的退款单会被更新，重复的通知或查询结果不会重复记账
+func (s *Service) applyRefundResult(refund *model.PaymentRefund, result *refundResult) error {
+	if result.Status == model.PaymentStatusPending {
+		updates := map[string]interface{}{"third_party_data": result.RawData}
+		if result.ThirdPartyID != "" {
+			updates["third_party_refund_id"] = result.ThirdPartyID
+		}
+		if err := s.db.Model(refund).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新退款记录失败: %v", err)
+		}
+		return nil
+	}
+
+	// 开启事务
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	updates := map[string]interface{}{
+		"refund_status":    result.Status,
+		"third_party_data": result.RawData,
+	}
+	if result.ThirdPartyID != "" {
+		updates["third_party_refund_id"] = result.ThirdPartyID
+	}
+	if result.Status == model.PaymentStatusSuccess {
+		refundedAt := time.Now()
+		if result.RefundedAt != nil {
+			refundedAt = *result.RefundedAt
+		}
+		updates["refunded_at"] = &refundedAt
+	}
+
+	updateResult := tx.Model(&model.PaymentRefund{}).
+		Where("id = ? AND refund_status = ?", refund.ID, model.PaymentStatusPending).
+		Updates(updates)
+	if updateResult.Error != nil {
+		tx.Rollback()
+		return fmt.Errorf("更新退款记录失败: %v", updateResult.Error)
+	}
+
+	if updateResult.RowsAffected == 0 {
+		// 已被其他通知处理
+		tx.Rollback()
+		return s.db.First(refund, refund.ID).Error
+	}
+
+	// 退款单终态与业务回调（如售后单完成）同时提交
+	settled := *refund
+	settled.RefundStatus = result.Status
+	if refundedAt, ok := updates["refunded_at"].(*time.Time); ok {
+		settled.RefundedAt = refundedAt
+	}
+	for _, hook := range s.refundHooks {
+		if err := hook(tx, &settled); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("处理退款结果失败: %v", err)
+		}
+	}
+
+	// 全额退款后更新支付状态
+	var syncEventID string
+	if result.Status == model.PaymentStatusSuccess {
+		var payment model.Payment
+		if err := tx.First(&payment, refund.PaymentID).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("查询支付记录失败: %v", err)
+		}
+
+		refunded, err := s.sumRefundAmount(tx, payment.ID, model.PaymentStatusSuccess)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if refunded.GreaterThanOrEqual(payment.Amount) {
+			if err := tx.Model(&payment).Update("payment_status", model.PaymentStatusRefunded).Error; err != nil {
+				tx.Rollback()
+				return fmt.Errorf("更新支付状态失败: %v", err)
+			}
+
+			// 订单状态由同步事件更新
+			if s.syncManager != nil {
+				syncEventID, err = s.syncManager.PublishEventTx(tx, SyncEventRefundSuccess, payment.ID, payment.OrderID, payment.UserID,
+					map[string]interface{}{"refund_no": refund.RefundNo})
+				if err != nil {
+					tx.Rollback()
+					return err
+				}
+			}
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("提交事务失败: %v", err)
+	}
+
+	if syncEventID != "" {
+		s.syncManager.Wake(syncEventID)
+	}
+
+	if err := s.db.First(refund, refund.ID).Error; err != nil {
+		return fmt.Errorf("查询退款记录失败: %v", err)
+	}
+
+	if result.Status == model.PaymentStatusSuccess {
+		s.logPaymentAction(refund.PaymentID, "REFUND", "SUCCESS", "退款成功", refund.RefundNo, result.RawData)
+	} else {
+		s.logPaymentAction(refund.PaymentID, "REFUND", "FAILED", "退款失败: "+result.Message, refund.RefundNo, result.RawData)
+	}
+
+	return nil
+}
+
+// QueryRefund 查询退款状态
+// 退款单处理中时会向第三方查询并同步最新状态
+func (s *Service) QueryRefund(refundNo string) (*model.PaymentRefundResponse, error) {
+	var refund model.PaymentRefund
+	if err := s.db.Where("refund_no = ?", refundNo).First(&refund).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, model.ErrRefundNotFound
+		}
+		return nil, fmt.Errorf("查询退款记录失败: %v", err)
+	}
+
+	if refund.RefundStatus == model.PaymentStatusPending {
+		if err := s.syncRefundStatus(&refund); err != nil {
+			logger.Error("同步退款状态失败", zap.String("refund_no", refundNo), zap.Error(err))
+		}
+	}
+
+	return s.buildRefundResponse(&refund), nil
+}
+
+// syncRefundStatus 同步退款状态
+func (s *Service) syncRefundStatus(refund *model.PaymentRefund) error {
+	var payment model.Payment
+	if err := s.db.First(&payment, refund.PaymentID).Error; err != nil {
+		return fmt.Errorf("查询支付记录失败: %v", err)
+	}
+
+	var result *refundResult
+	switch payment.PaymentMethod {
+	case model.PaymentMethodAlipay:
+		alipayClient := s.AlipayClient()
+		if alipayClient == nil {
+			return fmt.Errorf("支付宝客户端未初始化")
+		}
+		resp, err := alipayClient.QueryRefund(payment.PaymentNo, refund.RefundNo)
+		if err != nil {
+			return err
+		}
+		if !resp.Accepted() {
+			// 提交时支付宝系统繁忙未受理，使用相同的退款请求号重新提交，支付宝保证不会重复退款
+			result, err = s.refundAlipayPayment(&payment, refund)
+			if err != nil {
+				return err
+			}
+			break
+		}
+		rawData, _ := json.Marshal(resp)
+		result = &refundResult{
+			Status:       resp.Status,
+			ThirdPartyID: resp.TradeNo,
+			RefundedAt:   parseChannelTime(resp.GmtRefundPay),
+			RawData:      string(rawData),
+		}
+	case model.PaymentMethodWechat:
+		client := s.wechatPayClient()
+		if client == nil {
+			return fmt.Errorf("微信支付客户端未初始化")
+		}
+		resp, err := client.QueryRefund(refund.RefundNo)
+		if err != nil {
+			return err
+		}
+		rawData, _ := json.Marshal(resp)
+		result = &refundResult{
+			Status:       resp.Status,
+			ThirdPartyID: resp.RefundID,
+			RefundedAt:   parseChannelTime(resp.RefundSuccessTime),
+			Message:      resp.RefundStatus,
+			RawData:      string(rawData),
+		}
+	default:
+		return fmt.Errorf("不支持的支付方式: %s", payment.PaymentMethod)
+	}
+
+	return s.applyRefundResult(refund, result)
+}
+
+// ProcessRefundCallback 处理退款通知
+func (s *Service) ProcessRefundCallback(method model.PaymentMethod, data []byte) error {
+	logger.Info("处理退款通知",
+		zap.String("payment_method", string(method)),
+		zap.Int("data_size", len(data)))
+
+	switch method {
+	case model.PaymentMethodAlipay:
+		return s.processAlipayRefundCallback(data)
+	case model.PaymentMethodWechat:
+		return s.processWechatRefundCallback(data)
+	default:
+		return fmt.Errorf("不支持的支付方式: %s", method)
+	}
+}
+
+// processAlipayRefundCallback 处理支付宝退款通知
+func (s *Service) processAlipayRefundCallback(data []byte) error {
+	alipayClient := s.AlipayClient()
+	if alipayClient == nil {
+		return fmt.Errorf("支付宝客户端未初始化")
+	}
+
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return model.NewPaymentCallbackValidationError(fmt.Errorf("解析支付宝退款通知失败: %v", err))
+	}
+
+	params := make(map[string]string)
+	for key := range values {
+		params[key] = values.Get(key)
+	}
+
+	if err := alipayClient.VerifyCallback(params); err != nil {
+		return model.NewPaymentCallbackValidationError(fmt.Errorf("支付宝退款通知签名验证失败: %v", err))
+	}
+
+	return s.HandleAlipayRefundNotify(params)
+}
+
+// HandleAlipayRefundNotify 处理已验签的支付宝退款通知参数
+func (s *Service) HandleAlipayRefundNotify(params map[string]string) error {
+	notify := alipay.ParseRefundNotify(params)
+	if notify == nil {
+		return model.NewPaymentCallbackValidationError(fmt.Errorf("不是有效的支付宝退款通知"))
+	}
+
+	var refund model.PaymentRefund
+	if err := s.db.Where("refund_no = ?", notify.OutBizNo).First(&refund).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			logger.Warn("退款记录不存在", zap.String("refund_no", notify.OutBizNo))
+			return nil // 返回成功，避免重复通知
+		}
+		return fmt.Errorf("查询退款记录失败: %v", err)
+	}
+
+	var payment model.Payment
+	if err := s.db.First(&payment, refund.PaymentID).Error; err != nil {
+		return fmt.Errorf("查询支付记录失败: %v", err)
+	}
+	if err := checkRefundNotifyTrade(&payment, notify.OutTradeNo, notify.TradeNo); err != nil {
+		return err
+	}
+
+	// 支付宝通知中的 refund_fee 为交易累计退款金额，应等于其他已成功退款与本退款单之和
+	refunded, err := s.sumRefundAmount(s.db.Where("id <> ?", refund.ID), payment.ID, model.PaymentStatusSuccess)
+	if err != nil {
+		return err
+	}
+	if !notify.RefundFee.Equal(refunded.Add(refund.RefundAmount)) {
+		return fmt.Errorf("退款金额不匹配")
+	}
+
+	refundedAt, _ := notify.GetRefundTime()
+	rawData, _ := json.Marshal(notify)
+
+	return s.applyRefundResult(&refund, &refundResult{
+		Status:       model.PaymentStatusSuccess,
+		ThirdPartyID: notify.TradeNo,
+		RefundedAt:   refundedAt,
+		RawData:      string(rawData),
+	})
+}
+
+// processWechatRefundCallback 处理微信退款通知
+func (s *Service) processWechatRefundCallback(data []byte) error {
+	wechatClient := s.wechatV2Client()
+	if wechatClient == nil {
+		return fmt.Errorf("微信支付客户端未初始化")
+	}
+
+	// req_info 使用商户密钥加密，解密成功即可确认通知来源
+	notify, err := wechatClient.ParseRefundNotify(data)
+	if err != nil {
+		return model.NewPaymentCallbackValidationError(err)
+	}
+
+	return s.applyWechatRefundNotify(notify)
+}
+
+// ProcessWechatV3RefundCallback 处理微信支付APIv3退款结果通知
+func (s *Service) ProcessWechatV3RefundCallback(header http.Header, body []byte) error {
+	return s.processWechatV3RefundCallback(header, body, false)
+}
+
+// ReplayWechatV3RefundCallback 人工重放保存的微信支付APIv3退款结果通知，不校验签名时间戳是否过期
+func (s *Service) ReplayWechatV3RefundCallback(header http.Header, body []byte) error {
+	return s.processWechatV3RefundCallback(header, body, true)
+}
+
+// processWechatV3RefundCallback 验签解密并处理微信支付APIv3退款结果通知
+func (s *Service) processWechatV3RefundCallback(header http.Header, body []byte, replay bool) error {
+	wechatV3 := s.wechatV3Client()
+	if wechatV3 == nil {
+		return fmt.Errorf("微信支付APIv3客户端未初始化")
+	}
+
+	parse := wechatV3.ParseRefundNotify
+	if replay {
+		parse = wechatV3.ParseReplayedRefundNotify
+	}
+	notify, err := parse(header, body)
+	if err != nil {
+		return model.NewPaymentCallbackValidationError(err)
+	}
+
+	return s.applyWechatRefundNotify(notify)
+}
+
+// applyWechatRefundNotify 根据已解密的微信退款通知更新退款单
+func (s *Service) applyWechatRefundNotify(notify *wechat.RefundNotifyData) error {
+	var refund model.PaymentRefund
+	if err := s.db.Where("refund_no = ?", notify.OutRefundNo).First(&refund).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			logger.Warn("退款记录不存在", zap.String("refund_no", notify.OutRefundNo))
+			return nil // 返回成功，避免重复通知
+		}
+		return fmt.Errorf("查询退款记录失败: %v", err)
+	}
+
+	var payment model.Payment
+	if err := s.db.First(&payment, refund.PaymentID).Error; err != nil {
+		return fmt.Errorf("查询支付记录失败: %v", err)
+	}
+	if err := checkRefundNotifyTrade(&payment, notify.OutTradeNo, notify.TransactionID); err != nil {
+		return err
+	}
+
+	if !notify.GetRefundFee(payment.Currency).Equal(refund.RefundAmount) {
+		return fmt.Errorf("退款金额不匹配")
+	}
+
+	refundedAt, _ := notify.GetRefundTime()
+	rawData, _ := json.Marshal(notify)
+
+	return s.applyRefundResult(&refund, &refundResult{
+		Status:       notify.ToPaymentStatus(),
+		ThirdPartyID: notify.RefundID,
+		RefundedAt:   refundedAt,
+		Message:      notify.RefundStatus,
+		RawData:      string(rawData),
+	})
+}
+
+// checkRefundNotifyTrade 校验退款通知中的商户订单号和渠道交易号属于退款单对应的支付
+func checkRefundNotifyTrade(payment *model.Payment, outTradeNo, tradeNo string) error {
+	if outTradeNo != payment.PaymentNo {
+		return fmt.Errorf("退款通知商户订单号不匹配")
+	}
+	if tradeNo != "" && payment.ThirdPartyID != "" && tradeNo != payment.ThirdPartyID {
+		return fmt.Errorf("退款通知渠道交易号不匹配")
+	}
+	return nil
+}
+
+// sumRefundAmount 统计指定状态的退款金额
+func (s *Service) sumRefundAmount(db *gorm.DB, paymentID uint, statuses ...model.PaymentStatus) (decimal.Decimal, error) {
+	var refunds []model.PaymentRefund
+	if err := db.Select("refund_amount").
+		Where("payment_id = ? AND refund_status IN ?", paymentID, statuses).
+		Find(&refunds).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("统计退款金额失败: %v", err)
+	}
+
+	total := decimal.Zero
+	for _, refund := range refunds {
+		total = total.Add(refund.RefundAmount)
+	}
+	return total, nil
+}
+
+// buildRefundResponse 构建退款响应
+func (s *Service) buildRefundResponse(refund *model.PaymentRefund) *model.PaymentRefundResponse {
+	return &model.PaymentRefundResponse{
+		RefundID:     refund.ID,
+		RefundNo:     refund.RefundNo,
+		PaymentID:    refund.PaymentID,
+		RefundAmount: refund.RefundAmount,
+		RefundStatus: refund.RefundStatus,
+		RefundReason: refund.RefundReason,
+		ThirdPartyID: refund.ThirdPartyRefundID,
+		RefundedAt:   refund.RefundedAt,
+		CreatedAt:    refund.CreatedAt,
+	}
+}
+
+// generateRefundNo 生成退款单号
+func (s *Service) generateRefundNo() string {
+	return fmt.Sprintf("REF%d", time.Now().UnixNano())
+}
+
+// parseChannelTime 解析第三方返回的时间，格式: 2006-01-02 15:04:05
+func parseChannelTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
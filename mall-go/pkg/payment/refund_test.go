@@ -0,0 +1,258 @@
+package payment
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/payment/wechat"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// alipayGatewayFunc 按接口方法和业务参数返回模拟支付宝网关的响应
+type alipayGatewayFunc func(method string, bizContent map[string]interface{}) string
+
+// newAlipayTestConfig 启动模拟支付宝网关，返回启用支付宝并指向该网关的支付配置
+func newAlipayTestConfig(t *testing.T, gateway alipayGatewayFunc) *PaymentConfig {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		var bizContent map[string]interface{}
+		_ = json.Unmarshal([]byte(r.Form.Get("biz_content")), &bizContent)
+		_, _ = w.Write([]byte(gateway(r.Form.Get("method"), bizContent)))
+	}))
+	t.Cleanup(server.Close)
+
+	config := DefaultPaymentConfig()
+	config.Alipay.Enabled = true
+	config.Alipay.AppID = "2021000000000000"
+	config.Alipay.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	config.Alipay.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}))
+	config.Alipay.GatewayURL = server.URL
+	return config
+}
+
+// createRefundTestPayment 创建已支付的支付宝支付记录
+func createRefundTestPayment(t *testing.T, db *gorm.DB, paymentNo string, amount int64) *model.Payment {
+	user, order := createTestData(db)
+	payment := &model.Payment{
+		PaymentNo:     paymentNo,
+		OrderID:       order.ID,
+		UserID:        user.ID,
+		PaymentMethod: model.PaymentMethodAlipay,
+		PaymentStatus: model.PaymentStatusSuccess,
+		Amount:        decimal.NewFromInt(amount),
+		ThirdPartyID:  "2024010122001400000000000001",
+	}
+	require.NoError(t, db.Create(payment).Error)
+	return payment
+}
+
+func TestService_RefundPayment_ConcurrentLimit(t *testing.T) {
+	db := setupTestDB()
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1) // 内存库每个连接独立，共用同一连接
+
+	user, order := createTestData(db)
+	payment := &model.Payment{
+		PaymentNo:     "PAY_CONCURRENT",
+		OrderID:       order.ID,
+		UserID:        user.ID,
+		PaymentMethod: model.PaymentMethodAlipay,
+		PaymentStatus: model.PaymentStatusSuccess,
+		Amount:        decimal.NewFromInt(100),
+	}
+	require.NoError(t, db.Create(payment).Error)
+
+	service, err := NewService(db, DefaultPaymentConfig())
+	require.NoError(t, err)
+
+	// 未配置渠道客户端时提交渠道失败，退款单保持处理中并继续占用额度
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	exceeded := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := service.RefundPayment(&model.PaymentRefundRequest{
+				PaymentID:    payment.ID,
+				RefundAmount: decimal.NewFromInt(30),
+				RefundReason: "并发退款",
+				RefundNo:     fmt.Sprintf("REF_CONCURRENT_%d", i),
+			})
+			if err == model.ErrRefundAmountExceeded {
+				mu.Lock()
+				exceeded++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	refunded, err := service.sumRefundAmount(db, payment.ID, model.PaymentStatusPending, model.PaymentStatusSuccess)
+	require.NoError(t, err)
+	assert.True(t, refunded.Equal(decimal.NewFromInt(90)), "累计退款不能超过支付金额，实际 %s", refunded)
+	assert.Equal(t, 17, exceeded)
+}
+
+func TestService_RefundPayment_AlipaySystemErrorRetriedWithSameRequestNo(t *testing.T) {
+	db := setupTestDB()
+	payment := createRefundTestPayment(t, db, "PAY_SYSTEM_ERROR", 100)
+
+	var refundRequests []string
+	service, err := NewService(db, newAlipayTestConfig(t, func(method string, bizContent map[string]interface{}) string {
+		switch method {
+		case "alipay.trade.refund":
+			refundRequests = append(refundRequests, bizContent["out_request_no"].(string))
+			if len(refundRequests) == 1 {
+				return `{"alipay_trade_refund_response":{"code":"40004","msg":"Business Failed","sub_code":"ACQ.SYSTEM_ERROR","sub_msg":"系统错误"}}`
+			}
+			return `{"alipay_trade_refund_response":{"code":"10000","msg":"Success","out_trade_no":"PAY_SYSTEM_ERROR","trade_no":"2024010122001400000000000001","refund_fee":"30.00","fund_change":"Y"}}`
+		case "alipay.trade.fastpay.refund.query":
+			// 退款请求未被受理时查询不到退款数据
+			return `{"alipay_trade_fastpay_refund_query_response":{"code":"10000","msg":"Success","out_trade_no":"PAY_SYSTEM_ERROR","out_request_no":"REF_SYSTEM_ERROR"}}`
+		}
+		return `{}`
+	}))
+	require.NoError(t, err)
+
+	resp, err := service.RefundPayment(&model.PaymentRefundRequest{
+		PaymentID:    payment.ID,
+		RefundAmount: decimal.NewFromInt(30),
+		RefundReason: "系统繁忙重试",
+		RefundNo:     "REF_SYSTEM_ERROR",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusPending, resp.RefundStatus)
+
+	// 查询退款时发现未受理，使用相同的退款请求号重新提交
+	resp, err = service.QueryRefund("REF_SYSTEM_ERROR")
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusSuccess, resp.RefundStatus)
+	assert.Equal(t, []string{"REF_SYSTEM_ERROR", "REF_SYSTEM_ERROR"}, refundRequests)
+}
+
+func TestService_RefundPayment_AlipayBusinessFailure(t *testing.T) {
+	db := setupTestDB()
+	payment := createRefundTestPayment(t, db, "PAY_BUSINESS_FAILED", 100)
+
+	service, err := NewService(db, newAlipayTestConfig(t, func(method string, bizContent map[string]interface{}) string {
+		return `{"alipay_trade_refund_response":{"code":"40004","msg":"Business Failed","sub_code":"ACQ.TRADE_NOT_ALLOW_REFUND","sub_msg":"当前交易不允许退款"}}`
+	}))
+	require.NoError(t, err)
+
+	resp, err := service.RefundPayment(&model.PaymentRefundRequest{
+		PaymentID:    payment.ID,
+		RefundAmount: decimal.NewFromInt(30),
+		RefundReason: "不允许退款",
+		RefundNo:     "REF_BUSINESS_FAILED",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentStatusFailed, resp.RefundStatus)
+}
+
+func TestService_HandleAlipayRefundNotify(t *testing.T) {
+	db := setupTestDB()
+	payment := createRefundTestPayment(t, db, "PAY_NOTIFY", 100)
+	service, err := NewService(db, DefaultPaymentConfig())
+	require.NoError(t, err)
+
+	first := &model.PaymentRefund{RefundNo: "REF_NOTIFY_1", PaymentID: payment.ID, UserID: payment.UserID, RefundAmount: decimal.NewFromInt(30), RefundStatus: model.PaymentStatusPending}
+	second := &model.PaymentRefund{RefundNo: "REF_NOTIFY_2", PaymentID: payment.ID, UserID: payment.UserID, RefundAmount: decimal.NewFromInt(20), RefundStatus: model.PaymentStatusPending}
+	require.NoError(t, db.Create(first).Error)
+	require.NoError(t, db.Create(second).Error)
+
+	notify := func(outTradeNo, tradeNo, outBizNo, refundFee string) map[string]string {
+		return map[string]string{
+			"out_trade_no": outTradeNo,
+			"trade_no":     tradeNo,
+			"out_biz_no":   outBizNo,
+			"refund_fee":   refundFee,
+			"gmt_refund":   "2024-01-02 10:00:00",
+		}
+	}
+	status := func(refund *model.PaymentRefund) model.PaymentStatus {
+		var reloaded model.PaymentRefund
+		require.NoError(t, db.First(&reloaded, refund.ID).Error)
+		return reloaded.RefundStatus
+	}
+
+	// 订单号、交易号或金额与退款单不符的通知不更新退款单
+	assert.Error(t, service.HandleAlipayRefundNotify(notify("PAY_OTHER", payment.ThirdPartyID, "REF_NOTIFY_1", "30.00")))
+	assert.Error(t, service.HandleAlipayRefundNotify(notify("PAY_NOTIFY", "2024010122001400000000000002", "REF_NOTIFY_1", "30.00")))
+	assert.Error(t, service.HandleAlipayRefundNotify(notify("PAY_NOTIFY", payment.ThirdPartyID, "REF_NOTIFY_1", "50.00")))
+	assert.Equal(t, model.PaymentStatusPending, status(first))
+
+	require.NoError(t, service.HandleAlipayRefundNotify(notify("PAY_NOTIFY", payment.ThirdPartyID, "REF_NOTIFY_1", "30.00")))
+	assert.Equal(t, model.PaymentStatusSuccess, status(first))
+
+	// 部分退款时 refund_fee 为累计退款金额
+	assert.Error(t, service.HandleAlipayRefundNotify(notify("PAY_NOTIFY", payment.ThirdPartyID, "REF_NOTIFY_2", "20.00")))
+	require.NoError(t, service.HandleAlipayRefundNotify(notify("PAY_NOTIFY", payment.ThirdPartyID, "REF_NOTIFY_2", "50.00")))
+	assert.Equal(t, model.PaymentStatusSuccess, status(second))
+
+	// 重复通知不重复记账
+	require.NoError(t, service.HandleAlipayRefundNotify(notify("PAY_NOTIFY", payment.ThirdPartyID, "REF_NOTIFY_2", "50.00")))
+}
+
+func TestService_ApplyWechatRefundNotify_MinorUnits(t *testing.T) {
+	db := setupTestDB()
+	service, err := NewService(db, DefaultPaymentConfig())
+	require.NoError(t, err)
+
+	// 微信退款通知金额为支付币种的最小货币单位：JPY 无小数位，KWD 为三位小数
+	cases := []struct {
+		code      string
+		amount    decimal.Decimal
+		refund    decimal.Decimal
+		refundFee string
+	}{
+		{"JPY", decimal.NewFromInt(1000), decimal.NewFromInt(300), "300"},
+		{"KWD", decimal.NewFromInt(10), decimal.RequireFromString("2.5"), "2500"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.code, func(t *testing.T) {
+			payment := createRefundTestPayment(t, db, "PAY_WX_"+tc.code, 0)
+			require.NoError(t, db.Model(payment).Updates(map[string]interface{}{
+				"payment_method": model.PaymentMethodWechat,
+				"currency":       tc.code,
+				"amount":         tc.amount,
+			}).Error)
+
+			refund := &model.PaymentRefund{RefundNo: "REF_WX_" + tc.code, PaymentID: payment.ID, UserID: payment.UserID, RefundAmount: tc.refund, RefundStatus: model.PaymentStatusPending}
+			require.NoError(t, db.Create(refund).Error)
+
+			notify := &wechat.RefundNotifyData{
+				OutTradeNo:   payment.PaymentNo,
+				RefundID:     "50000000382019052709732678859",
+				OutRefundNo:  refund.RefundNo,
+				RefundFee:    tc.refundFee,
+				RefundStatus: wechat.RefundStatusSuccess,
+				SuccessTime:  "2024-01-02 10:00:00",
+			}
+			require.NoError(t, service.applyWechatRefundNotify(notify))
+
+			var reloaded model.PaymentRefund
+			require.NoError(t, db.First(&reloaded, refund.ID).Error)
+			assert.Equal(t, model.PaymentStatusSuccess, reloaded.RefundStatus)
+		})
+	}
+}
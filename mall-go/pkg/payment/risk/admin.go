@@ -0,0 +1,145 @@
+package risk
+
+import (
+	"fmt"
+	"strings"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/pagination"
+
+	"gorm.io/gorm"
+)
+
+// ListRules 查询全部风控规则
+func (e *Engine) ListRules() ([]model.PaymentRiskRule, error) {
+	var rules []model.PaymentRiskRule
+	if err := e.db.Order("priority ASC, id ASC").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("查询风控规则失败: %v", err)
+	}
+	return rules, nil
+}
+
+// SaveRule 按规则编码新增或更新风控规则，保存后立即生效
+func (e *Engine) SaveRule(req *model.PaymentRiskRuleRequest) (*model.PaymentRiskRule, error) {
+	if _, ok := getEvaluator(req.RuleType); !ok {
+		return nil, model.ErrRiskRuleTypeUnsupported
+	}
+	if _, err := ParseRuleParams(req.Params); err != nil {
+		return nil, err
+	}
+
+	var rule model.PaymentRiskRule
+	err := e.db.Where("code = ?", req.Code).First(&rule).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("查询风控规则失败: %v", err)
+	}
+	if err == gorm.ErrRecordNotFound {
+		rule = model.PaymentRiskRule{Code: req.Code, IsEnabled: true}
+	}
+
+	rule.Name = req.Name
+	rule.RuleType = req.RuleType
+	rule.Params = req.Params
+	rule.Action = req.Action
+	rule.Score = req.Score
+	rule.Priority = req.Priority
+	if req.IsEnabled != nil {
+		rule.IsEnabled = *req.IsEnabled
+	}
+
+	if err := e.db.Save(&rule).Error; err != nil {
+		return nil, fmt.Errorf("保存风控规则失败: %v", err)
+	}
+	e.Reload()
+	return &rule, nil
+}
+
+// SetRuleEnabled 启用或停用风控规则
+func (e *Engine) SetRuleEnabled(id uint, enabled bool) error {
+	result := e.db.Model(&model.PaymentRiskRule{}).Where("id = ?", id).Update("is_enabled", enabled)
+	if result.Error != nil {
+		return fmt.Errorf("更新风控规则失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrRiskRuleNotFound
+	}
+	e.Reload()
+	return nil
+}
+
+// ListBlacklist 查询黑名单
+func (e *Engine) ListBlacklist(listType string, page, pageSize int) ([]model.PaymentRiskBlacklist, int64, error) {
+	page, pageSize = pagination.Normalize(page, pageSize)
+
+	query := e.db.Model(&model.PaymentRiskBlacklist{})
+	if listType != "" {
+		query = query.Where("list_type = ?", listType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计黑名单失败: %v", err)
+	}
+
+	var entries []model.PaymentRiskBlacklist
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&entries).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询黑名单失败: %v", err)
+	}
+	return entries, total, nil
+}
+
+// AddBlacklist 添加黑名单，已存在时更新原因与过期时间
+func (e *Engine) AddBlacklist(req *model.PaymentRiskBlacklistRequest, operatorID uint) (*model.PaymentRiskBlacklist, error) {
+	value := strings.TrimSpace(req.Value)
+
+	var entry model.PaymentRiskBlacklist
+	err := e.db.Where("list_type = ? AND value = ?", req.ListType, value).First(&entry).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("查询黑名单失败: %v", err)
+	}
+
+	entry.ListType = req.ListType
+	entry.Value = value
+	entry.Reason = req.Reason
+	entry.ExpiresAt = req.ExpiresAt
+	entry.OperatorID = operatorID
+	if err := e.db.Save(&entry).Error; err != nil {
+		return nil, fmt.Errorf("保存黑名单失败: %v", err)
+	}
+	return &entry, nil
+}
+
+// RemoveBlacklist 移除黑名单
+func (e *Engine) RemoveBlacklist(id uint) error {
+	if err := e.db.Delete(&model.PaymentRiskBlacklist{}, id).Error; err != nil {
+		return fmt.Errorf("删除黑名单失败: %v", err)
+	}
+	return nil
+}
+
+// ListDecisions 查询风控决策记录
+func (e *Engine) ListDecisions(query *model.PaymentRiskDecisionQuery) ([]model.PaymentRiskDecision, int64, error) {
+	query.Page, query.PageSize = pagination.Normalize(query.Page, query.PageSize)
+
+	db := e.db.Model(&model.PaymentRiskDecision{})
+	if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+	if query.UserID > 0 {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if query.OrderID > 0 {
+		db = db.Where("order_id = ?", query.OrderID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计风控决策失败: %v", err)
+	}
+
+	var decisions []model.PaymentRiskDecision
+	if err := db.Order("id DESC").Offset((query.Page - 1) * query.PageSize).Limit(query.PageSize).Find(&decisions).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询风控决策失败: %v", err)
+	}
+	return decisions, total, nil
+}
@@ -0,0 +1,326 @@
+package risk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/verification"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 二次验证渠道
+const (
+	ChannelSMS   = "sms"
+	ChannelEmail = "email"
+)
+
+// Options 风控引擎配置
+type Options struct {
+	RuleRefreshInterval  time.Duration // 规则重新加载间隔
+	ChallengeScore       int           // 风险分达到该值时要求二次验证
+	DenyScore            int           // 风险分达到该值时拒绝
+	ChallengeTTL         time.Duration // 二次验证有效期
+	MaxChallengeAttempts int           // 最大验证尝试次数
+}
+
+// DefaultOptions 默认风控配置
+func DefaultOptions() Options {
+	return Options{
+		RuleRefreshInterval:  30 * time.Second,
+		ChallengeScore:       50,
+		DenyScore:            100,
+		ChallengeTTL:         10 * time.Minute,
+		MaxChallengeAttempts: 5,
+	}
+}
+
+// Engine 支付风控引擎
+// 在调用支付渠道前按规则评估支付请求，输出放行、二次验证或拒绝，并持久化决策与命中规则
+type Engine struct {
+	db           *gorm.DB
+	verification *verification.VerificationService
+	options      Options
+
+	mu       sync.RWMutex
+	rules    []model.PaymentRiskRule
+	loadedAt time.Time
+}
+
+// NewEngine 创建支付风控引擎
+func NewEngine(db *gorm.DB, verificationService *verification.VerificationService, options Options) *Engine {
+	return &Engine{
+		db:           db,
+		verification: verificationService,
+		options:      options,
+	}
+}
+
+// SeedDefaultRules 规则表为空时写入默认规则
+func (e *Engine) SeedDefaultRules() error {
+	var count int64
+	if err := e.db.Model(&model.PaymentRiskRule{}).Count(&count).Error; err != nil {
+		return fmt.Errorf("统计风控规则失败: %v", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	rules := DefaultRules()
+	if err := e.db.Create(&rules).Error; err != nil {
+		return fmt.Errorf("写入默认风控规则失败: %v", err)
+	}
+	e.Reload()
+	return nil
+}
+
+// Reload 使规则缓存失效，下次评估时重新加载
+func (e *Engine) Reload() {
+	e.mu.Lock()
+	e.loadedAt = time.Time{}
+	e.mu.Unlock()
+}
+
+// activeRules 获取启用的规则，超过刷新间隔时从数据库重新加载
+func (e *Engine) activeRules() ([]model.PaymentRiskRule, error) {
+	e.mu.RLock()
+	if !e.loadedAt.IsZero() && time.Since(e.loadedAt) < e.options.RuleRefreshInterval {
+		rules := e.rules
+		e.mu.RUnlock()
+		return rules, nil
+	}
+	e.mu.RUnlock()
+
+	var rules []model.PaymentRiskRule
+	if err := e.db.Where("is_enabled = ?", true).Order("priority ASC, id ASC").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("加载风控规则失败: %v", err)
+	}
+
+	e.mu.Lock()
+	e.rules = rules
+	e.loadedAt = time.Now()
+	e.mu.Unlock()
+
+	return rules, nil
+}
+
+// Evaluate 评估支付请求并持久化决策
+// 单条规则执行出错时记录日志并跳过，不影响其他规则
+func (e *Engine) Evaluate(input *Input) (*model.PaymentRiskDecision, error) {
+	if input.Now.IsZero() {
+		input.Now = time.Now()
+	}
+	if input.User == nil {
+		var user model.User
+		if err := e.db.First(&user, input.UserID).Error; err == nil {
+			input.User = &user
+		}
+	}
+
+	rules, err := e.activeRules()
+	if err != nil {
+		return nil, err
+	}
+
+	action := model.RiskActionAllow
+	score := 0
+	hits := make([]model.RiskRuleHit, 0)
+	for i := range rules {
+		rule := &rules[i]
+		evaluator, ok := getEvaluator(rule.RuleType)
+		if !ok {
+			logger.Warn("风控规则类型未注册", zap.String("code", rule.Code), zap.String("rule_type", rule.RuleType))
+			continue
+		}
+		params, err := ParseRuleParams(rule.Params)
+		if err != nil {
+			logger.Warn("风控规则参数无效", zap.String("code", rule.Code), zap.Error(err))
+			continue
+		}
+
+		hit, detail, err := evaluator(e.db, input, params)
+		if err != nil {
+			logger.Warn("风控规则执行失败", zap.String("code", rule.Code), zap.Error(err))
+			continue
+		}
+		if !hit {
+			continue
+		}
+
+		hits = append(hits, model.RiskRuleHit{
+			Code:   rule.Code,
+			Name:   rule.Name,
+			Action: rule.Action,
+			Score:  rule.Score,
+			Detail: detail,
+		})
+		score += rule.Score
+		if rule.Action.Severity() > action.Severity() {
+			action = rule.Action
+		}
+	}
+
+	// 累计风险分达到阈值时升级处置动作
+	if e.options.DenyScore > 0 && score >= e.options.DenyScore {
+		action = model.RiskActionDeny
+	} else if e.options.ChallengeScore > 0 && score >= e.options.ChallengeScore && action == model.RiskActionAllow {
+		action = model.RiskActionChallenge
+	}
+
+	hitRules, _ := json.Marshal(hits)
+	decision := &model.PaymentRiskDecision{
+		UserID:        input.UserID,
+		PaymentMethod: input.PaymentMethod,
+		Amount:        input.Amount,
+		ClientIP:      input.ClientIP,
+		DeviceID:      input.DeviceID,
+		Action:        action,
+		Score:         score,
+		HitRules:      string(hitRules),
+	}
+	if input.Order != nil {
+		decision.OrderID = input.Order.ID
+	}
+	if err := e.db.Create(decision).Error; err != nil {
+		return nil, fmt.Errorf("保存风控决策失败: %v", err)
+	}
+
+	if action != model.RiskActionAllow {
+		logger.Warn("支付风控命中",
+			zap.Uint("decision_id", decision.ID),
+			zap.Uint("user_id", input.UserID),
+			zap.String("action", string(action)),
+			zap.Int("score", score),
+			zap.String("hit_rules", decision.HitRules))
+	}
+
+	return decision, nil
+}
+
+// StartChallenge 发送二次验证码，优先使用已验证的手机号
+func (e *Engine) StartChallenge(decision *model.PaymentRiskDecision, user *model.User) error {
+	if e.verification == nil || user == nil {
+		return model.ErrPaymentRiskDenied
+	}
+
+	channel, target := ChannelEmail, user.Email
+	if user.Phone != "" && user.PhoneVerified {
+		channel, target = ChannelSMS, user.Phone
+	}
+	if target == "" {
+		return model.ErrPaymentRiskDenied
+	}
+
+	var err error
+	if channel == ChannelSMS {
+		_, err = e.verification.SendSMSVerification(target, model.VerificationTypePaymentRisk, user.ID)
+	} else {
+		_, err = e.verification.SendEmailVerification(target, model.VerificationTypePaymentRisk, user.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("发送安全验证码失败: %v", err)
+	}
+
+	err = e.db.Model(decision).Updates(map[string]interface{}{
+		"challenge_status":  model.RiskChallengePending,
+		"challenge_channel": channel,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("更新风控决策失败: %v", err)
+	}
+
+	return &model.PaymentRiskChallengeError{
+		DecisionID: decision.ID,
+		Channel:    channel,
+		Target:     maskTarget(channel, target),
+	}
+}
+
+// VerifyChallenge 校验二次验证码，通过后返回原决策
+func (e *Engine) VerifyChallenge(decisionID, userID, orderID uint, code string) (*model.PaymentRiskDecision, error) {
+	if e.verification == nil {
+		return nil, model.ErrRiskChallengeInvalid
+	}
+
+	var decision model.PaymentRiskDecision
+	if err := e.db.First(&decision, decisionID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, model.ErrRiskChallengeInvalid
+		}
+		return nil, fmt.Errorf("查询风控决策失败: %v", err)
+	}
+	if decision.UserID != userID || decision.OrderID != orderID ||
+		decision.Action != model.RiskActionChallenge ||
+		decision.ChallengeStatus != model.RiskChallengePending ||
+		time.Since(decision.CreatedAt) > e.options.ChallengeTTL {
+		return nil, model.ErrRiskChallengeInvalid
+	}
+
+	var user model.User
+	if err := e.db.First(&user, userID).Error; err != nil {
+		return nil, model.ErrRiskChallengeInvalid
+	}
+
+	var err error
+	if decision.ChallengeChannel == ChannelSMS {
+		_, err = e.verification.VerifyPhoneCode(user.Phone, code, model.VerificationTypePaymentRisk)
+	} else {
+		_, err = e.verification.VerifyEmailCode(user.Email, code, model.VerificationTypePaymentRisk)
+	}
+	if err != nil {
+		attempts := decision.ChallengeAttempts + 1
+		updates := map[string]interface{}{"challenge_attempts": attempts}
+		if attempts >= e.options.MaxChallengeAttempts {
+			updates["challenge_status"] = model.RiskChallengeFailed
+		}
+		if updateErr := e.db.Model(&decision).Updates(updates).Error; updateErr != nil {
+			logger.Error("更新风控验证次数失败", zap.Uint("decision_id", decision.ID), zap.Error(updateErr))
+		}
+		return nil, model.ErrRiskChallengeFailed
+	}
+
+	now := time.Now()
+	result := e.db.Model(&model.PaymentRiskDecision{}).
+		Where("id = ? AND challenge_status = ?", decision.ID, model.RiskChallengePending).
+		Updates(map[string]interface{}{
+			"challenge_status": model.RiskChallengePassed,
+			"verified_at":      now,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("更新风控决策失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, model.ErrRiskChallengeInvalid
+	}
+
+	decision.ChallengeStatus = model.RiskChallengePassed
+	decision.VerifiedAt = &now
+	return &decision, nil
+}
+
+// AttachPayment 关联放行后创建的支付记录
+func (e *Engine) AttachPayment(tx *gorm.DB, decisionID, paymentID uint) error {
+	return tx.Model(&model.PaymentRiskDecision{}).Where("id = ?", decisionID).Update("payment_id", paymentID).Error
+}
+
+// maskTarget 脱敏验证码接收方
+func maskTarget(channel, target string) string {
+	if channel == ChannelSMS {
+		if len(target) < 7 {
+			return target
+		}
+		return target[:3] + "****" + target[len(target)-4:]
+	}
+
+	at := strings.Index(target, "@")
+	if at <= 1 {
+		return target
+	}
+	return target[:1] + "***" + target[at:]
+}
@@ -0,0 +1,189 @@
+package risk
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/verification"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupEngine(t *testing.T) (*Engine, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(
+		&model.User{},
+		&model.Order{},
+		&model.Address{},
+		&model.UserVerificationCode{},
+		&model.PaymentRiskRule{},
+		&model.PaymentRiskBlacklist{},
+		&model.PaymentRiskDecision{},
+	))
+
+	engine := NewEngine(db, verification.NewVerificationService(db), DefaultOptions())
+	require.NoError(t, engine.SeedDefaultRules())
+	return engine, db
+}
+
+func createUser(t *testing.T, db *gorm.DB, username string) *model.User {
+	user := &model.User{Username: username, Email: username + "@example.com", Password: "x"}
+	require.NoError(t, db.Create(user).Error)
+	return user
+}
+
+func newInput(user *model.User, amount string) *Input {
+	return &Input{
+		UserID:        user.ID,
+		User:          user,
+		Order:         &model.Order{ID: 1, UserID: user.ID, Province: "广东省", City: "深圳市", ReceiverPhone: "13800000000"},
+		PaymentMethod: model.PaymentMethodAlipay,
+		Amount:        decimal.RequireFromString(amount),
+		ClientIP:      "10.0.0.1",
+		DeviceID:      "device-1",
+	}
+}
+
+func TestEngine_EvaluateAllow(t *testing.T) {
+	engine, db := setupEngine(t)
+	user := createUser(t, db, "alice")
+
+	decision, err := engine.Evaluate(newInput(user, "99.00"))
+	require.NoError(t, err)
+	assert.Equal(t, model.RiskActionAllow, decision.Action)
+	assert.Equal(t, 0, decision.Score)
+	assert.NotZero(t, decision.ID)
+}
+
+func TestEngine_EvaluateBlacklistDeny(t *testing.T) {
+	engine, db := setupEngine(t)
+	user := createUser(t, db, "bob")
+
+	_, err := engine.AddBlacklist(&model.PaymentRiskBlacklistRequest{ListType: model.RiskBlacklistIP, Value: "10.0.0.1"}, 1)
+	require.NoError(t, err)
+
+	decision, err := engine.Evaluate(newInput(user, "99.00"))
+	require.NoError(t, err)
+	assert.Equal(t, model.RiskActionDeny, decision.Action)
+	assert.Contains(t, decision.HitRules, `"code":"blacklist"`)
+
+	// 过期的黑名单不再生效
+	expired := time.Now().Add(-time.Hour)
+	_, err = engine.AddBlacklist(&model.PaymentRiskBlacklistRequest{ListType: model.RiskBlacklistIP, Value: "10.0.0.1", ExpiresAt: &expired}, 1)
+	require.NoError(t, err)
+
+	decision, err = engine.Evaluate(newInput(user, "99.00"))
+	require.NoError(t, err)
+	assert.Equal(t, model.RiskActionAllow, decision.Action)
+}
+
+func TestEngine_EvaluateVelocity(t *testing.T) {
+	engine, db := setupEngine(t)
+	user := createUser(t, db, "carol")
+
+	for i := 0; i < 5; i++ {
+		decision, err := engine.Evaluate(newInput(user, "10.00"))
+		require.NoError(t, err)
+		assert.Equal(t, model.RiskActionAllow, decision.Action)
+	}
+
+	decision, err := engine.Evaluate(newInput(user, "10.00"))
+	require.NoError(t, err)
+	assert.Equal(t, model.RiskActionChallenge, decision.Action)
+	assert.Contains(t, decision.HitRules, `"code":"user_velocity"`)
+}
+
+func TestEngine_ScoreEscalation(t *testing.T) {
+	engine, db := setupEngine(t)
+	user := createUser(t, db, "dave")
+	changedAt := time.Now().Add(-time.Hour)
+	user.PasswordChangedAt = &changedAt
+	require.NoError(t, db.Create(&model.Address{
+		UserID: user.ID, ReceiverName: "dave", ReceiverPhone: "13800000000",
+		Province: "北京市", City: "北京市", District: "朝阳区", DetailAddress: "xx",
+	}).Error)
+
+	// 地址不符(20) + 近期改密(30)，两条规则均仅计分，累计50分触发二次验证
+	decision, err := engine.Evaluate(newInput(user, "99.00"))
+	require.NoError(t, err)
+	assert.Equal(t, 50, decision.Score)
+	assert.Equal(t, model.RiskActionChallenge, decision.Action)
+
+	// 停用规则后立即生效
+	rules, err := engine.ListRules()
+	require.NoError(t, err)
+	for _, rule := range rules {
+		if rule.Code == "recent_password_change" {
+			require.NoError(t, engine.SetRuleEnabled(rule.ID, false))
+		}
+	}
+
+	decision, err = engine.Evaluate(newInput(user, "99.00"))
+	require.NoError(t, err)
+	assert.Equal(t, 20, decision.Score)
+	assert.Equal(t, model.RiskActionAllow, decision.Action)
+}
+
+func TestEngine_FirstOrderAmount(t *testing.T) {
+	engine, db := setupEngine(t)
+	user := createUser(t, db, "erin")
+
+	decision, err := engine.Evaluate(newInput(user, "8000.00"))
+	require.NoError(t, err)
+	assert.Equal(t, model.RiskActionChallenge, decision.Action)
+	assert.Contains(t, decision.HitRules, `"code":"first_order_large"`)
+}
+
+func TestEngine_Challenge(t *testing.T) {
+	engine, db := setupEngine(t)
+	user := createUser(t, db, "frank")
+
+	decision, err := engine.Evaluate(newInput(user, "8000.00"))
+	require.NoError(t, err)
+
+	err = engine.StartChallenge(decision, user)
+	var challenge *model.PaymentRiskChallengeError
+	require.True(t, errors.As(err, &challenge))
+	assert.Equal(t, decision.ID, challenge.DecisionID)
+	assert.Equal(t, ChannelEmail, challenge.Channel)
+	assert.Equal(t, "f***@example.com", challenge.Target)
+
+	_, err = engine.VerifyChallenge(decision.ID, user.ID, 1, "000000x")
+	assert.ErrorIs(t, err, model.ErrRiskChallengeFailed)
+
+	var code model.UserVerificationCode
+	require.NoError(t, db.Where("email = ? AND type = ?", user.Email, model.VerificationTypePaymentRisk).First(&code).Error)
+
+	// 其他订单不能复用该验证
+	_, err = engine.VerifyChallenge(decision.ID, user.ID, 2, code.Code)
+	assert.ErrorIs(t, err, model.ErrRiskChallengeInvalid)
+
+	passed, err := engine.VerifyChallenge(decision.ID, user.ID, 1, code.Code)
+	require.NoError(t, err)
+	assert.Equal(t, model.RiskChallengePassed, passed.ChallengeStatus)
+
+	_, err = engine.VerifyChallenge(decision.ID, user.ID, 1, code.Code)
+	assert.ErrorIs(t, err, model.ErrRiskChallengeInvalid)
+}
+
+func TestEngine_SaveRuleValidation(t *testing.T) {
+	engine, _ := setupEngine(t)
+
+	_, err := engine.SaveRule(&model.PaymentRiskRuleRequest{Code: "x", Name: "x", RuleType: "unknown", Action: model.RiskActionDeny})
+	assert.ErrorIs(t, err, model.ErrRiskRuleTypeUnsupported)
+
+	_, err = engine.SaveRule(&model.PaymentRiskRuleRequest{Code: "x", Name: "x", RuleType: model.RiskRuleVelocityIP, Params: "{bad", Action: model.RiskActionDeny})
+	assert.Error(t, err)
+
+	rule, err := engine.SaveRule(&model.PaymentRiskRuleRequest{Code: "ip_velocity", Name: "IP频率", RuleType: model.RiskRuleVelocityIP, Params: `{"window_minutes":5,"max_count":3}`, Action: model.RiskActionChallenge, Score: 10})
+	require.NoError(t, err)
+	assert.True(t, rule.IsEnabled)
+	assert.Equal(t, model.RiskActionChallenge, rule.Action)
+}
@@ -0,0 +1,218 @@
+package risk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// RuleParams 规则参数，不同规则类型使用其中的部分字段
+type RuleParams struct {
+	WindowMinutes int             `json:"window_minutes,omitempty"` // 统计窗口(分钟)，频率类规则使用
+	MaxCount      int             `json:"max_count,omitempty"`      // 窗口内允许的最大支付次数，频率类规则使用
+	Amount        decimal.Decimal `json:"amount,omitempty"`         // 金额阈值，首单大额规则使用
+	Hours         int             `json:"hours,omitempty"`          // 时间范围(小时)，近期修改密码规则使用
+}
+
+// ParseRuleParams 解析规则参数
+func ParseRuleParams(raw string) (*RuleParams, error) {
+	params := &RuleParams{}
+	if raw == "" {
+		return params, nil
+	}
+	if err := json.Unmarshal([]byte(raw), params); err != nil {
+		return nil, fmt.Errorf("解析规则参数失败: %v", err)
+	}
+	return params, nil
+}
+
+// Input 风控评估输入
+type Input struct {
+	UserID        uint
+	Order         *model.Order
+	User          *model.User // 用户不存在时为nil
+	PaymentMethod model.PaymentMethod
+	Amount        decimal.Decimal // 结算币种金额
+	ClientIP      string
+	DeviceID      string
+	Now           time.Time
+}
+
+// Evaluator 规则评估函数，返回是否命中及命中说明
+type Evaluator func(db *gorm.DB, input *Input, params *RuleParams) (bool, string, error)
+
+var (
+	evaluatorsMu sync.RWMutex
+	evaluators   = map[string]Evaluator{
+		model.RiskRuleVelocityUser:     evaluateUserVelocity,
+		model.RiskRuleVelocityDevice:   evaluateDeviceVelocity,
+		model.RiskRuleVelocityIP:       evaluateIPVelocity,
+		model.RiskRuleFirstOrderAmount: evaluateFirstOrderAmount,
+		model.RiskRuleAddressMismatch:  evaluateAddressMismatch,
+		model.RiskRulePasswordChanged:  evaluatePasswordChanged,
+		model.RiskRuleBlacklist:        evaluateBlacklist,
+	}
+)
+
+// RegisterEvaluator 注册自定义规则类型，已存在的类型会被覆盖
+func RegisterEvaluator(ruleType string, evaluator Evaluator) {
+	evaluatorsMu.Lock()
+	defer evaluatorsMu.Unlock()
+	evaluators[ruleType] = evaluator
+}
+
+// getEvaluator 获取规则类型对应的评估函数
+func getEvaluator(ruleType string) (Evaluator, bool) {
+	evaluatorsMu.RLock()
+	defer evaluatorsMu.RUnlock()
+	evaluator, ok := evaluators[ruleType]
+	return evaluator, ok
+}
+
+// DefaultRules 默认风控规则，规则表为空时写入
+func DefaultRules() []model.PaymentRiskRule {
+	return []model.PaymentRiskRule{
+		{Code: "blacklist", Name: "黑名单", RuleType: model.RiskRuleBlacklist, Action: model.RiskActionDeny, Score: 100, Priority: 10, IsEnabled: true},
+		{Code: "user_velocity", Name: "用户10分钟内支付过于频繁", RuleType: model.RiskRuleVelocityUser, Params: `{"window_minutes":10,"max_count":5}`, Action: model.RiskActionChallenge, Score: 30, Priority: 20, IsEnabled: true},
+		{Code: "device_velocity", Name: "设备10分钟内支付过于频繁", RuleType: model.RiskRuleVelocityDevice, Params: `{"window_minutes":10,"max_count":8}`, Action: model.RiskActionChallenge, Score: 30, Priority: 30, IsEnabled: true},
+		{Code: "ip_velocity", Name: "IP 10分钟内支付过于频繁", RuleType: model.RiskRuleVelocityIP, Params: `{"window_minutes":10,"max_count":20}`, Action: model.RiskActionDeny, Score: 60, Priority: 40, IsEnabled: true},
+		{Code: "first_order_large", Name: "首单大额支付", RuleType: model.RiskRuleFirstOrderAmount, Params: `{"amount":"5000"}`, Action: model.RiskActionChallenge, Score: 40, Priority: 50, IsEnabled: true},
+		{Code: "address_mismatch", Name: "收货地址不在地址簿中", RuleType: model.RiskRuleAddressMismatch, Action: model.RiskActionAllow, Score: 20, Priority: 60, IsEnabled: true},
+		{Code: "recent_password_change", Name: "24小时内修改过密码", RuleType: model.RiskRulePasswordChanged, Params: `{"hours":24}`, Action: model.RiskActionAllow, Score: 30, Priority: 70, IsEnabled: true},
+	}
+}
+
+// countRecentDecisions 统计窗口内某维度的支付尝试次数
+func countRecentDecisions(db *gorm.DB, column string, value interface{}, input *Input, params *RuleParams) (int64, error) {
+	if params.WindowMinutes <= 0 || params.MaxCount <= 0 {
+		return 0, nil
+	}
+	since := input.Now.Add(-time.Duration(params.WindowMinutes) * time.Minute)
+
+	var count int64
+	err := db.Model(&model.PaymentRiskDecision{}).
+		Where(column+" = ? AND created_at >= ?", value, since).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("统计支付频率失败: %v", err)
+	}
+	return count, nil
+}
+
+// evaluateUserVelocity 用户维度支付频率
+func evaluateUserVelocity(db *gorm.DB, input *Input, params *RuleParams) (bool, string, error) {
+	count, err := countRecentDecisions(db, "user_id", input.UserID, input, params)
+	if err != nil || params.MaxCount <= 0 || count < int64(params.MaxCount) {
+		return false, "", err
+	}
+	return true, fmt.Sprintf("用户%d分钟内已发起%d次支付", params.WindowMinutes, count), nil
+}
+
+// evaluateDeviceVelocity 设备维度支付频率
+func evaluateDeviceVelocity(db *gorm.DB, input *Input, params *RuleParams) (bool, string, error) {
+	if input.DeviceID == "" {
+		return false, "", nil
+	}
+	count, err := countRecentDecisions(db, "device_id", input.DeviceID, input, params)
+	if err != nil || params.MaxCount <= 0 || count < int64(params.MaxCount) {
+		return false, "", err
+	}
+	return true, fmt.Sprintf("设备%d分钟内已发起%d次支付", params.WindowMinutes, count), nil
+}
+
+// evaluateIPVelocity IP维度支付频率
+func evaluateIPVelocity(db *gorm.DB, input *Input, params *RuleParams) (bool, string, error) {
+	if input.ClientIP == "" {
+		return false, "", nil
+	}
+	count, err := countRecentDecisions(db, "client_ip", input.ClientIP, input, params)
+	if err != nil || params.MaxCount <= 0 || count < int64(params.MaxCount) {
+		return false, "", err
+	}
+	return true, fmt.Sprintf("IP %d分钟内已发起%d次支付", params.WindowMinutes, count), nil
+}
+
+// evaluateFirstOrderAmount 首单大额：用户没有已支付订单且金额超过阈值
+func evaluateFirstOrderAmount(db *gorm.DB, input *Input, params *RuleParams) (bool, string, error) {
+	if !params.Amount.IsPositive() || input.Amount.LessThan(params.Amount) {
+		return false, "", nil
+	}
+
+	var paidCount int64
+	err := db.Model(&model.Order{}).
+		Where("user_id = ? AND payment_status = ?", input.UserID, string(model.PaymentStatusPaid)).
+		Count(&paidCount).Error
+	if err != nil {
+		return false, "", fmt.Errorf("查询历史订单失败: %v", err)
+	}
+	if paidCount > 0 {
+		return false, "", nil
+	}
+	return true, fmt.Sprintf("首单金额%s超过阈值%s", input.Amount.StringFixed(2), params.Amount.StringFixed(2)), nil
+}
+
+// evaluateAddressMismatch 收货地址与用户地址簿中的省市均不一致
+func evaluateAddressMismatch(db *gorm.DB, input *Input, params *RuleParams) (bool, string, error) {
+	if input.Order == nil || input.Order.City == "" {
+		return false, "", nil
+	}
+
+	var addresses []model.Address
+	if err := db.Where("user_id = ?", input.UserID).Find(&addresses).Error; err != nil {
+		return false, "", fmt.Errorf("查询用户地址失败: %v", err)
+	}
+	if len(addresses) == 0 {
+		return false, "", nil
+	}
+
+	for _, address := range addresses {
+		if address.Province == input.Order.Province && address.City == input.Order.City {
+			return false, "", nil
+		}
+	}
+	return true, fmt.Sprintf("收货地址%s%s不在用户地址簿中", input.Order.Province, input.Order.City), nil
+}
+
+// evaluatePasswordChanged 近期修改过密码
+func evaluatePasswordChanged(db *gorm.DB, input *Input, params *RuleParams) (bool, string, error) {
+	if input.User == nil || input.User.PasswordChangedAt == nil || params.Hours <= 0 {
+		return false, "", nil
+	}
+	if input.Now.Sub(*input.User.PasswordChangedAt) > time.Duration(params.Hours)*time.Hour {
+		return false, "", nil
+	}
+	return true, fmt.Sprintf("%d小时内修改过密码", params.Hours), nil
+}
+
+// evaluateBlacklist 用户、IP、设备或收货手机号命中黑名单
+func evaluateBlacklist(db *gorm.DB, input *Input, params *RuleParams) (bool, string, error) {
+	conditions := db.Where("list_type = ? AND value = ?", model.RiskBlacklistUser, strconv.FormatUint(uint64(input.UserID), 10))
+	if input.ClientIP != "" {
+		conditions = conditions.Or("list_type = ? AND value = ?", model.RiskBlacklistIP, input.ClientIP)
+	}
+	if input.DeviceID != "" {
+		conditions = conditions.Or("list_type = ? AND value = ?", model.RiskBlacklistDevice, input.DeviceID)
+	}
+	if input.Order != nil && input.Order.ReceiverPhone != "" {
+		conditions = conditions.Or("list_type = ? AND value = ?", model.RiskBlacklistPhone, input.Order.ReceiverPhone)
+	}
+
+	var entry model.PaymentRiskBlacklist
+	err := db.Where(conditions).
+		Where("expires_at IS NULL OR expires_at > ?", input.Now).
+		First(&entry).Error
+	if err == gorm.ErrRecordNotFound {
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("查询黑名单失败: %v", err)
+	}
+	return true, fmt.Sprintf("%s %s 在黑名单中", entry.ListType, entry.Value), nil
+}
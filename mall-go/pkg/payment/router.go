@@ -3,6 +3,7 @@ package payment
 import (
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	"mall-go/internal/model"
@@ -13,7 +14,9 @@ import (
 )
 
 // PaymentRouter 支付路由器 - 智能选择支付方式
+// 配置热加载时会替换配置与渠道客户端，读写均需加锁
 type PaymentRouter struct {
+	mu       sync.RWMutex
 	config   *PaymentConfig
 	alipay   AlipayClientInterface
 	wechat   WechatClientInterface
@@ -42,6 +45,11 @@ type UnionPayClientInterface interface {
 	VerifyCallback(params map[string]string) error
 }
 
+// PaymentCloser 支持关闭交易的渠道客户端
+type PaymentCloser interface {
+	ClosePayment(outTradeNo string) error
+}
+
 // NewPaymentRouter 创建支付路由器
 func NewPaymentRouter(config *PaymentConfig) *PaymentRouter {
 	return &PaymentRouter{
@@ -50,6 +58,82 @@ func NewPaymentRouter(config *PaymentConfig) *PaymentRouter {
 	}
 }
 
+// SetAlipayClient 设置支付宝渠道客户端
+func (pr *PaymentRouter) SetAlipayClient(client AlipayClientInterface) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.alipay = client
+}
+
+// SetWechatClient 设置微信支付渠道客户端
+func (pr *PaymentRouter) SetWechatClient(client WechatClientInterface) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.wechat = client
+}
+
+// SetUnionPayClient 设置银联渠道客户端
+func (pr *PaymentRouter) SetUnionPayClient(client UnionPayClientInterface) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.unionpay = client
+}
+
+// SetConfig 替换路由配置，配置文件热加载后调用
+func (pr *PaymentRouter) SetConfig(config *PaymentConfig) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.config = config
+}
+
+// currentConfig 获取当前路由配置
+func (pr *PaymentRouter) currentConfig() *PaymentConfig {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+	return pr.config
+}
+
+// alipayClient 获取当前支付宝渠道客户端
+func (pr *PaymentRouter) alipayClient() AlipayClientInterface {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+	return pr.alipay
+}
+
+// wechatClient 获取当前微信支付渠道客户端
+func (pr *PaymentRouter) wechatClient() WechatClientInterface {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+	return pr.wechat
+}
+
+// unionpayClient 获取当前银联渠道客户端
+func (pr *PaymentRouter) unionpayClient() UnionPayClientInterface {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+	return pr.unionpay
+}
+
+// HasClient 支付方式是否已接入真实渠道客户端
+// 未接入时查询返回的是模拟结果，不能据此更新支付状态
+func (pr *PaymentRouter) HasClient(method model.PaymentMethod) bool {
+	switch method {
+	case model.PaymentMethodAlipay:
+		return pr.alipayClient() != nil
+	case model.PaymentMethodWechat:
+		return pr.wechatClient() != nil
+	case model.PaymentMethodUnionPay:
+		return pr.unionpayClient() != nil
+	default:
+		return false
+	}
+}
+
+// Metrics 获取支付指标
+func (pr *PaymentRouter) Metrics() *PaymentMetrics {
+	return pr.metrics
+}
+
 // CreatePaymentRequest 统一创建支付请求
 type CreatePaymentRequest struct {
 	OutTradeNo string                 `json:"out_trade_no" binding:"required"`
@@ -121,19 +205,19 @@ func (pr *PaymentRouter) CreatePayment(req *CreatePaymentRequest) (*CreatePaymen
 
 	switch req.Method {
 	case model.PaymentMethodAlipay:
-		if !pr.config.Alipay.Enabled {
+		if !pr.currentConfig().Alipay.Enabled {
 			return nil, fmt.Errorf("支付宝支付未启用")
 		}
 		response, err = pr.createAlipayPayment(req)
 
 	case model.PaymentMethodWechat:
-		if !pr.config.Wechat.Enabled {
+		if !pr.currentConfig().Wechat.Enabled {
 			return nil, fmt.Errorf("微信支付未启用")
 		}
 		response, err = pr.createWechatPayment(req)
 
 	case model.PaymentMethodUnionPay:
-		if !pr.config.UnionPay.Enabled {
+		if !pr.currentConfig().UnionPay.Enabled {
 			return nil, fmt.Errorf("银联支付未启用")
 		}
 		response, err = pr.createUnionPayPayment(req)
@@ -169,7 +253,7 @@ func (pr *PaymentRouter) CreatePayment(req *CreatePaymentRequest) (*CreatePaymen
 func (pr *PaymentRouter) preprocessRequest(req *CreatePaymentRequest) error {
 	// 设置默认币种
 	if req.Currency == "" {
-		req.Currency = pr.config.DefaultCurrency
+		req.Currency = pr.currentConfig().DefaultCurrency
 	}
 
 	// 设置默认过期时间（30分钟）
@@ -182,11 +266,11 @@ func (pr *PaymentRouter) preprocessRequest(req *CreatePaymentRequest) error {
 	if req.NotifyURL == "" {
 		switch req.Method {
 		case model.PaymentMethodAlipay:
-			req.NotifyURL = pr.config.Alipay.NotifyURL
+			req.NotifyURL = pr.currentConfig().Alipay.NotifyURL
 		case model.PaymentMethodWechat:
-			req.NotifyURL = pr.config.Wechat.NotifyURL
+			req.NotifyURL = pr.currentConfig().Wechat.NotifyURL
 		case model.PaymentMethodUnionPay:
-			req.NotifyURL = pr.config.UnionPay.NotifyURL
+			req.NotifyURL = pr.currentConfig().UnionPay.NotifyURL
 		}
 	}
 
@@ -205,7 +289,7 @@ func (pr *PaymentRouter) preprocessRequest(req *CreatePaymentRequest) error {
 
 // validatePaymentLimits 验证支付限额
 func (pr *PaymentRouter) validatePaymentLimits(req *CreatePaymentRequest) error {
-	methodLimit := pr.config.GetMethodLimit(req.Method)
+	methodLimit := pr.currentConfig().GetMethodLimit(req.Method)
 
 	// 单笔限额验证
 	if req.Amount.LessThan(methodLimit.MinAmount) {
@@ -216,16 +300,18 @@ func (pr *PaymentRouter) validatePaymentLimits(req *CreatePaymentRequest) error
 		return fmt.Errorf("支付金额不能大于 %s", methodLimit.MaxAmount.String())
 	}
 
-	// TODO: 实现日限额和月限额验证
-	// 这里需要查询数据库统计用户当日和当月的支付金额
+	// 用户日/月累计限额由 Service 创建支付时通过 limit.Limiter 原子预占校验
 
 	return nil
 }
 
 // createAlipayPayment 创建支付宝支付
 func (pr *PaymentRouter) createAlipayPayment(req *CreatePaymentRequest) (*CreatePaymentResponse, error) {
-	// TODO: 调用具体的支付宝客户端
-	// 这里返回模拟响应
+	if client := pr.alipayClient(); client != nil {
+		return client.CreatePayment(req)
+	}
+
+	// 未接入支付宝客户端时返回模拟响应
 	return &CreatePaymentResponse{
 		OutTradeNo: req.OutTradeNo,
 		Method:     model.PaymentMethodAlipay,
@@ -237,8 +323,11 @@ func (pr *PaymentRouter) createAlipayPayment(req *CreatePaymentRequest) (*Create
 
 // createWechatPayment 创建微信支付
 func (pr *PaymentRouter) createWechatPayment(req *CreatePaymentRequest) (*CreatePaymentResponse, error) {
-	// TODO: 调用具体的微信支付客户端
-	// 这里返回模拟响应
+	if client := pr.wechatClient(); client != nil {
+		return client.CreatePayment(req)
+	}
+
+	// 未接入微信支付客户端时返回模拟响应
 	return &CreatePaymentResponse{
 		OutTradeNo: req.OutTradeNo,
 		Method:     model.PaymentMethodWechat,
@@ -250,8 +339,11 @@ func (pr *PaymentRouter) createWechatPayment(req *CreatePaymentRequest) (*Create
 
 // createUnionPayPayment 创建银联支付
 func (pr *PaymentRouter) createUnionPayPayment(req *CreatePaymentRequest) (*CreatePaymentResponse, error) {
-	// TODO: 调用具体的银联支付客户端
-	// 这里返回模拟响应
+	if client := pr.unionpayClient(); client != nil {
+		return client.CreatePayment(req)
+	}
+
+	// 未接入银联支付客户端时返回模拟响应
 	return &CreatePaymentResponse{
 		OutTradeNo: req.OutTradeNo,
 		Method:     model.PaymentMethodUnionPay,
@@ -274,19 +366,19 @@ func (pr *PaymentRouter) QueryPayment(outTradeNo string, method model.PaymentMet
 
 	switch method {
 	case model.PaymentMethodAlipay:
-		if !pr.config.Alipay.Enabled {
+		if !pr.currentConfig().Alipay.Enabled {
 			return nil, fmt.Errorf("支付宝支付未启用")
 		}
 		response, err = pr.queryAlipayPayment(outTradeNo)
 
 	case model.PaymentMethodWechat:
-		if !pr.config.Wechat.Enabled {
+		if !pr.currentConfig().Wechat.Enabled {
 			return nil, fmt.Errorf("微信支付未启用")
 		}
 		response, err = pr.queryWechatPayment(outTradeNo)
 
 	case model.PaymentMethodUnionPay:
-		if !pr.config.UnionPay.Enabled {
+		if !pr.currentConfig().UnionPay.Enabled {
 			return nil, fmt.Errorf("银联支付未启用")
 		}
 		response, err = pr.queryUnionPayPayment(outTradeNo)
@@ -313,10 +405,40 @@ func (pr *PaymentRouter) QueryPayment(outTradeNo string, method model.PaymentMet
 	return response, nil
 }
 
+// ClosePayment 关闭渠道交易
+// 支付过期后调用，避免用户在过期后仍能完成付款
+func (pr *PaymentRouter) ClosePayment(outTradeNo string, method model.PaymentMethod) error {
+	logger.Info("关闭渠道交易",
+		zap.String("out_trade_no", outTradeNo),
+		zap.String("method", string(method)))
+
+	var client interface{}
+	switch method {
+	case model.PaymentMethodAlipay:
+		client = pr.alipayClient()
+	case model.PaymentMethodWechat:
+		client = pr.wechatClient()
+	case model.PaymentMethodUnionPay:
+		client = pr.unionpayClient()
+	default:
+		return fmt.Errorf("不支持的支付方式: %s", method)
+	}
+
+	closer, ok := client.(PaymentCloser)
+	if !ok {
+		return fmt.Errorf("支付方式 %s 不支持关闭交易", method)
+	}
+
+	return closer.ClosePayment(outTradeNo)
+}
+
 // queryAlipayPayment 查询支付宝支付状态
 func (pr *PaymentRouter) queryAlipayPayment(outTradeNo string) (*QueryPaymentResponse, error) {
-	// TODO: 调用具体的支付宝客户端
-	// 这里返回模拟响应
+	if client := pr.alipayClient(); client != nil {
+		return client.QueryPayment(outTradeNo)
+	}
+
+	// 未接入支付宝客户端时返回模拟响应
 	return &QueryPaymentResponse{
 		OutTradeNo:    outTradeNo,
 		TransactionID: fmt.Sprintf("alipay_%d", time.Now().Unix()),
@@ -329,8 +451,11 @@ func (pr *PaymentRouter) queryAlipayPayment(outTradeNo string) (*QueryPaymentRes
 
 // queryWechatPayment 查询微信支付状态
 func (pr *PaymentRouter) queryWechatPayment(outTradeNo string) (*QueryPaymentResponse, error) {
-	// TODO: 调用具体的微信支付客户端
-	// 这里返回模拟响应
+	if client := pr.wechatClient(); client != nil {
+		return client.QueryPayment(outTradeNo)
+	}
+
+	// 未接入微信支付客户端时返回模拟响应
 	return &QueryPaymentResponse{
 		OutTradeNo:    outTradeNo,
 		TransactionID: fmt.Sprintf("wechat_%d", time.Now().Unix()),
@@ -343,8 +468,11 @@ func (pr *PaymentRouter) queryWechatPayment(outTradeNo string) (*QueryPaymentRes
 
 // queryUnionPayPayment 查询银联支付状态
 func (pr *PaymentRouter) queryUnionPayPayment(outTradeNo string) (*QueryPaymentResponse, error) {
-	// TODO: 调用具体的银联支付客户端
-	// 这里返回模拟响应
+	if client := pr.unionpayClient(); client != nil {
+		return client.QueryPayment(outTradeNo)
+	}
+
+	// 未接入银联支付客户端时返回模拟响应
 	return &QueryPaymentResponse{
 		OutTradeNo:    outTradeNo,
 		TransactionID: fmt.Sprintf("unionpay_%d", time.Now().Unix()),
@@ -366,13 +494,13 @@ func (pr *PaymentRouter) generateMockQRCode(method, outTradeNo string) string {
 func (pr *PaymentRouter) GetSupportedMethods() []model.PaymentMethod {
 	var methods []model.PaymentMethod
 
-	if pr.config.Alipay.Enabled {
+	if pr.currentConfig().Alipay.Enabled {
 		methods = append(methods, model.PaymentMethodAlipay)
 	}
-	if pr.config.Wechat.Enabled {
+	if pr.currentConfig().Wechat.Enabled {
 		methods = append(methods, model.PaymentMethodWechat)
 	}
-	if pr.config.UnionPay.Enabled {
+	if pr.currentConfig().UnionPay.Enabled {
 		methods = append(methods, model.PaymentMethodUnionPay)
 	}
 
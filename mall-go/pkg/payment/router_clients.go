@@ -0,0 +1,154 @@
+package payment
+
+import (
+	"fmt"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/payment/alipay"
+	"mall-go/pkg/payment/wechat"
+)
+
+// alipayRouterClient 将支付宝客户端适配为路由器统一接口
+type alipayRouterClient struct {
+	client *alipay.Client
+}
+
+// NewAlipayRouterClient 创建支付宝路由客户端
+func NewAlipayRouterClient(client *alipay.Client) AlipayClientInterface {
+	return &alipayRouterClient{client: client}
+}
+
+// CreatePayment 创建支付
+func (a *alipayRouterClient) CreatePayment(req *CreatePaymentRequest) (*CreatePaymentResponse, error) {
+	resp, err := a.client.CreatePayment(&alipay.PaymentRequest{
+		OutTradeNo:  req.OutTradeNo,
+		TotalAmount: req.Amount,
+		Subject:     req.Subject,
+		Body:        req.Body,
+		NotifyURL:   req.NotifyURL,
+		ReturnURL:   req.ReturnURL,
+		TimeExpire:  req.ExpireTime,
+		Currency:    req.Currency,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreatePaymentResponse{
+		OutTradeNo: req.OutTradeNo,
+		Method:     model.PaymentMethodAlipay,
+		QRCode:     resp.QRCode,
+		Success:    resp.Success,
+		Message:    resp.Message,
+		ExpiresAt:  req.ExpireTime,
+	}, nil
+}
+
+// QueryPayment 查询支付
+func (a *alipayRouterClient) QueryPayment(outTradeNo string) (*QueryPaymentResponse, error) {
+	resp, err := a.client.QueryPayment(outTradeNo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueryPaymentResponse{
+		OutTradeNo:    outTradeNo,
+		TransactionID: resp.TradeNo,
+		Method:        model.PaymentMethodAlipay,
+		Status:        resp.Status,
+		Amount:        resp.TotalAmount,
+		Success:       resp.Success,
+		Message:       resp.Message,
+	}, nil
+}
+
+// VerifyCallback 验证回调签名
+func (a *alipayRouterClient) VerifyCallback(params map[string]string) error {
+	return a.client.VerifyCallback(params)
+}
+
+// ClosePayment 关闭交易
+func (a *alipayRouterClient) ClosePayment(outTradeNo string) error {
+	return a.client.CloseOrder(outTradeNo)
+}
+
+// wechatRouterClient 将微信支付客户端（v2或APIv3）适配为路由器统一接口
+type wechatRouterClient struct {
+	client wechat.PayClient
+}
+
+// NewWechatRouterClient 创建微信支付路由客户端
+func NewWechatRouterClient(client wechat.PayClient) WechatClientInterface {
+	return &wechatRouterClient{client: client}
+}
+
+// CreatePayment 创建支付
+func (w *wechatRouterClient) CreatePayment(req *CreatePaymentRequest) (*CreatePaymentResponse, error) {
+	resp, err := w.client.CreatePayment(&wechat.PaymentRequest{
+		Body:       req.Subject,
+		Detail:     req.Body,
+		OutTradeNo: req.OutTradeNo,
+		TotalFee:   req.Amount,
+		Currency:   req.Currency,
+		NotifyURL:  req.NotifyURL,
+		TimeExpire: req.ExpireTime,
+		ClientIP:   req.ClientIP,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreatePaymentResponse{
+		OutTradeNo: req.OutTradeNo,
+		Method:     model.PaymentMethodWechat,
+		CodeURL:    resp.CodeURL,
+		PayURL:     resp.H5URL,
+		PrepayID:   resp.PrepayID,
+		Success:    resp.Success,
+		Message:    resp.Message,
+		ExpiresAt:  req.ExpireTime,
+	}, nil
+}
+
+// QueryPayment 查询支付
+func (w *wechatRouterClient) QueryPayment(outTradeNo string) (*QueryPaymentResponse, error) {
+	resp, err := w.client.QueryPayment(outTradeNo)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &QueryPaymentResponse{
+		OutTradeNo:    outTradeNo,
+		TransactionID: resp.TransactionID,
+		Method:        model.PaymentMethodWechat,
+		Status:        resp.Status,
+		Amount:        resp.TotalFee,
+		Success:       resp.Success,
+		Message:       resp.Message,
+	}
+	if resp.TimeEnd != "" {
+		if paidAt, err := time.ParseInLocation("20060102150405", resp.TimeEnd, time.Local); err == nil {
+			result.PaidAt = &paidAt
+		}
+	}
+
+	return result, nil
+}
+
+// VerifyCallback 验证回调签名
+// APIv3通知的签名位于请求头中，需通过 ProcessWechatV3Callback 处理
+func (w *wechatRouterClient) VerifyCallback(params map[string]string) error {
+	verifier, ok := w.client.(interface {
+		VerifyCallback(params map[string]string) error
+	})
+	if !ok {
+		return fmt.Errorf("微信支付APIv3通知不支持参数验签")
+	}
+	return verifier.VerifyCallback(params)
+}
+
+// ClosePayment 关闭交易
+func (w *wechatRouterClient) ClosePayment(outTradeNo string) error {
+	return w.client.CloseOrder(outTradeNo)
+}
@@ -0,0 +1,314 @@
+package secret
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// 主密钥环境变量
+const (
+	EnvMasterKey         = "PAYMENT_MASTER_KEY"          // 当前主密钥(base64)
+	EnvMasterKeyFile     = "PAYMENT_MASTER_KEY_FILE"     // 主密钥文件，每行一个base64密钥，第一行为当前主密钥
+	EnvPreviousMasterKey = "PAYMENT_MASTER_KEY_PREVIOUS" // 轮换前的主密钥(base64)，多个以逗号分隔，仅用于解密
+)
+
+// prefix 密文前缀，格式 enc:v1:<主密钥ID>:<加密后的数据密钥>:<密文>
+const prefix = "enc:v1:"
+
+// KeySize 主密钥与数据密钥长度(AES-256)
+const KeySize = 32
+
+// 密钥错误定义
+var (
+	ErrNoMasterKey      = errors.New("未配置支付密钥主密钥")
+	ErrInvalidMasterKey = errors.New("主密钥必须为32字节的base64编码")
+	ErrUnknownMasterKey = errors.New("密文使用的主密钥不在密钥环中")
+	ErrMalformedSecret  = errors.New("密文格式错误")
+)
+
+// masterKey 主密钥
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Keyring 主密钥环
+// 使用信封加密：每个密文使用随机生成的数据密钥加密，数据密钥再由当前主密钥加密后随密文保存；
+// 密钥环同时保留轮换前的主密钥，旧密文仍可解密，重新保存时使用当前主密钥加密
+type Keyring struct {
+	primary *masterKey
+	keys    map[string]*masterKey
+}
+
+// NewKeyring 创建主密钥环，第一个密钥为当前主密钥，其余仅用于解密
+func NewKeyring(primary []byte, previous ...[]byte) (*Keyring, error) {
+	primaryKey, err := newMasterKey(primary)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring := &Keyring{
+		primary: primaryKey,
+		keys:    map[string]*masterKey{primaryKey.id: primaryKey},
+	}
+	for _, raw := range previous {
+		key, err := newMasterKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := keyring.keys[key.id]; !exists {
+			keyring.keys[key.id] = key
+		}
+	}
+	return keyring, nil
+}
+
+// ParseKeyring 从base64编码的密钥创建主密钥环
+func ParseKeyring(primary string, previous ...string) (*Keyring, error) {
+	primaryKey, err := decodeKey(primary)
+	if err != nil {
+		return nil, err
+	}
+	previousKeys := make([][]byte, 0, len(previous))
+	for _, encoded := range previous {
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, err
+		}
+		previousKeys = append(previousKeys, key)
+	}
+	return NewKeyring(primaryKey, previousKeys...)
+}
+
+// LoadKeyring 从环境变量或密钥文件加载主密钥环，均未配置时返回 ErrNoMasterKey
+func LoadKeyring() (*Keyring, error) {
+	var keys []string
+	if encoded := os.Getenv(EnvMasterKey); encoded != "" {
+		keys = append(keys, encoded)
+	}
+	if path := os.Getenv(EnvMasterKeyFile); path != "" {
+		fileKeys, err := readKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, fileKeys...)
+	}
+	if previous := os.Getenv(EnvPreviousMasterKey); previous != "" {
+		for _, encoded := range strings.Split(previous, ",") {
+			if encoded = strings.TrimSpace(encoded); encoded != "" {
+				keys = append(keys, encoded)
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoMasterKey
+	}
+	return ParseKeyring(keys[0], keys[1:]...)
+}
+
+var (
+	defaultKeyring    *Keyring
+	defaultKeyringErr error
+	defaultOnce       sync.Once
+)
+
+// DefaultKeyring 进程级主密钥环，首次调用时从环境变量加载
+func DefaultKeyring() (*Keyring, error) {
+	defaultOnce.Do(func() {
+		defaultKeyring, defaultKeyringErr = LoadKeyring()
+	})
+	return defaultKeyring, defaultKeyringErr
+}
+
+// GenerateKey 生成新的base64编码主密钥
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", fmt.Errorf("生成主密钥失败: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// PrimaryKeyID 当前主密钥ID
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary.id
+}
+
+// IsEncrypted 值是否为密文
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// KeyIDOf 密文使用的主密钥ID，非密文返回空
+func KeyIDOf(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, prefix), ":", 3)
+	return parts[0]
+}
+
+// Encrypt 使用当前主密钥加密，空值与已加密的值原样返回
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" || IsEncrypted(plaintext) {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", fmt.Errorf("生成数据密钥失败: %v", err)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	wrappedKey, err := seal(k.primary.aead, dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataAEAD, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + k.primary.id + ":" +
+		base64.RawURLEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt 解密密文，非密文视为明文原样返回
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformedSecret
+	}
+	key, ok := k.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownMasterKey, parts[0])
+	}
+
+	wrappedKey, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformedSecret
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformedSecret
+	}
+
+	dataKey, err := open(key.aead, wrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("解密数据密钥失败: %v", err)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataAEAD, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("解密密文失败: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// Reencrypt 使用当前主密钥重新加密，明文直接加密，已使用当前主密钥的密文原样返回
+func (k *Keyring) Reencrypt(value string) (string, error) {
+	if value == "" || KeyIDOf(value) == k.primary.id {
+		return value, nil
+	}
+	plaintext, err := k.Decrypt(value)
+	if err != nil {
+		return "", err
+	}
+	return k.Encrypt(plaintext)
+}
+
+// newMasterKey 创建主密钥，ID取密钥摘要前8位
+func newMasterKey(raw []byte) (*masterKey, error) {
+	if len(raw) != KeySize {
+		return nil, ErrInvalidMasterKey
+	}
+	aead, err := newAEAD(raw)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(raw)
+	return &masterKey{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// newAEAD 创建AES-GCM
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("初始化AES失败: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("初始化GCM失败: %v", err)
+	}
+	return aead, nil
+}
+
+// seal 加密，随机nonce置于密文之前
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("生成nonce失败: %v", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open 解密
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrMalformedSecret
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// decodeKey 解码base64主密钥
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != KeySize {
+		return nil, ErrInvalidMasterKey
+	}
+	return key, nil
+}
+
+// readKeyFile 读取主密钥文件，忽略空行与#开头的注释
+func readKeyFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取主密钥文件失败: %v", err)
+	}
+
+	var keys []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys, nil
+}
@@ -0,0 +1,127 @@
+package secret
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeyring(t *testing.T, previous ...string) (*Keyring, string) {
+	key, err := GenerateKey()
+	require.NoError(t, err)
+	keyring, err := ParseKeyring(key, previous...)
+	require.NoError(t, err)
+	return keyring, key
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	keyring, _ := newTestKeyring(t)
+
+	ciphertext, err := keyring.Encrypt("MIIEvQIBADANBgkqhkiG9w0BAQEFAASC")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(ciphertext))
+	assert.Equal(t, keyring.PrimaryKeyID(), KeyIDOf(ciphertext))
+	assert.NotContains(t, ciphertext, "MIIEvQIBADANBgkqhkiG9w0BAQEFAASC")
+
+	// 每次加密使用不同的数据密钥与nonce
+	again, err := keyring.Encrypt("MIIEvQIBADANBgkqhkiG9w0BAQEFAASC")
+	require.NoError(t, err)
+	assert.NotEqual(t, ciphertext, again)
+
+	plaintext, err := keyring.Decrypt(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "MIIEvQIBADANBgkqhkiG9w0BAQEFAASC", plaintext)
+
+	// 空值与已加密的值原样返回，明文解密原样返回
+	empty, err := keyring.Encrypt("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+	same, err := keyring.Encrypt(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, ciphertext, same)
+	plain, err := keyring.Decrypt("not-encrypted")
+	require.NoError(t, err)
+	assert.Equal(t, "not-encrypted", plain)
+}
+
+func TestDecryptRejectsTamperedOrUnknown(t *testing.T) {
+	keyring, _ := newTestKeyring(t)
+	other, _ := newTestKeyring(t)
+
+	ciphertext, err := keyring.Encrypt("api-key")
+	require.NoError(t, err)
+
+	_, err = other.Decrypt(ciphertext)
+	assert.True(t, errors.Is(err, ErrUnknownMasterKey))
+
+	parts := strings.Split(ciphertext, ":")
+	last := parts[len(parts)-1]
+	parts[len(parts)-1] = strings.Repeat("A", len(last))
+	_, err = keyring.Decrypt(strings.Join(parts, ":"))
+	assert.Error(t, err)
+
+	_, err = keyring.Decrypt("enc:v1:abc")
+	assert.ErrorIs(t, err, ErrMalformedSecret)
+}
+
+func TestRotation(t *testing.T) {
+	oldKeyring, oldKey := newTestKeyring(t)
+	ciphertext, err := oldKeyring.Encrypt("api-key")
+	require.NoError(t, err)
+
+	// 新密钥环保留旧主密钥，可以解密旧密文
+	newKeyring, _ := newTestKeyring(t, oldKey)
+	plaintext, err := newKeyring.Decrypt(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "api-key", plaintext)
+
+	rotated, err := newKeyring.Reencrypt(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, newKeyring.PrimaryKeyID(), KeyIDOf(rotated))
+
+	// 已使用当前主密钥的密文不再重复加密
+	unchanged, err := newKeyring.Reencrypt(rotated)
+	require.NoError(t, err)
+	assert.Equal(t, rotated, unchanged)
+
+	// 明文直接加密
+	encrypted, err := newKeyring.Reencrypt("plain")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+
+	_, err = oldKeyring.Decrypt(rotated)
+	assert.ErrorIs(t, err, ErrUnknownMasterKey)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	primary, err := GenerateKey()
+	require.NoError(t, err)
+	previous, err := GenerateKey()
+	require.NoError(t, err)
+
+	t.Setenv(EnvMasterKey, "")
+	t.Setenv(EnvMasterKeyFile, "")
+	t.Setenv(EnvPreviousMasterKey, "")
+	_, err = LoadKeyring()
+	assert.ErrorIs(t, err, ErrNoMasterKey)
+
+	path := filepath.Join(t.TempDir(), "payment.keys")
+	require.NoError(t, os.WriteFile(path, []byte("# 当前主密钥\n"+primary+"\n\n"+previous+"\n"), 0600))
+	t.Setenv(EnvMasterKeyFile, path)
+
+	keyring, err := LoadKeyring()
+	require.NoError(t, err)
+	expected, err := ParseKeyring(primary)
+	require.NoError(t, err)
+	assert.Equal(t, expected.PrimaryKeyID(), keyring.PrimaryKeyID())
+	assert.Len(t, keyring.keys, 2)
+
+	t.Setenv(EnvMasterKey, "too-short")
+	_, err = LoadKeyring()
+	assert.ErrorIs(t, err, ErrInvalidMasterKey)
+}
@@ -2,14 +2,20 @@ package payment
 
 import (
 	"fmt"
+	"net/http"
+	"sync"
 	"time"
 
 	"mall-go/internal/model"
 	"mall-go/pkg/logger"
 	"mall-go/pkg/payment/alipay"
 	paymentconfig "mall-go/pkg/payment/config"
+	"mall-go/pkg/payment/limit"
+	"mall-go/pkg/payment/qrcode"
+	"mall-go/pkg/payment/risk"
 	"mall-go/pkg/payment/wechat"
 
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -18,8 +24,14 @@ import (
 type Service struct {
 	db            *gorm.DB
 	configManager *ConfigManager
-	alipayClient  *alipay.Client
-	wechatClient  *wechat.Client
+	clientsMu     sync.RWMutex
+	clients       *channelClients  // 渠道客户端，配置文件热加载时整体替换
+	syncManager   *SyncManager     // 订单同步管理器，未设置时支付成功后直接更新订单
+	router        *PaymentRouter   // 统一渠道路由，供主动轮询查询与关单
+	riskEngine    *risk.Engine     // 支付风控引擎，未设置时不做风控评估
+	limiter       *limit.Limiter   // 用户累计限额，未设置时不校验日/月累计限额
+	qrRenderer    *qrcode.Renderer // 扫码支付二维码图片渲染
+	refundHooks   []RefundHook     // 退款单进入终态时在同一事务内执行
 }
 
 // NewService 创建支付服务
@@ -29,7 +41,44 @@ func NewService(db *gorm.DB, config *PaymentConfig) (*Service, error) {
 		configManager: NewConfigManager(db, config),
 	}
 
-	// 初始化支付客户端 - 仅在配置完整时启用
+	clients, err := newChannelClients(config)
+	if err != nil {
+		return nil, err
+	}
+	service.clients = clients
+	service.router = service.newPaymentRouter(config, clients)
+
+	// 配置文件热加载后重建渠道客户端，新配置无法创建客户端时保留原客户端
+	service.configManager.AddWatcher(ConfigWatcherFunc(service.reloadClients))
+
+	// 初始化二维码渲染器，未配置的项使用默认值
+	renderer, err := qrcode.NewRenderer(qrcode.Options{
+		Size:          config.QRCode.Size,
+		MaxSize:       config.QRCode.MaxSize,
+		RecoveryLevel: config.QRCode.RecoveryLevel,
+		LogoPath:      config.QRCode.LogoPath,
+		LogoRatio:     config.QRCode.LogoRatio,
+		CacheSize:     config.QRCode.CacheSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("初始化二维码渲染器失败: %v", err)
+	}
+	service.qrRenderer = renderer
+
+	return service, nil
+}
+
+// channelClients 支付渠道客户端
+type channelClients struct {
+	alipay   *alipay.Client
+	wechat   *wechat.Client   // v2接口客户端
+	wechatV3 *wechat.ClientV3 // APIv3客户端，商户配置api_version为v3时启用
+}
+
+// newChannelClients 按配置创建支付渠道客户端 - 仅在配置完整时启用
+func newChannelClients(config *PaymentConfig) (*channelClients, error) {
+	clients := &channelClients{}
+
 	// 初始化支付宝客户端
 	if config.Alipay.Enabled && config.Alipay.AppID != "" && config.Alipay.PrivateKey != "" {
 		// 将PaymentConfig的AlipayConfig转换为config包的AlipayConfig
@@ -47,30 +96,89 @@ func NewService(db *gorm.DB, config *PaymentConfig) (*Service, error) {
 		if err != nil {
 			return nil, fmt.Errorf("初始化支付宝客户端失败: %v", err)
 		}
-		service.alipayClient = client
+		clients.alipay = client
 		logger.Info("支付宝客户端初始化成功")
 	} else {
 		logger.Info("支付宝客户端配置不完整，跳过初始化")
 	}
 
-	// 初始化微信支付客户端
-	if config.Wechat.Enabled && config.Wechat.AppID != "" && config.Wechat.MchID != "" && config.Wechat.APIKey != "" {
+	// 初始化微信支付客户端，按商户配置选择v2或APIv3接口
+	wechatKeyReady := config.Wechat.APIKey != ""
+	if config.Wechat.IsV3() {
+		wechatKeyReady = config.Wechat.APIv3Key != ""
+	}
+	if config.Wechat.Enabled && config.Wechat.AppID != "" && config.Wechat.MchID != "" && wechatKeyReady {
 		// 将PaymentConfig的WechatConfig转换为config包的WechatConfig
 		wechatConfig := &paymentconfig.WechatConfig{
 			AppID:      config.Wechat.AppID,
 			MchID:      config.Wechat.MchID,
 			APIKey:     config.Wechat.APIKey,
+			CertPath:   config.Wechat.CertPath,
+			KeyPath:    config.Wechat.KeyPath,
 			SignType:   config.Wechat.SignType,
 			GatewayURL: config.Wechat.GatewayURL,
 			Timeout:    config.Wechat.Timeout,
+
+			APIVersion:      config.Wechat.APIVersion,
+			APIv3Key:        config.Wechat.APIv3Key,
+			SerialNo:        config.Wechat.SerialNo,
+			PrivateKey:      config.Wechat.PrivateKey,
+			CertRefreshTime: config.Wechat.CertRefreshTime,
+		}
+		if config.Wechat.IsV3() {
+			client, err := wechat.NewClientV3(wechatConfig)
+			if err != nil {
+				return nil, fmt.Errorf("初始化微信支付APIv3客户端失败: %v", err)
+			}
+			clients.wechatV3 = client
+			logger.Info("微信支付APIv3客户端初始化成功")
+		} else {
+			clients.wechat = wechat.NewClient(wechatConfig)
+			logger.Info("微信支付客户端初始化成功")
 		}
-		service.wechatClient = wechat.NewClient(wechatConfig)
-		logger.Info("微信支付客户端初始化成功")
 	} else {
 		logger.Info("微信支付客户端配置不完整，跳过初始化")
 	}
 
-	return service, nil
+	return clients, nil
+}
+
+// reloadClients 按新配置重建渠道客户端并更新支付路由
+func (s *Service) reloadClients(config *PaymentConfig) {
+	clients, err := newChannelClients(config)
+	if err != nil {
+		logger.Error("按新配置重建支付客户端失败，继续使用原客户端", zap.Error(err))
+		return
+	}
+
+	s.clientsMu.Lock()
+	s.clients = clients
+	s.clientsMu.Unlock()
+
+	s.router.SetConfig(config)
+	s.attachRouterClients(s.router, clients)
+	logger.Info("支付客户端已按新配置重建")
+}
+
+// newPaymentRouter 创建接入真实渠道客户端的支付路由器
+func (s *Service) newPaymentRouter(config *PaymentConfig, clients *channelClients) *PaymentRouter {
+	router := NewPaymentRouter(config)
+	s.attachRouterClients(router, clients)
+	return router
+}
+
+// attachRouterClients 将渠道客户端接入支付路由器，未配置的渠道置空
+func (s *Service) attachRouterClients(router *PaymentRouter, clients *channelClients) {
+	var alipayClient AlipayClientInterface
+	if clients.alipay != nil {
+		alipayClient = NewAlipayRouterClient(clients.alipay)
+	}
+	var wechatClient WechatClientInterface
+	if client := clients.payClient(); client != nil {
+		wechatClient = NewWechatRouterClient(client)
+	}
+	router.SetAlipayClient(alipayClient)
+	router.SetWechatClient(wechatClient)
 }
 
 // CreatePayment 创建支付
@@ -90,11 +198,6 @@ func (s *Service) CreatePayment(req *model.PaymentCreateRequest) (*model.Payment
 		return nil, fmt.Errorf("支付方式 %s 未启用", req.PaymentMethod)
 	}
 
-	// 验证金额限制
-	if err := s.configManager.ValidateAmount(req.PaymentMethod, req.Amount); err != nil {
-		return nil, err
-	}
-
 	// 查询订单信息
 	var order model.Order
 	if err := s.db.First(&order, req.OrderID).Error; err != nil {
@@ -109,11 +212,50 @@ func (s *Service) CreatePayment(req *model.PaymentCreateRequest) (*model.Payment
 		return nil, model.ErrPaymentAlreadyPaid
 	}
 
-	// 检查金额是否匹配
-	if !req.Amount.Equal(order.TotalAmount) {
+	// 检查金额是否匹配，按扣除促销、会员优惠和礼品卡抵扣后的应付金额支付，
+	// 外币订单按下单时锁定汇率的展示币种应付金额支付
+	currencyCode := model.BaseCurrency
+	payableAmount := order.PayableAmount
+	expectedAmount := order.PayableAmount
+	exchangeRate := decimal.NewFromInt(1)
+	if order.IsForeignCurrency() {
+		currencyCode = order.Currency
+		expectedAmount = order.PresentmentPayableAmount
+		exchangeRate = order.ExchangeRate
+	}
+	if !req.Amount.Equal(expectedAmount) {
 		return nil, model.ErrInvalidAmount
 	}
 
+	// 验证金额限制（按结算币种金额）
+	if err := s.configManager.ValidateAmount(req.PaymentMethod, payableAmount); err != nil {
+		return nil, err
+	}
+
+	// 调用支付渠道前进行风控评估
+	riskDecision, err := s.checkRisk(req, &order, payableAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	// 生成支付单号
+	paymentNo := s.generatePaymentNo()
+
+	// 预占用户日/月累计额度，支付单创建失败时释放
+	if s.limiter != nil {
+		if err := s.limiter.Reserve(order.UserID, req.PaymentMethod, payableAmount, paymentNo); err != nil {
+			return nil, err
+		}
+	}
+	committed := false
+	defer func() {
+		if !committed && s.limiter != nil {
+			if err := s.limiter.Release(order.UserID, paymentNo); err != nil {
+				logger.Error("释放累计支付限额失败", zap.String("payment_no", paymentNo), zap.Error(err))
+			}
+		}
+	}()
+
 	// 开启事务
 	tx := s.db.Begin()
 	defer func() {
@@ -122,25 +264,24 @@ func (s *Service) CreatePayment(req *model.PaymentCreateRequest) (*model.Payment
 		}
 	}()
 
-	// 生成支付单号
-	paymentNo := s.generatePaymentNo()
-
 	// 创建支付记录
 	payment := &model.Payment{
-		PaymentNo:     paymentNo,
-		OrderID:       req.OrderID,
-		UserID:        order.UserID,
-		PaymentType:   model.PaymentTypeOrder,
-		PaymentMethod: req.PaymentMethod,
-		PaymentStatus: model.PaymentStatusPending,
-		Amount:        req.Amount,
-		ActualAmount:  req.Amount,
-		Currency:      "CNY",
-		Subject:       req.Subject,
-		Description:   req.Description,
-		NotifyURL:     req.NotifyURL,
-		ReturnURL:     req.ReturnURL,
-		ExpiredAt:     s.calculateExpiredTime(req.ExpiredMinutes),
+		PaymentNo:        paymentNo,
+		OrderID:          req.OrderID,
+		UserID:           order.UserID,
+		PaymentType:      model.PaymentTypeOrder,
+		PaymentMethod:    req.PaymentMethod,
+		PaymentStatus:    model.PaymentStatusPending,
+		Amount:           req.Amount,
+		ActualAmount:     req.Amount,
+		Currency:         currencyCode,
+		SettlementAmount: payableAmount,
+		ExchangeRate:     exchangeRate,
+		Subject:          req.Subject,
+		Description:      req.Description,
+		NotifyURL:        req.NotifyURL,
+		ReturnURL:        req.ReturnURL,
+		ExpiredAt:        s.calculateExpiredTime(req.ExpiredMinutes),
 	}
 
 	if err := tx.Create(payment).Error; err != nil {
@@ -148,15 +289,23 @@ func (s *Service) CreatePayment(req *model.PaymentCreateRequest) (*model.Payment
 		return nil, fmt.Errorf("创建支付记录失败: %v", err)
 	}
 
+	if riskDecision != nil {
+		if err := s.riskEngine.AttachPayment(tx, riskDecision.ID, payment.ID); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("关联风控决策失败: %v", err)
+		}
+	}
+
 	// 调用第三方支付
-	paymentData, err := s.callThirdPartyPayment(payment)
+	paymentData, err := s.callThirdPartyPayment(payment, req)
 	if err != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("调用第三方支付失败: %v", err)
 	}
 
-	// 更新支付记录
+	// 更新支付记录，扫码支付保存二维码内容供重新获取二维码图片
 	payment.PaymentStatus = model.PaymentStatusPaying
+	payment.CodeURL = qrContentOf(paymentData)
 	if err := tx.Save(payment).Error; err != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("更新支付记录失败: %v", err)
@@ -166,28 +315,92 @@ func (s *Service) CreatePayment(req *model.PaymentCreateRequest) (*model.Payment
 	if err := tx.Commit().Error; err != nil {
 		return nil, fmt.Errorf("提交事务失败: %v", err)
 	}
+	committed = true
 
 	// 记录支付日志
 	s.logPaymentAction(payment.ID, "CREATE", "SUCCESS", "支付订单创建成功", "", "")
 
-	return &model.PaymentCreateResponse{
+	resp := &model.PaymentCreateResponse{
 		PaymentID:     payment.ID,
 		PaymentNo:     payment.PaymentNo,
 		PaymentMethod: payment.PaymentMethod,
 		Amount:        payment.Amount,
+		QRCode:        payment.CodeURL,
 		PaymentData:   paymentData,
 		ExpiredAt:     *payment.ExpiredAt,
 		CreatedAt:     payment.CreatedAt,
-	}, nil
+	}
+
+	// 按需返回二维码图片，渲染失败不影响支付创建，前端可通过二维码接口重新获取
+	if req.QRCodeFormat != "" && payment.CodeURL != "" {
+		if image, err := s.renderPaymentQRCode(payment, qrcode.Format(req.QRCodeFormat), req.QRCodeSize); err != nil {
+			logger.Warn("渲染支付二维码失败", zap.String("payment_no", payment.PaymentNo), zap.Error(err))
+		} else {
+			resp.QRCodeImage = image.DataURI()
+		}
+	}
+
+	return resp, nil
+}
+
+// qrContentOf 从渠道返回数据中取扫码支付的二维码内容
+func qrContentOf(paymentData interface{}) string {
+	data, ok := paymentData.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	for _, key := range []string{"code_url", "qr_code"} {
+		if content, ok := data[key].(string); ok && content != "" {
+			return content
+		}
+	}
+	return ""
+}
+
+// PaymentQRCode 获取用户待支付订单的二维码图片
+// 图片按支付单缓存至支付过期，已支付或已过期的支付不再返回二维码
+func (s *Service) PaymentQRCode(paymentID, userID uint, format qrcode.Format, size int) (*qrcode.Image, error) {
+	var payment model.Payment
+	if err := s.db.Where("id = ? AND user_id = ?", paymentID, userID).First(&payment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, model.ErrPaymentNotFound
+		}
+		return nil, fmt.Errorf("查询支付记录失败: %v", err)
+	}
+
+	switch payment.PaymentStatus {
+	case model.PaymentStatusPending, model.PaymentStatusPaying:
+	case model.PaymentStatusSuccess, model.PaymentStatusPaid:
+		return nil, model.ErrPaymentAlreadyPaid
+	default:
+		return nil, model.ErrPaymentExpired
+	}
+	if payment.ExpiredAt != nil && !payment.ExpiredAt.After(time.Now()) {
+		return nil, model.ErrPaymentExpired
+	}
+	if payment.CodeURL == "" {
+		return nil, model.ErrPaymentQRCodeUnavailable
+	}
+
+	return s.renderPaymentQRCode(&payment, format, size)
+}
+
+// renderPaymentQRCode 渲染支付二维码，以支付单号为缓存键
+func (s *Service) renderPaymentQRCode(payment *model.Payment, format qrcode.Format, size int) (*qrcode.Image, error) {
+	expiresAt := time.Now()
+	if payment.ExpiredAt != nil {
+		expiresAt = *payment.ExpiredAt
+	}
+	return s.qrRenderer.RenderCached(payment.PaymentNo, payment.CodeURL, format, size, expiresAt)
 }
 
 // callThirdPartyPayment 调用第三方支付
-func (s *Service) callThirdPartyPayment(payment *model.Payment) (interface{}, error) {
+func (s *Service) callThirdPartyPayment(payment *model.Payment, req *model.PaymentCreateRequest) (interface{}, error) {
 	switch payment.PaymentMethod {
 	case model.PaymentMethodAlipay:
 		return s.createAlipayPayment(payment)
 	case model.PaymentMethodWechat:
-		return s.createWechatPayment(payment)
+		return s.createWechatPayment(payment, req)
 	default:
 		return nil, fmt.Errorf("不支持的支付方式: %s", payment.PaymentMethod)
 	}
@@ -195,7 +408,8 @@ func (s *Service) callThirdPartyPayment(payment *model.Payment) (interface{}, er
 
 // createAlipayPayment 创建支付宝支付
 func (s *Service) createAlipayPayment(payment *model.Payment) (interface{}, error) {
-	if s.alipayClient == nil {
+	alipayClient := s.AlipayClient()
+	if alipayClient == nil {
 		return nil, fmt.Errorf("支付宝客户端未初始化")
 	}
 
@@ -206,9 +420,10 @@ func (s *Service) createAlipayPayment(payment *model.Payment) (interface{}, erro
 		Body:        payment.Description,
 		NotifyURL:   payment.NotifyURL,
 		TimeExpire:  payment.ExpiredAt,
+		Currency:    payment.Currency,
 	}
 
-	resp, err := s.alipayClient.CreatePayment(req)
+	resp, err := alipayClient.CreatePayment(req)
 	if err != nil {
 		return nil, err
 	}
@@ -220,8 +435,9 @@ func (s *Service) createAlipayPayment(payment *model.Payment) (interface{}, erro
 }
 
 // createWechatPayment 创建微信支付
-func (s *Service) createWechatPayment(payment *model.Payment) (interface{}, error) {
-	if s.wechatClient == nil {
+func (s *Service) createWechatPayment(payment *model.Payment, createReq *model.PaymentCreateRequest) (interface{}, error) {
+	client := s.wechatPayClient()
+	if client == nil {
 		return nil, fmt.Errorf("微信支付客户端未初始化")
 	}
 
@@ -230,19 +446,31 @@ func (s *Service) createWechatPayment(payment *model.Payment) (interface{}, erro
 		Detail:     payment.Description,
 		OutTradeNo: payment.PaymentNo,
 		TotalFee:   payment.Amount,
+		Currency:   payment.Currency,
 		NotifyURL:  payment.NotifyURL,
 		TimeExpire: payment.ExpiredAt,
+		TradeType:  createReq.TradeType,
+		OpenID:     createReq.OpenID,
+		ClientIP:   createReq.ClientIP,
 	}
 
-	resp, err := s.wechatClient.CreatePayment(req)
+	resp, err := client.CreatePayment(req)
 	if err != nil {
 		return nil, err
 	}
 
-	return map[string]interface{}{
-		"code_url":  resp.CodeURL,
-		"prepay_id": resp.PrepayID,
-	}, nil
+	data := map[string]interface{}{
+		"code_url":   resp.CodeURL,
+		"prepay_id":  resp.PrepayID,
+		"trade_type": resp.TradeType,
+	}
+	if resp.H5URL != "" {
+		data["h5_url"] = resp.H5URL
+	}
+	if len(resp.PayParams) > 0 {
+		data["pay_params"] = resp.PayParams
+	}
+	return data, nil
 }
 
 // QueryPayment 查询支付状态
@@ -308,11 +536,12 @@ func (s *Service) syncPaymentStatus(payment *model.Payment) error {
 
 // syncAlipayStatus 同步支付宝状态
 func (s *Service) syncAlipayStatus(payment *model.Payment) error {
-	if s.alipayClient == nil {
+	alipayClient := s.AlipayClient()
+	if alipayClient == nil {
 		return fmt.Errorf("支付宝客户端未初始化")
 	}
 
-	resp, err := s.alipayClient.QueryPayment(payment.PaymentNo)
+	resp, err := alipayClient.QueryPayment(payment.PaymentNo)
 	if err != nil {
 		return err
 	}
@@ -327,15 +556,8 @@ func (s *Service) syncAlipayStatus(payment *model.Payment) error {
 			payment.PaidAt = &now
 		}
 
-		if err := s.db.Save(payment).Error; err != nil {
-			return fmt.Errorf("更新支付状态失败: %v", err)
-		}
-
-		// 处理支付成功
-		if resp.Status == model.PaymentStatusSuccess {
-			if err := s.handlePaymentSuccess(payment); err != nil {
-				logger.Error("处理支付成功失败", zap.Error(err))
-			}
+		if err := s.savePaymentStatus(payment); err != nil {
+			return err
 		}
 	}
 
@@ -344,11 +566,12 @@ func (s *Service) syncAlipayStatus(payment *model.Payment) error {
 
 // syncWechatStatus 同步微信支付状态
 func (s *Service) syncWechatStatus(payment *model.Payment) error {
-	if s.wechatClient == nil {
+	client := s.wechatPayClient()
+	if client == nil {
 		return fmt.Errorf("微信支付客户端未初始化")
 	}
 
-	resp, err := s.wechatClient.QueryPayment(payment.PaymentNo)
+	resp, err := client.QueryPayment(payment.PaymentNo)
 	if err != nil {
 		return err
 	}
@@ -363,21 +586,78 @@ func (s *Service) syncWechatStatus(payment *model.Payment) error {
 			payment.PaidAt = &paidAt
 		}
 
-		if err := s.db.Save(payment).Error; err != nil {
-			return fmt.Errorf("更新支付状态失败: %v", err)
+		if err := s.savePaymentStatus(payment); err != nil {
+			return err
 		}
+	}
 
-		// 处理支付成功
-		if resp.Status == model.PaymentStatusSuccess {
-			if err := s.handlePaymentSuccess(payment); err != nil {
-				logger.Error("处理支付成功失败", zap.Error(err))
-			}
-		}
+	return nil
+}
+
+// savePaymentStatus 保存支付状态变更
+// 支付成功统一走 applyPaymentSuccess，回调、主动查询和轮询重复到达时只生效一次
+func (s *Service) savePaymentStatus(payment *model.Payment) error {
+	if payment.PaymentStatus == model.PaymentStatusSuccess {
+		_, err := s.applyPaymentSuccess(payment)
+		return err
 	}
 
+	if err := s.db.Save(payment).Error; err != nil {
+		return fmt.Errorf("更新支付状态失败: %v", err)
+	}
+	s.ReleasePaymentLimit(payment)
 	return nil
 }
 
+// applyPaymentSuccess 将待支付记录标记为支付成功，返回本次是否发生状态变更
+// 仅当记录仍处于待支付/支付中时更新；设置了同步管理器时，支付成功事件与支付记录在同一事务中落库，
+// 由同步工作协程更新订单，避免支付成功后进程重启导致订单一直处于未支付状态
+func (s *Service) applyPaymentSuccess(payment *model.Payment) (bool, error) {
+	applied := false
+	var eventID string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Payment{}).
+			Where("id = ? AND payment_status IN ?", payment.ID,
+				[]model.PaymentStatus{model.PaymentStatusPending, model.PaymentStatusPaying}).
+			Updates(map[string]interface{}{
+				"payment_status": model.PaymentStatusSuccess,
+				"third_party_id": payment.ThirdPartyID,
+				"paid_at":        payment.PaidAt,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("更新支付状态失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			// 已由其他回调或轮询处理
+			return nil
+		}
+		applied = true
+
+		if s.syncManager == nil {
+			return nil
+		}
+
+		var err error
+		eventID, err = s.syncManager.PublishEventTx(tx, SyncEventPaymentSuccess, payment.ID, payment.OrderID, payment.UserID,
+			map[string]interface{}{
+				"payment_no":     payment.PaymentNo,
+				"payment_method": payment.PaymentMethod,
+			})
+		return err
+	})
+	if err != nil || !applied {
+		return false, err
+	}
+
+	if s.syncManager == nil {
+		return true, s.handlePaymentSuccess(payment)
+	}
+
+	s.syncManager.Wake(eventID)
+	s.logPaymentAction(payment.ID, "SUCCESS", "SUCCESS", "支付成功", "", "")
+	return true, nil
+}
+
 // handlePaymentSuccess 处理支付成功
 func (s *Service) handlePaymentSuccess(payment *model.Payment) error {
 	logger.Info("处理支付成功",
@@ -396,20 +676,20 @@ func (s *Service) handlePaymentSuccess(payment *model.Payment) error {
 	if err := tx.Model(&model.Order{}).Where("id = ?", payment.OrderID).Updates(map[string]interface{}{
 		"status":         model.OrderStatusPaid,
 		"payment_status": model.PaymentStatusPaid,
-		"payment_method": payment.PaymentMethod,
+		"payment_type":   payment.PaymentMethod,
 	}).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("更新订单状态失败: %v", err)
 	}
 
-	// 记录支付日志
-	s.logPaymentAction(payment.ID, "SUCCESS", "SUCCESS", "支付成功", "", "")
-
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		return fmt.Errorf("提交事务失败: %v", err)
 	}
 
+	// 记录支付日志
+	s.logPaymentAction(payment.ID, "SUCCESS", "SUCCESS", "支付成功", "", "")
+
 	return nil
 }
 
@@ -445,7 +725,8 @@ func (s *Service) ProcessCallback(method model.PaymentMethod, data []byte) error
 
 // processAlipayCallback 处理支付宝回调
 func (s *Service) processAlipayCallback(data []byte) error {
-	if s.alipayClient == nil {
+	alipayClient := s.AlipayClient()
+	if alipayClient == nil {
 		return fmt.Errorf("支付宝客户端未初始化")
 	}
 
@@ -455,7 +736,7 @@ func (s *Service) processAlipayCallback(data []byte) error {
 	// 简化处理，实际应该解析POST参数
 
 	// 验证签名
-	if err := s.alipayClient.VerifyCallback(params); err != nil {
+	if err := alipayClient.VerifyCallback(params); err != nil {
 		return fmt.Errorf("支付宝回调签名验证失败: %v", err)
 	}
 
@@ -474,12 +755,7 @@ func (s *Service) processAlipayCallback(data []byte) error {
 		now := time.Now()
 		payment.PaidAt = &now
 
-		if err := s.db.Save(&payment).Error; err != nil {
-			return fmt.Errorf("更新支付状态失败: %v", err)
-		}
-
-		// 处理支付成功
-		return s.handlePaymentSuccess(&payment)
+		return s.savePaymentStatus(&payment)
 	}
 
 	return nil
@@ -487,12 +763,13 @@ func (s *Service) processAlipayCallback(data []byte) error {
 
 // processWechatCallback 处理微信支付回调
 func (s *Service) processWechatCallback(data []byte) error {
-	if s.wechatClient == nil {
+	wechatClient := s.wechatV2Client()
+	if wechatClient == nil {
 		return fmt.Errorf("微信支付客户端未初始化")
 	}
 
 	// 解析回调数据
-	callback, err := s.wechatClient.ParseCallback(data)
+	callback, err := wechatClient.ParseCallback(data)
 	if err != nil {
 		return fmt.Errorf("解析微信回调数据失败: %v", err)
 	}
@@ -512,10 +789,49 @@ func (s *Service) processWechatCallback(data []byte) error {
 		"time_end":       callback.TimeEnd,
 	}
 
-	if err := s.wechatClient.VerifyCallback(params); err != nil {
+	if err := wechatClient.VerifyCallback(params); err != nil {
 		return fmt.Errorf("微信回调签名验证失败: %v", err)
 	}
 
+	return s.applyWechatCallback(callback)
+}
+
+// ProcessWechatV3Callback 处理微信支付APIv3支付结果通知
+// APIv3通知为JSON报文，签名信息位于请求头中
+func (s *Service) ProcessWechatV3Callback(header http.Header, body []byte) error {
+	return s.processWechatV3Callback(header, body, false)
+}
+
+// ReplayWechatV3Callback 人工重放保存的微信支付APIv3支付结果通知，不校验签名时间戳是否过期
+func (s *Service) ReplayWechatV3Callback(header http.Header, body []byte) error {
+	return s.processWechatV3Callback(header, body, true)
+}
+
+// processWechatV3Callback 验签解密并处理微信支付APIv3支付结果通知
+func (s *Service) processWechatV3Callback(header http.Header, body []byte, replay bool) error {
+	wechatV3 := s.wechatV3Client()
+	if wechatV3 == nil {
+		return fmt.Errorf("微信支付APIv3客户端未初始化")
+	}
+
+	parse := wechatV3.ParseNotify
+	if replay {
+		parse = wechatV3.ParseReplayedNotify
+	}
+	callback, err := parse(header, body)
+	if err != nil {
+		return model.NewPaymentCallbackValidationError(err)
+	}
+
+	if err := callback.Validate(); err != nil {
+		return model.NewPaymentCallbackValidationError(fmt.Errorf("微信回调数据验证失败: %v", err))
+	}
+
+	return s.applyWechatCallback(callback)
+}
+
+// applyWechatCallback 根据已验签的微信回调数据更新支付状态
+func (s *Service) applyWechatCallback(callback *wechat.CallbackData) error {
 	// 查询支付记录
 	var payment model.Payment
 	if err := s.db.Where("payment_no = ?", callback.OutTradeNo).First(&payment).Error; err != nil {
@@ -531,151 +847,196 @@ func (s *Service) processWechatCallback(data []byte) error {
 			payment.PaidAt = paidAt
 		}
 
-		if err := s.db.Save(&payment).Error; err != nil {
-			return fmt.Errorf("更新支付状态失败: %v", err)
-		}
-
-		// 处理支付成功
-		return s.handlePaymentSuccess(&payment)
+		return s.savePaymentStatus(&payment)
 	}
 
 	return nil
 }
 
-// RefundPayment 退款
-func (s *Service) RefundPayment(req *model.PaymentRefundRequest) (*model.PaymentRefundResponse, error) {
-	logger.Info("处理退款请求",
-		zap.Uint("payment_id", req.PaymentID),
-		zap.String("refund_amount", req.RefundAmount.String()))
+// logPaymentAction 记录支付日志
+func (s *Service) logPaymentAction(paymentID uint, action, status, message, requestData, responseData string) {
+	log := &model.PaymentLog{
+		PaymentID:    paymentID,
+		Action:       action,
+		Status:       status,
+		Message:      message,
+		RequestData:  requestData,
+		ResponseData: responseData,
+	}
 
-	// 验证请求
-	if err := req.Validate(); err != nil {
-		return nil, err
+	if err := s.db.Create(log).Error; err != nil {
+		logger.Error("记录支付日志失败", zap.Error(err))
 	}
+}
 
-	// 查询支付记录
-	var payment model.Payment
-	if err := s.db.First(&payment, req.PaymentID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, model.ErrPaymentNotFound
+// AlipayClient 获取支付宝客户端，未配置时返回nil
+func (s *Service) AlipayClient() *alipay.Client {
+	return s.channels().alipay
+}
+
+// WechatClient 获取微信支付客户端，未配置时返回nil
+func (s *Service) WechatClient() wechat.PayClient {
+	return s.wechatPayClient()
+}
+
+// SetSyncManager 设置订单同步管理器
+func (s *Service) SetSyncManager(syncManager *SyncManager) {
+	s.syncManager = syncManager
+}
+
+// OnRefundSettled 注册退款单终态回调，如完成售后单；回调返回错误时退款单保持处理中，
+// 等待下次退款通知或退款查询重试
+func (s *Service) OnRefundSettled(hook RefundHook) {
+	s.refundHooks = append(s.refundHooks, hook)
+}
+
+// SetRiskEngine 设置支付风控引擎
+func (s *Service) SetRiskEngine(engine *risk.Engine) {
+	s.riskEngine = engine
+}
+
+// RiskEngine 获取支付风控引擎，未设置时返回nil
+func (s *Service) RiskEngine() *risk.Engine {
+	return s.riskEngine
+}
+
+// SetLimiter 设置用户累计限额校验器
+func (s *Service) SetLimiter(limiter *limit.Limiter) {
+	s.limiter = limiter
+}
+
+// Limiter 获取用户累计限额校验器，未设置时返回nil
+func (s *Service) Limiter() *limit.Limiter {
+	return s.limiter
+}
+
+// LimitDefaults 由当前支付配置生成默认累计限额，供 limit.Limiter 每次校验时读取
+func (s *Service) LimitDefaults() limit.Defaults {
+	limits := s.configManager.GetConfig().Limits
+	defaults := limit.Defaults{
+		Total: map[model.PaymentLimitPeriod]limit.Limit{
+			model.PaymentLimitDaily:   {MaxAmount: limits.DailyMaxAmount, MaxCount: limits.DailyMaxCount},
+			model.PaymentLimitMonthly: {MaxAmount: limits.MonthlyMaxAmount, MaxCount: limits.MonthlyMaxCount},
+		},
+		Methods: make(map[model.PaymentMethod]map[model.PaymentLimitPeriod]limit.Limit, len(limits.MethodLimits)),
+	}
+	for method, methodLimit := range limits.MethodLimits {
+		defaults.Methods[method] = map[model.PaymentLimitPeriod]limit.Limit{
+			model.PaymentLimitDaily:   {MaxAmount: methodLimit.DailyMaxAmount, MaxCount: methodLimit.DailyMaxCount},
+			model.PaymentLimitMonthly: {MaxAmount: methodLimit.MonthlyMaxAmount, MaxCount: methodLimit.MonthlyMaxCount},
 		}
-		return nil, fmt.Errorf("查询支付记录失败: %v", err)
 	}
+	return defaults
+}
 
-	// 检查支付状态
-	if payment.PaymentStatus != model.PaymentStatusSuccess {
-		return nil, fmt.Errorf("只有支付成功的订单才能退款")
+// ReleasePaymentLimit 支付失败、取消或过期后释放预占的累计额度，重复调用只释放一次
+func (s *Service) ReleasePaymentLimit(payment *model.Payment) {
+	if s.limiter == nil {
+		return
 	}
-
-	// 检查退款金额
-	if req.RefundAmount.GreaterThan(payment.Amount) {
-		return nil, fmt.Errorf("退款金额不能大于支付金额")
+	switch payment.PaymentStatus {
+	case model.PaymentStatusFailed, model.PaymentStatusCancelled, model.PaymentStatusExpired:
+	default:
+		return
 	}
+	if err := s.limiter.Release(payment.UserID, payment.PaymentNo); err != nil {
+		logger.Error("释放累计支付限额失败", zap.String("payment_no", payment.PaymentNo), zap.Error(err))
+	}
+}
 
-	// 开启事务
-	tx := s.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// 生成退款单号
-	refundNo := s.generateRefundNo()
+// checkRisk 风控评估，amount 为本次实际支付的结算币种金额
+// 携带二次验证码时校验之前的挑战，否则按规则评估：拒绝时返回 ErrPaymentRiskDenied，
+// 需要验证时发送验证码并返回 *model.PaymentRiskChallengeError
+func (s *Service) checkRisk(req *model.PaymentCreateRequest, order *model.Order, amount decimal.Decimal) (*model.PaymentRiskDecision, error) {
+	if s.riskEngine == nil {
+		return nil, nil
+	}
 
-	// 创建退款记录
-	refund := &model.PaymentRefund{
-		RefundNo:     refundNo,
-		PaymentID:    payment.ID,
-		UserID:       payment.UserID,
-		RefundAmount: req.RefundAmount,
-		RefundReason: req.RefundReason,
-		RefundStatus: model.PaymentStatusPending,
+	if req.RiskDecisionID > 0 {
+		return s.riskEngine.VerifyChallenge(req.RiskDecisionID, order.UserID, order.ID, req.VerificationCode)
 	}
 
-	if err := tx.Create(refund).Error; err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("创建退款记录失败: %v", err)
+	var user model.User
+	if err := s.db.First(&user, order.UserID).Error; err != nil {
+		return nil, fmt.Errorf("查询用户失败: %v", err)
 	}
 
-	// 调用第三方退款
-	err := s.callThirdPartyRefund(&payment, refund)
+	decision, err := s.riskEngine.Evaluate(&risk.Input{
+		UserID:        order.UserID,
+		Order:         order,
+		User:          &user,
+		PaymentMethod: req.PaymentMethod,
+		Amount:        amount,
+		ClientIP:      req.ClientIP,
+		DeviceID:      req.DeviceID,
+	})
 	if err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("调用第三方退款失败: %v", err)
+		return nil, err
 	}
 
-	// 更新退款状态
-	refund.RefundStatus = model.PaymentStatusSuccess
-	now := time.Now()
-	refund.RefundedAt = &now
-
-	if err := tx.Save(refund).Error; err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("更新退款状态失败: %v", err)
+	switch decision.Action {
+	case model.RiskActionDeny:
+		return nil, model.ErrPaymentRiskDenied
+	case model.RiskActionChallenge:
+		return nil, s.riskEngine.StartChallenge(decision, &user)
 	}
+	return decision, nil
+}
 
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
-		return nil, fmt.Errorf("提交事务失败: %v", err)
-	}
+// SyncManager 获取订单同步管理器，未设置时返回nil
+func (s *Service) SyncManager() *SyncManager {
+	return s.syncManager
+}
 
-	return &model.PaymentRefundResponse{
-		RefundID:     refund.ID,
-		RefundNo:     refund.RefundNo,
-		PaymentID:    refund.PaymentID,
-		RefundAmount: refund.RefundAmount,
-		RefundStatus: refund.RefundStatus,
-		RefundReason: refund.RefundReason,
-		CreatedAt:    refund.CreatedAt,
-	}, nil
+// Router 获取支付路由器
+func (s *Service) Router() *PaymentRouter {
+	return s.router
 }
 
-// callThirdPartyRefund 调用第三方退款
-func (s *Service) callThirdPartyRefund(payment *model.Payment, refund *model.PaymentRefund) error {
-	switch payment.PaymentMethod {
-	case model.PaymentMethodAlipay:
-		return s.refundAlipayPayment(payment, refund)
-	case model.PaymentMethodWechat:
-		return s.refundWechatPayment(payment, refund)
-	default:
-		return fmt.Errorf("不支持的支付方式: %s", payment.PaymentMethod)
-	}
+// IsWechatV3 微信支付是否使用APIv3接口
+func (s *Service) IsWechatV3() bool {
+	return s.wechatV3Client() != nil
 }
 
-// refundAlipayPayment 支付宝退款
-func (s *Service) refundAlipayPayment(payment *model.Payment, refund *model.PaymentRefund) error {
-	// 这里应该调用支付宝退款API
-	// 简化处理，实际需要实现完整的退款逻辑
-	logger.Info("执行支付宝退款", zap.String("payment_no", payment.PaymentNo))
-	return nil
+// wechatPayClient 获取当前商户配置的微信支付客户端
+func (s *Service) wechatPayClient() wechat.PayClient {
+	return s.channels().payClient()
 }
 
-// refundWechatPayment 微信支付退款
-func (s *Service) refundWechatPayment(payment *model.Payment, refund *model.PaymentRefund) error {
-	// 这里应该调用微信支付退款API
-	// 简化处理，实际需要实现完整的退款逻辑
-	logger.Info("执行微信支付退款", zap.String("payment_no", payment.PaymentNo))
-	return nil
+// wechatV2Client 获取微信支付v2接口客户端，未配置时返回nil
+func (s *Service) wechatV2Client() *wechat.Client {
+	return s.channels().wechat
 }
 
-// generateRefundNo 生成退款单号
-func (s *Service) generateRefundNo() string {
-	return fmt.Sprintf("REF%d", time.Now().UnixNano())
+// wechatV3Client 获取微信支付APIv3客户端，未配置时返回nil
+func (s *Service) wechatV3Client() *wechat.ClientV3 {
+	return s.channels().wechatV3
 }
 
-// logPaymentAction 记录支付日志
-func (s *Service) logPaymentAction(paymentID uint, action, status, message, requestData, responseData string) {
-	log := &model.PaymentLog{
-		PaymentID:    paymentID,
-		Action:       action,
-		Status:       status,
-		Message:      message,
-		RequestData:  requestData,
-		ResponseData: responseData,
-	}
+// channels 获取当前渠道客户端
+func (s *Service) channels() *channelClients {
+	s.clientsMu.RLock()
+	defer s.clientsMu.RUnlock()
+	return s.clients
+}
 
-	if err := s.db.Create(log).Error; err != nil {
-		logger.Error("记录支付日志失败", zap.Error(err))
+// payClient 当前商户配置的微信支付客户端
+func (c *channelClients) payClient() wechat.PayClient {
+	if c.wechatV3 != nil {
+		return c.wechatV3
+	}
+	if c.wechat != nil {
+		return c.wechat
 	}
+	return nil
+}
+
+// ConfigManager 获取配置管理器
+func (s *Service) ConfigManager() *ConfigManager {
+	return s.configManager
+}
+
+// AddConfigWatcher 添加支付配置热加载监听器，在支付客户端重建之后通知
+func (s *Service) AddConfigWatcher(watcher ConfigWatcher) {
+	s.configManager.AddWatcher(watcher)
 }
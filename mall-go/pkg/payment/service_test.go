@@ -2,6 +2,8 @@ package payment
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -10,6 +12,7 @@ import (
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -97,8 +100,9 @@ func createTestData(db *gorm.DB) (*model.User, *model.Order) {
 		OrderNo:       "ORDER123456",
 		UserID:        user.ID,
 		TotalAmount:   decimal.NewFromFloat(100.00),
+		PayableAmount: decimal.NewFromFloat(100.00),
 		Status:        model.OrderStatusPending,
-		PaymentStatus: model.PaymentStatusPending,
+		PaymentStatus: string(model.PaymentStatusPending),
 	}
 	db.Create(order)
 
@@ -107,7 +111,7 @@ func createTestData(db *gorm.DB) (*model.User, *model.Order) {
 
 func TestService_CreatePayment(t *testing.T) {
 	db := setupTestDB()
-	user, order := createTestData(db)
+	_, order := createTestData(db)
 
 	// 创建配置
 	config := DefaultPaymentConfig()
@@ -193,6 +197,113 @@ func TestService_CreatePayment(t *testing.T) {
 	}
 }
 
+// newWechatTestService 创建启用微信支付v2的支付服务，统一下单请求由本地模拟网关应答
+func newWechatTestService(t *testing.T, db *gorm.DB) *Service {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, `<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code>`+
+			`<prepay_id>wx_prepay</prepay_id><trade_type>NATIVE</trade_type><code_url>weixin://wxpay/test</code_url></xml>`)
+	}))
+	t.Cleanup(gateway.Close)
+
+	require.NoError(t, db.Create(&model.PaymentConfig{
+		PaymentMethod: model.PaymentMethodWechat,
+		IsEnabled:     true,
+		DisplayName:   "微信支付",
+		MinAmount:     decimal.NewFromFloat(0.01),
+		MaxAmount:     decimal.NewFromInt(50000),
+	}).Error)
+
+	config := DefaultPaymentConfig()
+	config.Wechat.Enabled = true
+	config.Wechat.AppID = "wx_test"
+	config.Wechat.MchID = "1900000001"
+	config.Wechat.APIKey = "test_api_key"
+	config.Wechat.GatewayURL = gateway.URL
+
+	service, err := NewService(db, config)
+	require.NoError(t, err)
+	return service
+}
+
+func TestService_CreatePayment_GiftCardPartial(t *testing.T) {
+	db := setupTestDB()
+	user, _ := createTestData(db)
+	service := newWechatTestService(t, db)
+
+	// 订单总额100，礼品卡抵扣30，剩余70由渠道支付
+	order := &model.Order{
+		OrderNo:        "ORDER_GIFTCARD",
+		UserID:         user.ID,
+		TotalAmount:    decimal.NewFromInt(100),
+		GiftCardAmount: decimal.NewFromInt(30),
+		PayableAmount:  decimal.NewFromInt(70),
+		Status:         model.OrderStatusPending,
+		PaymentStatus:  string(model.PaymentStatusPending),
+	}
+	require.NoError(t, db.Create(order).Error)
+
+	request := &model.PaymentCreateRequest{
+		OrderID:        order.ID,
+		PaymentMethod:  model.PaymentMethodWechat,
+		Amount:         decimal.NewFromInt(100),
+		Subject:        "礼品卡部分抵扣订单",
+		ExpiredMinutes: 30,
+	}
+
+	// 按订单总额支付会重复收取礼品卡已抵扣的金额
+	_, err := service.CreatePayment(request)
+	assert.ErrorIs(t, err, model.ErrInvalidAmount)
+
+	request.Amount = decimal.NewFromInt(70)
+	resp, err := service.CreatePayment(request)
+	require.NoError(t, err)
+	assert.True(t, resp.Amount.Equal(decimal.NewFromInt(70)))
+
+	var payment model.Payment
+	require.NoError(t, db.First(&payment, resp.PaymentID).Error)
+	assert.True(t, payment.Amount.Equal(decimal.NewFromInt(70)))
+	assert.True(t, payment.SettlementAmount.Equal(decimal.NewFromInt(70)), "结算金额为实际支付金额，不含礼品卡抵扣")
+	assert.Equal(t, model.BaseCurrency, payment.Currency)
+}
+
+func TestService_CreatePayment_ForeignCurrencyPayable(t *testing.T) {
+	db := setupTestDB()
+	user, _ := createTestData(db)
+	service := newWechatTestService(t, db)
+
+	// 外币订单按展示币种应付金额支付，结算金额为人民币应付金额
+	order := &model.Order{
+		OrderNo:                  "ORDER_USD",
+		UserID:                   user.ID,
+		TotalAmount:              decimal.NewFromInt(100),
+		GiftCardAmount:           decimal.NewFromInt(30),
+		PayableAmount:            decimal.NewFromInt(70),
+		Currency:                 "USD",
+		ExchangeRate:             decimal.RequireFromString("0.14"),
+		PresentmentTotalAmount:   decimal.RequireFromString("14.00"),
+		PresentmentPayableAmount: decimal.RequireFromString("9.80"),
+		Status:                   model.OrderStatusPending,
+		PaymentStatus:            string(model.PaymentStatusPending),
+	}
+	require.NoError(t, db.Create(order).Error)
+
+	resp, err := service.CreatePayment(&model.PaymentCreateRequest{
+		OrderID:        order.ID,
+		PaymentMethod:  model.PaymentMethodWechat,
+		Amount:         decimal.RequireFromString("9.80"),
+		Subject:        "外币订单",
+		ExpiredMinutes: 30,
+	})
+	require.NoError(t, err)
+
+	var payment model.Payment
+	require.NoError(t, db.First(&payment, resp.PaymentID).Error)
+	assert.Equal(t, "USD", payment.Currency)
+	assert.True(t, payment.Amount.Equal(decimal.RequireFromString("9.80")))
+	assert.True(t, payment.SettlementAmount.Equal(decimal.NewFromInt(70)))
+}
+
 func TestService_QueryPayment(t *testing.T) {
 	db := setupTestDB()
 	user, order := createTestData(db)
@@ -287,7 +398,10 @@ func TestService_RefundPayment(t *testing.T) {
 	}
 	db.Create(payment)
 
-	config := DefaultPaymentConfig()
+	// 退款提交到模拟支付宝网关，网关同步返回资金已变化
+	config := newAlipayTestConfig(t, func(method string, bizContent map[string]interface{}) string {
+		return `{"alipay_trade_refund_response":{"code":"10000","msg":"Success","out_trade_no":"PAY123456","refund_fee":"50.00","fund_change":"Y"}}`
+	})
 	service, err := NewService(db, config)
 	assert.NoError(t, err)
 
@@ -351,7 +465,7 @@ func TestService_RefundPayment(t *testing.T) {
 				assert.NoError(t, err)
 				assert.NotNil(t, resp)
 				assert.Equal(t, tt.request.PaymentID, resp.PaymentID)
-				assert.Equal(t, tt.request.RefundAmount, resp.RefundAmount)
+				assert.True(t, tt.request.RefundAmount.Equal(resp.RefundAmount))
 				assert.Equal(t, tt.request.RefundReason, resp.RefundReason)
 			}
 		})
@@ -443,8 +557,9 @@ func BenchmarkService_CreatePayment(b *testing.B) {
 			OrderNo:       fmt.Sprintf("BENCH%d", i),
 			UserID:        user.ID,
 			TotalAmount:   decimal.NewFromFloat(100.00),
+			PayableAmount: decimal.NewFromFloat(100.00),
 			Status:        model.OrderStatusPending,
-			PaymentStatus: model.PaymentStatusPending,
+			PaymentStatus: string(model.PaymentStatusPending),
 		}
 		db.Create(newOrder)
 
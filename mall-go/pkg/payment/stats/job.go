@@ -0,0 +1,75 @@
+package stats
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mall-go/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// RollupJob 支付统计汇总任务
+// 启动时立即汇总一次，之后按间隔重算最近几天，跨日完成的支付与延迟到达的回调会在下一轮计入
+type RollupJob struct {
+	service *Service
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewRollupJob 创建并启动支付统计汇总任务
+func NewRollupJob(service *Service) *RollupJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &RollupJob{
+		service: service,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	job.wg.Add(1)
+	go job.run()
+
+	logger.Info("支付统计汇总任务启动",
+		zap.Duration("interval", service.options.Interval),
+		zap.Int("lookback_days", service.options.LookbackDays))
+
+	return job
+}
+
+// run 汇总主循环
+func (j *RollupJob) run() {
+	defer j.wg.Done()
+
+	j.rollup()
+
+	ticker := time.NewTicker(j.service.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+			j.rollup()
+		}
+	}
+}
+
+// rollup 执行一次汇总
+func (j *RollupJob) rollup() {
+	start := time.Now()
+	if err := j.service.RollupRecent(); err != nil {
+		logger.Error("支付统计汇总失败", zap.Error(err))
+		return
+	}
+	logger.Debug("支付统计汇总完成", zap.Duration("duration", time.Since(start)))
+}
+
+// Stop 停止汇总任务
+func (j *RollupJob) Stop() {
+	logger.Info("停止支付统计汇总任务")
+	j.cancel()
+	j.wg.Wait()
+}
@@ -0,0 +1,390 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// dateLayout 汇总日期格式
+const dateLayout = "2006-01-02"
+
+// Options 支付统计配置
+type Options struct {
+	Location        *time.Location // 按该时区划分自然日，为空时使用本地时区
+	Interval        time.Duration  // 汇总任务执行间隔
+	LookbackDays    int            // 汇总任务每次重算的天数（含当天），覆盖跨日支付与延迟回调
+	MaxBackfillDays int            // 单次补算的最大天数
+	DefaultDays     int            // 统计查询未指定日期时的默认天数
+}
+
+// DefaultOptions 默认支付统计配置：每10分钟重算当天与前一天，单次最多补算一年
+func DefaultOptions() Options {
+	return Options{
+		Location:        time.Local,
+		Interval:        10 * time.Minute,
+		LookbackDays:    2,
+		MaxBackfillDays: 366,
+		DefaultDays:     30,
+	}
+}
+
+// Service 支付统计服务
+// 按日、按支付方式汇总支付与退款写入汇总表，统计查询只读取汇总表
+type Service struct {
+	db      *gorm.DB
+	options Options
+	now     func() time.Time
+}
+
+// NewService 创建支付统计服务
+func NewService(db *gorm.DB, options Options) *Service {
+	defaults := DefaultOptions()
+	if options.Location == nil {
+		options.Location = defaults.Location
+	}
+	if options.Interval <= 0 {
+		options.Interval = defaults.Interval
+	}
+	if options.LookbackDays <= 0 {
+		options.LookbackDays = defaults.LookbackDays
+	}
+	if options.MaxBackfillDays <= 0 {
+		options.MaxBackfillDays = defaults.MaxBackfillDays
+	}
+	if options.DefaultDays <= 0 {
+		options.DefaultDays = defaults.DefaultDays
+	}
+
+	return &Service{
+		db:      db,
+		options: options,
+		now:     time.Now,
+	}
+}
+
+// paymentRow 汇总所需的支付字段
+type paymentRow struct {
+	PaymentMethod    model.PaymentMethod
+	PaymentStatus    model.PaymentStatus
+	Amount           decimal.Decimal
+	SettlementAmount decimal.Decimal
+	CreatedAt        time.Time
+	PaidAt           *time.Time
+}
+
+// refundRow 汇总所需的退款字段
+type refundRow struct {
+	PaymentMethod    model.PaymentMethod
+	RefundAmount     decimal.Decimal
+	Amount           decimal.Decimal
+	SettlementAmount decimal.Decimal
+}
+
+// Rollup 重算指定自然日的汇总，返回写入的汇总行数
+// 支付按创建时间归属日期，退款按退款完成时间归属日期；重算会整体替换该日的汇总行，可重复执行
+func (s *Service) Rollup(day time.Time) (int, error) {
+	start := s.startOfDay(day)
+	end := start.AddDate(0, 0, 1)
+	date := start.Format(dateLayout)
+
+	rollups := make(map[model.PaymentMethod]*model.PaymentDailyRollup)
+	rollupFor := func(method model.PaymentMethod) *model.PaymentDailyRollup {
+		rollup, ok := rollups[method]
+		if !ok {
+			rollup = &model.PaymentDailyRollup{Date: date, PaymentMethod: method}
+			rollups[method] = rollup
+		}
+		return rollup
+	}
+
+	rows, err := s.db.Model(&model.Payment{}).
+		Select("payment_method, payment_status, amount, COALESCE(settlement_amount, 0) AS settlement_amount, created_at, paid_at").
+		Where("created_at >= ? AND created_at < ?", start, end).
+		Rows()
+	if err != nil {
+		return 0, fmt.Errorf("查询支付记录失败: %v", err)
+	}
+	for rows.Next() {
+		var row paymentRow
+		if err := s.db.ScanRows(rows, &row); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("读取支付记录失败: %v", err)
+		}
+
+		amount := baseAmount(row.Amount, row.SettlementAmount)
+		rollup := rollupFor(row.PaymentMethod)
+		rollup.TotalCount++
+		rollup.TotalAmount = rollup.TotalAmount.Add(amount)
+		switch row.PaymentStatus {
+		case model.PaymentStatusSuccess, model.PaymentStatusPaid, model.PaymentStatusRefunded:
+			rollup.SuccessCount++
+			rollup.SuccessAmount = rollup.SuccessAmount.Add(amount)
+			if row.PaidAt != nil && !row.PaidAt.Before(row.CreatedAt) {
+				rollup.PaySeconds += int64(row.PaidAt.Sub(row.CreatedAt).Seconds())
+				rollup.PayTimed++
+			}
+		case model.PaymentStatusFailed:
+			rollup.FailedCount++
+		}
+	}
+	rows.Close()
+
+	var refunds []refundRow
+	err = s.db.Model(&model.PaymentRefund{}).
+		Select("payments.payment_method, payment_refunds.refund_amount, payments.amount, COALESCE(payments.settlement_amount, 0) AS settlement_amount").
+		Joins("JOIN payments ON payments.id = payment_refunds.payment_id").
+		Where("payment_refunds.refund_status = ?", model.PaymentStatusSuccess).
+		Where("COALESCE(payment_refunds.refunded_at, payment_refunds.updated_at) >= ? AND COALESCE(payment_refunds.refunded_at, payment_refunds.updated_at) < ?", start, end).
+		Scan(&refunds).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询退款记录失败: %v", err)
+	}
+	for _, refund := range refunds {
+		amount := refund.RefundAmount
+		if refund.SettlementAmount.IsPositive() && refund.Amount.IsPositive() {
+			// 外币支付的退款按下单锁定的汇率折算为结算币种
+			amount = amount.Mul(refund.SettlementAmount).Div(refund.Amount)
+		}
+		rollup := rollupFor(refund.PaymentMethod)
+		rollup.RefundCount++
+		rollup.RefundAmount = rollup.RefundAmount.Add(amount)
+	}
+
+	now := s.now()
+	records := make([]*model.PaymentDailyRollup, 0, len(rollups))
+	for _, rollup := range rollups {
+		rollup.TotalAmount = rollup.TotalAmount.Round(2)
+		rollup.SuccessAmount = rollup.SuccessAmount.Round(2)
+		rollup.RefundAmount = rollup.RefundAmount.Round(2)
+		rollup.RolledUpAt = now
+		records = append(records, rollup)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].PaymentMethod < records[j].PaymentMethod })
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("date = ?", date).Delete(&model.PaymentDailyRollup{}).Error; err != nil {
+			return fmt.Errorf("清除支付统计汇总失败: %v", err)
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err := tx.Create(&records).Error; err != nil {
+			return fmt.Errorf("写入支付统计汇总失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
+
+// Backfill 补算日期范围内（含首尾）每一天的汇总
+func (s *Service) Backfill(startDate, endDate string) (*model.PaymentStatisticsBackfillResponse, error) {
+	start, end, err := s.parseRange(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	if days := int(end.Sub(start).Round(24*time.Hour).Hours()/24) + 1; days > s.options.MaxBackfillDays {
+		return nil, model.ErrStatisticsRangeTooLarge
+	}
+
+	result := &model.PaymentStatisticsBackfillResponse{}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		rows, err := s.Rollup(day)
+		if err != nil {
+			return nil, fmt.Errorf("汇总 %s 支付统计失败: %v", day.Format(dateLayout), err)
+		}
+		result.Days++
+		result.Rows += rows
+	}
+	return result, nil
+}
+
+// RollupRecent 重算最近几天（含当天）的汇总，供汇总任务定期执行
+func (s *Service) RollupRecent() error {
+	today := s.startOfDay(s.now())
+	for i := s.options.LookbackDays - 1; i >= 0; i-- {
+		if _, err := s.Rollup(today.AddDate(0, 0, -i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Statistics 按日期范围查询支付统计
+// 数据来自汇总表，当天的数据最多延迟一个汇总间隔；未指定日期时查询最近 DefaultDays 天
+func (s *Service) Statistics(req *model.PaymentStatisticsRequest) (*model.PaymentStatisticsResponse, error) {
+	if req.UserID > 0 || req.PaymentStatus != "" {
+		return nil, model.ErrStatisticsFilterUnsupported
+	}
+
+	today := s.startOfDay(s.now())
+	startDate, endDate := req.StartDate, req.EndDate
+	if endDate == "" {
+		endDate = today.Format(dateLayout)
+	}
+	if startDate == "" {
+		end, err := s.parseDate(endDate)
+		if err != nil {
+			return nil, err
+		}
+		startDate = end.AddDate(0, 0, 1-s.options.DefaultDays).Format(dateLayout)
+	}
+	if _, _, err := s.parseRange(startDate, endDate); err != nil {
+		return nil, err
+	}
+
+	db := s.db.Where("date >= ? AND date <= ?", startDate, endDate)
+	if req.PaymentMethod != "" {
+		db = db.Where("payment_method = ?", req.PaymentMethod)
+	}
+	var rollups []model.PaymentDailyRollup
+	if err := db.Order("date ASC, payment_method ASC").Find(&rollups).Error; err != nil {
+		return nil, fmt.Errorf("查询支付统计汇总失败: %v", err)
+	}
+
+	keyLength := len(dateLayout)
+	switch req.GroupBy {
+	case "month":
+		keyLength = len("2006-01")
+	case "year":
+		keyLength = len("2006")
+	}
+
+	var total aggregate
+	methods := make(map[model.PaymentMethod]*aggregate)
+	var buckets []string
+	periods := make(map[string]*aggregate)
+	for i := range rollups {
+		rollup := &rollups[i]
+		total.add(rollup)
+
+		if methods[rollup.PaymentMethod] == nil {
+			methods[rollup.PaymentMethod] = &aggregate{}
+		}
+		methods[rollup.PaymentMethod].add(rollup)
+
+		key := rollup.Date[:keyLength]
+		if periods[key] == nil {
+			periods[key] = &aggregate{}
+			buckets = append(buckets, key)
+		}
+		periods[key].add(rollup)
+	}
+
+	resp := &model.PaymentStatisticsResponse{
+		TotalAmount:   total.TotalAmount,
+		TotalCount:    total.TotalCount,
+		SuccessAmount: total.SuccessAmount,
+		SuccessCount:  total.SuccessCount,
+		FailedCount:   total.FailedCount,
+		RefundAmount:  total.RefundAmount,
+		RefundCount:   total.RefundCount,
+		MethodStats:   make(map[model.PaymentMethod]model.PaymentMethodStat, len(methods)),
+		DailyStats:    make([]model.PaymentDailyStat, 0, len(buckets)),
+	}
+	for method, stat := range methods {
+		resp.MethodStats[method] = model.PaymentMethodStat{
+			Method:        method,
+			Amount:        stat.TotalAmount,
+			Count:         stat.TotalCount,
+			SuccessRate:   stat.successRate(),
+			SuccessAmount: stat.SuccessAmount,
+			RefundAmount:  stat.RefundAmount,
+			AvgPaySeconds: stat.avgPaySeconds(),
+		}
+	}
+	for _, key := range buckets {
+		stat := periods[key]
+		resp.DailyStats = append(resp.DailyStats, model.PaymentDailyStat{
+			Date:          key,
+			Amount:        stat.TotalAmount,
+			Count:         stat.TotalCount,
+			SuccessRate:   stat.successRate(),
+			SuccessAmount: stat.SuccessAmount,
+			RefundAmount:  stat.RefundAmount,
+			AvgPaySeconds: stat.avgPaySeconds(),
+		})
+	}
+	return resp, nil
+}
+
+// aggregate 汇总行累加结果
+type aggregate struct {
+	model.PaymentDailyRollup
+}
+
+// add 累加一条汇总行
+func (a *aggregate) add(rollup *model.PaymentDailyRollup) {
+	a.TotalCount += rollup.TotalCount
+	a.TotalAmount = a.TotalAmount.Add(rollup.TotalAmount)
+	a.SuccessCount += rollup.SuccessCount
+	a.SuccessAmount = a.SuccessAmount.Add(rollup.SuccessAmount)
+	a.FailedCount += rollup.FailedCount
+	a.RefundCount += rollup.RefundCount
+	a.RefundAmount = a.RefundAmount.Add(rollup.RefundAmount)
+	a.PaySeconds += rollup.PaySeconds
+	a.PayTimed += rollup.PayTimed
+}
+
+// successRate 支付成功率
+func (a *aggregate) successRate() float64 {
+	if a.TotalCount == 0 {
+		return 0
+	}
+	return float64(a.SuccessCount) / float64(a.TotalCount)
+}
+
+// avgPaySeconds 平均支付耗时(秒)
+func (a *aggregate) avgPaySeconds() float64 {
+	if a.PayTimed == 0 {
+		return 0
+	}
+	return float64(a.PaySeconds) / float64(a.PayTimed)
+}
+
+// parseRange 解析日期范围
+func (s *Service) parseRange(startDate, endDate string) (time.Time, time.Time, error) {
+	start, err := s.parseDate(startDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := s.parseDate(endDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if start.After(end) {
+		return time.Time{}, time.Time{}, model.ErrInvalidStatisticsRange
+	}
+	return start, end, nil
+}
+
+// parseDate 按统计时区解析日期
+func (s *Service) parseDate(value string) (time.Time, error) {
+	date, err := time.ParseInLocation(dateLayout, value, s.options.Location)
+	if err != nil {
+		return time.Time{}, model.ErrInvalidStatisticsDate
+	}
+	return date, nil
+}
+
+// startOfDay 统计时区的当日零点
+func (s *Service) startOfDay(t time.Time) time.Time {
+	t = t.In(s.options.Location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.options.Location)
+}
+
+// baseAmount 结算币种金额，未记录结算金额的历史支付取支付金额
+func baseAmount(amount, settlementAmount decimal.Decimal) decimal.Decimal {
+	if settlementAmount.IsPositive() {
+		return settlementAmount
+	}
+	return amount
+}
@@ -0,0 +1,179 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// StatsServiceTestSuite 支付统计服务测试套件
+type StatsServiceTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service *Service
+}
+
+// SetupTest 每个用例使用独立的内存数据库，统计按UTC日期汇总，当前时间固定
+func (suite *StatsServiceTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.AutoMigrate(&model.Payment{}, &model.PaymentRefund{}, &model.PaymentDailyRollup{}))
+
+	options := DefaultOptions()
+	options.Location = time.UTC
+	suite.db = db
+	suite.service = NewService(db, options)
+	suite.service.now = func() time.Time { return time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC) }
+}
+
+// createPayment 创建支付记录，paidAfter 为0表示未支付
+func (suite *StatsServiceTestSuite) createPayment(method model.PaymentMethod, status model.PaymentStatus, amount string, createdAt time.Time, paidAfter time.Duration) *model.Payment {
+	payment := &model.Payment{
+		PaymentNo:     "PAY" + createdAt.Format("20060102150405.000000000") + string(method),
+		OrderID:       1,
+		UserID:        1,
+		PaymentMethod: method,
+		PaymentStatus: status,
+		Amount:        decimal.RequireFromString(amount),
+		CreatedAt:     createdAt,
+	}
+	if paidAfter > 0 {
+		paidAt := createdAt.Add(paidAfter)
+		payment.PaidAt = &paidAt
+	}
+	suite.Require().NoError(suite.db.Create(payment).Error)
+	return payment
+}
+
+func (suite *StatsServiceTestSuite) TestService_Rollup() {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	paid := suite.createPayment(model.PaymentMethodAlipay, model.PaymentStatusSuccess, "100.00", day.Add(time.Hour), 30*time.Second)
+	suite.createPayment(model.PaymentMethodAlipay, model.PaymentStatusPaid, "50.00", day.Add(2*time.Hour), 90*time.Second)
+	suite.createPayment(model.PaymentMethodAlipay, model.PaymentStatusFailed, "20.00", day.Add(3*time.Hour), 0)
+	suite.createPayment(model.PaymentMethodWechat, model.PaymentStatusPending, "10.00", day.Add(4*time.Hour), 0)
+	// 次日创建的支付不计入
+	suite.createPayment(model.PaymentMethodAlipay, model.PaymentStatusSuccess, "999.00", day.AddDate(0, 0, 1), time.Second)
+
+	// 外币支付按结算币种金额统计，退款按锁定汇率折算
+	foreign := suite.createPayment(model.PaymentMethodWechat, model.PaymentStatusRefunded, "10.00", day.Add(5*time.Hour), 60*time.Second)
+	suite.Require().NoError(suite.db.Model(foreign).Update("settlement_amount", decimal.NewFromFloat(72.00)).Error)
+
+	refundedAt := day.Add(6 * time.Hour)
+	suite.Require().NoError(suite.db.Create(&model.PaymentRefund{
+		RefundNo: "RF001", PaymentID: paid.ID, UserID: 1, RefundAmount: decimal.NewFromFloat(30.00),
+		RefundStatus: model.PaymentStatusSuccess, RefundedAt: &refundedAt,
+	}).Error)
+	suite.Require().NoError(suite.db.Create(&model.PaymentRefund{
+		RefundNo: "RF002", PaymentID: foreign.ID, UserID: 1, RefundAmount: decimal.NewFromFloat(5.00),
+		RefundStatus: model.PaymentStatusSuccess, RefundedAt: &refundedAt,
+	}).Error)
+	suite.Require().NoError(suite.db.Create(&model.PaymentRefund{
+		RefundNo: "RF003", PaymentID: paid.ID, UserID: 1, RefundAmount: decimal.NewFromFloat(10.00),
+		RefundStatus: model.PaymentStatusPending,
+	}).Error)
+
+	rows, err := suite.service.Rollup(day.Add(12 * time.Hour))
+	suite.Require().NoError(err)
+	suite.Equal(2, rows)
+
+	var rollups []model.PaymentDailyRollup
+	suite.Require().NoError(suite.db.Order("payment_method").Find(&rollups).Error)
+	suite.Require().Len(rollups, 2)
+
+	alipay := rollups[0]
+	suite.Equal("2026-03-01", alipay.Date)
+	suite.Equal(model.PaymentMethodAlipay, alipay.PaymentMethod)
+	suite.Equal(int64(3), alipay.TotalCount)
+	suite.True(alipay.TotalAmount.Equal(decimal.NewFromInt(170)))
+	suite.Equal(int64(2), alipay.SuccessCount)
+	suite.True(alipay.SuccessAmount.Equal(decimal.NewFromInt(150)))
+	suite.Equal(int64(1), alipay.FailedCount)
+	suite.Equal(int64(1), alipay.RefundCount)
+	suite.True(alipay.RefundAmount.Equal(decimal.NewFromInt(30)))
+	suite.Equal(int64(120), alipay.PaySeconds)
+	suite.Equal(int64(2), alipay.PayTimed)
+
+	wechat := rollups[1]
+	suite.Equal(int64(2), wechat.TotalCount)
+	suite.True(wechat.TotalAmount.Equal(decimal.NewFromInt(82)))
+	suite.Equal(int64(1), wechat.SuccessCount)
+	suite.True(wechat.RefundAmount.Equal(decimal.NewFromInt(36)))
+
+	// 重算替换原汇总行
+	suite.Require().NoError(suite.db.Model(&model.Payment{}).Where("payment_method = ?", model.PaymentMethodWechat).Delete(&model.Payment{}).Error)
+	suite.Require().NoError(suite.db.Where("payment_id = ?", foreign.ID).Delete(&model.PaymentRefund{}).Error)
+	rows, err = suite.service.Rollup(day)
+	suite.Require().NoError(err)
+	suite.Equal(1, rows)
+
+	var count int64
+	suite.Require().NoError(suite.db.Model(&model.PaymentDailyRollup{}).Count(&count).Error)
+	suite.Equal(int64(1), count)
+}
+
+func (suite *StatsServiceTestSuite) TestService_BackfillAndStatistics() {
+	suite.createPayment(model.PaymentMethodAlipay, model.PaymentStatusSuccess, "100.00", time.Date(2026, 2, 27, 10, 0, 0, 0, time.UTC), 10*time.Second)
+	suite.createPayment(model.PaymentMethodAlipay, model.PaymentStatusFailed, "100.00", time.Date(2026, 2, 28, 10, 0, 0, 0, time.UTC), 0)
+	suite.createPayment(model.PaymentMethodWechat, model.PaymentStatusSuccess, "60.00", time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), 30*time.Second)
+	suite.createPayment(model.PaymentMethodWechat, model.PaymentStatusSuccess, "40.00", time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC), 50*time.Second)
+
+	result, err := suite.service.Backfill("2026-02-27", "2026-03-02")
+	suite.Require().NoError(err)
+	suite.Equal(4, result.Days)
+	suite.Equal(4, result.Rows)
+
+	// 统计只读取汇总表，汇总后新增的支付在下次汇总前不可见
+	suite.createPayment(model.PaymentMethodWechat, model.PaymentStatusSuccess, "1000.00", time.Date(2026, 3, 2, 11, 0, 0, 0, time.UTC), time.Second)
+
+	resp, err := suite.service.Statistics(&model.PaymentStatisticsRequest{StartDate: "2026-02-27", EndDate: "2026-03-02"})
+	suite.Require().NoError(err)
+	suite.Equal(int64(4), resp.TotalCount)
+	suite.True(resp.TotalAmount.Equal(decimal.NewFromInt(300)))
+	suite.Equal(int64(3), resp.SuccessCount)
+	suite.Equal(int64(1), resp.FailedCount)
+	suite.Require().Len(resp.DailyStats, 4)
+	suite.Equal("2026-02-27", resp.DailyStats[0].Date)
+
+	alipay := resp.MethodStats[model.PaymentMethodAlipay]
+	suite.Equal(int64(2), alipay.Count)
+	suite.InDelta(0.5, alipay.SuccessRate, 1e-9)
+	suite.InDelta(10, alipay.AvgPaySeconds, 1e-9)
+	suite.InDelta(40, resp.MethodStats[model.PaymentMethodWechat].AvgPaySeconds, 1e-9)
+
+	resp, err = suite.service.Statistics(&model.PaymentStatisticsRequest{
+		PaymentMethod: model.PaymentMethodWechat,
+		StartDate:     "2026-02-01",
+		EndDate:       "2026-03-31",
+		GroupBy:       "month",
+	})
+	suite.Require().NoError(err)
+	suite.Require().Len(resp.DailyStats, 1)
+	suite.Equal("2026-03", resp.DailyStats[0].Date)
+	suite.Equal(int64(2), resp.DailyStats[0].Count)
+	suite.InDelta(1, resp.DailyStats[0].SuccessRate, 1e-9)
+
+	// 未指定日期时查询最近30天
+	resp, err = suite.service.Statistics(&model.PaymentStatisticsRequest{})
+	suite.Require().NoError(err)
+	suite.Equal(int64(4), resp.TotalCount)
+
+	_, err = suite.service.Statistics(&model.PaymentStatisticsRequest{UserID: 1})
+	suite.ErrorIs(err, model.ErrStatisticsFilterUnsupported)
+	_, err = suite.service.Statistics(&model.PaymentStatisticsRequest{StartDate: "2026-03-02", EndDate: "2026-03-01"})
+	suite.ErrorIs(err, model.ErrInvalidStatisticsRange)
+	_, err = suite.service.Backfill("2026/03/01", "2026-03-02")
+	suite.ErrorIs(err, model.ErrInvalidStatisticsDate)
+	_, err = suite.service.Backfill("2024-01-01", "2026-03-02")
+	suite.ErrorIs(err, model.ErrStatisticsRangeTooLarge)
+}
+
+func TestStatsServiceSuite(t *testing.T) {
+	suite.Run(t, new(StatsServiceTestSuite))
+}
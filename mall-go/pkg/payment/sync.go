@@ -2,30 +2,65 @@ package payment
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math/rand"
+	"os"
 	"sync"
 	"time"
 
 	"mall-go/internal/model"
 	"mall-go/pkg/logger"
+	"mall-go/pkg/pagination"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 // SyncManager 同步管理器
+// 事件持久化在 payment_sync_events 表中，工作协程通过租约认领事件，
+// 失败按指数退避重试，超过最大尝试次数后进入死信状态等待人工处理
 type SyncManager struct {
 	db         *gorm.DB
-	eventQueue chan *SyncEvent
-	workers    int
-	retryQueue chan *SyncEvent
-	maxRetries int
-	retryDelay time.Duration
+	options    SyncOptions
+	notifier   SyncNotifier
+	instanceID string
 	wg         sync.WaitGroup
 	ctx        context.Context
 	cancel     context.CancelFunc
 }
 
+// SyncOptions 同步管理器配置
+type SyncOptions struct {
+	Workers      int           // 工作协程数
+	MaxAttempts  int           // 最大尝试次数，超过后进入死信
+	BaseDelay    time.Duration // 首次重试间隔
+	MaxDelay     time.Duration // 最大重试间隔
+	LeaseTimeout time.Duration // 租约时长，工作协程崩溃后租约到期事件可被重新认领
+	PollInterval time.Duration // 轮询间隔
+	BatchSize    int           // 单次认领事件数
+}
+
+// DefaultSyncOptions 默认同步配置
+func DefaultSyncOptions() SyncOptions {
+	return SyncOptions{
+		Workers:      4,
+		MaxAttempts:  5,
+		BaseDelay:    30 * time.Second,
+		MaxDelay:     time.Hour,
+		LeaseTimeout: 2 * time.Minute,
+		PollInterval: 5 * time.Second,
+		BatchSize:    20,
+	}
+}
+
+// SyncNotifier 同步事件通知器
+// 事件以数据库为准，通知器仅用于在事件写入后尽快唤醒工作协程
+type SyncNotifier interface {
+	Notify(ctx context.Context, eventID string) error
+	Wait(ctx context.Context, consumer string, timeout time.Duration) error
+}
+
 // SyncEvent 同步事件
 type SyncEvent struct {
 	ID         string                 `json:"id"`
@@ -61,15 +96,44 @@ type SyncResult struct {
 
 // NewSyncManager 创建同步管理器
 func NewSyncManager(db *gorm.DB, workers int) *SyncManager {
+	options := DefaultSyncOptions()
+	options.Workers = workers
+	return NewSyncManagerWithOptions(db, options, nil)
+}
+
+// NewSyncManagerWithOptions 使用指定配置创建同步管理器，notifier 可为nil（仅轮询数据库）
+func NewSyncManagerWithOptions(db *gorm.DB, options SyncOptions, notifier SyncNotifier) *SyncManager {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	defaults := DefaultSyncOptions()
+	if options.Workers <= 0 {
+		options.Workers = defaults.Workers
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = defaults.MaxAttempts
+	}
+	if options.BaseDelay <= 0 {
+		options.BaseDelay = defaults.BaseDelay
+	}
+	if options.MaxDelay < options.BaseDelay {
+		options.MaxDelay = defaults.MaxDelay
+	}
+	if options.LeaseTimeout <= 0 {
+		options.LeaseTimeout = defaults.LeaseTimeout
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = defaults.PollInterval
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaults.BatchSize
+	}
+
+	hostname, _ := os.Hostname()
 	sm := &SyncManager{
 		db:         db,
-		eventQueue: make(chan *SyncEvent, 1000),
-		workers:    workers,
-		retryQueue: make(chan *SyncEvent, 500),
-		maxRetries: 3,
-		retryDelay: time.Second * 30,
+		options:    options,
+		notifier:   notifier,
+		instanceID: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
 		ctx:        ctx,
 		cancel:     cancel,
 	}
@@ -77,80 +141,173 @@ func NewSyncManager(db *gorm.DB, workers int) *SyncManager {
 	// 启动工作协程
 	sm.startWorkers()
 
-	// 启动重试协程
-	sm.startRetryWorker()
-
 	return sm
 }
 
 // startWorkers 启动工作协程
 func (sm *SyncManager) startWorkers() {
-	for i := 0; i < sm.workers; i++ {
+	for i := 0; i < sm.options.Workers; i++ {
 		sm.wg.Add(1)
 		go sm.worker(i)
 	}
 }
 
-// startRetryWorker 启动重试协程
-func (sm *SyncManager) startRetryWorker() {
-	sm.wg.Add(1)
-	go sm.retryWorker()
-}
-
 // worker 工作协程
 func (sm *SyncManager) worker(id int) {
 	defer sm.wg.Done()
 
-	logger.Info("同步工作协程启动", zap.Int("worker_id", id))
+	consumer := fmt.Sprintf("%s-%d", sm.instanceID, id)
+	logger.Info("同步工作协程启动", zap.String("consumer", consumer))
 
 	for {
 		select {
 		case <-sm.ctx.Done():
-			logger.Info("同步工作协程停止", zap.Int("worker_id", id))
+			logger.Info("同步工作协程停止", zap.String("consumer", consumer))
 			return
-		case event := <-sm.eventQueue:
-			sm.processEvent(event, id)
+		default:
+		}
+
+		// 有事件时持续处理，队列空闲时等待通知或轮询
+		if sm.processDueEvents(consumer) > 0 {
+			continue
 		}
+		sm.waitForEvents(consumer)
 	}
 }
 
-// retryWorker 重试工作协程
-func (sm *SyncManager) retryWorker() {
-	defer sm.wg.Done()
+// waitForEvents 等待新事件
+func (sm *SyncManager) waitForEvents(consumer string) {
+	if sm.notifier != nil {
+		err := sm.notifier.Wait(sm.ctx, consumer, sm.options.PollInterval)
+		if err == nil || sm.ctx.Err() != nil {
+			return
+		}
+		logger.Warn("等待同步事件通知失败，回退为轮询", zap.Error(err))
+	}
 
-	logger.Info("重试工作协程启动")
+	timer := time.NewTimer(sm.options.PollInterval)
+	defer timer.Stop()
 
-	ticker := time.NewTicker(time.Second * 10)
-	defer ticker.Stop()
+	select {
+	case <-sm.ctx.Done():
+	case <-timer.C:
+	}
+}
 
-	for {
-		select {
-		case <-sm.ctx.Done():
-			logger.Info("重试工作协程停止")
-			return
-		case <-ticker.C:
-			sm.processRetryEvents()
-		case event := <-sm.retryQueue:
-			if time.Now().After(event.NextRetry) {
-				sm.eventQueue <- event
-			} else {
-				// 重新放入重试队列
-				go func() {
-					time.Sleep(time.Until(event.NextRetry))
-					sm.retryQueue <- event
-				}()
+// processDueEvents 认领并处理到期事件，返回处理数量
+func (sm *SyncManager) processDueEvents(consumer string) int {
+	events, err := sm.claimEvents(consumer)
+	if err != nil {
+		logger.Error("认领同步事件失败", zap.Error(err))
+		return 0
+	}
+
+	for i := range events {
+		sm.processEvent(&events[i], consumer)
+	}
+
+	return len(events)
+}
+
+// claimEvents 通过租约认领到期事件
+// 待处理且已到执行时间的事件，或租约已过期的处理中事件（工作协程崩溃）均可被认领
+func (sm *SyncManager) claimEvents(consumer string) ([]model.PaymentSyncEvent, error) {
+	now := time.Now()
+	dueCondition := "(status = ? AND next_run_at <= ?) OR (status = ? AND locked_until < ?)"
+
+	var candidates []model.PaymentSyncEvent
+	if err := sm.db.Where(dueCondition, model.PaymentSyncEventPending, now, model.PaymentSyncEventProcessing, now).
+		Order("next_run_at").
+		Limit(sm.options.BatchSize).
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	leaseUntil := now.Add(sm.options.LeaseTimeout)
+	claimed := make([]model.PaymentSyncEvent, 0, len(candidates))
+	for _, event := range candidates {
+		// 租约过期的事件上次尝试已计入次数但未正常结束（如工作协程崩溃），次数用尽时直接进入死信
+		if event.Status == model.PaymentSyncEventProcessing && event.Attempts >= event.MaxAttempts {
+			if err := sm.expireEvent(&event, now); err != nil {
+				return claimed, err
 			}
+			continue
 		}
+
+		// 条件更新保证同一事件只会被一个工作协程认领
+		result := sm.db.Model(&model.PaymentSyncEvent{}).
+			Where("id = ?", event.ID).
+			Where(dueCondition, model.PaymentSyncEventPending, now, model.PaymentSyncEventProcessing, now).
+			Updates(map[string]interface{}{
+				"status":       model.PaymentSyncEventProcessing,
+				"locked_by":    consumer,
+				"locked_until": leaseUntil,
+				"attempts":     gorm.Expr("attempts + 1"),
+			})
+		if result.Error != nil {
+			return claimed, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		event.Status = model.PaymentSyncEventProcessing
+		event.LockedBy = consumer
+		event.LockedUntil = &leaseUntil
+		event.Attempts++
+		claimed = append(claimed, event)
 	}
+
+	return claimed, nil
+}
+
+// expireEvent 将租约过期且尝试次数已用尽的事件标记为死信
+func (sm *SyncManager) expireEvent(record *model.PaymentSyncEvent, now time.Time) error {
+	res := sm.db.Model(&model.PaymentSyncEvent{}).
+		Where("id = ? AND status = ? AND locked_until < ?", record.ID, model.PaymentSyncEventProcessing, now).
+		Updates(map[string]interface{}{
+			"status":       model.PaymentSyncEventDead,
+			"locked_by":    "",
+			"locked_until": nil,
+			"last_error":   fmt.Sprintf("租约已过期且尝试次数已达上限（上次租用者 %s）", record.LockedBy),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		logger.Error("同步事件租约过期且重试次数超限，进入死信",
+			zap.String("event_id", record.EventID),
+			zap.Int("attempts", record.Attempts),
+			zap.String("locked_by", record.LockedBy))
+	}
+	return nil
 }
 
 // processEvent 处理同步事件
-func (sm *SyncManager) processEvent(event *SyncEvent, workerID int) {
+func (sm *SyncManager) processEvent(record *model.PaymentSyncEvent, consumer string) {
+	event := &SyncEvent{
+		ID:         record.EventID,
+		Type:       SyncEventType(record.EventType),
+		PaymentID:  record.PaymentID,
+		OrderID:    record.OrderID,
+		UserID:     record.UserID,
+		Timestamp:  record.CreatedAt,
+		RetryCount: record.Attempts - 1,
+		MaxRetries: record.MaxAttempts - 1,
+	}
+	if record.Payload != "" {
+		if err := json.Unmarshal([]byte(record.Payload), &event.Data); err != nil {
+			sm.failEvent(record, consumer, fmt.Errorf("解析事件数据失败: %v", err))
+			return
+		}
+	}
+
 	logger.Info("处理同步事件",
 		zap.String("event_id", event.ID),
 		zap.String("event_type", string(event.Type)),
 		zap.Uint("payment_id", event.PaymentID),
-		zap.Int("worker_id", workerID))
+		zap.Int("attempt", record.Attempts),
+		zap.String("consumer", consumer))
 
 	var result *SyncResult
 	var err error
@@ -173,33 +330,16 @@ func (sm *SyncManager) processEvent(event *SyncEvent, workerID int) {
 
 	// 处理结果
 	if err != nil {
-		logger.Error("同步事件处理失败",
-			zap.String("event_id", event.ID),
-			zap.Error(err))
-
-		// 重试逻辑
-		if event.RetryCount < sm.maxRetries {
-			event.RetryCount++
-			event.NextRetry = time.Now().Add(sm.retryDelay * time.Duration(event.RetryCount))
-			sm.retryQueue <- event
-			logger.Info("事件加入重试队列",
-				zap.String("event_id", event.ID),
-				zap.Int("retry_count", event.RetryCount))
-		} else {
-			logger.Error("事件重试次数超限，放弃处理",
-				zap.String("event_id", event.ID),
-				zap.Int("retry_count", event.RetryCount))
-			sm.recordFailedEvent(event, err)
-		}
-	} else {
-		logger.Info("同步事件处理成功",
-			zap.String("event_id", event.ID),
-			zap.Any("result", result))
-		sm.recordSuccessEvent(event, result)
+		sm.failEvent(record, consumer, err)
+		return
 	}
+
+	sm.completeEvent(record, consumer, result)
 }
 
 // handlePaymentSuccess 处理支付成功事件
+// 只有待支付的订单会被更新为已支付，重复事件不会覆盖支付时间；
+// 支付超时关单后才到达的支付会恢复订单并重新扣减关单时释放的库存
 func (sm *SyncManager) handlePaymentSuccess(event *SyncEvent) (*SyncResult, error) {
 	// 开启事务
 	tx := sm.db.Begin()
@@ -209,40 +349,66 @@ func (sm *SyncManager) handlePaymentSuccess(event *SyncEvent) (*SyncResult, erro
 		}
 	}()
 
-	// 更新订单状态
-	err := tx.Model(&model.Order{}).Where("id = ?", event.OrderID).Updates(map[string]interface{}{
+	updates := map[string]interface{}{
 		"status":         model.OrderStatusPaid,
 		"payment_status": model.PaymentStatusPaid,
-		"payment_method": event.Data["payment_method"],
-		"paid_at":        time.Now(),
-	}).Error
+		"payment_type":   event.Data["payment_method"],
+		"pay_time":       time.Now(),
+	}
 
-	if err != nil {
+	// 更新订单状态
+	result := tx.Model(&model.Order{}).
+		Where("id = ? AND status = ?", event.OrderID, model.OrderStatusPending).
+		Updates(updates)
+	if result.Error != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("更新订单状态失败: %v", err)
+		return nil, fmt.Errorf("更新订单状态失败: %v", result.Error)
 	}
 
-	// 更新商品库存（如果需要）
-	if shouldUpdateStock(event) {
+	message := "订单状态同步成功"
+	if result.RowsAffected == 0 {
+		var order model.Order
+		if err := tx.First(&order, event.OrderID).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("查询订单失败: %v", err)
+		}
+
+		if !shouldUpdateStock(&order) {
+			// 订单已由其他事件更新为已支付或后续状态
+			tx.Rollback()
+			return &SyncResult{
+				Success:   true,
+				Message:   "订单已是支付后状态，无需同步",
+				Data:      map[string]interface{}{"order_id": event.OrderID, "status": order.Status},
+				Timestamp: time.Now(),
+			}, nil
+		}
+
+		// 使用过优惠券、积分或礼品卡的订单关单时已退回抵扣，无法自动恢复，交由人工处理退款
+		if order.CouponAmount.IsPositive() || order.PointsAmount.IsPositive() || order.GiftCardAmount.IsPositive() {
+			tx.Rollback()
+			return nil, fmt.Errorf("订单 %s 已取消且使用了抵扣，支付需人工处理", order.OrderNo)
+		}
+
+		// 更新商品库存
 		if err := sm.updateProductStock(tx, event); err != nil {
 			tx.Rollback()
 			return nil, fmt.Errorf("更新商品库存失败: %v", err)
 		}
-	}
-
-	// TODO: 创建订单日志功能需要实现OrderLog模型
-	// orderLog := &model.OrderLog{
-	// 	OrderID:     event.OrderID,
-	// 	UserID:      event.UserID,
-	// 	Action:      "PAYMENT_SUCCESS",
-	// 	Description: "支付成功",
-	// 	CreatedAt:   time.Now(),
-	// }
 
-	// if err := tx.Create(orderLog).Error; err != nil {
-	// 	logger.Error("创建订单日志失败", zap.Error(err))
-	// 	// 不影响主流程
-	// }
+		result = tx.Model(&model.Order{}).
+			Where("id = ? AND status = ?", event.OrderID, order.Status).
+			Updates(updates)
+		if result.Error != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("更新订单状态失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			return nil, fmt.Errorf("订单 %s 状态已变化", order.OrderNo)
+		}
+		message = "已取消订单收到支付，订单已恢复"
+	}
 
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
@@ -251,7 +417,7 @@ func (sm *SyncManager) handlePaymentSuccess(event *SyncEvent) (*SyncResult, erro
 
 	return &SyncResult{
 		Success:   true,
-		Message:   "订单状态同步成功",
+		Message:   message,
 		Data:      map[string]interface{}{"order_id": event.OrderID},
 		Timestamp: time.Now(),
 	}, nil
@@ -263,7 +429,7 @@ func (sm *SyncManager) handlePaymentFailed(event *SyncEvent) (*SyncResult, error
 	err := sm.db.Model(&model.Order{}).Where("id = ?", event.OrderID).Updates(map[string]interface{}{
 		"status":         model.OrderStatusCancelled,
 		"payment_status": model.PaymentStatusFailed,
-		"cancelled_at":   time.Now(),
+		"cancel_time":    time.Now(),
 	}).Error
 
 	if err != nil {
@@ -284,7 +450,7 @@ func (sm *SyncManager) handlePaymentCanceled(event *SyncEvent) (*SyncResult, err
 	err := sm.db.Model(&model.Order{}).Where("id = ?", event.OrderID).Updates(map[string]interface{}{
 		"status":         model.OrderStatusCancelled,
 		"payment_status": model.PaymentStatusCancelled,
-		"cancelled_at":   time.Now(),
+		"cancel_time":    time.Now(),
 	}).Error
 
 	if err != nil {
@@ -300,6 +466,7 @@ func (sm *SyncManager) handlePaymentCanceled(event *SyncEvent) (*SyncResult, err
 }
 
 // handleRefundSuccess 处理退款成功事件
+// 全额退款后订单更新为已退款，仅首次更新时恢复库存，已取消的订单取消时已恢复库存
 func (sm *SyncManager) handleRefundSuccess(event *SyncEvent) (*SyncResult, error) {
 	// 开启事务
 	tx := sm.db.Begin()
@@ -309,20 +476,40 @@ func (sm *SyncManager) handleRefundSuccess(event *SyncEvent) (*SyncResult, error
 		}
 	}()
 
-	// 更新订单状态
-	err := tx.Model(&model.Order{}).Where("id = ?", event.OrderID).Updates(map[string]interface{}{
-		"status":         model.OrderStatusRefunded,
-		"payment_status": model.PaymentStatusRefunded,
-		"refunded_at":    time.Now(),
-	}).Error
+	var order model.Order
+	if err := tx.First(&order, event.OrderID).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("查询订单失败: %v", err)
+	}
 
-	if err != nil {
+	// 更新订单状态
+	result := tx.Model(&model.Order{}).
+		Where("id = ? AND status = ?", event.OrderID, order.Status).
+		Where("status <> ?", model.OrderStatusRefunded).
+		Updates(map[string]interface{}{
+			"status":         model.OrderStatusRefunded,
+			"payment_status": model.PaymentStatusRefunded,
+		})
+	if result.Error != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("更新订单状态失败: %v", err)
+		return nil, fmt.Errorf("更新订单状态失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		// 重复事件或订单状态已被并发修改，由重试重新判断
+		tx.Rollback()
+		if order.Status == model.OrderStatusRefunded {
+			return &SyncResult{
+				Success:   true,
+				Message:   "订单已退款，无需同步",
+				Data:      map[string]interface{}{"order_id": event.OrderID},
+				Timestamp: time.Now(),
+			}, nil
+		}
+		return nil, fmt.Errorf("订单 %s 状态已变化", order.OrderNo)
 	}
 
 	// 恢复商品库存（如果需要）
-	if shouldRestoreStock(event) {
+	if shouldRestoreStock(&order) {
 		if err := sm.restoreProductStock(tx, event); err != nil {
 			tx.Rollback()
 			return nil, fmt.Errorf("恢复商品库存失败: %v", err)
@@ -359,88 +546,347 @@ func (sm *SyncManager) handleRefundFailed(event *SyncEvent) (*SyncResult, error)
 
 // PublishEvent 发布同步事件
 func (sm *SyncManager) PublishEvent(eventType SyncEventType, paymentID, orderID, userID uint, data map[string]interface{}) error {
-	event := &SyncEvent{
-		ID:         generateEventID(),
-		Type:       eventType,
-		PaymentID:  paymentID,
-		OrderID:    orderID,
-		UserID:     userID,
-		Data:       data,
-		Timestamp:  time.Now(),
-		RetryCount: 0,
-		MaxRetries: sm.maxRetries,
+	eventID, err := sm.PublishEventTx(sm.db, eventType, paymentID, orderID, userID, data)
+	if err != nil {
+		return err
 	}
 
-	select {
-	case sm.eventQueue <- event:
-		logger.Info("同步事件已发布",
-			zap.String("event_id", event.ID),
-			zap.String("event_type", string(eventType)))
-		return nil
-	default:
-		return fmt.Errorf("事件队列已满")
+	sm.Wake(eventID)
+	return nil
+}
+
+// PublishEventTx 在指定事务中写入同步事件
+// 与支付状态变更放在同一事务中，保证两者同时成功或失败；事务提交后应调用 Wake 唤醒工作协程
+func (sm *SyncManager) PublishEventTx(tx *gorm.DB, eventType SyncEventType, paymentID, orderID, userID uint, data map[string]interface{}) (string, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("序列化事件数据失败: %v", err)
+	}
+
+	event := &model.PaymentSyncEvent{
+		EventID:     generateEventID(),
+		EventType:   string(eventType),
+		PaymentID:   paymentID,
+		OrderID:     orderID,
+		UserID:      userID,
+		Payload:     string(payload),
+		Status:      model.PaymentSyncEventPending,
+		MaxAttempts: sm.options.MaxAttempts,
+		NextRunAt:   time.Now(),
+	}
+
+	if err := tx.Create(event).Error; err != nil {
+		return "", fmt.Errorf("保存同步事件失败: %v", err)
+	}
+
+	logger.Info("同步事件已发布",
+		zap.String("event_id", event.EventID),
+		zap.String("event_type", string(eventType)))
+
+	return event.EventID, nil
+}
+
+// Wake 通知工作协程有新事件，通知失败不影响事件处理（工作协程会轮询数据库）
+func (sm *SyncManager) Wake(eventID string) {
+	if sm.notifier == nil {
+		return
+	}
+
+	if err := sm.notifier.Notify(sm.ctx, eventID); err != nil {
+		logger.Warn("发送同步事件通知失败", zap.String("event_id", eventID), zap.Error(err))
 	}
 }
 
-// updateProductStock 更新商品库存
+// updateProductStock 重新扣减订单商品库存，用于恢复支付前已取消并释放库存的订单
 func (sm *SyncManager) updateProductStock(tx *gorm.DB, event *SyncEvent) error {
-	// 这里应该根据订单项更新商品库存
-	// 简化处理，实际应该查询订单项
+	var items []model.OrderItem
+	if err := tx.Where("order_id = ?", event.OrderID).Find(&items).Error; err != nil {
+		return fmt.Errorf("查询订单商品失败: %v", err)
+	}
+
+	for _, item := range items {
+		if item.SKUID > 0 {
+			result := tx.Model(&model.ProductSKU{}).
+				Where("id = ? AND stock >= ?", item.SKUID, item.Quantity).
+				UpdateColumn("stock", gorm.Expr("stock - ?", item.Quantity))
+			if result.Error != nil {
+				return fmt.Errorf("扣减SKU库存失败: %v", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("商品 %s 库存不足", item.ProductName)
+			}
+			continue
+		}
+
+		result := tx.Model(&model.Product{}).
+			Where("id = ? AND stock >= ?", item.ProductID, item.Quantity).
+			UpdateColumns(map[string]interface{}{
+				"stock":      gorm.Expr("stock - ?", item.Quantity),
+				"sold_count": gorm.Expr("sold_count + ?", item.Quantity),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("扣减商品库存失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("商品 %s 库存不足", item.ProductName)
+		}
+	}
 	return nil
 }
 
-// restoreProductStock 恢复商品库存
+// restoreProductStock 恢复订单商品库存，已通过退货售后恢复的数量不再重复恢复
 func (sm *SyncManager) restoreProductStock(tx *gorm.DB, event *SyncEvent) error {
-	// 这里应该根据订单项恢复商品库存
-	// 简化处理，实际应该查询订单项
+	var items []model.OrderItem
+	if err := tx.Where("order_id = ?", event.OrderID).Find(&items).Error; err != nil {
+		return fmt.Errorf("查询订单商品失败: %v", err)
+	}
+
+	// 确认退货时售后已恢复对应商品库存
+	var returned []struct {
+		OrderItemID uint
+		Quantity    int
+	}
+	if err := tx.Model(&model.OrderAfterSale{}).
+		Select("order_item_id, SUM(quantity) AS quantity").
+		Where("order_id = ? AND type <> ? AND status IN ?", event.OrderID, model.AfterSaleTypeRefund,
+			[]string{model.AfterSaleStatusRefunding, model.AfterSaleStatusRefundFailed, model.AfterSaleStatusCompleted}).
+		Group("order_item_id").
+		Scan(&returned).Error; err != nil {
+		return fmt.Errorf("查询售后退货数量失败: %v", err)
+	}
+	returnedQuantity := make(map[uint]int, len(returned))
+	for _, r := range returned {
+		returnedQuantity[r.OrderItemID] = r.Quantity
+	}
+
+	for _, item := range items {
+		quantity := item.Quantity - returnedQuantity[item.ID]
+		if quantity <= 0 {
+			continue
+		}
+
+		if item.SKUID > 0 {
+			if err := tx.Model(&model.ProductSKU{}).
+				Where("id = ?", item.SKUID).
+				UpdateColumn("stock", gorm.Expr("stock + ?", quantity)).Error; err != nil {
+				return fmt.Errorf("恢复SKU库存失败: %v", err)
+			}
+			continue
+		}
+
+		if err := tx.Model(&model.Product{}).
+			Where("id = ?", item.ProductID).
+			UpdateColumns(map[string]interface{}{
+				"stock":      gorm.Expr("stock + ?", quantity),
+				"sold_count": gorm.Expr("CASE WHEN sold_count > ? THEN sold_count - ? ELSE 0 END", quantity, quantity),
+			}).Error; err != nil {
+			return fmt.Errorf("恢复商品库存失败: %v", err)
+		}
+	}
 	return nil
 }
 
-// shouldUpdateStock 是否应该更新库存
-func shouldUpdateStock(event *SyncEvent) bool {
-	// 根据业务规则判断是否需要更新库存
-	return true
+// shouldUpdateStock 是否应该重新扣减库存，只有取消或关闭时已释放库存的订单需要
+func shouldUpdateStock(order *model.Order) bool {
+	return order.Status == model.OrderStatusCancelled || order.Status == model.OrderStatusClosed
 }
 
-// shouldRestoreStock 是否应该恢复库存
-func shouldRestoreStock(event *SyncEvent) bool {
-	// 根据业务规则判断是否需要恢复库存
-	return true
+// shouldRestoreStock 是否应该恢复库存，取消或关闭的订单在取消时已恢复库存
+func shouldRestoreStock(order *model.Order) bool {
+	return order.Status != model.OrderStatusCancelled && order.Status != model.OrderStatusClosed
 }
 
-// processRetryEvents 处理重试事件
-func (sm *SyncManager) processRetryEvents() {
-	// 从数据库中查询需要重试的事件
-	// 这里简化处理
-}
+// completeEvent 标记事件处理成功
+func (sm *SyncManager) completeEvent(record *model.PaymentSyncEvent, consumer string, result *SyncResult) {
+	now := time.Now()
+	res := sm.db.Model(&model.PaymentSyncEvent{}).
+		Where("id = ? AND status = ? AND locked_by = ?", record.ID, model.PaymentSyncEventProcessing, consumer).
+		Updates(map[string]interface{}{
+			"status":       model.PaymentSyncEventSucceeded,
+			"processed_at": now,
+			"locked_by":    "",
+			"locked_until": nil,
+			"last_error":   "",
+		})
+	if res.Error != nil {
+		logger.Error("更新同步事件状态失败", zap.String("event_id", record.EventID), zap.Error(res.Error))
+		return
+	}
+	if res.RowsAffected == 0 {
+		// 租约已过期并被其他工作协程认领，事件处理需保持幂等
+		logger.Warn("同步事件租约已失效", zap.String("event_id", record.EventID))
+		return
+	}
 
-// recordSuccessEvent 记录成功事件
-func (sm *SyncManager) recordSuccessEvent(event *SyncEvent, result *SyncResult) {
-	// 记录成功的同步事件
-	logger.Info("同步事件成功",
-		zap.String("event_id", event.ID),
+	logger.Info("同步事件处理成功",
+		zap.String("event_id", record.EventID),
 		zap.Any("result", result))
 }
 
-// recordFailedEvent 记录失败事件
-func (sm *SyncManager) recordFailedEvent(event *SyncEvent, err error) {
-	// 记录失败的同步事件
-	logger.Error("同步事件最终失败",
-		zap.String("event_id", event.ID),
-		zap.Error(err))
+// failEvent 记录事件处理失败，未超过最大尝试次数时按指数退避重新排期，否则进入死信
+func (sm *SyncManager) failEvent(record *model.PaymentSyncEvent, consumer string, err error) {
+	updates := map[string]interface{}{
+		"locked_by":    "",
+		"locked_until": nil,
+		"last_error":   err.Error(),
+	}
+
+	if record.Attempts >= record.MaxAttempts {
+		updates["status"] = model.PaymentSyncEventDead
+		logger.Error("同步事件重试次数超限，进入死信",
+			zap.String("event_id", record.EventID),
+			zap.Int("attempts", record.Attempts),
+			zap.Error(err))
+	} else {
+		nextRunAt := time.Now().Add(sm.backoff(record.Attempts))
+		updates["status"] = model.PaymentSyncEventPending
+		updates["next_run_at"] = nextRunAt
+		logger.Warn("同步事件处理失败，等待重试",
+			zap.String("event_id", record.EventID),
+			zap.Int("attempts", record.Attempts),
+			zap.Time("next_run_at", nextRunAt),
+			zap.Error(err))
+	}
+
+	if res := sm.db.Model(&model.PaymentSyncEvent{}).
+		Where("id = ? AND status = ? AND locked_by = ?", record.ID, model.PaymentSyncEventProcessing, consumer).
+		Updates(updates); res.Error != nil {
+		logger.Error("更新同步事件状态失败", zap.String("event_id", record.EventID), zap.Error(res.Error))
+	}
+}
+
+// backoff 计算第 attempts 次失败后的重试间隔：BaseDelay * 2^(attempts-1)，不超过 MaxDelay
+func (sm *SyncManager) backoff(attempts int) time.Duration {
+	delay := sm.options.BaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= sm.options.MaxDelay {
+			return sm.options.MaxDelay
+		}
+	}
+	return delay
+}
+
+// ListEvents 查询同步事件
+func (sm *SyncManager) ListEvents(query *model.PaymentSyncEventQuery) ([]model.PaymentSyncEvent, int64, error) {
+	db := sm.db.Model(&model.PaymentSyncEvent{})
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.EventType != "" {
+		db = db.Where("event_type = ?", query.EventType)
+	}
+	if query.PaymentID > 0 {
+		db = db.Where("payment_id = ?", query.PaymentID)
+	}
+	if query.OrderID > 0 {
+		db = db.Where("order_id = ?", query.OrderID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计同步事件失败: %v", err)
+	}
+
+	page, pageSize := pagination.Normalize(query.Page, query.PageSize)
+
+	var events []model.PaymentSyncEvent
+	if err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询同步事件失败: %v", err)
+	}
+
+	return events, total, nil
+}
+
+// GetEvent 获取同步事件详情
+func (sm *SyncManager) GetEvent(eventID string) (*model.PaymentSyncEvent, error) {
+	var event model.PaymentSyncEvent
+	if err := sm.db.Where("event_id = ?", eventID).First(&event).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, model.ErrSyncEventNotFound
+		}
+		return nil, fmt.Errorf("查询同步事件失败: %v", err)
+	}
+	return &event, nil
+}
+
+// CountByStatus 按状态统计同步事件数量
+func (sm *SyncManager) CountByStatus() (map[model.PaymentSyncEventStatus]int64, error) {
+	var rows []struct {
+		Status model.PaymentSyncEventStatus
+		Count  int64
+	}
+	if err := sm.db.Model(&model.PaymentSyncEvent{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("统计同步事件失败: %v", err)
+	}
+
+	counts := make(map[model.PaymentSyncEventStatus]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// ReplayEvent 重放死信或已丢弃的事件，重置尝试次数后立即执行
+func (sm *SyncManager) ReplayEvent(eventID string) error {
+	res := sm.db.Model(&model.PaymentSyncEvent{}).
+		Where("event_id = ? AND status IN ?", eventID,
+			[]model.PaymentSyncEventStatus{model.PaymentSyncEventDead, model.PaymentSyncEventDiscarded}).
+		Updates(map[string]interface{}{
+			"status":       model.PaymentSyncEventPending,
+			"attempts":     0,
+			"next_run_at":  time.Now(),
+			"locked_by":    "",
+			"locked_until": nil,
+		})
+	if res.Error != nil {
+		return fmt.Errorf("重放同步事件失败: %v", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return sm.stateError(eventID)
+	}
+
+	logger.Info("同步事件已重放", zap.String("event_id", eventID))
+	sm.Wake(eventID)
+	return nil
+}
+
+// DiscardEvent 丢弃死信事件
+func (sm *SyncManager) DiscardEvent(eventID string) error {
+	res := sm.db.Model(&model.PaymentSyncEvent{}).
+		Where("event_id = ? AND status = ?", eventID, model.PaymentSyncEventDead).
+		Update("status", model.PaymentSyncEventDiscarded)
+	if res.Error != nil {
+		return fmt.Errorf("丢弃同步事件失败: %v", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return sm.stateError(eventID)
+	}
+
+	logger.Info("同步事件已丢弃", zap.String("event_id", eventID))
+	return nil
+}
+
+// stateError 区分事件不存在与状态不允许操作
+func (sm *SyncManager) stateError(eventID string) error {
+	if _, err := sm.GetEvent(eventID); err != nil {
+		return err
+	}
+	return model.ErrSyncEventState
 }
 
-// generateEventID 生成事件ID
+// generateEventID 生成事件ID，多实例同时写入时追加随机数避免冲突
 func generateEventID() string {
-	return fmt.Sprintf("sync_%d", time.Now().UnixNano())
+	return fmt.Sprintf("sync_%d%04d", time.Now().UnixNano(), rand.Intn(10000))
 }
 
-// Stop 停止同步管理器
+// Stop 停止同步管理器，未处理完的事件保留在数据库中，重启后继续处理
 func (sm *SyncManager) Stop() {
 	logger.Info("停止同步管理器")
 	sm.cancel()
 	sm.wg.Wait()
-	close(sm.eventQueue)
-	close(sm.retryQueue)
 	logger.Info("同步管理器已停止")
 }
@@ -0,0 +1,82 @@
+package payment
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 同步事件通知流默认配置
+const (
+	defaultSyncStream      = "payment:sync:events"
+	defaultSyncStreamGroup = "payment-sync-workers"
+	syncStreamMaxLen       = 10000
+)
+
+// RedisStreamNotifier 基于Redis Streams的同步事件通知器
+// 流中只保存事件ID用于唤醒工作协程，事件的认领与状态仍以数据库为准，消息读取后立即确认
+type RedisStreamNotifier struct {
+	rdb    *redis.Client
+	stream string
+	group  string
+}
+
+// NewRedisStreamNotifier 创建Redis Streams通知器
+func NewRedisStreamNotifier(ctx context.Context, rdb *redis.Client) (*RedisStreamNotifier, error) {
+	n := &RedisStreamNotifier{
+		rdb:    rdb,
+		stream: defaultSyncStream,
+		group:  defaultSyncStreamGroup,
+	}
+
+	// 消费组已存在时忽略错误
+	err := rdb.XGroupCreateMkStream(ctx, n.stream, n.group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// Notify 写入事件通知
+func (n *RedisStreamNotifier) Notify(ctx context.Context, eventID string) error {
+	return n.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: n.stream,
+		MaxLen: syncStreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"event_id": eventID},
+	}).Err()
+}
+
+// Wait 阻塞等待事件通知，超时返回nil
+func (n *RedisStreamNotifier) Wait(ctx context.Context, consumer string, timeout time.Duration) error {
+	streams, err := n.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    n.group,
+		Consumer: consumer,
+		Streams:  []string{n.stream, ">"},
+		Count:    10,
+		Block:    timeout,
+	}).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, stream := range streams {
+		ids := make([]string, 0, len(stream.Messages))
+		for _, msg := range stream.Messages {
+			ids = append(ids, msg.ID)
+		}
+		if len(ids) > 0 {
+			if err := n.rdb.XAck(ctx, n.stream, n.group, ids...).Err(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,365 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newTestSyncManager 创建不启动工作协程的同步管理器，由测试直接驱动认领与处理
+func newTestSyncManager(t *testing.T) (*SyncManager, *gorm.DB) {
+	db := setupTestDB()
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1) // 内存库每个连接独立，共用同一连接
+	require.NoError(t, db.AutoMigrate(&model.PaymentSyncEvent{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	options := DefaultSyncOptions()
+	options.MaxAttempts = 3
+	options.BaseDelay = time.Minute
+	options.MaxDelay = 5 * time.Minute
+
+	return &SyncManager{db: db, options: options, instanceID: "test", ctx: ctx, cancel: cancel}, db
+}
+
+// publishTestEvent 写入一条立即到期的同步事件
+func publishTestEvent(t *testing.T, sm *SyncManager, eventType SyncEventType) *model.PaymentSyncEvent {
+	eventID, err := sm.PublishEventTx(sm.db, eventType, 1, 1, 1, map[string]interface{}{"amount": "10.00"})
+	require.NoError(t, err)
+
+	event, err := sm.GetEvent(eventID)
+	require.NoError(t, err)
+	return event
+}
+
+// expireLease 将事件租约改为已过期，模拟工作协程崩溃
+func expireLease(t *testing.T, db *gorm.DB, event *model.PaymentSyncEvent) {
+	require.NoError(t, db.Model(&model.PaymentSyncEvent{}).
+		Where("id = ?", event.ID).
+		Update("locked_until", time.Now().Add(-time.Second)).Error)
+}
+
+func TestSyncManager_ClaimLease(t *testing.T) {
+	sm, _ := newTestSyncManager(t)
+	event := publishTestEvent(t, sm, SyncEventRefundFailed)
+
+	claimed, err := sm.claimEvents("worker-a")
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, event.EventID, claimed[0].EventID)
+	assert.Equal(t, 1, claimed[0].Attempts)
+
+	// 租约有效期内其他工作协程不能认领
+	claimed, err = sm.claimEvents("worker-b")
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	stored, err := sm.GetEvent(event.EventID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentSyncEventProcessing, stored.Status)
+	assert.Equal(t, "worker-a", stored.LockedBy)
+	require.NotNil(t, stored.LockedUntil)
+	assert.True(t, stored.LockedUntil.After(time.Now()))
+}
+
+func TestSyncManager_LeaseExpiry(t *testing.T) {
+	sm, db := newTestSyncManager(t)
+	event := publishTestEvent(t, sm, SyncEventRefundFailed)
+
+	claimed, err := sm.claimEvents("worker-a")
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+
+	// 租约过期后事件可被重新认领，且计入尝试次数
+	expireLease(t, db, event)
+	claimed, err = sm.claimEvents("worker-b")
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, 2, claimed[0].Attempts)
+
+	// 原租用者租约已失效，不能再提交结果
+	sm.completeEvent(&claimed[0], "worker-a", nil)
+	stored, err := sm.GetEvent(event.EventID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentSyncEventProcessing, stored.Status)
+	assert.Equal(t, "worker-b", stored.LockedBy)
+
+	sm.processEvent(&claimed[0], "worker-b")
+	stored, err = sm.GetEvent(event.EventID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentSyncEventSucceeded, stored.Status)
+	assert.NotNil(t, stored.ProcessedAt)
+}
+
+func TestSyncManager_LeaseExpiryDeadLetter(t *testing.T) {
+	sm, db := newTestSyncManager(t)
+	event := publishTestEvent(t, sm, SyncEventRefundFailed)
+
+	// 工作协程每次认领后都崩溃，租约过期次数达到上限后进入死信
+	for i := 1; i <= sm.options.MaxAttempts; i++ {
+		claimed, err := sm.claimEvents("worker")
+		require.NoError(t, err)
+		require.Len(t, claimed, 1, "第 %d 次认领", i)
+		assert.Equal(t, i, claimed[0].Attempts)
+		expireLease(t, db, event)
+	}
+
+	claimed, err := sm.claimEvents("worker")
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	stored, err := sm.GetEvent(event.EventID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentSyncEventDead, stored.Status)
+	assert.Equal(t, sm.options.MaxAttempts, stored.Attempts)
+	assert.Empty(t, stored.LockedBy)
+	assert.Nil(t, stored.LockedUntil)
+	assert.NotEmpty(t, stored.LastError)
+}
+
+func TestSyncManager_Backoff(t *testing.T) {
+	sm, _ := newTestSyncManager(t)
+
+	assert.Equal(t, time.Minute, sm.backoff(1))
+	assert.Equal(t, 2*time.Minute, sm.backoff(2))
+	assert.Equal(t, 4*time.Minute, sm.backoff(3))
+	assert.Equal(t, 5*time.Minute, sm.backoff(4))
+	assert.Equal(t, 5*time.Minute, sm.backoff(10))
+}
+
+func TestSyncManager_FailureBackoffAndDeadLetter(t *testing.T) {
+	sm, db := newTestSyncManager(t)
+	event := publishTestEvent(t, sm, SyncEventType("unknown"))
+
+	for i := 1; i <= sm.options.MaxAttempts; i++ {
+		claimed, err := sm.claimEvents("worker")
+		require.NoError(t, err)
+		require.Len(t, claimed, 1, "第 %d 次认领", i)
+
+		before := time.Now()
+		sm.processEvent(&claimed[0], "worker")
+
+		stored, err := sm.GetEvent(event.EventID)
+		require.NoError(t, err)
+		assert.Equal(t, i, stored.Attempts)
+		assert.Contains(t, stored.LastError, "未知的事件类型")
+		assert.Empty(t, stored.LockedBy)
+
+		if i == sm.options.MaxAttempts {
+			assert.Equal(t, model.PaymentSyncEventDead, stored.Status)
+			break
+		}
+
+		// 未到期前不会被重新认领，退避间隔按尝试次数翻倍
+		assert.Equal(t, model.PaymentSyncEventPending, stored.Status)
+		delay := sm.backoff(i)
+		assert.WithinDuration(t, before.Add(delay), stored.NextRunAt, time.Second)
+
+		claimed, err = sm.claimEvents("worker")
+		require.NoError(t, err)
+		assert.Empty(t, claimed)
+
+		require.NoError(t, db.Model(&model.PaymentSyncEvent{}).
+			Where("id = ?", event.ID).
+			Update("next_run_at", time.Now().Add(-time.Second)).Error)
+	}
+
+	// 死信不再被认领
+	claimed, err := sm.claimEvents("worker")
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+}
+
+func TestSyncManager_ReplayAndDiscard(t *testing.T) {
+	sm, db := newTestSyncManager(t)
+	event := publishTestEvent(t, sm, SyncEventRefundFailed)
+
+	// 仅死信或已丢弃的事件可以重放，仅死信可以丢弃
+	assert.Equal(t, model.ErrSyncEventState, sm.ReplayEvent(event.EventID))
+	assert.Equal(t, model.ErrSyncEventState, sm.DiscardEvent(event.EventID))
+	assert.Equal(t, model.ErrSyncEventNotFound, sm.ReplayEvent("missing"))
+	assert.Equal(t, model.ErrSyncEventNotFound, sm.DiscardEvent("missing"))
+
+	require.NoError(t, db.Model(&model.PaymentSyncEvent{}).
+		Where("id = ?", event.ID).
+		Updates(map[string]interface{}{
+			"status":   model.PaymentSyncEventDead,
+			"attempts": sm.options.MaxAttempts,
+		}).Error)
+
+	require.NoError(t, sm.DiscardEvent(event.EventID))
+	stored, err := sm.GetEvent(event.EventID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentSyncEventDiscarded, stored.Status)
+
+	require.NoError(t, sm.ReplayEvent(event.EventID))
+	stored, err = sm.GetEvent(event.EventID)
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentSyncEventPending, stored.Status)
+	assert.Equal(t, 0, stored.Attempts)
+
+	// 重放后立即可被认领并重新计数
+	claimed, err := sm.claimEvents("worker")
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, 1, claimed[0].Attempts)
+
+	counts, err := sm.CountByStatus()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), counts[model.PaymentSyncEventProcessing])
+}
+
+func TestSyncManager_ListEvents(t *testing.T) {
+	sm, _ := newTestSyncManager(t)
+	for i := 0; i < 3; i++ {
+		publishTestEvent(t, sm, SyncEventRefundFailed)
+	}
+	publishTestEvent(t, sm, SyncEventPaymentFailed)
+
+	events, total, err := sm.ListEvents(&model.PaymentSyncEventQuery{
+		EventType: string(SyncEventRefundFailed),
+		Page:      2,
+		PageSize:  2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Len(t, events, 1)
+
+	// 超出上限的每页数量回退为默认值
+	events, total, err = sm.ListEvents(&model.PaymentSyncEventQuery{PageSize: 1000})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+	assert.Len(t, events, 4)
+}
+
+// createSyncTestOrder 创建指定状态的订单及一件订单商品，返回订单和商品
+func createSyncTestOrder(t *testing.T, db *gorm.DB, orderNo, status string, stock, quantity int) (*model.Order, *model.Product) {
+	require.NoError(t, db.AutoMigrate(&model.Product{}, &model.ProductSKU{}, &model.OrderItem{}, &model.OrderAfterSale{}))
+
+	product := &model.Product{Name: "同步测试商品", CategoryID: 1, MerchantID: 1, Price: decimal.NewFromInt(10), Stock: stock, SoldCount: quantity}
+	require.NoError(t, db.Create(product).Error)
+
+	order := &model.Order{
+		OrderNo:       orderNo,
+		UserID:        1,
+		TotalAmount:   decimal.NewFromInt(int64(10 * quantity)),
+		PayableAmount: decimal.NewFromInt(int64(10 * quantity)),
+		Status:        status,
+		PaymentStatus: string(model.PaymentStatusPending),
+	}
+	require.NoError(t, db.Create(order).Error)
+	require.NoError(t, db.Create(&model.OrderItem{
+		OrderID:     order.ID,
+		ProductID:   product.ID,
+		Quantity:    quantity,
+		ProductName: product.Name,
+		Price:       product.Price,
+		TotalPrice:  product.Price.Mul(decimal.NewFromInt(int64(quantity))),
+	}).Error)
+	return order, product
+}
+
+func TestSyncManager_PaymentSuccessIdempotent(t *testing.T) {
+	sm, db := newTestSyncManager(t)
+	order, product := createSyncTestOrder(t, db, "ORDER_SYNC_PAID", model.OrderStatusPending, 8, 2)
+	event := &SyncEvent{OrderID: order.ID, Data: map[string]interface{}{"payment_method": "alipay"}}
+
+	_, err := sm.handlePaymentSuccess(event)
+	require.NoError(t, err)
+	var paid model.Order
+	require.NoError(t, db.First(&paid, order.ID).Error)
+	assert.Equal(t, model.OrderStatusPaid, paid.Status)
+	require.NotNil(t, paid.PayTime)
+
+	// 重复事件不覆盖支付时间，下单时已扣减的库存不再扣减
+	require.NoError(t, db.Model(&model.Order{}).Where("id = ?", order.ID).Update("status", model.OrderStatusShipped).Error)
+	_, err = sm.handlePaymentSuccess(event)
+	require.NoError(t, err)
+	var reloaded model.Order
+	require.NoError(t, db.First(&reloaded, order.ID).Error)
+	assert.Equal(t, model.OrderStatusShipped, reloaded.Status)
+	assert.True(t, paid.PayTime.Equal(*reloaded.PayTime))
+
+	var stored model.Product
+	require.NoError(t, db.First(&stored, product.ID).Error)
+	assert.Equal(t, 8, stored.Stock)
+}
+
+func TestSyncManager_PaymentSuccessRevivesCancelledOrder(t *testing.T) {
+	sm, db := newTestSyncManager(t)
+	order, product := createSyncTestOrder(t, db, "ORDER_SYNC_REVIVE", model.OrderStatusCancelled, 5, 2)
+
+	_, err := sm.handlePaymentSuccess(&SyncEvent{OrderID: order.ID, Data: map[string]interface{}{"payment_method": "alipay"}})
+	require.NoError(t, err)
+
+	var reloaded model.Order
+	require.NoError(t, db.First(&reloaded, order.ID).Error)
+	assert.Equal(t, model.OrderStatusPaid, reloaded.Status)
+	assert.NotNil(t, reloaded.PayTime)
+
+	// 取消时释放的库存重新扣减
+	var stored model.Product
+	require.NoError(t, db.First(&stored, product.ID).Error)
+	assert.Equal(t, 3, stored.Stock)
+	assert.Equal(t, 4, stored.SoldCount)
+
+	// 库存不足时事件失败，订单保持取消状态等待重试或人工处理
+	shortOrder, _ := createSyncTestOrder(t, db, "ORDER_SYNC_SHORT", model.OrderStatusCancelled, 1, 2)
+	_, err = sm.handlePaymentSuccess(&SyncEvent{OrderID: shortOrder.ID})
+	assert.Error(t, err)
+	var short model.Order
+	require.NoError(t, db.First(&short, shortOrder.ID).Error)
+	assert.Equal(t, model.OrderStatusCancelled, short.Status)
+}
+
+func TestSyncManager_RefundSuccessRestoresStockOnce(t *testing.T) {
+	sm, db := newTestSyncManager(t)
+	order, product := createSyncTestOrder(t, db, "ORDER_SYNC_REFUND", model.OrderStatusPaid, 5, 3)
+
+	// 确认退货时售后已恢复1件库存
+	var item model.OrderItem
+	require.NoError(t, db.Where("order_id = ?", order.ID).First(&item).Error)
+	require.NoError(t, db.Create(&model.OrderAfterSale{
+		OrderID:     order.ID,
+		OrderItemID: item.ID,
+		AfterSaleNo: "AS_SYNC_REFUND",
+		Type:        model.AfterSaleTypeReturn,
+		Status:      model.AfterSaleStatusCompleted,
+		ApplyUserID: 1,
+		Reason:      "退货",
+		Quantity:    1,
+	}).Error)
+
+	event := &SyncEvent{OrderID: order.ID}
+	_, err := sm.handleRefundSuccess(event)
+	require.NoError(t, err)
+	_, err = sm.handleRefundSuccess(event)
+	require.NoError(t, err)
+
+	var reloaded model.Order
+	require.NoError(t, db.First(&reloaded, order.ID).Error)
+	assert.Equal(t, model.OrderStatusRefunded, reloaded.Status)
+
+	var stored model.Product
+	require.NoError(t, db.First(&stored, product.ID).Error)
+	assert.Equal(t, 7, stored.Stock)
+	assert.Equal(t, 1, stored.SoldCount)
+
+	// 已取消的订单取消时已恢复库存
+	cancelled, cancelledProduct := createSyncTestOrder(t, db, "ORDER_SYNC_CANCELLED", model.OrderStatusCancelled, 5, 2)
+	_, err = sm.handleRefundSuccess(&SyncEvent{OrderID: cancelled.ID})
+	require.NoError(t, err)
+	var restored model.Product
+	require.NoError(t, db.First(&restored, cancelledProduct.ID).Error)
+	assert.Equal(t, 5, restored.Stock)
+}
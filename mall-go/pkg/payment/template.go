@@ -1,9 +1,7 @@
 package payment
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -224,17 +222,7 @@ func LoadTemplateByEnvironment(env string) *PaymentConfig {
 // GenerateConfigFile 生成配置文件
 func GenerateConfigFile(env string, filePath string) error {
 	template := LoadTemplateByEnvironment(env)
-
-	data, err := json.MarshalIndent(template, "", "  ")
-	if err != nil {
-		return fmt.Errorf("序列化配置模板失败: %v", err)
-	}
-
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		return fmt.Errorf("写入配置文件失败: %v", err)
-	}
-
-	return nil
+	return template.SaveToFile(filePath)
 }
 
 // ValidateEnvironmentConfig 验证环境配置完整性
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"mall-go/pkg/logger"
+	"mall-go/pkg/payment/secret"
 
 	"go.uber.org/zap"
 )
@@ -53,20 +54,9 @@ func (ct *ConfigTool) GenerateConfigForEnvironment(env string, force bool) error
 	// 生成配置模板
 	template := LoadTemplateByEnvironment(env)
 
-	// 创建配置目录
-	if err := os.MkdirAll(filepath.Dir(ct.configPath), 0755); err != nil {
-		return fmt.Errorf("创建配置目录失败: %v", err)
-	}
-
-	// 序列化配置
-	data, err := json.MarshalIndent(template, "", "  ")
-	if err != nil {
-		return fmt.Errorf("序列化配置失败: %v", err)
-	}
-
-	// 写入配置文件
-	if err := os.WriteFile(ct.configPath, data, 0644); err != nil {
-		return fmt.Errorf("写入配置文件失败: %v", err)
+	// 写入配置文件，密钥字段加密保存
+	if err := template.SaveToFile(ct.configPath); err != nil {
+		return err
 	}
 
 	// 生成示例环境变量文件
@@ -217,7 +207,7 @@ func (ct *ConfigTool) backupExistingConfig() error {
 		return fmt.Errorf("读取原配置文件失败: %v", err)
 	}
 
-	if err := os.WriteFile(backupFile, data, 0644); err != nil {
+	if err := os.WriteFile(backupFile, data, 0600); err != nil {
 		return fmt.Errorf("写入备份文件失败: %v", err)
 	}
 
@@ -238,6 +228,11 @@ func (ct *ConfigTool) generateEnvExample(env string) error {
 	envContent.WriteString("PAYMENT_DEBUG=true\n")
 	envContent.WriteString("PAYMENT_LOG_LEVEL=info\n\n")
 
+	envContent.WriteString("# 配置文件密钥加密主密钥，使用 payment-config -cmd=gen-key 生成\n")
+	envContent.WriteString("PAYMENT_MASTER_KEY=\n")
+	envContent.WriteString("# PAYMENT_MASTER_KEY_FILE=/path/to/master.key\n")
+	envContent.WriteString("# PAYMENT_MASTER_KEY_PREVIOUS=\n\n")
+
 	envContent.WriteString("# 支付宝配置\n")
 	if env == "prod" {
 		envContent.WriteString("ALIPAY_APP_ID=your_production_app_id\n")
@@ -412,7 +407,7 @@ func (ct *ConfigTool) RestoreFromBackup(backupFileName string) error {
 		return fmt.Errorf("读取备份文件失败: %v", err)
 	}
 
-	if err := os.WriteFile(ct.configPath, data, 0644); err != nil {
+	if err := os.WriteFile(ct.configPath, data, 0600); err != nil {
 		return fmt.Errorf("恢复配置文件失败: %v", err)
 	}
 
@@ -422,3 +417,49 @@ func (ct *ConfigTool) RestoreFromBackup(backupFileName string) error {
 
 	return nil
 }
+
+// RotateSecrets 使用当前主密钥重新加密配置文件中的密钥，返回重新加密的字段数
+// 密钥环需包含轮换前的主密钥；尚未加密的明文密钥同样会被加密
+func (ct *ConfigTool) RotateSecrets(keyring *secret.Keyring) (int, error) {
+	if _, err := os.Stat(ct.configPath); err != nil {
+		return 0, fmt.Errorf("配置文件不存在: %s", ct.configPath)
+	}
+
+	data, err := os.ReadFile(ct.configPath)
+	if err != nil {
+		return 0, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	var stored PaymentConfig
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return 0, fmt.Errorf("解析配置文件失败: %v", err)
+	}
+	rotated := 0
+	for _, value := range stored.secretFields() {
+		if *value != "" && secret.KeyIDOf(*value) != keyring.PrimaryKeyID() {
+			rotated++
+		}
+	}
+	if rotated == 0 {
+		return 0, nil
+	}
+
+	config, err := LoadConfigFromFileWithKeyring(ct.configPath, keyring)
+	if err != nil {
+		return 0, err
+	}
+
+	// 备份轮换前的配置
+	if err := ct.backupExistingConfig(); err != nil {
+		logger.Warn("备份现有配置失败", zap.Error(err))
+	}
+
+	if err := config.SaveToFileWithKeyring(ct.configPath, keyring); err != nil {
+		return 0, err
+	}
+
+	logger.Info("配置文件密钥轮换完成",
+		zap.String("config_path", ct.configPath),
+		zap.String("key_id", keyring.PrimaryKeyID()),
+		zap.Int("rotated", rotated))
+	return rotated, nil
+}
@@ -2,7 +2,11 @@ package wechat
 
 import (
 	"bytes"
+	"crypto/aes"
 	"crypto/md5"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -13,6 +17,7 @@ import (
 	"strings"
 
 	"mall-go/internal/model"
+	"mall-go/pkg/currency"
 	"mall-go/pkg/logger"
 	"mall-go/pkg/payment/config"
 
@@ -24,16 +29,44 @@ import (
 type Client struct {
 	config     *config.WechatConfig
 	httpClient *http.Client
+	certClient *http.Client // 携带商户证书的客户端，退款等接口需要双向认证
+}
+
+// PayClient 微信支付客户端接口
+// v2(XML/MD5)与APIv3(JSON/RSA)两套接口均实现该接口，按商户配置选择
+type PayClient interface {
+	CreatePayment(req *PaymentRequest) (*PaymentResponse, error)
+	QueryPayment(outTradeNo string) (*QueryResponse, error)
+	CloseOrder(outTradeNo string) error
+	Refund(req *RefundRequest) (*RefundResponse, error)
+	QueryRefund(outRefundNo string) (*RefundQueryResponse, error)
 }
 
 // NewClient 创建微信支付客户端
 func NewClient(cfg *config.WechatConfig) *Client {
-	return &Client{
+	client := &Client{
 		config: cfg,
 		httpClient: &http.Client{
 			Timeout: cfg.Timeout,
 		},
 	}
+
+	// 加载商户证书，未配置时退款接口不可用
+	if cfg.CertPath != "" && cfg.KeyPath != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertPath, cfg.KeyPath)
+		if err != nil {
+			logger.Warn("加载微信支付商户证书失败，退款功能不可用", zap.Error(err))
+		} else {
+			client.certClient = &http.Client{
+				Timeout: cfg.Timeout,
+				Transport: &http.Transport{
+					TLSClientConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+				},
+			}
+		}
+	}
+
+	return client
 }
 
 // CreatePayment 创建支付
@@ -66,8 +99,22 @@ func (c *Client) CreatePayment(req *PaymentRequest) (*PaymentResponse, error) {
 
 // buildPaymentParams 构建支付参数
 func (c *Client) buildPaymentParams(req *PaymentRequest) map[string]string {
-	// 将金额转换为分
-	totalFee := req.TotalFee.Mul(decimal.NewFromInt(100)).IntPart()
+	// 将金额转换为最小货币单位
+	totalFee := currency.ToMinorUnits(req.TotalFee, req.Currency)
+
+	// v2接口中H5支付的交易类型为MWEB
+	tradeType := req.TradeType
+	switch tradeType {
+	case "":
+		tradeType = TradeTypeNative
+	case TradeTypeH5:
+		tradeType = "MWEB"
+	}
+
+	clientIP := req.ClientIP
+	if clientIP == "" {
+		clientIP = "127.0.0.1"
+	}
 
 	params := map[string]string{
 		"appid":            c.config.AppID,
@@ -76,9 +123,17 @@ func (c *Client) buildPaymentParams(req *PaymentRequest) map[string]string {
 		"body":             req.Body,
 		"out_trade_no":     req.OutTradeNo,
 		"total_fee":        strconv.FormatInt(totalFee, 10),
-		"spbill_create_ip": "127.0.0.1",
+		"spbill_create_ip": clientIP,
 		"notify_url":       req.NotifyURL,
-		"trade_type":       "NATIVE", // 扫码支付
+		"trade_type":       tradeType,
+	}
+
+	if code := currency.Normalize(req.Currency); code != "CNY" {
+		params["fee_type"] = code
+	}
+
+	if req.OpenID != "" {
+		params["openid"] = req.OpenID
 	}
 
 	if req.Detail != "" {
@@ -175,6 +230,7 @@ func (c *Client) parsePaymentResponse(data []byte) (*PaymentResponse, error) {
 		PrepayID   string   `xml:"prepay_id"`
 		TradeType  string   `xml:"trade_type"`
 		CodeURL    string   `xml:"code_url"`
+		MwebURL    string   `xml:"mweb_url"`
 	}
 
 	if err := xml.Unmarshal(data, &response); err != nil {
@@ -193,6 +249,7 @@ func (c *Client) parsePaymentResponse(data []byte) (*PaymentResponse, error) {
 		PrepayID:  response.PrepayID,
 		CodeURL:   response.CodeURL,
 		TradeType: response.TradeType,
+		H5URL:     response.MwebURL,
 		Success:   true,
 	}, nil
 }
@@ -240,6 +297,7 @@ func (c *Client) parseQueryResponse(data []byte) (*QueryResponse, error) {
 		TransactionID string   `xml:"transaction_id"`
 		TradeState    string   `xml:"trade_state"`
 		TotalFee      string   `xml:"total_fee"`
+		FeeType       string   `xml:"fee_type"`
 		TimeEnd       string   `xml:"time_end"`
 	}
 
@@ -251,6 +309,9 @@ func (c *Client) parseQueryResponse(data []byte) (*QueryResponse, error) {
 		return nil, fmt.Errorf("微信支付返回错误: %s", response.ReturnMsg)
 	}
 
+	if response.ErrCode == ErrorCodeOrderNotExist {
+		return nil, model.ErrTradeNotExist
+	}
 	if response.ResultCode != "SUCCESS" {
 		return nil, fmt.Errorf("微信支付业务错误: %s - %s", response.ErrCode, response.ErrCodeDes)
 	}
@@ -276,15 +337,11 @@ func (c *Client) parseQueryResponse(data []byte) (*QueryResponse, error) {
 		status = model.PaymentStatusFailed
 	}
 
-	// 转换金额（分转元）
-	totalFee, _ := strconv.ParseInt(response.TotalFee, 10, 64)
-	totalAmount := decimal.NewFromInt(totalFee).Div(decimal.NewFromInt(100))
-
 	return &QueryResponse{
 		OutTradeNo:    response.OutTradeNo,
 		TransactionID: response.TransactionID,
 		TradeState:    response.TradeState,
-		TotalFee:      totalAmount,
+		TotalFee:      fromMinorUnits(response.TotalFee, response.FeeType),
 		Status:        status,
 		TimeEnd:       response.TimeEnd,
 		Success:       true,
@@ -325,3 +382,339 @@ func (c *Client) ParseCallback(data []byte) (*CallbackData, error) {
 
 	return &callback, nil
 }
+
+// CloseOrder 关闭订单
+// 支付超时后关闭未付款订单，订单已关闭时视为成功
+func (c *Client) CloseOrder(outTradeNo string) error {
+	logger.Info("关闭微信支付订单", zap.String("out_trade_no", outTradeNo))
+
+	params := map[string]string{
+		"appid":        c.config.AppID,
+		"mch_id":       c.config.MchID,
+		"out_trade_no": outTradeNo,
+		"nonce_str":    c.generateNonceStr(),
+	}
+
+	// 签名
+	params["sign"] = c.sign(params)
+
+	// 构建XML请求
+	xmlData, err := c.buildXMLRequest(params)
+	if err != nil {
+		return fmt.Errorf("构建XML请求失败: %v", err)
+	}
+
+	// 发送请求
+	response, err := c.sendRequest(c.config.GatewayURL+"/pay/closeorder", xmlData)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %v", err)
+	}
+
+	return c.parseCloseResponse(response)
+}
+
+// parseCloseResponse 解析关闭订单响应
+func (c *Client) parseCloseResponse(data []byte) error {
+	var response struct {
+		XMLName    xml.Name `xml:"xml"`
+		ReturnCode string   `xml:"return_code"`
+		ReturnMsg  string   `xml:"return_msg"`
+		ResultCode string   `xml:"result_code"`
+		ErrCode    string   `xml:"err_code"`
+		ErrCodeDes string   `xml:"err_code_des"`
+	}
+
+	if err := xml.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+
+	if response.ReturnCode != "SUCCESS" {
+		return fmt.Errorf("微信支付返回错误: %s", response.ReturnMsg)
+	}
+
+	// 订单已关闭或不存在（用户未支付）均视为已关闭
+	if response.ResultCode != "SUCCESS" && response.ErrCode != "ORDERCLOSED" && response.ErrCode != ErrorCodeOrderNotExist {
+		return fmt.Errorf("微信支付业务错误: %s - %s", response.ErrCode, response.ErrCodeDes)
+	}
+
+	return nil
+}
+
+// Refund 申请退款
+// 同一笔退款需使用相同的 OutRefundNo，微信支付据此保证重复请求不会重复退款
+func (c *Client) Refund(req *RefundRequest) (*RefundResponse, error) {
+	logger.Info("申请微信支付退款",
+		zap.String("out_trade_no", req.OutTradeNo),
+		zap.String("out_refund_no", req.OutRefundNo),
+		zap.String("refund_fee", req.RefundFee.String()))
+
+	if c.certClient == nil {
+		return nil, fmt.Errorf("未配置微信支付商户证书")
+	}
+
+	if req.OutRefundNo == "" {
+		return nil, fmt.Errorf("商户退款单号不能为空")
+	}
+
+	params := map[string]string{
+		"appid":         c.config.AppID,
+		"mch_id":        c.config.MchID,
+		"nonce_str":     c.generateNonceStr(),
+		"out_refund_no": req.OutRefundNo,
+		"total_fee":     strconv.FormatInt(currency.ToMinorUnits(req.TotalFee, req.Currency), 10),
+		"refund_fee":    strconv.FormatInt(currency.ToMinorUnits(req.RefundFee, req.Currency), 10),
+	}
+
+	if code := currency.Normalize(req.Currency); code != "CNY" {
+		params["refund_fee_type"] = code
+	}
+
+	if req.TransactionID != "" {
+		params["transaction_id"] = req.TransactionID
+	} else {
+		params["out_trade_no"] = req.OutTradeNo
+	}
+
+	if req.RefundDesc != "" {
+		params["refund_desc"] = req.RefundDesc
+	}
+
+	if req.NotifyURL != "" {
+		params["notify_url"] = req.NotifyURL
+	}
+
+	// 签名
+	params["sign"] = c.sign(params)
+
+	// 构建XML请求
+	xmlData, err := c.buildXMLRequest(params)
+	if err != nil {
+		return nil, fmt.Errorf("构建XML请求失败: %v", err)
+	}
+
+	// 发送请求
+	resp, err := c.certClient.Post(c.config.GatewayURL+"/secapi/pay/refund", "application/xml", bytes.NewReader(xmlData))
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	response, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+
+	// 解析响应
+	return c.parseRefundResponse(response)
+}
+
+// parseRefundResponse 解析退款响应
+func (c *Client) parseRefundResponse(data []byte) (*RefundResponse, error) {
+	var response struct {
+		XMLName             xml.Name `xml:"xml"`
+		ReturnCode          string   `xml:"return_code"`
+		ReturnMsg           string   `xml:"return_msg"`
+		ResultCode          string   `xml:"result_code"`
+		ErrCode             string   `xml:"err_code"`
+		ErrCodeDes          string   `xml:"err_code_des"`
+		TransactionID       string   `xml:"transaction_id"`
+		OutTradeNo          string   `xml:"out_trade_no"`
+		OutRefundNo         string   `xml:"out_refund_no"`
+		RefundID            string   `xml:"refund_id"`
+		RefundFee           string   `xml:"refund_fee"`
+		SettlementRefundFee string   `xml:"settlement_refund_fee"`
+		TotalFee            string   `xml:"total_fee"`
+		SettlementTotalFee  string   `xml:"settlement_total_fee"`
+		FeeType             string   `xml:"fee_type"`
+		CashFee             string   `xml:"cash_fee"`
+		CashRefundFee       string   `xml:"cash_refund_fee"`
+		CashFeeType         string   `xml:"cash_fee_type"`
+	}
+
+	if err := xml.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+
+	if response.ReturnCode != ReturnCodeSuccess {
+		return nil, fmt.Errorf("微信支付返回错误: %s", response.ReturnMsg)
+	}
+
+	if response.ResultCode != ResultCodeSuccess {
+		// 业务失败不返回error，由调用方将退款单标记为失败
+		return &RefundResponse{
+			OutTradeNo: response.OutTradeNo,
+			Status:     model.PaymentStatusFailed,
+			Success:    false,
+			Message:    GetErrorMessage(response.ErrCode, response.ErrCodeDes),
+		}, nil
+	}
+
+	// 申请成功仅表示退款已受理，最终结果以退款通知或退款查询为准
+	return &RefundResponse{
+		OutTradeNo:          response.OutTradeNo,
+		TransactionID:       response.TransactionID,
+		OutRefundNo:         response.OutRefundNo,
+		RefundID:            response.RefundID,
+		RefundFee:           fromMinorUnits(response.RefundFee, response.FeeType),
+		SettlementRefundFee: fromMinorUnits(response.SettlementRefundFee, response.FeeType),
+		TotalFee:            fromMinorUnits(response.TotalFee, response.FeeType),
+		SettlementTotalFee:  fromMinorUnits(response.SettlementTotalFee, response.FeeType),
+		CashFee:             fromMinorUnits(response.CashFee, response.CashFeeType),
+		CashRefundFee:       fromMinorUnits(response.CashRefundFee, response.CashFeeType),
+		Status:              model.PaymentStatusPending,
+		Success:             true,
+	}, nil
+}
+
+// QueryRefund 查询退款
+func (c *Client) QueryRefund(outRefundNo string) (*RefundQueryResponse, error) {
+	logger.Info("查询微信支付退款状态", zap.String("out_refund_no", outRefundNo))
+
+	params := map[string]string{
+		"appid":         c.config.AppID,
+		"mch_id":        c.config.MchID,
+		"nonce_str":     c.generateNonceStr(),
+		"out_refund_no": outRefundNo,
+	}
+
+	// 签名
+	params["sign"] = c.sign(params)
+
+	// 构建XML请求
+	xmlData, err := c.buildXMLRequest(params)
+	if err != nil {
+		return nil, fmt.Errorf("构建XML请求失败: %v", err)
+	}
+
+	// 发送请求
+	response, err := c.sendRequest(c.config.GatewayURL+"/pay/refundquery", xmlData)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %v", err)
+	}
+
+	// 解析响应
+	return c.parseRefundQueryResponse(response)
+}
+
+// parseRefundQueryResponse 解析退款查询响应
+// 按退款单号查询时只返回一笔退款，取下标为0的字段
+func (c *Client) parseRefundQueryResponse(data []byte) (*RefundQueryResponse, error) {
+	var response struct {
+		XMLName           xml.Name `xml:"xml"`
+		ReturnCode        string   `xml:"return_code"`
+		ReturnMsg         string   `xml:"return_msg"`
+		ResultCode        string   `xml:"result_code"`
+		ErrCode           string   `xml:"err_code"`
+		ErrCodeDes        string   `xml:"err_code_des"`
+		TransactionID     string   `xml:"transaction_id"`
+		OutTradeNo        string   `xml:"out_trade_no"`
+		OutRefundNo       string   `xml:"out_refund_no_0"`
+		RefundID          string   `xml:"refund_id_0"`
+		RefundFee         string   `xml:"refund_fee_0"`
+		FeeType           string   `xml:"fee_type"`
+		RefundStatus      string   `xml:"refund_status_0"`
+		RefundSuccessTime string   `xml:"refund_success_time_0"`
+	}
+
+	if err := xml.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+
+	if response.ReturnCode != ReturnCodeSuccess {
+		return nil, fmt.Errorf("微信支付返回错误: %s", response.ReturnMsg)
+	}
+
+	if response.ResultCode != ResultCodeSuccess {
+		return nil, fmt.Errorf("微信支付业务错误: %s - %s", response.ErrCode, response.ErrCodeDes)
+	}
+
+	return &RefundQueryResponse{
+		OutTradeNo:        response.OutTradeNo,
+		TransactionID:     response.TransactionID,
+		OutRefundNo:       response.OutRefundNo,
+		RefundID:          response.RefundID,
+		RefundFee:         fromMinorUnits(response.RefundFee, response.FeeType),
+		RefundStatus:      response.RefundStatus,
+		RefundSuccessTime: response.RefundSuccessTime,
+		Status:            RefundStatusToPaymentStatus(response.RefundStatus),
+		Success:           true,
+	}, nil
+}
+
+// ParseRefundNotify 解析退款通知
+// 退款结果在 req_info 中以 AES-256-ECB 加密，密钥为商户API密钥的MD5小写值
+func (c *Client) ParseRefundNotify(data []byte) (*RefundNotifyData, error) {
+	var notify struct {
+		XMLName    xml.Name `xml:"xml"`
+		ReturnCode string   `xml:"return_code"`
+		ReturnMsg  string   `xml:"return_msg"`
+		AppID      string   `xml:"appid"`
+		MchID      string   `xml:"mch_id"`
+		ReqInfo    string   `xml:"req_info"`
+	}
+
+	if err := xml.Unmarshal(data, &notify); err != nil {
+		return nil, fmt.Errorf("解析退款通知失败: %v", err)
+	}
+
+	if notify.ReturnCode != ReturnCodeSuccess {
+		return nil, fmt.Errorf("退款通知返回失败: %s", notify.ReturnMsg)
+	}
+
+	if notify.MchID != c.config.MchID {
+		return nil, fmt.Errorf("退款通知商户号不匹配")
+	}
+
+	plain, err := c.decryptReqInfo(notify.ReqInfo)
+	if err != nil {
+		return nil, fmt.Errorf("解密退款通知失败: %v", err)
+	}
+
+	var result RefundNotifyData
+	if err := xml.Unmarshal(plain, &result); err != nil {
+		return nil, fmt.Errorf("解析退款通知内容失败: %v", err)
+	}
+
+	return &result, nil
+}
+
+// decryptReqInfo 解密退款通知中的 req_info
+func (c *Client) decryptReqInfo(reqInfo string) ([]byte, error) {
+	cipherText, err := base64.StdEncoding.DecodeString(reqInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	keyHash := md5.Sum([]byte(c.config.APIKey))
+	key := []byte(hex.EncodeToString(keyHash[:]))
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	blockSize := block.BlockSize()
+	if len(cipherText) == 0 || len(cipherText)%blockSize != 0 {
+		return nil, fmt.Errorf("密文长度错误")
+	}
+
+	// ECB模式逐块解密
+	plain := make([]byte, len(cipherText))
+	for i := 0; i < len(cipherText); i += blockSize {
+		block.Decrypt(plain[i:i+blockSize], cipherText[i:i+blockSize])
+	}
+
+	// 去除PKCS7填充
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > blockSize {
+		return nil, fmt.Errorf("填充数据错误")
+	}
+
+	return plain[:len(plain)-padding], nil
+}
+
+// fromMinorUnits 按币种将最小货币单位金额转换为元，未返回币种时按人民币处理
+func fromMinorUnits(value string, code string) decimal.Decimal {
+	minor, _ := strconv.ParseInt(value, 10, 64)
+	return currency.FromMinorUnits(minor, code)
+}
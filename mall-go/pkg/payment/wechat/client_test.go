@@ -0,0 +1,122 @@
+package wechat
+
+import (
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/payment/config"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// encryptReqInfo 按微信退款通知规则加密 req_info
+func encryptReqInfo(t *testing.T, apiKey string, plain []byte) string {
+	keyHash := md5.Sum([]byte(apiKey))
+	block, err := aes.NewCipher([]byte(hex.EncodeToString(keyHash[:])))
+	assert.NoError(t, err)
+
+	padding := block.BlockSize() - len(plain)%block.BlockSize()
+	for i := 0; i < padding; i++ {
+		plain = append(plain, byte(padding))
+	}
+
+	cipherText := make([]byte, len(plain))
+	for i := 0; i < len(plain); i += block.BlockSize() {
+		block.Encrypt(cipherText[i:i+block.BlockSize()], plain[i:i+block.BlockSize()])
+	}
+	return base64.StdEncoding.EncodeToString(cipherText)
+}
+
+func TestClient_ParseRefundNotify(t *testing.T) {
+	client := NewClient(&config.WechatConfig{AppID: "wx123", MchID: "1900000001", APIKey: "test_api_key"})
+
+	reqInfo := encryptReqInfo(t, "test_api_key", []byte(`<root>`+
+		`<out_trade_no><![CDATA[PAY123]]></out_trade_no>`+
+		`<refund_id><![CDATA[50000000382019052709732678859]]></refund_id>`+
+		`<out_refund_no><![CDATA[REF123]]></out_refund_no>`+
+		`<total_fee><![CDATA[1000]]></total_fee>`+
+		`<refund_fee><![CDATA[250]]></refund_fee>`+
+		`<refund_status><![CDATA[SUCCESS]]></refund_status>`+
+		`<success_time><![CDATA[2019-05-27 15:30:00]]></success_time>`+
+		`</root>`))
+
+	t.Run("解密成功", func(t *testing.T) {
+		body := fmt.Sprintf(`<xml><return_code>SUCCESS</return_code><mch_id>1900000001</mch_id><req_info><![CDATA[%s]]></req_info></xml>`, reqInfo)
+		notify, err := client.ParseRefundNotify([]byte(body))
+		assert.NoError(t, err)
+		assert.Equal(t, "REF123", notify.OutRefundNo)
+		assert.Equal(t, "PAY123", notify.OutTradeNo)
+		assert.True(t, notify.GetRefundFee("CNY").Equal(decimal.NewFromFloat(2.5)))
+		assert.True(t, notify.GetRefundFee("JPY").Equal(decimal.NewFromInt(250)))
+		assert.Equal(t, model.PaymentStatusSuccess, notify.ToPaymentStatus())
+
+		refundedAt, err := notify.GetRefundTime()
+		assert.NoError(t, err)
+		assert.NotNil(t, refundedAt)
+	})
+
+	t.Run("商户号不匹配", func(t *testing.T) {
+		body := fmt.Sprintf(`<xml><return_code>SUCCESS</return_code><mch_id>other</mch_id><req_info><![CDATA[%s]]></req_info></xml>`, reqInfo)
+		_, err := client.ParseRefundNotify([]byte(body))
+		assert.Error(t, err)
+	})
+
+	t.Run("密钥错误", func(t *testing.T) {
+		otherClient := NewClient(&config.WechatConfig{MchID: "1900000001", APIKey: "wrong_key"})
+		body := fmt.Sprintf(`<xml><return_code>SUCCESS</return_code><mch_id>1900000001</mch_id><req_info><![CDATA[%s]]></req_info></xml>`, reqInfo)
+		_, err := otherClient.ParseRefundNotify([]byte(body))
+		assert.Error(t, err)
+	})
+}
+
+func TestClient_parseRefundResponse(t *testing.T) {
+	client := NewClient(&config.WechatConfig{MchID: "1900000001", APIKey: "test_api_key"})
+
+	resp, err := client.parseRefundResponse([]byte(`<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code>` +
+		`<out_trade_no>PAY123</out_trade_no><out_refund_no>REF123</out_refund_no><refund_id>5000</refund_id>` +
+		`<refund_fee>250</refund_fee><total_fee>1000</total_fee></xml>`))
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+	assert.Equal(t, model.PaymentStatusPending, resp.Status)
+	assert.True(t, resp.RefundFee.Equal(decimal.NewFromFloat(2.5)))
+
+	resp, err = client.parseRefundResponse([]byte(`<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code>` +
+		`<err_code>NOTENOUGH</err_code><err_code_des></err_code_des></xml>`))
+	assert.NoError(t, err)
+	assert.False(t, resp.Success)
+	assert.Equal(t, model.PaymentStatusFailed, resp.Status)
+	assert.Equal(t, "余额不足", resp.Message)
+}
+
+func TestRefundStatusToPaymentStatus(t *testing.T) {
+	assert.Equal(t, model.PaymentStatusSuccess, RefundStatusToPaymentStatus(RefundStatusSuccess))
+	assert.Equal(t, model.PaymentStatusPending, RefundStatusToPaymentStatus(RefundStatusProcessing))
+	assert.Equal(t, model.PaymentStatusFailed, RefundStatusToPaymentStatus(RefundStatusChange))
+	assert.Equal(t, model.PaymentStatusFailed, RefundStatusToPaymentStatus(RefundStatusRefundClose))
+}
+
+func TestClient_parseQueryResponse_OrderNotExist(t *testing.T) {
+	client := &Client{}
+
+	_, err := client.parseQueryResponse([]byte(`<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>ORDERNOTEXIST</err_code><err_code_des>此交易订单号不存在</err_code_des></xml>`))
+	assert.ErrorIs(t, err, model.ErrTradeNotExist)
+
+	_, err = client.parseQueryResponse([]byte(`<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>SYSTEMERROR</err_code><err_code_des>系统错误</err_code_des></xml>`))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, model.ErrTradeNotExist)
+}
+
+func TestClient_parseCloseResponse(t *testing.T) {
+	client := &Client{}
+
+	assert.NoError(t, client.parseCloseResponse([]byte(`<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code></xml>`)))
+	assert.NoError(t, client.parseCloseResponse([]byte(`<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>ORDERCLOSED</err_code></xml>`)))
+	assert.NoError(t, client.parseCloseResponse([]byte(`<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>ORDERNOTEXIST</err_code></xml>`)))
+	assert.Error(t, client.parseCloseResponse([]byte(`<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>ORDERPAID</err_code><err_code_des>订单已支付</err_code_des></xml>`)))
+}
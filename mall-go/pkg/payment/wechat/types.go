@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"mall-go/internal/model"
+	"mall-go/pkg/currency"
 
 	"github.com/shopspring/decimal"
 )
@@ -18,8 +19,12 @@ type PaymentRequest struct {
 	Attach     string          `json:"attach"`       // 附加数据
 	OutTradeNo string          `json:"out_trade_no"` // 商户订单号
 	TotalFee   decimal.Decimal `json:"total_fee"`    // 订单总金额(元)
+	Currency   string          `json:"currency"`     // 标价币种，默认CNY
 	NotifyURL  string          `json:"notify_url"`   // 异步通知地址
 	TimeExpire *time.Time      `json:"time_expire"`  // 订单过期时间
+	TradeType  string          `json:"trade_type"`   // 交易类型: JSAPI, NATIVE, H5, APP，默认NATIVE
+	OpenID     string          `json:"openid"`       // 用户标识，JSAPI支付必填
+	ClientIP   string          `json:"client_ip"`    // 用户终端IP，H5支付必填
 }
 
 // PaymentResponse 支付响应
@@ -27,8 +32,12 @@ type PaymentResponse struct {
 	PrepayID  string `json:"prepay_id"`  // 预支付交易会话标识
 	CodeURL   string `json:"code_url"`   // 二维码链接
 	TradeType string `json:"trade_type"` // 交易类型
+	H5URL     string `json:"h5_url"`     // H5支付跳转链接
 	Success   bool   `json:"success"`    // 是否成功
 	Message   string `json:"message"`    // 错误信息
+
+	// PayParams 客户端调起支付所需参数（JSAPI、APP），已使用商户私钥签名
+	PayParams map[string]string `json:"pay_params,omitempty"`
 }
 
 // QueryResponse 查询响应
@@ -90,24 +99,109 @@ type RefundRequest struct {
 	OutRefundNo   string          `json:"out_refund_no"`  // 商户退款单号
 	TotalFee      decimal.Decimal `json:"total_fee"`      // 订单金额
 	RefundFee     decimal.Decimal `json:"refund_fee"`     // 退款金额
+	Currency      string          `json:"currency"`       // 币种，与支付币种一致
 	RefundDesc    string          `json:"refund_desc"`    // 退款原因
 	NotifyURL     string          `json:"notify_url"`     // 退款通知地址
 }
 
+// 分账接收方类型
+const (
+	ProfitSharingReceiverMerchant = "MERCHANT_ID"     // 商户号
+	ProfitSharingReceiverOpenID   = "PERSONAL_OPENID" // 个人openid
+)
+
+// ProfitSharingReceiver 分账接收方
+type ProfitSharingReceiver struct {
+	Type        string          `json:"type"`        // 接收方类型
+	Account     string          `json:"account"`     // 接收方账号
+	Amount      decimal.Decimal `json:"amount"`      // 分账金额
+	Description string          `json:"description"` // 分账描述
+}
+
+// ProfitSharingRequest 请求分账
+type ProfitSharingRequest struct {
+	TransactionID   string                  `json:"transaction_id"`   // 微信订单号
+	OutOrderNo      string                  `json:"out_order_no"`     // 商户分账单号，重复请求幂等
+	Receivers       []ProfitSharingReceiver `json:"receivers"`        // 分账接收方
+	UnfreezeUnsplit bool                    `json:"unfreeze_unsplit"` // 是否解冻剩余未分资金
+}
+
+// ProfitSharingResponse 分账结果
+type ProfitSharingResponse struct {
+	OrderID    string `json:"order_id"`     // 微信分账单号
+	OutOrderNo string `json:"out_order_no"` // 商户分账单号
+	State      string `json:"state"`        // 分账单状态 PROCESSING/FINISHED
+}
+
+// ComplaintNotify 消费者投诉通知（解密后的内容），投诉详情需另行查询
+type ComplaintNotify struct {
+	ComplaintID string `json:"complaint_id"` // 投诉单号
+	ActionType  string `json:"action_type"`  // 动作类型，如 CREATE_COMPLAINT
+}
+
+// ComplaintOrder 投诉关联的订单
+type ComplaintOrder struct {
+	TransactionID string          `json:"transaction_id"` // 微信订单号
+	OutTradeNo    string          `json:"out_trade_no"`   // 商户订单号
+	Amount        decimal.Decimal `json:"amount"`         // 订单金额
+}
+
+// Complaint 消费者投诉详情
+type Complaint struct {
+	ComplaintID       string           `json:"complaint_id"`        // 投诉单号
+	ComplaintTime     *time.Time       `json:"complaint_time"`      // 投诉时间
+	ComplaintDetail   string           `json:"complaint_detail"`    // 投诉详情
+	ComplaintState    string           `json:"complaint_state"`     // 投诉状态 PENDING/PROCESSING/PROCESSED
+	ProblemType       string           `json:"problem_type"`        // 问题类型，如 REFUND
+	ApplyRefundAmount decimal.Decimal  `json:"apply_refund_amount"` // 用户申请退款金额
+	Orders            []ComplaintOrder `json:"orders"`              // 投诉关联订单
+}
+
 // RefundResponse 退款响应
 type RefundResponse struct {
-	OutTradeNo          string          `json:"out_trade_no"`          // 商户订单号
-	TransactionID       string          `json:"transaction_id"`        // 微信订单号
-	OutRefundNo         string          `json:"out_refund_no"`         // 商户退款单号
-	RefundID            string          `json:"refund_id"`             // 微信退款单号
-	RefundFee           decimal.Decimal `json:"refund_fee"`            // 退款金额
-	SettlementRefundFee decimal.Decimal `json:"settlement_refund_fee"` // 应结退款金额
-	TotalFee            decimal.Decimal `json:"total_fee"`             // 订单金额
-	SettlementTotalFee  decimal.Decimal `json:"settlement_total_fee"`  // 应结订单金额
-	CashFee             decimal.Decimal `json:"cash_fee"`              // 现金支付金额
-	CashRefundFee       decimal.Decimal `json:"cash_refund_fee"`       // 现金退款金额
-	Success             bool            `json:"success"`               // 是否成功
-	Message             string          `json:"message"`               // 错误信息
+	OutTradeNo          string              `json:"out_trade_no"`          // 商户订单号
+	TransactionID       string              `json:"transaction_id"`        // 微信订单号
+	OutRefundNo         string              `json:"out_refund_no"`         // 商户退款单号
+	RefundID            string              `json:"refund_id"`             // 微信退款单号
+	RefundFee           decimal.Decimal     `json:"refund_fee"`            // 退款金额
+	SettlementRefundFee decimal.Decimal     `json:"settlement_refund_fee"` // 应结退款金额
+	TotalFee            decimal.Decimal     `json:"total_fee"`             // 订单金额
+	SettlementTotalFee  decimal.Decimal     `json:"settlement_total_fee"`  // 应结订单金额
+	CashFee             decimal.Decimal     `json:"cash_fee"`              // 现金支付金额
+	CashRefundFee       decimal.Decimal     `json:"cash_refund_fee"`       // 现金退款金额
+	Status              model.PaymentStatus `json:"status"`                // 标准化退款状态
+	Success             bool                `json:"success"`               // 是否成功
+	Message             string              `json:"message"`               // 错误信息
+}
+
+// RefundQueryResponse 退款查询响应
+type RefundQueryResponse struct {
+	OutTradeNo        string              `json:"out_trade_no"`        // 商户订单号
+	TransactionID     string              `json:"transaction_id"`      // 微信订单号
+	OutRefundNo       string              `json:"out_refund_no"`       // 商户退款单号
+	RefundID          string              `json:"refund_id"`           // 微信退款单号
+	RefundFee         decimal.Decimal     `json:"refund_fee"`          // 退款金额
+	RefundStatus      string              `json:"refund_status"`       // 退款状态
+	RefundSuccessTime string              `json:"refund_success_time"` // 退款成功时间
+	Status            model.PaymentStatus `json:"status"`              // 标准化退款状态
+	Success           bool                `json:"success"`             // 是否成功
+	Message           string              `json:"message"`             // 错误信息
+}
+
+// RefundNotifyData 退款通知数据（req_info 解密后的内容）
+type RefundNotifyData struct {
+	XMLName xml.Name `xml:"root"`
+
+	TransactionID       string `xml:"transaction_id"`        // 微信订单号
+	OutTradeNo          string `xml:"out_trade_no"`          // 商户订单号
+	RefundID            string `xml:"refund_id"`             // 微信退款单号
+	OutRefundNo         string `xml:"out_refund_no"`         // 商户退款单号
+	TotalFee            string `xml:"total_fee"`             // 订单金额(分)
+	RefundFee           string `xml:"refund_fee"`            // 申请退款金额(分)
+	SettlementRefundFee string `xml:"settlement_refund_fee"` // 退款金额(分)
+	RefundStatus        string `xml:"refund_status"`         // 退款状态
+	SuccessTime         string `xml:"success_time"`          // 退款成功时间
+	RefundRecvAccout    string `xml:"refund_recv_accout"`    // 退款入账账户
 }
 
 // TradeState 交易状态常量
@@ -121,6 +215,24 @@ const (
 	TradeStatePayError   = "PAYERROR"   // 支付失败
 )
 
+// TradeType 交易类型常量
+const (
+	TradeTypeJSAPI  = "JSAPI"  // 公众号/小程序支付
+	TradeTypeNative = "NATIVE" // 扫码支付
+	TradeTypeH5     = "H5"     // H5支付（v2接口中为MWEB）
+	TradeTypeApp    = "APP"    // APP支付
+)
+
+// RefundStatus 退款状态常量
+const (
+	RefundStatusSuccess     = "SUCCESS"     // 退款成功
+	RefundStatusChange      = "CHANGE"      // 退款异常
+	RefundStatusRefundClose = "REFUNDCLOSE" // 退款关闭
+	RefundStatusProcessing  = "PROCESSING"  // 退款处理中
+	RefundStatusClosed      = "CLOSED"      // 退款关闭（APIv3）
+	RefundStatusAbnormal    = "ABNORMAL"    // 退款异常（APIv3）
+)
+
 // ReturnCode 返回状态码常量
 const (
 	ReturnCodeSuccess = "SUCCESS" // 成功
@@ -166,10 +278,10 @@ func (cd *CallbackData) IsPaymentSuccess() bool {
 	return cd.ReturnCode == ReturnCodeSuccess && cd.ResultCode == ResultCodeSuccess
 }
 
-// GetTotalAmount 获取订单金额（转换为元）
-func (cd *CallbackData) GetTotalAmount() decimal.Decimal {
+// GetTotalAmount 获取订单金额，按币种小数位数由最小货币单位换算
+func (cd *CallbackData) GetTotalAmount(code string) decimal.Decimal {
 	totalFee, _ := strconv.ParseInt(cd.TotalFee, 10, 64)
-	return decimal.NewFromInt(totalFee).Div(decimal.NewFromInt(100))
+	return currency.FromMinorUnits(totalFee, code)
 }
 
 // GetPaymentTime 获取支付时间
@@ -224,7 +336,7 @@ func (cd *CallbackData) ToPaymentCallbackData() *model.PaymentCallbackData {
 		PaymentMethod: model.PaymentMethodWechat,
 		ThirdPartyID:  cd.TransactionID,
 		PaymentNo:     cd.OutTradeNo,
-		Amount:        cd.GetTotalAmount(),
+		Amount:        cd.GetTotalAmount(cd.FeeType),
 		PaymentStatus: cd.ToPaymentStatus(),
 		PaidAt:        *paidAt,
 		RawData:       "", // 需要在调用时设置
@@ -232,6 +344,62 @@ func (cd *CallbackData) ToPaymentCallbackData() *model.PaymentCallbackData {
 	}
 }
 
+// TradeStateToPaymentStatus 转换交易状态为标准支付状态
+func TradeStateToPaymentStatus(tradeState string) model.PaymentStatus {
+	switch tradeState {
+	case TradeStateSuccess:
+		return model.PaymentStatusSuccess
+	case TradeStateRefund:
+		return model.PaymentStatusRefunded
+	case TradeStateNotPay:
+		return model.PaymentStatusPending
+	case TradeStateClosed, TradeStateRevoked:
+		return model.PaymentStatusCancelled
+	case TradeStateUserPaying:
+		return model.PaymentStatusPaying
+	default:
+		return model.PaymentStatusFailed
+	}
+}
+
+// RefundStatusToPaymentStatus 转换退款状态为标准状态
+func RefundStatusToPaymentStatus(refundStatus string) model.PaymentStatus {
+	switch refundStatus {
+	case RefundStatusSuccess:
+		return model.PaymentStatusSuccess
+	case RefundStatusChange, RefundStatusRefundClose, RefundStatusClosed, RefundStatusAbnormal:
+		return model.PaymentStatusFailed
+	default:
+		return model.PaymentStatusPending
+	}
+}
+
+// ToPaymentStatus 转换为标准退款状态
+func (rn *RefundNotifyData) ToPaymentStatus() model.PaymentStatus {
+	return RefundStatusToPaymentStatus(rn.RefundStatus)
+}
+
+// GetRefundFee 获取退款金额，退款通知不携带币种，由调用方传入原支付币种
+func (rn *RefundNotifyData) GetRefundFee(code string) decimal.Decimal {
+	refundFee, _ := strconv.ParseInt(rn.RefundFee, 10, 64)
+	return currency.FromMinorUnits(refundFee, code)
+}
+
+// GetRefundTime 获取退款成功时间
+func (rn *RefundNotifyData) GetRefundTime() (*time.Time, error) {
+	if rn.SuccessTime == "" {
+		return nil, nil
+	}
+
+	// 解析时间格式: 2006-01-02 15:04:05
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", rn.SuccessTime, time.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
 // ErrorCode 错误码常量
 const (
 	ErrorCodeOrderNotExist   = "ORDERNOTEXIST"   // 订单不存在
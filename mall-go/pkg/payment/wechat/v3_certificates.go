@@ -0,0 +1,183 @@
+package wechat
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"sync"
+	"time"
+
+	"mall-go/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// 平台证书刷新相关默认值
+const (
+	defaultCertRefreshInterval = 12 * time.Hour
+	minCertForceRefreshGap     = time.Minute // 遇到未知序列号时强制刷新的最小间隔，防止被伪造请求刷爆
+)
+
+// CertificateFetcher 平台证书下载函数，返回以序列号为键的证书集合
+type CertificateFetcher func() (map[string]*x509.Certificate, error)
+
+// CertificateManager 微信支付平台证书管理器
+// 证书按刷新间隔自动更新，遇到未知序列号时立即刷新以应对平台证书轮换
+type CertificateManager struct {
+	mu              sync.RWMutex
+	refreshMu       sync.Mutex
+	certs           map[string]*x509.Certificate
+	lastRefresh     time.Time
+	refreshInterval time.Duration
+	fetch           CertificateFetcher
+}
+
+// NewCertificateManager 创建平台证书管理器
+func NewCertificateManager(refreshInterval time.Duration, fetch CertificateFetcher) *CertificateManager {
+	if refreshInterval <= 0 {
+		refreshInterval = defaultCertRefreshInterval
+	}
+
+	return &CertificateManager{
+		certs:           make(map[string]*x509.Certificate),
+		refreshInterval: refreshInterval,
+		fetch:           fetch,
+	}
+}
+
+// Get 根据序列号获取平台证书
+func (m *CertificateManager) Get(serialNo string) (*x509.Certificate, error) {
+	m.mu.RLock()
+	cert, ok := m.certs[serialNo]
+	sinceRefresh := time.Since(m.lastRefresh)
+	neverRefreshed := m.lastRefresh.IsZero()
+	m.mu.RUnlock()
+
+	if ok && sinceRefresh < m.refreshInterval {
+		return cert, nil
+	}
+
+	if !ok && !neverRefreshed && sinceRefresh < minCertForceRefreshGap {
+		return nil, fmt.Errorf("未知的平台证书序列号: %s", serialNo)
+	}
+
+	if err := m.Refresh(); err != nil {
+		// 刷新失败时继续使用未过期的缓存证书
+		if ok && time.Now().Before(cert.NotAfter) {
+			logger.Warn("刷新微信支付平台证书失败，继续使用缓存证书", zap.Error(err))
+			return cert, nil
+		}
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if cert, ok := m.certs[serialNo]; ok {
+		return cert, nil
+	}
+	return nil, fmt.Errorf("未知的平台证书序列号: %s", serialNo)
+}
+
+// Refresh 重新下载平台证书
+func (m *CertificateManager) Refresh() error {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	certs, err := m.fetch()
+	if err != nil {
+		return fmt.Errorf("下载平台证书失败: %v", err)
+	}
+
+	// 丢弃已过期的证书
+	now := time.Now()
+	for serialNo, cert := range certs {
+		if now.After(cert.NotAfter) {
+			delete(certs, serialNo)
+		}
+	}
+
+	if len(certs) == 0 {
+		return fmt.Errorf("没有可用的平台证书")
+	}
+
+	m.mu.Lock()
+	m.certs = certs
+	m.lastRefresh = now
+	m.mu.Unlock()
+
+	logger.Info("微信支付平台证书已更新", zap.Int("count", len(certs)))
+	return nil
+}
+
+// Add 手动添加平台证书，用于预置本地证书
+func (m *CertificateManager) Add(cert *x509.Certificate) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.certs[CertificateSerialNo(cert)] = cert
+}
+
+// CertificateSerialNo 获取证书序列号（大写十六进制，与微信支付平台一致）
+func CertificateSerialNo(cert *x509.Certificate) string {
+	return fmt.Sprintf("%X", cert.SerialNumber)
+}
+
+// DecryptAESGCM 使用APIv3密钥解密 AEAD_AES_256_GCM 加密的数据
+// 平台证书和回调通知的 resource 均使用该方式加密
+func DecryptAESGCM(apiV3Key, nonce, associatedData, ciphertext string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("密文解码失败: %v", err)
+	}
+
+	block, err := aes.NewCipher([]byte(apiV3Key))
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("随机串长度错误")
+	}
+
+	plain, err := gcm.Open(nil, []byte(nonce), data, []byte(associatedData))
+	if err != nil {
+		return nil, fmt.Errorf("解密失败: %v", err)
+	}
+
+	return plain, nil
+}
+
+// ParseCertificate 解析PEM格式证书
+func ParseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("证书格式错误")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// ParsePrivateKey 解析PEM格式商户私钥，支持PKCS#8和PKCS#1
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("私钥格式错误")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("私钥不是RSA类型")
+		}
+		return rsaKey, nil
+	}
+
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
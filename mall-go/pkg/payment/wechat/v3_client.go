@@ -0,0 +1,736 @@
+package wechat
+
+import (
+	"bytes"
+	"crypto"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/currency"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/payment/config"
+
+	"go.uber.org/zap"
+)
+
+// 应答和通知的时间戳与本地时间相差超过该值时拒绝处理，防止重放
+const v3SignatureTolerance = 5 * time.Minute
+
+var (
+	_ PayClient = (*Client)(nil)
+	_ PayClient = (*ClientV3)(nil)
+)
+
+// ClientV3 微信支付APIv3客户端
+// 请求使用商户私钥签名（SHA256-RSA2048），应答和通知使用平台证书验签
+type ClientV3 struct {
+	config      *config.WechatConfig
+	httpClient  *http.Client
+	privateKey  *rsa.PrivateKey
+	certManager *CertificateManager
+}
+
+// NewClientV3 创建微信支付APIv3客户端
+func NewClientV3(cfg *config.WechatConfig) (*ClientV3, error) {
+	if len(cfg.APIv3Key) != 32 {
+		return nil, fmt.Errorf("APIv3密钥长度必须为32位")
+	}
+
+	if cfg.SerialNo == "" {
+		return nil, fmt.Errorf("商户证书序列号不能为空")
+	}
+
+	keyData := []byte(cfg.PrivateKey)
+	if len(keyData) == 0 {
+		data, err := os.ReadFile(cfg.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取商户私钥失败: %v", err)
+		}
+		keyData = data
+	}
+
+	privateKey, err := ParsePrivateKey(keyData)
+	if err != nil {
+		return nil, fmt.Errorf("解析商户私钥失败: %v", err)
+	}
+
+	client := &ClientV3{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+		privateKey: privateKey,
+	}
+	client.certManager = NewCertificateManager(cfg.CertRefreshTime, client.downloadCertificates)
+
+	return client, nil
+}
+
+// CertificateManager 获取平台证书管理器
+func (c *ClientV3) CertificateManager() *CertificateManager {
+	return c.certManager
+}
+
+// CreatePayment 创建支付（预下单），根据交易类型调用JSAPI、Native、H5或APP下单接口
+func (c *ClientV3) CreatePayment(req *PaymentRequest) (*PaymentResponse, error) {
+	tradeType := req.TradeType
+	if tradeType == "" {
+		tradeType = TradeTypeNative
+	}
+
+	logger.Info("创建微信支付(APIv3)",
+		zap.String("out_trade_no", req.OutTradeNo),
+		zap.String("trade_type", tradeType),
+		zap.String("total_fee", req.TotalFee.String()))
+
+	body := &v3PrepayRequest{
+		AppID:       c.config.AppID,
+		MchID:       c.config.MchID,
+		Description: req.Body,
+		OutTradeNo:  req.OutTradeNo,
+		Attach:      req.Attach,
+		NotifyURL:   req.NotifyURL,
+		Amount:      v3Amount{Total: currency.ToMinorUnits(req.TotalFee, req.Currency), Currency: currency.Normalize(req.Currency)},
+	}
+
+	if req.TimeExpire != nil {
+		body.TimeExpire = req.TimeExpire.Format(time.RFC3339)
+	}
+
+	switch tradeType {
+	case TradeTypeJSAPI:
+		if req.OpenID == "" {
+			return nil, fmt.Errorf("JSAPI支付用户openid不能为空")
+		}
+		body.Payer = &v3Payer{OpenID: req.OpenID}
+	case TradeTypeH5:
+		if req.ClientIP == "" {
+			return nil, fmt.Errorf("H5支付用户终端IP不能为空")
+		}
+		body.SceneInfo = &v3SceneInfo{
+			PayerClientIP: req.ClientIP,
+			H5Info:        &v3H5Info{Type: "Wap"},
+		}
+	case TradeTypeNative, TradeTypeApp:
+	default:
+		return nil, fmt.Errorf("不支持的交易类型: %s", tradeType)
+	}
+
+	var resp v3PrepayResponse
+	if err := c.doRequest(http.MethodPost, "/v3/pay/transactions/"+strings.ToLower(tradeType), body, &resp); err != nil {
+		return nil, err
+	}
+
+	result := &PaymentResponse{
+		PrepayID:  resp.PrepayID,
+		CodeURL:   resp.CodeURL,
+		H5URL:     resp.H5URL,
+		TradeType: tradeType,
+		Success:   true,
+	}
+
+	var err error
+	switch tradeType {
+	case TradeTypeJSAPI:
+		result.PayParams, err = c.buildJSAPIPayParams(resp.PrepayID)
+	case TradeTypeApp:
+		result.PayParams, err = c.buildAppPayParams(resp.PrepayID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("生成调起支付参数失败: %v", err)
+	}
+
+	return result, nil
+}
+
+// buildJSAPIPayParams 生成JSAPI调起支付参数
+func (c *ClientV3) buildJSAPIPayParams(prepayID string) (map[string]string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonceStr := c.generateNonceStr()
+	pkg := "prepay_id=" + prepayID
+
+	paySign, err := c.sign(buildSignMessage(c.config.AppID, timestamp, nonceStr, pkg))
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"appId":     c.config.AppID,
+		"timeStamp": timestamp,
+		"nonceStr":  nonceStr,
+		"package":   pkg,
+		"signType":  "RSA",
+		"paySign":   paySign,
+	}, nil
+}
+
+// buildAppPayParams 生成APP调起支付参数
+func (c *ClientV3) buildAppPayParams(prepayID string) (map[string]string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonceStr := c.generateNonceStr()
+
+	sign, err := c.sign(buildSignMessage(c.config.AppID, timestamp, nonceStr, prepayID))
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"appid":     c.config.AppID,
+		"partnerid": c.config.MchID,
+		"prepayid":  prepayID,
+		"package":   "Sign=WXPay",
+		"noncestr":  nonceStr,
+		"timestamp": timestamp,
+		"sign":      sign,
+	}, nil
+}
+
+// QueryPayment 查询支付
+func (c *ClientV3) QueryPayment(outTradeNo string) (*QueryResponse, error) {
+	logger.Info("查询微信支付状态(APIv3)", zap.String("out_trade_no", outTradeNo))
+
+	path := fmt.Sprintf("/v3/pay/transactions/out-trade-no/%s?mchid=%s",
+		url.PathEscape(outTradeNo), url.QueryEscape(c.config.MchID))
+
+	var resp v3Transaction
+	if err := c.doRequest(http.MethodGet, path, nil, &resp); err != nil {
+		if apiErr, ok := err.(*APIError); ok && apiErr.IsOrderNotExist() {
+			return nil, model.ErrTradeNotExist
+		}
+		return nil, err
+	}
+
+	return &QueryResponse{
+		OutTradeNo:    resp.OutTradeNo,
+		TransactionID: resp.TransactionID,
+		TradeState:    resp.TradeState,
+		TotalFee:      currency.FromMinorUnits(resp.Amount.Total, resp.Amount.Currency),
+		Status:        TradeStateToPaymentStatus(resp.TradeState),
+		TimeEnd:       formatV3Time(resp.SuccessTime, "20060102150405"),
+		Success:       true,
+	}, nil
+}
+
+// CloseOrder 关闭订单
+// 关单成功时微信支付返回204且无应答体
+func (c *ClientV3) CloseOrder(outTradeNo string) error {
+	logger.Info("关闭微信支付订单(APIv3)", zap.String("out_trade_no", outTradeNo))
+
+	path := fmt.Sprintf("/v3/pay/transactions/out-trade-no/%s/close", url.PathEscape(outTradeNo))
+	body := map[string]string{
+		"mchid": c.config.MchID,
+	}
+
+	// 订单不存在（用户未支付）视为已关闭
+	if err := c.doRequest(http.MethodPost, path, body, nil); err != nil {
+		if apiErr, ok := err.(*APIError); ok && apiErr.IsOrderNotExist() {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Refund 申请退款
+// 同一笔退款需使用相同的 OutRefundNo，微信支付据此保证重复请求不会重复退款
+func (c *ClientV3) Refund(req *RefundRequest) (*RefundResponse, error) {
+	logger.Info("申请微信支付退款(APIv3)",
+		zap.String("out_trade_no", req.OutTradeNo),
+		zap.String("out_refund_no", req.OutRefundNo),
+		zap.String("refund_fee", req.RefundFee.String()))
+
+	if req.OutRefundNo == "" {
+		return nil, fmt.Errorf("商户退款单号不能为空")
+	}
+
+	body := &v3RefundRequest{
+		OutRefundNo: req.OutRefundNo,
+		Reason:      req.RefundDesc,
+		NotifyURL:   req.NotifyURL,
+		Amount: v3Amount{
+			Refund:   currency.ToMinorUnits(req.RefundFee, req.Currency),
+			Total:    currency.ToMinorUnits(req.TotalFee, req.Currency),
+			Currency: currency.Normalize(req.Currency),
+		},
+	}
+
+	if req.TransactionID != "" {
+		body.TransactionID = req.TransactionID
+	} else {
+		body.OutTradeNo = req.OutTradeNo
+	}
+
+	var resp v3Refund
+	if err := c.doRequest(http.MethodPost, "/v3/refund/domestic/refunds", body, &resp); err != nil {
+		// 业务失败不返回error，由调用方将退款单标记为失败
+		if apiErr, ok := err.(*APIError); ok && apiErr.IsBusinessError() {
+			return &RefundResponse{
+				OutTradeNo: req.OutTradeNo,
+				Status:     model.PaymentStatusFailed,
+				Success:    false,
+				Message:    apiErr.Message,
+			}, nil
+		}
+		return nil, err
+	}
+
+	return &RefundResponse{
+		OutTradeNo:    resp.OutTradeNo,
+		TransactionID: resp.TransactionID,
+		OutRefundNo:   resp.OutRefundNo,
+		RefundID:      resp.RefundID,
+		RefundFee:     currency.FromMinorUnits(resp.Amount.Refund, resp.Amount.Currency),
+		TotalFee:      currency.FromMinorUnits(resp.Amount.Total, resp.Amount.Currency),
+		CashFee:       currency.FromMinorUnits(resp.Amount.PayerTotal, resp.Amount.Currency),
+		CashRefundFee: currency.FromMinorUnits(resp.Amount.PayerRefund, resp.Amount.Currency),
+		Status:        RefundStatusToPaymentStatus(resp.Status),
+		Success:       true,
+	}, nil
+}
+
+// ProfitSharing 请求分账
+// 分账接收方需预先在商户平台添加，同一 OutOrderNo 重复请求不会重复分账
+func (c *ClientV3) ProfitSharing(req *ProfitSharingRequest) (*ProfitSharingResponse, error) {
+	logger.Info("请求微信支付分账(APIv3)",
+		zap.String("transaction_id", req.TransactionID),
+		zap.String("out_order_no", req.OutOrderNo))
+
+	if req.TransactionID == "" || req.OutOrderNo == "" || len(req.Receivers) == 0 {
+		return nil, fmt.Errorf("微信订单号、分账单号和接收方不能为空")
+	}
+
+	body := &v3ProfitSharingRequest{
+		AppID:           c.config.AppID,
+		TransactionID:   req.TransactionID,
+		OutOrderNo:      req.OutOrderNo,
+		UnfreezeUnsplit: req.UnfreezeUnsplit,
+	}
+	for _, receiver := range req.Receivers {
+		body.Receivers = append(body.Receivers, v3ProfitSharingReceiver{
+			Type:        receiver.Type,
+			Account:     receiver.Account,
+			Amount:      currency.ToMinorUnits(receiver.Amount, model.BaseCurrency),
+			Description: receiver.Description,
+		})
+	}
+
+	var resp v3ProfitSharingOrder
+	if err := c.doRequest(http.MethodPost, "/v3/profitsharing/orders", body, &resp); err != nil {
+		return nil, err
+	}
+
+	return &ProfitSharingResponse{
+		OrderID:    resp.OrderID,
+		OutOrderNo: resp.OutOrderNo,
+		State:      resp.State,
+	}, nil
+}
+
+// QueryRefund 查询退款
+func (c *ClientV3) QueryRefund(outRefundNo string) (*RefundQueryResponse, error) {
+	logger.Info("查询微信支付退款状态(APIv3)", zap.String("out_refund_no", outRefundNo))
+
+	var resp v3Refund
+	if err := c.doRequest(http.MethodGet, "/v3/refund/domestic/refunds/"+url.PathEscape(outRefundNo), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return &RefundQueryResponse{
+		OutTradeNo:        resp.OutTradeNo,
+		TransactionID:     resp.TransactionID,
+		OutRefundNo:       resp.OutRefundNo,
+		RefundID:          resp.RefundID,
+		RefundFee:         currency.FromMinorUnits(resp.Amount.Refund, resp.Amount.Currency),
+		RefundStatus:      resp.Status,
+		RefundSuccessTime: formatV3Time(resp.SuccessTime, "2006-01-02 15:04:05"),
+		Status:            RefundStatusToPaymentStatus(resp.Status),
+		Success:           true,
+	}, nil
+}
+
+// ParseNotify 验签并解密支付结果通知，转换为统一的回调数据
+func (c *ClientV3) ParseNotify(header http.Header, body []byte) (*CallbackData, error) {
+	return c.parseNotify(header, body, true)
+}
+
+// ParseReplayedNotify 验签并解密人工重放的支付结果通知
+// 重放的是本地保存的通知原文，仍需验证签名，但不再校验签名时间戳是否过期
+func (c *ClientV3) ParseReplayedNotify(header http.Header, body []byte) (*CallbackData, error) {
+	return c.parseNotify(header, body, false)
+}
+
+// parseNotify 验签并解密支付结果通知
+func (c *ClientV3) parseNotify(header http.Header, body []byte, checkTimestamp bool) (*CallbackData, error) {
+	plain, eventType, err := c.decodeNotify(header, body, checkTimestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	if eventType != EventTransactionSuccess {
+		return nil, fmt.Errorf("不支持的通知类型: %s", eventType)
+	}
+
+	var transaction v3Transaction
+	if err := json.Unmarshal(plain, &transaction); err != nil {
+		return nil, fmt.Errorf("解析支付通知内容失败: %v", err)
+	}
+
+	if transaction.MchID != c.config.MchID {
+		return nil, fmt.Errorf("支付通知商户号不匹配")
+	}
+
+	return transaction.toCallbackData(), nil
+}
+
+// ParseRefundNotify 验签并解密退款结果通知
+func (c *ClientV3) ParseRefundNotify(header http.Header, body []byte) (*RefundNotifyData, error) {
+	return c.parseRefundNotify(header, body, true)
+}
+
+// ParseReplayedRefundNotify 验签并解密人工重放的退款结果通知，不校验签名时间戳是否过期
+func (c *ClientV3) ParseReplayedRefundNotify(header http.Header, body []byte) (*RefundNotifyData, error) {
+	return c.parseRefundNotify(header, body, false)
+}
+
+// parseRefundNotify 验签并解密退款结果通知
+func (c *ClientV3) parseRefundNotify(header http.Header, body []byte, checkTimestamp bool) (*RefundNotifyData, error) {
+	plain, eventType, err := c.decodeNotify(header, body, checkTimestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	switch eventType {
+	case EventRefundSuccess, EventRefundAbnormal, EventRefundClosed:
+	default:
+		return nil, fmt.Errorf("不支持的通知类型: %s", eventType)
+	}
+
+	var refund v3Refund
+	if err := json.Unmarshal(plain, &refund); err != nil {
+		return nil, fmt.Errorf("解析退款通知内容失败: %v", err)
+	}
+
+	if refund.MchID != c.config.MchID {
+		return nil, fmt.Errorf("退款通知商户号不匹配")
+	}
+
+	return &RefundNotifyData{
+		TransactionID:       refund.TransactionID,
+		OutTradeNo:          refund.OutTradeNo,
+		RefundID:            refund.RefundID,
+		OutRefundNo:         refund.OutRefundNo,
+		TotalFee:            strconv.FormatInt(refund.Amount.Total, 10),
+		RefundFee:           strconv.FormatInt(refund.Amount.Refund, 10),
+		SettlementRefundFee: strconv.FormatInt(refund.Amount.PayerRefund, 10),
+		RefundStatus:        refund.RefundStatus,
+		SuccessTime:         formatV3Time(refund.SuccessTime, "2006-01-02 15:04:05"),
+		RefundRecvAccout:    refund.UserReceivedAccount,
+	}, nil
+}
+
+// ParseComplaintNotify 验签并解密消费者投诉通知
+func (c *ClientV3) ParseComplaintNotify(header http.Header, body []byte) (*ComplaintNotify, error) {
+	return c.parseComplaintNotify(header, body, true)
+}
+
+// ParseReplayedComplaintNotify 验签并解密人工重放的消费者投诉通知，不校验签名时间戳是否过期
+func (c *ClientV3) ParseReplayedComplaintNotify(header http.Header, body []byte) (*ComplaintNotify, error) {
+	return c.parseComplaintNotify(header, body, false)
+}
+
+// parseComplaintNotify 验签并解密消费者投诉通知
+func (c *ClientV3) parseComplaintNotify(header http.Header, body []byte, checkTimestamp bool) (*ComplaintNotify, error) {
+	plain, eventType, err := c.decodeNotify(header, body, checkTimestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(eventType, ComplaintEventPrefix) {
+		return nil, fmt.Errorf("不支持的通知类型: %s", eventType)
+	}
+
+	var notify ComplaintNotify
+	if err := json.Unmarshal(plain, &notify); err != nil {
+		return nil, fmt.Errorf("解析投诉通知内容失败: %v", err)
+	}
+	if notify.ComplaintID == "" {
+		return nil, fmt.Errorf("投诉通知缺少投诉单号")
+	}
+
+	return &notify, nil
+}
+
+// QueryComplaint 查询消费者投诉详情
+func (c *ClientV3) QueryComplaint(complaintID string) (*Complaint, error) {
+	logger.Info("查询微信支付消费者投诉(APIv3)", zap.String("complaint_id", complaintID))
+
+	var resp v3Complaint
+	if err := c.doRequest(http.MethodGet, "/v3/merchant-service/complaints-v2/"+url.PathEscape(complaintID), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	complaint := &Complaint{
+		ComplaintID:       resp.ComplaintID,
+		ComplaintDetail:   resp.ComplaintDetail,
+		ComplaintState:    resp.ComplaintState,
+		ProblemType:       resp.ProblemType,
+		ApplyRefundAmount: currency.FromMinorUnits(resp.ApplyRefundAmount, model.BaseCurrency),
+	}
+	if complaint.ComplaintDetail == "" {
+		complaint.ComplaintDetail = resp.ProblemDescription
+	}
+	if t, err := time.Parse(time.RFC3339, resp.ComplaintTime); err == nil {
+		complaint.ComplaintTime = &t
+	}
+	for _, order := range resp.ComplaintOrderInfo {
+		complaint.Orders = append(complaint.Orders, ComplaintOrder{
+			TransactionID: order.TransactionID,
+			OutTradeNo:    order.OutTradeNo,
+			Amount:        currency.FromMinorUnits(order.Amount, model.BaseCurrency),
+		})
+	}
+	return complaint, nil
+}
+
+// decodeNotify 验证通知签名并解密 resource
+func (c *ClientV3) decodeNotify(header http.Header, body []byte, checkTimestamp bool) ([]byte, string, error) {
+	if err := c.verifySignatureWith(header, body, checkTimestamp); err != nil {
+		return nil, "", fmt.Errorf("通知签名验证失败: %v", err)
+	}
+
+	var notify v3Notify
+	if err := json.Unmarshal(body, &notify); err != nil {
+		return nil, "", fmt.Errorf("解析通知失败: %v", err)
+	}
+
+	resource := notify.Resource
+	plain, err := DecryptAESGCM(c.config.APIv3Key, resource.Nonce, resource.AssociatedData, resource.Ciphertext)
+	if err != nil {
+		return nil, "", fmt.Errorf("解密通知失败: %v", err)
+	}
+
+	return plain, notify.EventType, nil
+}
+
+// doRequest 发送APIv3请求并验证应答签名
+func (c *ClientV3) doRequest(method, path string, payload interface{}, out interface{}) error {
+	header, body, err := c.send(method, path, payload)
+	if err != nil {
+		return err
+	}
+
+	if err := c.verifySignature(header, body); err != nil {
+		return fmt.Errorf("应答签名验证失败: %v", err)
+	}
+
+	if out != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("解析响应失败: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// send 发送签名请求，非2xx应答返回 *APIError
+func (c *ClientV3) send(method, path string, payload interface{}) (http.Header, []byte, error) {
+	var body []byte
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, nil, fmt.Errorf("构建请求失败: %v", err)
+		}
+		body = data
+	}
+
+	authorization, err := c.buildAuthorization(method, path, string(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("请求签名失败: %v", err)
+	}
+
+	req, err := http.NewRequest(method, c.config.GatewayURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("构建请求失败: %v", err)
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mall-go")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("发送请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(respBody, apiErr)
+		return nil, nil, apiErr
+	}
+
+	return resp.Header, respBody, nil
+}
+
+// downloadCertificates 下载并解密平台证书
+// 证书列表本身使用APIv3密钥加密，解密后用其中与应答序列号匹配的证书验证应答签名
+func (c *ClientV3) downloadCertificates() (map[string]*x509.Certificate, error) {
+	header, body, err := c.send(http.MethodGet, "/v3/certificates", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data []v3Certificate `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("解析平台证书失败: %v", err)
+	}
+
+	certs := make(map[string]*x509.Certificate, len(resp.Data))
+	for _, item := range resp.Data {
+		encrypted := item.EncryptCertificate
+		plain, err := DecryptAESGCM(c.config.APIv3Key, encrypted.Nonce, encrypted.AssociatedData, encrypted.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("解密平台证书失败: %v", err)
+		}
+
+		cert, err := ParseCertificate(plain)
+		if err != nil {
+			return nil, fmt.Errorf("解析平台证书失败: %v", err)
+		}
+
+		if serialNo := CertificateSerialNo(cert); serialNo != item.SerialNo {
+			return nil, fmt.Errorf("平台证书序列号不匹配: %s", item.SerialNo)
+		}
+		certs[item.SerialNo] = cert
+	}
+
+	cert, ok := certs[header.Get(HeaderSerial)]
+	if !ok {
+		return nil, fmt.Errorf("应答证书序列号不在平台证书列表中")
+	}
+	if err := verifyWithCertificate(cert, header, body, true); err != nil {
+		return nil, fmt.Errorf("平台证书应答签名验证失败: %v", err)
+	}
+
+	return certs, nil
+}
+
+// verifySignature 使用平台证书验证应答或通知签名
+func (c *ClientV3) verifySignature(header http.Header, body []byte) error {
+	return c.verifySignatureWith(header, body, true)
+}
+
+// verifySignatureWith 使用平台证书验证签名，checkTimestamp 为false时不校验时间戳是否过期
+func (c *ClientV3) verifySignatureWith(header http.Header, body []byte, checkTimestamp bool) error {
+	serialNo := header.Get(HeaderSerial)
+	if serialNo == "" {
+		return fmt.Errorf("缺少平台证书序列号")
+	}
+
+	cert, err := c.certManager.Get(serialNo)
+	if err != nil {
+		return err
+	}
+
+	return verifyWithCertificate(cert, header, body, checkTimestamp)
+}
+
+// verifyWithCertificate 使用指定证书验证签名
+func verifyWithCertificate(cert *x509.Certificate, header http.Header, body []byte, checkTimestamp bool) error {
+	timestamp := header.Get(HeaderTimestamp)
+	nonce := header.Get(HeaderNonce)
+	signature := header.Get(HeaderSignature)
+	if timestamp == "" || nonce == "" || signature == "" {
+		return fmt.Errorf("缺少签名信息")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("时间戳格式错误")
+	}
+	if diff := time.Since(time.Unix(ts, 0)); checkTimestamp && (diff > v3SignatureTolerance || diff < -v3SignatureTolerance) {
+		return fmt.Errorf("时间戳已过期")
+	}
+
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("平台证书公钥不是RSA类型")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("签名解码失败: %v", err)
+	}
+
+	hashed := sha256.Sum256([]byte(buildSignMessage(timestamp, nonce, string(body))))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], sig); err != nil {
+		return fmt.Errorf("签名不匹配")
+	}
+
+	return nil
+}
+
+// buildAuthorization 构建请求 Authorization 头
+func (c *ClientV3) buildAuthorization(method, path, body string) (string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonceStr := c.generateNonceStr()
+
+	signature, err := c.sign(buildSignMessage(method, path, timestamp, nonceStr, body))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`WECHATPAY2-SHA256-RSA2048 mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		c.config.MchID, nonceStr, signature, timestamp, c.config.SerialNo), nil
+}
+
+// sign 使用商户私钥进行SHA256withRSA签名
+func (c *ClientV3) sign(message string) (string, error) {
+	hashed := sha256.Sum256([]byte(message))
+	sig, err := rsa.SignPKCS1v15(crand.Reader, c.privateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// generateNonceStr 生成随机字符串
+func (c *ClientV3) generateNonceStr() string {
+	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, 32)
+	max := big.NewInt(int64(len(charset)))
+	for i := range b {
+		n, _ := crand.Int(crand.Reader, max)
+		b[i] = charset[n.Int64()]
+	}
+	return string(b)
+}
+
+// buildSignMessage 构建签名串，每个字段以换行符结尾
+func buildSignMessage(fields ...string) string {
+	var sb strings.Builder
+	for _, field := range fields {
+		sb.WriteString(field)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
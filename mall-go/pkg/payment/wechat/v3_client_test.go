@@ -0,0 +1,478 @@
+package wechat
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/payment/config"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAPIv3Key = "0123456789abcdef0123456789abcdef"
+
+// v3TestPlatform 模拟微信支付平台：持有平台私钥与证书，对应答和通知签名
+type v3TestPlatform struct {
+	t         *testing.T
+	key       *rsa.PrivateKey
+	cert      *x509.Certificate
+	certPEM   []byte
+	serialNo  string
+	merchant  *rsa.PublicKey
+	handlers  map[string]func(body []byte) (int, interface{})
+	certCalls int
+}
+
+func newV3TestPlatform(t *testing.T, merchant *rsa.PublicKey) *v3TestPlatform {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(0x5157F09EFDC096DE),
+		Subject:      pkix.Name{CommonName: "Tenpay.com Root CA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &v3TestPlatform{
+		t:        t,
+		key:      key,
+		cert:     cert,
+		certPEM:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		serialNo: CertificateSerialNo(cert),
+		merchant: merchant,
+		handlers: make(map[string]func(body []byte) (int, interface{})),
+	}
+}
+
+// encrypt 使用APIv3密钥加密
+func (p *v3TestPlatform) encrypt(plain []byte, associatedData string) v3Encrypted {
+	block, err := aes.NewCipher([]byte(testAPIv3Key))
+	require.NoError(p.t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(p.t, err)
+
+	nonce := "abcdef123456"
+	ciphertext := gcm.Seal(nil, []byte(nonce), plain, []byte(associatedData))
+	return v3Encrypted{
+		Algorithm:      "AEAD_AES_256_GCM",
+		Nonce:          nonce,
+		AssociatedData: associatedData,
+		Ciphertext:     base64.StdEncoding.EncodeToString(ciphertext),
+	}
+}
+
+// signHeader 生成平台签名头
+func (p *v3TestPlatform) signHeader(body []byte) http.Header {
+	return p.signHeaderAt(body, time.Now())
+}
+
+// signHeaderAt 按指定时间生成平台通知签名头
+func (p *v3TestPlatform) signHeaderAt(body []byte, at time.Time) http.Header {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	nonce := "platformnonce"
+	hashed := sha256.Sum256([]byte(buildSignMessage(timestamp, nonce, string(body))))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, hashed[:])
+	require.NoError(p.t, err)
+
+	header := http.Header{}
+	header.Set(HeaderSerial, p.serialNo)
+	header.Set(HeaderTimestamp, timestamp)
+	header.Set(HeaderNonce, nonce)
+	header.Set(HeaderSignature, base64.StdEncoding.EncodeToString(sig))
+	return header
+}
+
+// verifyAuthorization 校验商户请求签名
+func (p *v3TestPlatform) verifyAuthorization(r *http.Request, body []byte) bool {
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "WECHATPAY2-SHA256-RSA2048 ")
+	fields := make(map[string]string)
+	for _, part := range strings.Split(auth, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(fields["signature"])
+	if err != nil {
+		return false
+	}
+	message := buildSignMessage(r.Method, r.URL.RequestURI(), fields["timestamp"], fields["nonce_str"], string(body))
+	hashed := sha256.Sum256([]byte(message))
+	return rsa.VerifyPKCS1v15(p.merchant, crypto.SHA256, hashed[:], sig) == nil
+}
+
+func (p *v3TestPlatform) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if !p.verifyAuthorization(r, body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":"SIGN_ERROR","message":"签名错误"}`))
+		return
+	}
+
+	var status int
+	var payload interface{}
+	if r.URL.Path == "/v3/certificates" {
+		p.certCalls++
+		status, payload = http.StatusOK, map[string]interface{}{
+			"data": []v3Certificate{{
+				SerialNo:           p.serialNo,
+				EncryptCertificate: p.encrypt(p.certPEM, "certificate"),
+			}},
+		}
+	} else if handler, ok := p.handlers[r.Method+" "+r.URL.Path]; ok {
+		status, payload = handler(body)
+	} else {
+		status, payload = http.StatusNotFound, map[string]string{"code": "NOT_FOUND", "message": "not found"}
+	}
+
+	data, _ := json.Marshal(payload)
+	for k, v := range p.signHeader(data) {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func newTestClientV3(t *testing.T) (*ClientV3, *v3TestPlatform, *httptest.Server) {
+	merchantKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	platform := newV3TestPlatform(t, &merchantKey.PublicKey)
+	server := httptest.NewServer(platform)
+	t.Cleanup(server.Close)
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(merchantKey)})
+	client, err := NewClientV3(&config.WechatConfig{
+		AppID:      "wx123",
+		MchID:      "1900000001",
+		APIv3Key:   testAPIv3Key,
+		SerialNo:   "MERCHANTSERIAL",
+		PrivateKey: string(keyPEM),
+		GatewayURL: server.URL,
+		Timeout:    5 * time.Second,
+	})
+	require.NoError(t, err)
+
+	return client, platform, server
+}
+
+func TestClientV3_CreatePayment(t *testing.T) {
+	client, platform, _ := newTestClientV3(t)
+
+	var received v3PrepayRequest
+	platform.handlers["POST /v3/pay/transactions/native"] = func(body []byte) (int, interface{}) {
+		json.Unmarshal(body, &received)
+		return http.StatusOK, map[string]string{"code_url": "weixin://wxpay/bizpayurl?pr=abc"}
+	}
+	platform.handlers["POST /v3/pay/transactions/jsapi"] = func(body []byte) (int, interface{}) {
+		return http.StatusOK, map[string]string{"prepay_id": "wx201410272009395522657a690389285100"}
+	}
+
+	t.Run("Native下单", func(t *testing.T) {
+		resp, err := client.CreatePayment(&PaymentRequest{
+			Body:       "测试商品",
+			OutTradeNo: "PAY123",
+			TotalFee:   decimal.NewFromFloat(12.34),
+			NotifyURL:  "https://example.com/notify",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "weixin://wxpay/bizpayurl?pr=abc", resp.CodeURL)
+		assert.Equal(t, TradeTypeNative, resp.TradeType)
+		assert.Equal(t, int64(1234), received.Amount.Total)
+		assert.Equal(t, "1900000001", received.MchID)
+		assert.Equal(t, 1, platform.certCalls)
+	})
+
+	t.Run("JSAPI下单返回调起参数", func(t *testing.T) {
+		resp, err := client.CreatePayment(&PaymentRequest{
+			OutTradeNo: "PAY124",
+			TotalFee:   decimal.NewFromInt(1),
+			TradeType:  TradeTypeJSAPI,
+			OpenID:     "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "prepay_id=wx201410272009395522657a690389285100", resp.PayParams["package"])
+		assert.Equal(t, "RSA", resp.PayParams["signType"])
+		assert.NotEmpty(t, resp.PayParams["paySign"])
+		// 平台证书已缓存，不会重复下载
+		assert.Equal(t, 1, platform.certCalls)
+	})
+
+	t.Run("JSAPI缺少openid", func(t *testing.T) {
+		_, err := client.CreatePayment(&PaymentRequest{OutTradeNo: "PAY125", TradeType: TradeTypeJSAPI})
+		assert.Error(t, err)
+	})
+}
+
+func TestClientV3_OrderNotExist(t *testing.T) {
+	client, platform, _ := newTestClientV3(t)
+
+	notExist := func(body []byte) (int, interface{}) {
+		return http.StatusNotFound, map[string]string{"code": "ORDER_NOT_EXIST", "message": "订单不存在"}
+	}
+	platform.handlers["GET /v3/pay/transactions/out-trade-no/PAY404"] = notExist
+	platform.handlers["POST /v3/pay/transactions/out-trade-no/PAY404/close"] = notExist
+
+	// 用户未支付时查询返回交易不存在，关单视为已关闭
+	_, err := client.QueryPayment("PAY404")
+	assert.ErrorIs(t, err, model.ErrTradeNotExist)
+	assert.NoError(t, client.CloseOrder("PAY404"))
+}
+
+func TestClientV3_Refund(t *testing.T) {
+	client, platform, _ := newTestClientV3(t)
+
+	platform.handlers["POST /v3/refund/domestic/refunds"] = func(body []byte) (int, interface{}) {
+		var req v3RefundRequest
+		json.Unmarshal(body, &req)
+		if req.OutRefundNo == "REF_FAIL" {
+			return http.StatusForbidden, map[string]string{"code": "NOT_ENOUGH", "message": "基本账户余额不足"}
+		}
+		return http.StatusOK, map[string]interface{}{
+			"refund_id":     "50000000382019052709732678859",
+			"out_refund_no": req.OutRefundNo,
+			"status":        "PROCESSING",
+			"amount":        map[string]int64{"refund": req.Amount.Refund, "total": req.Amount.Total},
+		}
+	}
+
+	t.Run("退款受理", func(t *testing.T) {
+		resp, err := client.Refund(&RefundRequest{
+			OutTradeNo:  "PAY123",
+			OutRefundNo: "REF123",
+			TotalFee:    decimal.NewFromInt(10),
+			RefundFee:   decimal.NewFromFloat(2.5),
+		})
+		require.NoError(t, err)
+		assert.True(t, resp.Success)
+		assert.Equal(t, model.PaymentStatusPending, resp.Status)
+		assert.True(t, resp.RefundFee.Equal(decimal.NewFromFloat(2.5)))
+	})
+
+	t.Run("业务失败", func(t *testing.T) {
+		resp, err := client.Refund(&RefundRequest{OutTradeNo: "PAY123", OutRefundNo: "REF_FAIL"})
+		require.NoError(t, err)
+		assert.False(t, resp.Success)
+		assert.Equal(t, model.PaymentStatusFailed, resp.Status)
+		assert.Equal(t, "基本账户余额不足", resp.Message)
+	})
+}
+
+func TestClientV3_ProfitSharing(t *testing.T) {
+	client, platform, _ := newTestClientV3(t)
+
+	var received v3ProfitSharingRequest
+	platform.handlers["POST /v3/profitsharing/orders"] = func(body []byte) (int, interface{}) {
+		json.Unmarshal(body, &received)
+		return http.StatusOK, map[string]string{
+			"transaction_id": received.TransactionID,
+			"out_order_no":   received.OutOrderNo,
+			"order_id":       "3008450740201411110007820472",
+			"state":          "PROCESSING",
+		}
+	}
+
+	resp, err := client.ProfitSharing(&ProfitSharingRequest{
+		TransactionID: "4208450740201411110007820472",
+		OutOrderNo:    "SP20240101001",
+		Receivers: []ProfitSharingReceiver{{
+			Type:        ProfitSharingReceiverMerchant,
+			Account:     "1900000109",
+			Amount:      decimal.RequireFromString("88.80"),
+			Description: "商家结算",
+		}},
+		UnfreezeUnsplit: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "3008450740201411110007820472", resp.OrderID)
+	assert.Equal(t, "PROCESSING", resp.State)
+	assert.Equal(t, "wx123", received.AppID)
+	require.Len(t, received.Receivers, 1)
+	assert.Equal(t, int64(8880), received.Receivers[0].Amount)
+
+	_, err = client.ProfitSharing(&ProfitSharingRequest{OutOrderNo: "SP1"})
+	assert.Error(t, err)
+}
+
+func TestClientV3_ParseNotify(t *testing.T) {
+	client, platform, _ := newTestClientV3(t)
+
+	transaction, _ := json.Marshal(map[string]interface{}{
+		"mchid":          "1900000001",
+		"appid":          "wx123",
+		"out_trade_no":   "PAY123",
+		"transaction_id": "4200000000000000000000000001",
+		"trade_type":     "NATIVE",
+		"trade_state":    "SUCCESS",
+		"success_time":   "2024-05-27T15:30:00+08:00",
+		"amount":         map[string]interface{}{"total": 1234, "payer_total": 1234, "currency": "CNY"},
+	})
+	body, _ := json.Marshal(v3Notify{
+		ID:           "EV-2018022511223320873",
+		EventType:    EventTransactionSuccess,
+		ResourceType: "encrypt-resource",
+		Resource:     platform.encrypt(transaction, "transaction"),
+	})
+
+	t.Run("验签解密成功", func(t *testing.T) {
+		callback, err := client.ParseNotify(platform.signHeader(body), body)
+		require.NoError(t, err)
+		assert.True(t, callback.IsPaymentSuccess())
+		assert.Equal(t, "PAY123", callback.OutTradeNo)
+		assert.True(t, callback.GetTotalAmount(callback.FeeType).Equal(decimal.NewFromFloat(12.34)))
+		assert.NoError(t, callback.Validate())
+	})
+
+	t.Run("报文被篡改", func(t *testing.T) {
+		header := platform.signHeader(body)
+		tampered := []byte(strings.Replace(string(body), "EV-2018022511223320873", "EV-0000000000000000000", 1))
+		_, err := client.ParseNotify(header, tampered)
+		assert.Error(t, err)
+	})
+
+	t.Run("时间戳过期", func(t *testing.T) {
+		header := platform.signHeader(body)
+		header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+		_, err := client.ParseNotify(header, body)
+		assert.Error(t, err)
+	})
+
+	t.Run("重放历史通知", func(t *testing.T) {
+		header := platform.signHeaderAt(body, time.Now().Add(-24*time.Hour))
+		_, err := client.ParseNotify(header, body)
+		assert.Error(t, err)
+
+		// 重放时仍验证签名，但不校验时间戳
+		callback, err := client.ParseReplayedNotify(header, body)
+		require.NoError(t, err)
+		assert.Equal(t, "PAY123", callback.OutTradeNo)
+
+		tampered := []byte(strings.Replace(string(body), "EV-2018022511223320873", "EV-0000000000000000000", 1))
+		_, err = client.ParseReplayedNotify(header, tampered)
+		assert.Error(t, err)
+	})
+}
+
+func TestClientV3_ParseRefundNotify(t *testing.T) {
+	client, platform, _ := newTestClientV3(t)
+
+	refund, _ := json.Marshal(map[string]interface{}{
+		"mchid":         "1900000001",
+		"out_trade_no":  "PAY123",
+		"out_refund_no": "REF123",
+		"refund_id":     "50000000382019052709732678859",
+		"refund_status": "SUCCESS",
+		"success_time":  "2024-05-27T15:30:00+08:00",
+		"amount":        map[string]int64{"total": 1000, "refund": 250, "payer_refund": 250},
+	})
+	body, _ := json.Marshal(v3Notify{
+		EventType: EventRefundSuccess,
+		Resource:  platform.encrypt(refund, "refund"),
+	})
+
+	notify, err := client.ParseRefundNotify(platform.signHeader(body), body)
+	require.NoError(t, err)
+	assert.Equal(t, "REF123", notify.OutRefundNo)
+	assert.Equal(t, model.PaymentStatusSuccess, notify.ToPaymentStatus())
+	assert.True(t, notify.GetRefundFee("CNY").Equal(decimal.NewFromFloat(2.5)))
+
+	refundedAt, err := notify.GetRefundTime()
+	assert.NoError(t, err)
+	assert.NotNil(t, refundedAt)
+}
+
+func TestClientV3_Complaint(t *testing.T) {
+	client, platform, _ := newTestClientV3(t)
+
+	notify, _ := json.Marshal(map[string]string{
+		"complaint_id": "200201820200101080076610000",
+		"action_type":  ComplaintActionCreate,
+	})
+	body, _ := json.Marshal(v3Notify{
+		EventType: "COMPLAINT.CREATE",
+		Resource:  platform.encrypt(notify, "complaint"),
+	})
+
+	parsed, err := client.ParseComplaintNotify(platform.signHeader(body), body)
+	require.NoError(t, err)
+	assert.Equal(t, "200201820200101080076610000", parsed.ComplaintID)
+	assert.Equal(t, ComplaintActionCreate, parsed.ActionType)
+
+	// 支付通知不能作为投诉通知处理
+	refundBody, _ := json.Marshal(v3Notify{
+		EventType: EventRefundSuccess,
+		Resource:  platform.encrypt(notify, "refund"),
+	})
+	_, err = client.ParseComplaintNotify(platform.signHeader(refundBody), refundBody)
+	assert.Error(t, err)
+
+	platform.handlers["GET /v3/merchant-service/complaints-v2/200201820200101080076610000"] = func(body []byte) (int, interface{}) {
+		return http.StatusOK, map[string]interface{}{
+			"complaint_id":        "200201820200101080076610000",
+			"complaint_time":      "2024-05-27T15:30:00+08:00",
+			"complaint_detail":    "商品未发货",
+			"complaint_state":     "PENDING",
+			"problem_type":        "REFUND",
+			"apply_refund_amount": 1500,
+			"complaint_order_info": []map[string]interface{}{{
+				"transaction_id": "4200000000000000000000000001",
+				"out_trade_no":   "PAY123",
+				"amount":         3000,
+			}},
+		}
+	}
+
+	complaint, err := client.QueryComplaint(parsed.ComplaintID)
+	require.NoError(t, err)
+	assert.Equal(t, "商品未发货", complaint.ComplaintDetail)
+	assert.True(t, complaint.ApplyRefundAmount.Equal(decimal.NewFromInt(15)))
+	require.NotNil(t, complaint.ComplaintTime)
+	require.Len(t, complaint.Orders, 1)
+	assert.Equal(t, "PAY123", complaint.Orders[0].OutTradeNo)
+	assert.True(t, complaint.Orders[0].Amount.Equal(decimal.NewFromInt(30)))
+}
+
+func TestCertificateManager_UnknownSerial(t *testing.T) {
+	calls := 0
+	platform := newV3TestPlatform(t, nil)
+	manager := NewCertificateManager(time.Hour, func() (map[string]*x509.Certificate, error) {
+		calls++
+		return map[string]*x509.Certificate{platform.serialNo: platform.cert}, nil
+	})
+
+	cert, err := manager.Get(platform.serialNo)
+	require.NoError(t, err)
+	assert.Equal(t, platform.cert, cert)
+	assert.Equal(t, 1, calls)
+
+	// 短时间内的未知序列号不会反复触发下载
+	_, err = manager.Get("UNKNOWN")
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,254 @@
+package wechat
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// APIv3 相关请求头
+const (
+	HeaderSerial    = "Wechatpay-Serial"
+	HeaderSignature = "Wechatpay-Signature"
+	HeaderTimestamp = "Wechatpay-Timestamp"
+	HeaderNonce     = "Wechatpay-Nonce"
+)
+
+// APIv3 通知事件类型
+const (
+	EventTransactionSuccess = "TRANSACTION.SUCCESS" // 支付成功
+	EventRefundSuccess      = "REFUND.SUCCESS"      // 退款成功
+	EventRefundAbnormal     = "REFUND.ABNORMAL"     // 退款异常
+	EventRefundClosed       = "REFUND.CLOSED"       // 退款关闭
+)
+
+// ComplaintEventPrefix 消费者投诉通知事件类型前缀，如 COMPLAINT.CREATE
+const ComplaintEventPrefix = "COMPLAINT."
+
+// 消费者投诉通知动作类型
+const (
+	ComplaintActionCreate   = "CREATE_COMPLAINT"   // 用户提交投诉
+	ComplaintActionContinue = "CONTINUE_COMPLAINT" // 用户继续投诉
+)
+
+// APIError APIv3接口错误应答
+type APIError struct {
+	StatusCode int    `json:"-"`       // HTTP状态码
+	Code       string `json:"code"`    // 错误码
+	Message    string `json:"message"` // 错误描述
+}
+
+// Error 实现error接口
+func (e *APIError) Error() string {
+	return fmt.Sprintf("微信支付返回错误(%d): %s - %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsOrderNotExist 是否为订单不存在错误
+func (e *APIError) IsOrderNotExist() bool {
+	return e.Code == "ORDER_NOT_EXIST"
+}
+
+// IsBusinessError 是否为明确的业务错误（请求被拒绝，重试无意义）
+func (e *APIError) IsBusinessError() bool {
+	switch e.StatusCode {
+	case 400, 403, 404:
+		return true
+	default:
+		return false
+	}
+}
+
+// v3Amount 金额信息（单位：分）
+type v3Amount struct {
+	Total         int64  `json:"total,omitempty"`          // 订单总金额
+	PayerTotal    int64  `json:"payer_total,omitempty"`    // 用户支付金额
+	Refund        int64  `json:"refund,omitempty"`         // 退款金额
+	PayerRefund   int64  `json:"payer_refund,omitempty"`   // 用户退款金额
+	Currency      string `json:"currency,omitempty"`       // 货币类型
+	PayerCurrency string `json:"payer_currency,omitempty"` // 用户支付币种
+}
+
+// v3PrepayRequest 预下单请求
+type v3PrepayRequest struct {
+	AppID       string       `json:"appid"`
+	MchID       string       `json:"mchid"`
+	Description string       `json:"description"`
+	OutTradeNo  string       `json:"out_trade_no"`
+	TimeExpire  string       `json:"time_expire,omitempty"`
+	Attach      string       `json:"attach,omitempty"`
+	NotifyURL   string       `json:"notify_url"`
+	Amount      v3Amount     `json:"amount"`
+	Payer       *v3Payer     `json:"payer,omitempty"`
+	SceneInfo   *v3SceneInfo `json:"scene_info,omitempty"`
+}
+
+// v3Payer 支付者信息
+type v3Payer struct {
+	OpenID string `json:"openid"`
+}
+
+// v3SceneInfo 支付场景信息
+type v3SceneInfo struct {
+	PayerClientIP string    `json:"payer_client_ip"`
+	H5Info        *v3H5Info `json:"h5_info,omitempty"`
+}
+
+// v3H5Info H5场景信息
+type v3H5Info struct {
+	Type string `json:"type"`
+}
+
+// v3PrepayResponse 预下单应答
+type v3PrepayResponse struct {
+	PrepayID string `json:"prepay_id"`
+	CodeURL  string `json:"code_url"`
+	H5URL    string `json:"h5_url"`
+}
+
+// v3Transaction 交易信息（查询应答与支付通知解密后的内容）
+type v3Transaction struct {
+	AppID          string   `json:"appid"`
+	MchID          string   `json:"mchid"`
+	OutTradeNo     string   `json:"out_trade_no"`
+	TransactionID  string   `json:"transaction_id"`
+	TradeType      string   `json:"trade_type"`
+	TradeState     string   `json:"trade_state"`
+	TradeStateDesc string   `json:"trade_state_desc"`
+	BankType       string   `json:"bank_type"`
+	Attach         string   `json:"attach"`
+	SuccessTime    string   `json:"success_time"`
+	Payer          v3Payer  `json:"payer"`
+	Amount         v3Amount `json:"amount"`
+}
+
+// v3RefundRequest 退款请求
+type v3RefundRequest struct {
+	TransactionID string   `json:"transaction_id,omitempty"`
+	OutTradeNo    string   `json:"out_trade_no,omitempty"`
+	OutRefundNo   string   `json:"out_refund_no"`
+	Reason        string   `json:"reason,omitempty"`
+	NotifyURL     string   `json:"notify_url,omitempty"`
+	Amount        v3Amount `json:"amount"`
+}
+
+// v3ProfitSharingReceiver 分账接收方（金额单位：分）
+type v3ProfitSharingReceiver struct {
+	Type        string `json:"type"`
+	Account     string `json:"account"`
+	Amount      int64  `json:"amount"`
+	Description string `json:"description"`
+}
+
+// v3ProfitSharingRequest 请求分账
+type v3ProfitSharingRequest struct {
+	AppID           string                    `json:"appid"`
+	TransactionID   string                    `json:"transaction_id"`
+	OutOrderNo      string                    `json:"out_order_no"`
+	Receivers       []v3ProfitSharingReceiver `json:"receivers"`
+	UnfreezeUnsplit bool                      `json:"unfreeze_unsplit"`
+}
+
+// v3ProfitSharingOrder 分账单
+type v3ProfitSharingOrder struct {
+	TransactionID string `json:"transaction_id"`
+	OutOrderNo    string `json:"out_order_no"`
+	OrderID       string `json:"order_id"`
+	State         string `json:"state"`
+}
+
+// v3Refund 退款信息（退款应答、退款查询与退款通知解密后的内容）
+type v3Refund struct {
+	MchID               string   `json:"mchid"`
+	RefundID            string   `json:"refund_id"`
+	OutRefundNo         string   `json:"out_refund_no"`
+	TransactionID       string   `json:"transaction_id"`
+	OutTradeNo          string   `json:"out_trade_no"`
+	Status              string   `json:"status"`
+	RefundStatus        string   `json:"refund_status"` // 退款通知中的状态字段
+	SuccessTime         string   `json:"success_time"`
+	UserReceivedAccount string   `json:"user_received_account"`
+	Amount              v3Amount `json:"amount"`
+}
+
+// v3Complaint 消费者投诉详情（投诉查询应答）
+type v3Complaint struct {
+	ComplaintID        string `json:"complaint_id"`
+	ComplaintTime      string `json:"complaint_time"`
+	ComplaintDetail    string `json:"complaint_detail"`
+	ComplaintState     string `json:"complaint_state"`
+	ProblemType        string `json:"problem_type"`
+	ProblemDescription string `json:"problem_description"`
+	ApplyRefundAmount  int64  `json:"apply_refund_amount"`
+	ComplaintOrderInfo []struct {
+		TransactionID string `json:"transaction_id"`
+		OutTradeNo    string `json:"out_trade_no"`
+		Amount        int64  `json:"amount"`
+	} `json:"complaint_order_info"`
+}
+
+// v3Certificate 平台证书信息
+type v3Certificate struct {
+	SerialNo           string      `json:"serial_no"`
+	EffectiveTime      string      `json:"effective_time"`
+	ExpireTime         string      `json:"expire_time"`
+	EncryptCertificate v3Encrypted `json:"encrypt_certificate"`
+}
+
+// v3Encrypted 加密数据
+type v3Encrypted struct {
+	Algorithm      string `json:"algorithm"`
+	Nonce          string `json:"nonce"`
+	AssociatedData string `json:"associated_data"`
+	Ciphertext     string `json:"ciphertext"`
+	OriginalType   string `json:"original_type,omitempty"`
+}
+
+// v3Notify 回调通知报文
+type v3Notify struct {
+	ID           string      `json:"id"`
+	CreateTime   string      `json:"create_time"`
+	EventType    string      `json:"event_type"`
+	ResourceType string      `json:"resource_type"`
+	Summary      string      `json:"summary"`
+	Resource     v3Encrypted `json:"resource"`
+}
+
+// toCallbackData 将APIv3交易信息转换为统一的回调数据
+func (t *v3Transaction) toCallbackData() *CallbackData {
+	resultCode := ResultCodeFail
+	if t.TradeState == TradeStateSuccess {
+		resultCode = ResultCodeSuccess
+	}
+
+	return &CallbackData{
+		ReturnCode:    ReturnCodeSuccess,
+		ResultCode:    resultCode,
+		ErrCode:       t.TradeState,
+		ErrCodeDes:    t.TradeStateDesc,
+		AppID:         t.AppID,
+		MchID:         t.MchID,
+		OpenID:        t.Payer.OpenID,
+		TradeType:     t.TradeType,
+		BankType:      t.BankType,
+		TotalFee:      strconv.FormatInt(t.Amount.Total, 10),
+		FeeType:       t.Amount.Currency,
+		CashFee:       strconv.FormatInt(t.Amount.PayerTotal, 10),
+		TransactionID: t.TransactionID,
+		OutTradeNo:    t.OutTradeNo,
+		Attach:        t.Attach,
+		TimeEnd:       formatV3Time(t.SuccessTime, "20060102150405"),
+	}
+}
+
+// formatV3Time 将APIv3的RFC3339时间转换为v2接口使用的本地时间格式
+func formatV3Time(value, layout string) string {
+	if value == "" {
+		return ""
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return ""
+	}
+	return t.In(time.Local).Format(layout)
+}
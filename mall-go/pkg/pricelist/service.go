@@ -0,0 +1,607 @@
+package pricelist
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/currency"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/pagination"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// hundred 百分比换算基数
+var hundred = decimal.NewFromInt(100)
+
+// Service 客户分组价服务
+// 用户所属的启用分组中，在有效期内的价目表都参与定价，同一商品取换算为结算币种后最低的价格
+type Service struct {
+	db              *gorm.DB
+	currencyService *currency.Service
+	now             func() time.Time
+}
+
+// NewService 创建客户分组价服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:              db,
+		currencyService: currency.NewService(db),
+		now:             time.Now,
+	}
+}
+
+// Line 待定价的商品，BasePrice 为公开售价，用于百分比调整和无分组价时的价格
+type Line struct {
+	ProductID uint
+	SKUID     uint
+	Quantity  int
+	BasePrice decimal.Decimal
+}
+
+// ListGroups 查询客户分组
+func (s *Service) ListGroups() ([]model.CustomerGroup, error) {
+	var groups []model.CustomerGroup
+	if err := s.db.Order("id ASC").Find(&groups).Error; err != nil {
+		return nil, fmt.Errorf("查询客户分组失败: %v", err)
+	}
+	return groups, nil
+}
+
+// CreateGroup 创建客户分组
+func (s *Service) CreateGroup(req *model.CustomerGroupRequest, operatorID uint) (*model.CustomerGroup, error) {
+	group := &model.CustomerGroup{}
+	if err := s.applyGroupRequest(group, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(group).Error; err != nil {
+		return nil, fmt.Errorf("创建客户分组失败: %v", err)
+	}
+
+	logger.Info("创建客户分组", zap.Uint("group_id", group.ID), zap.String("code", group.Code), zap.Uint("operator_id", operatorID))
+	return group, nil
+}
+
+// UpdateGroup 更新客户分组
+func (s *Service) UpdateGroup(id uint, req *model.CustomerGroupRequest, operatorID uint) (*model.CustomerGroup, error) {
+	group, err := s.group(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyGroupRequest(group, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(group).Error; err != nil {
+		return nil, fmt.Errorf("更新客户分组失败: %v", err)
+	}
+
+	logger.Info("更新客户分组", zap.Uint("group_id", id), zap.Uint("operator_id", operatorID))
+	return group, nil
+}
+
+// ListMembers 分页查询分组成员
+func (s *Service) ListMembers(groupID uint, page, pageSize int) ([]model.CustomerGroupMember, int64, error) {
+	if _, err := s.group(groupID); err != nil {
+		return nil, 0, err
+	}
+	page, pageSize = pagination.Normalize(page, pageSize)
+
+	query := s.db.Model(&model.CustomerGroupMember{}).Where("group_id = ?", groupID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计分组成员失败: %v", err)
+	}
+
+	var members []model.CustomerGroupMember
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&members).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询分组成员失败: %v", err)
+	}
+	return members, total, nil
+}
+
+// AddMembers 添加分组成员，已在分组中的用户跳过，返回新增人数
+func (s *Service) AddMembers(groupID uint, userIDs []uint, operatorID uint) (int, error) {
+	if _, err := s.group(groupID); err != nil {
+		return 0, err
+	}
+
+	var existing []uint
+	if err := s.db.Model(&model.CustomerGroupMember{}).
+		Where("group_id = ? AND user_id IN ?", groupID, userIDs).
+		Pluck("user_id", &existing).Error; err != nil {
+		return 0, fmt.Errorf("查询分组成员失败: %v", err)
+	}
+	skip := make(map[uint]bool, len(existing))
+	for _, userID := range existing {
+		skip[userID] = true
+	}
+
+	var members []model.CustomerGroupMember
+	for _, userID := range userIDs {
+		if userID == 0 || skip[userID] {
+			continue
+		}
+		skip[userID] = true
+		members = append(members, model.CustomerGroupMember{GroupID: groupID, UserID: userID, CreatedBy: operatorID})
+	}
+	if len(members) == 0 {
+		return 0, nil
+	}
+	if err := s.db.Create(&members).Error; err != nil {
+		return 0, fmt.Errorf("添加分组成员失败: %v", err)
+	}
+
+	logger.Info("添加分组成员", zap.Uint("group_id", groupID), zap.Int("count", len(members)), zap.Uint("operator_id", operatorID))
+	return len(members), nil
+}
+
+// RemoveMember 移出分组成员
+func (s *Service) RemoveMember(groupID, userID, operatorID uint) error {
+	if _, err := s.group(groupID); err != nil {
+		return err
+	}
+	if err := s.db.Where("group_id = ? AND user_id = ?", groupID, userID).Delete(&model.CustomerGroupMember{}).Error; err != nil {
+		return fmt.Errorf("移出分组成员失败: %v", err)
+	}
+
+	logger.Info("移出分组成员", zap.Uint("group_id", groupID), zap.Uint("user_id", userID), zap.Uint("operator_id", operatorID))
+	return nil
+}
+
+// ListPriceLists 分页查询价目表，不含条目
+func (s *Service) ListPriceLists(query *model.PriceListQuery) ([]model.PriceList, int64, error) {
+	query.Page, query.PageSize = pagination.Normalize(query.Page, query.PageSize)
+
+	db := s.db.Model(&model.PriceList{})
+	if query.GroupID > 0 {
+		db = db.Where("group_id = ?", query.GroupID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计价目表失败: %v", err)
+	}
+
+	var lists []model.PriceList
+	if err := db.Order("id DESC").Offset((query.Page - 1) * query.PageSize).Limit(query.PageSize).Find(&lists).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询价目表失败: %v", err)
+	}
+	return lists, total, nil
+}
+
+// GetPriceList 查询价目表及条目
+func (s *Service) GetPriceList(id uint) (*model.PriceList, error) {
+	var list model.PriceList
+	err := s.db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("product_id ASC, sku_id ASC, min_quantity ASC")
+	}).First(&list, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, model.ErrPriceListNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询价目表失败: %v", err)
+	}
+	return &list, nil
+}
+
+// CreatePriceList 创建价目表
+func (s *Service) CreatePriceList(req *model.PriceListRequest, operatorID uint) (*model.PriceList, error) {
+	list := &model.PriceList{CreatedBy: operatorID}
+	if err := s.applyListRequest(list, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(list).Error; err != nil {
+		return nil, fmt.Errorf("创建价目表失败: %v", err)
+	}
+
+	logger.Info("创建价目表", zap.Uint("price_list_id", list.ID), zap.Uint("group_id", list.GroupID), zap.Int("items", len(list.Items)), zap.Uint("operator_id", operatorID))
+	return list, nil
+}
+
+// UpdatePriceList 更新价目表，条目整体替换
+func (s *Service) UpdatePriceList(id uint, req *model.PriceListRequest, operatorID uint) (*model.PriceList, error) {
+	list, err := s.GetPriceList(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyListRequest(list, req); err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("price_list_id = ?", id).Delete(&model.PriceListItem{}).Error; err != nil {
+			return fmt.Errorf("删除价目表条目失败: %v", err)
+		}
+		if err := tx.Omit("Items").Save(list).Error; err != nil {
+			return fmt.Errorf("更新价目表失败: %v", err)
+		}
+		for i := range list.Items {
+			list.Items[i].PriceListID = id
+		}
+		if err := tx.Create(&list.Items).Error; err != nil {
+			return fmt.Errorf("保存价目表条目失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("更新价目表", zap.Uint("price_list_id", id), zap.Int("items", len(list.Items)), zap.Uint("operator_id", operatorID))
+	return list, nil
+}
+
+// DeletePriceList 删除价目表及条目
+func (s *Service) DeletePriceList(id, operatorID uint) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&model.PriceList{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("删除价目表失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrPriceListNotFound
+		}
+		if err := tx.Where("price_list_id = ?", id).Delete(&model.PriceListItem{}).Error; err != nil {
+			return fmt.Errorf("删除价目表条目失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("删除价目表", zap.Uint("price_list_id", id), zap.Uint("operator_id", operatorID))
+	return nil
+}
+
+// Resolve 按用户所属分组的价目表为商品定价，返回与 lines 一一对应的分组价，无适用价目表的商品为nil
+func (s *Service) Resolve(userID uint, lines []Line) ([]*model.CustomerPrice, error) {
+	prices := make([]*model.CustomerPrice, len(lines))
+	if userID == 0 || len(lines) == 0 {
+		return prices, nil
+	}
+
+	productIDs := make([]uint, 0, len(lines))
+	for _, line := range lines {
+		productIDs = append(productIDs, line.ProductID)
+	}
+	candidates, err := s.candidates(userID, productIDs)
+	if err != nil || len(candidates) == 0 {
+		return prices, err
+	}
+
+	for i, line := range lines {
+		prices[i] = best(candidates, line)
+	}
+	return prices, nil
+}
+
+// PriceWithTiers 查询用户购买单件商品的分组价及各数量档位的价格，无适用价目表时返回nil
+func (s *Service) PriceWithTiers(userID, productID, skuID uint, basePrice decimal.Decimal) (*model.CustomerPrice, error) {
+	if userID == 0 {
+		return nil, nil
+	}
+	candidates, err := s.candidates(userID, []uint{productID})
+	if err != nil || len(candidates) == 0 {
+		return nil, err
+	}
+
+	line := Line{ProductID: productID, SKUID: skuID, Quantity: 1, BasePrice: basePrice}
+	var quantities []int
+	seen := map[int]bool{1: true}
+	for _, c := range candidates {
+		for _, item := range entries(c.list.Items, productID, skuID) {
+			if !seen[item.MinQuantity] {
+				seen[item.MinQuantity] = true
+				quantities = append(quantities, item.MinQuantity)
+			}
+		}
+	}
+	sort.Ints(quantities)
+
+	// 起订数量大于1的价目表在单件时不适用，单件仍为公开售价
+	price := best(candidates, line)
+	if price == nil {
+		price = &model.CustomerPrice{BasePrice: basePrice, Price: basePrice}
+	}
+	price.Tiers = []model.CustomerPriceTier{{MinQuantity: 1, Price: price.Price, PriceListID: price.PriceListID}}
+	for _, quantity := range quantities {
+		line.Quantity = quantity
+		tier := best(candidates, line)
+		last := price.Tiers[len(price.Tiers)-1]
+		if tier == nil || tier.Price.Equal(last.Price) {
+			continue
+		}
+		price.Tiers = append(price.Tiers, model.CustomerPriceTier{MinQuantity: quantity, Price: tier.Price, PriceListID: tier.PriceListID})
+	}
+	if price.PriceListID == 0 && len(price.Tiers) == 1 {
+		return nil, nil
+	}
+	return price, nil
+}
+
+// ApplyToCartItems 将商品项价格替换为用户的分组价，商品项价格需为当前公开售价，返回与 items 一一对应的分组价
+func (s *Service) ApplyToCartItems(userID uint, items []model.CartItem) ([]*model.CustomerPrice, error) {
+	lines := make([]Line, len(items))
+	for i, item := range items {
+		lines[i] = Line{ProductID: item.ProductID, SKUID: item.SKUID, Quantity: item.Quantity, BasePrice: item.Price}
+	}
+	prices, err := s.Resolve(userID, lines)
+	if err != nil {
+		return nil, err
+	}
+	for i, price := range prices {
+		if price != nil {
+			items[i].Price = price.Price
+		}
+	}
+	return prices, nil
+}
+
+// candidate 用户适用的价目表，rate 为结算币种兑价目表币种的汇率
+type candidate struct {
+	list model.PriceList
+	rate decimal.Decimal
+}
+
+// candidates 查询用户当前适用的价目表，只加载指定商品的条目
+func (s *Service) candidates(userID uint, productIDs []uint) ([]candidate, error) {
+	groupIDs := s.db.Model(&model.CustomerGroupMember{}).
+		Joins("JOIN customer_groups ON customer_groups.id = customer_group_members.group_id").
+		Where("customer_group_members.user_id = ? AND customer_groups.is_active = ?", userID, true).
+		Select("customer_group_members.group_id")
+
+	now := s.now()
+	var lists []model.PriceList
+	if err := s.db.Where("group_id IN (?) AND is_active = ?", groupIDs, true).
+		Where("start_at IS NULL OR start_at <= ?", now).
+		Where("end_at IS NULL OR end_at > ?", now).
+		Preload("Items", "product_id IN ?", productIDs).
+		Order("id ASC").
+		Find(&lists).Error; err != nil {
+		return nil, fmt.Errorf("查询价目表失败: %v", err)
+	}
+
+	candidates := make([]candidate, 0, len(lists))
+	rates := make(map[string]decimal.Decimal)
+	for _, list := range lists {
+		if len(list.Items) == 0 {
+			continue
+		}
+		rate, ok := rates[list.Currency]
+		if !ok {
+			exchangeRate, err := s.currencyService.GetRate(list.Currency, now)
+			if err != nil {
+				// 缺少汇率时无法换算固定价，跳过该价目表按其他价目表或公开售价定价
+				logger.Warn("价目表币种缺少汇率", zap.Uint("price_list_id", list.ID), zap.String("currency", list.Currency), zap.Error(err))
+				continue
+			}
+			rate = exchangeRate.Rate
+			rates[list.Currency] = rate
+		}
+		candidates = append(candidates, candidate{list: list, rate: rate})
+	}
+	return candidates, nil
+}
+
+// best 取各价目表中最低的分组价，价格相同时取先创建的价目表
+func best(candidates []candidate, line Line) *model.CustomerPrice {
+	var price *model.CustomerPrice
+	for _, c := range candidates {
+		value, ok := c.price(line)
+		if !ok {
+			continue
+		}
+		if price == nil || value.LessThan(price.Price) {
+			price = &model.CustomerPrice{
+				PriceListID:   c.list.ID,
+				PriceListName: c.list.Name,
+				BasePrice:     line.BasePrice,
+				Price:         value,
+			}
+		}
+	}
+	return price
+}
+
+// price 按购买数量所在档位计算结算币种单价，商品不在价目表或数量未达最低档位时不适用
+func (c *candidate) price(line Line) (decimal.Decimal, bool) {
+	var matched *model.PriceListItem
+	for _, item := range entries(c.list.Items, line.ProductID, line.SKUID) {
+		if item.MinQuantity <= line.Quantity && (matched == nil || item.MinQuantity > matched.MinQuantity) {
+			item := item
+			matched = &item
+		}
+	}
+	if matched == nil {
+		return decimal.Zero, false
+	}
+
+	var value decimal.Decimal
+	switch matched.Type {
+	case model.PriceAdjustmentFixed:
+		value = matched.Value.Div(c.rate)
+	case model.PriceAdjustmentPercentage:
+		value = line.BasePrice.Mul(hundred.Add(matched.Value)).Div(hundred)
+	default:
+		return decimal.Zero, false
+	}
+	value = currency.Round(value, model.BaseCurrency)
+	if value.IsNegative() {
+		value = decimal.Zero
+	}
+	return value, true
+}
+
+// entries 商品在价目表中的条目，SKU配置了条目时只用SKU条目，否则用商品条目
+func entries(items []model.PriceListItem, productID, skuID uint) []model.PriceListItem {
+	var productItems, skuItems []model.PriceListItem
+	for _, item := range items {
+		if item.ProductID != productID {
+			continue
+		}
+		switch {
+		case item.SKUID == 0:
+			productItems = append(productItems, item)
+		case skuID > 0 && item.SKUID == skuID:
+			skuItems = append(skuItems, item)
+		}
+	}
+	if len(skuItems) > 0 {
+		return skuItems
+	}
+	return productItems
+}
+
+// group 查询客户分组
+func (s *Service) group(id uint) (*model.CustomerGroup, error) {
+	var group model.CustomerGroup
+	err := s.db.First(&group, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, model.ErrCustomerGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询客户分组失败: %v", err)
+	}
+	return &group, nil
+}
+
+// applyGroupRequest 校验请求并写入客户分组
+func (s *Service) applyGroupRequest(group *model.CustomerGroup, req *model.CustomerGroupRequest) error {
+	var count int64
+	if err := s.db.Model(&model.CustomerGroup{}).
+		Where("id <> ? AND code = ?", group.ID, req.Code).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("查询客户分组失败: %v", err)
+	}
+	if count > 0 {
+		return model.ErrCustomerGroupDuplicate
+	}
+
+	group.Code = req.Code
+	group.Name = req.Name
+	group.Description = req.Description
+	group.IsActive = req.IsActive
+	return nil
+}
+
+// itemKey 价目表条目的唯一键
+type itemKey struct {
+	productID   uint
+	skuID       uint
+	minQuantity int
+}
+
+// applyListRequest 校验请求并写入价目表及条目
+func (s *Service) applyListRequest(list *model.PriceList, req *model.PriceListRequest) error {
+	if _, err := s.group(req.GroupID); err != nil {
+		return err
+	}
+	code := currency.Normalize(req.Currency)
+	if _, err := s.currencyService.GetCurrency(code); err != nil {
+		if errors.Is(err, model.ErrCurrencyNotSupported) {
+			return fmt.Errorf("%w: 不支持的币种 %s", model.ErrInvalidPriceList, code)
+		}
+		return err
+	}
+	if req.StartAt != nil && req.EndAt != nil && !req.EndAt.After(*req.StartAt) {
+		return fmt.Errorf("%w: 结束时间需晚于开始时间", model.ErrInvalidPriceList)
+	}
+
+	items := make([]model.PriceListItem, 0, len(req.Items))
+	seen := make(map[itemKey]bool, len(req.Items))
+	productIDs := make(map[uint]bool)
+	skuProducts := make(map[uint]uint)
+	for _, r := range req.Items {
+		minQuantity := r.MinQuantity
+		if minQuantity == 0 {
+			minQuantity = 1
+		}
+		if minQuantity < 0 {
+			return fmt.Errorf("%w: 起订数量不能为负数", model.ErrInvalidPriceList)
+		}
+		switch r.Type {
+		case model.PriceAdjustmentFixed:
+			if !r.Value.IsPositive() {
+				return fmt.Errorf("%w: 固定价需大于0", model.ErrInvalidPriceList)
+			}
+		case model.PriceAdjustmentPercentage:
+			if !r.Value.GreaterThan(hundred.Neg()) {
+				return fmt.Errorf("%w: 调整百分比需大于-100", model.ErrInvalidPriceList)
+			}
+		default:
+			return fmt.Errorf("%w: 不支持的定价方式 %s", model.ErrInvalidPriceList, r.Type)
+		}
+
+		key := itemKey{productID: r.ProductID, skuID: r.SKUID, minQuantity: minQuantity}
+		if seen[key] {
+			return fmt.Errorf("%w: 商品 %d 规格 %d 的 %d 件档位重复", model.ErrInvalidPriceList, r.ProductID, r.SKUID, minQuantity)
+		}
+		seen[key] = true
+		productIDs[r.ProductID] = true
+		if r.SKUID > 0 {
+			skuProducts[r.SKUID] = r.ProductID
+		}
+
+		items = append(items, model.PriceListItem{
+			ProductID:   r.ProductID,
+			SKUID:       r.SKUID,
+			MinQuantity: minQuantity,
+			Type:        r.Type,
+			Value:       r.Value,
+		})
+	}
+	if err := s.checkTargets(productIDs, skuProducts); err != nil {
+		return err
+	}
+
+	list.GroupID = req.GroupID
+	list.Name = req.Name
+	list.Currency = code
+	list.StartAt = req.StartAt
+	list.EndAt = req.EndAt
+	list.IsActive = req.IsActive
+	list.Items = items
+	return nil
+}
+
+// checkTargets 校验条目的商品和SKU存在且SKU属于对应商品
+func (s *Service) checkTargets(productIDs map[uint]bool, skuProducts map[uint]uint) error {
+	ids := make([]uint, 0, len(productIDs))
+	for id := range productIDs {
+		ids = append(ids, id)
+	}
+	var count int64
+	if err := s.db.Model(&model.Product{}).Where("id IN ?", ids).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询商品失败: %v", err)
+	}
+	if int(count) != len(ids) {
+		return fmt.Errorf("%w: 商品不存在", model.ErrInvalidPriceList)
+	}
+	if len(skuProducts) == 0 {
+		return nil
+	}
+
+	skuIDs := make([]uint, 0, len(skuProducts))
+	for id := range skuProducts {
+		skuIDs = append(skuIDs, id)
+	}
+	var skus []model.ProductSKU
+	if err := s.db.Select("id", "product_id").Where("id IN ?", skuIDs).Find(&skus).Error; err != nil {
+		return fmt.Errorf("查询SKU失败: %v", err)
+	}
+	if len(skus) != len(skuIDs) {
+		return fmt.Errorf("%w: SKU不存在", model.ErrInvalidPriceList)
+	}
+	for _, sku := range skus {
+		if skuProducts[sku.ID] != sku.ProductID {
+			return fmt.Errorf("%w: SKU %d 不属于商品 %d", model.ErrInvalidPriceList, sku.ID, skuProducts[sku.ID])
+		}
+	}
+	return nil
+}
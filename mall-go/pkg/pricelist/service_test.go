@@ -0,0 +1,170 @@
+package pricelist
+
+import (
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// PriceListServiceTestSuite 客户分组价目表服务测试套件
+type PriceListServiceTestSuite struct {
+	suite.Suite
+	service *Service
+	product *model.Product
+	sku     *model.ProductSKU
+}
+
+// SetupTest 每个用例使用独立的内存数据库和测试商品规格
+func (suite *PriceListServiceTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.AutoMigrate(&model.Product{}, &model.ProductSKU{}, &model.Currency{}, &model.ExchangeRate{},
+		&model.CustomerGroup{}, &model.CustomerGroupMember{}, &model.PriceList{}, &model.PriceListItem{}))
+
+	suite.product = &model.Product{Name: "打印纸", Price: decimal.NewFromInt(100), Stock: 1000, Status: model.ProductStatusActive}
+	suite.Require().NoError(db.Create(suite.product).Error)
+	suite.sku = &model.ProductSKU{ProductID: suite.product.ID, SKUCode: "A4-500", Name: "A4 500张", Price: decimal.NewFromInt(120), Stock: 1000}
+	suite.Require().NoError(db.Create(suite.sku).Error)
+
+	suite.service = NewService(db)
+}
+
+func (suite *PriceListServiceTestSuite) TestService_ResolvesBestListByQuantityTier() {
+	wholesale, err := suite.service.CreateGroup(&model.CustomerGroupRequest{Code: "wholesale", Name: "批发商", IsActive: true}, 1)
+	suite.Require().NoError(err)
+	partner, err := suite.service.CreateGroup(&model.CustomerGroupRequest{Code: "partner", Name: "合作伙伴", IsActive: true}, 1)
+	suite.Require().NoError(err)
+	_, err = suite.service.CreateGroup(&model.CustomerGroupRequest{Code: "wholesale", Name: "重复"}, 1)
+	suite.ErrorIs(err, model.ErrCustomerGroupDuplicate)
+
+	added, err := suite.service.AddMembers(wholesale.ID, []uint{7, 8, 7}, 1)
+	suite.Require().NoError(err)
+	suite.Equal(2, added)
+	added, err = suite.service.AddMembers(wholesale.ID, []uint{7}, 1)
+	suite.Require().NoError(err)
+	suite.Zero(added)
+	_, err = suite.service.AddMembers(partner.ID, []uint{7}, 1)
+	suite.Require().NoError(err)
+
+	// 批发价：1-9件公开价9折，10-99件85元，100件以上80元；SKU单独配置
+	_, err = suite.service.CreatePriceList(&model.PriceListRequest{GroupID: wholesale.ID, Name: "批发价", IsActive: true,
+		Items: []model.PriceListItemRequest{
+			{ProductID: suite.product.ID, Type: model.PriceAdjustmentPercentage, Value: decimal.NewFromInt(-10)},
+			{ProductID: suite.product.ID, MinQuantity: 10, Type: model.PriceAdjustmentFixed, Value: decimal.NewFromInt(85)},
+			{ProductID: suite.product.ID, MinQuantity: 100, Type: model.PriceAdjustmentFixed, Value: decimal.NewFromInt(80)},
+			{ProductID: suite.product.ID, SKUID: suite.sku.ID, MinQuantity: 1, Type: model.PriceAdjustmentFixed, Value: decimal.NewFromInt(110)},
+		}}, 1)
+	suite.Require().NoError(err)
+	// 合作伙伴价以美元计价，按汇率换算后在50件以上更低
+	suite.Require().NoError(suite.service.db.Create(&model.Currency{Code: "USD", Name: "美元", Symbol: "$", Decimals: 2, IsEnabled: true}).Error)
+	_, err = suite.service.currencyService.SetRate("USD", decimal.NewFromFloat(0.125), time.Now().Add(-time.Hour), "manual", 1)
+	suite.Require().NoError(err)
+	partnerList, err := suite.service.CreatePriceList(&model.PriceListRequest{GroupID: partner.ID, Name: "合作伙伴价", Currency: "usd", IsActive: true,
+		Items: []model.PriceListItemRequest{
+			{ProductID: suite.product.ID, MinQuantity: 50, Type: model.PriceAdjustmentFixed, Value: decimal.NewFromFloat(10.25)},
+		}}, 1)
+	suite.Require().NoError(err)
+	suite.Equal("USD", partnerList.Currency)
+
+	prices, err := suite.service.Resolve(7, []Line{
+		{ProductID: suite.product.ID, Quantity: 1, BasePrice: decimal.NewFromInt(100)},
+		{ProductID: suite.product.ID, Quantity: 10, BasePrice: decimal.NewFromInt(100)},
+		{ProductID: suite.product.ID, Quantity: 60, BasePrice: decimal.NewFromInt(100)},
+		{ProductID: suite.product.ID, Quantity: 100, BasePrice: decimal.NewFromInt(100)},
+		{ProductID: suite.product.ID, SKUID: suite.sku.ID, Quantity: 100, BasePrice: decimal.NewFromInt(120)},
+	})
+	suite.Require().NoError(err)
+	suite.True(prices[0].Price.Equal(decimal.NewFromInt(90)), "price %s", prices[0].Price)
+	suite.True(prices[1].Price.Equal(decimal.NewFromInt(85)))
+	suite.True(prices[2].Price.Equal(decimal.NewFromInt(82)), "price %s", prices[2].Price)
+	suite.Equal(partnerList.ID, prices[2].PriceListID)
+	suite.True(prices[3].Price.Equal(decimal.NewFromInt(80)))
+	// SKU配置了条目时不使用商品条目，但其他分组的商品条目仍参与比较
+	suite.True(prices[4].Price.Equal(decimal.NewFromInt(82)))
+
+	tiers, err := suite.service.PriceWithTiers(8, suite.product.ID, 0, decimal.NewFromInt(100))
+	suite.Require().NoError(err)
+	suite.True(tiers.Price.Equal(decimal.NewFromInt(90)))
+	suite.Require().Len(tiers.Tiers, 3)
+	suite.Equal([]int{1, 10, 100}, []int{tiers.Tiers[0].MinQuantity, tiers.Tiers[1].MinQuantity, tiers.Tiers[2].MinQuantity})
+
+	// 不在分组中的用户和游客按公开售价
+	prices, err = suite.service.Resolve(9, []Line{{ProductID: suite.product.ID, Quantity: 100, BasePrice: decimal.NewFromInt(100)}})
+	suite.Require().NoError(err)
+	suite.Nil(prices[0])
+	tiers, err = suite.service.PriceWithTiers(0, suite.product.ID, 0, decimal.NewFromInt(100))
+	suite.Require().NoError(err)
+	suite.Nil(tiers)
+
+	// 移出分组或分组停用后不再适用
+	suite.Require().NoError(suite.service.RemoveMember(partner.ID, 7, 1))
+	_, err = suite.service.UpdateGroup(wholesale.ID, &model.CustomerGroupRequest{Code: "wholesale", Name: "批发商"}, 1)
+	suite.Require().NoError(err)
+	items := []model.CartItem{{ProductID: suite.product.ID, Quantity: 60, Price: decimal.NewFromInt(100)}}
+	prices, err = suite.service.ApplyToCartItems(7, items)
+	suite.Require().NoError(err)
+	suite.Nil(prices[0])
+	suite.True(items[0].Price.Equal(decimal.NewFromInt(100)))
+}
+
+func (suite *PriceListServiceTestSuite) TestService_PriceListValidityAndValidation() {
+	group, err := suite.service.CreateGroup(&model.CustomerGroupRequest{Code: "b2b", Name: "企业客户", IsActive: true}, 1)
+	suite.Require().NoError(err)
+	_, err = suite.service.AddMembers(group.ID, []uint{7}, 1)
+	suite.Require().NoError(err)
+
+	other := &model.Product{Name: "订书机", Price: decimal.NewFromInt(30), Stock: 10, Status: model.ProductStatusActive}
+	suite.Require().NoError(suite.service.db.Create(other).Error)
+
+	item := model.PriceListItemRequest{ProductID: suite.product.ID, Type: model.PriceAdjustmentFixed, Value: decimal.NewFromInt(70)}
+	future := time.Now().Add(time.Hour)
+	list, err := suite.service.CreatePriceList(&model.PriceListRequest{GroupID: group.ID, Name: "下月价格", StartAt: &future,
+		IsActive: true, Items: []model.PriceListItemRequest{item}}, 1)
+	suite.Require().NoError(err)
+
+	// 未到开始时间不生效
+	prices, err := suite.service.Resolve(7, []Line{{ProductID: suite.product.ID, Quantity: 1, BasePrice: decimal.NewFromInt(100)}})
+	suite.Require().NoError(err)
+	suite.Nil(prices[0])
+
+	past := time.Now().Add(-time.Hour)
+	updated, err := suite.service.UpdatePriceList(list.ID, &model.PriceListRequest{GroupID: group.ID, Name: "本月价格", StartAt: &past,
+		IsActive: true, Items: []model.PriceListItemRequest{item, {ProductID: suite.product.ID, MinQuantity: 10, Type: model.PriceAdjustmentFixed, Value: decimal.NewFromInt(60)}}}, 1)
+	suite.Require().NoError(err)
+	suite.Len(updated.Items, 2)
+	prices, err = suite.service.Resolve(7, []Line{{ProductID: suite.product.ID, Quantity: 1, BasePrice: decimal.NewFromInt(100)}})
+	suite.Require().NoError(err)
+	suite.True(prices[0].Price.Equal(decimal.NewFromInt(70)))
+
+	invalid := []model.PriceListRequest{
+		{GroupID: group.ID, Name: "币种", Currency: "XXX", Items: []model.PriceListItemRequest{item}},
+		{GroupID: group.ID, Name: "时间", StartAt: &future, EndAt: &past, Items: []model.PriceListItemRequest{item}},
+		{GroupID: group.ID, Name: "重复档位", Items: []model.PriceListItemRequest{item, item}},
+		{GroupID: group.ID, Name: "百分比", Items: []model.PriceListItemRequest{{ProductID: suite.product.ID, Type: model.PriceAdjustmentPercentage, Value: decimal.NewFromInt(-100)}}},
+		{GroupID: group.ID, Name: "商品", Items: []model.PriceListItemRequest{{ProductID: 999, Type: model.PriceAdjustmentFixed, Value: decimal.NewFromInt(1)}}},
+		{GroupID: group.ID, Name: "规格", Items: []model.PriceListItemRequest{{ProductID: other.ID, SKUID: suite.sku.ID, Type: model.PriceAdjustmentFixed, Value: decimal.NewFromInt(1)}}},
+	}
+	for _, req := range invalid {
+		req := req
+		_, err := suite.service.CreatePriceList(&req, 1)
+		suite.ErrorIs(err, model.ErrInvalidPriceList, req.Name)
+	}
+	_, err = suite.service.CreatePriceList(&model.PriceListRequest{GroupID: 99, Name: "分组", Items: []model.PriceListItemRequest{item}}, 1)
+	suite.ErrorIs(err, model.ErrCustomerGroupNotFound)
+
+	suite.Require().NoError(suite.service.DeletePriceList(list.ID, 1))
+	suite.ErrorIs(suite.service.DeletePriceList(list.ID, 1), model.ErrPriceListNotFound)
+	var count int64
+	suite.Require().NoError(suite.service.db.Model(&model.PriceListItem{}).Count(&count).Error)
+	suite.Zero(count)
+}
+
+func TestPriceListServiceSuite(t *testing.T) {
+	suite.Run(t, new(PriceListServiceTestSuite))
+}
@@ -11,6 +11,7 @@ import (
 
 // InventoryService 库存管理服务
 type InventoryService struct {
+	changeListeners
 	db *gorm.DB
 }
 
@@ -164,6 +165,7 @@ func (is *InventoryService) AdjustStock(req *StockAdjustRequest) error {
 	}
 
 	tx.Commit()
+	is.stockChanged(req.ProductID, req.SKUID, beforeQty, afterQty)
 	return nil
 }
 
@@ -212,6 +214,7 @@ func (is *InventoryService) StockIn(req *StockInRequest) error {
 	}
 
 	tx.Commit()
+	is.stockChanged(req.ProductID, req.SKUID, beforeQty, afterQty)
 	return nil
 }
 
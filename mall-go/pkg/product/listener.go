@@ -0,0 +1,42 @@
+package product
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// ChangeListener 商品价格、库存变化监听，变更提交后同步回调
+// skuID 为0时表示商品本身的价格或库存
+type ChangeListener interface {
+	StockChanged(productID, skuID uint, before, after int)
+	PriceChanged(productID, skuID uint, before, after decimal.Decimal)
+}
+
+// changeListeners 服务内注册的变化监听
+type changeListeners struct {
+	listeners []ChangeListener
+}
+
+// AddChangeListener 注册价格、库存变化监听
+func (c *changeListeners) AddChangeListener(listener ChangeListener) {
+	c.listeners = append(c.listeners, listener)
+}
+
+// stockChanged 通知库存变化
+func (c *changeListeners) stockChanged(productID, skuID uint, before, after int) {
+	if before == after {
+		return
+	}
+	for _, listener := range c.listeners {
+		listener.StockChanged(productID, skuID, before, after)
+	}
+}
+
+// priceChanged 通知价格变化
+func (c *changeListeners) priceChanged(productID, skuID uint, before, after decimal.Decimal) {
+	if before.Equal(after) {
+		return
+	}
+	for _, listener := range c.listeners {
+		listener.PriceChanged(productID, skuID, before, after)
+	}
+}
@@ -0,0 +1,73 @@
+package product
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mall-go/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// PriceJob 定时调价任务，按间隔生效到点的调价并结束到期的限时价
+type PriceJob struct {
+	service *PriceService
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewPriceJob 创建并启动定时调价任务，启动时立即处理一次错过的调价
+func NewPriceJob(service *PriceService) *PriceJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &PriceJob{
+		service: service,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	job.wg.Add(1)
+	go job.run()
+
+	logger.Info("定时调价任务启动", zap.Duration("interval", service.options.Interval))
+
+	return job
+}
+
+// run 调价主循环
+func (j *PriceJob) run() {
+	defer j.wg.Done()
+
+	j.apply()
+
+	ticker := time.NewTicker(j.service.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+			j.apply()
+		}
+	}
+}
+
+// apply 执行一次调价并记录结果
+func (j *PriceJob) apply() {
+	activated, finished, err := j.service.Run()
+	if err != nil {
+		logger.Error("处理定时调价失败", zap.Error(err))
+	}
+	if activated > 0 || finished > 0 {
+		logger.Info("定时调价处理完成", zap.Int("activated", activated), zap.Int("finished", finished))
+	}
+}
+
+// Stop 停止定时调价任务
+func (j *PriceJob) Stop() {
+	logger.Info("停止定时调价任务")
+	j.cancel()
+	j.wg.Wait()
+}
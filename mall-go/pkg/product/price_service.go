@@ -0,0 +1,538 @@
+package product
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/pagination"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// PriceOptions 定时调价配置
+type PriceOptions struct {
+	Interval        time.Duration // 检查到期调价的间隔
+	BatchSize       int           // 每批处理的调价数量
+	LowestPriceDays int           // 最低价统计天数
+}
+
+// DefaultPriceOptions 默认定时调价配置
+func DefaultPriceOptions() PriceOptions {
+	return PriceOptions{
+		Interval:        time.Minute,
+		BatchSize:       200,
+		LowestPriceDays: 30,
+	}
+}
+
+// CacheInvalidator 商品价格相关缓存失效，定时调价生效或结束后调用
+type CacheInvalidator interface {
+	InvalidateProduct(productID uint)
+}
+
+// CacheInvalidatorFunc 函数形式的缓存失效
+type CacheInvalidatorFunc func(productID uint)
+
+// InvalidateProduct 调用函数本身
+func (f CacheInvalidatorFunc) InvalidateProduct(productID uint) {
+	f(productID)
+}
+
+// PriceService 定时调价与价格变更记录服务
+// 后台预先配置商品或SKU的调价，永久调价到点改价，限时价到点改价、结束时恢复原价；
+// 每次改价写入价格变更记录，据此计算近期最低价
+type PriceService struct {
+	changeListeners
+	db           *gorm.DB
+	options      PriceOptions
+	invalidators []CacheInvalidator
+	now          func() time.Time
+}
+
+// NewPriceService 创建定时调价服务
+func NewPriceService(db *gorm.DB, options PriceOptions) *PriceService {
+	return &PriceService{
+		db:      db,
+		options: options,
+		now:     time.Now,
+	}
+}
+
+// AddCacheInvalidator 注册价格变化后需要失效的缓存
+func (s *PriceService) AddCacheInvalidator(invalidator CacheInvalidator) {
+	s.invalidators = append(s.invalidators, invalidator)
+}
+
+// Schedule 创建定时调价，开始时间已到的立即生效
+func (s *PriceService) Schedule(req *model.PriceScheduleRequest, operatorID uint) (*model.PriceSchedule, error) {
+	if !req.Price.IsPositive() || (req.EndAt != nil && (!req.EndAt.After(req.StartAt) || !req.EndAt.After(s.now()))) {
+		return nil, model.ErrInvalidPriceSchedule
+	}
+	if _, err := currentPrice(s.db, req.ProductID, req.SKUID); err != nil {
+		return nil, err
+	}
+
+	schedule := &model.PriceSchedule{
+		ProductID: req.ProductID,
+		SKUID:     req.SKUID,
+		Price:     req.Price,
+		StartAt:   req.StartAt,
+		EndAt:     req.EndAt,
+		Status:    model.PriceSchedulePending,
+		Reason:    req.Reason,
+		CreatedBy: operatorID,
+	}
+
+	var existing []model.PriceSchedule
+	if err := s.db.Where("product_id = ? AND sku_id = ? AND status IN ?", req.ProductID, req.SKUID,
+		[]model.PriceScheduleStatus{model.PriceSchedulePending, model.PriceScheduleActive}).
+		Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("查询定时调价失败: %v", err)
+	}
+	for i := range existing {
+		if overlaps(schedule, &existing[i]) {
+			return nil, model.ErrPriceScheduleOverlap
+		}
+	}
+
+	if err := s.db.Create(schedule).Error; err != nil {
+		return nil, fmt.Errorf("创建定时调价失败: %v", err)
+	}
+	logger.Info("创建定时调价",
+		zap.Uint("schedule_id", schedule.ID),
+		zap.Uint("product_id", schedule.ProductID),
+		zap.Uint("sku_id", schedule.SKUID),
+		zap.String("price", schedule.Price.String()),
+		zap.Uint("operator_id", operatorID))
+
+	if !schedule.StartAt.After(s.now()) {
+		if err := s.activate(schedule); err != nil {
+			return nil, err
+		}
+	}
+	return schedule, nil
+}
+
+// Cancel 取消定时调价，生效中的限时价立即恢复原价
+func (s *PriceService) Cancel(id, operatorID uint) error {
+	var schedule model.PriceSchedule
+	if err := s.db.First(&schedule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrPriceScheduleNotFound
+		}
+		return fmt.Errorf("查询定时调价失败: %v", err)
+	}
+
+	switch schedule.Status {
+	case model.PriceSchedulePending:
+		now := s.now()
+		result := s.db.Model(&model.PriceSchedule{}).
+			Where("id = ? AND status = ?", id, model.PriceSchedulePending).
+			Updates(map[string]interface{}{
+				"status":       model.PriceScheduleCancelled,
+				"cancelled_by": operatorID,
+				"finished_at":  now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("取消定时调价失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrPriceScheduleFinished
+		}
+		return nil
+	case model.PriceScheduleActive:
+		finished, err := s.finish(&schedule, model.PriceScheduleCancelled, model.PriceChangeScheduleAbort, operatorID)
+		if err != nil {
+			return err
+		}
+		if !finished {
+			return model.ErrPriceScheduleFinished
+		}
+		return nil
+	default:
+		return model.ErrPriceScheduleFinished
+	}
+}
+
+// List 分页查询定时调价
+func (s *PriceService) List(query *model.PriceScheduleQuery) ([]model.PriceSchedule, int64, error) {
+	page, pageSize := pagination.Normalize(query.Page, query.PageSize)
+
+	db := s.db.Model(&model.PriceSchedule{})
+	if query.ProductID > 0 {
+		db = db.Where("product_id = ?", query.ProductID)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询定时调价失败: %v", err)
+	}
+	var schedules []model.PriceSchedule
+	if err := db.Order("start_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&schedules).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询定时调价失败: %v", err)
+	}
+	return schedules, total, nil
+}
+
+// History 分页查询商品或SKU的价格变更记录，新记录在前
+func (s *PriceService) History(productID uint, query *model.PriceHistoryQuery) ([]model.PriceHistory, int64, error) {
+	page, pageSize := pagination.Normalize(query.Page, query.PageSize)
+
+	db := s.db.Model(&model.PriceHistory{}).Where("product_id = ? AND sku_id = ?", productID, query.SKUID)
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询价格变更记录失败: %v", err)
+	}
+	var histories []model.PriceHistory
+	if err := db.Order("created_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&histories).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询价格变更记录失败: %v", err)
+	}
+	return histories, total, nil
+}
+
+// LowestPrice 计算商品或SKU当前价格生效前的近期最低价，作为划线原价等价格宣传的参考价
+// 统计期为最近一次变更前的若干天，不含当前价格本身，限时价期间参考价不会等于折扣价；无变更记录时价格未变化，取当前价格
+func (s *PriceService) LowestPrice(productID, skuID uint) (*model.LowestPriceView, error) {
+	current, err := currentPrice(s.db, productID, skuID)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &model.LowestPriceView{
+		ProductID:    productID,
+		SKUID:        skuID,
+		Days:         s.options.LowestPriceDays,
+		CurrentPrice: current,
+		LowestPrice:  current,
+	}
+
+	var latest model.PriceHistory
+	err = s.db.Where("product_id = ? AND sku_id = ?", productID, skuID).
+		Order("created_at DESC, id DESC").First(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return view, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询价格变更记录失败: %v", err)
+	}
+
+	since := latest.CreatedAt.AddDate(0, 0, -s.options.LowestPriceDays)
+	var histories []model.PriceHistory
+	if err := s.db.Where("product_id = ? AND sku_id = ? AND created_at >= ? AND created_at <= ? AND id <> ?",
+		productID, skuID, since, latest.CreatedAt, latest.ID).
+		Order("created_at, id").Find(&histories).Error; err != nil {
+		return nil, fmt.Errorf("查询价格变更记录失败: %v", err)
+	}
+
+	// 最近一次变更前的价格一直持续到当前价格生效，期内每次变更前后的价格都曾生效
+	lowest := latest.OldPrice
+	for _, history := range histories {
+		lowest = decimal.Min(lowest, history.OldPrice, history.NewPrice)
+	}
+	view.LowestPrice = lowest
+	view.Since = &since
+	return view, nil
+}
+
+// RecordChange 记录后台直接改价，用于不经过商品服务的改价入口
+func (s *PriceService) RecordChange(productID, skuID uint, before, after decimal.Decimal, operatorID uint) error {
+	return recordPriceChange(s.db, &model.PriceHistory{
+		ProductID:  productID,
+		SKUID:      skuID,
+		OldPrice:   before,
+		NewPrice:   after,
+		Source:     model.PriceChangeManual,
+		OperatorID: operatorID,
+	})
+}
+
+// Run 结束到期的限时价并生效到点的调价，返回生效和结束的数量
+// 先结束再生效，同一商品前一个限时价结束与下一个开始同时到点时，新限时价基于恢复后的价格
+func (s *PriceService) Run() (int, int, error) {
+	now := s.now()
+
+	finished := 0
+	err := s.drain(func() ([]model.PriceSchedule, error) {
+		var schedules []model.PriceSchedule
+		err := s.db.Where("status = ? AND end_at <= ?", model.PriceScheduleActive, now).
+			Order("end_at, id").Limit(s.options.BatchSize).Find(&schedules).Error
+		return schedules, err
+	}, func(schedule *model.PriceSchedule) (bool, error) {
+		return s.finish(schedule, model.PriceScheduleCompleted, model.PriceChangeScheduleEnd, 0)
+	}, &finished)
+	if err != nil {
+		return 0, finished, err
+	}
+
+	activated := 0
+	err = s.drain(func() ([]model.PriceSchedule, error) {
+		var schedules []model.PriceSchedule
+		err := s.db.Where("status = ? AND start_at <= ?", model.PriceSchedulePending, now).
+			Order("start_at, id").Limit(s.options.BatchSize).Find(&schedules).Error
+		return schedules, err
+	}, func(schedule *model.PriceSchedule) (bool, error) {
+		return true, s.activate(schedule)
+	}, &activated)
+	return activated, finished, err
+}
+
+// drain 分批处理到期调价直到没有剩余，单条失败记录日志后跳过，整批均失败时停止避免反复重试
+func (s *PriceService) drain(load func() ([]model.PriceSchedule, error), process func(*model.PriceSchedule) (bool, error), count *int) error {
+	for {
+		schedules, err := load()
+		if err != nil {
+			return fmt.Errorf("查询到期调价失败: %v", err)
+		}
+
+		succeeded := 0
+		for i := range schedules {
+			ok, err := process(&schedules[i])
+			if err != nil {
+				logger.Error("处理定时调价失败", zap.Uint("schedule_id", schedules[i].ID), zap.Error(err))
+				continue
+			}
+			succeeded++
+			if ok {
+				*count++
+			}
+		}
+
+		if len(schedules) < s.options.BatchSize || succeeded == 0 {
+			return nil
+		}
+	}
+}
+
+// activate 生效调价：永久调价改价后完成，限时价记录原价后进入生效中；已错过整个限时期的直接完成不改价
+func (s *PriceService) activate(schedule *model.PriceSchedule) error {
+	now := s.now()
+	if schedule.EndAt != nil && !schedule.EndAt.After(now) {
+		if err := s.db.Model(&model.PriceSchedule{}).
+			Where("id = ? AND status = ?", schedule.ID, model.PriceSchedulePending).
+			Updates(map[string]interface{}{"status": model.PriceScheduleCompleted, "finished_at": now}).Error; err != nil {
+			return fmt.Errorf("更新定时调价失败: %v", err)
+		}
+		logger.Warn("限时价已过期，未生效", zap.Uint("schedule_id", schedule.ID))
+		return nil
+	}
+
+	var before decimal.Decimal
+	applied := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		before, err = currentPrice(tx, schedule.ProductID, schedule.SKUID)
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"status":         model.PriceScheduleActive,
+			"original_price": before,
+			"activated_at":   now,
+		}
+		if schedule.EndAt == nil {
+			updates["status"] = model.PriceScheduleCompleted
+			updates["finished_at"] = now
+		}
+		result := tx.Model(&model.PriceSchedule{}).
+			Where("id = ? AND status = ?", schedule.ID, model.PriceSchedulePending).
+			Updates(updates)
+		if result.Error != nil {
+			return fmt.Errorf("更新定时调价失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := setPrice(tx, schedule.ProductID, schedule.SKUID, schedule.Price); err != nil {
+			return err
+		}
+		if err := recordPriceChange(tx, &model.PriceHistory{
+			ProductID:  schedule.ProductID,
+			SKUID:      schedule.SKUID,
+			OldPrice:   before,
+			NewPrice:   schedule.Price,
+			Source:     model.PriceChangeSchedule,
+			ScheduleID: schedule.ID,
+			OperatorID: schedule.CreatedBy,
+			Reason:     schedule.Reason,
+		}); err != nil {
+			return err
+		}
+		applied = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return nil
+	}
+
+	schedule.Status = model.PriceScheduleActive
+	if schedule.EndAt == nil {
+		schedule.Status = model.PriceScheduleCompleted
+	}
+	schedule.OriginalPrice = before
+	schedule.ActivatedAt = &now
+
+	logger.Info("定时调价生效",
+		zap.Uint("schedule_id", schedule.ID),
+		zap.Uint("product_id", schedule.ProductID),
+		zap.Uint("sku_id", schedule.SKUID),
+		zap.String("before", before.String()),
+		zap.String("after", schedule.Price.String()))
+	s.changed(schedule.ProductID, schedule.SKUID, before, schedule.Price)
+	return nil
+}
+
+// finish 结束生效中的限时价并恢复原价，期间价格被手动修改过的保留当前价格
+func (s *PriceService) finish(schedule *model.PriceSchedule, status model.PriceScheduleStatus, source model.PriceChangeSource, operatorID uint) (bool, error) {
+	now := s.now()
+	var before decimal.Decimal
+	claimed, restored := false, false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.PriceSchedule{}).
+			Where("id = ? AND status = ?", schedule.ID, model.PriceScheduleActive).
+			Updates(map[string]interface{}{
+				"status":       status,
+				"cancelled_by": operatorID,
+				"finished_at":  now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("更新定时调价失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		claimed = true
+
+		var err error
+		before, err = currentPrice(tx, schedule.ProductID, schedule.SKUID)
+		if err != nil {
+			if errors.Is(err, model.ErrPriceTargetNotFound) {
+				return nil
+			}
+			return err
+		}
+		if !before.Equal(schedule.Price) {
+			return nil
+		}
+
+		if err := setPrice(tx, schedule.ProductID, schedule.SKUID, schedule.OriginalPrice); err != nil {
+			return err
+		}
+		if err := recordPriceChange(tx, &model.PriceHistory{
+			ProductID:  schedule.ProductID,
+			SKUID:      schedule.SKUID,
+			OldPrice:   before,
+			NewPrice:   schedule.OriginalPrice,
+			Source:     source,
+			ScheduleID: schedule.ID,
+			OperatorID: operatorID,
+			Reason:     schedule.Reason,
+		}); err != nil {
+			return err
+		}
+		restored = true
+		return nil
+	})
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	schedule.Status = status
+	schedule.FinishedAt = &now
+	if !restored {
+		logger.Warn("限时价期间价格已被修改，结束时保留当前价格",
+			zap.Uint("schedule_id", schedule.ID),
+			zap.String("current", before.String()))
+		return true, nil
+	}
+
+	logger.Info("限时价结束，恢复原价",
+		zap.Uint("schedule_id", schedule.ID),
+		zap.Uint("product_id", schedule.ProductID),
+		zap.Uint("sku_id", schedule.SKUID),
+		zap.String("price", schedule.OriginalPrice.String()))
+	s.changed(schedule.ProductID, schedule.SKUID, before, schedule.OriginalPrice)
+	return true, nil
+}
+
+// changed 价格变化后失效缓存并通知监听
+func (s *PriceService) changed(productID, skuID uint, before, after decimal.Decimal) {
+	for _, invalidator := range s.invalidators {
+		invalidator.InvalidateProduct(productID)
+	}
+	s.priceChanged(productID, skuID, before, after)
+}
+
+// overlaps 两个调价是否冲突：限时价之间时间段不能重叠，永久调价不能落在限时价期间
+func overlaps(a, b *model.PriceSchedule) bool {
+	switch {
+	case a.EndAt == nil && b.EndAt == nil:
+		return false
+	case a.EndAt == nil:
+		return !a.StartAt.Before(b.StartAt) && a.StartAt.Before(*b.EndAt)
+	case b.EndAt == nil:
+		return !b.StartAt.Before(a.StartAt) && b.StartAt.Before(*a.EndAt)
+	default:
+		return a.StartAt.Before(*b.EndAt) && b.StartAt.Before(*a.EndAt)
+	}
+}
+
+// currentPrice 查询商品或SKU的当前价格
+func currentPrice(db *gorm.DB, productID, skuID uint) (decimal.Decimal, error) {
+	if skuID == 0 {
+		var product model.Product
+		if err := db.Select("id, price").First(&product, productID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return decimal.Zero, model.ErrPriceTargetNotFound
+			}
+			return decimal.Zero, fmt.Errorf("查询商品失败: %v", err)
+		}
+		return product.Price, nil
+	}
+
+	var sku model.ProductSKU
+	if err := db.Select("id, price").Where("id = ? AND product_id = ?", skuID, productID).First(&sku).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return decimal.Zero, model.ErrPriceTargetNotFound
+		}
+		return decimal.Zero, fmt.Errorf("查询商品规格失败: %v", err)
+	}
+	return sku.Price, nil
+}
+
+// setPrice 修改商品或SKU价格
+func setPrice(tx *gorm.DB, productID, skuID uint, price decimal.Decimal) error {
+	if skuID == 0 {
+		if err := tx.Model(&model.Product{}).Where("id = ?", productID).Update("price", price).Error; err != nil {
+			return fmt.Errorf("修改商品价格失败: %v", err)
+		}
+		return nil
+	}
+	if err := tx.Model(&model.ProductSKU{}).Where("id = ? AND product_id = ?", skuID, productID).Update("price", price).Error; err != nil {
+		return fmt.Errorf("修改商品规格价格失败: %v", err)
+	}
+	return nil
+}
+
+// recordPriceChange 写入价格变更记录，价格未变化时不记录
+func recordPriceChange(db *gorm.DB, history *model.PriceHistory) error {
+	if history.OldPrice.Equal(history.NewPrice) {
+		return nil
+	}
+	if err := db.Create(history).Error; err != nil {
+		return fmt.Errorf("记录价格变更失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package product
+
+import (
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPriceService_LowestPriceBeforeActiveDiscount(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.Product{}, &model.ProductSKU{}, &model.PriceSchedule{}, &model.PriceHistory{}))
+	priceService := NewPriceService(db, DefaultPriceOptions())
+
+	p := &model.Product{Name: "测试商品", Price: decimal.NewFromInt(100), Stock: 10, Status: model.ProductStatusActive}
+	require.NoError(t, db.Create(p).Error)
+	other := &model.Product{Name: "测试商品2", Price: decimal.NewFromInt(100), Stock: 10, Status: model.ProductStatusActive}
+	require.NoError(t, db.Create(other).Error)
+
+	// 无变更记录时参考价为当前价格
+	lowest, err := priceService.LowestPrice(p.ID, 0)
+	require.NoError(t, err)
+	assert.True(t, lowest.LowestPrice.Equal(decimal.NewFromInt(100)))
+	assert.Nil(t, lowest.Since)
+
+	// 第二个商品：40天前的90元已超出统计期，10天前曾短暂降到95元
+	now := time.Now()
+	for _, h := range []model.PriceHistory{
+		{OldPrice: decimal.NewFromInt(90), NewPrice: decimal.NewFromInt(100), CreatedAt: now.AddDate(0, 0, -40)},
+		{OldPrice: decimal.NewFromInt(100), NewPrice: decimal.NewFromInt(95), CreatedAt: now.AddDate(0, 0, -10)},
+		{OldPrice: decimal.NewFromInt(95), NewPrice: decimal.NewFromInt(100), CreatedAt: now.AddDate(0, 0, -5)},
+	} {
+		h.ProductID = other.ID
+		h.Source = model.PriceChangeManual
+		require.NoError(t, db.Create(&h).Error)
+	}
+
+	// 限时价生效期间，参考价取折扣生效前的最低价而不是折扣价本身
+	endAt := now.Add(time.Hour)
+	for _, id := range []uint{p.ID, other.ID} {
+		_, err := priceService.Schedule(&model.PriceScheduleRequest{
+			ProductID: id, Price: decimal.NewFromInt(80), StartAt: now.Add(-time.Minute), EndAt: &endAt,
+		}, 7)
+		require.NoError(t, err)
+	}
+
+	lowest, err = priceService.LowestPrice(p.ID, 0)
+	require.NoError(t, err)
+	assert.True(t, lowest.CurrentPrice.Equal(decimal.NewFromInt(80)))
+	assert.True(t, lowest.LowestPrice.Equal(decimal.NewFromInt(100)), "lowest %s", lowest.LowestPrice)
+	require.NotNil(t, lowest.Since)
+
+	lowest, err = priceService.LowestPrice(other.ID, 0)
+	require.NoError(t, err)
+	assert.True(t, lowest.LowestPrice.Equal(decimal.NewFromInt(95)), "lowest %s", lowest.LowestPrice)
+}
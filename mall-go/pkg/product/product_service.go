@@ -13,6 +13,7 @@ import (
 
 // ProductService 商品服务
 type ProductService struct {
+	changeListeners
 	db *gorm.DB
 }
 
@@ -76,6 +77,7 @@ type UpdateProductRequest struct {
 	Sort           int                       `json:"sort"`
 	Images         []string                  `json:"images"`
 	Attributes     []ProductAttributeRequest `json:"attributes"`
+	OperatorID     uint                      `json:"-"` // 操作人，记录价格变更
 }
 
 // ProductAttributeRequest 商品属性请求
@@ -242,6 +244,8 @@ func (ps *ProductService) UpdateProduct(id uint, req *UpdateProductRequest) (*mo
 		}
 	}()
 
+	beforePrice, beforeStock := product.Price, product.Stock
+
 	// 更新商品基本信息
 	product.Name = req.Name
 	product.SubTitle = req.SubTitle
@@ -274,6 +278,16 @@ func (ps *ProductService) UpdateProduct(id uint, req *UpdateProductRequest) (*mo
 		tx.Rollback()
 		return nil, fmt.Errorf("更新商品失败: %v", err)
 	}
+	if err := recordPriceChange(tx, &model.PriceHistory{
+		ProductID:  product.ID,
+		OldPrice:   beforePrice,
+		NewPrice:   product.Price,
+		Source:     model.PriceChangeManual,
+		OperatorID: req.OperatorID,
+	}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 
 	// 更新商品图片
 	if len(req.Images) > 0 {
@@ -322,6 +336,8 @@ func (ps *ProductService) UpdateProduct(id uint, req *UpdateProductRequest) (*mo
 	}
 
 	tx.Commit()
+	ps.priceChanged(product.ID, 0, beforePrice, product.Price)
+	ps.stockChanged(product.ID, 0, beforeStock, product.Stock)
 
 	// 重新查询商品（包含关联数据）
 	return ps.GetProduct(product.ID)
@@ -554,9 +570,11 @@ func (ps *ProductService) UpdateProductStock(id uint, stock int) error {
 		return fmt.Errorf("库存不能为负数")
 	}
 
+	beforeStock := product.Stock
 	if err := ps.db.Model(&product).Update("stock", stock).Error; err != nil {
 		return fmt.Errorf("更新商品库存失败: %v", err)
 	}
+	ps.stockChanged(product.ID, 0, beforeStock, stock)
 
 	return nil
 }
@@ -1,6 +1,7 @@
 package product
 
 import (
+	"fmt"
 	"testing"
 
 	"mall-go/internal/model"
@@ -324,11 +325,11 @@ func (suite *ProductServiceTestSuite) TestProductService_GetHotProducts() {
 		MerchantID: 1,
 		Price:      decimal.NewFromFloat(120.00),
 		Stock:      50,
-		IsHot:      true,
 	}
 
 	product, err := suite.productService.CreateProduct(createReq)
 	suite.NoError(err)
+	suite.NoError(suite.db.Model(product).Update("is_hot", true).Error)
 
 	// 设置为上架状态
 	suite.productService.UpdateProductStatus(product.ID, model.ProductStatusActive)
@@ -12,6 +12,7 @@ import (
 
 // SKUService 商品SKU服务
 type SKUService struct {
+	changeListeners
 	db *gorm.DB
 }
 
@@ -45,6 +46,7 @@ type UpdateSKURequest struct {
 	Volume     decimal.Decimal        `json:"volume"`
 	Status     string                 `json:"status"`
 	Attributes map[string]interface{} `json:"attributes"`
+	OperatorID uint                   `json:"-"` // 操作人，记录价格变更
 }
 
 // SKUListRequest SKU列表请求
@@ -128,6 +130,8 @@ func (ss *SKUService) UpdateSKU(id uint, req *UpdateSKURequest) (*model.ProductS
 		return nil, fmt.Errorf("属性序列化失败: %v", err)
 	}
 
+	beforePrice, beforeStock := sku.Price, sku.Stock
+
 	// 更新SKU信息
 	sku.Name = req.Name
 	sku.Price = req.Price
@@ -141,9 +145,24 @@ func (ss *SKUService) UpdateSKU(id uint, req *UpdateSKURequest) (*model.ProductS
 		sku.Status = req.Status
 	}
 
-	if err := ss.db.Save(&sku).Error; err != nil {
-		return nil, fmt.Errorf("更新SKU失败: %v", err)
+	err = ss.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&sku).Error; err != nil {
+			return fmt.Errorf("更新SKU失败: %v", err)
+		}
+		return recordPriceChange(tx, &model.PriceHistory{
+			ProductID:  sku.ProductID,
+			SKUID:      sku.ID,
+			OldPrice:   beforePrice,
+			NewPrice:   sku.Price,
+			Source:     model.PriceChangeManual,
+			OperatorID: req.OperatorID,
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
+	ss.priceChanged(sku.ProductID, sku.ID, beforePrice, sku.Price)
+	ss.stockChanged(sku.ProductID, sku.ID, beforeStock, sku.Stock)
 
 	return &sku, nil
 }
@@ -336,9 +355,11 @@ func (ss *SKUService) UpdateSKUStock(id uint, stock int) error {
 		return fmt.Errorf("库存不能为负数")
 	}
 
+	beforeStock := sku.Stock
 	if err := ss.db.Model(&sku).Update("stock", stock).Error; err != nil {
 		return fmt.Errorf("更新SKU库存失败: %v", err)
 	}
+	ss.stockChanged(sku.ProductID, sku.ID, beforeStock, stock)
 
 	return nil
 }
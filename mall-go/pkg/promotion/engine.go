@@ -0,0 +1,526 @@
+package promotion
+
+import (
+	"fmt"
+	"sort"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// Line 参与促销计算的商品行
+type Line struct {
+	ProductID uint            // 商品ID
+	SKUID     uint            // SKU ID
+	Price     decimal.Decimal // 单价
+	Quantity  int             // 数量
+}
+
+// Explanation 促销优惠说明
+type Explanation struct {
+	PromotionID uint                `json:"promotion_id"`
+	Name        string              `json:"name"`
+	Type        model.PromotionType `json:"type"`
+	Discount    decimal.Decimal     `json:"discount"` // 优惠金额，满赠为0
+	Message     string              `json:"message"`
+}
+
+// LineResult 商品行的促销结果
+type LineResult struct {
+	ProductID    uint            `json:"product_id"`
+	SKUID        uint            `json:"sku_id"`
+	Quantity     int             `json:"quantity"`
+	Amount       decimal.Decimal `json:"amount"`       // 原价小计
+	Discount     decimal.Decimal `json:"discount"`     // 促销优惠
+	PayAmount    decimal.Decimal `json:"pay_amount"`   // 优惠后金额
+	Explanations []Explanation   `json:"explanations"` // 命中的促销及分摊到本行的优惠
+}
+
+// Gift 满赠赠品
+type Gift struct {
+	PromotionID uint   `json:"promotion_id"`
+	ProductID   uint   `json:"product_id"`
+	SKUID       uint   `json:"sku_id"`
+	ProductName string `json:"product_name"`
+	Quantity    int    `json:"quantity"`
+}
+
+// Suggestion 凑单建议
+type Suggestion struct {
+	PromotionID  uint                `json:"promotion_id"`
+	Name         string              `json:"name"`
+	Type         model.PromotionType `json:"type"`
+	NeedAmount   decimal.Decimal     `json:"need_amount"`   // 还差的金额
+	NeedQuantity int                 `json:"need_quantity"` // 还差的件数
+	Message      string              `json:"message"`
+}
+
+// Result 促销计算结果
+type Result struct {
+	TotalAmount decimal.Decimal `json:"total_amount"` // 原价合计
+	Discount    decimal.Decimal `json:"discount"`     // 促销优惠合计
+	PayAmount   decimal.Decimal `json:"pay_amount"`   // 优惠后合计
+	Lines       []LineResult    `json:"lines"`        // 与输入顺序一致
+	Applied     []Explanation   `json:"applied"`      // 命中的促销及其优惠合计
+	Gifts       []Gift          `json:"gifts"`
+	Suggestions []Suggestion    `json:"suggestions"`
+}
+
+// productMeta 判断适用范围所需的商品信息
+type productMeta struct {
+	CategoryID uint
+	BrandID    uint
+	MerchantID uint
+}
+
+// lineState 计算过程中商品行的状态
+type lineState struct {
+	meta      productMeta
+	remaining decimal.Decimal // 扣除已命中促销后的金额
+	groups    map[string]bool // 已命中的叠加分组
+	promoted  bool            // 是否已命中任一促销
+	locked    bool            // 已命中互斥促销
+}
+
+var (
+	hundred = decimal.NewFromInt(100)
+	half    = decimal.NewFromFloat(0.5)
+)
+
+// Evaluate 按当前生效的促销规则计算商品行的优惠
+// 规则按优先级从高到低依次在各行剩余金额上计算：互斥规则只作用于尚未命中促销的商品，命中后不再参与其他规则；
+// 同一叠加分组内的规则对同一商品只生效一个。整单类优惠（满减、折扣、打包价）按金额比例分摊到各行。
+func (s *Service) Evaluate(lines []Line) (*Result, error) {
+	result := &Result{
+		TotalAmount: decimal.Zero,
+		Discount:    decimal.Zero,
+		PayAmount:   decimal.Zero,
+		Lines:       make([]LineResult, len(lines)),
+		Applied:     []Explanation{},
+		Gifts:       []Gift{},
+		Suggestions: []Suggestion{},
+	}
+	states := make([]*lineState, len(lines))
+	for i, line := range lines {
+		amount := line.Price.Mul(decimal.NewFromInt(int64(line.Quantity)))
+		result.Lines[i] = LineResult{
+			ProductID:    line.ProductID,
+			SKUID:        line.SKUID,
+			Quantity:     line.Quantity,
+			Amount:       amount,
+			Discount:     decimal.Zero,
+			PayAmount:    amount,
+			Explanations: []Explanation{},
+		}
+		states[i] = &lineState{remaining: amount, groups: make(map[string]bool)}
+		result.TotalAmount = result.TotalAmount.Add(amount)
+	}
+	result.PayAmount = result.TotalAmount
+	if len(lines) == 0 {
+		return result, nil
+	}
+
+	promotions, err := s.running(s.now())
+	if err != nil {
+		return nil, err
+	}
+	if len(promotions) == 0 {
+		return result, nil
+	}
+	if err := s.loadMeta(lines, states); err != nil {
+		return nil, err
+	}
+
+	for i := range promotions {
+		promotion := &promotions[i]
+		eligible := eligibleLines(promotion, lines, states)
+		if len(eligible) == 0 {
+			continue
+		}
+
+		discounts, message, suggestion := evaluateRule(promotion, lines, states, eligible)
+		if suggestion != nil {
+			result.Suggestions = append(result.Suggestions, *suggestion)
+		}
+		if message == "" {
+			continue
+		}
+
+		if promotion.Type == model.PromotionFreeGift {
+			gift, err := s.gift(promotion, states, eligible)
+			if err != nil {
+				return nil, err
+			}
+			result.Gifts = append(result.Gifts, gift)
+			message = fmt.Sprintf("%s，赠%s×%d", message, gift.ProductName, gift.Quantity)
+		}
+
+		total := decimal.Zero
+		for _, idx := range eligible {
+			discount, ok := discounts[idx]
+			if !ok {
+				continue
+			}
+			state := states[idx]
+			state.remaining = state.remaining.Sub(discount)
+			state.promoted = true
+			state.locked = state.locked || promotion.Exclusive
+			if promotion.StackGroup != "" {
+				state.groups[promotion.StackGroup] = true
+			}
+
+			lineResult := &result.Lines[idx]
+			lineResult.Discount = lineResult.Discount.Add(discount)
+			lineResult.PayAmount = state.remaining
+			lineResult.Explanations = append(lineResult.Explanations, explain(promotion, discount, message))
+			total = total.Add(discount)
+		}
+		result.Discount = result.Discount.Add(total)
+		result.Applied = append(result.Applied, explain(promotion, total, message))
+	}
+
+	result.PayAmount = result.TotalAmount.Sub(result.Discount)
+	return result, nil
+}
+
+// loadMeta 加载商品的分类、品牌、商家
+func (s *Service) loadMeta(lines []Line, states []*lineState) error {
+	ids := make([]uint, 0, len(lines))
+	for _, line := range lines {
+		ids = append(ids, line.ProductID)
+	}
+	var products []model.Product
+	if err := s.db.Select("id", "category_id", "brand_id", "merchant_id").Where("id IN ?", ids).Find(&products).Error; err != nil {
+		return fmt.Errorf("查询促销商品失败: %v", err)
+	}
+	metas := make(map[uint]productMeta, len(products))
+	for _, product := range products {
+		metas[product.ID] = productMeta{CategoryID: product.CategoryID, BrandID: product.BrandID, MerchantID: product.MerchantID}
+	}
+	for i, line := range lines {
+		states[i].meta = metas[line.ProductID]
+	}
+	return nil
+}
+
+// gift 生成满赠赠品
+func (s *Service) gift(promotion *model.Promotion, states []*lineState, eligible []int) (Gift, error) {
+	quantity := promotion.GiftQuantity
+	if promotion.Repeatable && promotion.Threshold.IsPositive() {
+		times := sumRemaining(states, eligible).Div(promotion.Threshold).IntPart()
+		quantity *= int(times)
+	}
+
+	var product model.Product
+	if err := s.db.Select("id", "name").Where("id = ?", promotion.GiftProductID).Limit(1).Find(&product).Error; err != nil {
+		return Gift{}, fmt.Errorf("查询赠品失败: %v", err)
+	}
+	name := product.Name
+	if name == "" {
+		name = "赠品"
+	}
+	return Gift{
+		PromotionID: promotion.ID,
+		ProductID:   promotion.GiftProductID,
+		SKUID:       promotion.GiftSKUID,
+		ProductName: name,
+		Quantity:    quantity,
+	}, nil
+}
+
+// eligibleLines 筛选可参与该促销的商品行
+func eligibleLines(promotion *model.Promotion, lines []Line, states []*lineState) []int {
+	var scopeIDs map[uint]bool
+	if promotion.ScopeType != model.PromotionScopeAll {
+		scopeIDs = make(map[uint]bool)
+		for _, id := range promotion.ScopeIDList() {
+			scopeIDs[id] = true
+		}
+	}
+
+	var eligible []int
+	for i, line := range lines {
+		state := states[i]
+		if line.Quantity <= 0 || !state.remaining.IsPositive() || state.locked {
+			continue
+		}
+		if promotion.Exclusive && state.promoted {
+			continue
+		}
+		if promotion.StackGroup != "" && state.groups[promotion.StackGroup] {
+			continue
+		}
+		if !inScope(promotion.ScopeType, scopeIDs, line.ProductID, state.meta) {
+			continue
+		}
+		eligible = append(eligible, i)
+	}
+	return eligible
+}
+
+// inScope 商品是否在促销适用范围内
+func inScope(scope model.PromotionScope, ids map[uint]bool, productID uint, meta productMeta) bool {
+	switch scope {
+	case model.PromotionScopeAll:
+		return true
+	case model.PromotionScopeProduct:
+		return ids[productID]
+	case model.PromotionScopeCategory:
+		return ids[meta.CategoryID]
+	case model.PromotionScopeBrand:
+		return ids[meta.BrandID]
+	case model.PromotionScopeMerchant:
+		return ids[meta.MerchantID]
+	}
+	return false
+}
+
+// evaluateRule 计算单条促销在各商品行上的优惠
+// 返回的 message 为空表示未命中；未达门槛时返回凑单建议
+func evaluateRule(promotion *model.Promotion, lines []Line, states []*lineState, eligible []int) (map[int]decimal.Decimal, string, *Suggestion) {
+	discounts := make(map[int]decimal.Decimal)
+	base := sumRemaining(states, eligible)
+
+	switch promotion.Type {
+	case model.PromotionFullReduction:
+		if base.LessThan(promotion.Threshold) {
+			return nil, "", amountSuggestion(promotion, promotion.Threshold.Sub(base),
+				fmt.Sprintf("再买%s元可享%s", promotion.Threshold.Sub(base).StringFixed(2), promotion.Name))
+		}
+		times := decimal.NewFromInt(1)
+		if promotion.Repeatable {
+			times = decimal.NewFromInt(base.Div(promotion.Threshold).IntPart())
+		}
+		discount := capDiscount(promotion, promotion.Discount.Mul(times), base)
+		allocate(discounts, states, eligible, discount)
+		return discounts, fmt.Sprintf("满%s减%s", promotion.Threshold.StringFixed(2), discount.StringFixed(2)), nil
+
+	case model.PromotionPercentage:
+		if base.LessThan(promotion.Threshold) {
+			return nil, "", amountSuggestion(promotion, promotion.Threshold.Sub(base),
+				fmt.Sprintf("再买%s元可享%s", promotion.Threshold.Sub(base).StringFixed(2), promotion.Name))
+		}
+		discount := capDiscount(promotion, base.Mul(promotion.Discount).Round(2), base)
+		allocate(discounts, states, eligible, discount)
+		return discounts, fmt.Sprintf("优惠%s%%，减%s", promotion.Discount.Mul(hundred).String(), discount.StringFixed(2)), nil
+
+	case model.PromotionNForM:
+		maxQuantity := 0
+		for _, idx := range eligible {
+			quantity := lines[idx].Quantity
+			if quantity > maxQuantity {
+				maxQuantity = quantity
+			}
+			sets := quantity / promotion.BuyQuantity
+			if sets == 0 {
+				continue
+			}
+			freeUnits := decimal.NewFromInt(int64(sets * (promotion.BuyQuantity - promotion.PayQuantity)))
+			discounts[idx] = unitPrice(states[idx], quantity).Mul(freeUnits).Round(2)
+		}
+		if len(discounts) == 0 {
+			need := promotion.BuyQuantity - maxQuantity
+			return nil, "", quantitySuggestion(promotion, need,
+				fmt.Sprintf("再买%d件可享%s", need, promotion.Name))
+		}
+		return discounts, fmt.Sprintf("买%d付%d", promotion.BuyQuantity, promotion.PayQuantity), nil
+
+	case model.PromotionSecondHalf:
+		rate := promotion.Discount
+		if !rate.IsPositive() {
+			rate = half
+		}
+		for _, idx := range eligible {
+			quantity := lines[idx].Quantity
+			if quantity < 2 {
+				continue
+			}
+			pairs := decimal.NewFromInt(int64(quantity / 2))
+			discounts[idx] = unitPrice(states[idx], quantity).Mul(pairs).Mul(rate).Round(2)
+		}
+		if len(discounts) == 0 {
+			return nil, "", quantitySuggestion(promotion, 1, fmt.Sprintf("再买1件可享%s", promotion.Name))
+		}
+		return discounts, fmt.Sprintf("第二件优惠%s%%", rate.Mul(hundred).String()), nil
+
+	case model.PromotionBundlePrice:
+		return evaluateBundle(promotion, lines, states, eligible)
+
+	case model.PromotionFreeGift:
+		if base.LessThan(promotion.Threshold) {
+			return nil, "", amountSuggestion(promotion, promotion.Threshold.Sub(base),
+				fmt.Sprintf("再买%s元可享%s", promotion.Threshold.Sub(base).StringFixed(2), promotion.Name))
+		}
+		for _, idx := range eligible {
+			discounts[idx] = decimal.Zero
+		}
+		return discounts, fmt.Sprintf("满%s赠", promotion.Threshold.StringFixed(2)), nil
+
+	case model.PromotionLimitedPrice:
+		for _, idx := range eligible {
+			quantity := lines[idx].Quantity
+			unit := unitPrice(states[idx], quantity)
+			if unit.LessThanOrEqual(promotion.Price) {
+				continue
+			}
+			discounts[idx] = unit.Sub(promotion.Price).Mul(decimal.NewFromInt(int64(quantity))).Round(2)
+		}
+		if len(discounts) == 0 {
+			return nil, "", nil
+		}
+		return discounts, fmt.Sprintf("限时价%s元", promotion.Price.StringFixed(2)), nil
+	}
+	return nil, "", nil
+}
+
+// evaluateBundle 任选打包价：优先将单价高的商品组成打包，优惠按各行参与打包的金额分摊
+func evaluateBundle(promotion *model.Promotion, lines []Line, states []*lineState, eligible []int) (map[int]decimal.Decimal, string, *Suggestion) {
+	ordered := append([]int(nil), eligible...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return unitPrice(states[ordered[i]], lines[ordered[i]].Quantity).
+			GreaterThan(unitPrice(states[ordered[j]], lines[ordered[j]].Quantity))
+	})
+
+	totalUnits := 0
+	for _, idx := range ordered {
+		totalUnits += lines[idx].Quantity
+	}
+	bundles := totalUnits / promotion.BuyQuantity
+	if bundles == 0 {
+		need := promotion.BuyQuantity - totalUnits
+		return nil, "", quantitySuggestion(promotion, need, fmt.Sprintf("再买%d件可享%s", need, promotion.Name))
+	}
+
+	// 参与打包的各行金额
+	units := bundles * promotion.BuyQuantity
+	bundled := make(map[int]decimal.Decimal)
+	original := decimal.Zero
+	for _, idx := range ordered {
+		if units == 0 {
+			break
+		}
+		quantity := lines[idx].Quantity
+		take := quantity
+		if take > units {
+			take = units
+		}
+		units -= take
+		amount := states[idx].remaining
+		if take < quantity {
+			amount = unitPrice(states[idx], quantity).Mul(decimal.NewFromInt(int64(take))).Round(2)
+		}
+		bundled[idx] = amount
+		original = original.Add(amount)
+	}
+
+	discount := original.Sub(promotion.Price.Mul(decimal.NewFromInt(int64(bundles))))
+	if !discount.IsPositive() {
+		return nil, "", nil
+	}
+	discount = capDiscount(promotion, discount, original)
+
+	discounts := make(map[int]decimal.Decimal)
+	shares := proportional(discount, ordered, bundled)
+	for idx, share := range shares {
+		discounts[idx] = decimal.Min(share, states[idx].remaining)
+	}
+	return discounts, fmt.Sprintf("%d件%s元", promotion.BuyQuantity, promotion.Price.StringFixed(2)), nil
+}
+
+// allocate 整单优惠按各行剩余金额比例分摊
+func allocate(discounts map[int]decimal.Decimal, states []*lineState, eligible []int, total decimal.Decimal) {
+	weights := make(map[int]decimal.Decimal, len(eligible))
+	for _, idx := range eligible {
+		weights[idx] = states[idx].remaining
+	}
+	for idx, share := range proportional(total, eligible, weights) {
+		discounts[idx] = decimal.Min(share, states[idx].remaining)
+	}
+}
+
+// proportional 按权重比例分摊金额，精确到分，尾差计入最后一行
+func proportional(total decimal.Decimal, order []int, weights map[int]decimal.Decimal) map[int]decimal.Decimal {
+	sum := decimal.Zero
+	var members []int
+	for _, idx := range order {
+		if weight, ok := weights[idx]; ok {
+			sum = sum.Add(weight)
+			members = append(members, idx)
+		}
+	}
+	shares := make(map[int]decimal.Decimal, len(members))
+	if !sum.IsPositive() {
+		return shares
+	}
+	allocated := decimal.Zero
+	for i, idx := range members {
+		if i == len(members)-1 {
+			shares[idx] = total.Sub(allocated)
+			break
+		}
+		share := total.Mul(weights[idx]).Div(sum).Round(2)
+		shares[idx] = share
+		allocated = allocated.Add(share)
+	}
+	return shares
+}
+
+// capDiscount 按最高优惠金额和可优惠金额封顶
+func capDiscount(promotion *model.Promotion, discount, base decimal.Decimal) decimal.Decimal {
+	if promotion.MaxDiscount.IsPositive() && discount.GreaterThan(promotion.MaxDiscount) {
+		discount = promotion.MaxDiscount
+	}
+	return decimal.Min(discount, base)
+}
+
+// sumRemaining 各行剩余金额合计
+func sumRemaining(states []*lineState, eligible []int) decimal.Decimal {
+	sum := decimal.Zero
+	for _, idx := range eligible {
+		sum = sum.Add(states[idx].remaining)
+	}
+	return sum
+}
+
+// unitPrice 商品行当前的单价
+func unitPrice(state *lineState, quantity int) decimal.Decimal {
+	if quantity <= 0 {
+		return decimal.Zero
+	}
+	return state.remaining.Div(decimal.NewFromInt(int64(quantity)))
+}
+
+// explain 生成促销说明
+func explain(promotion *model.Promotion, discount decimal.Decimal, message string) Explanation {
+	return Explanation{
+		PromotionID: promotion.ID,
+		Name:        promotion.Name,
+		Type:        promotion.Type,
+		Discount:    discount,
+		Message:     message,
+	}
+}
+
+// amountSuggestion 差额凑单建议
+func amountSuggestion(promotion *model.Promotion, need decimal.Decimal, message string) *Suggestion {
+	return &Suggestion{
+		PromotionID: promotion.ID,
+		Name:        promotion.Name,
+		Type:        promotion.Type,
+		NeedAmount:  need,
+		Message:     message,
+	}
+}
+
+// quantitySuggestion 差件数凑单建议
+func quantitySuggestion(promotion *model.Promotion, need int, message string) *Suggestion {
+	return &Suggestion{
+		PromotionID:  promotion.ID,
+		Name:         promotion.Name,
+		Type:         promotion.Type,
+		NeedAmount:   decimal.Zero,
+		NeedQuantity: need,
+		Message:      message,
+	}
+}
@@ -0,0 +1,241 @@
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/pagination"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Service 促销服务
+// 后台配置声明式的促销规则，由 Evaluate 统一计算购物车、下单预览和订单的促销优惠
+type Service struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+// NewService 创建促销服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+// Create 创建促销规则
+func (s *Service) Create(req *model.PromotionRequest, operatorID uint) (*model.Promotion, error) {
+	promotion := &model.Promotion{CreatedBy: operatorID}
+	if err := applyRequest(promotion, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(promotion).Error; err != nil {
+		return nil, fmt.Errorf("创建促销失败: %v", err)
+	}
+
+	logger.Info("创建促销",
+		zap.Uint("promotion_id", promotion.ID),
+		zap.String("type", string(promotion.Type)),
+		zap.Uint("operator_id", operatorID))
+	return promotion, nil
+}
+
+// Update 更新促销规则
+func (s *Service) Update(id uint, req *model.PromotionRequest, operatorID uint) (*model.Promotion, error) {
+	promotion, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyRequest(promotion, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(promotion).Error; err != nil {
+		return nil, fmt.Errorf("更新促销失败: %v", err)
+	}
+
+	logger.Info("更新促销", zap.Uint("promotion_id", id), zap.Uint("operator_id", operatorID))
+	return promotion, nil
+}
+
+// Get 查询促销规则
+func (s *Service) Get(id uint) (*model.Promotion, error) {
+	var promotion model.Promotion
+	if err := s.db.First(&promotion, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrPromotionNotFound
+		}
+		return nil, fmt.Errorf("查询促销失败: %v", err)
+	}
+	return &promotion, nil
+}
+
+// List 分页查询促销规则
+func (s *Service) List(query *model.PromotionQuery) ([]model.Promotion, int64, error) {
+	page, pageSize := pagination.Normalize(query.Page, query.PageSize)
+
+	db := s.db.Model(&model.Promotion{})
+	if query.Type != "" {
+		db = db.Where("type = ?", query.Type)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.Running {
+		now := s.now()
+		db = db.Where("status = ? AND start_at <= ? AND end_at > ?", model.PromotionStatusActive, now, now)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计促销失败: %v", err)
+	}
+	var promotions []model.Promotion
+	if err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&promotions).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询促销失败: %v", err)
+	}
+	return promotions, total, nil
+}
+
+// SetStatus 启用或停用促销
+func (s *Service) SetStatus(id uint, status model.PromotionStatus, operatorID uint) (*model.Promotion, error) {
+	promotion, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(promotion).Update("status", status).Error; err != nil {
+		return nil, fmt.Errorf("更新促销状态失败: %v", err)
+	}
+	promotion.Status = status
+
+	logger.Info("更新促销状态",
+		zap.Uint("promotion_id", id),
+		zap.String("status", string(status)),
+		zap.Uint("operator_id", operatorID))
+	return promotion, nil
+}
+
+// Delete 删除促销规则
+func (s *Service) Delete(id uint, operatorID uint) error {
+	result := s.db.Delete(&model.Promotion{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除促销失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrPromotionNotFound
+	}
+
+	logger.Info("删除促销", zap.Uint("promotion_id", id), zap.Uint("operator_id", operatorID))
+	return nil
+}
+
+// running 查询指定时间生效的促销，按优先级从高到低排列
+func (s *Service) running(now time.Time) ([]model.Promotion, error) {
+	var promotions []model.Promotion
+	if err := s.db.Where("status = ? AND start_at <= ? AND end_at > ?", model.PromotionStatusActive, now, now).
+		Order("priority DESC, id ASC").
+		Find(&promotions).Error; err != nil {
+		return nil, fmt.Errorf("查询生效促销失败: %v", err)
+	}
+	return promotions, nil
+}
+
+// applyRequest 校验请求并写入促销规则
+func applyRequest(promotion *model.Promotion, req *model.PromotionRequest) error {
+	if !req.Type.Valid() {
+		return fmt.Errorf("%w: 不支持的促销类型 %s", model.ErrInvalidPromotion, req.Type)
+	}
+	scope := req.ScopeType
+	if scope == "" {
+		scope = model.PromotionScopeAll
+	}
+	if !scope.Valid() {
+		return fmt.Errorf("%w: 不支持的适用范围 %s", model.ErrInvalidPromotion, scope)
+	}
+	if scope != model.PromotionScopeAll && len(req.ScopeIDs) == 0 {
+		return fmt.Errorf("%w: 未指定适用范围", model.ErrInvalidPromotion)
+	}
+	if !req.EndAt.After(req.StartAt) {
+		return model.ErrInvalidPromotionTime
+	}
+	status := req.Status
+	if status == "" {
+		status = model.PromotionStatusActive
+	}
+	if status != model.PromotionStatusActive && status != model.PromotionStatusInactive {
+		return fmt.Errorf("%w: 不支持的状态 %s", model.ErrInvalidPromotion, status)
+	}
+	if req.Threshold.IsNegative() || req.MaxDiscount.IsNegative() {
+		return fmt.Errorf("%w: 金额不能为负数", model.ErrInvalidPromotion)
+	}
+	if err := validateRule(req); err != nil {
+		return err
+	}
+
+	promotion.Name = req.Name
+	promotion.Description = req.Description
+	promotion.Type = req.Type
+	promotion.ScopeType = scope
+	promotion.SetScopeIDs(req.ScopeIDs)
+	if scope == model.PromotionScopeAll {
+		promotion.ScopeIDs = ""
+	}
+	promotion.Threshold = req.Threshold
+	promotion.Discount = req.Discount
+	promotion.MaxDiscount = req.MaxDiscount
+	promotion.Repeatable = req.Repeatable
+	promotion.BuyQuantity = req.BuyQuantity
+	promotion.PayQuantity = req.PayQuantity
+	promotion.Price = req.Price
+	promotion.GiftProductID = req.GiftProductID
+	promotion.GiftSKUID = req.GiftSKUID
+	promotion.GiftQuantity = req.GiftQuantity
+	promotion.Priority = req.Priority
+	promotion.Exclusive = req.Exclusive
+	promotion.StackGroup = req.StackGroup
+	promotion.StartAt = req.StartAt
+	promotion.EndAt = req.EndAt
+	promotion.Status = status
+	return nil
+}
+
+// validateRule 按促销类型校验规则参数
+func validateRule(req *model.PromotionRequest) error {
+	one := decimal.NewFromInt(1)
+	switch req.Type {
+	case model.PromotionFullReduction:
+		if !req.Threshold.IsPositive() || !req.Discount.IsPositive() || req.Discount.GreaterThan(req.Threshold) {
+			return fmt.Errorf("%w: 满减需设置门槛和不超过门槛的减免金额", model.ErrInvalidPromotion)
+		}
+	case model.PromotionPercentage:
+		if !req.Discount.IsPositive() || !req.Discount.LessThan(one) {
+			return fmt.Errorf("%w: 折扣比例需在0到1之间", model.ErrInvalidPromotion)
+		}
+	case model.PromotionNForM:
+		if req.BuyQuantity < 2 || req.PayQuantity < 1 || req.PayQuantity >= req.BuyQuantity {
+			return fmt.Errorf("%w: N件M元需满足 N > M >= 1", model.ErrInvalidPromotion)
+		}
+	case model.PromotionSecondHalf:
+		if req.Discount.IsNegative() || req.Discount.GreaterThan(one) {
+			return fmt.Errorf("%w: 第二件优惠比例需在0到1之间", model.ErrInvalidPromotion)
+		}
+	case model.PromotionBundlePrice:
+		if req.BuyQuantity < 2 || !req.Price.IsPositive() {
+			return fmt.Errorf("%w: 打包价需设置至少2件及打包价格", model.ErrInvalidPromotion)
+		}
+	case model.PromotionFreeGift:
+		if req.GiftProductID == 0 || req.GiftQuantity <= 0 {
+			return fmt.Errorf("%w: 满赠需设置赠品及数量", model.ErrInvalidPromotion)
+		}
+	case model.PromotionLimitedPrice:
+		if !req.Price.IsPositive() || req.ScopeType != model.PromotionScopeProduct {
+			return fmt.Errorf("%w: 限时价需指定商品及活动价格", model.ErrInvalidPromotion)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package promotion
+
+import (
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// PromotionServiceTestSuite 促销服务测试套件
+type PromotionServiceTestSuite struct {
+	suite.Suite
+	service  *Service
+	products []*model.Product
+}
+
+// SetupTest 每个用例使用独立的内存数据库和测试商品
+func (suite *PromotionServiceTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.AutoMigrate(&model.Promotion{}, &model.Product{}))
+
+	// 商品1、2属于分类10，商品3属于分类20、品牌5
+	suite.products = nil
+	for _, meta := range []struct{ category, brand uint }{{10, 0}, {10, 0}, {20, 5}, {30, 0}} {
+		product := &model.Product{Name: "测试商品", Price: decimal.NewFromInt(10), CategoryID: meta.category, BrandID: meta.brand, Status: model.ProductStatusActive}
+		suite.Require().NoError(db.Create(product).Error)
+		suite.products = append(suite.products, product)
+	}
+	suite.service = NewService(db)
+}
+
+// createPromotion 创建当前生效的促销
+func (suite *PromotionServiceTestSuite) createPromotion(req model.PromotionRequest) *model.Promotion {
+	req.Name = string(req.Type)
+	req.StartAt = time.Now().Add(-time.Hour)
+	req.EndAt = time.Now().Add(time.Hour)
+	promotion, err := suite.service.Create(&req, 1)
+	suite.Require().NoError(err)
+	return promotion
+}
+
+func (suite *PromotionServiceTestSuite) TestEvaluate_FullReductionAllocation() {
+	suite.createPromotion(model.PromotionRequest{
+		Type:       model.PromotionFullReduction,
+		ScopeType:  model.PromotionScopeCategory,
+		ScopeIDs:   []uint{10},
+		Threshold:  decimal.NewFromInt(100),
+		Discount:   decimal.NewFromInt(10),
+		Repeatable: true,
+	})
+
+	result, err := suite.service.Evaluate([]Line{
+		{ProductID: suite.products[0].ID, Price: decimal.NewFromInt(70), Quantity: 2},
+		{ProductID: suite.products[1].ID, Price: decimal.NewFromInt(50), Quantity: 1},
+		{ProductID: suite.products[2].ID, Price: decimal.NewFromInt(99), Quantity: 1},
+	})
+	suite.Require().NoError(err)
+
+	// 分类10合计190，每满100减10，减10按金额比例分摊
+	suite.True(result.Discount.Equal(decimal.NewFromInt(10)))
+	suite.True(result.Lines[0].Discount.Equal(decimal.NewFromFloat(7.37)))
+	suite.True(result.Lines[1].Discount.Equal(decimal.NewFromFloat(2.63)))
+	suite.True(result.Lines[2].Discount.IsZero())
+	suite.Empty(result.Lines[2].Explanations)
+	suite.Require().Len(result.Lines[0].Explanations, 1)
+	suite.Equal("满100.00减10.00", result.Lines[0].Explanations[0].Message)
+	suite.True(result.PayAmount.Equal(decimal.NewFromInt(279)))
+
+	// 未达门槛时给出凑单建议
+	result, err = suite.service.Evaluate([]Line{{ProductID: suite.products[0].ID, Price: decimal.NewFromInt(70), Quantity: 1}})
+	suite.Require().NoError(err)
+	suite.True(result.Discount.IsZero())
+	suite.Require().Len(result.Suggestions, 1)
+	suite.True(result.Suggestions[0].NeedAmount.Equal(decimal.NewFromInt(30)))
+}
+
+func (suite *PromotionServiceTestSuite) TestEvaluate_ItemRules() {
+	tests := []struct {
+		name     string
+		req      model.PromotionRequest
+		quantity int
+		want     string
+	}{
+		{"买3付2", model.PromotionRequest{Type: model.PromotionNForM, BuyQuantity: 3, PayQuantity: 2}, 7, "40"},
+		{"第二件半价", model.PromotionRequest{Type: model.PromotionSecondHalf}, 3, "10"},
+		{"限时价", model.PromotionRequest{Type: model.PromotionLimitedPrice, ScopeType: model.PromotionScopeProduct, Price: decimal.NewFromInt(15)}, 2, "10"},
+		{"9折封顶", model.PromotionRequest{Type: model.PromotionPercentage, Discount: decimal.NewFromFloat(0.1), MaxDiscount: decimal.NewFromInt(5)}, 3, "5"},
+		{"3件50元", model.PromotionRequest{Type: model.PromotionBundlePrice, BuyQuantity: 3, Price: decimal.NewFromInt(50)}, 4, "10"},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.SetupTest()
+			if tt.req.ScopeType == model.PromotionScopeProduct {
+				tt.req.ScopeIDs = []uint{suite.products[0].ID}
+			}
+			suite.createPromotion(tt.req)
+
+			result, err := suite.service.Evaluate([]Line{{ProductID: suite.products[0].ID, Price: decimal.NewFromInt(20), Quantity: tt.quantity}})
+			suite.Require().NoError(err)
+			suite.True(result.Discount.Equal(decimal.RequireFromString(tt.want)), "discount %s", result.Discount)
+			suite.True(result.Lines[0].PayAmount.Equal(result.Lines[0].Amount.Sub(decimal.RequireFromString(tt.want))))
+		})
+	}
+}
+
+func (suite *PromotionServiceTestSuite) TestEvaluate_StackingAndExclusive() {
+	// 同一分组内优先级高的满减生效，另一分组的折扣叠加在减后金额上
+	suite.createPromotion(model.PromotionRequest{Type: model.PromotionFullReduction, Threshold: decimal.NewFromInt(100), Discount: decimal.NewFromInt(20), Priority: 10, StackGroup: "reduction"})
+	suite.createPromotion(model.PromotionRequest{Type: model.PromotionFullReduction, Threshold: decimal.NewFromInt(50), Discount: decimal.NewFromInt(5), Priority: 5, StackGroup: "reduction"})
+	suite.createPromotion(model.PromotionRequest{Type: model.PromotionPercentage, Discount: decimal.NewFromFloat(0.1), Priority: 1})
+	// 品牌5的互斥限时价优先计算，命中的商品不再参与其他促销
+	suite.createPromotion(model.PromotionRequest{Type: model.PromotionLimitedPrice, ScopeType: model.PromotionScopeProduct, ScopeIDs: []uint{suite.products[2].ID}, Price: decimal.NewFromInt(30), Priority: 20, Exclusive: true})
+	suite.createPromotion(model.PromotionRequest{Type: model.PromotionFreeGift, Threshold: decimal.NewFromInt(100), GiftProductID: suite.products[3].ID, GiftQuantity: 1})
+
+	result, err := suite.service.Evaluate([]Line{
+		{ProductID: suite.products[0].ID, Price: decimal.NewFromInt(100), Quantity: 1},
+		{ProductID: suite.products[2].ID, Price: decimal.NewFromInt(40), Quantity: 1},
+	})
+	suite.Require().NoError(err)
+
+	suite.True(result.Lines[1].Discount.Equal(decimal.NewFromInt(10)))
+	suite.Require().Len(result.Lines[1].Explanations, 1)
+	suite.Equal(model.PromotionLimitedPrice, result.Lines[1].Explanations[0].Type)
+
+	// 100 - 20 = 80，再9折减8
+	suite.True(result.Lines[0].Discount.Equal(decimal.NewFromInt(28)))
+	suite.Require().Len(result.Lines[0].Explanations, 2)
+	suite.Equal(model.PromotionPercentage, result.Lines[0].Explanations[1].Type)
+	suite.Len(result.Applied, 3)
+	suite.True(result.PayAmount.Equal(decimal.NewFromInt(102)))
+
+	// 满赠按优惠后的72元计算，未达门槛
+	suite.Empty(result.Gifts)
+	suite.Require().Len(result.Suggestions, 1)
+	suite.True(result.Suggestions[0].NeedAmount.Equal(decimal.NewFromInt(28)))
+
+	// 未命中互斥促销的商品满足满赠
+	result, err = suite.service.Evaluate([]Line{{ProductID: suite.products[1].ID, Price: decimal.NewFromInt(200), Quantity: 1}})
+	suite.Require().NoError(err)
+	suite.Require().Len(result.Gifts, 1)
+	suite.Equal(suite.products[3].ID, result.Gifts[0].ProductID)
+	suite.Equal(1, result.Gifts[0].Quantity)
+}
+
+func (suite *PromotionServiceTestSuite) TestService_Validate() {
+	invalid := []model.PromotionRequest{
+		{Type: "unknown"},
+		{Type: model.PromotionFullReduction, Threshold: decimal.NewFromInt(10), Discount: decimal.NewFromInt(20)},
+		{Type: model.PromotionPercentage, Discount: decimal.NewFromFloat(1.5)},
+		{Type: model.PromotionNForM, BuyQuantity: 2, PayQuantity: 2},
+		{Type: model.PromotionLimitedPrice, Price: decimal.NewFromInt(10)},
+		{Type: model.PromotionFreeGift, ScopeType: model.PromotionScopeBrand},
+	}
+	for _, req := range invalid {
+		req.StartAt = time.Now()
+		req.EndAt = time.Now().Add(time.Hour)
+		_, err := suite.service.Create(&req, 1)
+		suite.ErrorIs(err, model.ErrInvalidPromotion, "type %s", req.Type)
+	}
+
+	now := time.Now()
+	_, err := suite.service.Create(&model.PromotionRequest{Type: model.PromotionSecondHalf, StartAt: now, EndAt: now}, 1)
+	suite.ErrorIs(err, model.ErrInvalidPromotionTime)
+
+	// 停用后不再参与计算
+	promotion := suite.createPromotion(model.PromotionRequest{Type: model.PromotionSecondHalf})
+	_, err = suite.service.SetStatus(promotion.ID, model.PromotionStatusInactive, 1)
+	suite.Require().NoError(err)
+	result, err := suite.service.Evaluate([]Line{{ProductID: 1, Price: decimal.NewFromInt(10), Quantity: 2}})
+	suite.Require().NoError(err)
+	suite.True(result.Discount.IsZero())
+}
+
+func TestPromotionServiceSuite(t *testing.T) {
+	suite.Run(t, new(PromotionServiceTestSuite))
+}
@@ -0,0 +1,17 @@
+package response
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParseID 解析路径中的ID参数，格式错误时返回参数错误响应
+func ParseID(c *gin.Context, name, message string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		BadRequest(c, message)
+		return 0, false
+	}
+	return uint(id), true
+}
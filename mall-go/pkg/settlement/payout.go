@@ -0,0 +1,293 @@
+package settlement
+
+import (
+	"fmt"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ShareRequest 渠道分账请求
+type ShareRequest struct {
+	OutRequestNo string          // 分账请求号，使用打款单号保证重复请求幂等
+	TradeNo      string          // 渠道交易号
+	Account      string          // 商家分账收款账户
+	AccountName  string          // 收款账户名称
+	Amount       decimal.Decimal // 分账金额
+	Description  string          // 分账描述
+}
+
+// ProfitSharer 支付渠道分账接口
+// 渠道受理分账请求即视为打款成功，返回渠道分账单号
+type ProfitSharer interface {
+	Share(req *ShareRequest) (string, error)
+}
+
+// SaveAccount 设置商家分账收款账户
+func (s *Service) SaveAccount(merchantID uint, req *model.MerchantSettlementAccountRequest) (*model.MerchantSettlementAccount, error) {
+	var account model.MerchantSettlementAccount
+	err := s.db.Where("merchant_id = ? AND method = ?", merchantID, req.Method).First(&account).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("查询分账账户失败: %v", err)
+	}
+	if err == gorm.ErrRecordNotFound {
+		account = model.MerchantSettlementAccount{MerchantID: merchantID, Method: req.Method}
+	}
+
+	account.Account = req.Account
+	account.AccountName = req.AccountName
+	if err := s.db.Save(&account).Error; err != nil {
+		return nil, fmt.Errorf("保存分账账户失败: %v", err)
+	}
+	return &account, nil
+}
+
+// ListAccounts 查询商家分账收款账户
+func (s *Service) ListAccounts(merchantID uint) ([]model.MerchantSettlementAccount, error) {
+	var accounts []model.MerchantSettlementAccount
+	if err := s.db.Where("merchant_id = ?", merchantID).Order("id ASC").Find(&accounts).Error; err != nil {
+		return nil, fmt.Errorf("查询分账账户失败: %v", err)
+	}
+	return accounts, nil
+}
+
+// RecordManualPayout 登记线下打款
+func (s *Service) RecordManualPayout(statementID uint, req *model.SettlementPayoutRequest, operatorID uint) (*model.SettlementPayout, error) {
+	if !req.Amount.IsPositive() {
+		return nil, model.ErrInvalidPayoutAmount
+	}
+
+	var payout *model.SettlementPayout
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		statement, err := payableStatement(tx, statementID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		payout = &model.SettlementPayout{
+			PayoutNo:    generatePayoutNo(0),
+			StatementID: statement.ID,
+			MerchantID:  statement.MerchantID,
+			Method:      model.PayoutMethodManual,
+			Amount:      req.Amount,
+			Status:      model.PayoutStatusSuccess,
+			Reference:   req.Reference,
+			OperatorID:  operatorID,
+			PaidAt:      &now,
+		}
+		if err := tx.Create(payout).Error; err != nil {
+			return fmt.Errorf("创建打款记录失败: %v", err)
+		}
+		return applyPayout(tx, statement, req.Amount)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("登记结算线下打款",
+		zap.Uint("statement_id", statementID),
+		zap.String("payout_no", payout.PayoutNo),
+		zap.String("amount", payout.Amount.StringFixed(2)))
+	return payout, nil
+}
+
+// PayoutViaChannel 通过支付渠道分账接口打款
+// 按订单将本结算单中该订单的应结金额分给商家，只处理通过该渠道支付成功的订单；
+// 已有成功或处理中打款的订单跳过，失败的可重新发起
+func (s *Service) PayoutViaChannel(statementID uint, method string, operatorID uint) ([]model.SettlementPayout, error) {
+	sharer, ok := s.getSharer(method)
+	if !ok {
+		return nil, model.ErrProfitSharingNotEnabled
+	}
+
+	statement, err := payableStatement(s.db, statementID)
+	if err != nil {
+		return nil, err
+	}
+
+	var account model.MerchantSettlementAccount
+	if err := s.db.Where("merchant_id = ? AND method = ?", statement.MerchantID, method).First(&account).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, model.ErrSettlementAccountNotSet
+		}
+		return nil, fmt.Errorf("查询分账账户失败: %v", err)
+	}
+
+	var orders []struct {
+		OrderID uint
+		OrderNo string
+		Net     decimal.Decimal
+	}
+	err = s.db.Model(&model.SettlementLine{}).
+		Select("order_id, MAX(order_no) AS order_no, SUM(net_amount) AS net").
+		Where("statement_id = ? AND order_id > 0", statement.ID).
+		Where("NOT EXISTS (SELECT 1 FROM settlement_payouts sp WHERE sp.statement_id = settlement_lines.statement_id AND sp.order_id = settlement_lines.order_id AND sp.status <> ?)", model.PayoutStatusFailed).
+		Group("order_id").
+		Order("order_id ASC").
+		Scan(&orders).Error
+	if err != nil {
+		return nil, fmt.Errorf("统计订单应结金额失败: %v", err)
+	}
+
+	remaining := statement.RemainingAmount()
+	payouts := make([]model.SettlementPayout, 0)
+	for _, order := range orders {
+		amount := order.Net
+		if amount.GreaterThan(remaining) {
+			amount = remaining
+		}
+		if !amount.IsPositive() {
+			continue
+		}
+
+		var payment model.Payment
+		err := s.db.Where("order_id = ? AND payment_method = ? AND payment_status IN ? AND third_party_id <> ''",
+			order.OrderID, method, []model.PaymentStatus{model.PaymentStatusSuccess, model.PaymentStatusPaid}).
+			Order("id DESC").First(&payment).Error
+		if err == gorm.ErrRecordNotFound {
+			continue
+		}
+		if err != nil {
+			return payouts, fmt.Errorf("查询订单支付记录失败: %v", err)
+		}
+
+		payout, err := s.shareOrder(sharer, statement, &account, &payment, order.OrderNo, amount, method, operatorID)
+		if err != nil {
+			return payouts, err
+		}
+		payouts = append(payouts, *payout)
+		if payout.Status == model.PayoutStatusSuccess {
+			remaining = remaining.Sub(payout.Amount)
+		}
+	}
+
+	if len(payouts) == 0 {
+		return nil, model.ErrNoShareableOrders
+	}
+	return payouts, nil
+}
+
+// shareOrder 对单个订单发起分账并记录打款结果
+func (s *Service) shareOrder(sharer ProfitSharer, statement *model.SettlementStatement, account *model.MerchantSettlementAccount,
+	payment *model.Payment, orderNo string, amount decimal.Decimal, method string, operatorID uint) (*model.SettlementPayout, error) {
+	payout := &model.SettlementPayout{
+		PayoutNo:    generatePayoutNo(payment.OrderID),
+		StatementID: statement.ID,
+		MerchantID:  statement.MerchantID,
+		OrderID:     payment.OrderID,
+		Method:      method,
+		Amount:      amount,
+		Status:      model.PayoutStatusProcessing,
+		OperatorID:  operatorID,
+	}
+	if err := s.db.Create(payout).Error; err != nil {
+		return nil, fmt.Errorf("创建打款记录失败: %v", err)
+	}
+
+	channelOrderNo, shareErr := sharer.Share(&ShareRequest{
+		OutRequestNo: payout.PayoutNo,
+		TradeNo:      payment.ThirdPartyID,
+		Account:      account.Account,
+		AccountName:  account.AccountName,
+		Amount:       amount,
+		Description:  fmt.Sprintf("订单%s结算", orderNo),
+	})
+	if shareErr != nil {
+		logger.Error("结算分账失败",
+			zap.String("payout_no", payout.PayoutNo),
+			zap.Uint("order_id", payment.OrderID),
+			zap.Error(shareErr))
+		payout.Status = model.PayoutStatusFailed
+		payout.FailReason = truncate(shareErr.Error(), 255)
+		if err := s.db.Model(payout).Updates(map[string]interface{}{
+			"status":      payout.Status,
+			"fail_reason": payout.FailReason,
+		}).Error; err != nil {
+			return nil, fmt.Errorf("更新打款记录失败: %v", err)
+		}
+		return payout, nil
+	}
+
+	now := time.Now()
+	payout.Status = model.PayoutStatusSuccess
+	payout.ChannelOrderNo = channelOrderNo
+	payout.PaidAt = &now
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(payout).Updates(map[string]interface{}{
+			"status":           payout.Status,
+			"channel_order_no": payout.ChannelOrderNo,
+			"paid_at":          now,
+		}).Error; err != nil {
+			return fmt.Errorf("更新打款记录失败: %v", err)
+		}
+		return applyPayout(tx, statement, amount)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payout, nil
+}
+
+// payableStatement 查询可打款的结算单
+func payableStatement(tx *gorm.DB, statementID uint) (*model.SettlementStatement, error) {
+	var statement model.SettlementStatement
+	if err := tx.First(&statement, statementID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, model.ErrSettlementNotFound
+		}
+		return nil, fmt.Errorf("查询结算单失败: %v", err)
+	}
+	if statement.Status != model.SettlementStatusPending && statement.Status != model.SettlementStatusPartiallyPaid {
+		return nil, model.ErrSettlementNotPayable
+	}
+	return &statement, nil
+}
+
+// applyPayout 累加结算单已打款金额并更新结算状态，超出应结金额时拒绝
+func applyPayout(tx *gorm.DB, statement *model.SettlementStatement, amount decimal.Decimal) error {
+	result := tx.Model(&model.SettlementStatement{}).
+		Where("id = ? AND paid_amount + ? <= net_amount", statement.ID, amount).
+		Update("paid_amount", gorm.Expr("paid_amount + ?", amount))
+	if result.Error != nil {
+		return fmt.Errorf("更新结算单打款金额失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrPayoutAmountExceeded
+	}
+
+	if err := tx.First(statement, statement.ID).Error; err != nil {
+		return fmt.Errorf("查询结算单失败: %v", err)
+	}
+	updates := map[string]interface{}{"status": model.SettlementStatusPartiallyPaid}
+	if statement.RemainingAmount().IsZero() {
+		now := time.Now()
+		updates["status"] = model.SettlementStatusPaid
+		updates["paid_at"] = now
+		statement.PaidAt = &now
+	}
+	if err := tx.Model(statement).Updates(updates).Error; err != nil {
+		return fmt.Errorf("更新结算单状态失败: %v", err)
+	}
+	statement.Status = updates["status"].(string)
+	return nil
+}
+
+// generatePayoutNo 生成打款单号
+func generatePayoutNo(orderID uint) string {
+	return fmt.Sprintf("PO%d%06d", time.Now().UnixNano(), orderID%1000000)
+}
+
+// truncate 按字符截断字符串
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
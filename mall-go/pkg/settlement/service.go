@@ -0,0 +1,189 @@
+package settlement
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Options 结算配置
+type Options struct {
+	AfterSaleWindow       time.Duration   // 订单完成后的售后期，售后期内的订单不参与结算
+	DefaultCommissionRate decimal.Decimal // 未配置任何费率时使用的佣金比例
+}
+
+// DefaultOptions 默认结算配置
+func DefaultOptions() Options {
+	return Options{
+		AfterSaleWindow:       7 * 24 * time.Hour,
+		DefaultCommissionRate: decimal.NewFromFloat(0.05),
+	}
+}
+
+// Service 商家结算服务
+// 按佣金费率为已完成且售后期结束的订单生成商家结算单，结算后发生的退款在下期冲减，
+// 应结金额为负时结转至下期；打款可线下登记，也可通过支付渠道分账接口完成
+type Service struct {
+	db      *gorm.DB
+	options Options
+
+	mu      sync.RWMutex
+	sharers map[string]ProfitSharer
+}
+
+// NewService 创建商家结算服务
+func NewService(db *gorm.DB, options Options) *Service {
+	return &Service{
+		db:      db,
+		options: options,
+		sharers: make(map[string]ProfitSharer),
+	}
+}
+
+// RegisterSharer 注册支付渠道分账实现
+func (s *Service) RegisterSharer(method string, sharer ProfitSharer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sharers[method] = sharer
+}
+
+// UnregisterSharer 移除支付渠道分账实现，渠道停用后只能线下打款
+func (s *Service) UnregisterSharer(method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sharers, method)
+}
+
+// getSharer 获取支付渠道分账实现
+func (s *Service) getSharer(method string) (ProfitSharer, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sharer, ok := s.sharers[method]
+	return sharer, ok
+}
+
+// ListCommissionRates 查询佣金费率配置
+func (s *Service) ListCommissionRates() ([]model.CommissionRate, error) {
+	var rates []model.CommissionRate
+	if err := s.db.Order("scope ASC, scope_id ASC").Find(&rates).Error; err != nil {
+		return nil, fmt.Errorf("查询佣金费率失败: %v", err)
+	}
+	return rates, nil
+}
+
+// SaveCommissionRate 按作用范围新增或更新佣金费率
+func (s *Service) SaveCommissionRate(req *model.CommissionRateRequest, operatorID uint) (*model.CommissionRate, error) {
+	if req.Rate.IsNegative() || req.Rate.GreaterThan(decimal.NewFromInt(1)) {
+		return nil, model.ErrInvalidCommissionRate
+	}
+	scopeID := req.ScopeID
+	if req.Scope == model.CommissionScopeDefault {
+		scopeID = 0
+	}
+
+	var rate model.CommissionRate
+	err := s.db.Where("scope = ? AND scope_id = ?", req.Scope, scopeID).First(&rate).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("查询佣金费率失败: %v", err)
+	}
+	if err == gorm.ErrRecordNotFound {
+		rate = model.CommissionRate{Scope: req.Scope, ScopeID: scopeID}
+	}
+
+	rate.Rate = req.Rate
+	rate.Remark = req.Remark
+	rate.OperatorID = operatorID
+	if err := s.db.Save(&rate).Error; err != nil {
+		return nil, fmt.Errorf("保存佣金费率失败: %v", err)
+	}
+	return &rate, nil
+}
+
+// DeleteCommissionRate 删除佣金费率配置
+func (s *Service) DeleteCommissionRate(id uint) error {
+	if err := s.db.Delete(&model.CommissionRate{}, id).Error; err != nil {
+		return fmt.Errorf("删除佣金费率失败: %v", err)
+	}
+	return nil
+}
+
+// ResolveRate 解析商品适用的佣金比例
+// 优先级：商家费率 > 商品分类费率（逐级向上查找上级分类） > 平台默认费率 > 配置默认值
+func (s *Service) ResolveRate(merchantID, categoryID uint) (decimal.Decimal, error) {
+	return newRateResolver(s.db, s.options.DefaultCommissionRate).resolve(merchantID, categoryID)
+}
+
+// rateResolver 在一次结算中缓存费率与分类层级，避免逐行查询
+type rateResolver struct {
+	db          *gorm.DB
+	defaultRate decimal.Decimal
+	rates       map[string]map[uint]decimal.Decimal
+	categories  map[uint]uint
+}
+
+// newRateResolver 创建费率解析器
+func newRateResolver(db *gorm.DB, defaultRate decimal.Decimal) *rateResolver {
+	return &rateResolver{db: db, defaultRate: defaultRate}
+}
+
+// load 加载费率配置与分类层级
+func (r *rateResolver) load() error {
+	if r.rates != nil {
+		return nil
+	}
+
+	var rates []model.CommissionRate
+	if err := r.db.Find(&rates).Error; err != nil {
+		return fmt.Errorf("查询佣金费率失败: %v", err)
+	}
+	r.rates = make(map[string]map[uint]decimal.Decimal)
+	for _, rate := range rates {
+		if r.rates[rate.Scope] == nil {
+			r.rates[rate.Scope] = make(map[uint]decimal.Decimal)
+		}
+		r.rates[rate.Scope][rate.ScopeID] = rate.Rate
+	}
+
+	var categories []model.Category
+	if err := r.db.Select("id", "parent_id").Find(&categories).Error; err != nil {
+		return fmt.Errorf("查询商品分类失败: %v", err)
+	}
+	r.categories = make(map[uint]uint, len(categories))
+	for _, category := range categories {
+		r.categories[category.ID] = category.ParentID
+	}
+	return nil
+}
+
+// resolve 解析佣金比例
+func (r *rateResolver) resolve(merchantID, categoryID uint) (decimal.Decimal, error) {
+	if err := r.load(); err != nil {
+		return decimal.Zero, err
+	}
+
+	if rate, ok := r.rates[model.CommissionScopeMerchant][merchantID]; ok {
+		return rate, nil
+	}
+	// 分类层级异常成环时以访问记录终止
+	visited := make(map[uint]bool)
+	for id := categoryID; id != 0 && !visited[id]; id = r.categories[id] {
+		visited[id] = true
+		if rate, ok := r.rates[model.CommissionScopeCategory][id]; ok {
+			return rate, nil
+		}
+	}
+	if rate, ok := r.rates[model.CommissionScopeDefault][0]; ok {
+		return rate, nil
+	}
+	return r.defaultRate, nil
+}
+
+// commissionOf 计算佣金金额
+func commissionOf(amount, rate decimal.Decimal) decimal.Decimal {
+	return amount.Mul(rate).Round(2)
+}
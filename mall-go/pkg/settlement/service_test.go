@@ -0,0 +1,394 @@
+package settlement
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SettlementServiceTestSuite 商家结算服务测试套件
+type SettlementServiceTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service *Service
+}
+
+// SetupTest 每个用例使用独立的内存数据库，默认佣金比例为10%
+func (suite *SettlementServiceTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.AutoMigrate(
+		&model.Category{},
+		&model.Product{},
+		&model.Order{},
+		&model.OrderItem{},
+		&model.OrderAfterSale{},
+		&model.Payment{},
+		&model.PaymentDispute{},
+		&model.CommissionRate{},
+		&model.SettlementStatement{},
+		&model.SettlementLine{},
+		&model.SettlementPayout{},
+		&model.MerchantSettlementAccount{},
+	))
+
+	options := DefaultOptions()
+	options.DefaultCommissionRate = decimal.RequireFromString("0.1")
+	suite.db = db
+	suite.service = NewService(db, options)
+}
+
+func (suite *SettlementServiceTestSuite) createProduct(merchantID, categoryID uint) *model.Product {
+	product := &model.Product{Name: "商品", MerchantID: merchantID, CategoryID: categoryID, Price: decimal.NewFromInt(1)}
+	suite.Require().NoError(suite.db.Create(product).Error)
+	return product
+}
+
+// createOrder 创建已完成订单，prices 为各商品项金额
+func (suite *SettlementServiceTestSuite) createOrder(product *model.Product, finishedAgo time.Duration, prices ...string) *model.Order {
+	finishTime := time.Now().Add(-finishedAgo)
+	order := &model.Order{
+		OrderNo:       "O" + time.Now().Format("150405.000000000"),
+		Status:        model.OrderStatusCompleted,
+		PaymentStatus: string(model.PaymentStatusPaid),
+		FinishTime:    &finishTime,
+	}
+	total := decimal.Zero
+	for _, price := range prices {
+		amount := decimal.RequireFromString(price)
+		total = total.Add(amount)
+		order.OrderItems = append(order.OrderItems, model.OrderItem{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			Quantity:    1,
+			Price:       amount,
+			TotalPrice:  amount,
+		})
+	}
+	order.TotalAmount = total
+	order.PayableAmount = total
+	suite.Require().NoError(suite.db.Create(order).Error)
+	return order
+}
+
+func (suite *SettlementServiceTestSuite) TestService_ResolveRate() {
+	parent := &model.Category{Name: "服装"}
+	suite.Require().NoError(suite.db.Create(parent).Error)
+	child := &model.Category{Name: "T恤", ParentID: parent.ID}
+	suite.Require().NoError(suite.db.Create(child).Error)
+
+	rate, err := suite.service.ResolveRate(1, child.ID)
+	suite.Require().NoError(err)
+	suite.True(rate.Equal(decimal.NewFromFloat(0.1)), "未配置时使用默认配置")
+
+	_, err = suite.service.SaveCommissionRate(&model.CommissionRateRequest{Scope: model.CommissionScopeDefault, ScopeID: 9, Rate: decimal.NewFromFloat(0.08)}, 1)
+	suite.Require().NoError(err)
+	rate, err = suite.service.ResolveRate(1, child.ID)
+	suite.Require().NoError(err)
+	suite.True(rate.Equal(decimal.NewFromFloat(0.08)))
+
+	_, err = suite.service.SaveCommissionRate(&model.CommissionRateRequest{Scope: model.CommissionScopeCategory, ScopeID: parent.ID, Rate: decimal.NewFromFloat(0.06)}, 1)
+	suite.Require().NoError(err)
+	rate, err = suite.service.ResolveRate(1, child.ID)
+	suite.Require().NoError(err)
+	suite.True(rate.Equal(decimal.NewFromFloat(0.06)), "子分类继承上级分类费率")
+
+	_, err = suite.service.SaveCommissionRate(&model.CommissionRateRequest{Scope: model.CommissionScopeMerchant, ScopeID: 1, Rate: decimal.NewFromFloat(0.03)}, 1)
+	suite.Require().NoError(err)
+	rate, err = suite.service.ResolveRate(1, child.ID)
+	suite.Require().NoError(err)
+	suite.True(rate.Equal(decimal.NewFromFloat(0.03)), "商家费率优先")
+
+	_, err = suite.service.SaveCommissionRate(&model.CommissionRateRequest{Scope: model.CommissionScopeMerchant, ScopeID: 1, Rate: decimal.NewFromFloat(1.5)}, 1)
+	suite.Equal(model.ErrInvalidCommissionRate, err)
+}
+
+func (suite *SettlementServiceTestSuite) TestService_GenerateStatement() {
+	product := suite.createProduct(1, 0)
+	other := suite.createProduct(2, 0)
+
+	settled := suite.createOrder(product, 10*24*time.Hour, "100", "50")
+	suite.Require().NoError(suite.db.Model(&model.OrderItem{}).Where("id = ?", settled.OrderItems[1].ID).Update("refund_amount", decimal.NewFromInt(20)).Error)
+	suite.Require().NoError(suite.db.Model(settled).Update("refund_amount", decimal.NewFromInt(20)).Error)
+
+	suite.createOrder(product, 24*time.Hour, "80")  // 售后期内
+	suite.createOrder(other, 10*24*time.Hour, "60") // 其他商家
+	disputed := suite.createOrder(product, 10*24*time.Hour, "70")
+	suite.Require().NoError(suite.db.Create(&model.OrderAfterSale{
+		OrderID:     disputed.ID,
+		AfterSaleNo: "AS1",
+		Type:        "refund",
+		Status:      model.AfterSaleStatusPending,
+		ApplyUserID: 1,
+		Reason:      "质量问题",
+	}).Error)
+
+	statement, err := suite.service.GenerateStatement(1, time.Time{})
+	suite.Require().NoError(err)
+	suite.Equal(2, statement.LineCount)
+	suite.Equal(model.SettlementStatusPending, statement.Status)
+	suite.True(statement.GrossAmount.Equal(decimal.NewFromInt(150)))
+	suite.True(statement.RefundAmount.Equal(decimal.NewFromInt(20)))
+	suite.True(statement.CommissionAmount.Equal(decimal.NewFromInt(13)))
+	suite.True(statement.NetAmount.Equal(decimal.NewFromInt(117)))
+
+	_, err = suite.service.GenerateStatement(1, time.Time{})
+	suite.Equal(model.ErrSettlementEmpty, err, "已结算商品项不会重复结算")
+
+	detail, err := suite.service.GetStatement(statement.ID, 1)
+	suite.Require().NoError(err)
+	suite.Len(detail.Lines, 2)
+	_, err = suite.service.GetStatement(statement.ID, 2)
+	suite.Equal(model.ErrSettlementNotFound, err, "商家只能查看自己的结算单")
+}
+
+func (suite *SettlementServiceTestSuite) TestService_RefundAfterSettlementCarriesForward() {
+	product := suite.createProduct(1, 0)
+	order := suite.createOrder(product, 10*24*time.Hour, "100")
+
+	first, err := suite.service.GenerateStatement(1, time.Time{})
+	suite.Require().NoError(err)
+	suite.True(first.NetAmount.Equal(decimal.NewFromInt(90)))
+	_, err = suite.service.RecordManualPayout(first.ID, &model.SettlementPayoutRequest{Amount: decimal.NewFromInt(90), Reference: "BANK001"}, 1)
+	suite.Require().NoError(err)
+
+	// 结算后整单退款，佣金按原比例退回
+	suite.Require().NoError(suite.db.Model(order).Update("refund_amount", decimal.NewFromInt(100)).Error)
+	second, err := suite.service.GenerateStatement(1, time.Time{})
+	suite.Require().NoError(err)
+	suite.Equal(model.SettlementStatusCarried, second.Status)
+	suite.True(second.RefundAmount.Equal(decimal.NewFromInt(100)))
+	suite.True(second.CommissionAmount.Equal(decimal.NewFromInt(-10)))
+	suite.True(second.NetAmount.Equal(decimal.NewFromInt(-90)))
+
+	suite.createOrder(product, 9*24*time.Hour, "200")
+	third, err := suite.service.GenerateStatement(1, time.Time{})
+	suite.Require().NoError(err)
+	suite.Equal(2, third.LineCount)
+	suite.True(third.CarryAmount.Equal(decimal.NewFromInt(-90)))
+	suite.True(third.NetAmount.Equal(decimal.NewFromInt(90)))
+	suite.Equal(model.SettlementStatusPending, third.Status)
+}
+
+func (suite *SettlementServiceTestSuite) TestService_RefundLinesPerIncrement() {
+	product := suite.createProduct(1, 0)
+	order := suite.createOrder(product, 10*24*time.Hour, "100")
+
+	_, err := suite.service.GenerateStatement(1, time.Time{})
+	suite.Require().NoError(err)
+
+	// 同一商品项结算后多次部分退款，每次生成一条退款明细
+	for i, refunded := range []string{"30", "50"} {
+		suite.Require().NoError(suite.db.Model(order).Update("refund_amount", decimal.RequireFromString(refunded)).Error)
+		statement, err := suite.service.GenerateStatement(1, time.Time{})
+		suite.Require().NoError(err)
+
+		var refundLines []model.SettlementLine
+		for _, line := range statement.Lines {
+			if line.LineType == model.SettlementLineRefund {
+				refundLines = append(refundLines, line)
+			}
+		}
+		suite.Require().Len(refundLines, 1)
+		suite.Equal(uint(i+1), refundLines[0].SourceID)
+		suite.True(refundLines[0].RefundAmount.Equal(decimal.RequireFromString([]string{"30", "20"}[i])))
+	}
+}
+
+func (suite *SettlementServiceTestSuite) TestService_GenerateStatementLineConflict() {
+	product := suite.createProduct(1, 0)
+	order := suite.createOrder(product, 10*24*time.Hour, "100", "50")
+
+	// 模拟并发生成：首次写入明细前，第一个商品项已被另一张结算单结算
+	attempts := 0
+	suite.Require().NoError(suite.db.Callback().Create().Before("gorm:create").Register("test:concurrent_settle", func(tx *gorm.DB) {
+		if tx.Statement.Table != "settlement_statements" {
+			return
+		}
+		attempts++
+		if attempts > 1 {
+			return
+		}
+		suite.Require().NoError(tx.Session(&gorm.Session{NewDB: true}).Create(&model.SettlementLine{
+			StatementID: 999,
+			MerchantID:  1,
+			LineType:    model.SettlementLineSale,
+			OrderID:     order.ID,
+			OrderItemID: order.OrderItems[0].ID,
+		}).Error)
+	}))
+
+	// 冲突后整单回滚并重新生成，回滚撤销了模拟写入，重新生成时两个商品项均可结算
+	statement, err := suite.service.GenerateStatement(1, time.Time{})
+	suite.Require().NoError(err)
+	suite.Equal(2, attempts)
+	suite.Equal(2, statement.LineCount)
+	suite.True(statement.GrossAmount.Equal(decimal.NewFromInt(150)))
+
+	var lines int64
+	suite.Require().NoError(suite.db.Model(&model.SettlementLine{}).Count(&lines).Error)
+	suite.Equal(int64(2), lines)
+
+	// 同一商品项的销售明细不能重复写入
+	duplicate := suite.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.SettlementLine{
+		StatementID: statement.ID,
+		MerchantID:  1,
+		LineType:    model.SettlementLineSale,
+		OrderItemID: order.OrderItems[0].ID,
+	})
+	suite.Require().NoError(duplicate.Error)
+	suite.Equal(int64(0), duplicate.RowsAffected)
+}
+
+// createDispute 为订单创建支付记录及支付争议
+func (suite *SettlementServiceTestSuite) createDispute(order *model.Order, paymentAmount, amount string, status model.PaymentDisputeStatus) *model.PaymentDispute {
+	payment := &model.Payment{
+		PaymentNo:     "PAY" + order.OrderNo,
+		OrderID:       order.ID,
+		UserID:        1,
+		PaymentMethod: model.PaymentMethodWechat,
+		PaymentStatus: model.PaymentStatusSuccess,
+		Amount:        decimal.RequireFromString(paymentAmount),
+	}
+	suite.Require().NoError(suite.db.Create(payment).Error)
+	dispute := &model.PaymentDispute{
+		DisputeNo: "DP" + order.OrderNo,
+		PaymentID: payment.ID,
+		OrderID:   order.ID,
+		Channel:   payment.PaymentMethod,
+		Type:      model.PaymentDisputeChargeback,
+		Source:    model.PaymentDisputeSourceManual,
+		Status:    status,
+		Amount:    decimal.RequireFromString(amount),
+	}
+	suite.Require().NoError(suite.db.Create(dispute).Error)
+	return dispute
+}
+
+func (suite *SettlementServiceTestSuite) TestService_LostDisputeChargeback() {
+	product := suite.createProduct(1, 0)
+	settled := suite.createOrder(product, 10*24*time.Hour, "100")
+	open := suite.createOrder(product, 10*24*time.Hour, "40")
+	openDispute := suite.createDispute(open, "40", "40", model.PaymentDisputeOpen)
+
+	// 争议处理中的订单暂不结算
+	first, err := suite.service.GenerateStatement(1, time.Time{})
+	suite.Require().NoError(err)
+	suite.Equal(1, first.LineCount)
+	suite.True(first.NetAmount.Equal(decimal.NewFromInt(90)))
+
+	// 已结算订单争议败诉，按争议金额比例冲回并退回佣金
+	suite.createDispute(settled, "100", "50", model.PaymentDisputeLost)
+	second, err := suite.service.GenerateStatement(1, time.Time{})
+	suite.Require().NoError(err)
+	suite.Equal(1, second.LineCount)
+	suite.Equal(model.SettlementStatusCarried, second.Status)
+	suite.True(second.ChargebackAmount.Equal(decimal.NewFromInt(50)))
+	suite.True(second.CommissionAmount.Equal(decimal.NewFromInt(-5)))
+	suite.True(second.NetAmount.Equal(decimal.NewFromInt(-45)))
+
+	// 未结算订单争议败诉，销售与拒付明细在同一期结算，已冲回的争议不再重复冲回
+	suite.Require().NoError(suite.db.Model(openDispute).Update("status", model.PaymentDisputeLost).Error)
+	third, err := suite.service.GenerateStatement(1, time.Time{})
+	suite.Require().NoError(err)
+	suite.Equal(3, third.LineCount)
+	suite.True(third.GrossAmount.Equal(decimal.NewFromInt(40)))
+	suite.True(third.ChargebackAmount.Equal(decimal.NewFromInt(40)))
+	suite.True(third.CarryAmount.Equal(decimal.NewFromInt(-45)))
+	suite.True(third.NetAmount.Equal(decimal.NewFromInt(-45)))
+}
+
+type fakeSharer struct {
+	requests []*ShareRequest
+	err      error
+}
+
+func (f *fakeSharer) Share(req *ShareRequest) (string, error) {
+	f.requests = append(f.requests, req)
+	if f.err != nil {
+		return "", f.err
+	}
+	return "SETTLE" + req.OutRequestNo, nil
+}
+
+func (suite *SettlementServiceTestSuite) TestService_PayoutViaChannel() {
+	product := suite.createProduct(1, 0)
+	order := suite.createOrder(product, 10*24*time.Hour, "100")
+	suite.Require().NoError(suite.db.Create(&model.Payment{
+		PaymentNo:     "PAY1",
+		OrderID:       order.ID,
+		UserID:        1,
+		PaymentMethod: model.PaymentMethodAlipay,
+		PaymentStatus: model.PaymentStatusSuccess,
+		Amount:        decimal.NewFromInt(100),
+		ThirdPartyID:  "2024010122001",
+	}).Error)
+
+	statement, err := suite.service.GenerateStatement(1, time.Time{})
+	suite.Require().NoError(err)
+
+	_, err = suite.service.PayoutViaChannel(statement.ID, model.PayoutMethodAlipay, 1)
+	suite.Equal(model.ErrProfitSharingNotEnabled, err)
+
+	sharer := &fakeSharer{err: errors.New("ACQ.TRADE_SETTLE_ERROR")}
+	suite.service.RegisterSharer(model.PayoutMethodAlipay, sharer)
+	_, err = suite.service.PayoutViaChannel(statement.ID, model.PayoutMethodAlipay, 1)
+	suite.Equal(model.ErrSettlementAccountNotSet, err)
+
+	_, err = suite.service.SaveAccount(1, &model.MerchantSettlementAccountRequest{Method: model.PayoutMethodAlipay, Account: "2088000000000001"})
+	suite.Require().NoError(err)
+
+	payouts, err := suite.service.PayoutViaChannel(statement.ID, model.PayoutMethodAlipay, 1)
+	suite.Require().NoError(err)
+	suite.Require().Len(payouts, 1)
+	suite.Equal(model.PayoutStatusFailed, payouts[0].Status)
+
+	// 失败的分账可重新发起
+	sharer.err = nil
+	payouts, err = suite.service.PayoutViaChannel(statement.ID, model.PayoutMethodAlipay, 1)
+	suite.Require().NoError(err)
+	suite.Require().Len(payouts, 1)
+	suite.Equal(model.PayoutStatusSuccess, payouts[0].Status)
+	suite.True(payouts[0].Amount.Equal(decimal.NewFromInt(90)))
+	suite.Equal("2024010122001", sharer.requests[1].TradeNo)
+	suite.Equal("2088000000000001", sharer.requests[1].Account)
+
+	detail, err := suite.service.GetStatement(statement.ID, 0)
+	suite.Require().NoError(err)
+	suite.Equal(model.SettlementStatusPaid, detail.Status)
+	suite.NotNil(detail.PaidAt)
+	suite.Len(detail.Payouts, 2)
+
+	_, err = suite.service.RecordManualPayout(statement.ID, &model.SettlementPayoutRequest{Amount: decimal.NewFromInt(1), Reference: "BANK002"}, 1)
+	suite.Equal(model.ErrSettlementNotPayable, err)
+}
+
+func (suite *SettlementServiceTestSuite) TestService_RecordManualPayoutExceeded() {
+	product := suite.createProduct(1, 0)
+	suite.createOrder(product, 10*24*time.Hour, "100")
+
+	statement, err := suite.service.GenerateStatement(1, time.Time{})
+	suite.Require().NoError(err)
+
+	_, err = suite.service.RecordManualPayout(statement.ID, &model.SettlementPayoutRequest{Amount: decimal.NewFromInt(100), Reference: "BANK001"}, 1)
+	suite.Equal(model.ErrPayoutAmountExceeded, err)
+
+	_, err = suite.service.RecordManualPayout(statement.ID, &model.SettlementPayoutRequest{Amount: decimal.NewFromInt(40), Reference: "BANK001"}, 1)
+	suite.Require().NoError(err)
+	detail, err := suite.service.GetStatement(statement.ID, 0)
+	suite.Require().NoError(err)
+	suite.Equal(model.SettlementStatusPartiallyPaid, detail.Status)
+	suite.True(detail.RemainingAmount().Equal(decimal.NewFromInt(50)))
+}
+
+func TestSettlementServiceSuite(t *testing.T) {
+	suite.Run(t, new(SettlementServiceTestSuite))
+}
@@ -0,0 +1,62 @@
+package settlement
+
+import (
+	"mall-go/pkg/payment/alipay"
+	"mall-go/pkg/payment/wechat"
+)
+
+// AlipaySharer 支付宝交易分账
+type AlipaySharer struct {
+	client *alipay.Client
+}
+
+// NewAlipaySharer 创建支付宝交易分账实现
+func NewAlipaySharer(client *alipay.Client) *AlipaySharer {
+	return &AlipaySharer{client: client}
+}
+
+// Share 将交易资金分给商家支付宝账户
+func (a *AlipaySharer) Share(req *ShareRequest) (string, error) {
+	resp, err := a.client.SettleOrder(&alipay.RoyaltyRequest{
+		OutRequestNo: req.OutRequestNo,
+		TradeNo:      req.TradeNo,
+		Receivers: []alipay.RoyaltyReceiver{{
+			TransIn: req.Account,
+			Amount:  req.Amount,
+			Desc:    req.Description,
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.SettleNo, nil
+}
+
+// WechatSharer 微信支付分账(APIv3)
+type WechatSharer struct {
+	client *wechat.ClientV3
+}
+
+// NewWechatSharer 创建微信支付分账实现
+func NewWechatSharer(client *wechat.ClientV3) *WechatSharer {
+	return &WechatSharer{client: client}
+}
+
+// Share 将交易资金分给商家微信商户号，剩余资金解冻给平台
+func (w *WechatSharer) Share(req *ShareRequest) (string, error) {
+	resp, err := w.client.ProfitSharing(&wechat.ProfitSharingRequest{
+		TransactionID: req.TradeNo,
+		OutOrderNo:    req.OutRequestNo,
+		Receivers: []wechat.ProfitSharingReceiver{{
+			Type:        wechat.ProfitSharingReceiverMerchant,
+			Account:     req.Account,
+			Amount:      req.Amount,
+			Description: req.Description,
+		}},
+		UnfreezeUnsplit: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.OrderID, nil
+}
@@ -0,0 +1,529 @@
+package settlement
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/pagination"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// openAfterSaleStatuses 未关闭的售后状态，存在此类售后的订单暂不结算
+var openAfterSaleStatuses = []string{
+	model.AfterSaleStatusPending,
+	model.AfterSaleStatusApproved,
+	model.AfterSaleStatusReturning,
+	model.AfterSaleStatusRefunding,
+	model.AfterSaleStatusRefundFailed,
+}
+
+// itemRow 待结算订单商品项
+type itemRow struct {
+	OrderItemID    uint
+	OrderID        uint
+	OrderNo        string
+	ProductID      uint
+	ProductName    string
+	CategoryID     uint
+	Quantity       int
+	TotalPrice     decimal.Decimal
+	FinishTime     *time.Time
+	CommissionRate decimal.Decimal // 已结算商品项的原佣金比例
+}
+
+// errLinesSettled 结算明细已被并发生成的结算单写入
+var errLinesSettled = errors.New("结算明细已被其他结算单结算")
+
+// GenerateStatement 为商家生成截至 periodEnd 的结算单
+// 只结算订单完成时间早于 periodEnd 且已过售后期、无进行中售后及未结案支付争议的商品项；
+// 已结算商品项后续产生的退款以退款明细冲减，败诉的支付争议以拒付明细冲回，上期为负的结算单结转至本期。
+// 并发生成时明细唯一索引冲突视为已结算，重新生成时会排除这些明细
+func (s *Service) GenerateStatement(merchantID uint, periodEnd time.Time) (*model.SettlementStatement, error) {
+	statement, err := s.generateStatement(merchantID, periodEnd)
+	if err == errLinesSettled {
+		logger.Warn("结算明细已被并发生成的结算单结算，重新生成", zap.Uint("merchant_id", merchantID))
+		statement, err = s.generateStatement(merchantID, periodEnd)
+	}
+	if err == errLinesSettled {
+		return nil, model.ErrSettlementEmpty
+	}
+	return statement, err
+}
+
+// generateStatement 在事务中生成结算单，明细写入冲突时返回 errLinesSettled 并回滚
+func (s *Service) generateStatement(merchantID uint, periodEnd time.Time) (*model.SettlementStatement, error) {
+	now := time.Now()
+	if periodEnd.IsZero() || periodEnd.After(now) {
+		periodEnd = now
+	}
+	cutoff := periodEnd
+	if windowEnd := now.Add(-s.options.AfterSaleWindow); windowEnd.Before(cutoff) {
+		cutoff = windowEnd
+	}
+
+	var statement *model.SettlementStatement
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		resolver := newRateResolver(tx, s.options.DefaultCommissionRate)
+
+		saleLines, err := s.buildSaleLines(tx, resolver, merchantID, cutoff)
+		if err != nil {
+			return err
+		}
+		refundLines, err := s.buildRefundLines(tx, merchantID)
+		if err != nil {
+			return err
+		}
+		chargebackLines, err := s.buildChargebackLines(tx, resolver, merchantID)
+		if err != nil {
+			return err
+		}
+		carryLines, err := s.buildCarryLines(tx, merchantID)
+		if err != nil {
+			return err
+		}
+
+		lines := append(append(append(saleLines, refundLines...), chargebackLines...), carryLines...)
+		if len(lines) == 0 {
+			return model.ErrSettlementEmpty
+		}
+
+		statement = &model.SettlementStatement{
+			StatementNo: generateStatementNo(merchantID),
+			MerchantID:  merchantID,
+			Currency:    model.BaseCurrency,
+			PeriodStart: s.periodStart(tx, merchantID, lines, cutoff),
+			PeriodEnd:   cutoff,
+			LineCount:   len(lines),
+		}
+		for _, line := range lines {
+			if line.LineType == model.SettlementLineCarry {
+				statement.CarryAmount = statement.CarryAmount.Add(line.NetAmount)
+			}
+			statement.GrossAmount = statement.GrossAmount.Add(line.Amount)
+			statement.RefundAmount = statement.RefundAmount.Add(line.RefundAmount)
+			statement.ChargebackAmount = statement.ChargebackAmount.Add(line.ChargebackAmount)
+			statement.CommissionAmount = statement.CommissionAmount.Add(line.CommissionAmount)
+			statement.NetAmount = statement.NetAmount.Add(line.NetAmount)
+		}
+		switch {
+		case statement.NetAmount.IsNegative():
+			statement.Status = model.SettlementStatusCarried
+		case statement.NetAmount.IsZero():
+			statement.Status = model.SettlementStatusPaid
+			statement.PaidAt = &now
+		default:
+			statement.Status = model.SettlementStatusPending
+		}
+
+		if err := tx.Create(statement).Error; err != nil {
+			return fmt.Errorf("创建结算单失败: %v", err)
+		}
+		for i := range lines {
+			lines[i].StatementID = statement.ID
+		}
+		// 唯一索引冲突的明细不写入，说明已被并发生成的结算单结算，整单回滚后重新生成
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(lines, 200)
+		if result.Error != nil {
+			return fmt.Errorf("创建结算明细失败: %v", result.Error)
+		}
+		if result.RowsAffected < int64(len(lines)) {
+			return errLinesSettled
+		}
+		statement.Lines = lines
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("生成商家结算单",
+		zap.String("statement_no", statement.StatementNo),
+		zap.Uint("merchant_id", merchantID),
+		zap.Int("line_count", statement.LineCount),
+		zap.String("net_amount", statement.NetAmount.StringFixed(2)))
+	return statement, nil
+}
+
+// GenerateStatements 为所有有商品的商家生成结算单，没有可结算订单的商家跳过
+func (s *Service) GenerateStatements(periodEnd time.Time) ([]model.SettlementStatement, error) {
+	var merchantIDs []uint
+	if err := s.db.Model(&model.Product{}).Where("merchant_id > 0").Distinct().Pluck("merchant_id", &merchantIDs).Error; err != nil {
+		return nil, fmt.Errorf("查询商家失败: %v", err)
+	}
+
+	statements := make([]model.SettlementStatement, 0)
+	for _, merchantID := range merchantIDs {
+		statement, err := s.GenerateStatement(merchantID, periodEnd)
+		if err == model.ErrSettlementEmpty {
+			continue
+		}
+		if err != nil {
+			logger.Error("生成商家结算单失败", zap.Uint("merchant_id", merchantID), zap.Error(err))
+			continue
+		}
+		statement.Lines = nil
+		statements = append(statements, *statement)
+	}
+	return statements, nil
+}
+
+// buildSaleLines 生成销售明细
+func (s *Service) buildSaleLines(tx *gorm.DB, resolver *rateResolver, merchantID uint, cutoff time.Time) ([]model.SettlementLine, error) {
+	var rows []itemRow
+	err := tx.Table("order_items AS oi").
+		Select("oi.id AS order_item_id, oi.order_id, o.order_no, oi.product_id, oi.product_name, p.category_id, oi.quantity, oi.total_price, o.finish_time").
+		Joins("JOIN orders o ON o.id = oi.order_id AND o.deleted_at IS NULL").
+		Joins("JOIN products p ON p.id = oi.product_id").
+		Where("p.merchant_id = ? AND oi.deleted_at IS NULL", merchantID).
+		Where("o.status = ? AND o.finish_time IS NOT NULL AND o.finish_time <= ?", model.OrderStatusCompleted, cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM settlement_lines sl WHERE sl.order_item_id = oi.id AND sl.line_type = ?)", model.SettlementLineSale).
+		Where("NOT EXISTS (SELECT 1 FROM order_after_sales a WHERE a.order_id = o.id AND a.status IN ? AND a.deleted_at IS NULL)", openAfterSaleStatuses).
+		Where("NOT EXISTS (SELECT 1 FROM payment_disputes d WHERE d.order_id = o.id AND d.status IN ?)", model.OpenPaymentDisputeStatuses).
+		Order("oi.id ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询待结算订单失败: %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	refunds, err := itemRefunds(tx, orderIDsOf(rows))
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]model.SettlementLine, 0, len(rows))
+	for _, row := range rows {
+		rate, err := resolver.resolve(merchantID, row.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		refund := refunds[row.OrderItemID]
+		commission := commissionOf(row.TotalPrice.Sub(refund), rate)
+		lines = append(lines, model.SettlementLine{
+			MerchantID:       merchantID,
+			LineType:         model.SettlementLineSale,
+			OrderID:          row.OrderID,
+			OrderNo:          row.OrderNo,
+			OrderItemID:      row.OrderItemID,
+			ProductID:        row.ProductID,
+			ProductName:      row.ProductName,
+			CategoryID:       row.CategoryID,
+			Quantity:         row.Quantity,
+			Amount:           row.TotalPrice,
+			RefundAmount:     refund,
+			CommissionRate:   rate,
+			CommissionAmount: commission,
+			NetAmount:        row.TotalPrice.Sub(refund).Sub(commission),
+			FinishTime:       row.FinishTime,
+		})
+	}
+	return lines, nil
+}
+
+// buildRefundLines 为已结算商品项结算后新增的退款生成冲减明细，佣金按原比例退回
+func (s *Service) buildRefundLines(tx *gorm.DB, merchantID uint) ([]model.SettlementLine, error) {
+	var rows []itemRow
+	err := tx.Table("settlement_lines AS sl").
+		Select("sl.order_item_id, sl.order_id, sl.order_no, sl.product_id, sl.product_name, sl.category_id, sl.commission_rate").
+		Joins("JOIN orders o ON o.id = sl.order_id").
+		Where("sl.merchant_id = ? AND sl.line_type = ? AND o.refund_amount > 0", merchantID, model.SettlementLineSale).
+		Order("sl.order_item_id ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询已结算订单失败: %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	itemIDs := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		itemIDs = append(itemIDs, row.OrderItemID)
+	}
+	var settled []struct {
+		OrderItemID uint
+		Refunded    decimal.Decimal
+		RefundLines uint
+	}
+	err = tx.Model(&model.SettlementLine{}).
+		Select("order_item_id, SUM(refund_amount) AS refunded, SUM(CASE WHEN line_type = ? THEN 1 ELSE 0 END) AS refund_lines", model.SettlementLineRefund).
+		Where("merchant_id = ? AND order_item_id IN ?", merchantID, itemIDs).
+		Group("order_item_id").
+		Scan(&settled).Error
+	if err != nil {
+		return nil, fmt.Errorf("统计已结算退款失败: %v", err)
+	}
+	settledRefunds := make(map[uint]decimal.Decimal, len(settled))
+	refundLines := make(map[uint]uint, len(settled))
+	for _, item := range settled {
+		settledRefunds[item.OrderItemID] = item.Refunded
+		refundLines[item.OrderItemID] = item.RefundLines
+	}
+
+	refunds, err := itemRefunds(tx, orderIDsOf(rows))
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]model.SettlementLine, 0)
+	for _, row := range rows {
+		delta := refunds[row.OrderItemID].Sub(settledRefunds[row.OrderItemID])
+		if !delta.IsPositive() {
+			continue
+		}
+		commission := commissionOf(delta, row.CommissionRate).Neg()
+		lines = append(lines, model.SettlementLine{
+			MerchantID:       merchantID,
+			LineType:         model.SettlementLineRefund,
+			OrderID:          row.OrderID,
+			OrderNo:          row.OrderNo,
+			OrderItemID:      row.OrderItemID,
+			ProductID:        row.ProductID,
+			ProductName:      row.ProductName,
+			CategoryID:       row.CategoryID,
+			SourceID:         refundLines[row.OrderItemID] + 1,
+			RefundAmount:     delta,
+			CommissionRate:   row.CommissionRate,
+			CommissionAmount: commission,
+			NetAmount:        delta.Neg().Sub(commission),
+		})
+	}
+	return lines, nil
+}
+
+// buildChargebackLines 为败诉的支付争议生成拒付冲回明细
+// 按争议金额占支付金额的比例冲回商家各商品项金额，佣金按销售明细的原比例退回，尚未结算的商品项按当前费率计算
+func (s *Service) buildChargebackLines(tx *gorm.DB, resolver *rateResolver, merchantID uint) ([]model.SettlementLine, error) {
+	var disputes []struct {
+		ID            uint
+		OrderID       uint
+		OrderNo       string
+		Amount        decimal.Decimal
+		PaymentAmount decimal.Decimal
+	}
+	err := tx.Table("payment_disputes AS d").
+		Select("d.id, d.order_id, o.order_no, d.amount, p.amount AS payment_amount").
+		Joins("JOIN payments p ON p.id = d.payment_id").
+		Joins("JOIN orders o ON o.id = d.order_id").
+		Where("d.status = ?", model.PaymentDisputeLost).
+		Where("EXISTS (SELECT 1 FROM order_items oi JOIN products pr ON pr.id = oi.product_id WHERE oi.order_id = d.order_id AND pr.merchant_id = ? AND oi.deleted_at IS NULL)", merchantID).
+		Where("NOT EXISTS (SELECT 1 FROM settlement_lines sl WHERE sl.line_type = ? AND sl.source_id = d.id AND sl.merchant_id = ?)", model.SettlementLineChargeback, merchantID).
+		Order("d.id ASC").
+		Scan(&disputes).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询败诉支付争议失败: %v", err)
+	}
+
+	lines := make([]model.SettlementLine, 0)
+	for _, dispute := range disputes {
+		if !dispute.PaymentAmount.IsPositive() {
+			continue
+		}
+		ratio := dispute.Amount.Div(dispute.PaymentAmount)
+		if ratio.GreaterThan(decimal.NewFromInt(1)) {
+			ratio = decimal.NewFromInt(1)
+		}
+
+		var rows []struct {
+			OrderItemID    uint
+			ProductID      uint
+			ProductName    string
+			CategoryID     uint
+			TotalPrice     decimal.Decimal
+			CommissionRate decimal.Decimal // 销售明细的佣金比例
+			SaleLineID     uint            // 销售明细ID，尚未结算时为0
+		}
+		err := tx.Table("order_items AS oi").
+			Select("oi.id AS order_item_id, oi.product_id, oi.product_name, p.category_id, oi.total_price, COALESCE(sl.commission_rate, 0) AS commission_rate, COALESCE(sl.id, 0) AS sale_line_id").
+			Joins("JOIN products p ON p.id = oi.product_id").
+			Joins("LEFT JOIN settlement_lines sl ON sl.order_item_id = oi.id AND sl.line_type = ?", model.SettlementLineSale).
+			Where("oi.order_id = ? AND p.merchant_id = ? AND oi.deleted_at IS NULL", dispute.OrderID, merchantID).
+			Order("oi.id ASC").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, fmt.Errorf("查询争议订单商品失败: %v", err)
+		}
+
+		for _, row := range rows {
+			chargeback := row.TotalPrice.Mul(ratio).Round(2)
+			if !chargeback.IsPositive() {
+				continue
+			}
+			rate := row.CommissionRate
+			if row.SaleLineID == 0 {
+				if rate, err = resolver.resolve(merchantID, row.CategoryID); err != nil {
+					return nil, err
+				}
+			}
+			commission := commissionOf(chargeback, rate).Neg()
+			lines = append(lines, model.SettlementLine{
+				MerchantID:       merchantID,
+				LineType:         model.SettlementLineChargeback,
+				OrderID:          dispute.OrderID,
+				OrderNo:          dispute.OrderNo,
+				OrderItemID:      row.OrderItemID,
+				ProductID:        row.ProductID,
+				ProductName:      row.ProductName,
+				CategoryID:       row.CategoryID,
+				SourceID:         dispute.ID,
+				ChargebackAmount: chargeback,
+				CommissionRate:   rate,
+				CommissionAmount: commission,
+				NetAmount:        chargeback.Neg().Sub(commission),
+			})
+		}
+	}
+	return lines, nil
+}
+
+// buildCarryLines 将尚未结转的负数结算单结转至本期
+func (s *Service) buildCarryLines(tx *gorm.DB, merchantID uint) ([]model.SettlementLine, error) {
+	var carried []model.SettlementStatement
+	err := tx.Where("merchant_id = ? AND status = ?", merchantID, model.SettlementStatusCarried).
+		Where("NOT EXISTS (SELECT 1 FROM settlement_lines sl WHERE sl.line_type = ? AND sl.source_id = settlement_statements.id)", model.SettlementLineCarry).
+		Order("id ASC").
+		Find(&carried).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询待结转结算单失败: %v", err)
+	}
+
+	lines := make([]model.SettlementLine, 0, len(carried))
+	for _, statement := range carried {
+		lines = append(lines, model.SettlementLine{
+			MerchantID: merchantID,
+			LineType:   model.SettlementLineCarry,
+			SourceID:   statement.ID,
+			NetAmount:  statement.NetAmount,
+		})
+	}
+	return lines, nil
+}
+
+// periodStart 结算周期开始时间：上一张结算单的截止时间，首次结算取最早的订单完成时间
+func (s *Service) periodStart(tx *gorm.DB, merchantID uint, lines []model.SettlementLine, cutoff time.Time) time.Time {
+	var last model.SettlementStatement
+	if err := tx.Where("merchant_id = ?", merchantID).Order("period_end DESC").First(&last).Error; err == nil {
+		return last.PeriodEnd
+	}
+
+	start := cutoff
+	for _, line := range lines {
+		if line.FinishTime != nil && line.FinishTime.Before(start) {
+			start = *line.FinishTime
+		}
+	}
+	return start
+}
+
+// itemRefunds 计算订单商品项的累计退款金额
+// 商品项售后的退款直接计入该商品项；整单售后的退款按商品金额比例分摊
+func itemRefunds(tx *gorm.DB, orderIDs []uint) (map[uint]decimal.Decimal, error) {
+	var orders []model.Order
+	if err := tx.Select("id", "refund_amount").Where("id IN ?", orderIDs).Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("查询订单退款失败: %v", err)
+	}
+	var items []model.OrderItem
+	if err := tx.Select("id", "order_id", "total_price", "refund_amount").Where("order_id IN ?", orderIDs).Order("id ASC").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("查询订单商品退款失败: %v", err)
+	}
+
+	itemsByOrder := make(map[uint][]model.OrderItem)
+	for _, item := range items {
+		itemsByOrder[item.OrderID] = append(itemsByOrder[item.OrderID], item)
+	}
+
+	refunds := make(map[uint]decimal.Decimal, len(items))
+	for _, order := range orders {
+		orderItems := itemsByOrder[order.ID]
+		total := decimal.Zero
+		itemRefunded := decimal.Zero
+		for _, item := range orderItems {
+			total = total.Add(item.TotalPrice)
+			itemRefunded = itemRefunded.Add(item.RefundAmount)
+		}
+		orderLevel := order.RefundAmount.Sub(itemRefunded)
+
+		for _, item := range orderItems {
+			refund := item.RefundAmount
+			if orderLevel.IsPositive() && total.IsPositive() {
+				refund = refund.Add(orderLevel.Mul(item.TotalPrice).Div(total).Round(2))
+			}
+			if refund.GreaterThan(item.TotalPrice) {
+				refund = item.TotalPrice
+			}
+			refunds[item.ID] = refund
+		}
+	}
+	return refunds, nil
+}
+
+// orderIDsOf 去重提取订单ID
+func orderIDsOf(rows []itemRow) []uint {
+	seen := make(map[uint]bool)
+	ids := make([]uint, 0)
+	for _, row := range rows {
+		if !seen[row.OrderID] {
+			seen[row.OrderID] = true
+			ids = append(ids, row.OrderID)
+		}
+	}
+	return ids
+}
+
+// generateStatementNo 生成结算单号
+func generateStatementNo(merchantID uint) string {
+	return fmt.Sprintf("ST%d%04d", time.Now().UnixNano(), merchantID%10000)
+}
+
+// ListStatements 分页查询结算单
+func (s *Service) ListStatements(query *model.SettlementStatementQuery) ([]model.SettlementStatement, int64, error) {
+	page, pageSize := pagination.Normalize(query.Page, query.PageSize)
+
+	db := s.db.Model(&model.SettlementStatement{})
+	if query.MerchantID > 0 {
+		db = db.Where("merchant_id = ?", query.MerchantID)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计结算单失败: %v", err)
+	}
+
+	var statements []model.SettlementStatement
+	if err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&statements).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询结算单失败: %v", err)
+	}
+	return statements, total, nil
+}
+
+// GetStatement 查询结算单详情（含明细与打款记录），merchantID 不为0时校验归属
+func (s *Service) GetStatement(id, merchantID uint) (*model.SettlementStatement, error) {
+	var statement model.SettlementStatement
+	query := s.db.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).Preload("Payouts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	})
+	if merchantID > 0 {
+		query = query.Where("merchant_id = ?", merchantID)
+	}
+	if err := query.First(&statement, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, model.ErrSettlementNotFound
+		}
+		return nil, fmt.Errorf("查询结算单失败: %v", err)
+	}
+	return &statement, nil
+}
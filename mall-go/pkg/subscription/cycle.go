@@ -0,0 +1,399 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/order"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// RunDue 处理到期的订阅：生成新一期订单并扣款，或重试催缴中的周期
+// 返回本轮处理的订阅数
+func (s *Service) RunDue(now time.Time) int {
+	var ids []uint
+	err := s.db.Model(&model.Subscription{}).
+		Where("status = ? AND next_run_at <= ? AND (locked_until IS NULL OR locked_until < ?)", model.SubscriptionActive, now, now).
+		Order("next_run_at").
+		Limit(s.options.BatchSize).
+		Pluck("id", &ids).Error
+	if err != nil {
+		logger.Error("查询到期订阅失败", zap.Error(err))
+		return 0
+	}
+
+	processed := 0
+	for _, id := range ids {
+		sub, ok := s.claim(id, now)
+		if !ok {
+			continue
+		}
+		s.process(sub, now)
+		processed++
+	}
+	return processed
+}
+
+// claim 认领到期订阅，多实例部署时只有一个实例能认领成功
+func (s *Service) claim(id uint, now time.Time) (*model.Subscription, bool) {
+	lockedUntil := now.Add(s.options.LockTimeout)
+	result := s.db.Model(&model.Subscription{}).
+		Where("id = ? AND status = ? AND next_run_at <= ? AND (locked_until IS NULL OR locked_until < ?)", id, model.SubscriptionActive, now, now).
+		Update("locked_until", lockedUntil)
+	if result.Error != nil {
+		logger.Error("认领订阅失败", zap.Uint("subscription_id", id), zap.Error(result.Error))
+		return nil, false
+	}
+	if result.RowsAffected == 0 {
+		return nil, false
+	}
+
+	var sub model.Subscription
+	if err := s.db.Preload("Plan").First(&sub, id).Error; err != nil {
+		logger.Error("查询订阅失败", zap.Uint("subscription_id", id), zap.Error(err))
+		s.release(&sub, id, map[string]interface{}{})
+		return nil, false
+	}
+	return &sub, true
+}
+
+// release 保存处理结果并释放认领
+func (s *Service) release(sub *model.Subscription, id uint, updates map[string]interface{}) {
+	updates["locked_until"] = nil
+	if err := s.db.Model(&model.Subscription{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		logger.Error("更新订阅失败",
+			zap.String("subscription_no", sub.SubscriptionNo),
+			zap.Error(err))
+	}
+}
+
+// process 处理一个已认领的订阅
+func (s *Service) process(sub *model.Subscription, now time.Time) {
+	if sub.Plan == nil {
+		logger.Error("订阅计划不存在", zap.String("subscription_no", sub.SubscriptionNo), zap.Uint("plan_id", sub.PlanID))
+		s.release(sub, sub.ID, map[string]interface{}{})
+		return
+	}
+
+	if sub.DunningCycleID > 0 {
+		s.retryDunning(sub, now)
+		return
+	}
+	s.runCycle(sub, now)
+}
+
+// runCycle 排期新一期：跳过、缺货处理或下单扣款
+func (s *Service) runCycle(sub *model.Subscription, now time.Time) {
+	cycle := &model.SubscriptionCycle{
+		SubscriptionID: sub.ID,
+		CycleNo:        sub.CycleCount + 1,
+		ScheduledAt:    sub.NextRunAt,
+	}
+	nextRunAt := nextRunAfter(sub.Plan, cycle.ScheduledAt, now)
+
+	if sub.SkipNext {
+		cycle.Status = model.SubscriptionCycleSkipped
+		if err := s.db.Create(cycle).Error; err != nil {
+			logger.Error("创建订阅周期失败", zap.String("subscription_no", sub.SubscriptionNo), zap.Error(err))
+			s.release(sub, sub.ID, map[string]interface{}{})
+			return
+		}
+		s.release(sub, sub.ID, map[string]interface{}{
+			"cycle_count": cycle.CycleNo,
+			"skip_next":   false,
+			"next_run_at": nextRunAt,
+		})
+		return
+	}
+
+	orderNo := fmt.Sprintf("SO%d%04d", time.Now().UnixNano(), sub.ID%10000)
+	createdOrder, err := s.orders.CreateSubscriptionOrder(sub.UserID, orderNo, orderRequest(sub), order.SubscriptionOrderItem{
+		ProductID: sub.Plan.ProductID,
+		SKUID:     sub.Plan.SKUID,
+		Quantity:  sub.Quantity,
+	}, sub.Plan.DiscountRate)
+	if err != nil {
+		if errors.Is(err, model.ErrOrderItemUnavailable) {
+			s.handleOutOfStock(sub, cycle, err, nextRunAt, now)
+			return
+		}
+		// 其他错误不占用期数，释放后下一轮重试
+		logger.Error("生成订阅订单失败", zap.String("subscription_no", sub.SubscriptionNo), zap.Error(err))
+		s.release(sub, sub.ID, map[string]interface{}{})
+		return
+	}
+
+	cycle.Status = model.SubscriptionCycleDunning
+	cycle.OrderID = createdOrder.ID
+	cycle.Amount = createdOrder.PayableAmount
+	if err := s.db.Create(cycle).Error; err != nil {
+		logger.Error("创建订阅周期失败", zap.String("subscription_no", sub.SubscriptionNo), zap.Error(err))
+		if cancelErr := s.statuses.UpdateOrderStatus(createdOrder.ID, model.OrderStatusCancelled, 0, model.OperatorTypeSystem, "订阅周期创建失败", ""); cancelErr != nil {
+			logger.Error("取消订阅周期订单失败", zap.Uint("order_id", createdOrder.ID), zap.Error(cancelErr))
+		}
+		s.release(sub, sub.ID, map[string]interface{}{})
+		return
+	}
+
+	s.charge(sub, cycle, createdOrder, map[string]interface{}{"cycle_count": cycle.CycleNo}, now)
+}
+
+// handleOutOfStock 缺货时记录周期并按计划跳过或暂停订阅
+func (s *Service) handleOutOfStock(sub *model.Subscription, cycle *model.SubscriptionCycle, cause error, nextRunAt, now time.Time) {
+	cycle.Status = model.SubscriptionCycleOutOfStock
+	cycle.Error = truncate(cause.Error(), 500)
+	if err := s.db.Create(cycle).Error; err != nil {
+		logger.Error("创建订阅周期失败", zap.String("subscription_no", sub.SubscriptionNo), zap.Error(err))
+		s.release(sub, sub.ID, map[string]interface{}{})
+		return
+	}
+
+	updates := map[string]interface{}{
+		"cycle_count": cycle.CycleNo,
+		"next_run_at": nextRunAt,
+	}
+	message := fmt.Sprintf("第%d期商品缺货，本期已跳过", cycle.CycleNo)
+	if sub.Plan.OutOfStockAction == model.SubscriptionStockPause {
+		updates["status"] = model.SubscriptionPaused
+		updates["pause_reason"] = model.SubscriptionPauseOutOfStock
+		updates["paused_at"] = now
+		message = fmt.Sprintf("第%d期商品缺货，订阅已暂停，补货后可恢复", cycle.CycleNo)
+	}
+	s.release(sub, sub.ID, updates)
+
+	logger.Warn("订阅商品缺货",
+		zap.String("subscription_no", sub.SubscriptionNo),
+		zap.Int("cycle_no", cycle.CycleNo),
+		zap.String("action", string(sub.Plan.OutOfStockAction)),
+		zap.Error(cause))
+	s.notifier.Notify(sub, EventOutOfStock, message)
+}
+
+// retryDunning 重试催缴中的周期；订单已被支付或取消时结束催缴
+func (s *Service) retryDunning(sub *model.Subscription, now time.Time) {
+	var cycle model.SubscriptionCycle
+	if err := s.db.First(&cycle, sub.DunningCycleID).Error; err != nil {
+		logger.Error("查询催缴周期失败", zap.String("subscription_no", sub.SubscriptionNo), zap.Error(err))
+		s.release(sub, sub.ID, map[string]interface{}{})
+		return
+	}
+	var cycleOrder model.Order
+	if err := s.db.First(&cycleOrder, cycle.OrderID).Error; err != nil {
+		logger.Error("查询订阅周期订单失败", zap.String("subscription_no", sub.SubscriptionNo), zap.Error(err))
+		s.release(sub, sub.ID, map[string]interface{}{})
+		return
+	}
+
+	switch {
+	case cycleOrder.Status == model.OrderStatusPending:
+		s.charge(sub, &cycle, &cycleOrder, map[string]interface{}{}, now)
+	case cycleOrder.IsPaid():
+		// 用户已自行支付
+		s.completeCycle(sub, &cycle, map[string]interface{}{}, now)
+	default:
+		// 订单已被取消或关闭
+		if err := s.db.Model(&cycle).Update("status", model.SubscriptionCycleCancelled).Error; err != nil {
+			logger.Error("更新订阅周期状态失败", zap.Uint("cycle_id", cycle.ID), zap.Error(err))
+		}
+		s.release(sub, sub.ID, map[string]interface{}{
+			"dunning_cycle_id": 0,
+			"next_run_at":      nextRunAfter(sub.Plan, cycle.ScheduledAt, now),
+		})
+	}
+}
+
+// charge 对周期订单扣款，成功后推进到下一期，失败进入催缴
+func (s *Service) charge(sub *model.Subscription, cycle *model.SubscriptionCycle, cycleOrder *model.Order, updates map[string]interface{}, now time.Time) {
+	cycle.Attempts++
+	err := s.pay(sub, cycleOrder, now)
+	if err == nil {
+		s.completeCycle(sub, cycle, updates, now)
+		return
+	}
+
+	logger.Warn("订阅扣款失败",
+		zap.String("subscription_no", sub.SubscriptionNo),
+		zap.Int("cycle_no", cycle.CycleNo),
+		zap.Int("attempts", cycle.Attempts),
+		zap.Error(err))
+
+	cycle.Error = truncate(err.Error(), 500)
+	if cycle.Attempts <= len(s.options.RetrySchedule) {
+		if err := s.db.Model(cycle).Updates(map[string]interface{}{
+			"status":   model.SubscriptionCycleDunning,
+			"attempts": cycle.Attempts,
+			"error":    cycle.Error,
+		}).Error; err != nil {
+			logger.Error("更新订阅周期失败", zap.Uint("cycle_id", cycle.ID), zap.Error(err))
+		}
+		retryAt := now.Add(s.options.RetrySchedule[cycle.Attempts-1])
+		updates["dunning_cycle_id"] = cycle.ID
+		updates["next_run_at"] = retryAt
+		s.release(sub, sub.ID, updates)
+		s.notifier.Notify(sub, EventPaymentFailed, fmt.Sprintf("第%d期扣款失败：%s，将于%s重试", cycle.CycleNo, err.Error(), retryAt.Format("2006-01-02 15:04")))
+		return
+	}
+
+	// 重试用尽：取消本期订单并暂停订阅
+	if err := s.db.Model(cycle).Updates(map[string]interface{}{
+		"attempts": cycle.Attempts,
+		"error":    cycle.Error,
+	}).Error; err != nil {
+		logger.Error("更新订阅周期失败", zap.Uint("cycle_id", cycle.ID), zap.Error(err))
+	}
+	s.cancelDunningCycle(sub, cycle.ID, 0, model.OperatorTypeSystem, "订阅扣款重试用尽")
+	updates["dunning_cycle_id"] = 0
+	updates["status"] = model.SubscriptionPaused
+	updates["pause_reason"] = model.SubscriptionPausePaymentFails
+	updates["paused_at"] = now
+	updates["next_run_at"] = nextRunAfter(sub.Plan, cycle.ScheduledAt, now)
+	s.release(sub, sub.ID, updates)
+	s.notifier.Notify(sub, EventDunningFailed, fmt.Sprintf("第%d期多次扣款失败，订单已取消，订阅已暂停", cycle.CycleNo))
+}
+
+// completeCycle 周期扣款成功，推进到下一期
+func (s *Service) completeCycle(sub *model.Subscription, cycle *model.SubscriptionCycle, updates map[string]interface{}, now time.Time) {
+	if err := s.db.Model(cycle).Updates(map[string]interface{}{
+		"status":   model.SubscriptionCyclePaid,
+		"attempts": cycle.Attempts,
+		"error":    "",
+		"paid_at":  now,
+	}).Error; err != nil {
+		logger.Error("更新订阅周期失败", zap.Uint("cycle_id", cycle.ID), zap.Error(err))
+	}
+
+	updates["completed_cycles"] = gorm.Expr("completed_cycles + 1")
+	updates["dunning_cycle_id"] = 0
+	updates["next_run_at"] = nextRunAfter(sub.Plan, cycle.ScheduledAt, now)
+	s.release(sub, sub.ID, updates)
+	s.notifier.Notify(sub, EventCycleOrdered, fmt.Sprintf("第%d期已下单并扣款 %s 元", cycle.CycleNo, cycle.Amount.StringFixed(2)))
+}
+
+// pay 按订阅扣款方式支付周期订单，记录支付单并将订单置为已支付
+func (s *Service) pay(sub *model.Subscription, cycleOrder *model.Order, now time.Time) error {
+	amount := cycleOrder.PayableAmount.Sub(cycleOrder.PaidAmount)
+	payment := &model.Payment{
+		PaymentNo:        fmt.Sprintf("SP%d%04d", time.Now().UnixNano(), cycleOrder.ID%10000),
+		OrderID:          cycleOrder.ID,
+		UserID:           sub.UserID,
+		PaymentType:      model.PaymentTypeOrder,
+		PaymentStatus:    model.PaymentStatusSuccess,
+		Amount:           amount,
+		ActualAmount:     amount,
+		Currency:         model.BaseCurrency,
+		SettlementAmount: amount,
+		ExchangeRate:     decimal.NewFromInt(1),
+		Subject:          fmt.Sprintf("订阅 %s 周期订单 %s", sub.SubscriptionNo, cycleOrder.OrderNo),
+		PaidAt:           &now,
+	}
+
+	switch sub.PayMethod {
+	case model.SubscriptionPayWallet:
+		payment.PaymentMethod = model.PaymentMethodBalance
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&model.User{}).
+				Where("id = ? AND balance >= ?", sub.UserID, amount).
+				Update("balance", gorm.Expr("balance - ?", amount))
+			if result.Error != nil {
+				return fmt.Errorf("扣减余额失败: %v", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return model.ErrInsufficientBalance
+			}
+			return s.recordPayment(tx, payment, cycleOrder)
+		})
+		if err != nil {
+			return err
+		}
+	case model.SubscriptionPayWithholding:
+		if s.withholding == nil {
+			return model.ErrWithholdingNotConfigured
+		}
+		agreement, err := s.findAgreement(sub.UserID, sub.AgreementID)
+		if err != nil {
+			return err
+		}
+		result, err := s.withholding.Withhold(agreement, payment.PaymentNo, amount, payment.Subject)
+		if err != nil {
+			return fmt.Errorf("渠道代扣失败: %v", err)
+		}
+		payment.PaymentMethod = agreement.Channel
+		payment.ThirdPartyID = result.TradeNo
+		if !result.PaidAt.IsZero() {
+			payment.PaidAt = &result.PaidAt
+		}
+		if err := s.db.Transaction(func(tx *gorm.DB) error {
+			return s.recordPayment(tx, payment, cycleOrder)
+		}); err != nil {
+			// 渠道已扣款，记录失败需人工核对，不再重复扣款
+			logger.Error("代扣成功但记录支付失败",
+				zap.String("payment_no", payment.PaymentNo),
+				zap.String("trade_no", result.TradeNo),
+				zap.Error(err))
+			return nil
+		}
+	default:
+		return fmt.Errorf("不支持的扣款方式: %s", sub.PayMethod)
+	}
+
+	if err := s.statuses.UpdateOrderStatus(cycleOrder.ID, model.OrderStatusPaid, 0, model.OperatorTypeSystem, "订阅自动扣款", payment.PaymentNo); err != nil {
+		logger.Error("更新订阅周期订单状态失败",
+			zap.Uint("order_id", cycleOrder.ID),
+			zap.String("payment_no", payment.PaymentNo),
+			zap.Error(err))
+	}
+	return nil
+}
+
+// recordPayment 创建支付单并累加订单已付金额
+func (s *Service) recordPayment(tx *gorm.DB, payment *model.Payment, cycleOrder *model.Order) error {
+	if err := tx.Create(payment).Error; err != nil {
+		return fmt.Errorf("创建支付记录失败: %v", err)
+	}
+	if err := tx.Model(&model.Order{}).Where("id = ?", cycleOrder.ID).Updates(map[string]interface{}{
+		"paid_amount":    gorm.Expr("paid_amount + ?", payment.Amount),
+		"payment_status": model.PaymentStatusPaid,
+		"payment_type":   string(payment.PaymentMethod),
+	}).Error; err != nil {
+		return fmt.Errorf("更新订单支付金额失败: %v", err)
+	}
+	return nil
+}
+
+// orderRequest 以订阅收货信息构造下单请求
+func orderRequest(sub *model.Subscription) *model.OrderCreateRequest {
+	return &model.OrderCreateRequest{
+		ReceiverName:    sub.ReceiverName,
+		ReceiverPhone:   sub.ReceiverPhone,
+		ReceiverAddress: sub.ReceiverAddress,
+		ReceiverZipCode: sub.ReceiverZipCode,
+		Province:        sub.Province,
+		City:            sub.City,
+		District:        sub.District,
+		BuyerMessage:    "订阅自动下单 " + sub.SubscriptionNo,
+	}
+}
+
+// nextRunAfter 从计划时间推算下一期，停机等原因错过的周期不补单
+func nextRunAfter(plan *model.SubscriptionPlan, scheduledAt, now time.Time) time.Time {
+	next := plan.NextRun(scheduledAt)
+	if !next.After(now) {
+		next = plan.NextRun(now)
+	}
+	return next
+}
+
+// truncate 截断过长的错误信息
+func truncate(value string, size int) string {
+	runes := []rune(value)
+	if len(runes) <= size {
+		return value
+	}
+	return string(runes[:size])
+}
@@ -0,0 +1,64 @@
+package subscription
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mall-go/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// Scheduler 订阅调度任务，按间隔扫描到期订阅
+type Scheduler struct {
+	service *Service
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewScheduler 创建并启动订阅调度任务
+func NewScheduler(service *Service) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler := &Scheduler{
+		service: service,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	scheduler.wg.Add(1)
+	go scheduler.run()
+
+	logger.Info("订阅调度任务启动",
+		zap.Duration("interval", service.options.ScanInterval),
+		zap.Int("batch_size", service.options.BatchSize))
+
+	return scheduler
+}
+
+// run 调度主循环
+func (j *Scheduler) run() {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(j.service.options.ScanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+			if processed := j.service.RunDue(time.Now()); processed > 0 {
+				logger.Info("订阅调度完成", zap.Int("processed", processed))
+			}
+		}
+	}
+}
+
+// Stop 停止订阅调度任务
+func (j *Scheduler) Stop() {
+	logger.Info("停止订阅调度任务")
+	j.cancel()
+	j.wg.Wait()
+}
@@ -0,0 +1,451 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/logger"
+	"mall-go/pkg/order"
+	"mall-go/pkg/pagination"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Options 订阅配置
+type Options struct {
+	ScanInterval  time.Duration   // 调度扫描间隔
+	BatchSize     int             // 每轮处理的到期订阅数
+	LockTimeout   time.Duration   // 认领租约时长，超时后其他实例可重新认领
+	RetrySchedule []time.Duration // 扣款失败后的催缴重试间隔，用尽后取消本期订单并暂停订阅
+}
+
+// DefaultOptions 默认订阅配置
+func DefaultOptions() Options {
+	return Options{
+		ScanInterval:  time.Minute,
+		BatchSize:     50,
+		LockTimeout:   5 * time.Minute,
+		RetrySchedule: []time.Duration{time.Hour, 24 * time.Hour, 72 * time.Hour},
+	}
+}
+
+// WithholdingResult 代扣结果
+type WithholdingResult struct {
+	TradeNo string    // 渠道交易号
+	PaidAt  time.Time // 扣款时间
+}
+
+// WithholdingClient 渠道代扣，按已签约协议从用户账户扣款
+type WithholdingClient interface {
+	Withhold(agreement *model.WithholdingAgreement, outTradeNo string, amount decimal.Decimal, subject string) (*WithholdingResult, error)
+}
+
+// Event 订阅通知事件
+type Event string
+
+const (
+	EventOutOfStock    Event = "out_of_stock"   // 缺货跳过或暂停
+	EventPaymentFailed Event = "payment_failed" // 扣款失败，等待重试
+	EventDunningFailed Event = "dunning_failed" // 重试用尽，订阅已暂停
+	EventCycleOrdered  Event = "cycle_ordered"  // 本期已下单并扣款
+	EventCancelled     Event = "cancelled"      // 订阅已取消
+)
+
+// Notifier 订阅通知，向用户推送缺货、扣款失败等消息
+type Notifier interface {
+	Notify(sub *model.Subscription, event Event, message string)
+}
+
+// logNotifier 默认通知实现，仅记录日志
+type logNotifier struct{}
+
+// Notify 记录通知日志
+func (logNotifier) Notify(sub *model.Subscription, event Event, message string) {
+	logger.Info("订阅通知",
+		zap.String("subscription_no", sub.SubscriptionNo),
+		zap.Uint("user_id", sub.UserID),
+		zap.String("event", string(event)),
+		zap.String("message", message))
+}
+
+// Service 商品订阅服务
+// 用户订阅计划后，调度器按周期通过订单服务生成订单并从余额或代扣协议扣款；
+// 缺货时按计划跳过或暂停，扣款失败进入催缴重试
+type Service struct {
+	db          *gorm.DB
+	orders      *order.OrderService
+	statuses    *order.StatusService
+	withholding WithholdingClient
+	notifier    Notifier
+	options     Options
+	now         func() time.Time
+}
+
+// NewService 创建订阅服务
+func NewService(db *gorm.DB, orders *order.OrderService, statuses *order.StatusService, options Options) *Service {
+	return &Service{
+		db:       db,
+		orders:   orders,
+		statuses: statuses,
+		notifier: logNotifier{},
+		options:  options,
+		now:      time.Now,
+	}
+}
+
+// SetWithholdingClient 设置渠道代扣实现，未设置时代扣订阅扣款失败
+func (s *Service) SetWithholdingClient(client WithholdingClient) {
+	s.withholding = client
+}
+
+// SetNotifier 设置订阅通知实现
+func (s *Service) SetNotifier(notifier Notifier) {
+	s.notifier = notifier
+}
+
+// CreatePlan 创建订阅计划
+func (s *Service) CreatePlan(req *model.SubscriptionPlanRequest) (*model.SubscriptionPlan, error) {
+	plan := &model.SubscriptionPlan{}
+	if err := s.applyPlan(plan, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(plan).Error; err != nil {
+		return nil, fmt.Errorf("创建订阅计划失败: %v", err)
+	}
+	return plan, nil
+}
+
+// UpdatePlan 更新订阅计划，已有订阅在下一期按新计划执行
+func (s *Service) UpdatePlan(id uint, req *model.SubscriptionPlanRequest) (*model.SubscriptionPlan, error) {
+	plan, err := s.findPlan(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyPlan(plan, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(plan).Error; err != nil {
+		return nil, fmt.Errorf("更新订阅计划失败: %v", err)
+	}
+	return plan, nil
+}
+
+// ListPlans 查询订阅计划，productID 为0时查询全部
+func (s *Service) ListPlans(productID uint, activeOnly bool) ([]model.SubscriptionPlan, error) {
+	query := s.db.Model(&model.SubscriptionPlan{})
+	if productID > 0 {
+		query = query.Where("product_id = ?", productID)
+	}
+	if activeOnly {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var plans []model.SubscriptionPlan
+	if err := query.Order("id DESC").Find(&plans).Error; err != nil {
+		return nil, fmt.Errorf("查询订阅计划失败: %v", err)
+	}
+	return plans, nil
+}
+
+// applyPlan 校验并填充订阅计划
+func (s *Service) applyPlan(plan *model.SubscriptionPlan, req *model.SubscriptionPlanRequest) error {
+	if req.DiscountRate.IsNegative() || req.DiscountRate.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+		return model.ErrInvalidSubscriptionDiscount
+	}
+
+	var product model.Product
+	if err := s.db.Select("id").First(&product, req.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrSubscriptionProductInvalid
+		}
+		return fmt.Errorf("查询商品失败: %v", err)
+	}
+	if req.SKUID > 0 {
+		var count int64
+		if err := s.db.Model(&model.ProductSKU{}).Where("id = ? AND product_id = ?", req.SKUID, req.ProductID).Count(&count).Error; err != nil {
+			return fmt.Errorf("查询商品规格失败: %v", err)
+		}
+		if count == 0 {
+			return model.ErrSubscriptionProductInvalid
+		}
+	}
+
+	plan.ProductID = req.ProductID
+	plan.SKUID = req.SKUID
+	plan.Name = req.Name
+	plan.IntervalUnit = req.IntervalUnit
+	plan.IntervalCount = req.IntervalCount
+	plan.DiscountRate = req.DiscountRate
+	plan.MinCycles = req.MinCycles
+	plan.OutOfStockAction = req.OutOfStockAction
+	if plan.OutOfStockAction == "" {
+		plan.OutOfStockAction = model.SubscriptionStockSkip
+	}
+	plan.IsActive = req.IsActive
+	return nil
+}
+
+// Subscribe 用户订阅计划，首期在 StartAt（为空时立即）由调度器下单
+func (s *Service) Subscribe(userID uint, req *model.CreateSubscriptionRequest) (*model.Subscription, error) {
+	plan, err := s.findPlan(req.PlanID)
+	if err != nil {
+		return nil, err
+	}
+	if !plan.IsActive {
+		return nil, model.ErrSubscriptionPlanInactive
+	}
+
+	if req.PayMethod == model.SubscriptionPayWithholding {
+		if _, err := s.findAgreement(userID, req.AgreementID); err != nil {
+			return nil, err
+		}
+	}
+
+	now := s.now()
+	nextRunAt := now
+	if req.StartAt != nil && req.StartAt.After(now) {
+		nextRunAt = *req.StartAt
+	}
+
+	sub := &model.Subscription{
+		SubscriptionNo:  fmt.Sprintf("SB%d%04d", time.Now().UnixNano(), userID%10000),
+		UserID:          userID,
+		PlanID:          plan.ID,
+		Quantity:        req.Quantity,
+		PayMethod:       req.PayMethod,
+		ReceiverName:    req.ReceiverName,
+		ReceiverPhone:   req.ReceiverPhone,
+		ReceiverAddress: req.ReceiverAddress,
+		ReceiverZipCode: req.ReceiverZipCode,
+		Province:        req.Province,
+		City:            req.City,
+		District:        req.District,
+		Status:          model.SubscriptionActive,
+		NextRunAt:       nextRunAt,
+	}
+	if req.PayMethod == model.SubscriptionPayWithholding {
+		sub.AgreementID = req.AgreementID
+	}
+	if err := s.db.Create(sub).Error; err != nil {
+		return nil, fmt.Errorf("创建订阅失败: %v", err)
+	}
+	sub.Plan = plan
+
+	logger.Info("创建订阅",
+		zap.String("subscription_no", sub.SubscriptionNo),
+		zap.Uint("user_id", userID),
+		zap.Uint("plan_id", plan.ID))
+	return sub, nil
+}
+
+// Get 查询用户的订阅详情及周期记录
+func (s *Service) Get(userID, id uint) (*model.Subscription, error) {
+	var sub model.Subscription
+	err := s.db.Preload("Plan").
+		Preload("Cycles", func(db *gorm.DB) *gorm.DB { return db.Order("cycle_no DESC") }).
+		Where("id = ? AND user_id = ?", id, userID).
+		First(&sub).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("查询订阅失败: %v", err)
+	}
+	return &sub, nil
+}
+
+// List 分页查询用户的订阅
+func (s *Service) List(userID uint, req *model.SubscriptionListRequest) ([]model.Subscription, int64, error) {
+	page, pageSize := pagination.Normalize(req.Page, req.PageSize)
+
+	query := s.db.Model(&model.Subscription{}).Where("user_id = ?", userID)
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计订阅失败: %v", err)
+	}
+
+	var subs []model.Subscription
+	if err := query.Preload("Plan").Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&subs).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询订阅失败: %v", err)
+	}
+	return subs, total, nil
+}
+
+// SkipNext 设置或撤销跳过下一期
+func (s *Service) SkipNext(userID, id uint, skip bool) (*model.Subscription, error) {
+	sub, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status == model.SubscriptionCancelled {
+		return nil, model.ErrSubscriptionCancelled
+	}
+
+	if err := s.updateUnlocked(sub, map[string]interface{}{"skip_next": skip}); err != nil {
+		return nil, err
+	}
+	sub.SkipNext = skip
+	return sub, nil
+}
+
+// Pause 用户暂停订阅，催缴中的周期同时停止重试
+func (s *Service) Pause(userID, id uint) (*model.Subscription, error) {
+	sub, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status != model.SubscriptionActive {
+		return nil, model.ErrSubscriptionNotActive
+	}
+
+	now := s.now()
+	if err := s.updateUnlocked(sub, map[string]interface{}{
+		"status":       model.SubscriptionPaused,
+		"pause_reason": model.SubscriptionPauseByUser,
+		"paused_at":    now,
+	}); err != nil {
+		return nil, err
+	}
+	sub.Status = model.SubscriptionPaused
+	sub.PauseReason = model.SubscriptionPauseByUser
+	sub.PausedAt = &now
+	return sub, nil
+}
+
+// Resume 恢复暂停的订阅，错过的周期不补单，下一期最早为当前时间
+func (s *Service) Resume(userID, id uint) (*model.Subscription, error) {
+	sub, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status != model.SubscriptionPaused {
+		return nil, model.ErrSubscriptionNotPaused
+	}
+
+	nextRunAt := sub.NextRunAt
+	if now := s.now(); nextRunAt.Before(now) {
+		nextRunAt = now
+	}
+	if err := s.updateUnlocked(sub, map[string]interface{}{
+		"status":       model.SubscriptionActive,
+		"pause_reason": "",
+		"paused_at":    nil,
+		"next_run_at":  nextRunAt,
+	}); err != nil {
+		return nil, err
+	}
+	sub.Status = model.SubscriptionActive
+	sub.PauseReason = ""
+	sub.PausedAt = nil
+	sub.NextRunAt = nextRunAt
+	return sub, nil
+}
+
+// Cancel 取消订阅，未满最少履约期数时不可取消；催缴中的周期订单一并取消
+func (s *Service) Cancel(userID, id uint, reason string) (*model.Subscription, error) {
+	sub, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status == model.SubscriptionCancelled {
+		return nil, model.ErrSubscriptionCancelled
+	}
+	if sub.Plan != nil && sub.CompletedCycles < sub.Plan.MinCycles {
+		return nil, fmt.Errorf("%w: 已履约 %d 期，至少 %d 期", model.ErrSubscriptionMinCycles, sub.CompletedCycles, sub.Plan.MinCycles)
+	}
+
+	now := s.now()
+	if err := s.updateUnlocked(sub, map[string]interface{}{
+		"status":           model.SubscriptionCancelled,
+		"cancelled_at":     now,
+		"cancel_reason":    reason,
+		"dunning_cycle_id": 0,
+	}); err != nil {
+		return nil, err
+	}
+
+	if sub.DunningCycleID > 0 {
+		s.cancelDunningCycle(sub, sub.DunningCycleID, userID, model.OperatorTypeUser, "取消订阅")
+	}
+
+	sub.Status = model.SubscriptionCancelled
+	sub.CancelledAt = &now
+	sub.CancelReason = reason
+	sub.DunningCycleID = 0
+	s.notifier.Notify(sub, EventCancelled, "订阅已取消")
+	return sub, nil
+}
+
+// updateUnlocked 在调度器未认领时更新订阅，避免与周期下单并发修改
+func (s *Service) updateUnlocked(sub *model.Subscription, updates map[string]interface{}) error {
+	result := s.db.Model(&model.Subscription{}).
+		Where("id = ? AND status = ? AND (locked_until IS NULL OR locked_until < ?)", sub.ID, sub.Status, s.now()).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("更新订阅失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrSubscriptionBusy
+	}
+	return nil
+}
+
+// cancelDunningCycle 取消催缴中的周期及其待支付订单，订单取消时恢复库存
+func (s *Service) cancelDunningCycle(sub *model.Subscription, cycleID uint, operatorID uint, operatorType, reason string) {
+	var cycle model.SubscriptionCycle
+	if err := s.db.First(&cycle, cycleID).Error; err != nil {
+		logger.Error("查询催缴周期失败", zap.Uint("cycle_id", cycleID), zap.Error(err))
+		return
+	}
+
+	if cycle.OrderID > 0 {
+		if err := s.statuses.UpdateOrderStatus(cycle.OrderID, model.OrderStatusCancelled, operatorID, operatorType, reason, "订阅周期订单未支付"); err != nil {
+			logger.Warn("取消订阅周期订单失败",
+				zap.String("subscription_no", sub.SubscriptionNo),
+				zap.Uint("order_id", cycle.OrderID),
+				zap.Error(err))
+		}
+	}
+
+	status := model.SubscriptionCycleCancelled
+	if operatorType == model.OperatorTypeSystem {
+		status = model.SubscriptionCyclePaymentFailed
+	}
+	if err := s.db.Model(&cycle).Update("status", status).Error; err != nil {
+		logger.Error("更新订阅周期状态失败", zap.Uint("cycle_id", cycleID), zap.Error(err))
+	}
+}
+
+// findPlan 查询订阅计划
+func (s *Service) findPlan(id uint) (*model.SubscriptionPlan, error) {
+	var plan model.SubscriptionPlan
+	if err := s.db.First(&plan, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrSubscriptionPlanNotFound
+		}
+		return nil, fmt.Errorf("查询订阅计划失败: %v", err)
+	}
+	return &plan, nil
+}
+
+// findAgreement 查询用户已签约的代扣协议
+func (s *Service) findAgreement(userID, id uint) (*model.WithholdingAgreement, error) {
+	var agreement model.WithholdingAgreement
+	err := s.db.Where("id = ? AND user_id = ? AND status = ?", id, userID, model.WithholdingAgreementSigned).
+		First(&agreement).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.ErrWithholdingAgreementInvalid
+		}
+		return nil, fmt.Errorf("查询代扣协议失败: %v", err)
+	}
+	return &agreement, nil
+}
@@ -0,0 +1,252 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+
+	"mall-go/internal/model"
+	"mall-go/pkg/cart"
+	"mall-go/pkg/inventory"
+	"mall-go/pkg/order"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var testNow = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+// SubscriptionServiceTestSuite 商品订阅服务测试套件
+type SubscriptionServiceTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service *Service
+}
+
+// SetupTest 每个用例使用独立的内存数据库，当前时间固定为 testNow
+func (suite *SubscriptionServiceTestSuite) SetupTest() {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.AutoMigrate(
+		&model.User{}, &model.Product{}, &model.ProductSKU{},
+		&model.Order{}, &model.OrderItem{}, &model.OrderStatusLog{}, &model.Payment{},
+		&model.SubscriptionPlan{}, &model.Subscription{}, &model.SubscriptionCycle{}, &model.WithholdingAgreement{},
+	))
+
+	orders := order.NewOrderService(db, cart.NewCartService(db), cart.NewCalculationService(db), inventory.NewInventoryService(db, nil))
+	suite.db = db
+	suite.service = NewService(db, orders, order.NewStatusService(db), DefaultOptions())
+	suite.service.now = func() time.Time { return testNow }
+}
+
+// createFixture 创建用户、商品与订阅计划
+func (suite *SubscriptionServiceTestSuite) createFixture(balance string, stock int, action model.SubscriptionStockAction) (*model.User, *model.Product, *model.SubscriptionPlan) {
+	user := &model.User{Username: "buyer", Email: "buyer@example.com", Password: "x", Balance: decimal.RequireFromString(balance)}
+	suite.Require().NoError(suite.db.Create(user).Error)
+	product := &model.Product{Name: "桶装水", Price: decimal.NewFromFloat(100.00), Stock: stock, Status: model.ProductStatusActive}
+	suite.Require().NoError(suite.db.Create(product).Error)
+
+	plan, err := suite.service.CreatePlan(&model.SubscriptionPlanRequest{
+		ProductID:        product.ID,
+		Name:             "每月一桶",
+		IntervalUnit:     model.SubscriptionIntervalMonth,
+		IntervalCount:    1,
+		DiscountRate:     decimal.NewFromFloat(0.1),
+		MinCycles:        2,
+		OutOfStockAction: action,
+		IsActive:         true,
+	})
+	suite.Require().NoError(err)
+	return user, product, plan
+}
+
+func (suite *SubscriptionServiceTestSuite) subscribe(userID, planID uint) *model.Subscription {
+	sub, err := suite.service.Subscribe(userID, &model.CreateSubscriptionRequest{
+		PlanID:          planID,
+		Quantity:        1,
+		PayMethod:       model.SubscriptionPayWallet,
+		ReceiverName:    "张三",
+		ReceiverPhone:   "13800000000",
+		ReceiverAddress: "测试路1号",
+		Province:        "广东",
+		City:            "深圳",
+		District:        "南山",
+	})
+	suite.Require().NoError(err)
+	return sub
+}
+
+func (suite *SubscriptionServiceTestSuite) TestService_WalletCycleAndSkipNext() {
+	user, product, plan := suite.createFixture("200.00", 10, model.SubscriptionStockSkip)
+	sub := suite.subscribe(user.ID, plan.ID)
+
+	suite.Equal(1, suite.service.RunDue(testNow))
+	// 已处理的订阅不会被重复下单
+	suite.Equal(0, suite.service.RunDue(testNow))
+
+	sub, err := suite.service.Get(user.ID, sub.ID)
+	suite.Require().NoError(err)
+	suite.Equal(1, sub.CompletedCycles)
+	suite.Equal(testNow.AddDate(0, 1, 0), sub.NextRunAt.UTC())
+	suite.Nil(sub.LockedUntil)
+	suite.Require().Len(sub.Cycles, 1)
+	cycle := sub.Cycles[0]
+	suite.Equal(model.SubscriptionCyclePaid, cycle.Status)
+	suite.True(cycle.Amount.Equal(decimal.NewFromInt(90)))
+
+	var cycleOrder model.Order
+	suite.Require().NoError(suite.db.First(&cycleOrder, cycle.OrderID).Error)
+	suite.Equal(model.OrderTypeSubscription, cycleOrder.OrderType)
+	suite.Equal(model.OrderStatusPaid, cycleOrder.Status)
+	suite.True(cycleOrder.DiscountAmount.Equal(decimal.NewFromInt(10)))
+	suite.Nil(cycleOrder.PayExpireTime)
+
+	var payment model.Payment
+	suite.Require().NoError(suite.db.Where("order_id = ?", cycleOrder.ID).First(&payment).Error)
+	suite.Equal(model.PaymentMethodBalance, payment.PaymentMethod)
+	suite.Equal(model.PaymentStatusSuccess, payment.PaymentStatus)
+
+	suite.Require().NoError(suite.db.First(user, user.ID).Error)
+	suite.True(user.Balance.Equal(decimal.NewFromInt(110)))
+	suite.Require().NoError(suite.db.First(product, product.ID).Error)
+	suite.Equal(9, product.Stock)
+
+	// 未满最少履约期数不可取消
+	_, err = suite.service.Cancel(user.ID, sub.ID, "")
+	suite.ErrorIs(err, model.ErrSubscriptionMinCycles)
+
+	// 跳过下一期：不下单，期数推进
+	_, err = suite.service.SkipNext(user.ID, sub.ID, true)
+	suite.Require().NoError(err)
+	next := testNow.AddDate(0, 1, 0)
+	suite.Equal(1, suite.service.RunDue(next))
+
+	sub, err = suite.service.Get(user.ID, sub.ID)
+	suite.Require().NoError(err)
+	suite.False(sub.SkipNext)
+	suite.Equal(2, sub.CycleCount)
+	suite.Equal(1, sub.CompletedCycles)
+	suite.Equal(model.SubscriptionCycleSkipped, sub.Cycles[0].Status)
+	suite.Equal(testNow.AddDate(0, 2, 0), sub.NextRunAt.UTC())
+}
+
+func (suite *SubscriptionServiceTestSuite) TestService_Dunning() {
+	user, product, plan := suite.createFixture("0", 10, model.SubscriptionStockSkip)
+	sub := suite.subscribe(user.ID, plan.ID)
+
+	// 余额不足进入催缴，按重试计划推迟
+	now := testNow
+	suite.service.RunDue(now)
+	suite.Require().NoError(suite.db.First(sub, sub.ID).Error)
+	suite.Require().NotZero(sub.DunningCycleID)
+	suite.Equal(now.Add(time.Hour), sub.NextRunAt.UTC())
+
+	var cycle model.SubscriptionCycle
+	suite.Require().NoError(suite.db.First(&cycle, sub.DunningCycleID).Error)
+	suite.Equal(model.SubscriptionCycleDunning, cycle.Status)
+	suite.Equal(1, cycle.Attempts)
+	suite.Contains(cycle.Error, model.ErrInsufficientBalance.Error())
+
+	// 催缴期间暂停不重试，恢复后继续
+	_, err := suite.service.Pause(user.ID, sub.ID)
+	suite.Require().NoError(err)
+	suite.Equal(0, suite.service.RunDue(now.Add(2*time.Hour)))
+	_, err = suite.service.Resume(user.ID, sub.ID)
+	suite.Require().NoError(err)
+
+	// 重试用尽：取消本期订单、恢复库存并暂停订阅
+	for _, retry := range DefaultOptions().RetrySchedule {
+		now = now.Add(retry)
+		suite.Require().NoError(suite.db.Model(&model.Subscription{}).Where("id = ?", sub.ID).Update("next_run_at", now).Error)
+		suite.Equal(1, suite.service.RunDue(now))
+	}
+
+	suite.Require().NoError(suite.db.First(sub, sub.ID).Error)
+	suite.Equal(model.SubscriptionPaused, sub.Status)
+	suite.Equal(model.SubscriptionPausePaymentFails, sub.PauseReason)
+	suite.Zero(sub.DunningCycleID)
+
+	suite.Require().NoError(suite.db.First(&cycle, cycle.ID).Error)
+	suite.Equal(model.SubscriptionCyclePaymentFailed, cycle.Status)
+	suite.Equal(4, cycle.Attempts)
+
+	var cycleOrder model.Order
+	suite.Require().NoError(suite.db.First(&cycleOrder, cycle.OrderID).Error)
+	suite.Equal(model.OrderStatusCancelled, cycleOrder.Status)
+	suite.Require().NoError(suite.db.First(product, product.ID).Error)
+	suite.Equal(10, product.Stock)
+
+	// 充值后恢复，下一期扣款成功
+	suite.Require().NoError(suite.db.Model(user).Update("balance", decimal.NewFromInt(100)).Error)
+	_, err = suite.service.Resume(user.ID, sub.ID)
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.db.First(sub, sub.ID).Error)
+	suite.Equal(1, suite.service.RunDue(sub.NextRunAt))
+	suite.Require().NoError(suite.db.First(sub, sub.ID).Error)
+	suite.Equal(1, sub.CompletedCycles)
+	suite.Equal(2, sub.CycleCount)
+}
+
+func (suite *SubscriptionServiceTestSuite) TestService_OutOfStock() {
+	user, _, plan := suite.createFixture("500.00", 0, model.SubscriptionStockSkip)
+	skipSub := suite.subscribe(user.ID, plan.ID)
+
+	pausePlan, err := suite.service.CreatePlan(&model.SubscriptionPlanRequest{
+		ProductID: plan.ProductID, Name: "每周一桶", IntervalUnit: model.SubscriptionIntervalWeek, IntervalCount: 1,
+		OutOfStockAction: model.SubscriptionStockPause, IsActive: true,
+	})
+	suite.Require().NoError(err)
+	pauseSub := suite.subscribe(user.ID, pausePlan.ID)
+
+	suite.Equal(2, suite.service.RunDue(testNow))
+
+	skipSub, err = suite.service.Get(user.ID, skipSub.ID)
+	suite.Require().NoError(err)
+	suite.Equal(model.SubscriptionActive, skipSub.Status)
+	suite.Equal(testNow.AddDate(0, 1, 0), skipSub.NextRunAt.UTC())
+	suite.Require().Len(skipSub.Cycles, 1)
+	suite.Equal(model.SubscriptionCycleOutOfStock, skipSub.Cycles[0].Status)
+	suite.Zero(skipSub.Cycles[0].OrderID)
+
+	pauseSub, err = suite.service.Get(user.ID, pauseSub.ID)
+	suite.Require().NoError(err)
+	suite.Equal(model.SubscriptionPaused, pauseSub.Status)
+	suite.Equal(model.SubscriptionPauseOutOfStock, pauseSub.PauseReason)
+
+	var orders int64
+	suite.Require().NoError(suite.db.Model(&model.Order{}).Count(&orders).Error)
+	suite.Zero(orders)
+}
+
+func (suite *SubscriptionServiceTestSuite) TestService_Validation() {
+	user, _, plan := suite.createFixture("0", 10, model.SubscriptionStockSkip)
+
+	_, err := suite.service.CreatePlan(&model.SubscriptionPlanRequest{
+		ProductID: plan.ProductID, Name: "免费", IntervalUnit: model.SubscriptionIntervalDay, IntervalCount: 1, DiscountRate: decimal.NewFromInt(1),
+	})
+	suite.ErrorIs(err, model.ErrInvalidSubscriptionDiscount)
+
+	_, err = suite.service.Subscribe(user.ID, &model.CreateSubscriptionRequest{
+		PlanID: plan.ID, Quantity: 1, PayMethod: model.SubscriptionPayWithholding, AgreementID: 99,
+	})
+	suite.ErrorIs(err, model.ErrWithholdingAgreementInvalid)
+
+	// 其他用户的订阅不可见
+	sub := suite.subscribe(user.ID, plan.ID)
+	_, err = suite.service.Pause(user.ID+1, sub.ID)
+	suite.ErrorIs(err, model.ErrSubscriptionNotFound)
+
+	// 调度器认领期间用户操作返回处理中
+	lockedUntil := testNow.Add(time.Minute)
+	suite.Require().NoError(suite.db.Model(sub).Update("locked_until", lockedUntil).Error)
+	_, err = suite.service.Pause(user.ID, sub.ID)
+	suite.ErrorIs(err, model.ErrSubscriptionBusy)
+
+	_, err = suite.service.Resume(user.ID, sub.ID)
+	suite.ErrorIs(err, model.ErrSubscriptionNotPaused)
+}
+
+func TestSubscriptionServiceSuite(t *testing.T) {
+	suite.Run(t, new(SubscriptionServiceTestSuite))
+}
@@ -216,6 +216,9 @@ func (vs *VerificationService) sendEmail(email, code, codeType string) error {
 	case model.VerificationTypeChangeEmail:
 		subject = "修改邮箱验证码"
 		content = fmt.Sprintf("您的修改邮箱验证码是：%s，有效期10分钟。", code)
+	case model.VerificationTypePaymentRisk:
+		subject = "支付安全验证码"
+		content = fmt.Sprintf("您正在进行支付，安全验证码是：%s，有效期10分钟。如非本人操作请立即修改密码。", code)
 	default:
 		subject = "验证码"
 		content = fmt.Sprintf("您的验证码是：%s，有效期10分钟。", code)
@@ -240,6 +243,8 @@ func (vs *VerificationService) sendSMS(phone, code, codeType string) error {
 		content = fmt.Sprintf("【商城】您的重置密码验证码是：%s，有效期5分钟。", code)
 	case model.VerificationTypeChangePhone:
 		content = fmt.Sprintf("【商城】您的修改手机号验证码是：%s，有效期5分钟。", code)
+	case model.VerificationTypePaymentRisk:
+		content = fmt.Sprintf("【商城】您正在进行支付，安全验证码是：%s，有效期5分钟。如非本人操作请立即修改密码。", code)
 	default:
 		content = fmt.Sprintf("【商城】您的验证码是：%s，有效期5分钟。", code)
 	}
@@ -9,8 +9,10 @@ import (
 	"testing"
 	"time"
 
+	"mall-go/internal/config"
 	"mall-go/internal/handler/payment"
 	"mall-go/internal/model"
+	"mall-go/pkg/auth"
 	paymentPkg "mall-go/pkg/payment"
 	"mall-go/pkg/response"
 
@@ -30,6 +32,7 @@ type PaymentIntegrationTestSuite struct {
 	paymentService *paymentPkg.Service
 	testUser       *model.User
 	testOrder      *model.Order
+	token          string
 }
 
 // SetupSuite 设置测试套件
@@ -37,6 +40,14 @@ func (suite *PaymentIntegrationTestSuite) SetupSuite() {
 	// 设置Gin为测试模式
 	gin.SetMode(gin.TestMode)
 
+	// 初始化全局配置，支付路由需要JWT认证
+	config.GlobalConfig = config.Config{
+		JWT: config.JWTConfig{
+			Secret: "test-secret-key-for-jwt-token-generation",
+			Expire: "24h",
+		},
+	}
+
 	// 设置测试数据库
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	suite.Require().NoError(err)
@@ -64,7 +75,7 @@ func (suite *PaymentIntegrationTestSuite) SetupSuite() {
 	suite.Require().NoError(err)
 	suite.paymentService = paymentService
 
-	// 设置路由
+	// 设置路由，与生产环境使用同一套支付路由注册
 	router := gin.New()
 	api := router.Group("/api/v1")
 	payment.RegisterRoutes(api, db, paymentService, nil, nil)
@@ -91,6 +102,11 @@ func (suite *PaymentIntegrationTestSuite) createTestData() {
 	suite.db.Create(user)
 	suite.testUser = user
 
+	// 生成JWT令牌
+	token, err := auth.GenerateToken(user.ID, user.Username, user.Role)
+	suite.Require().NoError(err)
+	suite.token = token
+
 	// 创建测试订单
 	order := &model.Order{
 		OrderNo:       "TEST_ORDER_001",
@@ -146,7 +162,7 @@ func (suite *PaymentIntegrationTestSuite) TestCreatePayment() {
 	// 发送请求
 	req, _ := http.NewRequest("POST", "/api/v1/payments", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer test_token") // 模拟认证
+	req.Header.Set("Authorization", "Bearer "+suite.token)
 
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
@@ -191,7 +207,7 @@ func (suite *PaymentIntegrationTestSuite) TestQueryPayment() {
 
 	// 测试通过支付ID查询
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/payments/%d", payment.ID), nil)
-	req.Header.Set("Authorization", "Bearer test_token")
+	req.Header.Set("Authorization", "Bearer "+suite.token)
 
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
@@ -250,7 +266,7 @@ func (suite *PaymentIntegrationTestSuite) TestPaymentWorkflow() {
 	jsonData, _ := json.Marshal(createReq)
 	req, _ := http.NewRequest("POST", "/api/v1/payments", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer test_token")
+	req.Header.Set("Authorization", "Bearer "+suite.token)
 
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
@@ -263,7 +279,7 @@ func (suite *PaymentIntegrationTestSuite) TestPaymentWorkflow() {
 
 	// 2. 查询支付状态
 	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/payments/%d", paymentID), nil)
-	req.Header.Set("Authorization", "Bearer test_token")
+	req.Header.Set("Authorization", "Bearer "+suite.token)
 
 	w = httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
@@ -322,7 +338,7 @@ func (suite *PaymentIntegrationTestSuite) TestRefundPayment() {
 	jsonData, _ := json.Marshal(refundReq)
 	req, _ := http.NewRequest("POST", "/api/v1/payments/refund", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer test_token")
+	req.Header.Set("Authorization", "Bearer "+suite.token)
 
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
@@ -389,7 +405,7 @@ func (suite *PaymentIntegrationTestSuite) TestPaymentValidation() {
 			jsonData, _ := json.Marshal(tt.request)
 			req, _ := http.NewRequest("POST", "/api/v1/payments", bytes.NewBuffer(jsonData))
 			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("Authorization", "Bearer test_token")
+			req.Header.Set("Authorization", "Bearer "+suite.token)
 
 			w := httptest.NewRecorder()
 			suite.router.ServeHTTP(w, req)
@@ -437,7 +453,7 @@ func (suite *PaymentIntegrationTestSuite) TestConcurrentPayments() {
 			jsonData, _ := json.Marshal(request)
 			req, _ := http.NewRequest("POST", "/api/v1/payments", bytes.NewBuffer(jsonData))
 			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("Authorization", "Bearer test_token")
+			req.Header.Set("Authorization", "Bearer "+suite.token)
 
 			w := httptest.NewRecorder()
 			suite.router.ServeHTTP(w, req)
@@ -458,6 +474,25 @@ func (suite *PaymentIntegrationTestSuite) TestConcurrentPayments() {
 	suite.GreaterOrEqual(count, int64(5))
 }
 
+// TestPaymentRoutesRequireAuth 测试支付路由需要认证，回调路由无需认证
+func (suite *PaymentIntegrationTestSuite) TestPaymentRoutesRequireAuth() {
+	for _, path := range []string{"/api/v1/payments", fmt.Sprintf("/api/v1/payments/%d/qrcode", 1), "/api/v1/payments/refund/REFUND_001"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Equal(http.StatusUnauthorized, w.Code, path)
+	}
+
+	// 回调路由已注册且无需认证，未签名的通知由处理器拒绝而不是返回401或404
+	for _, path := range []string{"/api/v1/payments/callback/wechat/refund", "/api/v1/payments/callback/wechat/complaint"} {
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString("{}"))
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.NotEqual(http.StatusUnauthorized, w.Code, path)
+		suite.NotEqual(http.StatusNotFound, w.Code, path)
+	}
+}
+
 // 运行集成测试套件
 func TestPaymentIntegrationSuite(t *testing.T) {
 	suite.Run(t, new(PaymentIntegrationTestSuite))